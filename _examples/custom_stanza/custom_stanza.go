@@ -51,5 +51,5 @@ func (c CustomPayload) GetSet() *stanza.ResultSet {
 	return nil
 }
 func init() {
-	stanza.TypeRegistry.MapExtension(stanza.PKTIQ, xml.Name{Space: "my:custom:payload", Local: "query"}, CustomPayload{})
+	stanza.TypeRegistry.MustRegister(stanza.PKTIQ, xml.Name{Space: "my:custom:payload", Local: "query"}, CustomPayload{})
 }
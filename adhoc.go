@@ -0,0 +1,143 @@
+package xmpp
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Ad-Hoc Commands session (XEP-0050)
+
+// AdHocStatus is the status of an ad-hoc command session, as reported by the responder.
+type AdHocStatus string
+
+const (
+	AdHocExecuting AdHocStatus = stanza.CommandStatusExecuting
+	AdHocCompleted AdHocStatus = stanza.CommandStatusCompleted
+	AdHocCanceled  AdHocStatus = stanza.CommandStatusCancelled
+)
+
+var (
+	ErrAdHocSessionClosed  = errors.New("ad-hoc command session is closed")
+	ErrAdHocSessionExpired = errors.New("ad-hoc command session expired")
+)
+
+// AdHocSession tracks the state of a multi-stage ad-hoc command execution.
+// The session is closed once the command is completed or canceled, or when it expired.
+type AdHocSession struct {
+	// To is the JID of the command responder
+	To        string
+	SessionID string
+	Node      string
+	// Actions allowed by the responder for the current stage, and for the stage before it
+	CurrentActions  []string
+	PreviousActions []string
+	CurrentForm     *stanza.Form
+	Notes           []stanza.Note
+	Status          AdHocStatus
+	// SessionExpired is set when the responder reported the session as expired
+	SessionExpired bool
+
+	sender Sender
+	closed bool
+}
+
+// ExecuteAdHocCommand starts the execution of the command node on the responder to.
+// The returned session holds the first stage of the command.
+func ExecuteAdHocCommand(ctx context.Context, s Sender, to, node string) (*AdHocSession, error) {
+	session := &AdHocSession{To: to, Node: node, sender: s}
+	if _, err := session.send(ctx, stanza.CommandActionExecute, nil); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Closed tells if the session is over.
+func (a *AdHocSession) Closed() bool {
+	return a.closed
+}
+
+// Next submits the filled form and moves to the next stage of the command.
+func (a *AdHocSession) Next(ctx context.Context, form stanza.Form) (AdHocSession, error) {
+	return a.send(ctx, stanza.CommandActionNext, &form)
+}
+
+// Complete submits the filled form and asks the responder to complete the command.
+func (a *AdHocSession) Complete(ctx context.Context, form stanza.Form) (AdHocSession, error) {
+	return a.send(ctx, stanza.CommandActionComplete, &form)
+}
+
+// Prev goes back to the previous stage of the command.
+func (a *AdHocSession) Prev(ctx context.Context) (AdHocSession, error) {
+	return a.send(ctx, stanza.CommandActionPrevious, nil)
+}
+
+// Cancel cancels the command execution and closes the session.
+func (a *AdHocSession) Cancel(ctx context.Context) error {
+	_, err := a.send(ctx, stanza.CommandActionCancel, nil)
+	a.closed = true
+	return err
+}
+
+// send sends the command action and updates the session state with the response.
+func (a *AdHocSession) send(ctx context.Context, action string, form *stanza.Form) (AdHocSession, error) {
+	if a.closed {
+		return *a, ErrAdHocSessionClosed
+	}
+
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeSet, To: a.To})
+	if err != nil {
+		return *a, err
+	}
+	cmd := &stanza.Command{
+		XMLName:   xml.Name{Space: stanza.NSCommands, Local: "command"},
+		Node:      a.Node,
+		SessionId: a.SessionID,
+		Action:    action,
+	}
+	if form != nil {
+		form.Type = stanza.FormTypeSubmit
+		cmd.CommandElement = form
+	}
+	iq.Payload = cmd
+
+	result, err := sendIQSync(ctx, a.sender, iq)
+	if err != nil {
+		return *a, err
+	}
+	if result.Type == stanza.IQTypeError {
+		if result.Error != nil && result.Error.Reason == "session-expired" {
+			a.SessionExpired = true
+			a.closed = true
+			return *a, ErrAdHocSessionExpired
+		}
+		return *a, iqError(result)
+	}
+
+	resp, ok := result.Payload.(*stanza.Command)
+	if !ok {
+		return *a, errors.New("ad-hoc command response does not contain a command")
+	}
+	a.update(resp)
+	return *a, nil
+}
+
+func (a *AdHocSession) update(resp *stanza.Command) {
+	if resp.SessionId != "" {
+		a.SessionID = resp.SessionId
+	}
+	a.PreviousActions = a.CurrentActions
+	a.CurrentActions = resp.Actions.List()
+	a.Notes = resp.Notes
+	a.CurrentForm = nil
+	if f, ok := resp.CommandElement.(*stanza.Form); ok {
+		a.CurrentForm = f
+	}
+	a.Status = AdHocStatus(resp.Status)
+	if a.Status == AdHocCompleted || a.Status == AdHocCanceled {
+		a.closed = true
+	}
+}
@@ -0,0 +1,161 @@
+package xmpp
+
+import (
+	"context"
+	"encoding/xml"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// scriptedIQSender answers each IQ with the next response of its script.
+type scriptedIQSender struct {
+	SenderMock
+	t         *testing.T
+	responses []string
+	requests  []*stanza.IQ
+}
+
+func (s *scriptedIQSender) SendIQ(ctx context.Context, iq *stanza.IQ) (chan stanza.IQ, error) {
+	s.requests = append(s.requests, iq)
+	if len(s.responses) == 0 {
+		s.t.Fatalf("unexpected IQ sent: %+v", iq)
+	}
+	var answer stanza.IQ
+	if err := xml.Unmarshal([]byte(s.responses[0]), &answer); err != nil {
+		s.t.Fatalf("invalid scripted response: %v", err)
+	}
+	s.responses = s.responses[1:]
+	answer.Id = iq.Id
+	res := make(chan stanza.IQ, 1)
+	res <- answer
+	return res, nil
+}
+
+// Multi-stage "Configure Service" workflow, from XEP-0050 - 3.4 Executing Commands
+var configureServiceScript = []string{
+	`<iq type='result' from='responder@domain' to='requester@domain'>
+  <command xmlns='http://jabber.org/protocol/commands' sessionid='config:20020923T213616Z-700' node='config' status='executing'>
+    <actions execute='next'><next/></actions>
+    <x xmlns='jabber:x:data' type='form'>
+      <title>Configure Service</title>
+      <field var='service' label='Service' type='list-single'><option><value>httpd</value></option></field>
+    </x>
+  </command>
+</iq>`,
+	`<iq type='result' from='responder@domain' to='requester@domain'>
+  <command xmlns='http://jabber.org/protocol/commands' sessionid='config:20020923T213616Z-700' node='config' status='executing'>
+    <actions execute='complete'><prev/><complete/></actions>
+    <x xmlns='jabber:x:data' type='form'>
+      <title>Configure Service</title>
+      <field var='state' label='Run State' type='list-single'><option><value>off</value></option><option><value>on</value></option></field>
+    </x>
+  </command>
+</iq>`,
+	`<iq type='result' from='responder@domain' to='requester@domain'>
+  <command xmlns='http://jabber.org/protocol/commands' sessionid='config:20020923T213616Z-700' node='config' status='completed'>
+    <note type='info'>Service 'httpd' has been configured.</note>
+  </command>
+</iq>`,
+}
+
+func TestAdHocSessionWorkflow(t *testing.T) {
+	sender := &scriptedIQSender{t: t, responses: configureServiceScript}
+	ctx := context.Background()
+
+	session, err := ExecuteAdHocCommand(ctx, sender, "responder@domain", "config")
+	if err != nil {
+		t.Fatalf("could not execute command: %v", err)
+	}
+	if session.SessionID != "config:20020923T213616Z-700" || session.Status != AdHocExecuting {
+		t.Fatalf("unexpected first stage: %+v", session)
+	}
+	if len(session.CurrentActions) != 1 || session.CurrentActions[0] != stanza.CommandActionNext {
+		t.Errorf("unexpected actions: %v", session.CurrentActions)
+	}
+	if session.CurrentForm == nil || session.CurrentForm.Field("service") == nil {
+		t.Fatalf("first stage form not found")
+	}
+
+	// Stage 2
+	form := stanza.NewForm([]*stanza.Field{{Var: "service", ValuesList: []string{"httpd"}}}, stanza.FormTypeSubmit)
+	state, err := session.Next(ctx, *form)
+	if err != nil {
+		t.Fatalf("could not go to next stage: %v", err)
+	}
+	if len(state.CurrentActions) != 2 || state.PreviousActions[0] != stanza.CommandActionNext {
+		t.Errorf("unexpected actions: current %v, previous %v", state.CurrentActions, state.PreviousActions)
+	}
+	if state.CurrentForm == nil || state.CurrentForm.Field("state") == nil {
+		t.Fatalf("second stage form not found")
+	}
+	cmd := sender.requests[1].Payload.(*stanza.Command)
+	if cmd.Action != stanza.CommandActionNext || cmd.SessionId != "config:20020923T213616Z-700" {
+		t.Errorf("unexpected next request: %+v", cmd)
+	}
+	if f, ok := cmd.CommandElement.(*stanza.Form); !ok || f.Type != stanza.FormTypeSubmit {
+		t.Errorf("next request should carry the submitted form")
+	}
+
+	// Stage 3: completion
+	form = stanza.NewForm([]*stanza.Field{{Var: "state", ValuesList: []string{"on"}}}, stanza.FormTypeSubmit)
+	state, err = session.Complete(ctx, *form)
+	if err != nil {
+		t.Fatalf("could not complete command: %v", err)
+	}
+	if state.Status != AdHocCompleted || !session.Closed() {
+		t.Errorf("session should be completed and closed: %+v", state)
+	}
+	if len(state.Notes) != 1 || state.Notes[0].Type != stanza.CommandNoteTypeInfo {
+		t.Errorf("unexpected notes: %+v", state.Notes)
+	}
+
+	if _, err = session.Prev(ctx); err != ErrAdHocSessionClosed {
+		t.Errorf("closed session should not send commands, got %v", err)
+	}
+}
+
+func TestAdHocSessionExpired(t *testing.T) {
+	sender := &scriptedIQSender{t: t, responses: []string{
+		configureServiceScript[0],
+		`<iq type='error' from='responder@domain' to='requester@domain'>
+  <error type='modify'>
+    <bad-request xmlns='urn:ietf:params:xml:ns:xmpp-stanzas'/>
+    <session-expired xmlns='http://jabber.org/protocol/commands'/>
+  </error>
+</iq>`,
+	}}
+	ctx := context.Background()
+
+	session, err := ExecuteAdHocCommand(ctx, sender, "responder@domain", "config")
+	if err != nil {
+		t.Fatalf("could not execute command: %v", err)
+	}
+	if _, err = session.Prev(ctx); err != ErrAdHocSessionExpired {
+		t.Errorf("expected session expired error, got %v", err)
+	}
+	if !session.SessionExpired || !session.Closed() {
+		t.Errorf("session should be expired and closed")
+	}
+}
+
+func TestAdHocSessionCancel(t *testing.T) {
+	sender := &scriptedIQSender{t: t, responses: []string{
+		configureServiceScript[0],
+		`<iq type='result' from='responder@domain' to='requester@domain'>
+  <command xmlns='http://jabber.org/protocol/commands' sessionid='config:20020923T213616Z-700' node='config' status='canceled'/>
+</iq>`,
+	}}
+	ctx := context.Background()
+
+	session, err := ExecuteAdHocCommand(ctx, sender, "responder@domain", "config")
+	if err != nil {
+		t.Fatalf("could not execute command: %v", err)
+	}
+	if err = session.Cancel(ctx); err != nil {
+		t.Fatalf("could not cancel command: %v", err)
+	}
+	if !session.Closed() || session.Status != AdHocCanceled {
+		t.Errorf("session should be canceled and closed: %+v", session)
+	}
+}
@@ -0,0 +1,234 @@
+package xmpp
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Message archive export and import
+
+// ArchiveFormat is the encoding of an exported message archive. Both formats write the messages
+// one after the other, so that archives can be processed as a stream with an ArchiveDecoder.
+type ArchiveFormat int
+
+const (
+	// ArchiveFormatXML writes each message as a XEP-0313 result element, wrapping the message in a
+	// forwarded element with its delay. Elements are followed by a newline, but the raw XML of
+	// extensions may contain line breaks: the archive must be read with an XML decoder, not line by line.
+	ArchiveFormatXML ArchiveFormat = iota
+	// ArchiveFormatJSON writes each message as a JSON object, with the XML of the message. Newlines
+	// are escaped in JSON strings, so each message is written on a single line.
+	ArchiveFormatJSON
+)
+
+// ArchivedMessage is a message of an archive, with the time it was originally sent and its id in
+// the archive, usually the stanza-id assigned by the server.
+type ArchivedMessage struct {
+	ID      string
+	Stamp   time.Time
+	Message stanza.Message
+}
+
+// NewArchivedMessage converts a message received in answer to an archive query. The time of the
+// message is taken from the delay of the forwarded element, or from the delay of the message.
+func NewArchivedMessage(result stanza.MAMResult) (ArchivedMessage, error) {
+	msg, ok := result.Forwarded.Message()
+	if !ok {
+		return ArchivedMessage{}, errors.New("archive result does not contain a message")
+	}
+	archived := ArchivedMessage{ID: result.Id, Message: msg}
+	delay := result.Forwarded.Delay
+	if delay == nil {
+		var d stanza.Delay
+		if msg.Get(&d) {
+			delay = &d
+		}
+	}
+	if delay != nil {
+		stamp, err := delay.Time()
+		if err != nil {
+			return ArchivedMessage{}, err
+		}
+		archived.Stamp = stamp
+	}
+	return archived, nil
+}
+
+// archiveRecord is a message of an archive in the JSON format.
+type archiveRecord struct {
+	ID      string `json:"id,omitempty"`
+	Stamp   string `json:"stamp,omitempty"`
+	Message string `json:"message"`
+}
+
+// ArchiveEncoder writes messages to an archive.
+type ArchiveEncoder struct {
+	w      io.Writer
+	format ArchiveFormat
+	json   *json.Encoder
+}
+
+// NewArchiveEncoder returns an encoder writing messages in format to w. Messages are written as
+// they are encoded: the encoder does not buffer the output.
+func NewArchiveEncoder(w io.Writer, format ArchiveFormat) *ArchiveEncoder {
+	e := &ArchiveEncoder{w: w, format: format}
+	if format == ArchiveFormatJSON {
+		e.json = json.NewEncoder(w)
+		e.json.SetEscapeHTML(false)
+	}
+	return e
+}
+
+// Encode writes the message to the archive, with all its extensions.
+func (e *ArchiveEncoder) Encode(m ArchivedMessage) error {
+	switch e.format {
+	case ArchiveFormatXML:
+		result := stanza.MAMResult{
+			XMLName:   xml.Name{Space: stanza.NSMam, Local: "result"},
+			Id:        m.ID,
+			Forwarded: stanza.Forwarded{Stanza: m.Message},
+		}
+		if !m.Stamp.IsZero() {
+			delay := stanza.NewDelay(m.Stamp, "")
+			result.Forwarded.Delay = &delay
+		}
+		data, err := xml.Marshal(result)
+		if err != nil {
+			return err
+		}
+		_, err = e.w.Write(append(data, '\n'))
+		return err
+	case ArchiveFormatJSON:
+		data, err := xml.Marshal(m.Message)
+		if err != nil {
+			return err
+		}
+		record := archiveRecord{ID: m.ID, Message: string(data)}
+		if !m.Stamp.IsZero() {
+			record.Stamp = m.Stamp.UTC().Format(time.RFC3339Nano)
+		}
+		return e.json.Encode(record)
+	default:
+		return fmt.Errorf("unknown archive format %d", e.format)
+	}
+}
+
+// ArchiveDecoder reads messages from an archive.
+type ArchiveDecoder struct {
+	format ArchiveFormat
+	xml    *xml.Decoder
+	json   *json.Decoder
+	// Number of messages read, to locate errors
+	count int
+}
+
+// NewArchiveDecoder returns a decoder reading messages in format from r.
+func NewArchiveDecoder(r io.Reader, format ArchiveFormat) *ArchiveDecoder {
+	d := &ArchiveDecoder{format: format}
+	if format == ArchiveFormatJSON {
+		d.json = json.NewDecoder(r)
+	} else {
+		d.xml = xml.NewDecoder(r)
+	}
+	return d
+}
+
+// Decode reads the next message of the archive. Extensions are decoded with the types registered
+// in stanza.TypeRegistry, and unknown extensions are kept as *stanza.Node. It returns io.EOF at the
+// end of the archive.
+func (d *ArchiveDecoder) Decode() (ArchivedMessage, error) {
+	var m ArchivedMessage
+	var err error
+	switch d.format {
+	case ArchiveFormatXML:
+		m, err = d.decodeXML()
+	case ArchiveFormatJSON:
+		m, err = d.decodeJSON()
+	default:
+		return ArchivedMessage{}, fmt.Errorf("unknown archive format %d", d.format)
+	}
+	if err == io.EOF {
+		return ArchivedMessage{}, err
+	}
+	d.count++
+	if err != nil {
+		return ArchivedMessage{}, fmt.Errorf("invalid archive message %d: %w", d.count, err)
+	}
+	return m, nil
+}
+
+func (d *ArchiveDecoder) decodeXML() (ArchivedMessage, error) {
+	for {
+		t, err := d.xml.Token()
+		if err != nil {
+			return ArchivedMessage{}, err
+		}
+		start, ok := t.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Space != stanza.NSMam || start.Name.Local != "result" {
+			return ArchivedMessage{}, fmt.Errorf("unexpected element %s", start.Name.Local)
+		}
+		var result stanza.MAMResult
+		if err = d.xml.DecodeElement(&result, &start); err != nil {
+			return ArchivedMessage{}, err
+		}
+		return NewArchivedMessage(result)
+	}
+}
+
+func (d *ArchiveDecoder) decodeJSON() (ArchivedMessage, error) {
+	var record archiveRecord
+	if err := d.json.Decode(&record); err != nil {
+		return ArchivedMessage{}, err
+	}
+	m := ArchivedMessage{ID: record.ID}
+	if record.Stamp != "" {
+		stamp, err := time.Parse(time.RFC3339Nano, record.Stamp)
+		if err != nil {
+			return ArchivedMessage{}, err
+		}
+		m.Stamp = stamp
+	}
+	if err := xml.Unmarshal([]byte(record.Message), &m.Message); err != nil {
+		return ArchivedMessage{}, err
+	}
+	return m, nil
+}
+
+// WriteArchive writes all the messages to w. Use an ArchiveEncoder to write messages as they are
+// retrieved.
+func WriteArchive(w io.Writer, format ArchiveFormat, messages []ArchivedMessage) error {
+	e := NewArchiveEncoder(w, format)
+	for _, m := range messages {
+		if err := e.Encode(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadArchive reads all the messages of an archive in memory. Use an ArchiveDecoder to process
+// large archives as a stream.
+func ReadArchive(r io.Reader, format ArchiveFormat) ([]ArchivedMessage, error) {
+	d := NewArchiveDecoder(r, format)
+	var messages []ArchivedMessage
+	for {
+		m, err := d.Decode()
+		if err == io.EOF {
+			return messages, nil
+		}
+		if err != nil {
+			return messages, err
+		}
+		messages = append(messages, m)
+	}
+}
@@ -0,0 +1,118 @@
+package xmpp
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func archiveMessages(t *testing.T) []ArchivedMessage {
+	first := parseMessage(t, `<message xmlns='jabber:client' from='witch@shakespeare.lit' to='macbeth@shakespeare.lit' id='m1' type='chat'>
+  <body>Hail to thee, Macbeth,
+thane of Glamis!</body>
+  <stanza-id xmlns='urn:xmpp:sid:0' id='28482-98726-73623' by='macbeth@shakespeare.lit'/>
+  <unknown xmlns='urn:example:unknown' level='3'><level>three</level></unknown>
+</message>`)
+	second := stanza.NewMessage(stanza.Attrs{From: "macbeth@shakespeare.lit/castle", To: "witch@shakespeare.lit", Type: stanza.MessageTypeChat})
+	second.Body = "Stay, you imperfect speakers, tell me more."
+	second.Extensions = append(second.Extensions, &stanza.ReceiptRequest{XMLName: xml.Name{Space: stanza.NSMsgReceipts, Local: "request"}})
+	return []ArchivedMessage{
+		{ID: "28482-98726-73623", Stamp: time.Date(2010, 7, 10, 23, 8, 25, 0, time.UTC), Message: first},
+		{ID: "09af3-cc343-b409f", Stamp: time.Date(2010, 7, 10, 23, 9, 32, 500000000, time.UTC), Message: second},
+	}
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	for _, format := range []ArchiveFormat{ArchiveFormatXML, ArchiveFormatJSON} {
+		messages := archiveMessages(t)
+		var buf bytes.Buffer
+		if err := WriteArchive(&buf, format, messages); err != nil {
+			t.Fatalf("format %d: could not write archive: %v", format, err)
+		}
+		if lines := strings.Count(buf.String(), "\n"); lines != len(messages) {
+			t.Errorf("format %d: expected one message per line, got %d lines:\n%s", format, lines, buf.String())
+		}
+
+		read, err := ReadArchive(&buf, format)
+		if err != nil {
+			t.Fatalf("format %d: could not read archive: %v", format, err)
+		}
+		if len(read) != len(messages) {
+			t.Fatalf("format %d: expected %d messages, got %d", format, len(messages), len(read))
+		}
+		for i, m := range read {
+			if m.ID != messages[i].ID || !m.Stamp.Equal(messages[i].Stamp) {
+				t.Errorf("format %d: unexpected message %d: %s %s", format, i, m.ID, m.Stamp)
+			}
+			if m.Message.From != messages[i].Message.From || m.Message.Body != messages[i].Message.Body {
+				t.Errorf("format %d: unexpected message %d: %+v", format, i, m.Message)
+			}
+		}
+
+		if sid := read[0].Message.GetStanzaId("macbeth@shakespeare.lit"); sid != "28482-98726-73623" {
+			t.Errorf("format %d: stanza-id should be kept, got %q", format, sid)
+		}
+		var node stanza.Node
+		if !read[0].Message.Get(&node) || node.XMLName.Space != "urn:example:unknown" || len(node.Nodes) != 1 {
+			t.Errorf("format %d: unknown extension should be kept: %+v", format, read[0].Message.Extensions)
+		}
+		var receipt stanza.ReceiptRequest
+		if !read[1].Message.Get(&receipt) {
+			t.Errorf("format %d: registered extensions should be decoded: %+v", format, read[1].Message.Extensions)
+		}
+	}
+}
+
+func TestArchiveDecoderStream(t *testing.T) {
+	// Archive results are decoded as received from the server
+	raw := `<result xmlns='urn:xmpp:mam:2' id='28482-98726-73623'>
+  <forwarded xmlns='urn:xmpp:forward:0'>
+    <delay xmlns='urn:xmpp:delay' stamp='2010-07-10T23:08:25Z'/>
+    <message xmlns='jabber:client' from='witch@shakespeare.lit'><body>Hail to thee</body></message>
+  </forwarded>
+</result>
+<result xmlns='urn:xmpp:mam:2' id='09af3-cc343-b409f'>
+  <forwarded xmlns='urn:xmpp:forward:0'>
+    <message xmlns='jabber:client' from='macbeth@shakespeare.lit'>
+      <body>Stay</body>
+      <delay xmlns='urn:xmpp:delay' stamp='2010-07-10T23:09:32.123Z'/>
+    </message>
+  </forwarded>
+</result>
+<message xmlns='jabber:client'><body>Not an archive result</body></message>`
+	d := NewArchiveDecoder(strings.NewReader(raw), ArchiveFormatXML)
+	m, err := d.Decode()
+	if err != nil || m.ID != "28482-98726-73623" || !m.Stamp.Equal(time.Date(2010, 7, 10, 23, 8, 25, 0, time.UTC)) {
+		t.Fatalf("unexpected first message %+v: %v", m, err)
+	}
+	// Without a forwarded delay, the delay of the message is used
+	m, err = d.Decode()
+	if err != nil || m.Message.Body != "Stay" || !m.Stamp.Equal(time.Date(2010, 7, 10, 23, 9, 32, 123000000, time.UTC)) {
+		t.Fatalf("unexpected second message %+v: %v", m, err)
+	}
+	if _, err = d.Decode(); err == nil || err == io.EOF {
+		t.Errorf("unexpected elements should be rejected, got %v", err)
+	}
+}
+
+func TestArchiveDecoderInvalidJSON(t *testing.T) {
+	raw := `{"id":"1","stamp":"2010-07-10T23:08:25Z","message":"<message><body>One</body></message>"}
+{"id":"2","stamp":"yesterday","message":"<message><body>Two</body></message>"}
+`
+	d := NewArchiveDecoder(strings.NewReader(raw), ArchiveFormatJSON)
+	if m, err := d.Decode(); err != nil || m.Message.Body != "One" {
+		t.Fatalf("unexpected first message %+v: %v", m, err)
+	}
+	_, err := d.Decode()
+	if err == nil || !strings.Contains(err.Error(), "message 2") {
+		t.Errorf("invalid stamp should be reported with the message position, got %v", err)
+	}
+	if _, err = d.Decode(); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sync"
 
 	"gosrc.io/xmpp/stanza"
 )
@@ -17,6 +18,11 @@ type Credential struct {
 	mechanisms []string
 }
 
+// scramMechanisms are the SCRAM mechanisms supported by the client, strongest first.
+var scramMechanisms = []string{"SCRAM-SHA-512", "SCRAM-SHA-256", "SCRAM-SHA-1"}
+
+// Password creates a password credential, authenticating with the PLAIN mechanism. SCRAM mechanisms
+// can be preferred with WithMechanisms, or with the WithSASLMechanisms option.
 func Password(pwd string) Credential {
 	credential := Credential{
 		secret:     pwd,
@@ -25,6 +31,15 @@ func Password(pwd string) Credential {
 	return credential
 }
 
+// WithMechanisms returns a copy of the credential authenticating with the first of the mechanisms
+// supported by the server, in order of preference. For example:
+//
+//	xmpp.Password(pwd).WithMechanisms("SCRAM-SHA-256", "SCRAM-SHA-1", "PLAIN")
+func (c Credential) WithMechanisms(mechanisms ...string) Credential {
+	c.mechanisms = append([]string(nil), mechanisms...)
+	return c
+}
+
 func OAuthToken(token string) Credential {
 	credential := Credential{
 		secret:     token,
@@ -33,10 +48,22 @@ func OAuthToken(token string) Credential {
 	return credential
 }
 
+// WithSASLMechanisms sets the SASL mechanisms the client authenticates with, in order of preference.
+// For example, to prefer SCRAM to PLAIN with a password credential:
+//
+//	xmpp.WithSASLMechanisms("SCRAM-SHA-512", "SCRAM-SHA-256", "SCRAM-SHA-1", "PLAIN")
+func WithSASLMechanisms(mechanisms ...string) Option {
+	return func(config *Config) {
+		config.SASLMechanisms = mechanisms
+	}
+}
+
 // ============================================================================
 // Authentication flow for SASL mechanisms
 
-func authSASL(socket io.ReadWriter, decoder *xml.Decoder, f stanza.StreamFeatures, user string, credential Credential) (err error) {
+// authSASL authenticates with the first mechanism of the credential supported by the server,
+// and returns the mechanism used.
+func authSASL(socket io.ReadWriter, decoder *xml.Decoder, f stanza.StreamFeatures, user string, credential Credential) (string, error) {
 	var matchingMech string
 	for _, mech := range credential.mechanisms {
 		if isSupportedMech(mech, f.Mechanisms.Mechanism) {
@@ -47,11 +74,12 @@ func authSASL(socket io.ReadWriter, decoder *xml.Decoder, f stanza.StreamFeature
 
 	switch matchingMech {
 	case "PLAIN", "X-OAUTH2":
-		// TODO: Implement other type of SASL mechanisms
-		return authPlain(socket, decoder, matchingMech, user, credential.secret)
+		return matchingMech, authPlain(socket, decoder, matchingMech, user, credential.secret)
+	case "SCRAM-SHA-1", "SCRAM-SHA-256", "SCRAM-SHA-512":
+		return matchingMech, authSCRAM(socket, decoder, matchingMech, user, credential.secret, f)
 	default:
 		err := fmt.Errorf("no matching authentication (%v) supported by server: %v", credential.mechanisms, f.Mechanisms.Mechanism)
-		return NewConnError(err, true)
+		return "", NewConnError(err, true)
 	}
 }
 
@@ -103,3 +131,67 @@ func isSupportedMech(mech string, mechanisms []string) bool {
 	}
 	return false
 }
+
+// ============================================================================
+// Mechanism pinning
+
+// MechanismStore remembers the strongest SASL mechanism negotiated with each server, so that the
+// client can be warned when a weaker mechanism is negotiated later on.
+type MechanismStore interface {
+	// StrongestMechanism returns the strongest mechanism negotiated with the server, or an empty string.
+	StrongestMechanism(domain string) string
+	SetStrongestMechanism(domain, mechanism string)
+}
+
+// MemoryMechanismStore is a MechanismStore keeping mechanisms in memory.
+type MemoryMechanismStore struct {
+	mu         sync.Mutex
+	mechanisms map[string]string
+}
+
+func NewMemoryMechanismStore() *MemoryMechanismStore {
+	return &MemoryMechanismStore{mechanisms: make(map[string]string)}
+}
+
+func (m *MemoryMechanismStore) StrongestMechanism(domain string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mechanisms[domain]
+}
+
+func (m *MemoryMechanismStore) SetStrongestMechanism(domain, mechanism string) {
+	m.mu.Lock()
+	m.mechanisms[domain] = mechanism
+	m.mu.Unlock()
+}
+
+// mechanismStrength ranks the supported mechanisms. Unknown mechanisms are ranked as the weakest.
+func mechanismStrength(mech string) int {
+	switch mech {
+	case "PLAIN", "X-OAUTH2":
+		return 1
+	case "SCRAM-SHA-1":
+		return 2
+	case "SCRAM-SHA-256":
+		return 3
+	case "SCRAM-SHA-512":
+		return 4
+	}
+	return 0
+}
+
+// pinMechanism records the mechanism negotiated with the server, and returns a warning if it is
+// weaker than the strongest mechanism previously negotiated.
+func pinMechanism(store MechanismStore, domain, mech string) string {
+	if store == nil {
+		return ""
+	}
+	strongest := store.StrongestMechanism(domain)
+	if mechanismStrength(mech) < mechanismStrength(strongest) {
+		return fmt.Sprintf("SASL mechanism %s negotiated with %s is weaker than previously negotiated %s", mech, domain, strongest)
+	}
+	if mech != strongest {
+		store.SetStrongestMechanism(domain, mech)
+	}
+	return ""
+}
@@ -0,0 +1,285 @@
+package xmpp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"hash"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// SCRAM authentication (RFC 5802, RFC 7677), with downgrade protection (XEP-0474)
+
+// ErrSASLDowngrade is returned when the mechanisms the server says it offered do not match the ones
+// received in the stream features, meaning that they may have been tampered with.
+var ErrSASLDowngrade = errors.New("SASL mechanism downgrade detected")
+
+var scramHashes = map[string]func() hash.Hash{
+	"SCRAM-SHA-1":   sha1.New,
+	"SCRAM-SHA-256": sha256.New,
+	"SCRAM-SHA-512": sha512.New,
+}
+
+// scramClient computes the client messages of a SCRAM exchange. Channel binding is not supported.
+type scramClient struct {
+	hash     func() hash.Hash
+	user     string
+	password string
+	nonce    string
+	// Mechanisms and channel binding types received in stream features, checked against
+	// the downgrade protection attribute sent by the server
+	mechanisms      []string
+	channelBindings []string
+
+	clientFirstBare string
+	serverSignature []byte
+}
+
+func newScramClient(mech, user, password string, f stanza.StreamFeatures) (*scramClient, error) {
+	h, ok := scramHashes[mech]
+	if !ok {
+		return nil, errors.New("unsupported SCRAM mechanism: " + mech)
+	}
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	return &scramClient{
+		hash:            h,
+		user:            user,
+		password:        password,
+		nonce:           base64.RawStdEncoding.EncodeToString(buf),
+		mechanisms:      f.Mechanisms.Mechanism,
+		channelBindings: f.ChannelBindingTypes(),
+	}, nil
+}
+
+// clientFirst returns the client-first-message. When the mechanisms offered in stream features are
+// known, the client indicates that it supports downgrade protection (XEP-0474) with the d extension
+// attribute, carrying the hash it expects from the server.
+func (c *scramClient) clientFirst() string {
+	escaper := strings.NewReplacer("=", "=3D", ",", "=2C")
+	c.clientFirstBare = "n=" + escaper.Replace(c.user) + ",r=" + c.nonce
+	if len(c.mechanisms) > 0 {
+		c.clientFirstBare += ",d=" + c.downgradeHash()
+	}
+	return "n,," + c.clientFirstBare
+}
+
+// clientFinal checks the server-first-message and returns the client-final-message.
+func (c *scramClient) clientFinal(serverFirst string) (string, error) {
+	attrs := parseScramAttrs(serverFirst)
+	if _, ok := attrs["m"]; ok {
+		return "", errors.New("unsupported SCRAM mandatory extension")
+	}
+	nonce := attrs["r"]
+	if !strings.HasPrefix(nonce, c.nonce) || len(nonce) == len(c.nonce) {
+		return "", errors.New("invalid SCRAM server nonce")
+	}
+	salt, err := base64.StdEncoding.DecodeString(attrs["s"])
+	if err != nil || len(salt) == 0 {
+		return "", errors.New("invalid SCRAM salt")
+	}
+	iterations, err := strconv.Atoi(attrs["i"])
+	if err != nil || iterations <= 0 {
+		return "", errors.New("invalid SCRAM iteration count")
+	}
+	if d, ok := attrs["d"]; ok && !hmac.Equal([]byte(d), []byte(c.downgradeHash())) {
+		return "", ErrSASLDowngrade
+	}
+
+	saltedPassword := c.hi([]byte(c.password), salt, iterations)
+	clientKey := c.hmac(saltedPassword, []byte("Client Key"))
+	h := c.hash()
+	h.Write(clientKey)
+	storedKey := h.Sum(nil)
+
+	clientFinalBare := "c=" + base64.StdEncoding.EncodeToString([]byte("n,,")) + ",r=" + nonce
+	authMessage := []byte(c.clientFirstBare + "," + serverFirst + "," + clientFinalBare)
+
+	proof := c.hmac(storedKey, authMessage)
+	for i := range proof {
+		proof[i] ^= clientKey[i]
+	}
+	c.serverSignature = c.hmac(c.hmac(saltedPassword, []byte("Server Key")), authMessage)
+	return clientFinalBare + ",p=" + base64.StdEncoding.EncodeToString(proof), nil
+}
+
+// verifyServerFinal checks the server signature sent in the server-final-message.
+func (c *scramClient) verifyServerFinal(serverFinal string) error {
+	attrs := parseScramAttrs(serverFinal)
+	if e, ok := attrs["e"]; ok {
+		return errors.New("SCRAM authentication failed: " + e)
+	}
+	signature, err := base64.StdEncoding.DecodeString(attrs["v"])
+	if err != nil || c.serverSignature == nil || subtle.ConstantTimeCompare(signature, c.serverSignature) != 1 {
+		return errors.New("invalid SCRAM server signature")
+	}
+	return nil
+}
+
+// downgradeHash is the value of the XEP-0474 downgrade protection attribute, computed from the
+// mechanisms and channel binding types received in stream features.
+func (c *scramClient) downgradeHash() string {
+	mechanisms := append([]string(nil), c.mechanisms...)
+	sort.Strings(mechanisms)
+	channelBindings := append([]string(nil), c.channelBindings...)
+	sort.Strings(channelBindings)
+
+	h := c.hash()
+	h.Write([]byte(strings.Join(mechanisms, ",") + "|" + strings.Join(channelBindings, ",")))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func (c *scramClient) hmac(key, data []byte) []byte {
+	mac := hmac.New(c.hash, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// hi is the PBKDF2 based Hi function defined in RFC 5802.
+func (c *scramClient) hi(password, salt []byte, iterations int) []byte {
+	return pbkdf2Block(c.hash, password, salt, iterations)
+}
+
+// pbkdf2Block computes the first block of the PBKDF2 key derivation (RFC 8018), as long as the
+// output of the hash function.
+func pbkdf2Block(h func() hash.Hash, password, salt []byte, iterations int) []byte {
+	mac := hmac.New(h, password)
+	mac.Write(salt)
+	mac.Write([]byte{0, 0, 0, 1})
+	u := mac.Sum(nil)
+	result := append([]byte(nil), u...)
+	for i := 1; i < iterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range result {
+			result[j] ^= u[j]
+		}
+	}
+	return result
+}
+
+func parseScramAttrs(msg string) map[string]string {
+	attrs := make(map[string]string)
+	for _, part := range strings.Split(msg, ",") {
+		if len(part) >= 2 && part[1] == '=' {
+			attrs[part[:1]] = part[2:]
+		}
+	}
+	return attrs
+}
+
+// authSCRAM runs the SCRAM exchange on the socket.
+func authSCRAM(socket io.ReadWriter, decoder *xml.Decoder, mech string, user string, secret string, f stanza.StreamFeatures) error {
+	client, err := newScramClient(mech, user, secret, f)
+	if err != nil {
+		return err
+	}
+	if err = writeSASL(socket, stanza.SASLAuth{Mechanism: mech, Value: encodeSASL(client.clientFirst())}); err != nil {
+		return err
+	}
+
+	serverFirst, err := readSASLChallenge(decoder)
+	if err != nil {
+		return err
+	}
+	clientFinal, err := client.clientFinal(serverFirst)
+	if err != nil {
+		return NewConnError(err, true)
+	}
+	if err = writeSASL(socket, stanza.SASLResponse{Value: encodeSASL(clientFinal)}); err != nil {
+		return err
+	}
+
+	// Server final message is sent with success, or as a last challenge by some servers
+	val, err := stanza.NextPacket(decoder)
+	if err != nil {
+		return err
+	}
+	if challenge, ok := val.(stanza.SASLChallenge); ok {
+		if err = checkServerFinal(client, challenge.Value); err != nil {
+			return err
+		}
+		if err = writeSASL(socket, stanza.SASLResponse{}); err != nil {
+			return err
+		}
+		if val, err = stanza.NextPacket(decoder); err != nil {
+			return err
+		}
+		if _, ok = val.(stanza.SASLSuccess); ok {
+			return nil
+		}
+	}
+
+	switch v := val.(type) {
+	case stanza.SASLSuccess:
+		return checkServerFinal(client, v.Value)
+	case stanza.SASLFailure:
+		return NewConnError(errors.New("auth failure: "+v.Any.Local), true)
+	default:
+		return errors.New("expected SASL success or failure, got " + v.Name())
+	}
+}
+
+func checkServerFinal(client *scramClient, value string) error {
+	serverFinal, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return NewConnError(errors.New("invalid SCRAM server final message"), true)
+	}
+	if err = client.verifyServerFinal(string(serverFinal)); err != nil {
+		return NewConnError(err, true)
+	}
+	return nil
+}
+
+func readSASLChallenge(decoder *xml.Decoder) (string, error) {
+	val, err := stanza.NextPacket(decoder)
+	if err != nil {
+		return "", err
+	}
+	switch v := val.(type) {
+	case stanza.SASLChallenge:
+		data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v.Value))
+		if err != nil {
+			return "", errors.New("invalid SASL challenge: " + err.Error())
+		}
+		return string(data), nil
+	case stanza.SASLFailure:
+		return "", NewConnError(errors.New("auth failure: "+v.Any.Local), true)
+	default:
+		return "", errors.New("expected SASL challenge, got " + v.Name())
+	}
+}
+
+func encodeSASL(msg string) string {
+	return base64.StdEncoding.EncodeToString([]byte(msg))
+}
+
+// writeSASL writes a SASL nonza to the socket.
+func writeSASL(socket io.Writer, nonza interface{}) error {
+	data, err := xml.Marshal(nonza)
+	if err != nil {
+		return err
+	}
+	n, err := socket.Write(data)
+	if err != nil {
+		return err
+	} else if n == 0 {
+		return errors.New("failed to write SASL nonza to socket : wrote 0 bytes")
+	}
+	return nil
+}
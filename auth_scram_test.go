@@ -0,0 +1,207 @@
+package xmpp
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/xml"
+	"hash"
+	"net"
+	"strings"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestScramVectors(t *testing.T) {
+	tests := []struct {
+		name        string
+		hash        func() hash.Hash
+		nonce       string
+		serverFirst string
+		clientFinal string
+		serverFinal string
+	}{
+		{
+			// RFC 5802 - 5. SCRAM Authentication Exchange
+			name:        "SCRAM-SHA-1",
+			hash:        sha1.New,
+			nonce:       "fyko+d2lbbFgONRv9qkxdawL",
+			serverFirst: "r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,s=QSXCR+Q6sek8bf92,i=4096",
+			clientFinal: "c=biws,r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,p=v0X8v3Bz2T0CJGbJQyF0X+HI4Ts=",
+			serverFinal: "v=rmF9pqV8S7suAoZWja4dJRkFsKQ=",
+		},
+		{
+			// RFC 7677 - 3. SCRAM-SHA-256 and SCRAM-SHA-256-PLUS
+			name:        "SCRAM-SHA-256",
+			hash:        sha256.New,
+			nonce:       "rOprNGfwEbeRWgbNEkqO",
+			serverFirst: "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096",
+			clientFinal: "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=",
+			serverFinal: "v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &scramClient{hash: tc.hash, user: "user", password: "pencil", nonce: tc.nonce}
+			if first := client.clientFirst(); first != "n,,n=user,r="+tc.nonce {
+				t.Errorf("unexpected client first message: %s", first)
+			}
+			final, err := client.clientFinal(tc.serverFirst)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if final != tc.clientFinal {
+				t.Errorf("unexpected client final message: %s", final)
+			}
+			if err = client.verifyServerFinal(tc.serverFinal); err != nil {
+				t.Errorf("server signature not verified: %v", err)
+			}
+			if err = client.verifyServerFinal("v=" + base64.StdEncoding.EncodeToString([]byte("forged"))); err == nil {
+				t.Errorf("forged server signature should be rejected")
+			}
+		})
+	}
+}
+
+func TestAuthSCRAM(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+	features := stanza.StreamFeatures{}
+	features.Mechanisms.Mechanism = []string{"SCRAM-SHA-256", "PLAIN"}
+
+	go func() {
+		decoder := xml.NewDecoder(serverConn)
+		var auth stanza.SASLAuth
+		if err := decoder.Decode(&auth); err != nil || auth.Mechanism != "SCRAM-SHA-256" {
+			t.Errorf("unexpected auth: %+v, %v", auth, err)
+			return
+		}
+		clientFirst, _ := base64.StdEncoding.DecodeString(auth.Value)
+		attrs := parseScramAttrs(string(clientFirst))
+
+		// The server side of the exchange computes the same messages
+		expected := &scramClient{hash: sha256.New, user: "user", password: "pencil", nonce: attrs["r"],
+			mechanisms: features.Mechanisms.Mechanism}
+		if data := expected.clientFirst(); string(clientFirst) != data || attrs["d"] == "" {
+			t.Errorf("client first message should indicate downgrade protection: %s", clientFirst)
+		}
+		serverFirst := "r=" + attrs["r"] + "srv,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"
+		clientFinal, _ := expected.clientFinal(serverFirst)
+		_, _ = serverConn.Write([]byte("<challenge xmlns='urn:ietf:params:xml:ns:xmpp-sasl'>" + encodeSASL(serverFirst) + "</challenge>"))
+
+		var response stanza.SASLResponse
+		if err := decoder.Decode(&response); err != nil {
+			t.Errorf("cannot decode response: %v", err)
+			return
+		}
+		if data, _ := base64.StdEncoding.DecodeString(response.Value); string(data) != clientFinal {
+			t.Errorf("unexpected client final message: %s", data)
+		}
+		serverFinal := "v=" + base64.StdEncoding.EncodeToString(expected.serverSignature)
+		_, _ = serverConn.Write([]byte("<success xmlns='urn:ietf:params:xml:ns:xmpp-sasl'>" + encodeSASL(serverFinal) + "</success>"))
+	}()
+
+	mech, err := authSASL(clientConn, xml.NewDecoder(clientConn), features, "user", Password("pencil").WithMechanisms(scramMechanisms...))
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+	if mech != "SCRAM-SHA-256" {
+		t.Errorf("unexpected mechanism: %s", mech)
+	}
+}
+
+func TestScramInvalidServerFirst(t *testing.T) {
+	for _, serverFirst := range []string{
+		"r=othernonce,s=QSXCR+Q6sek8bf92,i=4096",
+		"r=fyko+d2lbbFgONRv9qkxdawL,s=QSXCR+Q6sek8bf92,i=4096",
+		"r=fyko+d2lbbFgONRv9qkxdawL3rfc,s=QSXCR+Q6sek8bf92,i=0",
+		"r=fyko+d2lbbFgONRv9qkxdawL3rfc,i=4096",
+		"m=ext,r=fyko+d2lbbFgONRv9qkxdawL3rfc,s=QSXCR+Q6sek8bf92,i=4096",
+	} {
+		client := &scramClient{hash: sha1.New, user: "user", password: "pencil", nonce: "fyko+d2lbbFgONRv9qkxdawL"}
+		client.clientFirst()
+		if _, err := client.clientFinal(serverFirst); err == nil {
+			t.Errorf("server first message should be rejected: %s", serverFirst)
+		}
+	}
+}
+
+func TestScramUsernameEscaping(t *testing.T) {
+	client := &scramClient{hash: sha1.New, user: "a=b,c", nonce: "abc"}
+	if first := client.clientFirst(); first != "n,,n=a=3Db=2Cc,r=abc" {
+		t.Errorf("username not escaped: %s", first)
+	}
+}
+
+func TestScramDowngradeProtection(t *testing.T) {
+	offered := []string{"SCRAM-SHA-1", "SCRAM-SHA-256", "SCRAM-SHA-512", "PLAIN"}
+	cb := []string{"tls-server-end-point"}
+
+	// Hash computed by the server from the mechanisms it actually offered
+	server := &scramClient{hash: sha512.New, mechanisms: offered, channelBindings: cb}
+	d := server.downgradeHash()
+	serverFirst := "r=rOprNGfwEbeRWgbNEkqO%hvY,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096,d=" + d
+
+	client := &scramClient{hash: sha512.New, user: "user", password: "pencil", nonce: "rOprNGfwEbeRWgbNEkqO",
+		mechanisms: []string{"PLAIN", "SCRAM-SHA-512", "SCRAM-SHA-256", "SCRAM-SHA-1"}, channelBindings: cb}
+	client.clientFirst()
+	if _, err := client.clientFinal(serverFirst); err != nil {
+		t.Errorf("matching downgrade protection hash should be accepted: %v", err)
+	}
+
+	// Stronger mechanism stripped from stream features
+	client = &scramClient{hash: sha512.New, user: "user", password: "pencil", nonce: "rOprNGfwEbeRWgbNEkqO",
+		mechanisms: []string{"SCRAM-SHA-1", "SCRAM-SHA-256", "PLAIN"}, channelBindings: cb}
+	client.clientFirst()
+	if _, err := client.clientFinal(serverFirst); err != ErrSASLDowngrade {
+		t.Errorf("expected downgrade error, got %v", err)
+	}
+}
+
+func TestPinMechanism(t *testing.T) {
+	store := NewMemoryMechanismStore()
+	if w := pinMechanism(store, "example.com", "SCRAM-SHA-1"); w != "" {
+		t.Errorf("unexpected warning: %s", w)
+	}
+	if w := pinMechanism(store, "example.com", "SCRAM-SHA-256"); w != "" {
+		t.Errorf("unexpected warning: %s", w)
+	}
+	if store.StrongestMechanism("example.com") != "SCRAM-SHA-256" {
+		t.Errorf("strongest mechanism not stored: %s", store.StrongestMechanism("example.com"))
+	}
+	w := pinMechanism(store, "example.com", "PLAIN")
+	if !strings.Contains(w, "PLAIN") || !strings.Contains(w, "SCRAM-SHA-256") {
+		t.Errorf("expected downgrade warning, got %q", w)
+	}
+	if store.StrongestMechanism("example.com") != "SCRAM-SHA-256" {
+		t.Errorf("strongest mechanism should not be replaced by a weaker one")
+	}
+	if w := pinMechanism(store, "other.com", "PLAIN"); w != "" {
+		t.Errorf("servers should be pinned independently: %s", w)
+	}
+	if w := pinMechanism(nil, "example.com", "PLAIN"); w != "" {
+		t.Errorf("no warning expected without store: %s", w)
+	}
+}
+
+func TestSASLMechanismsOption(t *testing.T) {
+	config := Config{Jid: "romeo@montague.lit", Credential: Password("pencil")}
+	if _, err := NewClient(&config, NewRouter(), clientDefaultErrorHandler); err != nil {
+		t.Fatalf("cannot create client: %v", err)
+	}
+	if mechs := config.Credential.mechanisms; len(mechs) != 1 || mechs[0] != "PLAIN" {
+		t.Errorf("password should authenticate with PLAIN by default, got %v", mechs)
+	}
+
+	config = Config{Jid: "romeo@montague.lit", Credential: Password("pencil")}
+	if _, err := NewClient(&config, NewRouter(), clientDefaultErrorHandler, WithSASLMechanisms("SCRAM-SHA-256", "PLAIN")); err != nil {
+		t.Fatalf("cannot create client: %v", err)
+	}
+	if mechs := config.Credential.mechanisms; len(mechs) != 2 || mechs[0] != "SCRAM-SHA-256" {
+		t.Errorf("unexpected mechanisms: %v", mechs)
+	}
+}
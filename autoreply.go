@@ -0,0 +1,113 @@
+package xmpp
+
+import (
+	"strings"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Automatic answers to messages
+
+// AutoReplyPolicy tells if the automatic answers of the client, such as delivery receipts, may be
+// sent for a message received from a contact. As they tell that the account is online, they should
+// not be sent to spammers.
+type AutoReplyPolicy func(from stanza.Jid, msg stanza.Message) bool
+
+var (
+	// AutoReplyAll answers all the messages.
+	AutoReplyAll AutoReplyPolicy = func(stanza.Jid, stanza.Message) bool { return true }
+	// AutoReplyNone never answers automatically.
+	AutoReplyNone AutoReplyPolicy = func(stanza.Jid, stanza.Message) bool { return false }
+)
+
+// RosterOnly only answers the contacts in the roster kept by the manager, which must be attached to
+// the client with WithRosterManager.
+func RosterOnly(m *RosterManager) AutoReplyPolicy {
+	return func(from stanza.Jid, _ stanza.Message) bool {
+		_, ok := m.Item(from.Bare())
+		return ok
+	}
+}
+
+// AutoReplyAllowList only answers the JIDs of the list. A bare JID allows all its resources.
+func AutoReplyAllowList(jids ...string) AutoReplyPolicy {
+	allowed := make(map[string]bool, len(jids))
+	for _, jid := range jids {
+		allowed[strings.ToLower(jid)] = true
+	}
+	return func(from stanza.Jid, _ stanza.Message) bool {
+		return allowed[strings.ToLower(from.Full())] || allowed[strings.ToLower(from.Bare())]
+	}
+}
+
+// WithAutoReplyPolicy sets the policy consulted before sending any automatic answer.
+func WithAutoReplyPolicy(policy AutoReplyPolicy) Option {
+	return func(config *Config) {
+		config.AutoReplyPolicy = policy
+	}
+}
+
+// WithAutoReceipts answers the delivery receipt requests of the messages allowed by the policy.
+func WithAutoReceipts() Option {
+	return func(config *Config) {
+		config.AutoReceipts = true
+	}
+}
+
+// WithAutoReceivedMarkers answers the markable messages allowed by the policy with a received
+// marker.
+func WithAutoReceivedMarkers() Option {
+	return func(config *Config) {
+		config.AutoReceivedMarkers = true
+	}
+}
+
+// autoReply sends the automatic answers enabled in the configuration.
+func (c *Client) autoReply(p stanza.Packet) {
+	msg, ok := p.(stanza.Message)
+	if !ok || (!c.config.AutoReceipts && !c.config.AutoReceivedMarkers) {
+		return
+	}
+	if !mayAutoReply(c.config.AutoReplyPolicy, c.BareJID(), msg) {
+		return
+	}
+	for _, answer := range autoReplies(c.config, msg) {
+		if err := c.Send(answer); err != nil {
+			c.ErrorHandler(err)
+			return
+		}
+	}
+}
+
+// mayAutoReply tells if an automatic answer may be sent for the message. All the automatic answers
+// must check it first. Errors, room messages and messages without id are never answered, nor the
+// messages sent by our other resources.
+func mayAutoReply(policy AutoReplyPolicy, account string, msg stanza.Message) bool {
+	if msg.Id == "" || msg.Type == stanza.MessageTypeError || msg.Type == stanza.MessageTypeGroupchat {
+		return false
+	}
+	from, err := stanza.NewJid(msg.From)
+	if err != nil || strings.EqualFold(from.Bare(), account) {
+		return false
+	}
+	return policy == nil || policy(*from, msg)
+}
+
+// autoReplies returns the answers to the message, once allowed by the policy.
+func autoReplies(config *Config, msg stanza.Message) []stanza.Message {
+	var answers []stanza.Message
+	var request stanza.ReceiptRequest
+	if config.AutoReceipts && msg.Get(&request) {
+		answer := stanza.NewMessage(stanza.Attrs{To: stanza.ReplyAddress(msg.From), Type: msg.Type})
+		answer.Extensions = append(answer.Extensions, stanza.ReceiptReceived{ID: msg.Id})
+		answers = append(answers, answer)
+	}
+	var markable stanza.Markable
+	if config.AutoReceivedMarkers && msg.Get(&markable) {
+		answer := stanza.NewMessage(stanza.Attrs{To: stanza.ReplyAddress(msg.From), Type: msg.Type})
+		answer.Extensions = append(answer.Extensions, stanza.MarkReceived{ID: msg.Id})
+		answers = append(answers, answer)
+	}
+	return answers
+}
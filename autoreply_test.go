@@ -0,0 +1,75 @@
+package xmpp
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+const receiptRequestMessage = `<message from='northumberland@shakespeare.lit/westminster' to='kingrichard@royalty.england.lit/throne' id='richard2-4.1.247' type='chat'>
+  <body>My lord, dispatch; read o'er these articles.</body>
+  <request xmlns='urn:xmpp:receipts'/>
+  <markable xmlns='urn:xmpp:chat-markers:0'/>
+</message>`
+
+func TestAutoReplies(t *testing.T) {
+	msg := parseMessage(t, receiptRequestMessage)
+	config := &Config{}
+	config.Apply(WithAutoReceipts(), WithAutoReceivedMarkers())
+
+	answers := autoReplies(config, msg)
+	if len(answers) != 2 {
+		t.Fatalf("unexpected answers: %+v", answers)
+	}
+	var out []string
+	for _, answer := range answers {
+		data, err := xml.Marshal(answer)
+		if err != nil {
+			t.Fatalf("cannot marshal answer: %v", err)
+		}
+		out = append(out, string(data))
+	}
+	if !strings.Contains(out[0], `<received xmlns="urn:xmpp:receipts" id="richard2-4.1.247">`) ||
+		!strings.Contains(out[1], `<received xmlns="urn:xmpp:chat-markers:0" id="richard2-4.1.247">`) ||
+		!strings.Contains(out[0], `to="northumberland@shakespeare.lit/westminster"`) {
+		t.Errorf("unexpected answers: %v", out)
+	}
+}
+
+func TestAutoReplyPolicy(t *testing.T) {
+	const account = "kingrichard@royalty.england.lit"
+	msg := parseMessage(t, receiptRequestMessage)
+
+	roster := NewRosterManager()
+	rosterOnly := RosterOnly(roster)
+	if mayAutoReply(rosterOnly, account, msg) {
+		t.Error("contact out of the roster should not be answered")
+	}
+	result, _ := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeResult, Id: "roster1"})
+	result.RosterItems().AddItem("northumberland@shakespeare.lit", stanza.SubscriptionNone, "", "", nil)
+	roster.roster.update(account, result)
+	if !mayAutoReply(rosterOnly, account, msg) {
+		t.Error("contact in the roster should be answered")
+	}
+
+	if mayAutoReply(AutoReplyNone, account, msg) || !mayAutoReply(AutoReplyAll, account, msg) || !mayAutoReply(nil, account, msg) {
+		t.Error("built-in policies not applied")
+	}
+	if !mayAutoReply(AutoReplyAllowList("Northumberland@shakespeare.lit"), account, msg) ||
+		mayAutoReply(AutoReplyAllowList("northumberland@shakespeare.lit/tower"), account, msg) {
+		t.Error("allow list not applied")
+	}
+
+	// Room messages and messages from our other resources are never answered
+	msg.Type = stanza.MessageTypeGroupchat
+	if mayAutoReply(AutoReplyAll, account, msg) {
+		t.Error("room message should not be answered")
+	}
+	msg.Type = stanza.MessageTypeChat
+	msg.From = account + "/laptop"
+	if mayAutoReply(AutoReplyAll, account, msg) {
+		t.Error("message from our own account should not be answered")
+	}
+}
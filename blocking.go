@@ -0,0 +1,265 @@
+package xmpp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Blocking Command (XEP-0191) and Spam Reporting (XEP-0377)
+
+// BlockingSupport tells which blocking features are advertised by the server.
+type BlockingSupport struct {
+	Blocking  bool
+	Reporting bool
+}
+
+// GetBlockingSupport discovers if the server supports the blocking command, and if it accepts
+// reports when blocking a JID. Reports sent to a server without reporting support are ignored.
+func (c *Client) GetBlockingSupport(ctx context.Context) (BlockingSupport, error) {
+	return getBlockingSupport(ctx, c, c.ServerJID())
+}
+
+// GetBlockList returns the JIDs blocked by the account, and caches them for IsBlocked.
+func (c *Client) GetBlockList(ctx context.Context) ([]string, error) {
+	jids, err := getBlockList(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	c.blockList.set(jids)
+	return jids, nil
+}
+
+// Block blocks the JIDs, without reporting them. At least one JID is required.
+func (c *Client) Block(ctx context.Context, jids ...string) error {
+	if len(jids) == 0 {
+		return errors.New("a JID is required to block it")
+	}
+	iq, err := stanza.NewBlockIQ(jids...)
+	if err != nil {
+		return err
+	}
+	if err = sendBlockingIQ(ctx, c, iq); err != nil {
+		return err
+	}
+	c.blockList.block(jids)
+	return nil
+}
+
+// Unblock unblocks the JIDs. Without JIDs, all blocked JIDs are unblocked.
+func (c *Client) Unblock(ctx context.Context, jids ...string) error {
+	iq, err := stanza.NewUnblockIQ(jids...)
+	if err != nil {
+		return err
+	}
+	if err = sendBlockingIQ(ctx, c, iq); err != nil {
+		return err
+	}
+	c.blockList.unblock(jids)
+	return nil
+}
+
+// IsBlocked tells if messages from jid are blocked by the server, according to the block list
+// cached by the client. The cache is filled by GetBlockList, and kept up to date with the changes
+// made by the client and pushed by the server for the other resources of the account.
+// As blocking is enforced by the server, stanzas sent to blocked JIDs are not intercepted: this
+// is meant for user interfaces.
+func (c *Client) IsBlocked(jid string) bool {
+	return c.blockList.blocked(jid)
+}
+
+// updateBlockList applies the block list changes pushed by the server, and acknowledges them.
+// It returns true if the packet was a block list push.
+func (c *Client) updateBlockList(p stanza.Packet) bool {
+	return applyBlockListPush(c, &c.blockList, c.BareJID(), p)
+}
+
+// ReportSpam blocks the JID and reports it as a spammer. The messageIDs are the stanza-ids
+// assigned by our server to the spam messages, as returned by Message.GetStanzaId(c.BareJID()).
+func (c *Client) ReportSpam(ctx context.Context, jid string, messageIDs ...string) error {
+	if err := blockAndReport(ctx, c, jid, stanza.ReportReasonSpam, "", c.BareJID(), messageIDs); err != nil {
+		return err
+	}
+	c.blockList.block([]string{jid})
+	return nil
+}
+
+// ReportAbuse blocks the JID and reports it as abusive, with an optional text explaining the report.
+func (c *Client) ReportAbuse(ctx context.Context, jid, text string, messageIDs ...string) error {
+	if err := blockAndReport(ctx, c, jid, stanza.ReportReasonAbuse, text, c.BareJID(), messageIDs); err != nil {
+		return err
+	}
+	c.blockList.block([]string{jid})
+	return nil
+}
+
+func blockAndReport(ctx context.Context, s Sender, jid, reason, text, by string, ids []string) error {
+	if jid == "" {
+		return errors.New("a JID is required to report it")
+	}
+	iq, err := stanza.NewBlockReportIQ(jid, reason, text, by, ids...)
+	if err != nil {
+		return err
+	}
+	return sendBlockingIQ(ctx, s, iq)
+}
+
+func sendBlockingIQ(ctx context.Context, s Sender, iq *stanza.IQ) error {
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return err
+	}
+	return iqError(result)
+}
+
+func getBlockList(ctx context.Context, s Sender) ([]string, error) {
+	iq, err := stanza.NewBlockListIQ()
+	if err != nil {
+		return nil, err
+	}
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return nil, err
+	}
+	if err = iqError(result); err != nil {
+		return nil, err
+	}
+	list, ok := result.Payload.(*stanza.BlockList)
+	if !ok {
+		return nil, errors.New("invalid block list response")
+	}
+	jids := make([]string, 0, len(list.Items))
+	for _, item := range list.Items {
+		jids = append(jids, item.JID)
+	}
+	return jids, nil
+}
+
+func getBlockingSupport(ctx context.Context, s Sender, server string) (BlockingSupport, error) {
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, To: server})
+	if err != nil {
+		return BlockingSupport{}, err
+	}
+	iq.DiscoInfo()
+
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return BlockingSupport{}, err
+	}
+	if err = iqError(result); err != nil {
+		return BlockingSupport{}, err
+	}
+	info, ok := result.Payload.(*stanza.DiscoInfo)
+	if !ok {
+		return BlockingSupport{}, errors.New("invalid server info response")
+	}
+	return BlockingSupport{
+		Blocking:  info.HasFeature(stanza.NSBlocking),
+		Reporting: info.HasFeature(stanza.NSReporting),
+	}, nil
+}
+
+// applyBlockListPush updates the cache with a block or unblock push sent by the server of account,
+// and answers it. See XEP-0191 - 3.3 User Blocks Contact
+func applyBlockListPush(s Sender, cache *blockListCache, account string, p stanza.Packet) bool {
+	iq, ok := p.(*stanza.IQ)
+	if !ok || iq.Type != stanza.IQTypeSet {
+		return false
+	}
+	// Pushes are only accepted from the account itself
+	if iq.From != "" && !strings.EqualFold(iq.From, account) {
+		return false
+	}
+	switch payload := iq.Payload.(type) {
+	case *stanza.Block:
+		cache.block(blockItemJids(payload.Items))
+	case *stanza.Unblock:
+		cache.unblock(blockItemJids(payload.Items))
+	default:
+		return false
+	}
+	reply, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeResult, To: iq.From, Id: iq.Id})
+	if err == nil {
+		_ = s.Send(reply)
+	}
+	return true
+}
+
+func blockItemJids(items []stanza.BlockItem) []string {
+	jids := make([]string, 0, len(items))
+	for _, item := range items {
+		jids = append(jids, item.JID)
+	}
+	return jids
+}
+
+// blockListCache is the block list of the account, as known by the client.
+type blockListCache struct {
+	mu   sync.RWMutex
+	jids map[string]bool
+}
+
+func (b *blockListCache) set(jids []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.jids = make(map[string]bool, len(jids))
+	for _, jid := range jids {
+		b.jids[blockedKey(jid)] = true
+	}
+}
+
+func (b *blockListCache) block(jids []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.jids == nil {
+		b.jids = make(map[string]bool, len(jids))
+	}
+	for _, jid := range jids {
+		b.jids[blockedKey(jid)] = true
+	}
+}
+
+// unblock removes the JIDs from the block list, or all the JIDs when jids is empty.
+func (b *blockListCache) unblock(jids []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(jids) == 0 {
+		b.jids = nil
+		return
+	}
+	for _, jid := range jids {
+		delete(b.jids, blockedKey(jid))
+	}
+}
+
+// blocked tells if jid is matched by an item of the block list. As with privacy lists, an item
+// matches a full JID, the bare JID, the domain with the resource, or the whole domain.
+func (b *blockListCache) blocked(jid string) bool {
+	j, err := stanza.NewJid(jid)
+	if err != nil {
+		return false
+	}
+	candidates := []string{j.Full(), j.Bare(), j.Domain}
+	if j.Resource != "" {
+		candidates = append(candidates, j.Domain+"/"+j.Resource)
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, c := range candidates {
+		if b.jids[blockedKey(c)] {
+			return true
+		}
+	}
+	return false
+}
+
+// blockedKey normalizes the case of the node and domain parts of a blocked JID.
+func blockedKey(jid string) string {
+	parts := strings.SplitN(jid, "/", 2)
+	parts[0] = strings.ToLower(parts[0])
+	return strings.Join(parts, "/")
+}
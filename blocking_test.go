@@ -0,0 +1,127 @@
+package xmpp
+
+import (
+	"context"
+	"encoding/xml"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestBlockAndReport(t *testing.T) {
+	sender := &scriptedIQSender{t: t, responses: []string{`<iq type='result'/>`}}
+	err := blockAndReport(context.Background(), sender, "spammer@example.com", stanza.ReportReasonSpam, "",
+		"romeo@example.net", []string{"28482-98726-73623"})
+	if err != nil {
+		t.Fatalf("could not report JID: %v", err)
+	}
+	block, ok := sender.requests[0].Payload.(*stanza.Block)
+	if !ok || len(block.Items) != 1 || block.Items[0].JID != "spammer@example.com" {
+		t.Fatalf("unexpected request: %+v", sender.requests[0])
+	}
+	report := block.Items[0].Report
+	if report == nil || report.Reason != stanza.ReportReasonSpam || len(report.StanzaIds) != 1 ||
+		report.StanzaIds[0].By != "romeo@example.net" {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
+
+func TestBlockWithoutJID(t *testing.T) {
+	client := &Client{config: &Config{}}
+	if err := client.Block(context.Background()); err == nil {
+		t.Errorf("blocking without JID should fail")
+	}
+}
+
+func TestGetBlockingSupport(t *testing.T) {
+	response := `<iq type='result' from='example.net'>
+  <query xmlns='http://jabber.org/protocol/disco#info'>
+    <identity category='server' type='im'/>
+    <feature var='urn:xmpp:blocking'/>
+    <feature var='urn:xmpp:reporting:1'/>
+  </query>
+</iq>`
+	sender := &scriptedIQSender{t: t, responses: []string{response}}
+	support, err := getBlockingSupport(context.Background(), sender, "example.net")
+	if err != nil {
+		t.Fatalf("could not discover blocking support: %v", err)
+	}
+	if !support.Blocking || !support.Reporting {
+		t.Errorf("unexpected support: %+v", support)
+	}
+	if sender.requests[0].To != "example.net" {
+		t.Errorf("features should be discovered on the server")
+	}
+}
+
+func TestGetBlockList(t *testing.T) {
+	response := `<iq type='result'>
+  <blocklist xmlns='urn:xmpp:blocking'>
+    <item jid='romeo@montague.net'/>
+    <item jid='iago@shakespeare.lit'/>
+  </blocklist>
+</iq>`
+	sender := &scriptedIQSender{t: t, responses: []string{response}}
+	jids, err := getBlockList(context.Background(), sender)
+	if err != nil {
+		t.Fatalf("could not get block list: %v", err)
+	}
+	if len(jids) != 2 || jids[1] != "iago@shakespeare.lit" {
+		t.Errorf("unexpected block list: %v", jids)
+	}
+}
+
+func TestBlockListPush(t *testing.T) {
+	var cache blockListCache
+	cache.set([]string{"romeo@montague.net", "Iago@Shakespeare.lit"})
+	if !cache.blocked("iago@shakespeare.lit/pda") || cache.blocked("juliet@capulet.lit") {
+		t.Fatalf("unexpected initial block list: %v", cache.jids)
+	}
+
+	// Another resource of the account blocks a contact and a whole domain
+	sender := NewSenderMock()
+	push := `<iq type='set' id='push1' to='juliet@capulet.com/chamber'>
+  <block xmlns='urn:xmpp:blocking'><item jid='tybalt@capulet.com'/><item jid='spam.example'/></block>
+</iq>`
+	if !applyBlockListPush(sender, &cache, "juliet@capulet.com", parseIQ(t, push)) {
+		t.Fatal("block push should be handled")
+	}
+	if !cache.blocked("tybalt@capulet.com/sword") || !cache.blocked("bot@spam.example") {
+		t.Errorf("pushed JIDs should be blocked: %v", cache.jids)
+	}
+	if sender.String() != `<iq type="result" id="push1"></iq>` {
+		t.Errorf("push should be acknowledged: %s", sender.String())
+	}
+
+	push = `<iq type='set' id='push2' from='juliet@capulet.com'>
+  <unblock xmlns='urn:xmpp:blocking'><item jid='romeo@montague.net'/></unblock>
+</iq>`
+	if !applyBlockListPush(NewSenderMock(), &cache, "juliet@capulet.com", parseIQ(t, push)) {
+		t.Fatal("unblock push should be handled")
+	}
+	if cache.blocked("romeo@montague.net") || !cache.blocked("iago@shakespeare.lit") {
+		t.Errorf("only the pushed JID should be unblocked: %v", cache.jids)
+	}
+
+	// Pushes from other entities are ignored
+	push = `<iq type='set' id='push3' from='mallory@evil.example'>
+  <unblock xmlns='urn:xmpp:blocking'/>
+</iq>`
+	if applyBlockListPush(NewSenderMock(), &cache, "juliet@capulet.com", parseIQ(t, push)) || !cache.blocked("iago@shakespeare.lit") {
+		t.Errorf("push from another entity should be ignored")
+	}
+
+	// Unblocking without items clears the list
+	push = `<iq type='set' id='push4'><unblock xmlns='urn:xmpp:blocking'/></iq>`
+	if !applyBlockListPush(NewSenderMock(), &cache, "juliet@capulet.com", parseIQ(t, push)) || cache.blocked("iago@shakespeare.lit") {
+		t.Errorf("block list should be cleared: %v", cache.jids)
+	}
+}
+
+func parseIQ(t *testing.T, raw string) *stanza.IQ {
+	var iq stanza.IQ
+	if err := xml.Unmarshal([]byte(raw), &iq); err != nil {
+		t.Fatalf("could not parse IQ: %v", err)
+	}
+	return &iq
+}
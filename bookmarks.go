@@ -0,0 +1,215 @@
+package xmpp
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// PEP Native Bookmarks (XEP-0402)
+
+// NativeBookmark is a room bookmark stored on the account.
+type NativeBookmark struct {
+	// JID is the bare JID of the room, used as id of the bookmark item
+	JID      string
+	Name     string
+	Nick     string
+	Password string
+	AutoJoin bool
+}
+
+// GetNativeBookmarks fetches the bookmarks stored on the account, and refreshes the bookmark cache.
+func (c *Client) GetNativeBookmarks(ctx context.Context) ([]NativeBookmark, error) {
+	bookmarks, err := getNativeBookmarks(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	c.bookmarks.reset(bookmarks)
+	return bookmarks, nil
+}
+
+// SaveNativeBookmark adds or replaces a bookmark.
+func (c *Client) SaveNativeBookmark(ctx context.Context, b NativeBookmark) error {
+	if err := saveNativeBookmark(ctx, c, b); err != nil {
+		return err
+	}
+	c.bookmarks.set(b)
+	return nil
+}
+
+// DeleteNativeBookmark removes the bookmark of a room.
+func (c *Client) DeleteNativeBookmark(ctx context.Context, roomJID string) error {
+	if err := deleteNativeBookmark(ctx, c, roomJID); err != nil {
+		return err
+	}
+	c.bookmarks.remove(roomJID)
+	return nil
+}
+
+// GetCachedBookmarks returns the known bookmarks, sorted by room JID, without querying the server.
+// The cache is filled by GetNativeBookmarks and kept up to date with the bookmark notifications
+// the server sends when bookmarks are changed, for instance by another client of the account.
+func (c *Client) GetCachedBookmarks() []NativeBookmark {
+	return c.bookmarks.list()
+}
+
+// updateBookmarks applies the bookmark notifications sent by the account to the bookmark cache.
+func (c *Client) updateBookmarks(p stanza.Packet) {
+	msg, ok := p.(stanza.Message)
+	if !ok || (msg.From != "" && !strings.EqualFold(msg.From, c.BareJID())) {
+		return
+	}
+	var event stanza.PubSubEvent
+	if !msg.Get(&event) {
+		return
+	}
+	c.bookmarks.apply(event)
+}
+
+func getNativeBookmarks(ctx context.Context, s Sender) ([]NativeBookmark, error) {
+	iq, err := stanza.NewItemsRequest("", stanza.NodeBookmarks, 0)
+	if err != nil {
+		return nil, err
+	}
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return nil, err
+	}
+	if isItemNotFound(result) {
+		// No bookmark was ever stored on the account
+		return nil, nil
+	}
+	if err = iqError(result); err != nil {
+		return nil, err
+	}
+	ps, ok := result.Payload.(*stanza.PubSubGeneric)
+	if !ok || ps.Items == nil {
+		return nil, errors.New("invalid bookmarks response")
+	}
+
+	bookmarks := make([]NativeBookmark, 0, len(ps.Items.List))
+	for _, item := range ps.Items.List {
+		var conf stanza.BookmarkConference
+		if err = item.DecodePayload(&conf); err != nil {
+			// Skip items that are not bookmarks
+			continue
+		}
+		bookmarks = append(bookmarks, newNativeBookmark(item.Id, conf))
+	}
+	return bookmarks, nil
+}
+
+func saveNativeBookmark(ctx context.Context, s Sender, b NativeBookmark) error {
+	if b.JID == "" {
+		return errors.New("a room JID is required to save a bookmark")
+	}
+	item, err := stanza.NewPayloadItem(b.JID, stanza.BookmarkConference{
+		Name:     b.Name,
+		AutoJoin: b.AutoJoin,
+		Nick:     b.Nick,
+		Password: b.Password,
+	})
+	if err != nil {
+		return err
+	}
+	iq, err := stanza.NewPublishItemOptsRq("", stanza.NodeBookmarks, []stanza.Item{item}, stanza.NewBookmarkPublishOptions())
+	if err != nil {
+		return err
+	}
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return err
+	}
+	return iqError(result)
+}
+
+func deleteNativeBookmark(ctx context.Context, s Sender, roomJID string) error {
+	if roomJID == "" {
+		return errors.New("a room JID is required to delete a bookmark")
+	}
+	notify := true
+	iq, err := stanza.NewDelItemFromNode("", stanza.NodeBookmarks, roomJID, &notify)
+	if err != nil {
+		return err
+	}
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return err
+	}
+	return iqError(result)
+}
+
+func newNativeBookmark(jid string, conf stanza.BookmarkConference) NativeBookmark {
+	return NativeBookmark{
+		JID:      jid,
+		Name:     conf.Name,
+		Nick:     conf.Nick,
+		Password: conf.Password,
+		AutoJoin: conf.AutoJoin,
+	}
+}
+
+// bookmarkCache holds the bookmarks of the account, keyed by room JID.
+type bookmarkCache struct {
+	mu        sync.RWMutex
+	bookmarks map[string]NativeBookmark
+}
+
+func (bc *bookmarkCache) reset(bookmarks []NativeBookmark) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	bc.bookmarks = make(map[string]NativeBookmark, len(bookmarks))
+	for _, b := range bookmarks {
+		bc.bookmarks[b.JID] = b
+	}
+}
+
+func (bc *bookmarkCache) set(b NativeBookmark) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	if bc.bookmarks == nil {
+		bc.bookmarks = make(map[string]NativeBookmark)
+	}
+	bc.bookmarks[b.JID] = b
+}
+
+func (bc *bookmarkCache) remove(jid string) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	delete(bc.bookmarks, jid)
+}
+
+func (bc *bookmarkCache) list() []NativeBookmark {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	bookmarks := make([]NativeBookmark, 0, len(bc.bookmarks))
+	for _, b := range bc.bookmarks {
+		bookmarks = append(bookmarks, b)
+	}
+	sort.Slice(bookmarks, func(i, j int) bool { return bookmarks[i].JID < bookmarks[j].JID })
+	return bookmarks
+}
+
+// apply updates the cache from a PubSub event on the bookmarks node.
+func (bc *bookmarkCache) apply(event stanza.PubSubEvent) {
+	items, ok := event.EventElement.(*stanza.ItemsEvent)
+	if !ok || items.Node != stanza.NodeBookmarks {
+		return
+	}
+	for _, ie := range items.Items {
+		item := stanza.Item{Id: ie.Id, Any: ie.Any}
+		var conf stanza.BookmarkConference
+		if ie.Id == "" || item.DecodePayload(&conf) != nil {
+			continue
+		}
+		bc.set(newNativeBookmark(ie.Id, conf))
+	}
+	if items.Retract != nil {
+		bc.remove(items.Retract.ID)
+	}
+}
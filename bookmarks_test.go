@@ -0,0 +1,160 @@
+package xmpp
+
+import (
+	"context"
+	"encoding/xml"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestGetNativeBookmarks(t *testing.T) {
+	response := `<iq type='result' to='juliet@capulet.lit/balcony'>
+  <pubsub xmlns='http://jabber.org/protocol/pubsub'>
+    <items node='urn:xmpp:bookmarks:1'>
+      <item id='theplay@conference.shakespeare.lit'>
+        <conference xmlns='urn:xmpp:bookmarks:1' name='The Play&apos;s the Thing' autojoin='true'>
+          <nick>JC</nick>
+        </conference>
+      </item>
+      <item id='orchard@conference.shakespeare.lit'>
+        <conference xmlns='urn:xmpp:bookmarks:1' name='The Orchard' autojoin='1'>
+          <nick>JC</nick>
+          <password>secret</password>
+        </conference>
+      </item>
+    </items>
+  </pubsub>
+</iq>`
+	sender := &scriptedIQSender{t: t, responses: []string{response}}
+	bookmarks, err := getNativeBookmarks(context.Background(), sender)
+	if err != nil {
+		t.Fatalf("could not get bookmarks: %v", err)
+	}
+	ps, ok := sender.requests[0].Payload.(*stanza.PubSubGeneric)
+	if !ok || ps.Items == nil || ps.Items.Node != stanza.NodeBookmarks {
+		t.Fatalf("unexpected request: %+v", sender.requests[0])
+	}
+	expected := []NativeBookmark{
+		{JID: "theplay@conference.shakespeare.lit", Name: "The Play's the Thing", Nick: "JC", AutoJoin: true},
+		{JID: "orchard@conference.shakespeare.lit", Name: "The Orchard", Nick: "JC", Password: "secret", AutoJoin: true},
+	}
+	if len(bookmarks) != len(expected) {
+		t.Fatalf("expected %d bookmarks, got %+v", len(expected), bookmarks)
+	}
+	for i := range expected {
+		if bookmarks[i] != expected[i] {
+			t.Errorf("unexpected bookmark %d: %+v", i, bookmarks[i])
+		}
+	}
+}
+
+func TestGetNativeBookmarksNoNode(t *testing.T) {
+	response := `<iq type='error'>
+  <error type='cancel'><item-not-found xmlns='urn:ietf:params:xml:ns:xmpp-stanzas'/></error>
+</iq>`
+	sender := &scriptedIQSender{t: t, responses: []string{response}}
+	bookmarks, err := getNativeBookmarks(context.Background(), sender)
+	if err != nil || len(bookmarks) != 0 {
+		t.Errorf("missing node should return no bookmark, got %v, %v", bookmarks, err)
+	}
+}
+
+func TestSaveNativeBookmark(t *testing.T) {
+	sender := &scriptedIQSender{t: t, responses: []string{`<iq type='result'/>`}}
+	b := NativeBookmark{JID: "theplay@conference.shakespeare.lit", Name: "The Play", Nick: "JC", AutoJoin: true}
+	if err := saveNativeBookmark(context.Background(), sender, b); err != nil {
+		t.Fatalf("could not save bookmark: %v", err)
+	}
+	iq := sender.requests[0]
+	ps, ok := iq.Payload.(*stanza.PubSubGeneric)
+	if !ok || iq.Type != stanza.IQTypeSet || ps.Publish == nil || ps.Publish.Node != stanza.NodeBookmarks {
+		t.Fatalf("unexpected request: %+v", iq)
+	}
+	if ps.PublishOptions == nil || ps.PublishOptions.Form == nil {
+		t.Errorf("bookmark should be published with publish options")
+	}
+	if len(ps.Publish.Items) != 1 || ps.Publish.Items[0].Id != b.JID {
+		t.Fatalf("bookmark item should use the room JID as id: %+v", ps.Publish.Items)
+	}
+	var conf stanza.BookmarkConference
+	if err := ps.Publish.Items[0].DecodePayload(&conf); err != nil {
+		t.Fatalf("could not decode published bookmark: %v", err)
+	}
+	if conf.Name != b.Name || conf.Nick != b.Nick || !conf.AutoJoin {
+		t.Errorf("unexpected published bookmark: %+v", conf)
+	}
+
+	if err := saveNativeBookmark(context.Background(), sender, NativeBookmark{Name: "No JID"}); err == nil {
+		t.Errorf("bookmark without room JID should be rejected")
+	}
+}
+
+func TestDeleteNativeBookmark(t *testing.T) {
+	sender := &scriptedIQSender{t: t, responses: []string{`<iq type='result'/>`}}
+	if err := deleteNativeBookmark(context.Background(), sender, "theplay@conference.shakespeare.lit"); err != nil {
+		t.Fatalf("could not delete bookmark: %v", err)
+	}
+	ps, ok := sender.requests[0].Payload.(*stanza.PubSubGeneric)
+	if !ok || ps.Retract == nil || ps.Retract.Node != stanza.NodeBookmarks {
+		t.Fatalf("unexpected request: %+v", sender.requests[0])
+	}
+	if len(ps.Retract.Items) != 1 || ps.Retract.Items[0].Id != "theplay@conference.shakespeare.lit" {
+		t.Errorf("unexpected retracted items: %+v", ps.Retract.Items)
+	}
+}
+
+func TestBookmarkCacheUpdates(t *testing.T) {
+	c := &Client{config: &Config{Jid: "juliet@capulet.lit"}}
+	c.config.parsedJid, _ = stanza.NewJid(c.config.Jid)
+
+	publish := `<message from='juliet@capulet.lit' to='juliet@capulet.lit/balcony' type='headline'>
+  <event xmlns='http://jabber.org/protocol/pubsub#event'>
+    <items node='urn:xmpp:bookmarks:1'>
+      <item id='theplay@conference.shakespeare.lit'>
+        <conference xmlns='urn:xmpp:bookmarks:1' name='The Play' autojoin='true'><nick>JC</nick></conference>
+      </item>
+    </items>
+  </event>
+</message>`
+	c.updateBookmarks(parseMessage(t, publish))
+	cached := c.GetCachedBookmarks()
+	if len(cached) != 1 || cached[0].JID != "theplay@conference.shakespeare.lit" || cached[0].Nick != "JC" {
+		t.Fatalf("unexpected cached bookmarks: %+v", cached)
+	}
+
+	// Notifications from other entities must be ignored
+	spoofed := `<message from='romeo@montague.lit' to='juliet@capulet.lit/balcony' type='headline'>
+  <event xmlns='http://jabber.org/protocol/pubsub#event'>
+    <items node='urn:xmpp:bookmarks:1'>
+      <item id='orchard@conference.shakespeare.lit'>
+        <conference xmlns='urn:xmpp:bookmarks:1' name='The Orchard'/>
+      </item>
+    </items>
+  </event>
+</message>`
+	c.updateBookmarks(parseMessage(t, spoofed))
+	if len(c.GetCachedBookmarks()) != 1 {
+		t.Errorf("bookmark notification from another entity should be ignored")
+	}
+
+	retract := `<message from='juliet@capulet.lit' to='juliet@capulet.lit/balcony' type='headline'>
+  <event xmlns='http://jabber.org/protocol/pubsub#event'>
+    <items node='urn:xmpp:bookmarks:1'>
+      <retract id='theplay@conference.shakespeare.lit'/>
+    </items>
+  </event>
+</message>`
+	c.updateBookmarks(parseMessage(t, retract))
+	if cached = c.GetCachedBookmarks(); len(cached) != 0 {
+		t.Errorf("retracted bookmark should be removed from cache: %+v", cached)
+	}
+}
+
+func parseMessage(t *testing.T, raw string) stanza.Message {
+	var msg stanza.Message
+	if err := xml.Unmarshal([]byte(raw), &msg); err != nil {
+		t.Fatalf("could not parse message: %v", err)
+	}
+	return msg
+}
@@ -0,0 +1,430 @@
+package xmpp
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Entity Capabilities (XEP-0115)
+
+// capsHistorySize is the number of previous verification strings still answered after a
+// capabilities change, as peers may query them while they process our last presence.
+const capsHistorySize = 3
+
+// CapsResponder answers disco#info queries about our own capabilities, and builds the caps
+// element to advertise them in presences. It must be registered on the router with Route.
+type CapsResponder struct {
+	node string
+
+	mu sync.RWMutex
+	// Information set by the application, without the notify features
+	base stanza.DiscoInfo
+	// Number of registrations of each node whose notifications are requested
+	notify  map[string]int
+	current capsEntry
+	// Previous capabilities, most recent first
+	history []capsEntry
+}
+
+type capsEntry struct {
+	ver  string
+	info stanza.DiscoInfo
+}
+
+// NewCapsResponder creates a responder publishing info on node, usually the URL of the software.
+// The features registered by the extensions with stanza.RegisterFeature are added to info.
+// info must not be modified after having been passed to the responder: use SetInfo to change it.
+func NewCapsResponder(node string, info stanza.DiscoInfo) *CapsResponder {
+	r := &CapsResponder{node: node, base: info}
+	info = r.advertised()
+	r.current = capsEntry{ver: stanza.CapsVerification(info), info: info}
+	return r
+}
+
+// SetInfo changes the advertised capabilities. Queries for the previous verification strings
+// are still answered, with the information they were computed from. A new presence must be
+// sent with the updated Caps to notify peers.
+func (r *CapsResponder) SetInfo(info stanza.DiscoInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.base = info
+	r.refresh()
+}
+
+// AddNotify adds the "node+notify" feature to the advertised capabilities, so that PEP services
+// send the notifications of node (XEP-0163 - 4.3.3). Nodes can be added several times: the feature
+// is advertised until they are removed as many times. It returns true if the capabilities changed.
+func (r *CapsResponder) AddNotify(node string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.notify == nil {
+		r.notify = make(map[string]int)
+	}
+	r.notify[node]++
+	if r.notify[node] > 1 {
+		return false
+	}
+	return r.refresh()
+}
+
+// RemoveNotify removes a registration of node added with AddNotify. It returns true if the
+// capabilities changed.
+func (r *CapsResponder) RemoveNotify(node string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.notify[node] == 0 {
+		return false
+	}
+	r.notify[node]--
+	if r.notify[node] > 0 {
+		return false
+	}
+	delete(r.notify, node)
+	return r.refresh()
+}
+
+// advertised returns the capabilities to advertise: the base information, with the features
+// registered with stanza.RegisterFeature and the notify features. It must be called with mu locked.
+func (r *CapsResponder) advertised() stanza.DiscoInfo {
+	features := stanza.RegisteredFeatures()
+	nodes := make([]string, 0, len(r.notify))
+	for node := range r.notify {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	for _, node := range nodes {
+		features = append(features, node+"+notify")
+	}
+
+	info := r.base
+	info.Features = append([]stanza.Feature(nil), r.base.Features...)
+	for _, f := range features {
+		if !info.HasFeature(f) {
+			info.AddFeatures(f)
+		}
+	}
+	return info
+}
+
+// refresh computes the advertised capabilities. It returns true if the verification string changed.
+// It must be called with mu locked.
+func (r *CapsResponder) refresh() bool {
+	info := r.advertised()
+	entry := capsEntry{ver: stanza.CapsVerification(info), info: info}
+	if entry.ver == r.current.ver {
+		r.current = entry
+		return false
+	}
+	history := []capsEntry{r.current}
+	for _, e := range r.history {
+		if len(history) == capsHistorySize {
+			break
+		}
+		if e.ver != entry.ver {
+			history = append(history, e)
+		}
+	}
+	r.current = entry
+	r.history = history
+	return true
+}
+
+// Caps returns the caps element advertising the current capabilities, to add to presences.
+func (r *CapsResponder) Caps() stanza.Caps {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return stanza.Caps{
+		XMLName: xml.Name{Space: stanza.NSCaps, Local: "c"},
+		Hash:    stanza.CapsHashSHA1,
+		Node:    r.node,
+		Ver:     r.current.ver,
+	}
+}
+
+// Route registers the responder on the router, to answer disco#info queries.
+func (r *CapsResponder) Route(router *Router) *Route {
+	return router.NewRoute().IQNamespaces(stanza.NSDiscoInfo).StanzaType(string(stanza.IQTypeGet)).Handler(r)
+}
+
+// HandlePacket answers disco#info queries without node, or for the node of the current or a
+// previous verification string. It implements the router Handler interface.
+func (r *CapsResponder) HandlePacket(s Sender, p stanza.Packet) {
+	iq, ok := p.(*stanza.IQ)
+	if !ok || iq.Type != stanza.IQTypeGet {
+		return
+	}
+	query, ok := iq.Payload.(*stanza.DiscoInfo)
+	if !ok {
+		return
+	}
+
+	info, ok := r.lookup(query.Node)
+	if !ok {
+		_ = s.Send(iq.MakeError(stanza.Err{
+			XMLName: xml.Name{Local: "error"},
+			Code:    404,
+			Type:    stanza.ErrorTypeCancel,
+			Reason:  "item-not-found",
+		}))
+		return
+	}
+	reply, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeResult, From: iq.To, To: iq.From, Id: iq.Id})
+	if err != nil {
+		return
+	}
+	info.XMLName = xml.Name{Space: stanza.NSDiscoInfo, Local: "query"}
+	info.Node = query.Node
+	reply.Payload = &info
+	_ = s.Send(reply)
+}
+
+// lookup returns the information to answer a query for the node.
+func (r *CapsResponder) lookup(node string) (stanza.DiscoInfo, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if node == "" || node == r.node+"#"+r.current.ver {
+		return r.current.info, true
+	}
+	for _, e := range r.history {
+		if node == r.node+"#"+e.ver {
+			return e.info, true
+		}
+	}
+	return stanza.DiscoInfo{}, false
+}
+
+// ============================================================================
+// Capabilities of the contacts
+
+// ErrNoCaps is returned by GetCapsInfo when the JID has not advertised its capabilities.
+var ErrNoCaps = errors.New("no capabilities advertised by the JID")
+
+// ErrCapsVerification is returned when the service discovery information of a contact does not
+// match the verification string it advertised.
+var ErrCapsVerification = errors.New("service discovery information does not match the caps verification string")
+
+// capsQueryTimeout bounds the disco#info query sent to retrieve capabilities missing from the cache.
+// It is independent of the callers contexts, as the query is shared by all lookups of the same caps.
+const capsQueryTimeout = 30 * time.Second
+
+// CapsCache stores the service discovery information of the capabilities advertised by contacts,
+// keyed by caps node and verification string. Only verified information is stored, so a cache can
+// be shared between clients and persisted.
+type CapsCache interface {
+	Get(node, ver string) (stanza.DiscoInfo, bool)
+	Set(node, ver string, info stanza.DiscoInfo)
+}
+
+// WithCapsCache sets the cache used to store the capabilities of the contacts. By default, they are
+// cached in memory for the lifetime of the client.
+func WithCapsCache(c CapsCache) Option {
+	return func(config *Config) {
+		config.CapsCache = c
+	}
+}
+
+// GetCapsInfo returns the service discovery information matching the most recent capabilities
+// advertised in a presence by the full JID. When the capabilities are not cached yet, a single
+// disco#info query is sent for all the JIDs advertising them.
+func (c *Client) GetCapsInfo(ctx context.Context, jid string) (stanza.DiscoInfo, error) {
+	return c.caps.info(ctx, c, jid)
+}
+
+// memoryCapsCache is the default CapsCache. It is not bounded, as the number of distinct
+// capabilities is usually small: contacts running the same software share them.
+type memoryCapsCache struct {
+	mu    sync.RWMutex
+	infos map[string]stanza.DiscoInfo
+}
+
+func (m *memoryCapsCache) Get(node, ver string) (stanza.DiscoInfo, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	info, ok := m.infos[node+"#"+ver]
+	return info, ok
+}
+
+func (m *memoryCapsCache) Set(node, ver string, info stanza.DiscoInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.infos == nil {
+		m.infos = make(map[string]stanza.DiscoInfo)
+	}
+	m.infos[node+"#"+ver] = info
+}
+
+// capsResolver tracks the capabilities advertised by the contacts, and resolves them to their
+// service discovery information. Its zero value is ready to use, with an in-memory cache.
+type capsResolver struct {
+	mu    sync.Mutex
+	cache CapsCache
+	// Most recent caps advertised by each full JID
+	jids map[string]stanza.Caps
+	// Queries in flight, by caps node
+	pending map[string]*capsLookup
+	// Information queried from the full JIDs that do not advertise caps, until they go offline
+	discovered map[string]stanza.DiscoInfo
+}
+
+type capsLookup struct {
+	// done is closed once info or err is set
+	done chan struct{}
+	info stanza.DiscoInfo
+	err  error
+}
+
+// update records the caps advertised in presences, and retrieves their information if it is not
+// cached yet, so that it is available when needed.
+func (r *capsResolver) update(s Sender, p stanza.Packet) {
+	pres, ok := p.(stanza.Presence)
+	if !ok || pres.From == "" {
+		return
+	}
+	if pres.Type == stanza.PresenceTypeUnavailable || pres.Type == stanza.PresenceTypeError {
+		r.mu.Lock()
+		delete(r.jids, pres.From)
+		delete(r.discovered, pres.From)
+		r.mu.Unlock()
+		return
+	}
+	var caps stanza.Caps
+	if !pres.Get(&caps) || caps.Node == "" || caps.Ver == "" {
+		return
+	}
+
+	r.mu.Lock()
+	if r.jids == nil {
+		r.jids = make(map[string]stanza.Caps)
+	}
+	r.jids[pres.From] = caps
+	delete(r.discovered, pres.From)
+	r.mu.Unlock()
+
+	if _, ok := r.getCache().Get(caps.Node, caps.Ver); !ok {
+		r.start(s, pres.From, caps)
+	}
+}
+
+func (r *capsResolver) info(ctx context.Context, s Sender, jid string) (stanza.DiscoInfo, error) {
+	r.mu.Lock()
+	caps, ok := r.jids[jid]
+	r.mu.Unlock()
+	if !ok {
+		return stanza.DiscoInfo{}, ErrNoCaps
+	}
+
+	if info, ok := r.getCache().Get(caps.Node, caps.Ver); ok {
+		return info, nil
+	}
+	l := r.start(s, jid, caps)
+	select {
+	case <-l.done:
+		return l.info, l.err
+	case <-ctx.Done():
+		return stanza.DiscoInfo{}, ctx.Err()
+	}
+}
+
+// cachedInfo returns the cached information of the caps advertised by jid, or the information
+// queried from jid with discoInfo, without querying it.
+func (r *capsResolver) cachedInfo(jid string) (stanza.DiscoInfo, bool) {
+	r.mu.Lock()
+	caps, ok := r.jids[jid]
+	info, discovered := r.discovered[jid]
+	r.mu.Unlock()
+	if !ok {
+		return info, discovered
+	}
+	return r.getCache().Get(caps.Node, caps.Ver)
+}
+
+// discoInfo returns the information of jid, from its caps when they are cached. Otherwise, jid is
+// queried and the result is kept until it goes offline or advertises caps.
+func (r *capsResolver) discoInfo(ctx context.Context, s Sender, jid string) (stanza.DiscoInfo, error) {
+	if info, ok := r.cachedInfo(jid); ok {
+		return info, nil
+	}
+	info, err := getDiscoInfo(ctx, s, jid)
+	if err != nil {
+		return stanza.DiscoInfo{}, err
+	}
+	r.mu.Lock()
+	if r.discovered == nil {
+		r.discovered = make(map[string]stanza.DiscoInfo)
+	}
+	r.discovered[jid] = info
+	r.mu.Unlock()
+	return info, nil
+}
+
+// start queries jid for the information of the caps, unless a query for the same caps is in flight.
+func (r *capsResolver) start(s Sender, jid string, caps stanza.Caps) *capsLookup {
+	key := caps.CapsNode()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if l, ok := r.pending[key]; ok {
+		return l
+	}
+	if r.pending == nil {
+		r.pending = make(map[string]*capsLookup)
+	}
+	l := &capsLookup{done: make(chan struct{})}
+	r.pending[key] = l
+	go r.fetch(s, jid, caps, l)
+	return l
+}
+
+func (r *capsResolver) fetch(s Sender, jid string, caps stanza.Caps, l *capsLookup) {
+	ctx, cancel := context.WithTimeout(context.Background(), capsQueryTimeout)
+	defer cancel()
+	l.info, l.err = getCapsDiscoInfo(ctx, s, jid, caps)
+	// Legacy caps, without hash, and unsupported hashes cannot be verified: they are not cached
+	if l.err == nil && caps.Hash == stanza.CapsHashSHA1 {
+		r.getCache().Set(caps.Node, caps.Ver, l.info)
+	}
+
+	r.mu.Lock()
+	delete(r.pending, caps.CapsNode())
+	r.mu.Unlock()
+	close(l.done)
+}
+
+func (r *capsResolver) getCache() CapsCache {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cache == nil {
+		r.cache = &memoryCapsCache{}
+	}
+	return r.cache
+}
+
+func getCapsDiscoInfo(ctx context.Context, s Sender, jid string, caps stanza.Caps) (stanza.DiscoInfo, error) {
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, To: jid})
+	if err != nil {
+		return stanza.DiscoInfo{}, err
+	}
+	iq.DiscoInfo().SetNode(caps.CapsNode())
+
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return stanza.DiscoInfo{}, err
+	}
+	if err = iqError(result); err != nil {
+		return stanza.DiscoInfo{}, err
+	}
+	info, ok := result.Payload.(*stanza.DiscoInfo)
+	if !ok {
+		return stanza.DiscoInfo{}, errors.New("invalid caps info response")
+	}
+	if caps.Hash == stanza.CapsHashSHA1 && stanza.CapsVerification(*info) != caps.Ver {
+		return stanza.DiscoInfo{}, ErrCapsVerification
+	}
+	return *info, nil
+}
@@ -0,0 +1,260 @@
+package xmpp
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"sync"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+const capsTestNode = "https://gosrc.io/xmpp"
+
+func capsTestInfo(features ...string) stanza.DiscoInfo {
+	info := stanza.DiscoInfo{
+		Identity: []stanza.Identity{{Category: "client", Type: "bot", Name: "gox"}},
+	}
+	for _, f := range append([]string{stanza.NSDiscoInfo, stanza.NSCaps}, features...) {
+		info.Features = append(info.Features, stanza.Feature{Var: f})
+	}
+	return info
+}
+
+func TestCapsResponderRegisteredFeatures(t *testing.T) {
+	stanza.RegisterFeature("urn:xmpp:test:registered:0")
+	t.Cleanup(func() { stanza.UnregisterFeature("urn:xmpp:test:registered:0") })
+
+	r := NewCapsResponder(capsTestNode, capsTestInfo())
+	reply := queryCaps(t, r, r.Caps().CapsNode())
+	info, ok := reply.Payload.(*stanza.DiscoInfo)
+	if !ok || !info.HasFeature("urn:xmpp:test:registered:0") || !info.HasFeature(stanza.NSCaps) {
+		t.Fatalf("registered feature should be advertised: %+v", reply.Payload)
+	}
+	if r.Caps().Ver != stanza.CapsVerification(capsTestInfo("urn:xmpp:test:registered:0")) {
+		t.Errorf("verification string should include the registered feature")
+	}
+}
+
+// queryCaps routes a disco#info query for node and returns the reply.
+func queryCaps(t *testing.T, r *CapsResponder, node string) stanza.IQ {
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, From: "juliet@capulet.lit/balcony", To: "romeo@montague.lit/orchard", Id: "disco1"})
+	if err != nil {
+		t.Fatalf("could not create IQ: %v", err)
+	}
+	iq.Payload = &stanza.DiscoInfo{XMLName: xml.Name{Space: stanza.NSDiscoInfo, Local: "query"}, Node: node}
+
+	conn := NewSenderMock()
+	router := NewRouter()
+	r.Route(router)
+	router.route(conn, iq)
+
+	var reply stanza.IQ
+	if err := xml.Unmarshal([]byte(conn.String()), &reply); err != nil {
+		t.Fatalf("could not unmarshal reply %q: %v", conn.String(), err)
+	}
+	if reply.Id != "disco1" || reply.To != "juliet@capulet.lit/balcony" {
+		t.Errorf("unexpected reply attributes: %+v", reply.Attrs)
+	}
+	return reply
+}
+
+func TestCapsResponderCurrentAndPrevious(t *testing.T) {
+	r := NewCapsResponder(capsTestNode, capsTestInfo())
+	previous := r.Caps()
+	r.SetInfo(capsTestInfo(stanza.NSMucUser))
+	current := r.Caps()
+	if previous.Ver == current.Ver {
+		t.Fatalf("verification string should change with the features")
+	}
+
+	for _, caps := range []stanza.Caps{current, previous} {
+		reply := queryCaps(t, r, caps.CapsNode())
+		info, ok := reply.Payload.(*stanza.DiscoInfo)
+		if reply.Type != stanza.IQTypeResult || !ok {
+			t.Fatalf("unexpected reply for %s: %+v", caps.Ver, reply)
+		}
+		if info.Node != caps.CapsNode() {
+			t.Errorf("reply should be for node %s, got %s", caps.CapsNode(), info.Node)
+		}
+		if ver := stanza.CapsVerification(*info); ver != caps.Ver {
+			t.Errorf("returned information hashes to %s, expected %s", ver, caps.Ver)
+		}
+	}
+}
+
+func TestCapsResponderNoNode(t *testing.T) {
+	r := NewCapsResponder(capsTestNode, capsTestInfo())
+	reply := queryCaps(t, r, "")
+	info, ok := reply.Payload.(*stanza.DiscoInfo)
+	if !ok || info.Node != "" || stanza.CapsVerification(*info) != r.Caps().Ver {
+		t.Errorf("unexpected reply: %+v", reply)
+	}
+}
+
+func TestCapsResponderHistoryBound(t *testing.T) {
+	r := NewCapsResponder(capsTestNode, capsTestInfo())
+	first := r.Caps()
+	for i := 0; i <= capsHistorySize; i++ {
+		r.SetInfo(capsTestInfo(string(rune('a' + i))))
+	}
+	reply := queryCaps(t, r, first.CapsNode())
+	if reply.Type != stanza.IQTypeError || reply.Error == nil || reply.Error.Reason != "item-not-found" {
+		t.Errorf("expired verification string should be item-not-found: %+v", reply)
+	}
+
+	reply = queryCaps(t, r, capsTestNode+"#unknown")
+	if reply.Type != stanza.IQTypeError {
+		t.Errorf("unknown verification string should be an error: %+v", reply)
+	}
+}
+
+func TestCapsResponderSetSameInfo(t *testing.T) {
+	r := NewCapsResponder(capsTestNode, capsTestInfo())
+	r.SetInfo(capsTestInfo())
+	if len(r.history) != 0 {
+		t.Errorf("unchanged capabilities should not be kept in history: %+v", r.history)
+	}
+}
+
+func TestCapsResponderNotify(t *testing.T) {
+	r := NewCapsResponder(capsTestNode, capsTestInfo())
+	initial := r.Caps()
+	if !r.AddNotify(stanza.NodeBookmarks) || r.AddNotify(stanza.NodeBookmarks) {
+		t.Fatalf("only the first registration of a node should change the capabilities")
+	}
+	if r.Caps().Ver != stanza.CapsVerification(capsTestInfo(stanza.NodeBookmarks+"+notify")) {
+		t.Errorf("notify feature should be advertised: %+v", r.current.info.Features)
+	}
+	// The notify features are kept when the application changes the information
+	r.SetInfo(capsTestInfo(stanza.NSMucUser))
+	if !r.current.info.HasFeature(stanza.NodeBookmarks+"+notify") || !r.current.info.HasFeature(stanza.NSMucUser) {
+		t.Errorf("unexpected features: %+v", r.current.info.Features)
+	}
+
+	r.SetInfo(capsTestInfo())
+	if r.RemoveNotify(stanza.NodeBookmarks) || !r.RemoveNotify(stanza.NodeBookmarks) || r.RemoveNotify(stanza.NodeBookmarks) {
+		t.Fatalf("only the last removal of a node should change the capabilities")
+	}
+	if r.Caps().Ver != initial.Ver {
+		t.Errorf("capabilities should be back to the initial ones: %+v", r.current.info.Features)
+	}
+}
+
+// capsIQSender answers disco#info queries with info, once release is closed.
+type capsIQSender struct {
+	SenderMock
+	info    stanza.DiscoInfo
+	release chan struct{}
+
+	mu       sync.Mutex
+	requests []*stanza.IQ
+}
+
+func newCapsIQSender(info stanza.DiscoInfo) *capsIQSender {
+	return &capsIQSender{SenderMock: NewSenderMock(), info: info, release: make(chan struct{})}
+}
+
+func (s *capsIQSender) SendIQ(ctx context.Context, iq *stanza.IQ) (chan stanza.IQ, error) {
+	s.mu.Lock()
+	s.requests = append(s.requests, iq)
+	s.mu.Unlock()
+
+	reply, _ := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeResult, From: iq.To, Id: iq.Id})
+	info := s.info
+	reply.Payload = &info
+	res := make(chan stanza.IQ, 1)
+	go func() {
+		<-s.release
+		res <- *reply
+	}()
+	return res, nil
+}
+
+func (s *capsIQSender) sent() []*stanza.IQ {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*stanza.IQ(nil), s.requests...)
+}
+
+func capsPresence(from string, caps stanza.Caps) stanza.Presence {
+	p := stanza.NewPresence(stanza.Attrs{From: from})
+	p.Extensions = append(p.Extensions, &caps)
+	return p
+}
+
+func TestCapsLookupDeduplication(t *testing.T) {
+	info := capsTestInfo(stanza.NSMucUser)
+	caps := NewCapsResponder(capsTestNode, info).Caps()
+	sender := newCapsIQSender(info)
+
+	var r capsResolver
+	r.update(sender, capsPresence("romeo@montague.lit/orchard", caps))
+	r.update(sender, capsPresence("juliet@capulet.lit/balcony", caps))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for _, jid := range []string{"romeo@montague.lit/orchard", "juliet@capulet.lit/balcony"} {
+		wg.Add(1)
+		go func(jid string) {
+			defer wg.Done()
+			got, err := r.info(context.Background(), sender, jid)
+			if err == nil && !got.HasFeature(stanza.NSMucUser) {
+				err = fmt.Errorf("unexpected info for %s: %+v", jid, got)
+			}
+			errs <- err
+		}(jid)
+	}
+	close(sender.release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	requests := sender.sent()
+	if len(requests) != 1 {
+		t.Fatalf("expected a single disco#info query, got %d", len(requests))
+	}
+	query, ok := requests[0].Payload.(*stanza.DiscoInfo)
+	if !ok || query.Node != caps.CapsNode() || requests[0].To != "romeo@montague.lit/orchard" {
+		t.Errorf("unexpected query: %+v", requests[0])
+	}
+
+	// Information is now cached
+	if _, err := r.info(context.Background(), sender, "juliet@capulet.lit/balcony"); err != nil || len(sender.sent()) != 1 {
+		t.Errorf("cached capabilities should not be queried again: %v", err)
+	}
+}
+
+func TestCapsLookupVerification(t *testing.T) {
+	caps := NewCapsResponder(capsTestNode, capsTestInfo()).Caps()
+	// The contact answers with features that do not match the advertised hash
+	sender := newCapsIQSender(capsTestInfo(stanza.NSMucUser))
+	close(sender.release)
+	cache := &memoryCapsCache{}
+	config := Config{}
+	config.Apply(WithCapsCache(cache))
+	r := capsResolver{cache: config.CapsCache}
+
+	r.update(sender, capsPresence("romeo@montague.lit/orchard", caps))
+	if _, err := r.info(context.Background(), sender, "romeo@montague.lit/orchard"); err != ErrCapsVerification {
+		t.Errorf("expected ErrCapsVerification, got %v", err)
+	}
+	if _, ok := cache.Get(caps.Node, caps.Ver); ok {
+		t.Errorf("unverified information should not be cached")
+	}
+}
+
+func TestCapsLookupUnknownJID(t *testing.T) {
+	var r capsResolver
+	sender := newCapsIQSender(capsTestInfo())
+	r.update(sender, capsPresence("romeo@montague.lit/orchard", NewCapsResponder(capsTestNode, capsTestInfo()).Caps()))
+	r.update(sender, stanza.NewPresence(stanza.Attrs{From: "romeo@montague.lit/orchard", Type: stanza.PresenceTypeUnavailable}))
+	if _, err := r.info(context.Background(), sender, "romeo@montague.lit/orchard"); err != ErrNoCaps {
+		t.Errorf("expected ErrNoCaps, got %v", err)
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"encoding/xml"
 	"errors"
+	"fmt"
 	"io"
 	"net"
+	"net/http"
+	"strings"
 	"sync"
 	"time"
 
@@ -57,6 +60,15 @@ type Event struct {
 	Description string
 	StreamError string
 	SMState     SMState
+	// Warning describes a non fatal issue detected on the connection. State is unchanged.
+	Warning string
+	// Err is the error that caused the disconnection, if any. Decoding failures are reported as *DecodeError.
+	Err error
+	// Resumed tells if the stream management session was resumed, when the session is established
+	Resumed bool
+	// EnabledFeatures are the namespaces of the features enabled when the session is established,
+	// whether they were enabled inline with SASL2 or after binding the resource
+	EnabledFeatures []string
 }
 
 // SMState holds Stream Management information regarding the session that can be
@@ -100,12 +112,28 @@ func (em *EventManager) updateState(state ConnState) {
 	}
 }
 
+// sessionEstablished changes the CurrentState in the event manager to "session established", with
+// the outcome of the negotiation.
+func (em *EventManager) sessionEstablished(s *Session) {
+	em.CurrentState.setState(StateSessionEstablished)
+	if em.Handler != nil {
+		em.Handler(Event{State: em.CurrentState, SMState: s.SMState, Resumed: s.Resumed, EnabledFeatures: s.EnabledFeatures})
+	}
+}
+
 // disconnected changes the CurrentState in the event manager to "disconnected". The state read is threadsafe but there is no guarantee
 // regarding the triggered callback function.
-func (em *EventManager) disconnected(state SMState) {
+func (em *EventManager) disconnected(state SMState, err error) {
 	em.CurrentState.setState(StateDisconnected)
 	if em.Handler != nil {
-		em.Handler(Event{State: em.CurrentState, SMState: state})
+		em.Handler(Event{State: em.CurrentState, SMState: state, Err: err})
+	}
+}
+
+// warning notifies the handler of a non fatal issue, without changing the CurrentState.
+func (em *EventManager) warning(desc string) {
+	if em.Handler != nil {
+		em.Handler(Event{State: SyncConnState{state: em.CurrentState.getState()}, Warning: desc})
 	}
 }
 
@@ -143,6 +171,44 @@ type Client struct {
 
 	// Post resume hook. This will be executed after the client resumes a lost connection using StreamManagement (XEP-0198)
 	PostResumeHook func() error
+
+	// Recently received message ids, kept across resumptions to detect duplicates
+	recentIds *recentIds
+
+	// Bookmarks of the account, kept up to date with PEP notifications
+	bookmarks bookmarkCache
+	// Rooms the client is an occupant of
+	rooms joinedRooms
+	// Capabilities advertised by the contacts
+	caps capsResolver
+	// Available resources of the contacts
+	resources resourceTracker
+	// Block list of the account, kept up to date with the server pushes
+	blockList blockListCache
+	// Roster of the account, kept up to date with the server pushes when no RosterManager is attached
+	roster rosterCache
+	// Whitelist of the JIDs allowed to decloak us
+	decloak decloakFilter
+	// Round-trip times of the pings sent by the client, and response times of the server pings
+	pings pingTracker
+	// Results of the archive queries in progress
+	mamQueries mamQueries
+	// HTTP client verifying upload slots and uploading files
+	httpUpload *http.Client
+	// Events of the first connection attempt
+	dialEvents dialEvents
+	// Stanzas suppressed by the server while the client was inactive
+	csi csiState
+	// Stanzas being handled by the router, waited for by Drain
+	inbound inboundTracker
+	// Stream management state exported by a previous process, resumed by the next connection
+	importedSM *SMResumptionState
+	// Servers the client connects to, with their health
+	endpoints *endpointPool
+	// Tokens skipped between the received stanzas
+	skippedTokens *stanza.SkippedTokens
+	// Last broadcast presence sent by the application, re-sent when the capabilities change
+	lastPresence broadcastPresence
 }
 
 /*
@@ -152,9 +218,12 @@ Setting up the client / Checking the parameters
 // NewClient generates a new XMPP client, based on Config passed as parameters.
 // If host is not specified, the DNS SRV should be used to find the host from the domain part of the Jid.
 // Default the port to 5222.
-func NewClient(config *Config, r *Router, errorHandler func(error)) (c *Client, err error) {
+// The options are applied to the configuration before it is checked.
+func NewClient(config *Config, r *Router, errorHandler func(error), opts ...Option) (c *Client, err error) {
+	config.Apply(opts...)
 	if config.KeepaliveInterval == 0 {
 		config.KeepaliveInterval = time.Second * 30
+		config.defaultKeepalive = true
 	}
 	// Parse Jid
 	if config.parsedJid, err = stanza.NewJid(config.Jid); err != nil {
@@ -166,24 +235,49 @@ func NewClient(config *Config, r *Router, errorHandler func(error)) (c *Client,
 		err = errors.New("missing credential")
 		return nil, NewConnError(err, true)
 	}
+	if len(config.SASLMechanisms) > 0 {
+		config.Credential = config.Credential.WithMechanisms(config.SASLMechanisms...)
+	}
 
 	// Fallback to jid domain
-	if config.Address == "" {
+	var dnsStart time.Time
+	var dnsErr error
+	var dnsDetail string
+	var endpoints []string
+	switch {
+	case len(config.Endpoints) > 0:
+		endpoints = config.Endpoints
+		config.Address = endpoints[0]
+	case config.Address == "":
 		config.Address = config.parsedJid.Domain
 
 		// Fetch SRV DNS-Entries
+		dnsStart = time.Now()
 		_, srvEntries, err := net.LookupSRV("xmpp-client", "tcp", config.parsedJid.Domain)
+		dnsErr = err
+		dnsDetail = fmt.Sprintf("%d SRV records", len(srvEntries))
 
+		bestIndex := 0
 		if err == nil && len(srvEntries) > 0 {
 			// If we found matching DNS records, use the entry with highest weight
 			bestSrv := srvEntries[0]
-			for _, srv := range srvEntries {
+			for i, srv := range srvEntries {
 				if srv.Priority <= bestSrv.Priority && srv.Weight >= bestSrv.Weight {
 					bestSrv = srv
+					bestIndex = i
 					config.Address = ensurePort(srv.Target, int(srv.Port))
 				}
 			}
 		}
+		// The other records are failover endpoints, in order of priority
+		endpoints = []string{config.Address}
+		for i, srv := range srvEntries {
+			if i != bestIndex {
+				endpoints = append(endpoints, ensurePort(srv.Target, int(srv.Port)))
+			}
+		}
+	default:
+		endpoints = []string{config.Address}
 	}
 	if config.Domain == "" {
 		// Fallback to jid domain
@@ -194,16 +288,30 @@ func NewClient(config *Config, r *Router, errorHandler func(error)) (c *Client,
 	c.config = config
 	c.router = r
 	c.ErrorHandler = errorHandler
+	c.caps.cache = config.CapsCache
+	c.httpUpload = newHTTPUploadClient(config.HTTPUpload, nil)
+	c.skippedTokens = &stanza.SkippedTokens{}
+	if config.DecloakPolicy != nil {
+		c.decloak.allow(config.DecloakPolicy.whitelist)
+	}
+	if !dnsStart.IsZero() {
+		c.dialEvents.emit(DialStageDNS, config.Address, dnsDetail, dnsStart, dnsErr)
+	}
 
 	if c.config.ConnectTimeout == 0 {
 		c.config.ConnectTimeout = 15 // 15 second as default
 	}
 
+	if c.config.StreamManagementEnable && c.config.InboundDuplicateWindow > 0 {
+		c.recentIds = newRecentIds(c.config.InboundDuplicateWindow)
+	}
+
 	if config.TransportConfiguration.Domain == "" {
 		config.TransportConfiguration.Domain = config.parsedJid.Domain
 	}
 	c.config.TransportConfiguration.ConnectTimeout = c.config.ConnectTimeout
 	c.transport = NewClientTransport(c.config.TransportConfiguration)
+	c.endpoints = newEndpointPool(endpoints, c.config.EndpointFailureThreshold)
 
 	if config.StreamLogger != nil {
 		c.transport.LogTraffic(config.StreamLogger)
@@ -215,13 +323,15 @@ func NewClient(config *Config, r *Router, errorHandler func(error)) (c *Client,
 // Connect establishes a first time connection to a XMPP server.
 // It calls the PostConnectHook
 func (c *Client) Connect() error {
-	err := c.connect()
+	err := c.connectEndpoints()
+	c.dialEvents.close()
 	if err != nil {
-		return err
+		return c.dialEvents.failure(err)
 	}
 	// TODO: Do we always want to send initial presence automatically ?
 	// Do we need an option to avoid that or do we rely on client to send the presence itself ?
-	err = c.sendWithWriter(c.transport, []byte(InitialPresence))
+	// The presence is counted by stream management, to resend the right stanzas on resumption
+	err = c.SendRaw(InitialPresence)
 	// Execute the post first connection hook. Typically this holds "ask for roster" and this type of actions.
 	if c.PostConnectHook != nil {
 		err = c.PostConnectHook()
@@ -232,7 +342,7 @@ func (c *Client) Connect() error {
 
 	// Start the keepalive go routine
 	keepaliveQuit := make(chan struct{})
-	go keepalive(c.transport, c.config.KeepaliveInterval, keepaliveQuit)
+	go keepalive(c.transport, c.keepalivePing(), keepaliveInterval(c.config, c.StreamLimits()), keepaliveQuit)
 	// Start the receiver go routine
 	go c.recv(keepaliveQuit)
 	return err
@@ -240,10 +350,18 @@ func (c *Client) Connect() error {
 
 // connect establishes an actual TCP connection, based on previously defined parameters, as well as a XMPP session
 func (c *Client) connect() error {
-	var state SMState
 	var err error
+	state, bindJid := c.takeImportedSMState()
 	// This is the TCP connection
-	streamId, err := c.transport.Connect()
+	var streamId string
+	if t, ok := c.transport.(dialTracer); ok {
+		t.setDialTrace(c.traceDial)
+		streamId, err = c.transport.Connect()
+	} else {
+		start := time.Now()
+		streamId, err = c.transport.Connect()
+		c.traceDial(DialStageConnect, "", start, err)
+	}
 	if err != nil {
 		return err
 	}
@@ -251,19 +369,24 @@ func (c *Client) connect() error {
 	// Client is ok, we now open XMPP session with TLS negotiation if possible and session resume or binding
 	// depending on state.
 	if c.Session, err = NewSession(c, state); err != nil {
-		// Try to get the stream close tag from the server.
+		// Try to get the stream close tag from the server. The transport is replaced when connecting to
+		// another endpoint.
+		transport := c.transport
 		go func() {
 			for {
-				val, err := stanza.NextPacket(c.transport.GetDecoder())
+				dec := transport.GetDecoder()
+				start := dec.InputOffset()
+				val, err := stanza.NextPacket(dec)
 				if err != nil {
+					err = decodeError(transport, dec, err, start)
 					c.ErrorHandler(err)
-					c.disconnected(state)
+					c.disconnected(state, err)
 					return
 				}
 				switch val.(type) {
 				case stanza.StreamClosePacket:
 					// TCP messages should arrive in order, so we can expect to get nothing more after this occurs
-					c.transport.ReceivedStreamClose()
+					transport.ReceivedStreamClose()
 					return
 				}
 			}
@@ -272,7 +395,15 @@ func (c *Client) connect() error {
 		return err
 	}
 	c.Session.StreamId = streamId
-	c.updateState(StateSessionEstablished)
+	if state.Id != "" && c.Session.SMState.Id == state.Id && c.Session.BindJid == "" {
+		// The imported session was resumed: no resource was bound
+		c.Session.BindJid = bindJid
+	}
+	startReadLimit(c.transport)
+	if c.Session.Resumed {
+		c.recentIds.resumed()
+	}
+	c.sessionEstablished(c.Session)
 
 	return err
 }
@@ -281,7 +412,7 @@ func (c *Client) connect() error {
 // state. See XEP-0198
 func (c *Client) Resume() error {
 	c.EventManager.updateState(StateResuming)
-	err := c.connect()
+	err := c.reconnectEndpoint()
 	if err != nil {
 		return err
 	}
@@ -312,10 +443,17 @@ func (c *Client) Send(packet stanza.Packet) error {
 	if conn == nil {
 		return errors.New("client is not connected")
 	}
+	if c.config.StreamManagementEnable {
+		packet = withOriginId(packet)
+	}
+	c.lastPresence.record(packet)
 
-	data, err := xml.Marshal(packet)
+	data, err := stanza.MarshalPacket(packet, c.config.InvalidCharPolicy)
 	if err != nil {
-		return errors.New("cannot marshal packet " + err.Error())
+		return fmt.Errorf("cannot marshal packet %w", err)
+	}
+	if err = checkStanzaSize(c.StreamLimits(), data); err != nil {
+		return err
 	}
 
 	// Store stanza as non-acked as part of stream management
@@ -327,6 +465,14 @@ func (c *Client) Send(packet stanza.Packet) error {
 		}
 	}
 
+	if t := c.config.DeliveryTracker; t != nil {
+		if key, tracked := t.sending(packet); tracked {
+			if err = c.sendWithWriter(c.transport, data); err != nil {
+				t.forget(key)
+			}
+			return err
+		}
+	}
 	return c.sendWithWriter(c.transport, data)
 }
 
@@ -339,14 +485,85 @@ func (c *Client) Send(packet stanza.Packet) error {
 //   ctx, _ := context.WithTimeout(context.Background(), 30 * time.Second)
 //   result := <- client.SendIQ(ctx, iq)
 //
+// An IQ without recipient is sent as is: it is handled by the server on behalf of the account.
+// The result is only accepted from the recipient of the IQ or its bare JID, or from the account or
+// its server if the IQ has no recipient. Results from other senders are reported as warning events.
 func (c *Client) SendIQ(ctx context.Context, iq *stanza.IQ) (chan stanza.IQ, error) {
 	if iq.Attrs.Type != stanza.IQTypeSet && iq.Attrs.Type != stanza.IQTypeGet {
 		return nil, ErrCanOnlySendGetOrSetIq
 	}
-	if err := c.Send(iq); err != nil {
-		return nil, err
+	from := c.iqResultSenders(iq)
+	send := func() (chan stanza.IQ, error) {
+		res, err := sendIQ(ctx, c.config.IQTracer, iq, c.Send, c.router, from)
+		if _, ok := iq.Payload.(*stanza.Ping); ok && err == nil {
+			res = c.pings.track(ctx, res)
+		}
+		return res, err
+	}
+	if scheduler := c.config.IQScheduler; scheduler != nil {
+		return scheduler.schedule(ctx, iq.To, send)
 	}
-	return c.router.NewIQResultRoute(ctx, iq.Attrs.Id), nil
+	return send()
+}
+
+// BareJID returns the bare JID of the account, as bound by the server. Before the session is
+// established, it is the bare JID of the configured JID.
+func (c *Client) BareJID() string {
+	jid := c.jid()
+	return jid.Node + "@" + jid.Domain
+}
+
+// ServerJID returns the JID of the server hosting the account.
+func (c *Client) ServerJID() string {
+	return c.jid().Domain
+}
+
+// SkippedTokens returns the number of whitespace keepalives, comments and processing instructions
+// received between stanzas since the client was created.
+func (c *Client) SkippedTokens() stanza.SkippedTokens {
+	return c.skippedTokens.Load()
+}
+
+func (c *Client) jid() *stanza.Jid {
+	if c.Session != nil && c.Session.BindJid != "" {
+		if jid, err := stanza.NewJid(c.Session.BindJid); err == nil {
+			return jid
+		}
+	}
+	if c.config.parsedJid != nil {
+		return c.config.parsedJid
+	}
+	return &stanza.Jid{}
+}
+
+// iqResultSenders returns the JIDs a result to the IQ is accepted from.
+func (c *Client) iqResultSenders(iq *stanza.IQ) []string {
+	var full string
+	if c.Session != nil {
+		full = c.Session.BindJid
+	}
+	return iqResultSenders(iq.To, c.BareJID(), full, c.ServerJID())
+}
+
+// iqResultSenders returns the JIDs a result to a request sent to the JID to is accepted from, for
+// the account bare and full JIDs, following RFC 6120 - 8.1.2.1.
+// Servers answer requests sent to the account either without from attribute, or from the bare JID
+// of the account, its full JID, or the server domain. Requests sent to a full JID are answered from
+// that JID, or from its bare JID as done by some components. Other requests are only answered from
+// their recipient.
+func iqResultSenders(to, bare, full, server string) []string {
+	if to == "" || strings.EqualFold(to, bare) {
+		from := []string{"", bare, server}
+		if full != "" {
+			from = append(from, full)
+		}
+		return from
+	}
+	from := []string{to}
+	if jid, err := stanza.NewJid(to); err == nil && jid.Resource != "" {
+		from = append(from, jid.Bare())
+	}
+	return from
 }
 
 // SendRaw sends an XMPP stanza as a string to the server.
@@ -358,6 +575,9 @@ func (c *Client) SendRaw(packet string) error {
 	if conn == nil {
 		return errors.New("client is not connected")
 	}
+	if err := checkStanzaSize(c.StreamLimits(), []byte(packet)); err != nil {
+		return err
+	}
 
 	// Store stanza as non-acked as part of stream management
 	// See https://xmpp.org/extensions/xep-0198.html#scenarios
@@ -382,10 +602,34 @@ func (c *Client) recv(keepaliveQuit chan<- struct{}) {
 	defer close(keepaliveQuit)
 
 	for {
-		val, err := stanza.NextPacket(c.transport.GetDecoder())
+		dec := c.transport.GetDecoder()
+		start := dec.InputOffset()
+		val, err := stanza.NextPacketCounting(dec, c.skippedTokens)
+		if c.config.WhitespacePing && c.config.WhitespacePongHandler != nil {
+			c.config.WhitespacePongHandler.readDone(err)
+		}
 		if err != nil {
+			var interrupted *stanza.InterruptedStanzaError
+			if errors.As(err, &interrupted) {
+				// The stream is closed in the middle of a stanza: the stream close tag of the server
+				// cannot be decoded anymore, so there is no need to wait for it
+				c.router.route(c, interrupted.StreamError)
+				c.streamError(interrupted.StreamError.Error.Local, interrupted.StreamError.Text)
+				go c.transport.ReceivedStreamClose()
+				c.Disconnect()
+			} else {
+				if errors.Is(err, stanza.ErrStanzaTooComplex) {
+					c.streamError("policy-violation", err.Error())
+					policyViolation(c.transport, err)
+				} else if errors.Is(err, stanza.ErrUnexpectedCharData) {
+					c.streamError("bad-format", err.Error())
+					badFormat(c.transport, err)
+				}
+				err = decodeError(c.transport, dec, err, start)
+			}
+			c.endpoints.takeFailover()
 			c.ErrorHandler(err)
-			c.disconnected(c.Session.SMState)
+			c.disconnected(c.Session.SMState, err)
 			return
 		}
 
@@ -411,26 +655,89 @@ func (c *Client) recv(keepaliveQuit chan<- struct{}) {
 		case stanza.StreamClosePacket:
 			// TCP messages should arrive in order, so we can expect to get nothing more after this occurs
 			c.transport.ReceivedStreamClose()
+			if c.endpoints.takeFailover() {
+				// Reconnect to the next endpoint
+				c.disconnected(c.Session.SMState, ErrFailover)
+			}
 			return
+		case stanza.CSIActive:
+			// Answer of the server to the active nonza, with the suppressed stanzas count
+			c.csi.setSuppressed(packet.Suppressed)
+			continue
 		default:
 			c.Session.SMState.Inbound++
 		}
+
+		c.updateBookmarks(val)
+		c.updateJoinedRooms(val)
+		c.caps.update(c, val)
+		c.resources.update(val)
+		if m := c.config.ResourceManager; m != nil {
+			m.update(val)
+		}
+		if m := c.config.RosterManager; m != nil {
+			m.update(c, c.BareJID(), val)
+		} else {
+			c.roster.update(c.BareJID(), val)
+		}
+		if t := c.config.DeliveryTracker; t != nil {
+			t.received(val)
+		}
+		c.handleSubscriptionRequest(val)
+		if c.updateBlockList(val) || c.answerServerPing(val) || c.mamQueries.collect(val) || c.handleDecloakRequest(val) {
+			// Block list pushes, server pings and decloaking requests are answered by the client, and
+			// archive query results are returned to the caller of the query
+			continue
+		}
+
+		sender, ok := c.recentIds.sender(c, val, c.config.InboundDuplicatePolicy)
+		if !ok {
+			continue
+		}
+		// Duplicates are not answered again
+		if !IsDuplicate(sender) {
+			c.autoReply(val)
+		}
 		// Do normal route processing in a go-routine so we can immediately
 		// start receiving other stanzas. This also allows route handlers to
 		// send and receive more stanzas.
-		go c.router.route(c, val)
+		c.inbound.received()
+		go func() {
+			defer c.inbound.done()
+			c.router.route(sender, val)
+		}()
+	}
+}
+
+// keepalivePing returns the function used by the keepalive loop to ping the server.
+// Whitespace ping is used when configured and supported by the transport.
+func (c *Client) keepalivePing() func() error {
+	wp, ok := c.transport.(WhitespacePinger)
+	if !c.config.WhitespacePing || !ok {
+		return c.transport.Ping
+	}
+	h := c.config.WhitespacePongHandler
+	if h == nil {
+		return wp.WhitespacePing
+	}
+	h.reset()
+	return func() error {
+		err := wp.WhitespacePing()
+		h.pingDone(err)
+		return err
 	}
 }
 
 // Loop: send whitespace keepalive to server
 // This is use to keep the connection open, but also to detect connection loss
 // and trigger proper client connection shutdown.
-func keepalive(transport Transport, interval time.Duration, quit <-chan struct{}) {
+// Pings are sent at each interval, regardless of other traffic on the connection.
+func keepalive(transport Transport, ping func() error, interval time.Duration, quit <-chan struct{}) {
 	ticker := time.NewTicker(interval)
 	for {
 		select {
 		case <-ticker.C:
-			if err := transport.Ping(); err != nil {
+			if err := ping(); err != nil {
 				// When keepalive fails, we force close the transport. In all cases, the recv will also fail.
 				ticker.Stop()
 				_ = transport.Close()
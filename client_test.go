@@ -5,6 +5,8 @@ import (
 	"encoding/xml"
 	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -25,7 +27,7 @@ func TestEventManager(t *testing.T) {
 		t.Fatal("CurrentState not updated by updateState()")
 	}
 
-	mgr.disconnected(SMState{})
+	mgr.disconnected(SMState{}, nil)
 
 	if mgr.CurrentState.getState() != StateDisconnected {
 		t.Fatalf("CurrentState not reset by disconnected()")
@@ -330,6 +332,61 @@ func TestClient_SendRaw(t *testing.T) {
 	}
 }
 
+// The server closes the stream with a stream error while the client is reading a message.
+func TestClient_StreamErrorInsideStanza(t *testing.T) {
+	ready := make(chan struct{})
+	done := make(chan struct{})
+	h := func(t *testing.T, sc *ServerConn) {
+		handlerClientConnectSuccess(t, sc)
+		discardPresence(t, sc)
+		<-ready
+		fmt.Fprint(sc.connection, `<message from='juliet@capulet.lit/balcony' to='test@localhost' type='chat'><body>Wherefore art thou</body>`)
+		fmt.Fprint(sc.connection, `<stream:error><conflict xmlns='urn:ietf:params:xml:ns:xmpp-streams'/></stream:error>`)
+		fmt.Fprint(sc.connection, stanza.StreamClose)
+		close(done)
+	}
+	client, mock := mockClientConnection(t, h, testClientStreamErrorPort)
+	defer mock.Stop()
+
+	streamErrors := make(chan stanza.StreamError, 1)
+	client.router.NewRoute().HandlerFunc(func(s Sender, p stanza.Packet) {
+		if streamErr, ok := p.(stanza.StreamError); ok {
+			streamErrors <- streamErr
+		}
+	})
+	errChan := make(chan error, 10)
+	client.ErrorHandler = func(err error) {
+		errChan <- err
+	}
+	close(ready)
+
+	select {
+	case err := <-errChan:
+		var interrupted *stanza.InterruptedStanzaError
+		if !errors.As(err, &interrupted) || interrupted.Stanza != "message" || interrupted.StreamError.Error.Local != "conflict" {
+			t.Errorf("expected the stream error to be reported, got %v", err)
+		}
+	case <-time.After(defaultChannelTimeout):
+		t.Fatal("stream error was not reported")
+	}
+	select {
+	case streamErr := <-streamErrors:
+		if streamErr.Error.Local != "conflict" {
+			t.Errorf("unexpected stream error: %+v", streamErr)
+		}
+	case <-time.After(defaultChannelTimeout):
+		t.Fatal("stream error was not routed")
+	}
+	deadline := time.Now().Add(defaultChannelTimeout)
+	for client.CurrentState.getState() != StateDisconnected {
+		if time.Now().After(deadline) {
+			t.Fatalf("client should be disconnected, state is %d", client.CurrentState.getState())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	<-done
+}
+
 func TestClient_Disconnect(t *testing.T) {
 	c, m := mockClientConnection(t, func(t *testing.T, sc *ServerConn) {
 		handlerClientConnectSuccess(t, sc)
@@ -657,3 +714,106 @@ func mockClientConnection(t *testing.T, serverHandler func(*testing.T, *ServerCo
 // It's just meant to be a placeholder when error handling is not needed at this level
 func clientDefaultErrorHandler(err error) {
 }
+
+func TestClientAccountJIDs(t *testing.T) {
+	jid, _ := stanza.NewJid("romeo@montague.lit/config")
+	client := &Client{config: &Config{parsedJid: jid}}
+	if client.BareJID() != "romeo@montague.lit" || client.ServerJID() != "montague.lit" {
+		t.Errorf("unexpected JIDs before bind: %s, %s", client.BareJID(), client.ServerJID())
+	}
+	// Server may change the JID at bind time
+	client.Session = &Session{BindJid: "romeo@Montague.lit/orchard"}
+	if client.BareJID() != "romeo@Montague.lit" || client.ServerJID() != "Montague.lit" {
+		t.Errorf("unexpected JIDs after bind: %s, %s", client.BareJID(), client.ServerJID())
+	}
+}
+
+func TestIQResultSenders(t *testing.T) {
+	tests := []struct {
+		to   string
+		from []string
+	}{
+		{"", []string{"", "romeo@montague.lit", "montague.lit", "romeo@montague.lit/orchard"}},
+		{"romeo@montague.lit", []string{"", "romeo@montague.lit", "montague.lit", "romeo@montague.lit/orchard"}},
+		{"juliet@capulet.lit", []string{"juliet@capulet.lit"}},
+		{"juliet@capulet.lit/balcony", []string{"juliet@capulet.lit/balcony", "juliet@capulet.lit"}},
+	}
+	for _, tc := range tests {
+		from := iqResultSenders(tc.to, "romeo@montague.lit", "romeo@montague.lit/orchard", "montague.lit")
+		if !reflect.DeepEqual(from, tc.from) {
+			t.Errorf("unexpected senders for %q: %q", tc.to, from)
+		}
+	}
+}
+
+func TestClientIQResultRejectedWarning(t *testing.T) {
+	jid, _ := stanza.NewJid("romeo@montague.lit/orchard")
+	client := &Client{config: &Config{parsedJid: jid}}
+	var warnings []string
+	client.SetHandler(func(e Event) error {
+		warnings = append(warnings, e.Warning)
+		return nil
+	})
+
+	router := NewRouter()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	iq, _ := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, To: "juliet@capulet.lit/balcony"})
+	router.NewIQResultRouteFrom(ctx, iq.Id, client.iqResultSenders(iq)...)
+
+	answer, _ := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeResult, Id: iq.Id, From: "tybalt@capulet.lit/street"})
+	router.route(client, answer)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "tybalt@capulet.lit/street") {
+		t.Errorf("rejected result should be reported: %q", warnings)
+	}
+}
+
+func TestClientIQResultToAccount(t *testing.T) {
+	jid, _ := stanza.NewJid("romeo@montague.lit/orchard")
+	client := &Client{config: &Config{parsedJid: jid}, Session: &Session{BindJid: "romeo@montague.lit/orchard"}}
+
+	tests := []struct {
+		name     string
+		to       string
+		from     string
+		accepted bool
+	}{
+		// Behaviours seen in the wild for requests without recipient
+		{"empty to, answered without from", "", "", true},
+		{"empty to, answered from bare JID", "", "romeo@montague.lit", true},
+		{"empty to, answered from domain", "", "montague.lit", true},
+		{"empty to, answered from full JID", "", "romeo@montague.lit/orchard", true},
+		{"empty to, spoofed answer", "", "juliet@capulet.lit", false},
+		{"to bare JID, answered without from", "romeo@montague.lit", "", true},
+		{"to bare JID, answered from bare JID", "romeo@montague.lit", "Romeo@Montague.lit", true},
+		{"to contact, answered by contact", "juliet@capulet.lit/balcony", "juliet@capulet.lit/balcony", true},
+		{"to contact, answered without from", "juliet@capulet.lit/balcony", "", false},
+		{"to contact, answered by server", "juliet@capulet.lit/balcony", "montague.lit", false},
+		{"to contact, answered from bare JID", "juliet@capulet.lit/balcony", "Juliet@capulet.lit", true},
+		{"to contact, answered by another resource", "juliet@capulet.lit/balcony", "juliet@capulet.lit/chamber", false},
+		{"to contact bare JID, answered from full JID", "juliet@capulet.lit", "juliet@capulet.lit/balcony", false},
+		{"to component resource, answered from domain", "irc.capulet.lit/bot", "irc.capulet.lit", true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			router := NewRouter()
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			iq, _ := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, To: tc.to})
+			res := router.NewIQResultRouteFrom(ctx, iq.Id, client.iqResultSenders(iq)...)
+
+			answer, _ := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeResult, Id: iq.Id, From: tc.from})
+			go router.route(NewSenderMock(), answer)
+			select {
+			case <-res:
+				if !tc.accepted {
+					t.Errorf("result should not be accepted")
+				}
+			case <-ctx.Done():
+				if tc.accepted {
+					t.Errorf("result should be accepted")
+				}
+			}
+		})
+	}
+}
@@ -4,11 +4,11 @@ import (
 	"context"
 	"crypto/sha1"
 	"encoding/hex"
-	"encoding/xml"
 	"errors"
 	"fmt"
 	"gosrc.io/xmpp/stanza"
 	"io"
+	"sync"
 )
 
 type ComponentOptions struct {
@@ -36,6 +36,23 @@ type ComponentOptions struct {
 
 	// Track and broadcast connection state
 	EventManager
+
+	// =================================
+	// Stream management (XEP-0198)
+
+	// StreamManagementEnable enables stream management when the server advertises it after the handshake.
+	// The session is resumed on reconnection, and the stanzas the server did not receive are sent again.
+	StreamManagementEnable bool
+	// InboundDuplicateWindow and InboundDuplicatePolicy configure the detection of the duplicate
+	// stanzas sent again by the server after a resumption, as for clients. See Config.
+	InboundDuplicateWindow int
+	InboundDuplicatePolicy DuplicatePolicy
+
+	// IQTracer, if set, is notified of the IQ requests sent with SendIQ and of their responses.
+	IQTracer IQTracer
+	// InvalidCharPolicy tells if characters not allowed in XML are replaced (default) in sent packets,
+	// or if such packets are rejected by Send, with an error wrapping stanza.ErrInvalidXMLChar.
+	InvalidCharPolicy stanza.InvalidCharPolicy
 }
 
 // Component implements an XMPP extension allowing to extend XMPP server
@@ -50,10 +67,19 @@ type Component struct {
 	// read / write
 	socketProxy  io.ReadWriter // TODO
 	ErrorHandler func(error)
+
+	// Stream management state, kept across connections to resume the session
+	smMu sync.Mutex
+	sm   SMState
+	// Recently received message ids, kept across resumptions to detect duplicates
+	recentIds *recentIds
 }
 
 func NewComponent(opts ComponentOptions, r *Router, errorHandler func(error)) (*Component, error) {
 	c := Component{ComponentOptions: opts, router: r, ErrorHandler: errorHandler}
+	if opts.StreamManagementEnable && opts.InboundDuplicateWindow > 0 {
+		c.recentIds = newRecentIds(opts.InboundDuplicateWindow)
+	}
 	return &c, nil
 }
 
@@ -99,7 +125,13 @@ func (c *Component) Resume() error {
 		c.streamError("conflict", "no auth loop")
 		return NewConnError(errors.New("handshake failed "+v.Error.Local), true)
 	case stanza.Handshake:
+		if err = c.resumeStreamManagement(); err != nil {
+			c.transport.Close()
+			c.updateState(StateStreamError)
+			return NewConnError(err, false)
+		}
 		// Start the receiver go routine
+		startReadLimit(c.transport)
 		c.updateState(StateSessionEstablished)
 		go c.recv()
 		return err // Should be empty at this point
@@ -125,9 +157,29 @@ func (c *Component) SetHandler(handler EventHandler) {
 // Receiver Go routine receiver
 func (c *Component) recv() {
 	for {
-		val, err := stanza.NextPacket(c.transport.GetDecoder())
+		dec := c.transport.GetDecoder()
+		start := dec.InputOffset()
+		val, err := stanza.NextPacket(dec)
 		if err != nil {
-			c.updateState(StateDisconnected)
+			var interrupted *stanza.InterruptedStanzaError
+			if errors.As(err, &interrupted) {
+				// The stream is closed in the middle of a stanza: the stream close tag of the server
+				// cannot be decoded anymore, so there is no need to wait for it
+				c.router.route(c, interrupted.StreamError)
+				c.streamError(interrupted.StreamError.Error.Local, interrupted.StreamError.Text)
+				go c.transport.ReceivedStreamClose()
+				c.Disconnect()
+			} else {
+				if errors.Is(err, stanza.ErrStanzaTooComplex) {
+					c.streamError("policy-violation", err.Error())
+					policyViolation(c.transport, err)
+				} else if errors.Is(err, stanza.ErrUnexpectedCharData) {
+					c.streamError("bad-format", err.Error())
+					badFormat(c.transport, err)
+				}
+				err = decodeError(c.transport, dec, err, start)
+			}
+			c.disconnected(c.smState(), err)
 			c.ErrorHandler(err)
 			return
 		}
@@ -144,7 +196,14 @@ func (c *Component) recv() {
 			c.transport.ReceivedStreamClose()
 			return
 		}
-		c.router.route(c, val)
+		if c.handleStreamManagement(val) {
+			continue
+		}
+		sender, ok := c.recentIds.sender(c, val, c.InboundDuplicatePolicy)
+		if !ok {
+			continue
+		}
+		c.router.route(sender, val)
 	}
 }
 
@@ -154,10 +213,17 @@ func (c *Component) Send(packet stanza.Packet) error {
 	if transport == nil {
 		return errors.New("component is not connected")
 	}
+	if c.StreamManagementEnable {
+		packet = withOriginId(packet)
+	}
 
-	data, err := xml.Marshal(packet)
+	data, err := stanza.MarshalPacket(packet, c.InvalidCharPolicy)
 	if err != nil {
-		return errors.New("cannot marshal packet " + err.Error())
+		return fmt.Errorf("cannot marshal packet %w", err)
+	}
+	// Store stanza as non-acked as part of stream management
+	if _, ok := packet.(stanza.SMRequest); !ok {
+		c.queueUnacked(string(data))
 	}
 
 	if err := c.sendWithWriter(transport, data); err != nil {
@@ -185,10 +251,7 @@ func (c *Component) SendIQ(ctx context.Context, iq *stanza.IQ) (chan stanza.IQ,
 	if iq.Attrs.Type != stanza.IQTypeSet && iq.Attrs.Type != stanza.IQTypeGet {
 		return nil, ErrCanOnlySendGetOrSetIq
 	}
-	if err := c.Send(iq); err != nil {
-		return nil, err
-	}
-	return c.router.NewIQResultRoute(ctx, iq.Attrs.Id), nil
+	return sendIQ(ctx, c.IQTracer, iq, c.Send, c.router, nil)
 }
 
 // SendRaw sends an XMPP stanza as a string to the server.
@@ -201,6 +264,7 @@ func (c *Component) SendRaw(packet string) error {
 		return errors.New("component is not connected")
 	}
 
+	c.queueUnacked(packet)
 	var err error
 	err = c.sendWithWriter(transport, []byte(packet))
 	return err
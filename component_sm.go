@@ -0,0 +1,153 @@
+package xmpp
+
+import (
+	"encoding/xml"
+	"errors"
+	"strconv"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Stream Management (XEP-0198) for components
+
+// Components negotiate stream management after the handshake, when the server advertises it in
+// the stream features it sends then, as ejabberd does. Components connected to servers which do
+// not advertise it work as usual, without stream management.
+
+// ExportSMState returns the state of the stream management session, to resume it from a new process
+// with ImportSMState. As for clients, the process must exit without calling Disconnect.
+func (c *Component) ExportSMState() (SMResumptionState, error) {
+	sm := c.smState()
+	if sm.Id == "" {
+		return SMResumptionState{}, ErrNoResumableSession
+	}
+	return newSMResumptionState(sm, ""), nil
+}
+
+// ImportSMState sets the stream management state exported by a previous process, to be resumed by
+// the next call to Connect. When the server cannot resume the session anymore, a new session is
+// enabled, and the unacknowledged stanzas of the previous session are dropped.
+func (c *Component) ImportSMState(state SMResumptionState) {
+	c.smMu.Lock()
+	defer c.smMu.Unlock()
+	c.sm = state.smState()
+}
+
+// smState returns a copy of the stream management state.
+func (c *Component) smState() SMState {
+	c.smMu.Lock()
+	defer c.smMu.Unlock()
+	return c.sm
+}
+
+// resumeStreamManagement resumes the stream management session of the previous connection, if any,
+// and sends again the stanzas the server did not receive. It is called right after the handshake,
+// before the receiver is started. When the session cannot be resumed, a new one is enabled.
+func (c *Component) resumeStreamManagement() error {
+	c.smMu.Lock()
+	defer c.smMu.Unlock()
+	if !c.StreamManagementEnable || c.sm.Id == "" {
+		// Stream management will be enabled if advertised by the server
+		c.sm = SMState{}
+		return nil
+	}
+
+	data, err := xml.Marshal(stanza.SMResume{PrevId: c.sm.Id, H: &c.sm.Inbound})
+	if err != nil {
+		return err
+	}
+	if _, err = c.transport.Write(data); err != nil {
+		return err
+	}
+	for {
+		packet, err := stanza.NextPacket(c.transport.GetDecoder())
+		if err != nil {
+			return err
+		}
+		switch p := packet.(type) {
+		case stanza.StreamFeatures:
+			// The server supports stream management, as the previous session shows
+			continue
+		case stanza.SMResumed:
+			if p.PrevId != c.sm.Id {
+				c.sm = SMState{}
+				return errors.New("session resumption: mismatched id")
+			}
+			c.recentIds.resumed()
+			return resendUnacked(c.transport, c.sm.UnAckQueue, p.H)
+		case stanza.SMFailed:
+			return c.enableStreamManagement()
+		default:
+			return errors.New("unexpected reply to SM resume")
+		}
+	}
+}
+
+// enableStreamManagement requests a new stream management session, with resumption. Stanzas sent
+// from now on are queued until acknowledged. The answer of the server is processed by the receiver.
+// It must be called with smMu locked.
+func (c *Component) enableStreamManagement() error {
+	resume := true
+	data, err := xml.Marshal(stanza.SMEnable{Resume: &resume})
+	if err != nil {
+		return err
+	}
+	c.sm = SMState{UnAckQueue: stanza.NewUnAckQueue()}
+	_, err = c.transport.Write(data)
+	return err
+}
+
+// handleStreamManagement processes the stream management packets received, and counts the received
+// stanzas. It returns true if the packet was a stream management packet handled by the component.
+func (c *Component) handleStreamManagement(p stanza.Packet) bool {
+	c.smMu.Lock()
+	defer c.smMu.Unlock()
+	switch packet := p.(type) {
+	case stanza.StreamFeatures:
+		if c.StreamManagementEnable && c.sm.UnAckQueue == nil && packet.DoesStreamManagement() {
+			if err := c.enableStreamManagement(); err != nil {
+				c.ErrorHandler(err)
+			}
+		}
+		return true
+	case stanza.SMEnabled:
+		// The session can only be resumed if the server allows it
+		if resume, err := strconv.ParseBool(packet.Resume); err == nil && resume {
+			c.sm.Id = packet.Id
+		}
+		return true
+	case stanza.SMFailed:
+		// The server refused to enable stream management: go on without it
+		c.sm = SMState{}
+		return true
+	case stanza.SMRequest:
+		if c.sm.UnAckQueue == nil {
+			return true
+		}
+		answer := stanza.SMAnswer{XMLName: xml.Name{Space: stanza.NSStreamManagement, Local: "a"}, H: c.sm.Inbound}
+		data, err := xml.Marshal(answer)
+		if err == nil {
+			_, err = c.transport.Write(data)
+		}
+		if err != nil {
+			c.ErrorHandler(err)
+		}
+		return true
+	case stanza.Message, stanza.Presence, *stanza.IQ:
+		if c.sm.UnAckQueue != nil {
+			c.sm.Inbound++
+		}
+	}
+	return false
+}
+
+// queueUnacked keeps a sent stanza until the server acknowledges it, when stream management is
+// enabled.
+func (c *Component) queueUnacked(data string) {
+	c.smMu.Lock()
+	defer c.smMu.Unlock()
+	if c.sm.UnAckQueue != nil {
+		_ = c.sm.UnAckQueue.Push(&stanza.UnAckedStz{Stz: data})
+	}
+}
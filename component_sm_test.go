@@ -0,0 +1,128 @@
+package xmpp
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+const componentSMFeatures = `<stream:features><sm xmlns='urn:xmpp:sm:3'/></stream:features>`
+
+func newSMComponent(t *testing.T, port int) *Component {
+	c := makeBasicComponent(defaultComponentName, fmt.Sprintf("%s:%d", testComponentDomain, port), t)
+	c.StreamManagementEnable = true
+	return c
+}
+
+func TestComponentStreamManagement(t *testing.T) {
+	serverDone := make(chan struct{})
+	mock := ServerMock{}
+	mock.Start(t, fmt.Sprintf("%s:%d", testComponentDomain, testComponentSMPort), func(t *testing.T, sc *ServerConn) {
+		handlerForComponentHandshakeDefaultID(t, sc)
+		sc.connection.Write([]byte(componentSMFeatures))
+		enableStreamManagement(t, sc, false, true)
+
+		var msg stanza.Message
+		if err := sc.decoder.Decode(&msg); err != nil || msg.Id != "m1" {
+			t.Errorf("expected message from the component: %+v, %v", msg, err)
+		}
+		sc.connection.Write([]byte("<message from='juliet@capulet.lit' to='localhost' id='in1'/><r xmlns='urn:xmpp:sm:3'/>"))
+		var answer stanza.SMAnswer
+		if err := sc.decoder.Decode(&answer); err != nil || answer.H != 1 {
+			t.Errorf("expected ack of the received message: %+v, %v", answer, err)
+		}
+		serverDone <- struct{}{}
+	})
+	defer mock.Stop()
+
+	c := newSMComponent(t, testComponentSMPort)
+	if err := c.Connect(); err != nil {
+		t.Fatalf("could not connect component: %v", err)
+	}
+	// Wait for the session to be enabled
+	deadline := time.Now().Add(defaultTimeout)
+	for c.smState().Id == "" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := c.Send(stanza.Message{Attrs: stanza.Attrs{Id: "m1", To: "juliet@capulet.lit"}}); err != nil {
+		t.Fatalf("could not send message: %v", err)
+	}
+	waitForEntity(t, serverDone)
+
+	state, err := c.ExportSMState()
+	if err != nil {
+		t.Fatalf("could not export state: %v", err)
+	}
+	if state.ID != streamManagementID || state.Inbound != 1 || len(state.Unacked) != 1 || state.Unacked[0].Seq != 1 {
+		t.Errorf("unexpected state: %+v", state)
+	}
+}
+
+func TestComponentStreamManagement_Resume(t *testing.T) {
+	serverDone := make(chan struct{})
+	mock := ServerMock{}
+	mock.Start(t, fmt.Sprintf("%s:%d", testComponentDomain, testComponentSMResumePort), func(t *testing.T, sc *ServerConn) {
+		handlerForComponentHandshakeDefaultID(t, sc)
+		sc.connection.Write([]byte(componentSMFeatures))
+
+		var resume stanza.SMResume
+		if err := sc.decoder.Decode(&resume); err != nil {
+			t.Errorf("cannot decode resume: %v", err)
+			return
+		}
+		if resume.PrevId != streamManagementID || resume.H == nil || *resume.H != 4 {
+			t.Errorf("unexpected resume request: %+v", resume)
+		}
+		// Only the first stanza was received by the server
+		sc.connection.Write([]byte(fmt.Sprintf("<resumed xmlns='urn:xmpp:sm:3' previd='%s' h='1'/>", streamManagementID)))
+
+		var msg stanza.Message
+		if err := sc.decoder.Decode(&msg); err != nil || msg.Id != "m2" {
+			t.Errorf("unacked message should be sent again: %+v, %v", msg, err)
+		}
+		serverDone <- struct{}{}
+	})
+	defer mock.Stop()
+
+	c := newSMComponent(t, testComponentSMResumePort)
+	c.ImportSMState(SMResumptionState{ID: streamManagementID, Inbound: 4, Unacked: []SMUnackedStanza{
+		{Seq: 1, XML: `<message id="m1" to="juliet@capulet.lit"/>`},
+		{Seq: 2, XML: `<message id="m2" to="juliet@capulet.lit"/>`},
+	}})
+	if err := c.Connect(); err != nil {
+		t.Fatalf("could not resume component session: %v", err)
+	}
+	waitForEntity(t, serverDone)
+	if state := c.smState(); state.Id != streamManagementID || state.Inbound != 4 {
+		t.Errorf("resumed session should keep its state: %+v", state)
+	}
+}
+
+func TestComponentStreamManagement_NotAdvertised(t *testing.T) {
+	serverDone := make(chan struct{})
+	mock := ServerMock{}
+	mock.Start(t, fmt.Sprintf("%s:%d", testComponentDomain, testComponentNoSMPort), func(t *testing.T, sc *ServerConn) {
+		handlerForComponentHandshakeDefaultID(t, sc)
+		// The first packet after the handshake is the message: no enable request was sent
+		var msg stanza.Message
+		if err := sc.decoder.Decode(&msg); err != nil || msg.Id != "m1" {
+			t.Errorf("expected message from the component: %+v, %v", msg, err)
+		}
+		serverDone <- struct{}{}
+	})
+	defer mock.Stop()
+
+	c := newSMComponent(t, testComponentNoSMPort)
+	if err := c.Connect(); err != nil {
+		t.Fatalf("could not connect component: %v", err)
+	}
+	if err := c.Send(stanza.Message{Attrs: stanza.Attrs{Id: "m1", To: "juliet@capulet.lit"}}); err != nil {
+		t.Fatalf("could not send message: %v", err)
+	}
+	waitForEntity(t, serverDone)
+	if _, err := c.ExportSMState(); err != ErrNoResumableSession {
+		t.Errorf("stream management should not be enabled: %v", err)
+	}
+}
@@ -16,16 +16,124 @@ type Config struct {
 	Credential        Credential
 	StreamLogger      *os.File      // Used for debugging
 	Lang              string        // TODO: should default to 'en'
-	KeepaliveInterval time.Duration // Interval between keepalive packets
+	KeepaliveInterval time.Duration // Interval between keepalive packets. Default to 30 seconds, or less if the server advertises a shorter idle timeout.
+	defaultKeepalive  bool          // KeepaliveInterval was not configured
 	ConnectTimeout    int           // Client timeout in seconds. Default to 15
+	// Endpoints are the addresses of interchangeable servers, in order of preference. When set, they
+	// replace Address: the client connects to the first one completing the authentication, and fails
+	// over to the next one when reconnections fail repeatedly. See WithEndpoints.
+	Endpoints []string
+	// EndpointFailureThreshold is the number of consecutive failed reconnections to an endpoint before
+	// failing over to the next one. Default to 3.
+	EndpointFailureThreshold int
 	// Insecure can be set to true to allow to open a session without TLS. If TLS
 	// is supported on the server, we will still try to use it.
 	Insecure bool
+	// WhitespacePing makes keepalive send a single space character on the connection, as allowed
+	// by RFC 6120 - 4.6.1, instead of the transport specific ping. It is ignored by transports
+	// that do not support whitespace keepalive.
+	WhitespacePing bool
+	// WhitespacePongHandler, if set, is used to track the connection health when WhitespacePing is enabled.
+	WhitespacePongHandler *WhitespacePongHandler
 
 	// Activate stream management process during session
 	StreamManagementEnable bool
 	// Enable stream management resume capability
 	streamManagementResume bool
+	// InboundDuplicateWindow is the number of received message ids remembered to detect duplicates,
+	// re-sent by the server after a stream resumption. It is only used with stream management.
+	// Duplicate detection is disabled when zero. Messages are identified by their origin-id, which
+	// the client adds to the messages it sends with stream management. Only the first
+	// InboundDuplicateWindow messages received after a resumption are checked.
+	InboundDuplicateWindow int
+	// InboundDuplicatePolicy tells if duplicates are dropped (default), or flagged to the handlers.
+	InboundDuplicatePolicy DuplicatePolicy
+
+	// IQTracer, if set, is notified of the IQ requests sent with SendIQ and of their responses.
+	IQTracer IQTracer
+	// IQScheduler, if set, limits the number of IQ requests sent with SendIQ that are waiting for a response.
+	IQScheduler *IQScheduler
+	// DeliveryTracker, if set, measures the delivery latency of the messages requesting a delivery
+	// receipt or a chat marker.
+	DeliveryTracker *DeliveryTracker
+	// LatencyProbeInterval is the delay between the pings sent by ProbeLatency. Default to 1 second.
+	LatencyProbeInterval time.Duration
+	// LatencyProbeTimeout is the time ProbeLatency waits for each ping reply, before counting it as lost.
+	// Default to 5 seconds.
+	LatencyProbeTimeout time.Duration
+	// PingResponseDeadline is the time allowed to answer the pings sent by the server, which are
+	// answered automatically. The connection is closed when a ping cannot be answered in time.
+	// Default to 10 seconds.
+	PingResponseDeadline time.Duration
+
+	// InvalidCharPolicy tells if characters not allowed in XML are replaced (default) in sent packets,
+	// or if such packets are rejected by Send, with an error wrapping stanza.ErrInvalidXMLChar.
+	InvalidCharPolicy stanza.InvalidCharPolicy
+
+	// MechanismStore, if set, remembers the strongest SASL mechanism negotiated with the server.
+	// A warning event is sent to the event handler when a weaker mechanism is negotiated.
+	MechanismStore MechanismStore
+	// SASLMechanisms, if set, replaces the mechanisms of the credential, in order of preference.
+	// See WithSASLMechanisms.
+	SASLMechanisms []string
+
+	// SubscriptionRequestHandler, if set, is called when a PubSub service asks the client to approve
+	// a subscription to a node it owns. See OnSubscriptionRequest.
+	SubscriptionRequestHandler SubscriptionRequestHandler
+
+	// HTTPUpload configures the HTTP client used to upload files. See WithHTTPUploadOptions.
+	HTTPUpload HTTPUploadOptions
+
+	// CapsCache, if set, stores the capabilities advertised by the contacts, instead of the default
+	// in-memory cache. See WithCapsCache.
+	CapsCache CapsCache
+
+	// ResourceManager, if set, tracks the resources of the contacts to pick the one to send messages
+	// to. See WithResourceManager.
+	ResourceManager *ResourceManager
+
+	// DecloakPolicy, if set, decides which decloaking requests are passed to the
+	// DecloakRequestHandler. See WithDecloakPolicy.
+	DecloakPolicy *DecloakPolicy
+	// DecloakRequestHandler, if set, decides whether to accept the decloaking requests. See
+	// OnDecloakRequest.
+	DecloakRequestHandler DecloakRequestHandler
+
+	// PushEncryption, if set, is the key sent to the app server when enabling push notifications,
+	// to encrypt the summaries it pushes. See WithEncryptedPush.
+	PushEncryption *PushEncryption
+
+	// RosterManager, if set, keeps a copy of the roster of the account. See WithRosterManager.
+	RosterManager *RosterManager
+
+	// AutoReceipts answers the delivery receipt requests (XEP-0184) of the messages received.
+	AutoReceipts bool
+	// AutoReceivedMarkers answers the markable messages (XEP-0333) with a received marker.
+	AutoReceivedMarkers bool
+	// AutoReplyPolicy, if set, decides to which messages the automatic answers are sent. All the
+	// messages are answered by default. See WithAutoReplyPolicy.
+	AutoReplyPolicy AutoReplyPolicy
+	// ReceiptRequestPolicy decides which messages built with WithReceiptRequestIfSupported request a
+	// delivery receipt. See WithReceiptRequestPolicy.
+	ReceiptRequestPolicy ReceiptRequestPolicy
+
+	// ClientTag identifies the client software when binding the resource with Bind 2 (XEP-0386).
+	// See WithClientTag.
+	ClientTag string
+	// Carbons enables Message Carbons (XEP-0280) when the session is established. See WithCarbons.
+	Carbons bool
+	// DisableSASL2 forces the classic authentication and resource binding. See WithoutSASL2.
+	DisableSASL2 bool
+}
+
+// Option configures an XMPP client. Options are applied with Config.Apply, or passed to NewClient.
+type Option func(config *Config)
+
+// Apply applies the options to the configuration, in order.
+func (c *Config) Apply(opts ...Option) {
+	for _, opt := range opts {
+		opt(c)
+	}
 }
 
 // IsStreamResumable tells if a stream session is resumable by reading the "config" part of a client.
@@ -0,0 +1,58 @@
+package xmpp
+
+import (
+	"context"
+	"sync"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Client State Indication (XEP-0352)
+
+// SetInactive tells the server that the client is inactive, for example when the application is in
+// background. The server may then delay or suppress the stanzas which are not urgent, until
+// FlushSuppressedStanzas is called.
+func (c *Client) SetInactive() error {
+	return c.Send(stanza.CSIInactive{})
+}
+
+// FlushSuppressedStanzas tells the server that the client is active again, and sends an available
+// presence to trigger the delivery of the stanzas buffered by the server.
+func (c *Client) FlushSuppressedStanzas(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := c.Send(stanza.CSIActive{}); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Send(stanza.NewPresence(stanza.Attrs{}))
+}
+
+// StanzasSuppressedDuringInactive returns the number of stanzas the server reported as suppressed
+// during the last inactive period. It is only known when the server adds the suppressed count to its
+// answer to FlushSuppressedStanzas, which is not part of XEP-0352.
+func (c *Client) StanzasSuppressedDuringInactive() int64 {
+	return c.csi.getSuppressed()
+}
+
+// csiState records the suppressed stanzas count reported by the server.
+type csiState struct {
+	mu         sync.Mutex
+	suppressed int64
+}
+
+func (s *csiState) setSuppressed(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.suppressed = n
+}
+
+func (s *csiState) getSuppressed() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.suppressed
+}
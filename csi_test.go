@@ -0,0 +1,57 @@
+package xmpp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestClientCSISuppressedCount(t *testing.T) {
+	done := make(chan struct{})
+	h := func(t *testing.T, sc *ServerConn) {
+		handlerClientConnectSuccess(t, sc)
+		discardPresence(t, sc)
+
+		packet, err := stanza.NextPacket(sc.decoder)
+		if err != nil {
+			t.Errorf("cannot read CSI active: %s", err)
+			return
+		}
+		if _, ok := packet.(stanza.CSIActive); !ok {
+			t.Errorf("expected CSI active, got %#v", packet)
+			return
+		}
+		packet, err = stanza.NextPacket(sc.decoder)
+		if err != nil {
+			t.Errorf("cannot read presence: %s", err)
+			return
+		}
+		if _, ok := packet.(stanza.Presence); !ok {
+			t.Errorf("expected presence, got %#v", packet)
+			return
+		}
+		sc.connection.Write([]byte("<active xmlns='urn:xmpp:csi:0' suppressed='3'/>"))
+		done <- struct{}{}
+	}
+	client, mock := mockClientConnection(t, h, testClientCSIPort)
+	defer mock.Stop()
+
+	if err := client.FlushSuppressedStanzas(context.Background()); err != nil {
+		t.Fatalf("cannot flush suppressed stanzas: %s", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(defaultChannelTimeout):
+		t.Fatal("The mock server failed to finish its job !")
+	}
+
+	deadline := time.Now().Add(defaultChannelTimeout)
+	for client.StanzasSuppressedDuringInactive() != 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected suppressed count: %d", client.StanzasSuppressedDuringInactive())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,192 @@
+package xmpp
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Presence Decloaking (XEP-0276)
+
+// DecloakRequest is a request to send our presence to an entity without presence subscription,
+// which would disclose our full JID.
+type DecloakRequest struct {
+	From   string
+	Reason string
+	Id     string
+}
+
+// DecloakRequestHandler is called with the decloaking requests allowed by the policy, and returns
+// whether to send our presence to the requester.
+type DecloakRequestHandler func(req DecloakRequest) bool
+
+// DecloakPolicy decides which decloaking requests are passed to the DecloakRequestHandler. The
+// other requests are denied by the client. JIDs can be added to the whitelist at runtime with
+// AllowDecloak.
+type DecloakPolicy struct {
+	mode decloakMode
+	// JIDs initially whitelisted
+	whitelist []string
+}
+
+type decloakMode uint8
+
+const (
+	decloakDenyAll decloakMode = iota
+	decloakAllowSubscribed
+	decloakAllowWhitelist
+)
+
+var (
+	// DenyAll denies all the decloaking requests, including the ones from whitelisted JIDs.
+	DenyAll = DecloakPolicy{mode: decloakDenyAll}
+	// AllowSubscribed allows the requests from the contacts subscribed to our presence in the
+	// roster, and from the whitelisted JIDs. The roster is the one last retrieved by the client.
+	AllowSubscribed = DecloakPolicy{mode: decloakAllowSubscribed}
+)
+
+// AllowWhitelist allows the requests from the JIDs, and from the JIDs added with AllowDecloak.
+// A bare JID allows all its resources.
+func AllowWhitelist(jids []string) DecloakPolicy {
+	return DecloakPolicy{mode: decloakAllowWhitelist, whitelist: append([]string{}, jids...)}
+}
+
+// WithDecloakPolicy makes the client answer the decloaking requests according to the policy. The
+// requests it allows are passed to the handler set with OnDecloakRequest, or accepted without
+// handler.
+func WithDecloakPolicy(policy DecloakPolicy) Option {
+	return func(config *Config) {
+		config.DecloakPolicy = &policy
+	}
+}
+
+// OnDecloakRequest sets the handler deciding whether to accept the decloaking requests. Without
+// decloak policy, all the requests are passed to the handler.
+func OnDecloakRequest(handler DecloakRequestHandler) Option {
+	return func(config *Config) {
+		config.DecloakRequestHandler = handler
+	}
+}
+
+// AllowDecloak adds the JIDs to the decloaking whitelist.
+func (c *Client) AllowDecloak(jids ...string) {
+	c.decloak.allow(jids)
+}
+
+// DisallowDecloak removes the JIDs from the decloaking whitelist.
+func (c *Client) DisallowDecloak(jids ...string) {
+	c.decloak.disallow(jids)
+}
+
+// AllowedDecloakJIDs returns the JIDs of the decloaking whitelist, sorted.
+func (c *Client) AllowedDecloakJIDs() []string {
+	return c.decloak.allowed()
+}
+
+// handleDecloakRequest answers the decloaking requests. It returns true if the packet was a request
+// handled by the client.
+func (c *Client) handleDecloakRequest(p stanza.Packet) bool {
+	return c.decloak.handle(c, c.config.DecloakPolicy, c.config.DecloakRequestHandler, c.rosterCache(), p)
+}
+
+// decloakFilter applies the decloak policy, with the JIDs whitelisted at runtime.
+type decloakFilter struct {
+	mu        sync.RWMutex
+	whitelist map[string]bool
+}
+
+func (f *decloakFilter) allow(jids []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.whitelist == nil {
+		f.whitelist = make(map[string]bool, len(jids))
+	}
+	for _, jid := range jids {
+		f.whitelist[strings.ToLower(jid)] = true
+	}
+}
+
+func (f *decloakFilter) disallow(jids []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, jid := range jids {
+		delete(f.whitelist, strings.ToLower(jid))
+	}
+}
+
+func (f *decloakFilter) allowed() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	jids := make([]string, 0, len(f.whitelist))
+	for jid := range f.whitelist {
+		jids = append(jids, jid)
+	}
+	sort.Strings(jids)
+	return jids
+}
+
+// whitelisted tells if the full JID, or its bare JID, is in the whitelist.
+func (f *decloakFilter) whitelisted(jid string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.whitelist[strings.ToLower(jid)] || f.whitelist[strings.ToLower(bareJid(jid))]
+}
+
+// permits tells if the policy allows the request.
+func (f *decloakFilter) permits(policy DecloakPolicy, roster *rosterCache, from string) bool {
+	switch policy.mode {
+	case decloakAllowSubscribed:
+		if f.whitelisted(from) {
+			return true
+		}
+		sub, ok := roster.subscription(from)
+		return ok && (sub == stanza.SubscriptionFrom || sub == stanza.SubscriptionBoth)
+	case decloakAllowWhitelist:
+		return f.whitelisted(from)
+	}
+	return false
+}
+
+// handle answers the decloaking request carried by the packet. Denied requests are answered with a
+// forbidden error, without calling the handler.
+func (f *decloakFilter) handle(s Sender, policy *DecloakPolicy, handler DecloakRequestHandler, roster *rosterCache, p stanza.Packet) bool {
+	if policy == nil && handler == nil {
+		return false
+	}
+	pres, ok := p.(stanza.Presence)
+	if !ok || pres.Type != "" {
+		return false
+	}
+	var decloak stanza.Decloak
+	if !pres.Get(&decloak) {
+		return false
+	}
+
+	req := DecloakRequest{From: pres.From, Reason: decloak.Reason, Id: decloak.Id}
+	if policy != nil && !f.permits(*policy, roster, req.From) {
+		_ = s.Send(decloakAnswer(req, false))
+		return true
+	}
+	if handler == nil {
+		_ = s.Send(decloakAnswer(req, true))
+		return true
+	}
+	go func() {
+		_ = s.Send(decloakAnswer(req, handler(req)))
+	}()
+	return true
+}
+
+// decloakAnswer returns the directed presence accepting the request, or the error denying it.
+func decloakAnswer(req DecloakRequest, accept bool) stanza.Presence {
+	if accept {
+		return stanza.NewPresence(stanza.Attrs{To: req.From})
+	}
+	pres := stanza.NewPresence(stanza.Attrs{To: req.From, Type: stanza.PresenceTypeError})
+	pres.Error = stanza.Err{Type: stanza.ErrorTypeCancel, Reason: "forbidden"}
+	pres.Extensions = append(pres.Extensions, stanza.Decloak{Reason: req.Reason, Id: req.Id})
+	return pres
+}
@@ -0,0 +1,92 @@
+package xmpp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+const decloakRequest = `<presence from='romeo@montague.lit/orchard' to='juliet@capulet.lit/balcony'>
+  <decloak xmlns='urn:xmpp:decloak:0' reason='media'/>
+</presence>`
+
+func TestDecloakDenyAll(t *testing.T) {
+	conn := NewSenderMock()
+	var f decloakFilter
+	f.allow([]string{"romeo@montague.lit"})
+	var roster rosterCache
+	called := false
+	handler := func(DecloakRequest) bool { called = true; return true }
+
+	if !f.handle(conn, &DenyAll, handler, &roster, parsePresence(t, decloakRequest)) {
+		t.Fatal("decloak request was not handled")
+	}
+	if called {
+		t.Error("handler should not be called with DenyAll")
+	}
+	sent := conn.String()
+	if !strings.Contains(sent, `type="error"`) || !strings.Contains(sent, "<forbidden") {
+		t.Errorf("request was not denied: %s", sent)
+	}
+}
+
+func TestDecloakAllowSubscribed(t *testing.T) {
+	var roster rosterCache
+	push, _ := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeResult, Id: "roster1"})
+	push.RosterItems().AddItem("romeo@montague.lit", stanza.SubscriptionTo, "", "Romeo", nil)
+	roster.update("juliet@capulet.lit", push)
+
+	var f decloakFilter
+	requests := make(chan DecloakRequest, 1)
+	handler := func(req DecloakRequest) bool { requests <- req; return true }
+
+	// Romeo is not subscribed to our presence
+	conn := NewSenderMock()
+	f.handle(conn, &AllowSubscribed, handler, &roster, parsePresence(t, decloakRequest))
+	if len(requests) != 0 || !strings.Contains(conn.String(), `type="error"`) {
+		t.Fatalf("request from unsubscribed contact was not denied: %s", conn.String())
+	}
+
+	set, _ := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeSet, Id: "push1"})
+	set.RosterItems().AddItem("romeo@montague.lit", stanza.SubscriptionBoth, "", "Romeo", nil)
+	roster.update("juliet@capulet.lit", set)
+
+	f.handle(NewSenderMock(), &AllowSubscribed, handler, &roster, parsePresence(t, decloakRequest))
+	select {
+	case req := <-requests:
+		if req.From != "romeo@montague.lit/orchard" || req.Reason != "media" {
+			t.Errorf("unexpected request: %#v", req)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler was not called for a subscribed contact")
+	}
+}
+
+func TestDecloakWhitelist(t *testing.T) {
+	var f decloakFilter
+	f.allow(AllowWhitelist([]string{"Romeo@montague.lit"}).whitelist)
+	f.allow([]string{"nurse@capulet.lit/kitchen"})
+	if got := f.allowed(); !reflect.DeepEqual(got, []string{"nurse@capulet.lit/kitchen", "romeo@montague.lit"}) {
+		t.Errorf("unexpected whitelist: %v", got)
+	}
+
+	var roster rosterCache
+	policy := AllowWhitelist(nil)
+	if !f.permits(policy, &roster, "romeo@montague.lit/orchard") || f.permits(policy, &roster, "nurse@capulet.lit/garden") {
+		t.Error("whitelist was not applied")
+	}
+	f.disallow([]string{"romeo@montague.lit"})
+	if f.permits(policy, &roster, "romeo@montague.lit/orchard") {
+		t.Error("removed JID should not be allowed")
+	}
+
+	// Without handler, allowed requests are accepted
+	conn := NewSenderMock()
+	f.handle(conn, &policy, nil, &roster, parsePresence(t, strings.Replace(decloakRequest, "romeo@montague.lit/orchard", "nurse@capulet.lit/kitchen", 1)))
+	if conn.String() != `<presence to="nurse@capulet.lit/kitchen"></presence>` {
+		t.Errorf("request was not accepted: %s", conn.String())
+	}
+}
@@ -0,0 +1,141 @@
+package xmpp
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"sync"
+)
+
+// ============================================================================
+// Decoding errors context
+
+// defaultDecodeErrorBufferSize is the number of received bytes kept by default to describe decoding errors.
+const defaultDecodeErrorBufferSize = 4096
+
+// DecodeError is returned when a packet received on the stream cannot be decoded.
+// It holds the last bytes received, to identify the offending stanza.
+type DecodeError struct {
+	Err error
+	// Offset is the stream position where decoding failed, and StanzaOffset the position
+	// where decoding of the failing packet started.
+	Offset       int64
+	StanzaOffset int64
+	// Raw holds the last bytes received, starting at stream position RawOffset. It may contain bytes
+	// received after the decoding error. The content of authentication elements is masked.
+	Raw       []byte
+	RawOffset int64
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("cannot decode packet at offset %d: %v", e.StanzaOffset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Stanza returns the received bytes from the start of the failing packet, or all the kept bytes
+// if the packet started before them.
+func (e *DecodeError) Stanza() []byte {
+	start := e.StanzaOffset - e.RawOffset
+	if start < 0 || start > int64(len(e.Raw)) {
+		return e.Raw
+	}
+	return e.Raw[start:]
+}
+
+// inboundTransport is implemented by transports keeping the last bytes received.
+type inboundTransport interface {
+	inbound() *inboundRecorder
+}
+
+// decodeError adds the last received bytes to an error returned by the decoder.
+// Network and end of stream errors are returned unchanged.
+func decodeError(t Transport, dec *xml.Decoder, err error, stanzaOffset int64) error {
+	var netErr net.Error
+	if err == io.EOF || errors.As(err, &netErr) {
+		return err
+	}
+	it, ok := t.(inboundTransport)
+	if !ok || it.inbound() == nil {
+		return err
+	}
+	raw, rawOffset := it.inbound().snapshot()
+	return &DecodeError{
+		Err:          err,
+		Offset:       dec.InputOffset(),
+		StanzaOffset: stanzaOffset,
+		Raw:          redactInbound(raw),
+		RawOffset:    rawOffset,
+	}
+}
+
+// inboundRecorder is a reader keeping the last bytes read from the underlying reader.
+type inboundRecorder struct {
+	r    io.Reader
+	size int
+
+	mu    sync.Mutex
+	buf   []byte
+	total int64
+}
+
+// newInboundRecorder records the last size bytes read from r. A zero size uses the default size,
+// and a negative size disables recording.
+func newInboundRecorder(r io.Reader, size int) *inboundRecorder {
+	if size == 0 {
+		size = defaultDecodeErrorBufferSize
+	}
+	if size < 0 {
+		size = 0
+	}
+	return &inboundRecorder{r: r, size: size}
+}
+
+func (ir *inboundRecorder) Read(p []byte) (n int, err error) {
+	n, err = ir.r.Read(p)
+	if n > 0 {
+		ir.mu.Lock()
+		ir.total += int64(n)
+		if ir.size > 0 {
+			ir.buf = append(ir.buf, p[:n]...)
+			// Trim once the buffer is twice the size, to avoid copying on each read
+			if len(ir.buf) > 2*ir.size {
+				ir.buf = append(ir.buf[:0], ir.buf[len(ir.buf)-ir.size:]...)
+			}
+		}
+		ir.mu.Unlock()
+	}
+	return
+}
+
+// snapshot returns a copy of the last bytes read, and their position in the stream.
+func (ir *inboundRecorder) snapshot() ([]byte, int64) {
+	ir.mu.Lock()
+	defer ir.mu.Unlock()
+	data := ir.buf
+	if len(data) > ir.size {
+		data = data[len(data)-ir.size:]
+	}
+	out := make([]byte, len(data))
+	copy(out, data)
+	return out, ir.total - int64(len(out))
+}
+
+// Elements whose content must not appear in error reports: SASL exchanges and passwords.
+var sensitiveElement = regexp.MustCompile(`<(?:[\w-]+:)?(?:auth|response|challenge|success|password)(?:\s[^>]*[^/])?>([^<]*)`)
+
+// redactInbound masks the content of sensitive elements. Masked content keeps its length,
+// so that stream offsets remain valid.
+func redactInbound(raw []byte) []byte {
+	for _, loc := range sensitiveElement.FindAllSubmatchIndex(raw, -1) {
+		for i := loc[2]; i < loc[3]; i++ {
+			raw[i] = '*'
+		}
+	}
+	return raw
+}
@@ -0,0 +1,86 @@
+package xmpp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestInboundRecorderKeepsLastBytes(t *testing.T) {
+	data := strings.Repeat("0123456789", 10)
+	ir := newInboundRecorder(iotest.OneByteReader(strings.NewReader(data)), 16)
+	var out bytes.Buffer
+	if _, err := out.ReadFrom(ir); err != nil {
+		t.Fatalf("could not read: %v", err)
+	}
+	if out.String() != data {
+		t.Errorf("recorder should not alter read data")
+	}
+	raw, offset := ir.snapshot()
+	if string(raw) != data[len(data)-16:] || offset != int64(len(data)-16) {
+		t.Errorf("unexpected snapshot %q at offset %d", raw, offset)
+	}
+}
+
+func TestInboundRecorderDisabled(t *testing.T) {
+	ir := newInboundRecorder(strings.NewReader("<message/>"), -1)
+	var out bytes.Buffer
+	_, _ = out.ReadFrom(ir)
+	if raw, offset := ir.snapshot(); len(raw) != 0 || offset != int64(len("<message/>")) {
+		t.Errorf("disabled recorder should not keep data, got %q at offset %d", raw, offset)
+	}
+}
+
+func TestDecodeErrorContext(t *testing.T) {
+	stream := `<stream:stream xmlns='jabber:client' xmlns:stream='http://etherx.jabber.org/streams' id='s1' version='1.0'>` +
+		`<challenge xmlns='urn:ietf:params:xml:ns:xmpp-sasl'>c2VjcmV0</challenge>` +
+		`<message from='romeo@montague.lit'><body>hi</body></message>` +
+		`<message from='mallory@evil.lit'><body>fish & chips</body></message>`
+
+	recorder := newInboundRecorder(strings.NewReader(stream), 0)
+	transport := &XMPPTransport{recorder: recorder, decoder: xml.NewDecoder(bufio.NewReader(recorder))}
+	dec := transport.GetDecoder()
+	if _, err := stanza.InitStream(dec); err != nil {
+		t.Fatalf("could not open stream: %v", err)
+	}
+
+	var err error
+	for err == nil {
+		start := dec.InputOffset()
+		if _, err = stanza.NextPacket(dec); err != nil {
+			err = decodeError(transport, dec, err, start)
+		}
+	}
+
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("expected a DecodeError, got %v", err)
+	}
+	if !strings.HasPrefix(string(de.Stanza()), `<message from='mallory@evil.lit'>`) {
+		t.Errorf("unexpected failing stanza: %q", de.Stanza())
+	}
+	if de.Offset <= de.StanzaOffset {
+		t.Errorf("error offset %d should be after stanza start %d", de.Offset, de.StanzaOffset)
+	}
+	if bytes.Contains(de.Raw, []byte("c2VjcmV0")) {
+		t.Errorf("SASL content should be redacted: %q", de.Raw)
+	}
+	if !bytes.Contains(de.Raw, []byte("<body>hi</body>")) {
+		t.Errorf("regular content should be kept: %q", de.Raw)
+	}
+}
+
+func TestRedactInbound(t *testing.T) {
+	raw := `<success xmlns='urn:ietf:params:xml:ns:xmpp-sasl'/><authorize>keep</authorize><password>secret</password>`
+	redacted := string(redactInbound([]byte(raw)))
+	expected := `<success xmlns='urn:ietf:params:xml:ns:xmpp-sasl'/><authorize>keep</authorize><password>******</password>`
+	if redacted != expected {
+		t.Errorf("unexpected redaction:\n%s\nexpected:\n%s", redacted, expected)
+	}
+}
@@ -0,0 +1,37 @@
+package xmpp
+
+import (
+	"encoding/xml"
+)
+
+// ============================================================================
+// Stanzas exceeding the decoding limits
+
+const nsStreamErrors = "urn:ietf:params:xml:ns:xmpp-streams"
+
+// policyViolation closes the stream with a policy-violation stream error, when a received stanza
+// exceeds the decoding limits. The rest of the stream cannot be decoded, so there is no need to wait
+// for the stream close tag of the server.
+func policyViolation(t Transport, err error) {
+	closeWithStreamError(t, "policy-violation", err)
+}
+
+// badFormat closes the stream with a bad-format stream error, when character data other than
+// whitespace is received between stanzas.
+func badFormat(t Transport, err error) {
+	closeWithStreamError(t, "bad-format", err)
+}
+
+// closeWithStreamError sends a stream error with the condition, explained by err, and closes the
+// stream without waiting for the stream close tag of the server.
+func closeWithStreamError(t Transport, condition string, err error) {
+	text := struct {
+		XMLName xml.Name `xml:"urn:ietf:params:xml:ns:xmpp-streams text"`
+		Text    string   `xml:",chardata"`
+	}{Text: err.Error()}
+	data, _ := xml.Marshal(text)
+	_, _ = t.Write([]byte("<stream:error><" + condition + " xmlns='" + nsStreamErrors + "'/>" +
+		string(data) + "</stream:error>"))
+	go t.ReceivedStreamClose()
+	_ = t.Close()
+}
@@ -0,0 +1,153 @@
+package xmpp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// The server sends a stanza with 100k sibling elements: the client stops decoding it and closes the
+// stream with a policy-violation stream error.
+func TestClient_StanzaTooComplex(t *testing.T) {
+	ready := make(chan struct{})
+	done := make(chan struct{})
+	h := func(t *testing.T, sc *ServerConn) {
+		defer close(done)
+		handlerClientConnectSuccess(t, sc)
+		discardPresence(t, sc)
+		<-ready
+		go fmt.Fprint(sc.connection, `<message from='mallory@evil.lit' to='test@localhost'>`+
+			strings.Repeat(`<x/>`, 100000)+`</message>`)
+
+		se, err := stanza.NextStart(sc.decoder)
+		if err != nil {
+			t.Errorf("cannot read stream error: %s", err)
+			return
+		}
+		var streamErr stanza.StreamError
+		if err = sc.decoder.DecodeElement(&streamErr, &se); err != nil {
+			t.Errorf("cannot decode stream error: %s", err)
+			return
+		}
+		if streamErr.Error.Local != "policy-violation" {
+			t.Errorf("expected a policy-violation stream error, got %+v", streamErr)
+		}
+	}
+	client, mock := mockClientConnection(t, h, testClientDecodeLimitsPort)
+	defer mock.Stop()
+
+	errChan := make(chan error, 10)
+	client.ErrorHandler = func(err error) {
+		errChan <- err
+	}
+	close(ready)
+
+	select {
+	case err := <-errChan:
+		var limitErr *stanza.DecodeLimitError
+		if !errors.As(err, &limitErr) || limitErr.Limit != "elements" {
+			t.Errorf("expected the elements limit to be reported, got %v", err)
+		}
+	case <-time.After(defaultChannelTimeout):
+		t.Fatal("decode limit error was not reported")
+	}
+	select {
+	case <-done:
+	case <-time.After(defaultChannelTimeout):
+		t.Errorf("The mock server failed to finish its job !")
+	}
+}
+
+// The server sends whitespace keepalives and a comment between messages: they are skipped and
+// counted.
+func TestClient_SkippedTokens(t *testing.T) {
+	ready := make(chan struct{})
+	h := func(t *testing.T, sc *ServerConn) {
+		handlerClientConnectSuccess(t, sc)
+		discardPresence(t, sc)
+		<-ready
+		fmt.Fprint(sc.connection, "\n"+`<message from='juliet@capulet.lit/balcony' to='test@localhost' id='1'/>`+
+			"\n \n"+`<!-- keepalive -->`+"\n"+
+			`<message from='juliet@capulet.lit/balcony' to='test@localhost' id='2'/>`)
+	}
+	client, mock := mockClientConnection(t, h, testClientSkippedTokensPort)
+	defer mock.Stop()
+
+	received := make(chan string, 2)
+	client.router.HandleFunc("message", func(_ Sender, p stanza.Packet) {
+		received <- p.(stanza.Message).Id
+	})
+	close(ready)
+
+	// Messages are handled concurrently
+	ids := make(map[string]bool)
+	for len(ids) < 2 {
+		select {
+		case id := <-received:
+			ids[id] = true
+		case <-time.After(defaultChannelTimeout):
+			t.Fatalf("messages were not received: %v", ids)
+		}
+	}
+	if !ids["1"] || !ids["2"] {
+		t.Errorf("unexpected messages: %v", ids)
+	}
+	expected := stanza.SkippedTokens{Whitespace: 3, Comments: 1}
+	if got := client.SkippedTokens(); got != expected {
+		t.Errorf("unexpected skipped tokens: %+v, expected %+v", got, expected)
+	}
+}
+
+// The server sends character data between stanzas: the client closes the stream with a bad-format
+// stream error.
+func TestClient_UnexpectedCharData(t *testing.T) {
+	ready := make(chan struct{})
+	done := make(chan struct{})
+	h := func(t *testing.T, sc *ServerConn) {
+		defer close(done)
+		handlerClientConnectSuccess(t, sc)
+		discardPresence(t, sc)
+		<-ready
+		go fmt.Fprint(sc.connection, `garbage<message from='juliet@capulet.lit/balcony' to='test@localhost'/>`)
+
+		se, err := stanza.NextStart(sc.decoder)
+		if err != nil {
+			t.Errorf("cannot read stream error: %s", err)
+			return
+		}
+		var streamErr stanza.StreamError
+		if err = sc.decoder.DecodeElement(&streamErr, &se); err != nil {
+			t.Errorf("cannot decode stream error: %s", err)
+			return
+		}
+		if streamErr.Error.Local != "bad-format" {
+			t.Errorf("expected a bad-format stream error, got %+v", streamErr)
+		}
+	}
+	client, mock := mockClientConnection(t, h, testClientUnexpectedCharDataPort)
+	defer mock.Stop()
+
+	errChan := make(chan error, 10)
+	client.ErrorHandler = func(err error) {
+		errChan <- err
+	}
+	close(ready)
+
+	select {
+	case err := <-errChan:
+		if !errors.Is(err, stanza.ErrUnexpectedCharData) {
+			t.Errorf("expected ErrUnexpectedCharData, got %v", err)
+		}
+	case <-time.After(defaultChannelTimeout):
+		t.Fatal("unexpected character data was not reported")
+	}
+	select {
+	case <-done:
+	case <-time.After(defaultChannelTimeout):
+		t.Errorf("The mock server failed to finish its job !")
+	}
+}
@@ -0,0 +1,159 @@
+package xmpp
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Delivery latency
+
+// defaultMaxPendingDeliveries is the number of messages waiting for a receipt or marker kept by a
+// delivery tracker, when the maximum is not set.
+const defaultMaxPendingDeliveries = 1000
+
+// DeliveryLatencyHook is called with the domain of the peer and the time elapsed between sending a
+// message and receiving its delivery receipt or marker.
+type DeliveryLatencyHook func(domain string, latency time.Duration)
+
+// DeliveryTracker measures the delivery latency of the messages requesting a delivery receipt
+// (XEP-0184) or a chat marker (XEP-0333). It is enabled on a client by setting the DeliveryTracker
+// field of its Config.
+//
+// The send time of the tracked messages is read from the monotonic clock, so that latencies are not
+// affected by changes of the wall clock. Other messages are not tracked.
+type DeliveryTracker struct {
+	hook       DeliveryLatencyHook
+	maxPending int
+
+	mu      sync.Mutex
+	pending map[deliveryKey]time.Time
+	// order of the pending messages, oldest first, to drop them when there are too many. Keys of the
+	// messages delivered since are skipped.
+	order []deliveryKey
+}
+
+type deliveryKey struct {
+	peer string
+	id   string
+}
+
+// NewDeliveryTracker creates a delivery tracker reporting the latencies to the hook. maxPending is
+// the number of messages waiting for a receipt or marker: the oldest one is dropped when it is
+// exceeded. Defaults to 1000.
+func NewDeliveryTracker(hook DeliveryLatencyHook, maxPending int) *DeliveryTracker {
+	if maxPending <= 0 {
+		maxPending = defaultMaxPendingDeliveries
+	}
+	return &DeliveryTracker{
+		hook:       hook,
+		maxPending: maxPending,
+		pending:    make(map[deliveryKey]time.Time),
+	}
+}
+
+// Pending returns the number of tracked messages waiting for a receipt or marker.
+func (t *DeliveryTracker) Pending() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.pending)
+}
+
+// sending records the send time of the message, if it requests a receipt or marker. It returns the
+// key of the tracked message.
+func (t *DeliveryTracker) sending(p stanza.Packet) (deliveryKey, bool) {
+	msg, ok := p.(stanza.Message)
+	if !ok || msg.Id == "" || msg.To == "" || !requestsDelivery(msg) {
+		return deliveryKey{}, false
+	}
+	key := deliveryKey{peer: strings.ToLower(bareJid(msg.To)), id: msg.Id}
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending[key] = now
+	t.order = append(t.order, key)
+	for len(t.pending) > t.maxPending {
+		oldest := t.order[0]
+		t.order = t.order[1:]
+		delete(t.pending, oldest)
+	}
+	if len(t.order) > 2*t.maxPending {
+		t.compact()
+	}
+	return key, true
+}
+
+// compact removes the keys of the delivered messages from the order.
+func (t *DeliveryTracker) compact() {
+	order := make([]deliveryKey, 0, len(t.pending))
+	for _, key := range t.order {
+		if _, ok := t.pending[key]; ok {
+			order = append(order, key)
+		}
+	}
+	t.order = order
+}
+
+// forget stops tracking a message that could not be sent.
+func (t *DeliveryTracker) forget(key deliveryKey) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.pending, key)
+}
+
+// received reports the latency of the tracked message acknowledged by the packet, if any.
+func (t *DeliveryTracker) received(p stanza.Packet) {
+	msg, ok := p.(stanza.Message)
+	if !ok || msg.From == "" {
+		return
+	}
+	id := deliveredId(msg)
+	if id == "" {
+		return
+	}
+	key := deliveryKey{peer: strings.ToLower(bareJid(msg.From)), id: id}
+
+	t.mu.Lock()
+	sent, ok := t.pending[key]
+	delete(t.pending, key)
+	t.mu.Unlock()
+	if !ok || t.hook == nil {
+		return
+	}
+	domain := key.peer
+	if i := strings.IndexByte(domain, '@'); i >= 0 {
+		domain = domain[i+1:]
+	}
+	t.hook(domain, time.Since(sent))
+}
+
+// requestsDelivery tells if the message requests a delivery receipt or is markable.
+func requestsDelivery(msg stanza.Message) bool {
+	for _, ext := range msg.Extensions {
+		switch ext.(type) {
+		case stanza.ReceiptRequest, *stanza.ReceiptRequest, stanza.Markable, *stanza.Markable:
+			return true
+		}
+	}
+	return false
+}
+
+// deliveredId returns the id of the message acknowledged by a receipt, or by a received or displayed
+// marker.
+func deliveredId(msg stanza.Message) string {
+	for _, ext := range msg.Extensions {
+		switch e := ext.(type) {
+		case *stanza.ReceiptReceived:
+			return e.ID
+		case *stanza.MarkReceived:
+			return e.ID
+		case *stanza.MarkDisplayed:
+			return e.ID
+		}
+	}
+	return ""
+}
@@ -0,0 +1,124 @@
+package xmpp
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+type deliveryObservation struct {
+	domain  string
+	latency time.Duration
+}
+
+func TestDeliveryTracker(t *testing.T) {
+	var observed []deliveryObservation
+	tracker := NewDeliveryTracker(func(domain string, latency time.Duration) {
+		observed = append(observed, deliveryObservation{domain, latency})
+	}, 0)
+
+	receipt := stanza.NewMessage(stanza.Attrs{To: "Juliet@capulet.lit/balcony", Id: "msg1"})
+	receipt.Extensions = append(receipt.Extensions, stanza.ReceiptRequest{})
+	markable := stanza.NewMessage(stanza.Attrs{To: "juliet@capulet.lit", Id: "msg2"})
+	markable.Extensions = append(markable.Extensions, &stanza.Markable{})
+	untracked := stanza.NewMessage(stanza.Attrs{To: "juliet@capulet.lit", Id: "msg3"})
+	for _, msg := range []stanza.Message{receipt, markable, untracked} {
+		tracker.sending(msg)
+	}
+	if tracker.Pending() != 2 {
+		t.Fatalf("only the messages requesting a receipt or marker should be tracked, got %d", tracker.Pending())
+	}
+
+	// The receipt is sent by another resource of the peer
+	time.Sleep(time.Millisecond)
+	received := stanza.NewMessage(stanza.Attrs{From: "juliet@capulet.lit/phone"})
+	received.Extensions = append(received.Extensions, &stanza.ReceiptReceived{ID: "msg1"})
+	tracker.received(received)
+	if len(observed) != 1 || observed[0].domain != "capulet.lit" || observed[0].latency < time.Millisecond {
+		t.Fatalf("unexpected observation: %+v", observed)
+	}
+
+	// A receipt from another peer is ignored
+	displayed := stanza.NewMessage(stanza.Attrs{From: "nurse@capulet.lit/chamber"})
+	displayed.Extensions = append(displayed.Extensions, &stanza.MarkDisplayed{ID: "msg2"})
+	tracker.received(displayed)
+	if len(observed) != 1 {
+		t.Fatalf("marker from another peer should be ignored: %+v", observed)
+	}
+	displayed.From = "juliet@capulet.lit/balcony"
+	tracker.received(displayed)
+	tracker.received(displayed)
+	if len(observed) != 2 || tracker.Pending() != 0 {
+		t.Errorf("marker should be reported once: %+v", observed)
+	}
+}
+
+func TestDeliveryTrackerMaxPending(t *testing.T) {
+	tracker := NewDeliveryTracker(nil, 3)
+	for i := 0; i < 10; i++ {
+		msg := stanza.NewMessage(stanza.Attrs{To: "juliet@capulet.lit", Id: fmt.Sprintf("msg%d", i)})
+		msg.Extensions = append(msg.Extensions, stanza.ReceiptRequest{})
+		tracker.sending(msg)
+	}
+	if tracker.Pending() != 3 {
+		t.Errorf("unexpected number of pending messages: %d", tracker.Pending())
+	}
+	if _, ok := tracker.pending[deliveryKey{peer: "juliet@capulet.lit", id: "msg9"}]; !ok {
+		t.Errorf("latest message should be kept")
+	}
+	if len(tracker.order) > 6 {
+		t.Errorf("order of the pending messages should be compacted: %d", len(tracker.order))
+	}
+}
+
+func TestClient_DeliveryLatency(t *testing.T) {
+	done := make(chan struct{})
+	h := func(t *testing.T, sc *ServerConn) {
+		defer close(done)
+		handlerClientConnectSuccess(t, sc)
+		discardPresence(t, sc)
+
+		packet, err := stanza.NextPacket(sc.decoder)
+		if err != nil {
+			t.Errorf("cannot read message: %s", err)
+			return
+		}
+		msg, ok := packet.(stanza.Message)
+		if !ok {
+			t.Errorf("expected a message, got %T", packet)
+			return
+		}
+		fmt.Fprintf(sc.connection, `<message from='juliet@capulet.lit/balcony' to='test@localhost'>`+
+			`<received xmlns='urn:xmpp:receipts' id='%s'/></message>`, msg.Id)
+	}
+	client, mock := mockClientConnection(t, h, testClientDeliveryPort)
+	defer mock.Stop()
+
+	observed := make(chan string, 1)
+	client.config.DeliveryTracker = NewDeliveryTracker(func(domain string, _ time.Duration) {
+		observed <- domain
+	}, 0)
+
+	msg := stanza.NewMessage(stanza.Attrs{To: "juliet@capulet.lit", Id: "receipt1"})
+	msg.Body = "Art thou not Romeo?"
+	msg.Extensions = append(msg.Extensions, stanza.ReceiptRequest{})
+	if err := client.Send(msg); err != nil {
+		t.Fatalf("cannot send message: %s", err)
+	}
+
+	select {
+	case domain := <-observed:
+		if domain != "capulet.lit" {
+			t.Errorf("unexpected peer domain: %s", domain)
+		}
+	case <-time.After(defaultChannelTimeout):
+		t.Fatal("delivery latency was not reported")
+	}
+	select {
+	case <-done:
+	case <-time.After(defaultChannelTimeout):
+		t.Fatal("server did not complete")
+	}
+}
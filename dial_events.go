@@ -0,0 +1,261 @@
+package xmpp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Connection attempt events
+
+// Stages of a connection attempt, reported in DialEvent.Stage.
+const (
+	// DialStageDNS is the resolution of the server address with the SRV records of the domain
+	DialStageDNS = "dns"
+	// DialStageConnect is the TCP connection to the server. With the websocket transport, it includes
+	// the opening of the stream.
+	DialStageConnect = "connect"
+	// DialStageTLS is the TLS handshake, with direct TLS or after StartTLS. The detail is the
+	// negotiated version and cipher suite.
+	DialStageTLS = "tls"
+	// DialStageStream is the exchange of the stream headers, repeated after TLS and authentication.
+	// The detail is the stream id.
+	DialStageStream = "stream"
+	// DialStageFeatures is the reception of the stream features, listed in the detail
+	DialStageFeatures = "features"
+	// DialStageSASL is the authentication. The detail is the SASL mechanism chosen.
+	DialStageSASL = "sasl"
+	// DialStageBind is the binding of the resource. It is skipped when a session is resumed. The
+	// detail is the bound JID.
+	DialStageBind = "bind"
+	// DialStageSM is the enabling of stream management (XEP-0198), or the resumption of the session
+	DialStageSM = "sm"
+)
+
+// dialEventsSize is the number of events buffered for the application. Events are dropped when the
+// buffer is full, as a connection attempt must not wait for the application.
+const dialEventsSize = 32
+
+// DialEvent describes a stage of a connection attempt, to show which addresses were tried and why
+// the connection failed, for example in a UI.
+type DialEvent struct {
+	Stage   string
+	Address string
+	// Detail describes the outcome of the stage, for example the number of SRV records found
+	Detail   string
+	Duration time.Duration
+	Error    error
+	Success  bool
+}
+
+func (e DialEvent) String() string {
+	s := fmt.Sprintf("%s %s (%s)", e.Stage, e.Address, e.Duration)
+	if e.Detail != "" {
+		s += ": " + e.Detail
+	}
+	if e.Error != nil {
+		s += ": " + e.Error.Error()
+	}
+	return s
+}
+
+// DialError is returned by Connect when the connection fails, with the last stage reached.
+type DialError struct {
+	Stage string
+	Err   error
+}
+
+func (e *DialError) Error() string {
+	return fmt.Sprintf("connection failed at %s stage: %s", e.Stage, e.Err)
+}
+
+func (e *DialError) Unwrap() error { return e.Err }
+
+// DialEvents returns the events of the first connection of the client, from the resolution of the
+// server address to the binding of the resource. The channel is closed when Connect returns.
+func (c *Client) DialEvents() <-chan DialEvent {
+	return c.dialEvents.events()
+}
+
+// DiagnoseConnection connects with the configuration, then disconnects, and returns the events of
+// the connection attempt, for example to include them in a support bundle. The initial presence is
+// not sent and the hooks are not called. When ctx ends first, the events received so far are
+// returned with the error of ctx.
+func DiagnoseConnection(ctx context.Context, config *Config) ([]DialEvent, error) {
+	c, err := NewClient(config, NewRouter(), func(error) {})
+	if err != nil {
+		return nil, err
+	}
+	done := make(chan error, 1)
+	go func() {
+		err := c.connect()
+		c.dialEvents.close()
+		done <- c.dialEvents.failure(err)
+		if err == nil {
+			go c.awaitStreamClose()
+			_ = c.Disconnect()
+		}
+	}()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return c.dialEvents.recorded(), err
+}
+
+// awaitStreamClose reads the stream until the server closes it, to disconnect without the receiver.
+func (c *Client) awaitStreamClose() {
+	for {
+		val, err := stanza.NextPacket(c.transport.GetDecoder())
+		if err != nil {
+			return
+		}
+		if _, ok := val.(stanza.StreamClosePacket); ok {
+			c.transport.ReceivedStreamClose()
+			return
+		}
+	}
+}
+
+// dialTrace emits the event of a connection stage started at start.
+type dialTrace func(stage, detail string, start time.Time, err error)
+
+// dialTracer is implemented by the transports emitting the events of their own stages.
+type dialTracer interface {
+	setDialTrace(trace dialTrace)
+}
+
+// traceDial emits the event of a connection stage of the client.
+func (c *Client) traceDial(stage, detail string, start time.Time, err error) {
+	c.dialEvents.emit(stage, c.config.Address, detail, start, err)
+}
+
+// tlsDetail describes the negotiated TLS version and cipher suite.
+func tlsDetail(state tls.ConnectionState) string {
+	version := fmt.Sprintf("0x%04x", state.Version)
+	switch state.Version {
+	case tls.VersionTLS10:
+		version = "TLS 1.0"
+	case tls.VersionTLS11:
+		version = "TLS 1.1"
+	case tls.VersionTLS12:
+		version = "TLS 1.2"
+	case tls.VersionTLS13:
+		version = "TLS 1.3"
+	}
+	return version + " " + tls.CipherSuiteName(state.CipherSuite)
+}
+
+// transportTLSDetail describes the TLS connection of the transport, if known.
+func transportTLSDetail(t Transport) string {
+	if x, ok := t.(*XMPPTransport); ok {
+		if conn, ok := x.conn.(*tls.Conn); ok {
+			return tlsDetail(conn.ConnectionState())
+		}
+	}
+	return ""
+}
+
+// smDetail describes the stream management session enabled.
+func smDetail(state SMState) string {
+	if state.Id == "" {
+		return "not resumable"
+	}
+	return "id " + state.Id
+}
+
+// featuresDetail lists the stream features relevant to the connection.
+func featuresDetail(f stanza.StreamFeatures) string {
+	var features []string
+	if _, ok := f.DoesStartTLS(); ok {
+		features = append(features, "starttls")
+	}
+	if len(f.Mechanisms.Mechanism) > 0 {
+		features = append(features, "mechanisms="+strings.Join(f.Mechanisms.Mechanism, ","))
+	}
+	if f.Bind.XMLName.Local != "" {
+		features = append(features, "bind")
+	}
+	if f.DoesStreamManagement() {
+		features = append(features, "sm")
+	}
+	if f.DoesClientStateIndication() {
+		features = append(features, "csi")
+	}
+	return strings.Join(features, " ")
+}
+
+// dialEvents is the channel of the connection attempt events. Its zero value is ready to use.
+type dialEvents struct {
+	mu     sync.Mutex
+	ch     chan DialEvent
+	closed bool
+	// Events emitted before the channel was closed, returned by DiagnoseConnection
+	trace []DialEvent
+	// Stage of the last event, reported in DialError
+	last string
+}
+
+func (d *dialEvents) events() chan DialEvent {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.ch == nil {
+		d.ch = make(chan DialEvent, dialEventsSize)
+	}
+	return d.ch
+}
+
+// emit sends the event of the stage started at start, without blocking. Events emitted after the
+// channel is closed are dropped, but their stage is still reported by failure.
+func (d *dialEvents) emit(stage, address, detail string, start time.Time, err error) {
+	ch := d.events()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.last = stage
+	if d.closed {
+		return
+	}
+	e := DialEvent{Stage: stage, Address: address, Detail: detail, Duration: time.Since(start), Error: err, Success: err == nil}
+	d.trace = append(d.trace, e)
+	select {
+	case ch <- e:
+	default:
+	}
+}
+
+// close closes the channel, once the connection is established or failed.
+func (d *dialEvents) close() {
+	ch := d.events()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.closed {
+		d.closed = true
+		close(ch)
+	}
+}
+
+func (d *dialEvents) recorded() []DialEvent {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]DialEvent{}, d.trace...)
+}
+
+// failure wraps the error of a connection attempt in a DialError, with the last stage reached.
+func (d *dialEvents) failure(err error) error {
+	if err == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.last == "" {
+		return err
+	}
+	return &DialError{Stage: d.last, Err: err}
+}
@@ -0,0 +1,106 @@
+package xmpp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientDialEvents(t *testing.T) {
+	mock := ServerMock{}
+	mock.Start(t, testXMPPAddress, handlerClientConnectSuccess)
+	defer mock.Stop()
+
+	config := Config{
+		TransportConfiguration: TransportConfiguration{
+			Address: testXMPPAddress,
+		},
+		Jid:        "test@localhost",
+		Credential: Password("test"),
+		Insecure:   true}
+	client, err := NewClient(&config, NewRouter(), clientDefaultErrorHandler)
+	if err != nil {
+		t.Fatalf("connect create XMPP client: %s", err)
+	}
+	if err = client.Connect(); err != nil {
+		t.Fatalf("XMPP connection failed: %s", err)
+	}
+
+	// The mock server does not offer StartTLS
+	var stages []string
+	for e := range client.DialEvents() {
+		if !e.Success || e.Error != nil || e.Address != testXMPPAddress {
+			t.Errorf("unexpected event: %+v", e)
+		}
+		if e.Stage == DialStageSASL && e.Detail != "PLAIN" {
+			t.Errorf("unexpected SASL mechanism: %+v", e)
+		}
+		stages = append(stages, e.Stage)
+	}
+	expected := []string{DialStageConnect, DialStageStream, DialStageFeatures, DialStageSASL,
+		DialStageStream, DialStageFeatures, DialStageBind}
+	if len(stages) != len(expected) {
+		t.Fatalf("unexpected stages: %v", stages)
+	}
+	for i, stage := range expected {
+		if stages[i] != stage {
+			t.Errorf("unexpected stages: %v", stages)
+		}
+	}
+}
+
+func TestClientDialEventsFailure(t *testing.T) {
+	config := Config{
+		TransportConfiguration: TransportConfiguration{
+			// Nothing listens on this port
+			Address: testXMPPAddress,
+		},
+		Jid:        "test@localhost",
+		Credential: Password("test"),
+		Insecure:   true}
+	client, err := NewClient(&config, NewRouter(), clientDefaultErrorHandler)
+	if err != nil {
+		t.Fatalf("connect create XMPP client: %s", err)
+	}
+	err = client.Connect()
+	var dialErr *DialError
+	if !errors.As(err, &dialErr) || dialErr.Stage != DialStageConnect {
+		t.Fatalf("connection should fail at connect stage: %v", err)
+	}
+
+	e, ok := <-client.DialEvents()
+	if !ok || e.Stage != DialStageConnect || e.Success || e.Error == nil {
+		t.Errorf("unexpected event: %+v", e)
+	}
+	if _, ok = <-client.DialEvents(); ok {
+		t.Errorf("channel should be closed after a failed connection")
+	}
+}
+
+func TestDiagnoseConnection(t *testing.T) {
+	mock := ServerMock{}
+	mock.Start(t, testXMPPAddress, handlerClientConnectSuccess)
+	defer mock.Stop()
+
+	config := Config{
+		TransportConfiguration: TransportConfiguration{
+			Address: testXMPPAddress,
+		},
+		Jid:        "test@localhost",
+		Credential: Password("test"),
+		Insecure:   true}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	trace, err := DiagnoseConnection(ctx, &config)
+	if err != nil {
+		t.Fatalf("diagnosis failed: %s", err)
+	}
+	if len(trace) != 7 || trace[6].Stage != DialStageBind {
+		t.Fatalf("unexpected trace: %v", trace)
+	}
+	if !strings.Contains(trace[2].Detail, "mechanisms=PLAIN") {
+		t.Errorf("features should list the SASL mechanisms: %+v", trace[2])
+	}
+}
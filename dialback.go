@@ -0,0 +1,98 @@
+package xmpp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Server Dialback (XEP-0220)
+
+// ComputeDialbackKey generates a dialback key, using the algorithm recommended in XEP-0220 - 2.4 (from XEP-0185):
+//
+//	key = HEX( HMAC-SHA256( HEX( SHA256(secret) ), { receivingDomain, ' ', sendingDomain, ' ', streamID } ) )
+//
+// The key is always 64 lowercase hex characters long, leading zeros included.
+func ComputeDialbackKey(streamID, receivingDomain, sendingDomain, secret string) string {
+	hashedSecret := sha256.Sum256([]byte(secret))
+	mac := hmac.New(sha256.New, []byte(hex.EncodeToString(hashedSecret[:])))
+	mac.Write([]byte(receivingDomain + " " + sendingDomain + " " + streamID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyDialbackKey checks a dialback key against the one we would generate for the same stream.
+// The comparison is done in constant time.
+func VerifyDialbackKey(key, streamID, receivingDomain, sendingDomain, secret string) bool {
+	expected := ComputeDialbackKey(streamID, receivingDomain, sendingDomain, secret)
+	return subtle.ConstantTimeCompare([]byte(key), []byte(expected)) == 1
+}
+
+// DialbackNegotiator generates and checks dialback keys on behalf of a server domain.
+// Secret must be kept private and shared only by the servers authoritative for Domain.
+type DialbackNegotiator struct {
+	Domain string
+	Secret string
+}
+
+// NewDialbackNegotiator creates a negotiator for the given local domain.
+func NewDialbackNegotiator(domain, secret string) (*DialbackNegotiator, error) {
+	if domain == "" || secret == "" {
+		return nil, errors.New("dialback requires a domain and a secret")
+	}
+	return &DialbackNegotiator{Domain: domain, Secret: secret}, nil
+}
+
+// Result builds the db:result the initiating server sends on the stream streamID opened
+// with receivingDomain.
+func (n *DialbackNegotiator) Result(streamID, receivingDomain string) stanza.DialbackResult {
+	return stanza.DialbackResult{
+		From: n.Domain,
+		To:   receivingDomain,
+		Key:  ComputeDialbackKey(streamID, receivingDomain, n.Domain, n.Secret),
+	}
+}
+
+// VerifyRequest builds the db:verify the receiving server sends to the authoritative server,
+// to check the key received in a db:result on the stream streamID.
+func (n *DialbackNegotiator) VerifyRequest(result stanza.DialbackResult, streamID string) stanza.DialbackVerify {
+	return stanza.DialbackVerify{
+		From: n.Domain,
+		To:   result.From,
+		Id:   streamID,
+		Key:  result.Key,
+	}
+}
+
+// Verify answers a db:verify request received by the authoritative server.
+// The answer type is valid if the key was generated by this server for the stream.
+func (n *DialbackNegotiator) Verify(req stanza.DialbackVerify) stanza.DialbackVerify {
+	answer := stanza.DialbackVerify{
+		From: n.Domain,
+		To:   req.From,
+		Id:   req.Id,
+		Type: stanza.DialbackTypeInvalid,
+	}
+	if req.To == n.Domain && VerifyDialbackKey(req.Key, req.Id, req.From, n.Domain, n.Secret) {
+		answer.Type = stanza.DialbackTypeValid
+	}
+	return answer
+}
+
+// Validate builds the db:result the receiving server sends to the initiating server, once
+// the authoritative server answered the verification request.
+func (n *DialbackNegotiator) Validate(answer stanza.DialbackVerify) stanza.DialbackResult {
+	result := stanza.DialbackResult{
+		From: n.Domain,
+		To:   answer.From,
+		Type: stanza.DialbackTypeInvalid,
+	}
+	if answer.Type == stanza.DialbackTypeValid {
+		result.Type = stanza.DialbackTypeValid
+	}
+	return result
+}
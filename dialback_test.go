@@ -0,0 +1,79 @@
+package xmpp
+
+import (
+	"testing"
+)
+
+// Test vector from XEP-0185 - Dialback Key Generation and Validation, as referenced by XEP-0220
+const (
+	dialbackSecret    = "s3cr3tf0rd14lb4ck"
+	dialbackReceiving = "example.net"
+	dialbackSending   = "example.com"
+	dialbackStreamID  = "D60000229F"
+	dialbackKey       = "008c689ff366b50c63d69a3e2d2c0e0e1f8404b0118eb688a0102c87cb691bdc"
+)
+
+func TestComputeDialbackKey(t *testing.T) {
+	key := ComputeDialbackKey(dialbackStreamID, dialbackReceiving, dialbackSending, dialbackSecret)
+	if key != dialbackKey {
+		t.Errorf("incorrect dialback key: %s", key)
+	}
+	// The HMAC output starts with a zero byte: leading zeros must be kept.
+	if len(key) != 64 {
+		t.Errorf("dialback key should be 64 hex characters, got %d", len(key))
+	}
+}
+
+func TestVerifyDialbackKey(t *testing.T) {
+	if !VerifyDialbackKey(dialbackKey, dialbackStreamID, dialbackReceiving, dialbackSending, dialbackSecret) {
+		t.Errorf("valid dialback key was rejected")
+	}
+	// Key without zero padding must not be accepted
+	if VerifyDialbackKey(dialbackKey[2:], dialbackStreamID, dialbackReceiving, dialbackSending, dialbackSecret) {
+		t.Errorf("truncated dialback key was accepted")
+	}
+	if VerifyDialbackKey(dialbackKey, "otherstream", dialbackReceiving, dialbackSending, dialbackSecret) {
+		t.Errorf("dialback key for another stream was accepted")
+	}
+	if VerifyDialbackKey(dialbackKey, dialbackStreamID, dialbackReceiving, dialbackSending, "wrong") {
+		t.Errorf("dialback key generated with another secret was accepted")
+	}
+}
+
+func TestDialbackNegotiator(t *testing.T) {
+	originating, err := NewDialbackNegotiator(dialbackSending, dialbackSecret)
+	if err != nil {
+		t.Fatalf("could not create negotiator: %v", err)
+	}
+	receiving, err := NewDialbackNegotiator(dialbackReceiving, "another secret")
+	if err != nil {
+		t.Fatalf("could not create negotiator: %v", err)
+	}
+
+	result := originating.Result(dialbackStreamID, dialbackReceiving)
+	if result.Key != dialbackKey || result.From != dialbackSending || result.To != dialbackReceiving {
+		t.Fatalf("unexpected db:result: %#v", result)
+	}
+
+	req := receiving.VerifyRequest(result, dialbackStreamID)
+	if req.To != dialbackSending || req.Id != dialbackStreamID {
+		t.Fatalf("unexpected db:verify: %#v", req)
+	}
+
+	answer := originating.Verify(req)
+	if answer.Type != "valid" || answer.To != dialbackReceiving {
+		t.Errorf("key should be valid: %#v", answer)
+	}
+	if final := receiving.Validate(answer); final.Type != "valid" || final.To != dialbackSending {
+		t.Errorf("unexpected final db:result: %#v", final)
+	}
+
+	req.Key = "f" + req.Key[1:]
+	if answer = originating.Verify(req); answer.Type != "invalid" {
+		t.Errorf("tampered key should be invalid: %#v", answer)
+	}
+
+	if _, err = NewDialbackNegotiator("example.com", ""); err == nil {
+		t.Errorf("negotiator without secret should not be created")
+	}
+}
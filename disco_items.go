@@ -0,0 +1,192 @@
+package xmpp
+
+import (
+	"encoding/xml"
+	"strconv"
+	"sync"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Service Discovery items (XEP-0030) with Result Set Management (XEP-0059)
+
+// defaultDiscoItemsPageSize is the number of items answered at most in a disco#items result, when
+// the responder page size is not set.
+const defaultDiscoItemsPageSize = 100
+
+// DiscoItemsHandler returns the items of a node, for a disco#items query sent by the from JID. It
+// returns all the items: the responder answers the page requested by the query.
+type DiscoItemsHandler func(from, node string) ([]stanza.DiscoItem, error)
+
+// DiscoItemsResponder answers disco#items queries, with the items of the nodes set statically or
+// returned by handlers when queried, for nodes whose items change at runtime. The root node is the
+// empty node. Lists of items are paged with Result Set Management, the item identifiers being their
+// JID and node. It must be registered on the router with Route.
+type DiscoItemsResponder struct {
+	// PageSize is the number of items answered at most, whatever the max requested by the query,
+	// so that results fit in a stanza. Defaults to 100.
+	PageSize int
+
+	mu       sync.RWMutex
+	handlers map[string]DiscoItemsHandler
+}
+
+// NewDiscoItemsResponder creates a responder without node: queries are answered with an
+// item-not-found error until nodes are set.
+func NewDiscoItemsResponder() *DiscoItemsResponder {
+	return &DiscoItemsResponder{handlers: make(map[string]DiscoItemsHandler)}
+}
+
+// SetItems sets the static items of the node.
+func (r *DiscoItemsResponder) SetItems(node string, items []stanza.DiscoItem) {
+	items = append([]stanza.DiscoItem(nil), items...)
+	r.HandleNode(node, func(string, string) ([]stanza.DiscoItem, error) {
+		return items, nil
+	})
+}
+
+// HandleNode sets the handler returning the items of the node when it is queried.
+func (r *DiscoItemsResponder) HandleNode(node string, handler DiscoItemsHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[node] = handler
+}
+
+// RemoveNode removes the items of the node. It is not found anymore.
+func (r *DiscoItemsResponder) RemoveNode(node string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.handlers, node)
+}
+
+// Route registers the responder on the router, to answer disco#items queries.
+func (r *DiscoItemsResponder) Route(router *Router) *Route {
+	return router.NewRoute().IQNamespaces(stanza.NSDiscoItems).StanzaType(string(stanza.IQTypeGet)).Handler(r)
+}
+
+// HandlePacket answers disco#items queries for the nodes of the responder. It implements the router
+// Handler interface.
+func (r *DiscoItemsResponder) HandlePacket(s Sender, p stanza.Packet) {
+	iq, ok := p.(*stanza.IQ)
+	if !ok || iq.Type != stanza.IQTypeGet {
+		return
+	}
+	query, ok := iq.Payload.(*stanza.DiscoItems)
+	if !ok {
+		return
+	}
+
+	r.mu.RLock()
+	handler := r.handlers[query.Node]
+	r.mu.RUnlock()
+	if handler == nil {
+		_ = s.Send(iq.MakeError(discoItemsError(404, stanza.ErrorTypeCancel, "item-not-found")))
+		return
+	}
+	items, err := handler(iq.From, query.Node)
+	if err != nil {
+		_ = s.Send(iq.MakeError(discoItemsError(500, stanza.ErrorTypeWait, "internal-server-error")))
+		return
+	}
+
+	reply, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeResult, From: iq.To, To: iq.From, Id: iq.Id})
+	if err != nil {
+		return
+	}
+	result := reply.DiscoItems().SetNode(query.Node)
+	pageSize := r.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultDiscoItemsPageSize
+	}
+	if query.ResultSet == nil && len(items) <= pageSize {
+		result.Items = items
+		_ = s.Send(reply)
+		return
+	}
+	if result.Items, result.ResultSet, ok = discoItemsPage(items, query.ResultSet, pageSize); !ok {
+		// The item of the after or before identifier was removed
+		_ = s.Send(iq.MakeError(discoItemsError(404, stanza.ErrorTypeCancel, "item-not-found")))
+		return
+	}
+	_ = s.Send(reply)
+}
+
+func discoItemsError(code int, errType stanza.ErrorType, reason string) stanza.Err {
+	return stanza.Err{XMLName: xml.Name{Local: "error"}, Code: code, Type: errType, Reason: reason}
+}
+
+// discoItemUID returns the RSM identifier of the item.
+func discoItemUID(item stanza.DiscoItem) string {
+	if item.Node == "" {
+		return item.JID
+	}
+	return item.JID + "#" + item.Node
+}
+
+// discoItemsPage returns the page of items requested by the result set, with at most pageSize items.
+// It returns false when the item of the after or before identifier is not found.
+func discoItemsPage(items []stanza.DiscoItem, query *stanza.ResultSet, pageSize int) ([]stanza.DiscoItem, *stanza.ResultSet, bool) {
+	max := pageSize
+	if query != nil && query.Max != nil && *query.Max < max {
+		max = *query.Max
+	}
+	if max < 0 {
+		max = 0
+	}
+	indexOf := func(uid string) int {
+		for i, item := range items {
+			if discoItemUID(item) == uid {
+				return i
+			}
+		}
+		return -1
+	}
+
+	start := 0
+	switch {
+	case query == nil:
+	case query.Index != nil:
+		start = *query.Index
+	case query.After != nil:
+		i := indexOf(*query.After)
+		if i < 0 {
+			return nil, nil, false
+		}
+		start = i + 1
+	case query.Before != nil:
+		end := len(items)
+		if *query.Before != "" {
+			if end = indexOf(*query.Before); end < 0 {
+				return nil, nil, false
+			}
+		}
+		start = end - max
+		if start < 0 {
+			start = 0
+		}
+		if start+max > end {
+			max = end - start
+		}
+	}
+	if start < 0 || start > len(items) {
+		start = len(items)
+	}
+	end := start + max
+	if end > len(items) {
+		end = len(items)
+	}
+
+	page := stanza.RSMSet{Count: len(items)}
+	if end > start {
+		page.First = discoItemUID(items[start])
+		page.Last = discoItemUID(items[end-1])
+		page.Index = strconv.Itoa(start)
+	}
+	rs := page.ResultSet()
+	if len(items) == 0 {
+		// An empty list has a count of zero, which RSMSet omits
+		rs.Count = &page.Count
+	}
+	return items[start:end], rs, true
+}
@@ -0,0 +1,159 @@
+package xmpp
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// queryDiscoItems routes a disco#items query for node, with the result set, and returns the reply.
+func queryDiscoItems(t *testing.T, r *DiscoItemsResponder, node string, rs *stanza.ResultSet) stanza.IQ {
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, From: "juliet@capulet.lit/balcony", To: "rooms.example.com", Id: "items1"})
+	if err != nil {
+		t.Fatalf("could not create IQ: %v", err)
+	}
+	iq.Payload = &stanza.DiscoItems{XMLName: xml.Name{Space: stanza.NSDiscoItems, Local: "query"}, Node: node, ResultSet: rs}
+
+	conn := NewSenderMock()
+	router := NewRouter()
+	r.Route(router)
+	router.route(conn, iq)
+
+	var reply stanza.IQ
+	if err := xml.Unmarshal([]byte(conn.String()), &reply); err != nil {
+		t.Fatalf("could not unmarshal reply %q: %v", conn.String(), err)
+	}
+	if reply.Id != "items1" || reply.To != "juliet@capulet.lit/balcony" {
+		t.Errorf("unexpected reply attributes: %+v", reply.Attrs)
+	}
+	return reply
+}
+
+func roomItems(n int) []stanza.DiscoItem {
+	items := make([]stanza.DiscoItem, n)
+	for i := range items {
+		items[i] = stanza.DiscoItem{JID: fmt.Sprintf("room%03d@rooms.example.com", i)}
+	}
+	return items
+}
+
+func TestDiscoItemsResponderStatic(t *testing.T) {
+	r := NewDiscoItemsResponder()
+	r.SetItems("", []stanza.DiscoItem{{JID: "rooms.example.com", Name: "Rooms"}})
+
+	reply := queryDiscoItems(t, r, "", nil)
+	items, ok := reply.Payload.(*stanza.DiscoItems)
+	if reply.Type != stanza.IQTypeResult || !ok || len(items.Items) != 1 || items.Items[0].Name != "Rooms" {
+		t.Fatalf("unexpected reply: %+v", reply)
+	}
+	if items.ResultSet != nil {
+		t.Errorf("unpaged query should not get a result set: %+v", items.ResultSet)
+	}
+
+	reply = queryDiscoItems(t, r, "unknown", nil)
+	if reply.Type != stanza.IQTypeError || reply.Error == nil || reply.Error.Reason != "item-not-found" {
+		t.Errorf("unknown node should not be found: %+v", reply)
+	}
+}
+
+func TestDiscoItemsResponderDynamic(t *testing.T) {
+	r := NewDiscoItemsResponder()
+	rooms := roomItems(2)
+	r.HandleNode("rooms", func(from, node string) ([]stanza.DiscoItem, error) {
+		if from != "juliet@capulet.lit/balcony" || node != "rooms" {
+			t.Errorf("unexpected handler arguments: %s %s", from, node)
+		}
+		return rooms, nil
+	})
+
+	if items := queryDiscoItems(t, r, "rooms", nil).Payload.(*stanza.DiscoItems); len(items.Items) != 2 || items.Node != "rooms" {
+		t.Errorf("unexpected items: %+v", items)
+	}
+	rooms = roomItems(3)
+	if items := queryDiscoItems(t, r, "rooms", nil).Payload.(*stanza.DiscoItems); len(items.Items) != 3 {
+		t.Errorf("items should be returned by the handler on each query: %+v", items)
+	}
+
+	r.HandleNode("broken", func(string, string) ([]stanza.DiscoItem, error) {
+		return nil, errors.New("backend unavailable")
+	})
+	if reply := queryDiscoItems(t, r, "broken", nil); reply.Error == nil || reply.Error.Reason != "internal-server-error" {
+		t.Errorf("handler error should be answered: %+v", reply)
+	}
+
+	r.RemoveNode("rooms")
+	if reply := queryDiscoItems(t, r, "rooms", nil); reply.Type != stanza.IQTypeError {
+		t.Errorf("removed node should not be found: %+v", reply)
+	}
+}
+
+// Pages the items forwards and backwards, with the requested max and the page size of the responder.
+func TestDiscoItemsResponderPaging(t *testing.T) {
+	r := NewDiscoItemsResponder()
+	r.PageSize = 4
+	rooms := roomItems(10)
+	r.SetItems("", rooms)
+
+	var seen []stanza.DiscoItem
+	query := stanza.NewRSMQuery(3, "")
+	for page := 0; ; page++ {
+		if page > 4 {
+			t.Fatalf("too many pages")
+		}
+		items := queryDiscoItems(t, r, "", query.ResultSet()).Payload.(*stanza.DiscoItems)
+		rs := stanza.NewRSMSet(items.ResultSet)
+		if rs.Count != 10 {
+			t.Errorf("unexpected count: %d", rs.Count)
+		}
+		if len(items.Items) == 0 {
+			break
+		}
+		if len(items.Items) > 3 || rs.First != items.Items[0].JID || rs.Last != items.Items[len(items.Items)-1].JID {
+			t.Errorf("unexpected page %d: %+v %+v", page, items.Items, rs)
+		}
+		seen = append(seen, items.Items...)
+		query = stanza.NewRSMQuery(3, rs.Last)
+	}
+	if len(seen) != 10 || seen[9].JID != rooms[9].JID {
+		t.Errorf("all items should be paged: %+v", seen)
+	}
+
+	// Without max, the page size applies, even without result set in the query
+	items := queryDiscoItems(t, r, "", nil).Payload.(*stanza.DiscoItems)
+	rs := stanza.NewRSMSet(items.ResultSet)
+	if len(items.Items) != 4 || rs.Count != 10 || rs.Index != "0" || rs.Last != rooms[3].JID {
+		t.Errorf("unexpected first page: %+v %+v", items.Items, rs)
+	}
+
+	// Last page
+	items = queryDiscoItems(t, r, "", stanza.NewRSMQueryBefore(3, "").ResultSet()).Payload.(*stanza.DiscoItems)
+	rs = stanza.NewRSMSet(items.ResultSet)
+	if len(items.Items) != 3 || rs.First != rooms[7].JID || rs.Index != "7" {
+		t.Errorf("unexpected last page: %+v %+v", items.Items, rs)
+	}
+	// Previous page
+	items = queryDiscoItems(t, r, "", stanza.NewRSMQueryBefore(3, rooms[1].JID).ResultSet()).Payload.(*stanza.DiscoItems)
+	if len(items.Items) != 1 || items.Items[0].JID != rooms[0].JID {
+		t.Errorf("unexpected previous page: %+v", items.Items)
+	}
+	// Page by index
+	items = queryDiscoItems(t, r, "", stanza.NewRSMQueryIndex(2, 5).ResultSet()).Payload.(*stanza.DiscoItems)
+	if len(items.Items) != 2 || items.Items[0].JID != rooms[5].JID {
+		t.Errorf("unexpected page at index: %+v", items.Items)
+	}
+	// Count only
+	zero := 0
+	items = queryDiscoItems(t, r, "", &stanza.ResultSet{Max: &zero}).Payload.(*stanza.DiscoItems)
+	if rs = stanza.NewRSMSet(items.ResultSet); len(items.Items) != 0 || rs.Count != 10 {
+		t.Errorf("unexpected count: %+v %+v", items.Items, rs)
+	}
+
+	// The item of the identifier is gone
+	reply := queryDiscoItems(t, r, "", stanza.NewRSMQuery(3, "gone@rooms.example.com").ResultSet())
+	if reply.Error == nil || reply.Error.Reason != "item-not-found" {
+		t.Errorf("unknown identifier should not be found: %+v", reply)
+	}
+}
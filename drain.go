@@ -0,0 +1,118 @@
+package xmpp
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Draining before an intentional disconnection
+
+// drainPriority is the priority advertised while draining: resources with a negative priority do
+// not receive the messages sent to the bare JID of the account (RFC 6121 - 8.5.2.1.1).
+const drainPriority = -1
+
+// Drain prepares a clean handover to another resource of the account before disconnecting. It
+// sends a presence with a negative priority, so that the server routes the new messages to the
+// other resources, waits until no stanza was received for quietPeriod and the handlers of the
+// received stanzas have returned, then disconnects. It returns the number of stanzas handled during
+// the drain. When ctx ends first, the client stays connected and the error of ctx is returned.
+func (c *Client) Drain(ctx context.Context, quietPeriod time.Duration) (int, error) {
+	start := c.inbound.handledCount()
+	pres := stanza.NewPresence(stanza.Attrs{})
+	pres.Priority = drainPriority
+	if err := c.Send(pres); err != nil {
+		return 0, err
+	}
+	err := c.inbound.waitQuiet(ctx, quietPeriod)
+	handled := int(c.inbound.handledCount() - start)
+	if err != nil {
+		return handled, err
+	}
+	return handled, c.Disconnect()
+}
+
+// inboundTracker tracks the stanzas passed to the router, to know when the client is quiet.
+type inboundTracker struct {
+	mu       sync.Mutex
+	inFlight int
+	handled  int64
+	last     time.Time
+	// changed is closed, and replaced, when a stanza is received or handled
+	changed chan struct{}
+}
+
+// received records a stanza passed to the router. done must be called once it is handled.
+func (t *inboundTracker) received() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.inFlight++
+	t.last = time.Now()
+	t.notify()
+}
+
+func (t *inboundTracker) done() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.inFlight--
+	t.handled++
+	t.notify()
+}
+
+// notify wakes up the goroutines waiting for a change. The lock must be held.
+func (t *inboundTracker) notify() {
+	if t.changed != nil {
+		close(t.changed)
+		t.changed = nil
+	}
+}
+
+func (t *inboundTracker) handledCount() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.handled
+}
+
+// waitQuiet waits until no stanza was received for quietPeriod, counted from the call at the
+// earliest, and no stanza is being handled.
+func (t *inboundTracker) waitQuiet(ctx context.Context, quietPeriod time.Duration) error {
+	start := time.Now()
+	for {
+		t.mu.Lock()
+		last := t.last
+		if last.Before(start) {
+			last = start
+		}
+		wait := time.Until(last.Add(quietPeriod))
+		if t.inFlight == 0 && wait <= 0 {
+			t.mu.Unlock()
+			return nil
+		}
+		if t.changed == nil {
+			t.changed = make(chan struct{})
+		}
+		changed := t.changed
+		t.mu.Unlock()
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-changed:
+		case <-timeout:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
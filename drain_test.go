@@ -0,0 +1,42 @@
+package xmpp
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestInboundTrackerWaitQuiet(t *testing.T) {
+	var tracker inboundTracker
+	quiet := 50 * time.Millisecond
+
+	// A stanza is received during the quiet period, and handled after it
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		tracker.received()
+		time.Sleep(100 * time.Millisecond)
+		tracker.done()
+	}()
+
+	start := time.Now()
+	if err := tracker.waitQuiet(context.Background(), quiet); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 120*time.Millisecond {
+		t.Errorf("drain ended before the handler returned: %v", elapsed)
+	}
+	if tracker.handledCount() != 1 {
+		t.Errorf("unexpected handled count: %d", tracker.handledCount())
+	}
+}
+
+func TestInboundTrackerWaitQuietCancel(t *testing.T) {
+	var tracker inboundTracker
+	tracker.received()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if err := tracker.waitQuiet(ctx, 10*time.Millisecond); err != context.DeadlineExceeded {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package xmpp
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Edit history: Last Message Correction (XEP-0308) and Message Attaching (XEP-0367)
+
+const (
+	// editHistoryPageSize is the number of archived messages requested by each query of the history.
+	editHistoryPageSize = 100
+	// editHistoryMaxMessages is the default number of archived messages scanned for the history.
+	editHistoryMaxMessages = 10 * editHistoryPageSize
+)
+
+// ErrEditHistoryTruncated is returned with the history found when the archive has more messages to
+// scan than the MaxMessages of the query.
+var ErrEditHistoryTruncated = errors.New("edit history truncated: too many archived messages")
+
+// EditHistoryQuery identifies the message whose corrections and attachments are fetched from the
+// archive.
+type EditHistoryQuery struct {
+	// Archive is the archive to query, for example a room. The account archive is queried if empty.
+	Archive string
+	// With is the JID of the conversation, when querying the account archive
+	With string
+	// ID is the id of the message, referenced by its corrections and attachments
+	ID string
+	// ArchiveID is the archive id of the message. Only the later messages are fetched.
+	ArchiveID string
+	// From is the sender of the message: corrections sent by someone else are ignored. In a room
+	// archive, it is the occupant JID of the sender.
+	From string
+	// MaxMessages is the maximum number of archived messages scanned after the message, 1000 if
+	// not set. Busy conversations can have many messages after the one that was corrected.
+	MaxMessages int
+}
+
+// GetEditHistory fetches from the archive the corrections and the attachments of a message, in the
+// order they were archived. Corrections of corrections are included, as some clients reference the
+// previous correction instead of the original message. The archive must support the after-id
+// filter, advertised with stanza.NSMamExtended. When MaxMessages archived messages were scanned
+// without reaching the end of the archive, the history found is returned with
+// ErrEditHistoryTruncated.
+func (c *Client) GetEditHistory(ctx context.Context, q EditHistoryQuery) ([]stanza.Message, error) {
+	return getEditHistory(ctx, c, &c.mamQueries, q)
+}
+
+func getEditHistory(ctx context.Context, s Sender, queries *mamQueries, q EditHistoryQuery) ([]stanza.Message, error) {
+	if q.ID == "" || q.ArchiveID == "" {
+		return nil, errors.New("message id and archive id are required to fetch the edit history")
+	}
+
+	// Ids of the message and its corrections
+	ids := map[string]bool{q.ID: true}
+	var history []stanza.Message
+	limit := q.MaxMessages
+	if limit <= 0 {
+		limit = editHistoryMaxMessages
+	}
+	opts := MAMQuery{With: q.With, AfterID: q.ArchiveID, Max: editHistoryPageSize}
+	for scanned := 0; ; {
+		if remaining := limit - scanned; remaining < opts.Max {
+			opts.Max = remaining
+		}
+		iq, queryId, err := newMAMQueryIQ(q.Archive, opts)
+		if err != nil {
+			return nil, err
+		}
+		results, err := queryArchive(ctx, s, queries, iq, queryId)
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range results {
+			msg, ok := result.Forwarded.Message()
+			if !ok {
+				continue
+			}
+			if id := msg.GetReplaceId(); id != "" && ids[id] && q.sentBy(msg.From) {
+				history = append(history, msg)
+				if msg.Id != "" {
+					ids[msg.Id] = true
+				}
+			} else if id := msg.GetAttachToId(); id != "" && ids[id] {
+				history = append(history, msg)
+			}
+		}
+		if len(results) < opts.Max {
+			return history, nil
+		}
+		if scanned += len(results); scanned >= limit {
+			return history, ErrEditHistoryTruncated
+		}
+		opts.After = results[len(results)-1].Id
+	}
+}
+
+// sentBy tells if jid is the sender of the message. Occupants of a room share the bare JID of the
+// room, so their full JID is compared.
+func (q EditHistoryQuery) sentBy(jid string) bool {
+	if q.From == "" {
+		return true
+	}
+	if q.Archive != "" {
+		return strings.EqualFold(jid, q.From)
+	}
+	return strings.EqualFold(bareJid(jid), bareJid(q.From))
+}
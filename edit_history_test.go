@@ -0,0 +1,82 @@
+package xmpp
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestGetEditHistory(t *testing.T) {
+	// Results of the account archive are sent without from attribute
+	server := &mixArchiveServer{t: t, queries: &mamQueries{}, messages: []string{
+		archivedMessage("", "a1", `<message xmlns='jabber:client' from='romeo@montague.example/orchard' to='juliet@capulet.example' id='c1'>
+        <body>But soft, what light through yonder window breaks?</body>
+        <replace xmlns='urn:xmpp:message-correct:0' id='m1'/>
+      </message>`),
+		archivedMessage("", "a2", `<message xmlns='jabber:client' from='juliet@capulet.example/balcony' to='romeo@montague.example' id='r1'>
+        <body>Ay me!</body>
+        <attach-to xmlns='urn:xmpp:message-attaching:1' id='m1'/>
+      </message>`),
+		// Unrelated message
+		archivedMessage("", "a3", `<message xmlns='jabber:client' from='juliet@capulet.example/balcony' to='romeo@montague.example' id='m2'>
+        <body>O Romeo, Romeo!</body>
+      </message>`),
+		// Corrections can only be sent by the sender of the message
+		archivedMessage("", "a4", `<message xmlns='jabber:client' from='juliet@capulet.example/balcony' to='romeo@montague.example' id='c2'>
+        <body>Spoofed</body>
+        <replace xmlns='urn:xmpp:message-correct:0' id='m1'/>
+      </message>`),
+		// Correction of the previous correction
+		archivedMessage("", "a5", `<message xmlns='jabber:client' from='romeo@montague.example/orchard' to='juliet@capulet.example' id='c3'>
+        <body>But soft, what light through yonder window breaks? It is the east.</body>
+        <replace xmlns='urn:xmpp:message-correct:0' id='c1'/>
+      </message>`),
+	}}
+
+	history, err := getEditHistory(context.Background(), server, server.queries, EditHistoryQuery{
+		With: "juliet@capulet.example", ID: "m1", ArchiveID: "a0", From: "romeo@montague.example/orchard",
+	})
+	if err != nil {
+		t.Fatalf("cannot get edit history: %v", err)
+	}
+	var ids []string
+	for _, msg := range history {
+		ids = append(ids, msg.Id)
+	}
+	if len(ids) != 3 || ids[0] != "c1" || ids[1] != "r1" || ids[2] != "c3" {
+		t.Errorf("unexpected history: %v", ids)
+	}
+
+	fields := map[string]string{}
+	for _, f := range server.query.Filter.Fields {
+		fields[f.Var] = f.Value()
+	}
+	if fields["with"] != "juliet@capulet.example" || fields["after-id"] != "a0" {
+		t.Errorf("unexpected query filters: %v", fields)
+	}
+}
+
+func TestGetEditHistoryLimit(t *testing.T) {
+	server := &mixArchiveServer{t: t, queries: &mamQueries{}, messages: []string{
+		archivedMessage("", "a1", `<message xmlns='jabber:client' from='romeo@montague.example/orchard' to='juliet@capulet.example' id='c1'>
+        <body>But soft, what light through yonder window breaks?</body>
+        <replace xmlns='urn:xmpp:message-correct:0' id='m1'/>
+      </message>`),
+		archivedMessage("", "a2", `<message xmlns='jabber:client' from='juliet@capulet.example/balcony' to='romeo@montague.example' id='m2'>
+        <body>O Romeo, Romeo!</body>
+      </message>`),
+	}}
+
+	history, err := getEditHistory(context.Background(), server, server.queries, EditHistoryQuery{
+		With: "juliet@capulet.example", ID: "m1", ArchiveID: "a0", MaxMessages: 2,
+	})
+	if !errors.Is(err, ErrEditHistoryTruncated) {
+		t.Fatalf("expected truncated history, got %v", err)
+	}
+	if len(history) != 1 || history[0].Id != "c1" {
+		t.Errorf("history found should be returned: %+v", history)
+	}
+	if server.query.ResultSet == nil || server.query.ResultSet.Max == nil || *server.query.ResultSet.Max != 2 {
+		t.Errorf("page should not exceed the limit: %+v", server.query.ResultSet)
+	}
+}
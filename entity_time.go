@@ -0,0 +1,242 @@
+package xmpp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Entity Time (XEP-0202)
+
+// ErrUnknownTimezone is returned by ResolveTimezone when no known timezone currently uses the offset.
+var ErrUnknownTimezone = errors.New("no timezone matching the offset")
+
+// EntityTime is the time of an entity. Location is the timezone resolved from the offset, or a
+// fixed zone named after the offset when it could not be resolved.
+type EntityTime struct {
+	UTC      time.Time
+	TZO      string
+	Location *time.Location
+}
+
+// Local returns the time of the entity in its timezone.
+func (t EntityTime) Local() time.Time {
+	return t.UTC.In(t.Location)
+}
+
+// EntityTimeOption configures a GetEntityTime query.
+type EntityTimeOption func(q *entityTimeQuery)
+
+type entityTimeQuery struct {
+	country string
+}
+
+// WithTimezoneHint restricts the timezone resolution to the timezones of the country, given as an
+// ISO 3166 alpha-2 code such as "KR". When the country has no timezone matching the offset, the
+// hint is ignored.
+func WithTimezoneHint(country string) EntityTimeOption {
+	return func(q *entityTimeQuery) {
+		q.country = strings.ToUpper(country)
+	}
+}
+
+// GetEntityTime queries the time of the entity, usually a full JID, and resolves its timezone.
+func (c *Client) GetEntityTime(ctx context.Context, jid string, opts ...EntityTimeOption) (EntityTime, error) {
+	return getEntityTime(ctx, c, jid, opts)
+}
+
+// ResolveTimezone returns the canonical timezone currently using the UTC offset tzo, given as
+// "+05:30", "-08:00" or "Z". As several timezones share the same offset, the most populated one
+// is returned: "+09:00" resolves to Asia/Tokyo rather than Asia/Seoul.
+//
+// Timezones are loaded from the system database. Programs running on systems without it can embed
+// the database in their binary by importing the time/tzdata package.
+func ResolveTimezone(tzo string) (*time.Location, error) {
+	return resolveTimezone(tzo, time.Now(), "")
+}
+
+func getEntityTime(ctx context.Context, s Sender, jid string, opts []EntityTimeOption) (EntityTime, error) {
+	var q entityTimeQuery
+	for _, opt := range opts {
+		opt(&q)
+	}
+
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, To: jid})
+	if err != nil {
+		return EntityTime{}, err
+	}
+	iq.EntityTime()
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return EntityTime{}, err
+	}
+	if err = iqError(result); err != nil {
+		return EntityTime{}, err
+	}
+	payload, ok := result.Payload.(*stanza.EntityTime)
+	if !ok {
+		return EntityTime{}, errors.New("invalid entity time response")
+	}
+
+	utc, err := time.Parse(time.RFC3339Nano, payload.UTC)
+	if err != nil {
+		return EntityTime{}, fmt.Errorf("invalid entity time: %w", err)
+	}
+	offset, err := parseTZO(payload.TZO)
+	if err != nil {
+		return EntityTime{}, err
+	}
+	loc, err := resolveTimezone(payload.TZO, utc, q.country)
+	if err != nil {
+		loc = time.FixedZone(payload.TZO, offset)
+	}
+	return EntityTime{UTC: utc.UTC(), TZO: payload.TZO, Location: loc}, nil
+}
+
+// resolveTimezone returns the first candidate timezone using the offset at the given time,
+// preferring the timezones of the country when there is one.
+func resolveTimezone(tzo string, at time.Time, country string) (*time.Location, error) {
+	offset, err := parseTZO(tzo)
+	if err != nil {
+		return nil, err
+	}
+	var fallback *time.Location
+	for _, c := range timezoneCandidates {
+		if fallback != nil && (country == "" || c.country != country) {
+			continue
+		}
+		loc, err := time.LoadLocation(c.name)
+		if err != nil {
+			continue
+		}
+		if _, o := at.In(loc).Zone(); o != offset {
+			continue
+		}
+		if country == "" || c.country == country {
+			return loc, nil
+		}
+		fallback = loc
+	}
+	if fallback == nil {
+		return nil, ErrUnknownTimezone
+	}
+	return fallback, nil
+}
+
+// parseTZO returns the offset in seconds of a XEP-0082 time zone definition.
+func parseTZO(tzo string) (int, error) {
+	if tzo == "Z" {
+		return 0, nil
+	}
+	invalid := fmt.Errorf("invalid timezone offset %q", tzo)
+	if len(tzo) != 6 || (tzo[0] != '+' && tzo[0] != '-') || tzo[3] != ':' {
+		return 0, invalid
+	}
+	hours, err := strconv.Atoi(tzo[1:3])
+	if err != nil {
+		return 0, invalid
+	}
+	minutes, err := strconv.Atoi(tzo[4:6])
+	if err != nil || hours > 14 || minutes > 59 {
+		return 0, invalid
+	}
+	offset := hours*3600 + minutes*60
+	if tzo[0] == '-' {
+		offset = -offset
+	}
+	return offset, nil
+}
+
+// timezoneCandidates are the timezones offsets are resolved to, with their ISO 3166 country code.
+// When several timezones use the same offset at the same time, the first one is the canonical
+// timezone for that offset, so they are ordered by decreasing relevance.
+var timezoneCandidates = []struct{ name, country string }{
+	{"UTC", ""},
+	{"Europe/London", "GB"},
+	{"Europe/Dublin", "IE"},
+	{"Europe/Lisbon", "PT"},
+	{"Africa/Abidjan", "CI"},
+	{"Europe/Berlin", "DE"},
+	{"Europe/Paris", "FR"},
+	{"Europe/Madrid", "ES"},
+	{"Europe/Rome", "IT"},
+	{"Europe/Warsaw", "PL"},
+	{"Africa/Lagos", "NG"},
+	{"Africa/Algiers", "DZ"},
+	{"Africa/Cairo", "EG"},
+	{"Africa/Johannesburg", "ZA"},
+	{"Europe/Moscow", "RU"},
+	{"Europe/Istanbul", "TR"},
+	{"Europe/Athens", "GR"},
+	{"Europe/Helsinki", "FI"},
+	{"Asia/Jerusalem", "IL"},
+	{"Asia/Riyadh", "SA"},
+	{"Africa/Nairobi", "KE"},
+	{"Asia/Tehran", "IR"},
+	{"Asia/Dubai", "AE"},
+	{"Asia/Baku", "AZ"},
+	{"Asia/Kabul", "AF"},
+	{"Asia/Karachi", "PK"},
+	{"Asia/Tashkent", "UZ"},
+	{"Asia/Yekaterinburg", "RU"},
+	{"Asia/Kolkata", "IN"},
+	{"Asia/Colombo", "LK"},
+	{"Asia/Kathmandu", "NP"},
+	{"Asia/Dhaka", "BD"},
+	{"Asia/Yangon", "MM"},
+	{"Asia/Bangkok", "TH"},
+	{"Asia/Jakarta", "ID"},
+	{"Asia/Ho_Chi_Minh", "VN"},
+	{"Asia/Shanghai", "CN"},
+	{"Asia/Hong_Kong", "HK"},
+	{"Asia/Taipei", "TW"},
+	{"Asia/Singapore", "SG"},
+	{"Asia/Manila", "PH"},
+	{"Australia/Perth", "AU"},
+	{"Asia/Tokyo", "JP"},
+	{"Asia/Seoul", "KR"},
+	{"Australia/Darwin", "AU"},
+	{"Australia/Adelaide", "AU"},
+	{"Australia/Sydney", "AU"},
+	{"Australia/Brisbane", "AU"},
+	{"Asia/Vladivostok", "RU"},
+	{"Pacific/Noumea", "NC"},
+	{"Pacific/Auckland", "NZ"},
+	{"Pacific/Fiji", "FJ"},
+	{"Pacific/Chatham", "NZ"},
+	{"Pacific/Tongatapu", "TO"},
+	{"Pacific/Kiritimati", "KI"},
+	{"America/Chicago", "US"},
+	{"America/New_York", "US"},
+	{"America/Denver", "US"},
+	{"America/Los_Angeles", "US"},
+	{"America/Phoenix", "US"},
+	{"America/Anchorage", "US"},
+	{"Pacific/Honolulu", "US"},
+	{"America/Sao_Paulo", "BR"},
+	{"America/Argentina/Buenos_Aires", "AR"},
+	{"America/Mexico_City", "MX"},
+	{"America/Bogota", "CO"},
+	{"America/Lima", "PE"},
+	{"America/Toronto", "CA"},
+	{"America/Winnipeg", "CA"},
+	{"America/Edmonton", "CA"},
+	{"America/Vancouver", "CA"},
+	{"America/Halifax", "CA"},
+	{"America/St_Johns", "CA"},
+	{"America/Tijuana", "MX"},
+	{"America/Guatemala", "GT"},
+	{"America/Caracas", "VE"},
+	{"America/La_Paz", "BO"},
+	{"America/Santiago", "CL"},
+	{"Atlantic/Cape_Verde", "CV"},
+	{"Atlantic/Azores", "PT"},
+	{"Atlantic/South_Georgia", "GS"},
+	{"Pacific/Pago_Pago", "AS"},
+}
@@ -0,0 +1,75 @@
+package xmpp
+
+import (
+	"context"
+	"testing"
+	"time"
+	// Tests do not depend on the timezone database of the system
+	_ "time/tzdata"
+)
+
+func TestResolveTimezone(t *testing.T) {
+	winter := time.Date(2026, time.January, 15, 12, 0, 0, 0, time.UTC)
+	summer := time.Date(2026, time.July, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		tzo  string
+		at   time.Time
+		want string
+	}{
+		{"+05:30", winter, "Asia/Kolkata"},
+		{"+05:45", winter, "Asia/Kathmandu"},
+		{"+08:00", summer, "Asia/Shanghai"},
+		{"+09:00", winter, "Asia/Tokyo"},
+		{"-03:00", winter, "America/Sao_Paulo"},
+		{"Z", summer, "UTC"},
+		// Offsets of timezones observing daylight saving time depend on the date
+		{"-05:00", winter, "America/New_York"},
+		{"-05:00", summer, "America/Chicago"},
+	}
+	for _, tt := range tests {
+		loc, err := resolveTimezone(tt.tzo, tt.at, "")
+		if err != nil {
+			t.Errorf("could not resolve %s: %v", tt.tzo, err)
+			continue
+		}
+		if loc.String() != tt.want {
+			t.Errorf("%s resolved to %s, expected %s", tt.tzo, loc, tt.want)
+		}
+	}
+
+	// The hint resolves the ambiguity between timezones using the same offset
+	loc, err := resolveTimezone("+09:00", winter, "KR")
+	if err != nil || loc.String() != "Asia/Seoul" {
+		t.Errorf("+09:00 in Korea should resolve to Asia/Seoul: %v, %v", loc, err)
+	}
+	// Hints without matching timezone are ignored
+	loc, err = resolveTimezone("+05:30", winter, "KR")
+	if err != nil || loc.String() != "Asia/Kolkata" {
+		t.Errorf("hint should be ignored: %v, %v", loc, err)
+	}
+
+	if _, err = resolveTimezone("+14:45", winter, ""); err != ErrUnknownTimezone {
+		t.Errorf("unexpected error for unknown offset: %v", err)
+	}
+	if _, err = resolveTimezone("0530", winter, ""); err == nil {
+		t.Errorf("invalid offset should be rejected")
+	}
+}
+
+func TestGetEntityTime(t *testing.T) {
+	response := `<iq type='result' from='juliet@capulet.com/balcony'>
+  <time xmlns='urn:xmpp:time'>
+    <tzo>+09:00</tzo>
+    <utc>2026-01-15T03:30:00Z</utc>
+  </time>
+</iq>`
+	sender := &scriptedIQSender{t: t, responses: []string{response}}
+	et, err := getEntityTime(context.Background(), sender, "juliet@capulet.com/balcony",
+		[]EntityTimeOption{WithTimezoneHint("kr")})
+	if err != nil {
+		t.Fatalf("could not get entity time: %v", err)
+	}
+	if et.Location.String() != "Asia/Seoul" || et.Local().Hour() != 12 {
+		t.Errorf("unexpected entity time: %v in %v", et.Local(), et.Location)
+	}
+}
@@ -0,0 +1,237 @@
+package xmpp
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Failover between interchangeable servers
+
+// defaultEndpointFailureThreshold is the number of consecutive failed reconnections to an endpoint
+// before failing over to the next one, when the threshold is not set.
+const defaultEndpointFailureThreshold = 3
+
+// ErrNoFailoverEndpoint is returned by Failover when the client has a single endpoint.
+var ErrNoFailoverEndpoint = errors.New("no other endpoint to fail over to")
+
+// ErrFailover is the error of the disconnection event sent when the client is disconnected by
+// Failover.
+var ErrFailover = errors.New("failover to the next endpoint")
+
+// WithEndpoints sets the addresses of interchangeable servers the client connects to, in order of
+// preference. They replace the address of the transport configuration.
+func WithEndpoints(addresses ...string) Option {
+	return func(config *Config) {
+		config.Endpoints = addresses
+	}
+}
+
+// EndpointHealth describes the recent connections to an endpoint.
+type EndpointHealth struct {
+	Address string
+	// ConsecutiveFailures is the number of connections to the endpoint that failed since the last
+	// successful one
+	ConsecutiveFailures int
+	LastSuccess         time.Time
+	LastFailure         time.Time
+	// LastError is the error of the last failed connection
+	LastError error
+}
+
+type endpoint struct {
+	health EndpointHealth
+	// backoff delays the reconnections to the endpoint
+	backoff backoff
+}
+
+// endpointPool holds the endpoints of a client, with the one used to connect. The endpoint of the
+// last successful connection is kept for the next ones, until it fails repeatedly.
+type endpointPool struct {
+	mu        sync.Mutex
+	endpoints []*endpoint
+	current   int
+	// failures is the number of consecutive failed reconnections to the current endpoint
+	failures  int
+	threshold int
+	// failingOver is set when the connection is closed by Failover
+	failingOver bool
+}
+
+func newEndpointPool(addresses []string, threshold int) *endpointPool {
+	if threshold <= 0 {
+		threshold = defaultEndpointFailureThreshold
+	}
+	p := &endpointPool{threshold: threshold}
+	for _, address := range addresses {
+		p.endpoints = append(p.endpoints, &endpoint{health: EndpointHealth{Address: address}})
+	}
+	return p
+}
+
+func (p *endpointPool) len() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.endpoints)
+}
+
+// address returns the address of the current endpoint.
+func (p *endpointPool) address() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.endpoints[p.current].health.Address
+}
+
+// succeeded records a successful connection to the current endpoint.
+func (p *endpointPool) succeeded() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e := p.endpoints[p.current]
+	e.health.ConsecutiveFailures = 0
+	e.health.LastSuccess = time.Now()
+	e.backoff.reset()
+	p.failures = 0
+}
+
+// failed records a failed connection to the current endpoint. With rotate, or once the reconnections
+// to the endpoint failed as many times in a row as the threshold, the next endpoint becomes current.
+func (p *endpointPool) failed(err error, rotate bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e := p.endpoints[p.current]
+	e.health.ConsecutiveFailures++
+	e.health.LastFailure = time.Now()
+	e.health.LastError = err
+	p.failures++
+	if rotate || p.failures >= p.threshold {
+		p.next()
+	}
+}
+
+// rotate makes the next endpoint current.
+func (p *endpointPool) rotate() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.next()
+}
+
+func (p *endpointPool) next() {
+	p.current = (p.current + 1) % len(p.endpoints)
+	p.failures = 0
+}
+
+// wait sleeps before reconnecting to the current endpoint, with the backoff of the endpoint.
+func (p *endpointPool) wait() {
+	p.mu.Lock()
+	d := p.endpoints[p.current].backoff.duration()
+	p.mu.Unlock()
+	time.Sleep(d)
+}
+
+func (p *endpointPool) health() []EndpointHealth {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	health := make([]EndpointHealth, len(p.endpoints))
+	for i, e := range p.endpoints {
+		health[i] = e.health
+	}
+	return health
+}
+
+func (p *endpointPool) setFailingOver(failingOver bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failingOver = failingOver
+}
+
+// takeFailover tells if the connection was closed by Failover, and clears the flag.
+func (p *endpointPool) takeFailover() bool {
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	failingOver := p.failingOver
+	p.failingOver = false
+	return failingOver
+}
+
+// Endpoints returns the health of the endpoints of the client, in order of preference.
+func (c *Client) Endpoints() []EndpointHealth {
+	return c.endpoints.health()
+}
+
+// CurrentEndpoint returns the address of the endpoint the client is connected to, or connects to next.
+func (c *Client) CurrentEndpoint() string {
+	if c.endpoints.len() == 0 {
+		return c.config.Address
+	}
+	return c.endpoints.address()
+}
+
+// Failover makes the next endpoint current, for example before the maintenance of the server the
+// client is connected to. An established session is closed: the client is then notified of the
+// disconnection with ErrFailover, so that a StreamManager reconnects to the new endpoint.
+func (c *Client) Failover() error {
+	if c.endpoints.len() < 2 {
+		return ErrNoFailoverEndpoint
+	}
+	c.endpoints.rotate()
+	if c.CurrentState.getState() != StateSessionEstablished {
+		return nil
+	}
+	c.endpoints.setFailingOver(true)
+	return c.Disconnect()
+}
+
+// connectEndpoints connects to the current endpoint, or to the next ones in turn when it fails, until
+// a session is established.
+func (c *Client) connectEndpoints() error {
+	if c.endpoints.len() == 0 {
+		return c.connect()
+	}
+	var err error
+	for i := 0; i < c.endpoints.len(); i++ {
+		c.useEndpoint()
+		if err = c.connect(); err == nil {
+			c.endpoints.succeeded()
+			return nil
+		}
+		c.endpoints.failed(err, true)
+	}
+	return err
+}
+
+// reconnectEndpoint reconnects to the current endpoint. The next endpoint becomes current when the
+// reconnections failed repeatedly.
+func (c *Client) reconnectEndpoint() error {
+	if c.endpoints.len() == 0 {
+		return c.connect()
+	}
+	c.useEndpoint()
+	err := c.connect()
+	if err != nil {
+		c.endpoints.failed(err, false)
+		return err
+	}
+	c.endpoints.succeeded()
+	return nil
+}
+
+// useEndpoint sets up the transport to connect to the current endpoint.
+func (c *Client) useEndpoint() {
+	address := c.endpoints.address()
+	if address == c.config.Address && c.transport != nil {
+		return
+	}
+	c.config.Address = address
+	c.transport = NewClientTransport(c.config.TransportConfiguration)
+	if c.config.StreamLogger != nil {
+		c.transport.LogTraffic(c.config.StreamLogger)
+	}
+}
@@ -0,0 +1,149 @@
+package xmpp
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestEndpointPoolRotation(t *testing.T) {
+	pool := newEndpointPool([]string{"a:5222", "b:5222", "c:5222"}, 2)
+	errDown := errors.New("down")
+
+	// Reconnections fail over once the threshold is reached
+	pool.failed(errDown, false)
+	if pool.address() != "a:5222" {
+		t.Errorf("endpoint changed before the threshold: %s", pool.address())
+	}
+	pool.failed(errDown, false)
+	if pool.address() != "b:5222" {
+		t.Errorf("endpoint did not fail over: %s", pool.address())
+	}
+
+	// The endpoint of the last success is kept
+	pool.succeeded()
+	pool.failed(errDown, false)
+	pool.succeeded()
+	if pool.address() != "b:5222" {
+		t.Errorf("endpoint changed after a success: %s", pool.address())
+	}
+
+	health := pool.health()
+	if health[0].ConsecutiveFailures != 2 || health[0].LastError != errDown || !health[0].LastSuccess.IsZero() {
+		t.Errorf("unexpected health of the failed endpoint: %+v", health[0])
+	}
+	if health[1].ConsecutiveFailures != 0 || health[1].LastSuccess.IsZero() || health[1].LastFailure.IsZero() {
+		t.Errorf("unexpected health of the current endpoint: %+v", health[1])
+	}
+
+	// Rotation wraps around
+	pool.rotate()
+	pool.failed(errDown, true)
+	if pool.address() != "a:5222" {
+		t.Errorf("endpoint did not wrap around: %s", pool.address())
+	}
+}
+
+func TestClientConnectFailover(t *testing.T) {
+	mock := &ServerMock{}
+	down := fmt.Sprintf("%s:%d", testClientDomain, testClientFailoverDownPort)
+	up := fmt.Sprintf("%s:%d", testClientDomain, testClientFailoverPort)
+	mock.Start(t, up, handlerClientConnectSuccess)
+	defer mock.Stop()
+
+	config := Config{
+		Jid:        "test@localhost",
+		Credential: Password("test"),
+		Insecure:   true,
+	}
+	client, err := NewClient(&config, NewRouter(), clientDefaultErrorHandler, WithEndpoints(down, up))
+	if err != nil {
+		t.Fatalf("cannot create XMPP client: %s", err)
+	}
+	if client.CurrentEndpoint() != down {
+		t.Errorf("client should start with the first endpoint, got %s", client.CurrentEndpoint())
+	}
+
+	if err = client.Connect(); err != nil {
+		t.Fatalf("XMPP connection failed: %s", err)
+	}
+	if client.CurrentEndpoint() != up {
+		t.Errorf("client should be connected to the second endpoint, got %s", client.CurrentEndpoint())
+	}
+	health := client.Endpoints()
+	if len(health) != 2 {
+		t.Fatalf("unexpected endpoints: %+v", health)
+	}
+	if health[0].Address != down || health[0].ConsecutiveFailures != 1 || health[0].LastError == nil {
+		t.Errorf("unexpected health of the unavailable endpoint: %+v", health[0])
+	}
+	if health[1].Address != up || health[1].ConsecutiveFailures != 0 || health[1].LastSuccess.IsZero() {
+		t.Errorf("unexpected health of the available endpoint: %+v", health[1])
+	}
+}
+
+func TestClientForcedFailover(t *testing.T) {
+	first := fmt.Sprintf("%s:%d", testClientDomain, testClientForcedFailoverPort)
+	second := fmt.Sprintf("%s:%d", testClientDomain, testClientForcedFailoverNextPort)
+	mock1 := &ServerMock{}
+	mock1.Start(t, first, func(t *testing.T, sc *ServerConn) {
+		handlerClientConnectSuccess(t, sc)
+		closeConn(t, sc)
+	})
+	defer mock1.Stop()
+	mock2 := &ServerMock{}
+	mock2.Start(t, second, handlerClientConnectSuccess)
+	defer mock2.Stop()
+
+	config := Config{
+		Jid:        "test@localhost",
+		Credential: Password("test"),
+		Insecure:   true,
+		Endpoints:  []string{first, second},
+	}
+	client, err := NewClient(&config, NewRouter(), clientDefaultErrorHandler)
+	if err != nil {
+		t.Fatalf("cannot create XMPP client: %s", err)
+	}
+	disconnected := make(chan error, 1)
+	client.SetHandler(func(e Event) error {
+		if e.State.getState() == StateDisconnected {
+			disconnected <- e.Err
+		}
+		return nil
+	})
+	if err = client.Connect(); err != nil {
+		t.Fatalf("XMPP connection failed: %s", err)
+	}
+
+	if err = client.Failover(); err != nil {
+		t.Fatalf("failover failed: %s", err)
+	}
+	select {
+	case err = <-disconnected:
+		if !errors.Is(err, ErrFailover) {
+			t.Errorf("unexpected disconnection error: %v", err)
+		}
+	case <-time.After(defaultChannelTimeout):
+		t.Fatalf("client was not disconnected")
+	}
+	if client.CurrentEndpoint() != second {
+		t.Errorf("client should fail over to the second endpoint, got %s", client.CurrentEndpoint())
+	}
+
+	// Reconnection uses the new endpoint
+	if err = client.Resume(); err != nil {
+		t.Fatalf("reconnection failed: %s", err)
+	}
+	if health := client.Endpoints(); health[1].LastSuccess.IsZero() {
+		t.Errorf("client did not reconnect to the second endpoint: %+v", health)
+	}
+}
+
+func TestClientFailoverSingleEndpoint(t *testing.T) {
+	client := &Client{config: &Config{}, endpoints: newEndpointPool([]string{"localhost:5222"}, 0)}
+	if err := client.Failover(); !errors.Is(err, ErrNoFailoverEndpoint) {
+		t.Errorf("unexpected failover error: %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package xmpp
+
+import (
+	"context"
+	"errors"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Gateway Interaction (XEP-0100)
+
+// GatewayInfo describes a gateway to a legacy network, found with service discovery.
+type GatewayInfo struct {
+	JID  string
+	Name string
+	// Type is the legacy network of the gateway, for example "irc" or "sms"
+	Type string
+	// Translates tells if the gateway translates legacy identifiers with PromptAndTranslate
+	Translates bool
+}
+
+// GatewayPrompt is the description of the legacy identifiers a gateway translates into JIDs.
+type GatewayPrompt struct {
+	Desc string
+	// Prompt is the name of the legacy identifier, for example "Contact ID"
+	Prompt string
+}
+
+// DiscoverGateways returns the gateways among the items of the service, usually the server of the
+// account. Items that cannot be queried are skipped.
+func (c *Client) DiscoverGateways(ctx context.Context, service string) ([]GatewayInfo, error) {
+	return discoverGateways(ctx, c, service)
+}
+
+// GetGatewayPrompt requests the description of the legacy identifiers translated by the gateway, to
+// ask the user for one.
+func (c *Client) GetGatewayPrompt(ctx context.Context, gateway string) (GatewayPrompt, error) {
+	return getGatewayPrompt(ctx, c, gateway)
+}
+
+// PromptAndTranslate translates a legacy identifier into the JID addressing it through the gateway.
+// The prompt is requested first, as required by the protocol.
+func (c *Client) PromptAndTranslate(ctx context.Context, gateway, identifier string) (string, error) {
+	return promptAndTranslate(ctx, c, gateway, identifier)
+}
+
+func discoverGateways(ctx context.Context, s Sender, service string) ([]GatewayInfo, error) {
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, To: service})
+	if err != nil {
+		return nil, err
+	}
+	iq.DiscoItems()
+
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return nil, err
+	}
+	if err = iqError(result); err != nil {
+		return nil, err
+	}
+	items, ok := result.Payload.(*stanza.DiscoItems)
+	if !ok {
+		return nil, errors.New("invalid service items response")
+	}
+
+	var gateways []GatewayInfo
+	for _, item := range items.Items {
+		if item.JID == "" || item.Node != "" {
+			continue
+		}
+		gateway, ok, err := getGatewayInfo(ctx, s, item.JID)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err == nil && ok {
+			gateways = append(gateways, gateway)
+		}
+	}
+	return gateways, nil
+}
+
+// getGatewayInfo queries the information of the JID, and returns false if it is not a gateway.
+func getGatewayInfo(ctx context.Context, s Sender, jid string) (GatewayInfo, bool, error) {
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, To: jid})
+	if err != nil {
+		return GatewayInfo{}, false, err
+	}
+	iq.DiscoInfo()
+
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return GatewayInfo{}, false, err
+	}
+	if err = iqError(result); err != nil {
+		return GatewayInfo{}, false, err
+	}
+	info, ok := result.Payload.(*stanza.DiscoInfo)
+	if !ok {
+		return GatewayInfo{}, false, errors.New("invalid gateway info response")
+	}
+	for _, identity := range info.Identity {
+		if identity.Category == "gateway" {
+			return GatewayInfo{
+				JID:        jid,
+				Name:       identity.Name,
+				Type:       identity.Type,
+				Translates: info.HasFeature(stanza.NSGateway),
+			}, true, nil
+		}
+	}
+	return GatewayInfo{}, false, nil
+}
+
+func getGatewayPrompt(ctx context.Context, s Sender, gateway string) (GatewayPrompt, error) {
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, To: gateway})
+	if err != nil {
+		return GatewayPrompt{}, err
+	}
+	iq.Gateway("")
+
+	res, err := sendGatewayIQ(ctx, s, iq)
+	if err != nil {
+		return GatewayPrompt{}, err
+	}
+	return GatewayPrompt{Desc: res.Desc, Prompt: res.Prompt}, nil
+}
+
+func promptAndTranslate(ctx context.Context, s Sender, gateway, identifier string) (string, error) {
+	if _, err := getGatewayPrompt(ctx, s, gateway); err != nil {
+		return "", err
+	}
+
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeSet, To: gateway})
+	if err != nil {
+		return "", err
+	}
+	iq.Gateway(identifier)
+
+	res, err := sendGatewayIQ(ctx, s, iq)
+	if err != nil {
+		return "", err
+	}
+	// Older gateways return the JID in the prompt element
+	jid := res.JID
+	if jid == "" {
+		jid = res.Prompt
+	}
+	if jid == "" {
+		return "", errors.New("gateway did not return a JID")
+	}
+	return jid, nil
+}
+
+func sendGatewayIQ(ctx context.Context, s Sender, iq *stanza.IQ) (*stanza.Gateway, error) {
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return nil, err
+	}
+	if err = iqError(result); err != nil {
+		return nil, err
+	}
+	res, ok := result.Payload.(*stanza.Gateway)
+	if !ok {
+		return nil, errors.New("invalid gateway response")
+	}
+	return res, nil
+}
@@ -0,0 +1,67 @@
+package xmpp
+
+import (
+	"context"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestDiscoverGateways(t *testing.T) {
+	sender := &scriptedIQSender{t: t, responses: []string{
+		`<iq type='result' from='shakespeare.lit'>
+  <query xmlns='http://jabber.org/protocol/disco#items'>
+    <item jid='conference.shakespeare.lit'/>
+    <item jid='irc.shakespeare.lit'/>
+  </query>
+</iq>`,
+		`<iq type='result' from='conference.shakespeare.lit'>
+  <query xmlns='http://jabber.org/protocol/disco#info'>
+    <identity category='conference' type='text' name='Chatrooms'/>
+  </query>
+</iq>`,
+		`<iq type='result' from='irc.shakespeare.lit'>
+  <query xmlns='http://jabber.org/protocol/disco#info'>
+    <identity category='gateway' type='irc' name='IRC Gateway'/>
+    <feature var='jabber:iq:gateway'/>
+  </query>
+</iq>`,
+	}}
+
+	gateways, err := discoverGateways(context.Background(), sender, "shakespeare.lit")
+	if err != nil {
+		t.Fatalf("could not discover gateways: %v", err)
+	}
+	if len(gateways) != 1 || gateways[0] != (GatewayInfo{JID: "irc.shakespeare.lit", Name: "IRC Gateway", Type: "irc", Translates: true}) {
+		t.Errorf("unexpected gateways: %+v", gateways)
+	}
+}
+
+func TestPromptAndTranslate(t *testing.T) {
+	sender := &scriptedIQSender{t: t, responses: []string{
+		`<iq type='result' from='irc.shakespeare.lit'>
+  <query xmlns='jabber:iq:gateway'>
+    <desc>Please enter the nick and network of the person you would like to contact</desc>
+    <prompt>Nick%Network</prompt>
+  </query>
+</iq>`,
+		`<iq type='result' from='irc.shakespeare.lit'>
+  <query xmlns='jabber:iq:gateway'>
+    <jid>juliet%irc.libera.chat@irc.shakespeare.lit</jid>
+  </query>
+</iq>`,
+	}}
+
+	jid, err := promptAndTranslate(context.Background(), sender, "irc.shakespeare.lit", "juliet%irc.libera.chat")
+	if err != nil {
+		t.Fatalf("could not translate identifier: %v", err)
+	}
+	if jid != "juliet%irc.libera.chat@irc.shakespeare.lit" {
+		t.Errorf("unexpected JID: %s", jid)
+	}
+	set := sender.requests[1]
+	g, ok := set.Payload.(*stanza.Gateway)
+	if set.Type != stanza.IQTypeSet || !ok || g.Prompt != "juliet%irc.libera.chat" {
+		t.Errorf("unexpected translation request: %+v", set)
+	}
+}
@@ -1,6 +1,6 @@
 module gosrc.io/xmpp
 
-go 1.13
+go 1.18
 
 require (
 	github.com/google/go-cmp v0.3.1
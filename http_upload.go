@@ -0,0 +1,215 @@
+package xmpp
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// HTTP File Upload (XEP-0363)
+
+// ErrCertificatePinMismatch is returned when the certificate of an upload endpoint does not match
+// HTTPUploadOptions.PinnedCert.
+var ErrCertificatePinMismatch = errors.New("upload endpoint certificate does not match the pinned certificate")
+
+// HTTPUploadOptions configures the HTTP client used to upload files. Upload endpoints are often
+// hosted on another host than the XMPP server, so their certificate is checked separately.
+type HTTPUploadOptions struct {
+	// PinnedCert is the hex encoded SHA-256 fingerprint of the certificate expected from upload
+	// endpoints. When set, it replaces the verification of the certificate chain: endpoints presenting
+	// another certificate are rejected, even if it is signed by a trusted CA. Colons are ignored.
+	PinnedCert string
+	// MaxRetries is the number of times a failed upload is retried. Uploads rejected by the server with
+	// a client error, or because of the certificate, are not retried.
+	MaxRetries int
+	// UploadTimeout bounds each HTTP request. There is no timeout other than the context by default.
+	UploadTimeout time.Duration
+}
+
+// WithHTTPUploadOptions sets the options of the HTTP client used to verify upload slots and
+// upload files.
+func WithHTTPUploadOptions(opts HTTPUploadOptions) Option {
+	return func(config *Config) {
+		config.HTTPUpload = opts
+	}
+}
+
+// UploadSlot is a slot allocated by an upload service for a file. The file is uploaded to PutURL,
+// with PutHeaders, and can then be shared with GetURL.
+type UploadSlot struct {
+	PutURL      string
+	PutHeaders  http.Header
+	GetURL      string
+	Size        int64
+	ContentType string
+}
+
+// Headers allowed in the PUT request.
+// See XEP-0363 - 4. Requesting a slot
+var uploadPutHeaders = map[string]bool{"Authorization": true, "Cookie": true, "Expires": true}
+
+// RequestUploadSlot requests a slot from the upload service to upload a file. When a certificate is
+// pinned, the upload endpoint is contacted with a HEAD request to verify its certificate, before
+// returning the slot.
+func (c *Client) RequestUploadSlot(ctx context.Context, service, filename string, size int64, contentType string) (UploadSlot, error) {
+	return requestUploadSlot(ctx, c, c.httpUpload, c.config.HTTPUpload, service, filename, size, contentType)
+}
+
+// UploadFile uploads the content of the file to the slot, with the HTTP client configured by
+// HTTPUploadOptions. The body is read again from its start when the upload is retried.
+func (c *Client) UploadFile(ctx context.Context, slot UploadSlot, body io.ReadSeeker) error {
+	return uploadFile(ctx, c.httpUpload, c.config.HTTPUpload, slot, body)
+}
+
+// newHTTPUploadClient returns the HTTP client configured by the options. The certificates of the
+// endpoints are verified with roots, or the system roots when nil, unless a certificate is pinned.
+func newHTTPUploadClient(opts HTTPUploadOptions, roots *x509.CertPool) *http.Client {
+	tlsConfig := &tls.Config{RootCAs: roots}
+	if pin := strings.ToLower(strings.ReplaceAll(opts.PinnedCert, ":", "")); pin != "" {
+		// The pin replaces the verification of the chain, done by VerifyPeerCertificate
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyCertificatePin(rawCerts, pin)
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport, Timeout: opts.UploadTimeout}
+}
+
+// verifyCertificatePin checks that the leaf certificate matches the hex encoded SHA-256 pin.
+func verifyCertificatePin(rawCerts [][]byte, pin string) error {
+	if len(rawCerts) == 0 {
+		return ErrCertificatePinMismatch
+	}
+	sum := sha256.Sum256(rawCerts[0])
+	if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(pin)) != 1 {
+		return ErrCertificatePinMismatch
+	}
+	return nil
+}
+
+func requestUploadSlot(ctx context.Context, s Sender, client *http.Client, opts HTTPUploadOptions,
+	service, filename string, size int64, contentType string) (UploadSlot, error) {
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, To: service})
+	if err != nil {
+		return UploadSlot{}, err
+	}
+	iq.HTTPUploadRequest(filename, size, contentType)
+
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return UploadSlot{}, err
+	}
+	if err = iqError(result); err != nil {
+		return UploadSlot{}, err
+	}
+	payload, ok := result.Payload.(*stanza.HTTPUploadSlot)
+	if !ok || payload.Put.URL == "" || payload.Get.URL == "" {
+		return UploadSlot{}, errors.New("invalid upload slot response")
+	}
+
+	slot := UploadSlot{
+		PutURL:      payload.Put.URL,
+		PutHeaders:  make(http.Header),
+		GetURL:      payload.Get.URL,
+		Size:        size,
+		ContentType: contentType,
+	}
+	for _, h := range payload.Put.Headers {
+		name := http.CanonicalHeaderKey(h.Name)
+		if uploadPutHeaders[name] {
+			slot.PutHeaders.Add(name, strings.ReplaceAll(h.Value, "\n", ""))
+		}
+	}
+	if opts.PinnedCert != "" {
+		if err = verifyUploadEndpoint(ctx, client, slot.PutURL); err != nil {
+			return UploadSlot{}, err
+		}
+	}
+	return slot, nil
+}
+
+// verifyUploadEndpoint sends a HEAD request to the upload endpoint, to verify its certificate.
+// The status of the response does not matter: the file is not uploaded yet.
+func verifyUploadEndpoint(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return uploadError(err)
+	}
+	return resp.Body.Close()
+}
+
+func uploadFile(ctx context.Context, client *http.Client, opts HTTPUploadOptions, slot UploadSlot, body io.ReadSeeker) error {
+	b := backoff{Base: 500}
+	for attempt := 0; ; attempt++ {
+		retry, err := putFile(ctx, client, slot, body)
+		if err == nil || !retry || attempt >= opts.MaxRetries {
+			return err
+		}
+		timer := time.NewTimer(b.duration())
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// putFile sends the PUT request uploading the file. It returns true with an error when the upload
+// can be retried.
+func putFile(ctx context.Context, client *http.Client, slot UploadSlot, body io.ReadSeeker) (bool, error) {
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, slot.PutURL, ioutil.NopCloser(body))
+	if err != nil {
+		return false, err
+	}
+	req.ContentLength = slot.Size
+	for name, values := range slot.PutHeaders {
+		req.Header[name] = values
+	}
+	if slot.ContentType != "" {
+		req.Header.Set("Content-Type", slot.ContentType)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		err = uploadError(err)
+		return err != ErrCertificatePinMismatch && ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	return resp.StatusCode >= 500, fmt.Errorf("upload failed with status %s: %s", resp.Status, bytes.TrimSpace(msg))
+}
+
+// uploadError unwraps the certificate pin errors returned by the HTTP client.
+func uploadError(err error) error {
+	if errors.Is(err, ErrCertificatePinMismatch) {
+		return ErrCertificatePinMismatch
+	}
+	return err
+}
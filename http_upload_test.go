@@ -0,0 +1,146 @@
+package xmpp
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// uploadServer records the files uploaded with PUT requests.
+type uploadServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	methods []string
+	body    string
+	header  http.Header
+	// Number of requests answered with an error before accepting uploads
+	failures int
+}
+
+func newUploadServer() *uploadServer {
+	s := &uploadServer{}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.methods = append(s.methods, r.Method)
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if s.failures > 0 {
+			s.failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		s.body = string(data)
+		s.header = r.Header
+		w.WriteHeader(http.StatusCreated)
+	}))
+	return s
+}
+
+// pin returns the fingerprint of the server certificate.
+func (s *uploadServer) pin() string {
+	sum := sha256.Sum256(s.Certificate().Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// roots returns a pool trusting the server certificate, like a custom CA installed on the system.
+func (s *uploadServer) roots() *x509.CertPool {
+	roots := x509.NewCertPool()
+	roots.AddCert(s.Certificate())
+	return roots
+}
+
+func slotResponse(putURL string) string {
+	return `<iq type='result' from='upload.montague.tld'>
+  <slot xmlns='urn:xmpp:http:upload:0'>
+    <put url='` + putURL + `/file.txt'>
+      <header name='Authorization'>Basic Base64String==</header>
+      <header name='X-Injected'>evil</header>
+    </put>
+    <get url='https://download.montague.tld/file.txt'/>
+  </slot>
+</iq>`
+}
+
+func TestRequestUploadSlot(t *testing.T) {
+	server := newUploadServer()
+	defer server.Close()
+	opts := HTTPUploadOptions{PinnedCert: server.pin()}
+	client := newHTTPUploadClient(opts, nil)
+
+	sender := &scriptedIQSender{t: t, responses: []string{slotResponse(server.URL)}}
+	slot, err := requestUploadSlot(context.Background(), sender, client, opts, "upload.montague.tld", "file.txt", 5, "text/plain")
+	if err != nil {
+		t.Fatalf("could not request slot: %v", err)
+	}
+	req, ok := sender.requests[0].Payload.(*stanza.HTTPUploadRequest)
+	if !ok || sender.requests[0].To != "upload.montague.tld" || req.Filename != "file.txt" || req.Size != 5 {
+		t.Fatalf("unexpected slot request: %+v", sender.requests[0].Payload)
+	}
+	if slot.GetURL != "https://download.montague.tld/file.txt" || slot.PutHeaders.Get("Authorization") == "" ||
+		slot.PutHeaders.Get("X-Injected") != "" {
+		t.Errorf("unexpected slot: %+v", slot)
+	}
+	if len(server.methods) != 1 || server.methods[0] != http.MethodHead {
+		t.Errorf("endpoint should be verified with a HEAD request: %v", server.methods)
+	}
+
+	if err = uploadFile(context.Background(), client, opts, slot, strings.NewReader("hello")); err != nil {
+		t.Fatalf("could not upload file: %v", err)
+	}
+	if server.body != "hello" || server.header.Get("Authorization") != "Basic Base64String==" ||
+		server.header.Get("Content-Type") != "text/plain" {
+		t.Errorf("unexpected upload: %q %v", server.body, server.header)
+	}
+}
+
+func TestHTTPUploadPinMismatch(t *testing.T) {
+	server := newUploadServer()
+	defer server.Close()
+	// The certificate is trusted, but it is not the pinned one
+	opts := HTTPUploadOptions{PinnedCert: strings.Repeat("ab", sha256.Size)}
+	client := newHTTPUploadClient(opts, server.roots())
+
+	sender := &scriptedIQSender{t: t, responses: []string{slotResponse(server.URL)}}
+	_, err := requestUploadSlot(context.Background(), sender, client, opts, "upload.montague.tld", "file.txt", 5, "text/plain")
+	if err != ErrCertificatePinMismatch {
+		t.Errorf("slot should be rejected: %v", err)
+	}
+
+	slot := UploadSlot{PutURL: server.URL + "/file.txt", Size: 5}
+	opts.MaxRetries = 2
+	if err = uploadFile(context.Background(), client, opts, slot, strings.NewReader("hello")); err != ErrCertificatePinMismatch {
+		t.Errorf("upload should be rejected: %v", err)
+	}
+	if len(server.methods) != 0 {
+		t.Errorf("no request should reach the server: %v", server.methods)
+	}
+}
+
+func TestHTTPUploadRetries(t *testing.T) {
+	server := newUploadServer()
+	defer server.Close()
+	server.failures = 1
+	opts := HTTPUploadOptions{MaxRetries: 1}
+	client := newHTTPUploadClient(opts, server.roots())
+
+	slot := UploadSlot{PutURL: server.URL + "/file.txt", Size: 5}
+	if err := uploadFile(context.Background(), client, opts, slot, strings.NewReader("hello")); err != nil {
+		t.Fatalf("upload should succeed after a retry: %v", err)
+	}
+	if len(server.methods) != 2 || server.body != "hello" {
+		t.Errorf("unexpected requests: %v, %q", server.methods, server.body)
+	}
+}
@@ -0,0 +1,46 @@
+package xmpp
+
+import (
+	"context"
+	"errors"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+var ErrIQResultTimeout = errors.New("no IQ result received before context end")
+
+// sendIQSync sends an IQ and waits for its result, until the context is done.
+// IQ errors are returned as the result IQ: it is up to the caller to check the result type.
+func sendIQSync(ctx context.Context, s Sender, iq *stanza.IQ) (stanza.IQ, error) {
+	res, err := s.SendIQ(ctx, iq)
+	if err != nil {
+		return stanza.IQ{}, err
+	}
+	select {
+	case result, ok := <-res:
+		if !ok {
+			return stanza.IQ{}, ErrIQResultTimeout
+		}
+		return result, nil
+	case <-ctx.Done():
+		return stanza.IQ{}, ErrIQResultTimeout
+	}
+}
+
+// iqError returns an error describing the error of an IQ result, or nil if the IQ is not an error.
+func iqError(iq stanza.IQ) error {
+	if iq.Type != stanza.IQTypeError {
+		return nil
+	}
+	if iq.Error == nil {
+		return errors.New("IQ error without error payload")
+	}
+	msg := "IQ error"
+	if iq.Error.Reason != "" {
+		msg += ": " + iq.Error.Reason
+	}
+	if text := iq.Error.Text.String(); text != "" {
+		msg += " (" + text + ")"
+	}
+	return errors.New(msg)
+}
@@ -0,0 +1,186 @@
+package xmpp
+
+import (
+	"context"
+	"encoding/xml"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// IQ result cache
+
+// IQResultCache is a Sender middleware caching the results of IQ get requests.
+// Results are keyed by recipient and marshaled payload, so that requests differing by any attribute
+// or child of the payload, such as a pubsub node or a result set page, are not shared. While a result is cached, or while an
+// identical request is in flight, SendIQ does not send the IQ and returns the result of the previous
+// request. Error responses are never cached, and invalidate the corresponding entry.
+//
+// A request shared by concurrent callers is not bound to the context of any of them: each caller
+// stops waiting when its own context is done, and the request is only cancelled once all of them
+// stopped waiting.
+type IQResultCache struct {
+	Sender
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[iqCacheKey]*iqCacheEntry
+
+	hits   int64
+	misses int64
+}
+
+type iqCacheKey struct {
+	to      string
+	payload string
+}
+
+type iqCacheEntry struct {
+	// done is closed once the request is answered or has failed
+	done    chan struct{}
+	result  stanza.IQ
+	ok      bool
+	expires time.Time
+
+	// Callers waiting for the request in flight, and the cancellation of the request
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// NewIQResultCache wraps a Sender with an IQ result cache. Cached results expire after ttl.
+func NewIQResultCache(s Sender, ttl time.Duration) *IQResultCache {
+	return &IQResultCache{
+		Sender:  s,
+		ttl:     ttl,
+		entries: make(map[iqCacheKey]*iqCacheEntry),
+	}
+}
+
+// SendIQ sends the IQ through the wrapped Sender, unless a cached or pending result exists for
+// an identical get request. Only get requests with a payload are cached.
+func (c *IQResultCache) SendIQ(ctx context.Context, iq *stanza.IQ) (chan stanza.IQ, error) {
+	if iq.Type != stanza.IQTypeGet || iq.Payload == nil {
+		return c.Sender.SendIQ(ctx, iq)
+	}
+	payload, err := xml.Marshal(iq.Payload)
+	if err != nil {
+		return c.Sender.SendIQ(ctx, iq)
+	}
+	key := iqCacheKey{to: iq.To, payload: string(payload)}
+
+	c.mu.Lock()
+	if entry, ok := c.entries[key]; ok {
+		select {
+		case <-entry.done:
+			if entry.ok && time.Now().Before(entry.expires) {
+				c.mu.Unlock()
+				atomic.AddInt64(&c.hits, 1)
+				return c.reply(ctx, entry, iq.Id), nil
+			}
+			delete(c.entries, key)
+		default:
+			// Identical request in flight: wait for its result
+			entry.waiters++
+			c.mu.Unlock()
+			atomic.AddInt64(&c.hits, 1)
+			return c.reply(ctx, entry, iq.Id), nil
+		}
+	}
+	reqCtx, cancel := context.WithCancel(context.Background())
+	entry := &iqCacheEntry{done: make(chan struct{}), waiters: 1, cancel: cancel}
+	c.entries[key] = entry
+	c.mu.Unlock()
+	atomic.AddInt64(&c.misses, 1)
+
+	res, err := c.Sender.SendIQ(reqCtx, iq)
+	if err != nil {
+		cancel()
+		c.remove(key, entry)
+		close(entry.done)
+		return nil, err
+	}
+
+	go func() {
+		defer cancel()
+		select {
+		case result := <-res:
+			entry.result = result
+			if result.Type == stanza.IQTypeResult {
+				entry.ok = true
+				entry.expires = time.Now().Add(c.ttl)
+			} else {
+				c.remove(key, entry)
+			}
+		case <-reqCtx.Done():
+			c.remove(key, entry)
+		}
+		close(entry.done)
+	}()
+	return c.reply(ctx, entry, iq.Id), nil
+}
+
+// CacheStats returns the number of requests answered from the cache, and the number of requests sent.
+func (c *IQResultCache) CacheStats() (hits, misses int64) {
+	return atomic.LoadInt64(&c.hits), atomic.LoadInt64(&c.misses)
+}
+
+// Purge removes all cached results.
+func (c *IQResultCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, entry := range c.entries {
+		select {
+		case <-entry.done:
+			delete(c.entries, key)
+		default:
+		}
+	}
+}
+
+// remove deletes the entry from the cache, if it was not replaced in the meantime.
+func (c *IQResultCache) remove(key iqCacheKey, entry *iqCacheEntry) {
+	c.mu.Lock()
+	if c.entries[key] == entry {
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+}
+
+// reply returns a channel receiving the entry result, with the id of the request it answers.
+// The channel is closed without value if the request failed or the context is done.
+func (c *IQResultCache) reply(ctx context.Context, entry *iqCacheEntry, id string) chan stanza.IQ {
+	out := make(chan stanza.IQ, 1)
+	go func() {
+		defer close(out)
+		select {
+		case <-entry.done:
+			if entry.result.Type != "" {
+				result := entry.result
+				result.Id = id
+				out <- result
+			}
+		case <-ctx.Done():
+			c.release(entry)
+		}
+	}()
+	return out
+}
+
+// release records that a caller stopped waiting for the entry, and cancels the request in flight
+// when no caller is waiting for it anymore.
+func (c *IQResultCache) release(entry *iqCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-entry.done:
+		return
+	default:
+	}
+	entry.waiters--
+	if entry.waiters == 0 {
+		entry.cancel()
+	}
+}
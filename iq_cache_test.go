@@ -0,0 +1,248 @@
+package xmpp
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// iqResponderMock is a Sender answering each IQ after a delay, with a result or an error.
+type iqResponderMock struct {
+	SenderMock
+	sent  int64
+	delay time.Duration
+	fail  atomic.Value
+}
+
+func newIQResponderMock(delay time.Duration) *iqResponderMock {
+	m := &iqResponderMock{SenderMock: NewSenderMock(), delay: delay}
+	m.fail.Store(false)
+	return m
+}
+
+func (m *iqResponderMock) SendIQ(ctx context.Context, iq *stanza.IQ) (chan stanza.IQ, error) {
+	atomic.AddInt64(&m.sent, 1)
+	res := make(chan stanza.IQ)
+	answer := stanza.IQ{Attrs: stanza.Attrs{Type: stanza.IQTypeResult, Id: iq.Id, From: iq.To}}
+	if m.fail.Load().(bool) {
+		answer.Type = stanza.IQTypeError
+	}
+	go func() {
+		time.Sleep(m.delay)
+		select {
+		case res <- answer:
+		case <-ctx.Done():
+		}
+	}()
+	return res, nil
+}
+
+func newDiscoInfoRequest(t *testing.T, id string) *stanza.IQ {
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, To: "pubsub.localhost", Id: id})
+	if err != nil {
+		t.Fatalf("failed to create IQ: %v", err)
+	}
+	iq.DiscoInfo()
+	return iq
+}
+
+func waitIQ(t *testing.T, res chan stanza.IQ) stanza.IQ {
+	select {
+	case iq, ok := <-res:
+		if !ok {
+			t.Fatalf("IQ result channel closed without result")
+		}
+		return iq
+	case <-time.After(defaultTimeout):
+		t.Fatalf("timeout waiting for IQ result")
+	}
+	return stanza.IQ{}
+}
+
+func TestIQResultCacheConcurrentRequests(t *testing.T) {
+	mock := newIQResponderMock(50 * time.Millisecond)
+	cache := NewIQResultCache(mock, time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, id := range []string{"disco1", "disco2"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			res, err := cache.SendIQ(ctx, newDiscoInfoRequest(t, id))
+			if err != nil {
+				t.Errorf("SendIQ failed: %v", err)
+				return
+			}
+			select {
+			case result := <-res:
+				if result.Id != id || result.Type != stanza.IQTypeResult {
+					t.Errorf("unexpected result for %s: %+v", id, result.Attrs)
+				}
+			case <-time.After(defaultTimeout):
+				t.Errorf("timeout waiting for IQ result %s", id)
+			}
+		}(id)
+	}
+	wg.Wait()
+
+	if sent := atomic.LoadInt64(&mock.sent); sent != 1 {
+		t.Errorf("expected a single IQ to be sent, got %d", sent)
+	}
+
+	// Subsequent request is served from the cache
+	res, err := cache.SendIQ(ctx, newDiscoInfoRequest(t, "disco3"))
+	if err != nil {
+		t.Fatalf("SendIQ failed: %v", err)
+	}
+	if result := waitIQ(t, res); result.Id != "disco3" {
+		t.Errorf("cached result should have the request id: %s", result.Id)
+	}
+	if sent := atomic.LoadInt64(&mock.sent); sent != 1 {
+		t.Errorf("cached request should not be sent, got %d IQs sent", sent)
+	}
+	if hits, misses := cache.CacheStats(); hits != 2 || misses != 1 {
+		t.Errorf("unexpected cache stats: %d hits, %d misses", hits, misses)
+	}
+}
+
+func TestIQResultCacheExpiration(t *testing.T) {
+	mock := newIQResponderMock(0)
+	cache := NewIQResultCache(mock, 20*time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	for _, id := range []string{"disco1", "disco2"} {
+		res, err := cache.SendIQ(ctx, newDiscoInfoRequest(t, id))
+		if err != nil {
+			t.Fatalf("SendIQ failed: %v", err)
+		}
+		waitIQ(t, res)
+		time.Sleep(40 * time.Millisecond)
+	}
+	if sent := atomic.LoadInt64(&mock.sent); sent != 2 {
+		t.Errorf("expired result should not be used, got %d IQs sent", sent)
+	}
+}
+
+func TestIQResultCacheError(t *testing.T) {
+	mock := newIQResponderMock(0)
+	mock.fail.Store(true)
+	cache := NewIQResultCache(mock, time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	for _, id := range []string{"disco1", "disco2"} {
+		res, err := cache.SendIQ(ctx, newDiscoInfoRequest(t, id))
+		if err != nil {
+			t.Fatalf("SendIQ failed: %v", err)
+		}
+		if result := waitIQ(t, res); result.Type != stanza.IQTypeError {
+			t.Errorf("expected error result, got %s", result.Type)
+		}
+	}
+	if sent := atomic.LoadInt64(&mock.sent); sent != 2 {
+		t.Errorf("error results should not be cached, got %d IQs sent", sent)
+	}
+
+	// Set requests are never cached
+	iq, _ := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeSet, To: "pubsub.localhost"})
+	iq.DiscoInfo()
+	if _, err := cache.SendIQ(ctx, iq); err != nil {
+		t.Fatalf("SendIQ failed: %v", err)
+	}
+	if _, misses := cache.CacheStats(); misses != 2 {
+		t.Errorf("set request should bypass the cache")
+	}
+}
+
+func TestIQResultCacheCallerCancel(t *testing.T) {
+	mock := newIQResponderMock(50 * time.Millisecond)
+	cache := NewIQResultCache(mock, time.Minute)
+
+	// The first caller gives up: the shared request is still answered to the second one
+	first, cancelFirst := context.WithCancel(context.Background())
+	res1, err := cache.SendIQ(first, newDiscoInfoRequest(t, "disco1"))
+	if err != nil {
+		t.Fatalf("SendIQ failed: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	res2, err := cache.SendIQ(ctx, newDiscoInfoRequest(t, "disco2"))
+	if err != nil {
+		t.Fatalf("SendIQ failed: %v", err)
+	}
+	cancelFirst()
+
+	if _, ok := <-res1; ok {
+		t.Errorf("cancelled caller should not receive a result")
+	}
+	if result := waitIQ(t, res2); result.Id != "disco2" || result.Type != stanza.IQTypeResult {
+		t.Errorf("unexpected result: %+v", result.Attrs)
+	}
+	if sent := atomic.LoadInt64(&mock.sent); sent != 1 {
+		t.Errorf("expected a single IQ to be sent, got %d", sent)
+	}
+}
+
+func TestIQResultCacheNodes(t *testing.T) {
+	mock := newIQResponderMock(0)
+	cache := NewIQResultCache(mock, time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	for i, node := range []string{"", "http://jabber.org/protocol/commands", ""} {
+		iq := newDiscoInfoRequest(t, "disco")
+		iq.Payload.(*stanza.DiscoInfo).Node = node
+		res, err := cache.SendIQ(ctx, iq)
+		if err != nil {
+			t.Fatalf("SendIQ %d failed: %v", i, err)
+		}
+		waitIQ(t, res)
+	}
+	if sent := atomic.LoadInt64(&mock.sent); sent != 2 {
+		t.Errorf("queries to different nodes should not share results, got %d IQs sent", sent)
+	}
+}
+
+func TestIQResultCachePayloads(t *testing.T) {
+	mock := newIQResponderMock(0)
+	cache := NewIQResultCache(mock, time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	var requests []*stanza.IQ
+	// Items of different pubsub nodes
+	for _, node := range []string{"princely_musings", "news"} {
+		iq, err := stanza.NewItemsRequest("pubsub.shakespeare.lit", node, 0)
+		if err != nil {
+			t.Fatalf("failed to create IQ: %v", err)
+		}
+		requests = append(requests, iq)
+	}
+	// Pages of the rooms of a service
+	for _, after := range []string{"", "coven@chat.shakespeare.lit"} {
+		iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, To: "chat.shakespeare.lit"})
+		if err != nil {
+			t.Fatalf("failed to create IQ: %v", err)
+		}
+		iq.DiscoItems().ResultSet = stanza.NewRSMQuery(10, after).ResultSet()
+		requests = append(requests, iq)
+	}
+
+	for i, iq := range requests {
+		res, err := cache.SendIQ(ctx, iq)
+		if err != nil {
+			t.Fatalf("SendIQ %d failed: %v", i, err)
+		}
+		waitIQ(t, res)
+	}
+	if sent := atomic.LoadInt64(&mock.sent); sent != int64(len(requests)) {
+		t.Errorf("requests with different payloads should not share results, got %d IQs sent", sent)
+	}
+}
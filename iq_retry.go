@@ -0,0 +1,95 @@
+package xmpp
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/google/uuid"
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// IQ retries
+
+const (
+	defaultRetryMaxAttempts  = 3
+	defaultRetryInitialDelay = 500 * time.Millisecond
+	defaultRetryMaxDelay     = 30 * time.Second
+	// retryJitter is the maximum random variation of the delays between attempts, as a fraction
+	retryJitter = 0.25
+)
+
+// RetryPolicy tells how SendIQWithRetry retries IQ requests failing with transient errors.
+// Zero values are replaced by the defaults: 3 attempts, an initial delay of 500ms doubled after each
+// attempt up to 30s, and retries on DefaultRetryOn errors.
+type RetryPolicy struct {
+	MaxAttempts  int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	// RetryOn tells if an error returned by the recipient is transient, and the request must be retried
+	RetryOn func(stanza.Err) bool
+}
+
+// DefaultRetryOn retries requests failing because the server of the recipient could not be reached.
+func DefaultRetryOn(err stanza.Err) bool {
+	return err.Reason == "remote-server-not-found" || err.Reason == "remote-server-timeout"
+}
+
+// SendIQWithRetry sends an IQ get or set request and waits for its response, retrying with
+// exponential backoff while the recipient returns an error matching the policy. A new stanza ID is
+// used for each attempt. It returns the last response, with an error if it is an error IQ or if
+// no response was received. Retries stop as soon as ctx is done.
+func (c *Client) SendIQWithRetry(ctx context.Context, iq *stanza.IQ, policy RetryPolicy) (stanza.IQ, error) {
+	return sendIQWithRetry(ctx, c, iq, policy)
+}
+
+func sendIQWithRetry(ctx context.Context, s Sender, iq *stanza.IQ, policy RetryPolicy) (stanza.IQ, error) {
+	policy.setDefaults()
+	delay := policy.InitialDelay
+	for attempt := 1; ; attempt++ {
+		result, err := sendIQSync(ctx, s, iq)
+		if err != nil {
+			return stanza.IQ{}, err
+		}
+		if err = iqError(result); err == nil || attempt >= policy.MaxAttempts ||
+			result.Error == nil || !policy.RetryOn(*result.Error) {
+			return result, err
+		}
+
+		timer := time.NewTimer(jitter(delay))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return result, ctx.Err()
+		}
+		if delay *= 2; delay > policy.MaxDelay {
+			delay = policy.MaxDelay
+		}
+		// The server may still remember the previous ID
+		retry := *iq
+		retry.Id = uuid.New().String()
+		iq = &retry
+	}
+}
+
+func (p *RetryPolicy) setDefaults() {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = defaultRetryMaxAttempts
+	}
+	if p.InitialDelay <= 0 {
+		p.InitialDelay = defaultRetryInitialDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = defaultRetryMaxDelay
+	}
+	if p.RetryOn == nil {
+		p.RetryOn = DefaultRetryOn
+	}
+}
+
+// jitter returns d changed randomly by up to retryJitter.
+func jitter(d time.Duration) time.Duration {
+	return time.Duration(float64(d) * (1 + retryJitter*(2*rand.Float64()-1)))
+}
@@ -0,0 +1,77 @@
+package xmpp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+const remoteServerNotFound = `<iq type='error' from='characters.shakespeare.lit'>
+  <error type='cancel'><remote-server-not-found xmlns='urn:ietf:params:xml:ns:xmpp-stanzas'/></error>
+</iq>`
+
+func TestSendIQWithRetry(t *testing.T) {
+	sender := &scriptedIQSender{t: t, responses: []string{
+		remoteServerNotFound,
+		remoteServerNotFound,
+		`<iq type='result' from='characters.shakespeare.lit'/>`,
+	}}
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, To: "characters.shakespeare.lit"})
+	if err != nil {
+		t.Fatalf("could not create IQ: %v", err)
+	}
+	iq.Ping()
+
+	policy := RetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+	result, err := sendIQWithRetry(context.Background(), sender, iq, policy)
+	if err != nil || result.Type != stanza.IQTypeResult {
+		t.Fatalf("request should succeed on the third attempt: %+v, %v", result, err)
+	}
+	if len(sender.requests) != 3 {
+		t.Fatalf("unexpected number of attempts: %d", len(sender.requests))
+	}
+	ids := map[string]bool{}
+	for _, req := range sender.requests {
+		ids[req.Id] = true
+	}
+	if len(ids) != 3 {
+		t.Errorf("each attempt should use a new ID")
+	}
+}
+
+func TestSendIQWithRetry_Stops(t *testing.T) {
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, To: "characters.shakespeare.lit"})
+	if err != nil {
+		t.Fatalf("could not create IQ: %v", err)
+	}
+	iq.Ping()
+
+	// Errors not matching the policy are not retried
+	sender := &scriptedIQSender{t: t, responses: []string{`<iq type='error'>
+  <error type='cancel'><item-not-found xmlns='urn:ietf:params:xml:ns:xmpp-stanzas'/></error>
+</iq>`}}
+	result, err := sendIQWithRetry(context.Background(), sender, iq, RetryPolicy{InitialDelay: time.Millisecond})
+	if err == nil || result.Error == nil || result.Error.Reason != "item-not-found" {
+		t.Errorf("unexpected result: %+v, %v", result, err)
+	}
+
+	// The last error is returned when attempts are exhausted
+	sender = &scriptedIQSender{t: t, responses: []string{remoteServerNotFound, remoteServerNotFound}}
+	policy := RetryPolicy{MaxAttempts: 2, InitialDelay: time.Millisecond}
+	if _, err = sendIQWithRetry(context.Background(), sender, iq, policy); err == nil || len(sender.requests) != 2 {
+		t.Errorf("retries should stop after 2 attempts: %d, %v", len(sender.requests), err)
+	}
+
+	// A cancelled context stops the retries
+	ctx, cancel := context.WithCancel(context.Background())
+	sender = &scriptedIQSender{t: t, responses: []string{remoteServerNotFound}}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if _, err = sendIQWithRetry(ctx, sender, iq, RetryPolicy{InitialDelay: time.Hour}); err != context.Canceled {
+		t.Errorf("retries should stop with the context: %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+package xmpp
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// IQ scheduler
+
+// IQScheduler limits the number of IQ requests waiting for a response, in total and per recipient JID.
+// It is enabled on a client by setting the IQScheduler field of its Config.
+//
+// Requests exceeding the limits are queued, and sent in FIFO order as responses are received.
+// SendIQ does not block while the request is queued: the result channel is closed without value if
+// the request context is done before it could be sent.
+//
+// SendIQ returns the error of the requests sent without queuing. The result channel of a queued
+// request that cannot be sent is closed without value, and the error is passed to the handler set
+// with OnSendError.
+type IQScheduler struct {
+	maxInFlight  int
+	maxPerTarget int
+
+	mu        sync.Mutex
+	inFlight  int
+	perTarget map[string]int
+	queue     []*scheduledIQ
+	stats     IQSchedulerStats
+
+	// Handler of the send errors of queued requests
+	sendErrorFn func(target string, err error)
+}
+
+// IQSchedulerStats are the metrics of an IQ scheduler.
+type IQSchedulerStats struct {
+	// Number of requests waiting to be sent, and number of requests waiting for a response
+	QueueDepth int
+	InFlight   int
+	// Number of requests sent, with their cumulated and maximum time spent in the queue
+	Dispatched int64
+	TotalWait  time.Duration
+	MaxWait    time.Duration
+	// Number of requests whose context was done while they were queued
+	Expired int64
+	// Number of requests that could not be sent
+	SendErrors int64
+}
+
+type scheduledIQ struct {
+	target   string
+	enqueued time.Time
+	// start is closed when the request can be sent
+	start chan struct{}
+}
+
+// NewIQScheduler creates an IQ scheduler. maxInFlight is the maximum number of requests waiting for a
+// response, and maxPerTarget the maximum number of such requests for a single recipient JID.
+// Zero means no limit.
+func NewIQScheduler(maxInFlight, maxPerTarget int) *IQScheduler {
+	return &IQScheduler{
+		maxInFlight:  maxInFlight,
+		maxPerTarget: maxPerTarget,
+		perTarget:    make(map[string]int),
+	}
+}
+
+// OnSendError sets the handler called when a queued request cannot be sent, with the recipient JID
+// of the request and the send error.
+func (s *IQScheduler) OnSendError(handler func(target string, err error)) {
+	s.mu.Lock()
+	s.sendErrorFn = handler
+	s.mu.Unlock()
+}
+
+// Stats returns the current metrics of the scheduler.
+func (s *IQScheduler) Stats() IQSchedulerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.stats
+	stats.QueueDepth = len(s.queue)
+	stats.InFlight = s.inFlight
+	return stats
+}
+
+// schedule calls send when the request to target is allowed by the limits, and forwards its result.
+// The send error is returned when the request is sent without queuing.
+func (s *IQScheduler) schedule(ctx context.Context, target string, send func() (chan stanza.IQ, error)) (chan stanza.IQ, error) {
+	req := &scheduledIQ{target: target, enqueued: time.Now(), start: make(chan struct{})}
+	s.mu.Lock()
+	s.queue = append(s.queue, req)
+	s.dispatch()
+	s.mu.Unlock()
+
+	out := make(chan stanza.IQ, 1)
+	select {
+	case <-req.start:
+		if ctx.Err() != nil {
+			s.release(target)
+			close(out)
+			return out, nil
+		}
+		res, err := send()
+		if err != nil {
+			s.failed(target)
+			return nil, err
+		}
+		go s.forward(ctx, target, res, out)
+		return out, nil
+	default:
+	}
+
+	go func() {
+		select {
+		case <-req.start:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			s.expire(req)
+			close(out)
+			return
+		}
+		res, err := send()
+		if err != nil {
+			if handler := s.failed(target); handler != nil {
+				handler(target, err)
+			}
+			close(out)
+			return
+		}
+		s.forward(ctx, target, res, out)
+	}()
+	return out, nil
+}
+
+// forward sends the result of a request to out, then releases its slot.
+func (s *IQScheduler) forward(ctx context.Context, target string, res chan stanza.IQ, out chan stanza.IQ) {
+	defer close(out)
+	defer s.release(target)
+	select {
+	case result, ok := <-res:
+		if ok {
+			out <- result
+		}
+	case <-ctx.Done():
+	}
+}
+
+// failed counts a request that could not be sent and releases its slot. It returns the send error
+// handler.
+func (s *IQScheduler) failed(target string) func(target string, err error) {
+	s.mu.Lock()
+	s.stats.SendErrors++
+	handler := s.sendErrorFn
+	s.mu.Unlock()
+	s.release(target)
+	return handler
+}
+
+// dispatch starts the queued requests allowed by the limits, in FIFO order for each target.
+// It must be called with the lock held.
+func (s *IQScheduler) dispatch() {
+	remaining := s.queue[:0]
+	for i, req := range s.queue {
+		if s.maxInFlight > 0 && s.inFlight >= s.maxInFlight {
+			remaining = append(remaining, s.queue[i:]...)
+			break
+		}
+		if s.maxPerTarget > 0 && s.perTarget[req.target] >= s.maxPerTarget {
+			remaining = append(remaining, req)
+			continue
+		}
+		s.inFlight++
+		s.perTarget[req.target]++
+		wait := time.Since(req.enqueued)
+		s.stats.Dispatched++
+		s.stats.TotalWait += wait
+		if wait > s.stats.MaxWait {
+			s.stats.MaxWait = wait
+		}
+		close(req.start)
+	}
+	for i := len(remaining); i < len(s.queue); i++ {
+		s.queue[i] = nil
+	}
+	s.queue = remaining
+}
+
+// expire removes a request whose context is done. If it was started in the meantime, its slot is released.
+func (s *IQScheduler) expire(req *scheduledIQ) {
+	s.mu.Lock()
+	for i, r := range s.queue {
+		if r == req {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			s.stats.Expired++
+			s.mu.Unlock()
+			return
+		}
+	}
+	s.mu.Unlock()
+	s.release(req.target)
+}
+
+// release frees the slot of a request, and starts the queued requests it allows.
+func (s *IQScheduler) release(target string) {
+	s.mu.Lock()
+	s.inFlight--
+	if s.perTarget[target]--; s.perTarget[target] <= 0 {
+		delete(s.perTarget, target)
+	}
+	s.dispatch()
+	s.mu.Unlock()
+}
@@ -0,0 +1,196 @@
+package xmpp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// pendingIQs simulates IQ requests, answered on demand by the test.
+type pendingIQs struct {
+	mu      sync.Mutex
+	sent    []string
+	results map[string]chan stanza.IQ
+}
+
+func newPendingIQs() *pendingIQs {
+	return &pendingIQs{results: make(map[string]chan stanza.IQ)}
+}
+
+func (p *pendingIQs) send(id string) func() (chan stanza.IQ, error) {
+	return func() (chan stanza.IQ, error) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		res := make(chan stanza.IQ, 1)
+		p.sent = append(p.sent, id)
+		p.results[id] = res
+		return res, nil
+	}
+}
+
+func (p *pendingIQs) answer(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.results[id] <- stanza.IQ{Attrs: stanza.Attrs{Id: id, Type: stanza.IQTypeResult}}
+}
+
+func (p *pendingIQs) sentIds() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.sent...)
+}
+
+// waitSent waits until n requests were sent.
+func (p *pendingIQs) waitSent(t *testing.T, n int) []string {
+	deadline := time.Now().Add(defaultTimeout)
+	for time.Now().Before(deadline) {
+		if sent := p.sentIds(); len(sent) >= n {
+			return sent
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected %d requests sent, got %v", n, p.sentIds())
+	return nil
+}
+
+func TestIQSchedulerPerTargetLimit(t *testing.T) {
+	scheduler := NewIQScheduler(0, 1)
+	pending := newPendingIQs()
+	ctx := context.Background()
+
+	res1, _ := scheduler.schedule(ctx, "a.example.com", pending.send("a1"))
+	scheduler.schedule(ctx, "a.example.com", pending.send("a2"))
+	scheduler.schedule(ctx, "b.example.com", pending.send("b1"))
+
+	// a2 is queued behind a1, but b1 is not blocked by it
+	sent := pending.waitSent(t, 2)
+	if !sameIds(sent, "a1", "b1") {
+		t.Fatalf("unexpected requests sent: %v", sent)
+	}
+	if stats := scheduler.Stats(); stats.QueueDepth != 1 || stats.InFlight != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	pending.answer("a1")
+	if result := <-res1; result.Id != "a1" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if sent = pending.waitSent(t, 3); sent[2] != "a2" {
+		t.Errorf("queued request not sent: %v", sent)
+	}
+	if stats := scheduler.Stats(); stats.QueueDepth != 0 || stats.Dispatched != 3 || stats.MaxWait == 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestIQSchedulerTotalLimitFIFO(t *testing.T) {
+	scheduler := NewIQScheduler(2, 0)
+	pending := newPendingIQs()
+	ctx := context.Background()
+
+	for _, id := range []string{"1", "2", "3", "4", "5"} {
+		scheduler.schedule(ctx, id+".example.com", pending.send(id))
+	}
+	pending.waitSent(t, 2)
+	pending.answer("1")
+	pending.waitSent(t, 3)
+	pending.answer("2")
+	pending.answer("3")
+	// Requests started together can be sent in any order
+	sent := pending.waitSent(t, 5)
+	if !sameIds(sent[:2], "1", "2") || sent[2] != "3" || !sameIds(sent[3:], "4", "5") {
+		t.Fatalf("requests not sent in order: %v", sent)
+	}
+}
+
+func sameIds(ids []string, expected ...string) bool {
+	if len(ids) != len(expected) {
+		return false
+	}
+	count := make(map[string]int)
+	for _, id := range ids {
+		count[id]++
+	}
+	for _, id := range expected {
+		if count[id]--; count[id] < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestIQSchedulerQueuedDeadline(t *testing.T) {
+	scheduler := NewIQScheduler(1, 0)
+	pending := newPendingIQs()
+
+	scheduler.schedule(context.Background(), "example.com", pending.send("first"))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	res, _ := scheduler.schedule(ctx, "example.com", pending.send("expired"))
+
+	select {
+	case _, ok := <-res:
+		if ok {
+			t.Errorf("no result expected for expired request")
+		}
+	case <-time.After(defaultTimeout):
+		t.Fatalf("expired request channel not closed")
+	}
+	if stats := scheduler.Stats(); stats.QueueDepth != 0 || stats.Expired != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	// The expired request must never be sent
+	pending.answer("first")
+	scheduler.schedule(context.Background(), "example.com", pending.send("next"))
+	if sent := pending.waitSent(t, 2); sent[1] != "next" {
+		t.Errorf("unexpected requests sent: %v", sent)
+	}
+}
+
+func TestIQSchedulerSendError(t *testing.T) {
+	scheduler := NewIQScheduler(1, 0)
+	pending := newPendingIQs()
+	sendErr := errors.New("not connected")
+	failing := func() (chan stanza.IQ, error) { return nil, sendErr }
+
+	// A request sent without queuing returns the send error
+	if _, err := scheduler.schedule(context.Background(), "example.com", failing); err != sendErr {
+		t.Errorf("expected send error, got %v", err)
+	}
+
+	// The error of a queued request is passed to the handler
+	errs := make(chan error, 1)
+	scheduler.OnSendError(func(target string, err error) {
+		if target != "example.com" {
+			t.Errorf("unexpected target: %s", target)
+		}
+		errs <- err
+	})
+	if _, err := scheduler.schedule(context.Background(), "example.com", pending.send("first")); err != nil {
+		t.Fatalf("schedule failed: %v", err)
+	}
+	res, err := scheduler.schedule(context.Background(), "example.com", failing)
+	if err != nil {
+		t.Fatalf("queued request should not fail: %v", err)
+	}
+	pending.answer("first")
+	select {
+	case err := <-errs:
+		if err != sendErr {
+			t.Errorf("unexpected send error: %v", err)
+		}
+	case <-time.After(defaultTimeout):
+		t.Fatal("send error was not reported")
+	}
+	if _, ok := <-res; ok {
+		t.Errorf("no result expected for a request that could not be sent")
+	}
+	if stats := scheduler.Stats(); stats.SendErrors != 2 || stats.InFlight != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
@@ -0,0 +1,58 @@
+package xmpp
+
+import (
+	"context"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// IQ tracing
+
+// IQTracer is notified of the IQ requests sent with SendIQ, to trace them.
+// See the xmppotel package for an OpenTelemetry implementation.
+type IQTracer interface {
+	// StartIQ is called before the request is sent, with the context passed to SendIQ. It returns
+	// the context of the request, carrying its span, which is used while waiting for the response.
+	// The returned function is called once: with the response, or with a nil response and the error
+	// that prevented the request from being answered.
+	StartIQ(ctx context.Context, iq *stanza.IQ) (context.Context, func(result *stanza.IQ, err error))
+}
+
+// sendIQ sends the IQ request and returns the channel receiving its result, sent by one of the from
+// JIDs, or by any JID if from is empty. The request is traced when a tracer is set.
+func sendIQ(ctx context.Context, tracer IQTracer, iq *stanza.IQ, send func(stanza.Packet) error, router *Router, from []string) (chan stanza.IQ, error) {
+	if tracer == nil {
+		if err := send(iq); err != nil {
+			return nil, err
+		}
+		return router.NewIQResultRouteFrom(ctx, iq.Attrs.Id, from...), nil
+	}
+
+	ctx, end := tracer.StartIQ(ctx, iq)
+	if err := send(iq); err != nil {
+		end(nil, err)
+		return nil, err
+	}
+	return traceIQResult(ctx, end, router.NewIQResultRouteFrom(ctx, iq.Attrs.Id, from...)), nil
+}
+
+// traceIQResult forwards the result of an IQ request, reporting it to the trace end function.
+func traceIQResult(ctx context.Context, end func(*stanza.IQ, error), res chan stanza.IQ) chan stanza.IQ {
+	out := make(chan stanza.IQ, 1)
+	go func() {
+		defer close(out)
+		select {
+		case result, ok := <-res:
+			if !ok {
+				end(nil, ErrIQResultTimeout)
+				return
+			}
+			end(&result, nil)
+			out <- result
+		case <-ctx.Done():
+			end(nil, ErrIQResultTimeout)
+		}
+	}()
+	return out
+}
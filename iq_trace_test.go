@@ -0,0 +1,76 @@
+package xmpp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+type traceRecord struct {
+	id     string
+	result *stanza.IQ
+	err    error
+}
+
+type recordingTracer struct {
+	ended chan traceRecord
+}
+
+func (t recordingTracer) StartIQ(ctx context.Context, iq *stanza.IQ) (context.Context, func(*stanza.IQ, error)) {
+	return ctx, func(result *stanza.IQ, err error) {
+		t.ended <- traceRecord{id: iq.Id, result: result, err: err}
+	}
+}
+
+func TestSendIQTraced(t *testing.T) {
+	tracer := recordingTracer{ended: make(chan traceRecord, 1)}
+	router := NewRouter()
+	var sent []stanza.Packet
+	send := func(p stanza.Packet) error {
+		sent = append(sent, p)
+		return nil
+	}
+
+	iq, _ := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, Id: "trace1", To: "example.com"})
+	iq.Version()
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	res, err := sendIQ(ctx, tracer, iq, send, router, nil)
+	if err != nil || len(sent) != 1 {
+		t.Fatalf("IQ not sent: %v", err)
+	}
+
+	answer, _ := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeResult, Id: "trace1", From: "example.com"})
+	router.route(nil, answer)
+	select {
+	case result := <-res:
+		if result.Id != "trace1" {
+			t.Errorf("unexpected result: %+v", result)
+		}
+	case <-time.After(defaultTimeout):
+		t.Fatalf("result not forwarded")
+	}
+	record := <-tracer.ended
+	if record.id != "trace1" || record.err != nil || record.result == nil || record.result.Type != stanza.IQTypeResult {
+		t.Errorf("unexpected trace end: %+v", record)
+	}
+}
+
+func TestSendIQTracedTimeout(t *testing.T) {
+	tracer := recordingTracer{ended: make(chan traceRecord, 1)}
+	iq, _ := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, Id: "trace2"})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	res, err := sendIQ(ctx, tracer, iq, func(stanza.Packet) error { return nil }, NewRouter(), nil)
+	if err != nil {
+		t.Fatalf("IQ not sent: %v", err)
+	}
+	if _, ok := <-res; ok {
+		t.Errorf("no result should be received")
+	}
+	if record := <-tracer.ended; record.err != ErrIQResultTimeout || record.result != nil {
+		t.Errorf("unexpected trace end: %+v", record)
+	}
+}
@@ -0,0 +1,139 @@
+package xmpp
+
+import (
+	"context"
+	"errors"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Publishing Available Jingle Sessions (XEP-0358)
+
+// JingleApp is a Jingle application the account accepts sessions for. NS is the namespace of the
+// application, such as stanza.NSJingleRTP, and Media the type of media: "audio" or "video".
+type JingleApp struct {
+	NS    string
+	Media string
+}
+
+// PublishJingleSession advertises the Jingle applications the account can be called with, replacing
+// the previous publication. Without apps, the publication is retracted.
+func (c *Client) PublishJingleSession(ctx context.Context, apps []JingleApp) error {
+	if len(apps) == 0 {
+		return retractJingleSession(ctx, c)
+	}
+	return publishJingleSession(ctx, c, apps)
+}
+
+// RetractJingleSession retracts the publication of the Jingle applications, when the account can no
+// longer be called.
+func (c *Client) RetractJingleSession(ctx context.Context) error {
+	return retractJingleSession(ctx, c)
+}
+
+// GetContactJingleApps fetches the Jingle applications published by the contact. It returns no
+// applications if the contact did not publish any.
+func (c *Client) GetContactJingleApps(ctx context.Context, contactJID string) ([]JingleApp, error) {
+	return getContactJingleApps(ctx, c, contactJID)
+}
+
+// CanCallContact tells if the contact accepts RTP audio and video sessions.
+func (c *Client) CanCallContact(ctx context.Context, jid string) (audio, video bool, err error) {
+	apps, err := getContactJingleApps(ctx, c, jid)
+	if err != nil {
+		return false, false, err
+	}
+	audio, video = callableMedia(apps)
+	return audio, video, nil
+}
+
+func publishJingleSession(ctx context.Context, s Sender, apps []JingleApp) error {
+	var payload stanza.JingleApps
+	for _, app := range apps {
+		if app.NS == "" {
+			return errors.New("a namespace is required to publish a Jingle application")
+		}
+		payload.Descriptions = append(payload.Descriptions, stanza.NewJingleDescription(app.NS, app.Media))
+	}
+	item, err := stanza.NewPayloadItem(stanza.JingleAppsItemId, payload)
+	if err != nil {
+		return err
+	}
+	iq, err := stanza.NewPublishItemRq("", stanza.NodeJingleApps, stanza.JingleAppsItemId, item)
+	if err != nil {
+		return err
+	}
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return err
+	}
+	return iqError(result)
+}
+
+func retractJingleSession(ctx context.Context, s Sender) error {
+	notify := true
+	iq, err := stanza.NewDelItemFromNode("", stanza.NodeJingleApps, stanza.JingleAppsItemId, &notify)
+	if err != nil {
+		return err
+	}
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return err
+	}
+	if isItemNotFound(result) {
+		// Nothing was published
+		return nil
+	}
+	return iqError(result)
+}
+
+func getContactJingleApps(ctx context.Context, s Sender, jid string) ([]JingleApp, error) {
+	iq, err := stanza.NewItemsRequest(jid, stanza.NodeJingleApps, 1)
+	if err != nil {
+		return nil, err
+	}
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return nil, err
+	}
+	if isItemNotFound(result) {
+		return nil, nil
+	}
+	if err = iqError(result); err != nil {
+		return nil, err
+	}
+	ps, ok := result.Payload.(*stanza.PubSubGeneric)
+	if !ok || ps.Items == nil {
+		return nil, errors.New("invalid Jingle applications response")
+	}
+
+	var apps []JingleApp
+	for _, item := range ps.Items.List {
+		var payload stanza.JingleApps
+		if err = item.DecodePayload(&payload); err != nil {
+			// Skip items that are not Jingle applications
+			continue
+		}
+		for _, d := range payload.Descriptions {
+			apps = append(apps, JingleApp{NS: d.XMLName.Space, Media: d.Media})
+		}
+	}
+	return apps, nil
+}
+
+// callableMedia tells if the applications include RTP audio and video sessions.
+func callableMedia(apps []JingleApp) (audio, video bool) {
+	for _, app := range apps {
+		if app.NS != stanza.NSJingleRTP {
+			continue
+		}
+		switch app.Media {
+		case "audio":
+			audio = true
+		case "video":
+			video = true
+		}
+	}
+	return audio, video
+}
@@ -0,0 +1,59 @@
+package xmpp
+
+import (
+	"context"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestPublishJingleSession(t *testing.T) {
+	sender := &scriptedIQSender{t: t, responses: []string{`<iq type='result'/>`}}
+	apps := []JingleApp{{NS: stanza.NSJingleRTP, Media: "audio"}}
+	if err := publishJingleSession(context.Background(), sender, apps); err != nil {
+		t.Fatalf("could not publish Jingle applications: %v", err)
+	}
+	ps, ok := sender.requests[0].Payload.(*stanza.PubSubGeneric)
+	if !ok || ps.Publish == nil || ps.Publish.Node != stanza.NodeJingleApps || len(ps.Publish.Items) != 1 ||
+		ps.Publish.Items[0].Id != stanza.JingleAppsItemId {
+		t.Fatalf("unexpected request: %+v", sender.requests[0].Payload)
+	}
+
+	// Retracting an absent publication succeeds
+	sender = &scriptedIQSender{t: t, responses: []string{`<iq type='error'>
+  <error type='cancel'><item-not-found xmlns='urn:ietf:params:xml:ns:xmpp-stanzas'/></error>
+</iq>`}}
+	if err := retractJingleSession(context.Background(), sender); err != nil {
+		t.Errorf("could not retract Jingle applications: %v", err)
+	}
+	ps, ok = sender.requests[0].Payload.(*stanza.PubSubGeneric)
+	if !ok || ps.Retract == nil || ps.Retract.Node != stanza.NodeJingleApps {
+		t.Errorf("unexpected request: %+v", sender.requests[0].Payload)
+	}
+}
+
+func TestGetContactJingleApps(t *testing.T) {
+	response := `<iq type='result' from='juliet@capulet.lit'>
+  <pubsub xmlns='http://jabber.org/protocol/pubsub'>
+    <items node='urn:xmpp:jingle:apps:rtp:1'>
+      <item id='current'>
+        <apps xmlns='urn:xmpp:jingle:apps:rtp:1'>
+          <description xmlns='urn:xmpp:jingle:apps:rtp:1' media='audio'/>
+          <description xmlns='urn:xmpp:jingle:apps:file-transfer:5'/>
+        </apps>
+      </item>
+    </items>
+  </pubsub>
+</iq>`
+	sender := &scriptedIQSender{t: t, responses: []string{response}}
+	apps, err := getContactJingleApps(context.Background(), sender, "juliet@capulet.lit")
+	if err != nil {
+		t.Fatalf("could not get Jingle applications: %v", err)
+	}
+	if len(apps) != 2 || apps[1].NS != "urn:xmpp:jingle:apps:file-transfer:5" {
+		t.Fatalf("unexpected applications: %+v", apps)
+	}
+	if audio, video := callableMedia(apps); !audio || video {
+		t.Errorf("contact should only accept audio calls: %v, %v", audio, video)
+	}
+}
@@ -0,0 +1,99 @@
+package xmpp
+
+import (
+	"sync"
+	"time"
+)
+
+// WhitespacePinger is implemented by transports that can send a single whitespace
+// character on the underlying connection as keepalive (RFC 6120 - 4.6.1).
+// The whitespace is written directly on the connection, bypassing the stanza encoder.
+type WhitespacePinger interface {
+	WhitespacePing() error
+}
+
+// WhitespacePongHandler tracks the health of a connection kept alive with whitespace pings.
+// Whitespace pings do not get any reply from the server: the connection is considered
+// alive as long as the ping could be written and reads on the connection complete without error.
+type WhitespacePongHandler struct {
+	// OnDead is called, if set, the first time the connection is detected as dead.
+	OnDead func(err error)
+
+	mu       sync.Mutex
+	lastPing time.Time
+	lastRead time.Time
+	err      error
+}
+
+// Alive returns true if no error was detected on the connection since the keepalive was started.
+func (h *WhitespacePongHandler) Alive() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.err == nil
+}
+
+// Err returns the error that caused the connection to be considered dead, if any.
+func (h *WhitespacePongHandler) Err() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.err
+}
+
+// LastPing returns the time the last whitespace ping was successfully written.
+func (h *WhitespacePongHandler) LastPing() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.lastPing
+}
+
+// LastRead returns the time the last read on the connection completed.
+func (h *WhitespacePongHandler) LastRead() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.lastRead
+}
+
+// reset clears the connection state, when a new connection is established.
+func (h *WhitespacePongHandler) reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastPing = time.Time{}
+	h.lastRead = time.Time{}
+	h.err = nil
+}
+
+// pingDone records the result of a whitespace ping write.
+func (h *WhitespacePongHandler) pingDone(err error) {
+	if err != nil {
+		h.fail(err)
+		return
+	}
+	h.mu.Lock()
+	h.lastPing = time.Now()
+	h.mu.Unlock()
+}
+
+// readDone records the result of a read on the connection.
+func (h *WhitespacePongHandler) readDone(err error) {
+	if err != nil {
+		h.fail(err)
+		return
+	}
+	h.mu.Lock()
+	h.lastRead = time.Now()
+	h.mu.Unlock()
+}
+
+func (h *WhitespacePongHandler) fail(err error) {
+	h.mu.Lock()
+	first := h.err == nil
+	if first {
+		h.err = err
+	}
+	onDead := h.OnDead
+	h.mu.Unlock()
+
+	if first && onDead != nil {
+		onDead(err)
+	}
+}
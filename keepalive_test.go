@@ -0,0 +1,128 @@
+package xmpp
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// writeRecorderConn is a net.Conn mock recording each write with its timestamp.
+type writeRecorderConn struct {
+	net.Conn
+	mu       sync.Mutex
+	writes   []string
+	times    []time.Time
+	writeErr error
+}
+
+func (c *writeRecorderConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.writeErr != nil {
+		return 0, c.writeErr
+	}
+	c.writes = append(c.writes, string(p))
+	c.times = append(c.times, time.Now())
+	return len(p), nil
+}
+
+func (c *writeRecorderConn) Close() error {
+	return nil
+}
+
+func (c *writeRecorderConn) recorded() ([]string, []time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string{}, c.writes...), append([]time.Time{}, c.times...)
+}
+
+func TestWhitespacePingInterval(t *testing.T) {
+	conn := &writeRecorderConn{}
+	transport := &XMPPTransport{conn: conn}
+	c := &Client{
+		config:    &Config{WhitespacePing: true, WhitespacePongHandler: &WhitespacePongHandler{}},
+		transport: transport,
+	}
+
+	interval := 50 * time.Millisecond
+	quit := make(chan struct{})
+	start := time.Now()
+	go keepalive(transport, c.keepalivePing(), interval, quit)
+	time.Sleep(4*interval + interval/2)
+	close(quit)
+
+	writes, times := conn.recorded()
+	if len(writes) < 3 || len(writes) > 4 {
+		t.Fatalf("expected 4 whitespace pings, got %d", len(writes))
+	}
+	previous := start
+	for i, w := range writes {
+		if w != " " {
+			t.Errorf("ping %d: expected a single space, got %q", i, w)
+		}
+		if elapsed := times[i].Sub(previous); elapsed < interval/2 || elapsed > 2*interval {
+			t.Errorf("ping %d: sent after %s, expected about %s", i, elapsed, interval)
+		}
+		previous = times[i]
+	}
+
+	h := c.config.WhitespacePongHandler
+	if !h.Alive() || h.LastPing().IsZero() {
+		t.Errorf("connection should be alive after successful pings")
+	}
+}
+
+func TestWhitespacePongHandlerDetectsFailure(t *testing.T) {
+	writeErr := errors.New("broken pipe")
+	conn := &writeRecorderConn{writeErr: writeErr}
+	transport := &XMPPTransport{conn: conn}
+
+	dead := make(chan error, 1)
+	h := &WhitespacePongHandler{OnDead: func(err error) { dead <- err }}
+	c := &Client{
+		config:    &Config{WhitespacePing: true, WhitespacePongHandler: h},
+		transport: transport,
+	}
+
+	quit := make(chan struct{})
+	defer close(quit)
+	go keepalive(transport, c.keepalivePing(), 10*time.Millisecond, quit)
+
+	select {
+	case err := <-dead:
+		if err != writeErr {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(defaultTimeout):
+		t.Fatalf("connection failure was not detected")
+	}
+	if h.Alive() || h.Err() != writeErr {
+		t.Errorf("connection should be reported as dead")
+	}
+
+	// A read error is also reported, but OnDead is only called once
+	h.readDone(errors.New("read error"))
+	if h.Err() != writeErr {
+		t.Errorf("first error should be kept, got %v", h.Err())
+	}
+	select {
+	case <-dead:
+		t.Errorf("OnDead should only be called once")
+	default:
+	}
+}
+
+func TestKeepaliveDefaultsToTransportPing(t *testing.T) {
+	conn := &writeRecorderConn{}
+	transport := &XMPPTransport{conn: conn}
+	c := &Client{config: &Config{}, transport: transport}
+
+	if err := c.keepalivePing()(); err != nil {
+		t.Fatalf("ping failed: %v", err)
+	}
+	if writes, _ := conn.recorded(); len(writes) != 1 || writes[0] != "\n" {
+		t.Errorf("expected transport ping, got %q", writes)
+	}
+}
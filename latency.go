@@ -0,0 +1,121 @@
+package xmpp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Latency probe, using XMPP Ping (XEP-0199)
+
+const (
+	defaultLatencyProbeInterval = time.Second
+	defaultLatencyProbeTimeout  = 5 * time.Second
+)
+
+// LatencyStats summarizes the round-trip times of the pings sent by ProbeLatency.
+// Error replies are counted as received, as they prove the entity is reachable.
+type LatencyStats struct {
+	Sent     int
+	Received int
+	Min      time.Duration
+	Avg      time.Duration
+	Max      time.Duration
+	// Loss is the percentage of pings that were not answered in time
+	Loss float64
+}
+
+// ProbeLatency sends samples pings to the JID, spaced by Config.LatencyProbeInterval, and returns
+// their round-trip times. It can be used to pick the closest of several components.
+// Pings still sent when ctx is done are counted as lost.
+func (c *Client) ProbeLatency(ctx context.Context, jid string, samples int) (LatencyStats, error) {
+	return probeLatency(ctx, c, jid, samples, c.config.LatencyProbeInterval, c.config.LatencyProbeTimeout)
+}
+
+func probeLatency(ctx context.Context, s Sender, jid string, samples int, interval, timeout time.Duration) (LatencyStats, error) {
+	if samples <= 0 {
+		return LatencyStats{}, errors.New("at least one ping must be sent to probe latency")
+	}
+	if interval <= 0 {
+		interval = defaultLatencyProbeInterval
+	}
+	if timeout <= 0 {
+		timeout = defaultLatencyProbeTimeout
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		rtts    []time.Duration
+		sendErr error
+		stats   LatencyStats
+	)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+probe:
+	for i := 0; i < samples; i++ {
+		if i > 0 {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				break probe
+			}
+		}
+		iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, To: jid})
+		if err != nil {
+			return LatencyStats{}, err
+		}
+		iq.Ping()
+		stats.Sent++
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pingCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			start := time.Now()
+			res, err := s.SendIQ(pingCtx, iq)
+			if err != nil {
+				mu.Lock()
+				sendErr = err
+				mu.Unlock()
+				return
+			}
+			select {
+			case _, ok := <-res:
+				if !ok {
+					return
+				}
+				mu.Lock()
+				rtts = append(rtts, time.Since(start))
+				mu.Unlock()
+			case <-pingCtx.Done():
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats.Received = len(rtts)
+	stats.Loss = float64(stats.Sent-stats.Received) * 100 / float64(stats.Sent)
+	if stats.Received == 0 {
+		return stats, sendErr
+	}
+	var total time.Duration
+	stats.Min = rtts[0]
+	for _, rtt := range rtts {
+		total += rtt
+		if rtt < stats.Min {
+			stats.Min = rtt
+		}
+		if rtt > stats.Max {
+			stats.Max = rtt
+		}
+	}
+	stats.Avg = total / time.Duration(stats.Received)
+	return stats, nil
+}
@@ -0,0 +1,82 @@
+package xmpp
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// pingResponder answers the pings in order with the scripted reply types. Pings scripted with an
+// empty type are never answered.
+type pingResponder struct {
+	SenderMock
+	delay time.Duration
+
+	mu      sync.Mutex
+	replies []stanza.StanzaType
+	pings   int
+}
+
+func (p *pingResponder) SendIQ(ctx context.Context, iq *stanza.IQ) (chan stanza.IQ, error) {
+	if _, ok := iq.Payload.(*stanza.Ping); !ok {
+		return nil, errors.New("only pings are expected")
+	}
+	p.mu.Lock()
+	reply := p.replies[p.pings]
+	p.pings++
+	p.mu.Unlock()
+
+	res := make(chan stanza.IQ, 1)
+	if reply != "" {
+		answer := stanza.IQ{XMLName: xml.Name{Local: "iq"}, Attrs: stanza.Attrs{Type: reply, Id: iq.Id, From: iq.To}}
+		if reply == stanza.IQTypeError {
+			answer.Error = &stanza.Err{Code: 501, Type: stanza.ErrorTypeCancel, Reason: "service-unavailable"}
+		}
+		time.AfterFunc(p.delay, func() { res <- answer })
+	}
+	return res, nil
+}
+
+func TestProbeLatency(t *testing.T) {
+	responder := &pingResponder{
+		SenderMock: NewSenderMock(),
+		delay:      5 * time.Millisecond,
+		replies:    []stanza.StanzaType{stanza.IQTypeResult, stanza.IQTypeError, "", stanza.IQTypeResult},
+	}
+	stats, err := probeLatency(context.Background(), responder, "gateway.example.com", 4, time.Millisecond, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("could not probe latency: %v", err)
+	}
+	if responder.pings != 4 || stats.Sent != 4 {
+		t.Errorf("expected 4 pings, got %d", responder.pings)
+	}
+	// Error replies prove the entity is reachable
+	if stats.Received != 3 || stats.Loss != 25 {
+		t.Errorf("unexpected loss: %+v", stats)
+	}
+	if stats.Min < responder.delay || stats.Min > stats.Avg || stats.Avg > stats.Max {
+		t.Errorf("inconsistent round-trip times: %+v", stats)
+	}
+}
+
+func TestProbeLatencyAllLost(t *testing.T) {
+	responder := &pingResponder{SenderMock: NewSenderMock(), replies: []stanza.StanzaType{"", ""}}
+	stats, err := probeLatency(context.Background(), responder, "gateway.example.com", 2, time.Millisecond, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("lost pings should not be reported as an error: %v", err)
+	}
+	if stats.Received != 0 || stats.Loss != 100 || stats.Max != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestProbeLatencyInvalidSamples(t *testing.T) {
+	if _, err := probeLatency(context.Background(), NewSenderMock(), "gateway.example.com", 0, 0, 0); err == nil {
+		t.Errorf("probing with no sample should fail")
+	}
+}
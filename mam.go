@@ -0,0 +1,229 @@
+package xmpp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Message Archive Management preferences (XEP-0313)
+
+var ErrMAMNotSupported = errors.New("message archive management is not supported by the server")
+
+// MAMPreferences are the archiving preferences of the user account.
+// Default is one of stanza.MAMDefaultAlways, stanza.MAMDefaultNever or stanza.MAMDefaultRoster.
+// Always and Never list the JIDs whose messages are always, or never, archived.
+type MAMPreferences struct {
+	Default string
+	Always  []string
+	Never   []string
+}
+
+// GetMAMPreferences retrieves the archiving preferences of the user account.
+func (c *Client) GetMAMPreferences(ctx context.Context) (MAMPreferences, error) {
+	return getMAMPreferences(ctx, c)
+}
+
+// SetMAMPreferences replaces the archiving preferences of the user account.
+// Preferences are sent in a single IQ, whatever the size of the JID lists.
+func (c *Client) SetMAMPreferences(ctx context.Context, prefs MAMPreferences) error {
+	return setMAMPreferences(ctx, c, prefs)
+}
+
+func getMAMPreferences(ctx context.Context, s Sender) (MAMPreferences, error) {
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet})
+	if err != nil {
+		return MAMPreferences{}, err
+	}
+	iq.MAMPrefs()
+	return sendMAMPrefs(ctx, s, iq)
+}
+
+func setMAMPreferences(ctx context.Context, s Sender, prefs MAMPreferences) error {
+	switch prefs.Default {
+	case stanza.MAMDefaultAlways, stanza.MAMDefaultNever, stanza.MAMDefaultRoster:
+	default:
+		return errors.New("invalid MAM default preference: " + prefs.Default)
+	}
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeSet})
+	if err != nil {
+		return err
+	}
+	p := iq.MAMPrefs()
+	p.Default = prefs.Default
+	p.Always = uniqueJids(prefs.Always)
+	p.Never = uniqueJids(prefs.Never)
+	_, err = sendMAMPrefs(ctx, s, iq)
+	return err
+}
+
+// sendMAMPrefs sends a prefs IQ and decodes the preferences returned by the server.
+func sendMAMPrefs(ctx context.Context, s Sender, iq *stanza.IQ) (MAMPreferences, error) {
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return MAMPreferences{}, err
+	}
+	if result.Type == stanza.IQTypeError && result.Error != nil {
+		switch result.Error.Reason {
+		case "feature-not-implemented", "service-unavailable":
+			return MAMPreferences{}, ErrMAMNotSupported
+		}
+	}
+	if err = iqError(result); err != nil {
+		return MAMPreferences{}, err
+	}
+	// The server may answer a set request with an empty result
+	p, ok := result.Payload.(*stanza.MAMPrefs)
+	if !ok {
+		return MAMPreferences{}, nil
+	}
+	return MAMPreferences{
+		Default: p.Default,
+		Always:  uniqueJids(p.Always),
+		Never:   uniqueJids(p.Never),
+	}, nil
+}
+
+// uniqueJids returns the JIDs of the list, without duplicates, in their original order.
+func uniqueJids(jids []string) []string {
+	if len(jids) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool, len(jids))
+	out := make([]string, 0, len(jids))
+	for _, jid := range jids {
+		if jid == "" || seen[jid] {
+			continue
+		}
+		seen[jid] = true
+		out = append(out, jid)
+	}
+	return out
+}
+
+// ============================================================================
+// Archive queries
+
+// MAMQuery filters the messages returned by an archive query. With only returns the messages
+// exchanged with a JID, and Start and End bound their time. Max is the maximum number of messages
+// returned, and After the archive id of the last message of the previous page.
+// AfterID and BeforeID bound the messages by archive id, excluding the given messages. They are
+// only supported by servers advertising stanza.NSMamExtended.
+type MAMQuery struct {
+	With     string
+	Start    time.Time
+	End      time.Time
+	AfterID  string
+	BeforeID string
+	Max      int
+	After    string
+}
+
+// newMAMQueryIQ builds the IQ querying the archive of to, with a new query id.
+func newMAMQueryIQ(to string, opts MAMQuery) (*stanza.IQ, string, error) {
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeSet, To: to})
+	if err != nil {
+		return nil, "", err
+	}
+	queryId := uuid.New().String()
+	q := iq.MAMQuery(queryId)
+	q.AddFilter("with", opts.With)
+	if !opts.Start.IsZero() {
+		q.AddFilter("start", opts.Start.UTC().Format(time.RFC3339))
+	}
+	if !opts.End.IsZero() {
+		q.AddFilter("end", opts.End.UTC().Format(time.RFC3339))
+	}
+	q.AddFilter("after-id", opts.AfterID)
+	q.AddFilter("before-id", opts.BeforeID)
+	if opts.Max > 0 || opts.After != "" {
+		q.ResultSet = stanza.NewRSMQuery(opts.Max, opts.After).ResultSet()
+	}
+	return iq, queryId, nil
+}
+
+// queryArchive sends an archive query and returns the results collected until the server
+// answers it.
+func queryArchive(ctx context.Context, s Sender, queries *mamQueries, iq *stanza.IQ, queryId string) ([]stanza.MAMResult, error) {
+	archive := iq.To
+	if c, ok := s.(*Client); ok && archive == "" {
+		archive = c.BareJID()
+	}
+	queries.start(queryId, archive, iq.To == "")
+	defer queries.stop(queryId)
+
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return nil, err
+	}
+	if err = iqError(result); err != nil {
+		return nil, err
+	}
+	return queries.results(queryId), nil
+}
+
+// mamQueries collects the results of the archive queries in progress, by query id.
+type mamQueries struct {
+	mu      sync.Mutex
+	pending map[string]*mamQuery
+}
+
+type mamQuery struct {
+	// Archive queried: results sent by another entity are ignored
+	archive string
+	// The account archive also sends results without from attribute
+	account bool
+	results []stanza.MAMResult
+}
+
+func (q *mamQueries) start(queryId, archive string, account bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pending == nil {
+		q.pending = make(map[string]*mamQuery)
+	}
+	q.pending[queryId] = &mamQuery{archive: archive, account: account}
+}
+
+func (q *mamQueries) stop(queryId string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.pending, queryId)
+}
+
+func (q *mamQueries) results(queryId string) []stanza.MAMResult {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if query, ok := q.pending[queryId]; ok {
+		return query.results
+	}
+	return nil
+}
+
+// collect records the packet if it is a result of a query in progress. It returns true if the packet
+// was collected. Results are collected as they are received, so that they are all known when the
+// server answers the query.
+func (q *mamQueries) collect(p stanza.Packet) bool {
+	msg, ok := p.(stanza.Message)
+	if !ok {
+		return false
+	}
+	var result stanza.MAMResult
+	if !msg.Get(&result) || result.QueryId == "" {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	query, ok := q.pending[result.QueryId]
+	if !ok || !(strings.EqualFold(bareJid(msg.From), bareJid(query.archive)) || (query.account && msg.From == "")) {
+		return false
+	}
+	query.results = append(query.results, result)
+	return true
+}
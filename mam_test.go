@@ -0,0 +1,73 @@
+package xmpp
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestSetMAMPreferences(t *testing.T) {
+	always := []string{"romeo@montague.lit", "juliet@capulet.lit", "romeo@montague.lit"}
+	var never []string
+	for i := 0; i < 500; i++ {
+		never = append(never, fmt.Sprintf("user%d@capulet.lit", i))
+	}
+
+	for _, def := range []string{stanza.MAMDefaultAlways, stanza.MAMDefaultNever, stanza.MAMDefaultRoster} {
+		sender := &scriptedIQSender{t: t, responses: []string{`<iq type='result'/>`}}
+		err := setMAMPreferences(context.Background(), sender, MAMPreferences{Default: def, Always: always, Never: never})
+		if err != nil {
+			t.Fatalf("could not set preferences: %v", err)
+		}
+		if len(sender.requests) != 1 {
+			t.Fatalf("preferences should be sent in a single IQ, got %d", len(sender.requests))
+		}
+		p, ok := sender.requests[0].Payload.(*stanza.MAMPrefs)
+		if !ok || sender.requests[0].Type != stanza.IQTypeSet {
+			t.Fatalf("unexpected request: %+v", sender.requests[0])
+		}
+		if p.Default != def || len(p.Always) != 2 || len(p.Never) != 500 {
+			t.Errorf("unexpected preferences sent: default=%s always=%v never=%d", p.Default, p.Always, len(p.Never))
+		}
+	}
+}
+
+func TestSetMAMPreferencesInvalidDefault(t *testing.T) {
+	sender := &scriptedIQSender{t: t}
+	if err := setMAMPreferences(context.Background(), sender, MAMPreferences{Default: "sometimes"}); err == nil {
+		t.Errorf("invalid default should be rejected")
+	}
+}
+
+func TestGetMAMPreferences(t *testing.T) {
+	response := `<iq type='result'>
+  <prefs xmlns='urn:xmpp:mam:2' default='always'>
+    <always><jid>romeo@montague.lit</jid><jid>romeo@montague.lit</jid></always>
+    <never><jid>montague@montague.lit</jid></never>
+  </prefs>
+</iq>`
+	sender := &scriptedIQSender{t: t, responses: []string{response}}
+	prefs, err := getMAMPreferences(context.Background(), sender)
+	if err != nil {
+		t.Fatalf("could not get preferences: %v", err)
+	}
+	if sender.requests[0].Type != stanza.IQTypeGet {
+		t.Errorf("preferences should be requested with a get IQ")
+	}
+	if prefs.Default != stanza.MAMDefaultAlways || len(prefs.Always) != 1 || len(prefs.Never) != 1 {
+		t.Errorf("unexpected preferences: %+v", prefs)
+	}
+}
+
+func TestGetMAMPreferencesNotSupported(t *testing.T) {
+	response := `<iq type='error'>
+  <prefs xmlns='urn:xmpp:mam:2'/>
+  <error type='cancel'><feature-not-implemented xmlns='urn:ietf:params:xml:ns:xmpp-stanzas'/></error>
+</iq>`
+	sender := &scriptedIQSender{t: t, responses: []string{response}}
+	if _, err := getMAMPreferences(context.Background(), sender); err != ErrMAMNotSupported {
+		t.Errorf("expected ErrMAMNotSupported, got %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package xmpp
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"sync"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Chat Markers (XEP-0333)
+
+// MarkConversationRead sends a single displayed marker for the latest message of the conversation.
+// As markers apply to all the previous messages, there is no need to send a marker per message.
+// to is the address the latest message was received from, as returned by stanza.ReplyAddress.
+func (c *Client) MarkConversationRead(ctx context.Context, to, latestMessageID string) error {
+	return markConversationRead(ctx, c, to, latestMessageID)
+}
+
+func markConversationRead(ctx context.Context, s Sender, to, latestMessageID string) error {
+	if to == "" || latestMessageID == "" {
+		return errors.New("a recipient and a message id are required to send a displayed marker")
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	msg := stanza.NewMessage(stanza.Attrs{To: to, Type: stanza.MessageTypeChat})
+	msg.Extensions = append(msg.Extensions, stanza.MarkDisplayed{
+		XMLName: xml.Name{Space: stanza.NSMsgChatMarkers, Local: "displayed"},
+		ID:      latestMessageID,
+	})
+	return s.Send(msg)
+}
+
+// ConversationTracker keeps the id of the last message received from each contact, to send displayed
+// markers only when new messages have been displayed. Conversations are tracked by reply address, so
+// that the private conversations with the occupants of a room are kept apart.
+type ConversationTracker struct {
+	sender Sender
+
+	mu sync.Mutex
+	// Conversations by reply address
+	conversations map[string]*conversationMarkers
+}
+
+type conversationMarkers struct {
+	lastID   string
+	markedID string
+}
+
+// NewConversationTracker returns a tracker sending the markers with s, usually the client.
+func NewConversationTracker(s Sender) *ConversationTracker {
+	return &ConversationTracker{
+		sender:        s,
+		conversations: make(map[string]*conversationMarkers),
+	}
+}
+
+// RecordReceived records a markable message received from a contact.
+func (t *ConversationTracker) RecordReceived(from, id string) {
+	if from == "" || id == "" {
+		return
+	}
+	key := stanza.ReplyAddress(from)
+	if key == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	conv, ok := t.conversations[key]
+	if !ok {
+		conv = &conversationMarkers{}
+		t.conversations[key] = conv
+	}
+	conv.lastID = id
+}
+
+// MarkAsDisplayed sends a displayed marker for the last message received from the contact, unless it
+// has already been sent. It can be called on every display update.
+// With a full JID, only the conversation with that JID is marked. With a bare JID, the conversations
+// with all the resources of the contact are marked, each marker being sent to its full JID: markers
+// for private messages are never sent to the room.
+func (t *ConversationTracker) MarkAsDisplayed(ctx context.Context, from string) error {
+	jid, err := stanza.NewJid(from)
+	if err != nil {
+		return err
+	}
+	var pending []string
+	t.mu.Lock()
+	for to, conv := range t.conversations {
+		if to == jid.Full() || (jid.Resource == "" && bareJid(to) == jid.Bare()) {
+			if conv.lastID != conv.markedID {
+				pending = append(pending, to)
+			}
+		}
+	}
+	t.mu.Unlock()
+
+	for _, to := range pending {
+		if err := t.markDisplayed(ctx, to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *ConversationTracker) markDisplayed(ctx context.Context, to string) error {
+	t.mu.Lock()
+	conv := t.conversations[to]
+	if conv.lastID == conv.markedID {
+		t.mu.Unlock()
+		return nil
+	}
+	id, previous := conv.lastID, conv.markedID
+	// Marked before sending, so that concurrent calls do not send the same marker
+	conv.markedID = id
+	t.mu.Unlock()
+
+	err := markConversationRead(ctx, t.sender, to, id)
+	if err != nil {
+		t.mu.Lock()
+		if conv.markedID == id {
+			conv.markedID = previous
+		}
+		t.mu.Unlock()
+	}
+	return err
+}
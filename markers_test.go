@@ -0,0 +1,112 @@
+package xmpp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestMarkConversationRead(t *testing.T) {
+	sender := NewSenderMock()
+	if err := markConversationRead(context.Background(), sender, "juliet@capulet.lit", "message-3"); err != nil {
+		t.Fatalf("could not send marker: %v", err)
+	}
+	expected := `<message type="chat" to="juliet@capulet.lit"><displayed xmlns="urn:xmpp:chat-markers:0" id="message-3"></displayed></message>`
+	if sender.String() != expected {
+		t.Errorf("unexpected marker:\n%s\nexpected:\n%s", sender.String(), expected)
+	}
+}
+
+func TestConversationTracker(t *testing.T) {
+	sender := NewSenderMock()
+	tracker := NewConversationTracker(sender)
+	ctx := context.Background()
+
+	for _, id := range []string{"message-1", "message-2", "message-3"} {
+		tracker.RecordReceived("juliet@capulet.lit/balcony", id)
+	}
+	for i := 0; i < 3; i++ {
+		if err := tracker.MarkAsDisplayed(ctx, "juliet@capulet.lit"); err != nil {
+			t.Fatalf("could not mark as displayed: %v", err)
+		}
+	}
+	out := sender.String()
+	if strings.Count(out, "<displayed") != 1 || !strings.Contains(out, `id="message-3"`) || !strings.Contains(out, `to="juliet@capulet.lit/balcony"`) {
+		t.Fatalf("a single marker should be sent for the latest message: %s", out)
+	}
+
+	// A new message must be marked again
+	tracker.RecordReceived("juliet@capulet.lit/chamber", "message-4")
+	if err := tracker.MarkAsDisplayed(ctx, "juliet@capulet.lit/chamber"); err != nil {
+		t.Fatalf("could not mark as displayed: %v", err)
+	}
+	// Unknown conversations have nothing to mark
+	if err := tracker.MarkAsDisplayed(ctx, "romeo@montague.lit"); err != nil {
+		t.Fatalf("could not mark as displayed: %v", err)
+	}
+	out = sender.String()
+	if strings.Count(out, "<displayed") != 2 || !strings.Contains(out, `id="message-4"`) {
+		t.Errorf("a marker should be sent for the new message: %s", out)
+	}
+}
+
+type failingSender struct {
+	SenderMock
+	fail bool
+}
+
+func (s *failingSender) Send(packet stanza.Packet) error {
+	if s.fail {
+		return errors.New("connection lost")
+	}
+	return s.SenderMock.Send(packet)
+}
+
+func TestConversationTrackerSendError(t *testing.T) {
+	sender := &failingSender{SenderMock: NewSenderMock(), fail: true}
+	tracker := NewConversationTracker(sender)
+	tracker.RecordReceived("juliet@capulet.lit/balcony", "message-1")
+	if err := tracker.MarkAsDisplayed(context.Background(), "juliet@capulet.lit"); err == nil {
+		t.Fatal("send error should be returned")
+	}
+
+	// The marker is sent again once the connection is back
+	sender.fail = false
+	if err := tracker.MarkAsDisplayed(context.Background(), "juliet@capulet.lit"); err != nil {
+		t.Fatalf("could not mark as displayed: %v", err)
+	}
+	if !strings.Contains(sender.String(), `id="message-1"`) {
+		t.Errorf("marker should be sent after a failure: %s", sender.String())
+	}
+}
+
+func TestConversationTrackerMUCPrivateMessages(t *testing.T) {
+	sender := NewSenderMock()
+	tracker := NewConversationTracker(sender)
+	ctx := context.Background()
+
+	tracker.RecordReceived("coven@chat.shakespeare.lit/firstwitch", "pm-1")
+	tracker.RecordReceived("coven@chat.shakespeare.lit/secondwitch", "pm-2")
+	if err := tracker.MarkAsDisplayed(ctx, "coven@chat.shakespeare.lit/firstwitch"); err != nil {
+		t.Fatalf("could not mark as displayed: %v", err)
+	}
+	expected := `<message type="chat" to="coven@chat.shakespeare.lit/firstwitch"><displayed xmlns="urn:xmpp:chat-markers:0" id="pm-1"></displayed></message>`
+	if sender.String() != expected {
+		t.Fatalf("marker should only be sent to the occupant:\n%s", sender.String())
+	}
+
+	// Marking the whole room marks each private conversation, never the room itself
+	if err := tracker.MarkAsDisplayed(ctx, "coven@chat.shakespeare.lit"); err != nil {
+		t.Fatalf("could not mark as displayed: %v", err)
+	}
+	out := sender.String()
+	if strings.Contains(out, `to="coven@chat.shakespeare.lit"`) {
+		t.Errorf("private marker sent to the room: %s", out)
+	}
+	if strings.Count(out, "<displayed") != 2 || !strings.Contains(out, `to="coven@chat.shakespeare.lit/secondwitch"><displayed xmlns="urn:xmpp:chat-markers:0" id="pm-2">`) {
+		t.Errorf("unexpected markers: %s", out)
+	}
+}
@@ -0,0 +1,225 @@
+package xmpp
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Microblogging over XMPP (XEP-0277)
+
+// AtomEntry is a microblog post.
+type AtomEntry struct {
+	// ID is the Atom id of the entry, also used as PubSub item id. It is generated when publishing
+	// an entry without ID.
+	ID      string
+	Title   string
+	Summary string
+	Content string
+	// ContentType is the type of Content, stanza.AtomTextTypeText (default) or stanza.AtomTextTypeHTML
+	ContentType string
+	// Link is the URL of the alternate representation of the entry, usually a web page
+	Link      string
+	Published time.Time
+	Updated   time.Time
+}
+
+// MicroblogEventHandler receives the microblog entries published by the contacts. PEP only sends the
+// notifications if the client advertises the "urn:xmpp:microblog:0+notify" feature in its
+// capabilities: Client.HandleMicroblogEvents registers the handler and advertises the feature.
+// Route only registers the handler on the router.
+type MicroblogEventHandler func(from string, entry AtomEntry)
+
+// PublishMicroblogEntry publishes the entry on the account microblog and returns the item id.
+// Published and Updated default to the current time.
+func (c *Client) PublishMicroblogEntry(ctx context.Context, entry AtomEntry) (string, error) {
+	return publishMicroblogEntry(ctx, c, entry)
+}
+
+// GetMicroblogFeed fetches the microblog entries published by the JID.
+func (c *Client) GetMicroblogFeed(ctx context.Context, jid string) ([]AtomEntry, error) {
+	return getMicroblogFeed(ctx, c, jid)
+}
+
+// HandleMicroblogEvents registers the handler on the router, to receive the microblog notifications,
+// and advertises the "urn:xmpp:microblog:0+notify" feature in caps, as HandlePEPEvents does.
+func (c *Client) HandleMicroblogEvents(router *Router, caps *CapsResponder, h MicroblogEventHandler) (*PEPRegistration, error) {
+	return c.HandlePEPEvents(router, caps, stanza.NodeMicroblog, h.handleItems)
+}
+
+// Route registers the handler on the router, to receive the microblog notifications.
+func (h MicroblogEventHandler) Route(router *Router) *Route {
+	return router.NewRoute().AddMatcher(microblogMatcher{}).Handler(h)
+}
+
+// HandlePacket decodes the entries of a microblog notification and passes them to the handler.
+// It implements the router Handler interface.
+func (h MicroblogEventHandler) HandlePacket(_ Sender, p stanza.Packet) {
+	msg, ok := p.(stanza.Message)
+	if !ok {
+		return
+	}
+	if items, ok := microblogItems(msg); ok {
+		h.handleItems(msg.From, items)
+	}
+}
+
+// handleItems decodes the entries of the notification items and passes them to the handler.
+func (h MicroblogEventHandler) handleItems(from string, items *stanza.ItemsEvent) {
+	for _, ie := range items.Items {
+		item := stanza.Item{Id: ie.Id, Any: ie.Any}
+		var entry stanza.AtomEntry
+		if item.DecodePayload(&entry) != nil {
+			continue
+		}
+		h(from, newAtomEntry(entry))
+	}
+}
+
+type microblogMatcher struct{}
+
+func (microblogMatcher) Match(p stanza.Packet, _ *RouteMatch) bool {
+	msg, ok := p.(stanza.Message)
+	if !ok {
+		return false
+	}
+	_, ok = microblogItems(msg)
+	return ok
+}
+
+// microblogItems returns the items of the microblog notification carried by the message, if any.
+func microblogItems(msg stanza.Message) (*stanza.ItemsEvent, bool) {
+	var event stanza.PubSubEvent
+	if !msg.Get(&event) {
+		return nil, false
+	}
+	items, ok := event.EventElement.(*stanza.ItemsEvent)
+	if !ok || items.Node != stanza.NodeMicroblog {
+		return nil, false
+	}
+	return items, true
+}
+
+func publishMicroblogEntry(ctx context.Context, s Sender, entry AtomEntry) (string, error) {
+	if entry.Title == "" {
+		return "", errors.New("a title is required to publish a microblog entry")
+	}
+	itemId := entry.ID
+	if itemId == "" {
+		id, err := uuid.NewRandom()
+		if err != nil {
+			return "", err
+		}
+		itemId = id.String()
+		entry.ID = id.URN()
+	}
+	now := time.Now()
+	if entry.Published.IsZero() {
+		entry.Published = now
+	}
+	if entry.Updated.IsZero() {
+		entry.Updated = now
+	}
+
+	item, err := stanza.NewPayloadItem(itemId, newStanzaAtomEntry(entry))
+	if err != nil {
+		return "", err
+	}
+	iq, err := stanza.NewPublishItemRq("", stanza.NodeMicroblog, itemId, item)
+	if err != nil {
+		return "", err
+	}
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return "", err
+	}
+	if err = iqError(result); err != nil {
+		return "", err
+	}
+	// The service may return the id it assigned to the item
+	if ps, ok := result.Payload.(*stanza.PubSubGeneric); ok && ps.Publish != nil &&
+		len(ps.Publish.Items) > 0 && ps.Publish.Items[0].Id != "" {
+		return ps.Publish.Items[0].Id, nil
+	}
+	return itemId, nil
+}
+
+func getMicroblogFeed(ctx context.Context, s Sender, jid string) ([]AtomEntry, error) {
+	iq, err := stanza.NewItemsRequest(jid, stanza.NodeMicroblog, 0)
+	if err != nil {
+		return nil, err
+	}
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return nil, err
+	}
+	if isItemNotFound(result) {
+		// Nothing was ever published
+		return nil, nil
+	}
+	if err = iqError(result); err != nil {
+		return nil, err
+	}
+	ps, ok := result.Payload.(*stanza.PubSubGeneric)
+	if !ok || ps.Items == nil {
+		return nil, errors.New("invalid microblog feed response")
+	}
+
+	entries := make([]AtomEntry, 0, len(ps.Items.List))
+	for _, item := range ps.Items.List {
+		var entry stanza.AtomEntry
+		if err = item.DecodePayload(&entry); err != nil {
+			// Skip items that are not Atom entries
+			continue
+		}
+		entries = append(entries, newAtomEntry(entry))
+	}
+	return entries, nil
+}
+
+func newStanzaAtomEntry(entry AtomEntry) stanza.AtomEntry {
+	e := stanza.AtomEntry{
+		Title:     &stanza.AtomText{Type: stanza.AtomTextTypeText, Text: entry.Title},
+		Id:        entry.ID,
+		Published: entry.Published.UTC().Format(time.RFC3339),
+		Updated:   entry.Updated.UTC().Format(time.RFC3339),
+	}
+	if entry.Summary != "" {
+		e.Summary = &stanza.AtomText{Type: stanza.AtomTextTypeText, Text: entry.Summary}
+	}
+	if entry.Content != "" {
+		contentType := entry.ContentType
+		if contentType == "" {
+			contentType = stanza.AtomTextTypeText
+		}
+		e.Content = &stanza.AtomText{Type: contentType, Text: entry.Content}
+	}
+	if entry.Link != "" {
+		e.Links = append(e.Links, stanza.AtomLink{Rel: "alternate", Href: entry.Link, Type: "text/html"})
+	}
+	return e
+}
+
+func newAtomEntry(e stanza.AtomEntry) AtomEntry {
+	entry := AtomEntry{ID: e.Id, Link: e.Link("alternate")}
+	if e.Title != nil {
+		entry.Title = e.Title.Text
+	}
+	if e.Summary != nil {
+		entry.Summary = e.Summary.Text
+	}
+	if e.Content != nil {
+		entry.Content = e.Content.Text
+		entry.ContentType = e.Content.Type
+		if entry.ContentType == "" {
+			entry.ContentType = stanza.AtomTextTypeText
+		}
+	}
+	entry.Published, _ = time.Parse(time.RFC3339, e.Published)
+	entry.Updated, _ = time.Parse(time.RFC3339, e.Updated)
+	return entry
+}
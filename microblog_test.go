@@ -0,0 +1,118 @@
+package xmpp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestPublishMicroblogEntry(t *testing.T) {
+	response := `<iq type='result'>
+  <pubsub xmlns='http://jabber.org/protocol/pubsub'>
+    <publish node='urn:xmpp:microblog:0'><item id='1cb57d9c-1c46-11dd-838c-001143d5d5db'/></publish>
+  </pubsub>
+</iq>`
+	sender := &scriptedIQSender{t: t, responses: []string{response}}
+	entry := AtomEntry{Title: "Verona", Content: "<p>Fair Verona</p>", ContentType: stanza.AtomTextTypeHTML}
+	id, err := publishMicroblogEntry(context.Background(), sender, entry)
+	if err != nil {
+		t.Fatalf("could not publish entry: %v", err)
+	}
+	if id != "1cb57d9c-1c46-11dd-838c-001143d5d5db" {
+		t.Errorf("unexpected item id: %s", id)
+	}
+
+	ps, ok := sender.requests[0].Payload.(*stanza.PubSubGeneric)
+	if !ok || ps.Publish == nil || ps.Publish.Node != stanza.NodeMicroblog || len(ps.Publish.Items) != 1 {
+		t.Fatalf("unexpected request: %+v", sender.requests[0].Payload)
+	}
+	var published stanza.AtomEntry
+	if err = ps.Publish.Items[0].DecodePayload(&published); err != nil {
+		t.Fatalf("published item is not an Atom entry: %v", err)
+	}
+	if published.Id == "" || published.Updated == "" || published.Content == nil ||
+		published.Content.Type != stanza.AtomTextTypeHTML || published.Content.Text != "<p>Fair Verona</p>" {
+		t.Errorf("unexpected published entry: %+v", published)
+	}
+}
+
+func TestGetMicroblogFeed(t *testing.T) {
+	response := `<iq type='result' from='romeo@montague.lit'>
+  <pubsub xmlns='http://jabber.org/protocol/pubsub'>
+    <items node='urn:xmpp:microblog:0'>
+      <item id='1cb57d9c-1c46-11dd-838c-001143d5d5db'>
+        <entry xmlns='http://www.w3.org/2005/Atom'>
+          <title type='text'>hanging out at the Caf&#233; Napolitano</title>
+          <link rel='alternate' type='text/html' href='http://montague.lit/romeo/posts/1cb57d9c'/>
+          <id>tag:montague.lit,2008-05-08:posts-1cb57d9c-1c46-11dd-838c-001143d5d5db</id>
+          <published>2008-05-08T18:30:02Z</published>
+          <updated>2008-05-08T18:30:02Z</updated>
+        </entry>
+      </item>
+    </items>
+  </pubsub>
+</iq>`
+	sender := &scriptedIQSender{t: t, responses: []string{response}}
+	entries, err := getMicroblogFeed(context.Background(), sender, "romeo@montague.lit")
+	if err != nil {
+		t.Fatalf("could not get feed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected one entry, got %+v", entries)
+	}
+	e := entries[0]
+	if e.Title != "hanging out at the Café Napolitano" || e.Link != "http://montague.lit/romeo/posts/1cb57d9c" ||
+		!e.Published.Equal(time.Date(2008, 5, 8, 18, 30, 2, 0, time.UTC)) {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+}
+
+func TestMicroblogEventHandler(t *testing.T) {
+	msg := parseMessage(t, `<message from='romeo@montague.lit' to='juliet@capulet.lit' type='headline'>
+  <event xmlns='http://jabber.org/protocol/pubsub#event'>
+    <items node='urn:xmpp:microblog:0'>
+      <item id='1cb57d9c-1c46-11dd-838c-001143d5d5db'>
+        <entry xmlns='http://www.w3.org/2005/Atom'>
+          <title type='text'>Verona</title>
+          <content type='html'>&lt;b&gt;Fair&lt;/b&gt; Verona</content>
+          <id>tag:montague.lit,2008-05-08:posts-1cb57d9c</id>
+          <updated>2008-05-08T18:30:02Z</updated>
+        </entry>
+      </item>
+    </items>
+  </event>
+</message>`)
+
+	var from string
+	var entries []AtomEntry
+	handler := MicroblogEventHandler(func(f string, entry AtomEntry) {
+		from = f
+		entries = append(entries, entry)
+	})
+	router := NewRouter()
+	handler.Route(router)
+	router.route(NewSenderMock(), msg)
+
+	if from != "romeo@montague.lit" || len(entries) != 1 {
+		t.Fatalf("unexpected notification from %s: %+v", from, entries)
+	}
+	if entries[0].Content != "<b>Fair</b> Verona" || entries[0].ContentType != stanza.AtomTextTypeHTML {
+		t.Errorf("unexpected entry: %+v", entries[0])
+	}
+
+	// Registered with the client, the handler advertises the notify feature
+	caps := NewCapsResponder(capsTestNode, capsTestInfo())
+	router = NewRouter()
+	if _, err := (&Client{}).HandleMicroblogEvents(router, caps, handler); err != nil {
+		t.Fatalf("could not register handler: %v", err)
+	}
+	if info, _ := caps.lookup(""); !info.HasFeature(stanza.NodeMicroblog + "+notify") {
+		t.Errorf("notify feature should be advertised: %+v", info.Features)
+	}
+	router.route(NewSenderMock(), msg)
+	if len(entries) != 2 {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+}
@@ -0,0 +1,265 @@
+package xmpp
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Mediated Information eXchange channels (XEP-0369 and XEP-0406)
+
+// MIXChannelInfo is the channel information published by a MIX channel. Nodes are the PubSub
+// nodes of the channel, as stanza.MixNode constants.
+type MIXChannelInfo struct {
+	Name        string
+	Description string
+	Nodes       []string
+}
+
+// GetMIXChannelInfo fetches the information of a MIX channel, using service discovery.
+// See XEP-0369 - 6.3 Discovering Channel Information
+func (c *Client) GetMIXChannelInfo(ctx context.Context, channel string) (MIXChannelInfo, error) {
+	return getMIXChannelInfo(ctx, c, channel)
+}
+
+// MIXGetChannelHistory fetches the messages archived by a MIX channel, with a MAM query sent to the
+// channel JID. Only the messages distributed by the channel, carrying a mix element, are returned:
+// other archived messages are filtered out. Messages are returned once the channel has sent the whole
+// page of results, and the returned channel is closed after the last one.
+// Use the After option to fetch the next page, with the archive id of the last message.
+func (c *Client) MIXGetChannelHistory(ctx context.Context, channel string, opts MAMQuery) (<-chan stanza.Message, error) {
+	return mixGetChannelHistory(ctx, c, &c.mamQueries, channel, opts)
+}
+
+// MIXParticipant is a participant of a MIX channel. ID is the stable participant id assigned by the
+// channel, used as item id on the participants node.
+type MIXParticipant struct {
+	ID   string
+	JID  string
+	Nick string
+}
+
+// MIXParticipantChanged is passed to the MIXParticipantHandler when a participant joins a channel,
+// changes its nickname, or leaves the channel. Only the ID of the participants leaving is known.
+type MIXParticipantChanged struct {
+	Channel     string
+	Participant MIXParticipant
+	Left        bool
+}
+
+// MIXParticipantHandler receives the changes of the participant lists of the channels the client is
+// subscribed to. It must be registered on the router with Route.
+type MIXParticipantHandler func(e MIXParticipantChanged)
+
+// MIXInviteParticipant requests an invitation of jid to the channel, and sends it to the invitee.
+// See XEP-0407 - 2. Invitation
+func (c *Client) MIXInviteParticipant(ctx context.Context, channel, jid string) error {
+	return mixInviteParticipant(ctx, c, channel, jid)
+}
+
+// MIXKickParticipant removes the participant with the given JID from the channel. It requires
+// administration privileges on the channel.
+func (c *Client) MIXKickParticipant(ctx context.Context, channel, jid string) error {
+	return mixKickParticipant(ctx, c, channel, jid)
+}
+
+// GetMIXParticipants fetches the participants of the channel, from its participants node.
+func (c *Client) GetMIXParticipants(ctx context.Context, channel string) ([]MIXParticipant, error) {
+	return getMIXParticipants(ctx, c, channel)
+}
+
+// Route registers the handler on the router, to receive the changes of the participant lists.
+func (h MIXParticipantHandler) Route(router *Router) *Route {
+	return router.NewRoute().AddMatcher(mixParticipantsMatcher{}).Handler(h)
+}
+
+// HandlePacket decodes a notification of the participants node and passes the changes to the
+// handler. It implements the router Handler interface.
+func (h MIXParticipantHandler) HandlePacket(_ Sender, p stanza.Packet) {
+	msg, ok := p.(stanza.Message)
+	if !ok {
+		return
+	}
+	items, ok := mixParticipantItems(msg)
+	if !ok {
+		return
+	}
+	channel := bareJid(msg.From)
+	for _, ie := range items.Items {
+		item := stanza.Item{Id: ie.Id, Any: ie.Any}
+		var participant stanza.MixParticipant
+		if item.DecodePayload(&participant) != nil {
+			continue
+		}
+		h(MIXParticipantChanged{Channel: channel, Participant: newMIXParticipant(ie.Id, participant)})
+	}
+	if items.Retract != nil {
+		h(MIXParticipantChanged{Channel: channel, Participant: MIXParticipant{ID: items.Retract.ID}, Left: true})
+	}
+}
+
+type mixParticipantsMatcher struct{}
+
+func (mixParticipantsMatcher) Match(p stanza.Packet, _ *RouteMatch) bool {
+	msg, ok := p.(stanza.Message)
+	if !ok {
+		return false
+	}
+	_, ok = mixParticipantItems(msg)
+	return ok
+}
+
+// mixParticipantItems returns the items of the participants node notification carried by the
+// message, if any.
+func mixParticipantItems(msg stanza.Message) (*stanza.ItemsEvent, bool) {
+	var event stanza.PubSubEvent
+	if !msg.Get(&event) {
+		return nil, false
+	}
+	items, ok := event.EventElement.(*stanza.ItemsEvent)
+	if !ok || items.Node != stanza.MixNodeParticipants {
+		return nil, false
+	}
+	return items, true
+}
+
+func newMIXParticipant(id string, p stanza.MixParticipant) MIXParticipant {
+	return MIXParticipant{ID: id, JID: p.Jid, Nick: p.Nick}
+}
+
+func mixInviteParticipant(ctx context.Context, s Sender, channel, jid string) error {
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, To: channel})
+	if err != nil {
+		return err
+	}
+	iq.MixInvite(jid)
+
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return err
+	}
+	if err = iqError(result); err != nil {
+		return err
+	}
+	invite, ok := result.Payload.(*stanza.MixInvite)
+	if !ok || invite.Invitation == nil {
+		return errors.New("invalid channel invitation response")
+	}
+	msg := stanza.NewMessage(stanza.Attrs{To: jid})
+	msg.Extensions = append(msg.Extensions, *invite.Invitation)
+	return s.Send(msg)
+}
+
+func mixKickParticipant(ctx context.Context, s Sender, channel, jid string) error {
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeSet, To: channel})
+	if err != nil {
+		return err
+	}
+	iq.MixKick(jid)
+
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return err
+	}
+	return iqError(result)
+}
+
+func getMIXParticipants(ctx context.Context, s Sender, channel string) ([]MIXParticipant, error) {
+	iq, err := stanza.NewItemsRequest(channel, stanza.MixNodeParticipants, 0)
+	if err != nil {
+		return nil, err
+	}
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return nil, err
+	}
+	if err = iqError(result); err != nil {
+		return nil, err
+	}
+	ps, ok := result.Payload.(*stanza.PubSubGeneric)
+	if !ok || ps.Items == nil {
+		return nil, errors.New("invalid channel participants response")
+	}
+
+	participants := make([]MIXParticipant, 0, len(ps.Items.List))
+	for _, item := range ps.Items.List {
+		var participant stanza.MixParticipant
+		if err = item.DecodePayload(&participant); err != nil {
+			// Skip items that are not participants
+			continue
+		}
+		participants = append(participants, newMIXParticipant(item.Id, participant))
+	}
+	return participants, nil
+}
+
+func getMIXChannelInfo(ctx context.Context, s Sender, channel string) (MIXChannelInfo, error) {
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, To: channel})
+	if err != nil {
+		return MIXChannelInfo{}, err
+	}
+	iq.DiscoInfo()
+
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return MIXChannelInfo{}, err
+	}
+	if err = iqError(result); err != nil {
+		return MIXChannelInfo{}, err
+	}
+	disco, ok := result.Payload.(*stanza.DiscoInfo)
+	if !ok {
+		return MIXChannelInfo{}, errors.New("invalid channel info response")
+	}
+
+	var info MIXChannelInfo
+	for _, identity := range disco.Identity {
+		if identity.Category == "conference" && identity.Type == "mix" {
+			info.Name = identity.Name
+			break
+		}
+	}
+	// The information form of the channel takes precedence over the identity
+	if form := disco.ExtendedForm(stanza.NSMixCore); form != nil {
+		if f := form.Field("Name"); f != nil && f.Value() != "" {
+			info.Name = f.Value()
+		}
+		if f := form.Field("Description"); f != nil {
+			info.Description = f.Value()
+		}
+	}
+	for _, f := range disco.Features {
+		if strings.HasPrefix(f.Var, stanza.MixNodePrefix) {
+			info.Nodes = append(info.Nodes, f.Var)
+		}
+	}
+	return info, nil
+}
+
+func mixGetChannelHistory(ctx context.Context, s Sender, queries *mamQueries, channel string, opts MAMQuery) (<-chan stanza.Message, error) {
+	iq, queryId, err := newMAMQueryIQ(channel, opts)
+	if err != nil {
+		return nil, err
+	}
+	results, err := queryArchive(ctx, s, queries, iq, queryId)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make(chan stanza.Message, len(results))
+	for _, result := range results {
+		msg, ok := result.Forwarded.Message()
+		if !ok {
+			continue
+		}
+		var mix stanza.Mix
+		if msg.Get(&mix) {
+			messages <- msg
+		}
+	}
+	close(messages)
+	return messages, nil
+}
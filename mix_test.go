@@ -0,0 +1,230 @@
+package xmpp
+
+import (
+	"context"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// mixArchiveServer answers archive queries like a MIX channel, sending the archived messages before
+// the result of the query. The $queryid placeholder of the messages is replaced by the query id.
+type mixArchiveServer struct {
+	SenderMock
+	t        *testing.T
+	queries  *mamQueries
+	messages []string
+	query    *stanza.MAMQuery
+}
+
+func (s *mixArchiveServer) SendIQ(_ context.Context, iq *stanza.IQ) (chan stanza.IQ, error) {
+	q, ok := iq.Payload.(*stanza.MAMQuery)
+	if !ok {
+		s.t.Fatalf("unexpected IQ sent: %+v", iq)
+	}
+	s.query = q
+	for _, m := range s.messages {
+		var msg stanza.Message
+		if err := xml.Unmarshal([]byte(strings.ReplaceAll(m, "$queryid", q.QueryId)), &msg); err != nil {
+			s.t.Fatalf("invalid archived message: %v", err)
+		}
+		s.queries.collect(msg)
+	}
+	res := make(chan stanza.IQ, 1)
+	res <- stanza.IQ{Attrs: stanza.Attrs{Type: stanza.IQTypeResult, Id: iq.Id, From: iq.To}, Payload: &stanza.MAMFin{Complete: true}}
+	return res, nil
+}
+
+func archivedMessage(from, id, inner string) string {
+	return `<message from='` + from + `' to='hag66@shakespeare.example/pda'>
+  <result xmlns='urn:xmpp:mam:2' queryid='$queryid' id='` + id + `'>
+    <forwarded xmlns='urn:xmpp:forward:0'>
+      <delay xmlns='urn:xmpp:delay' stamp='2010-07-10T23:08:25Z'/>
+      ` + inner + `
+    </forwarded>
+  </result>
+</message>`
+}
+
+func TestMIXGetChannelHistory(t *testing.T) {
+	channel := "coven@mix.shakespeare.example"
+	server := &mixArchiveServer{t: t, messages: []string{
+		archivedMessage(channel, "28482-98726-73623", `<message xmlns='jabber:client' from='coven@mix.shakespeare.example/123456' type='groupchat' id='1'>
+        <body>Thrice the brinded cat hath mew'd.</body>
+        <mix xmlns='urn:xmpp:mix:core:1'><nick>thirdwitch</nick><jid>hag66@shakespeare.example</jid></mix>
+      </message>`),
+		// Regular message, without mix element
+		archivedMessage(channel, "09af3-cc343-b409f", `<message xmlns='jabber:client' from='coven@mix.shakespeare.example' type='groupchat' id='2'>
+        <body>Not a channel message</body>
+      </message>`),
+		// Result of another query
+		`<message from='coven@mix.shakespeare.example'><result xmlns='urn:xmpp:mam:2' queryid='other' id='x'/></message>`,
+		archivedMessage(channel, "5d398-28273-f7382", `<message xmlns='jabber:client' from='coven@mix.shakespeare.example/789012' type='groupchat' id='3'>
+        <body>Thrice and once the hedge-pig whined.</body>
+        <mix xmlns='urn:xmpp:mix:core:1'><nick>secondwitch</nick><jid>hag77@shakespeare.example</jid></mix>
+      </message>`),
+		// Result sent by another entity
+		archivedMessage("mallory@evil.example", "4e1a2-0f3b1-ba7e2", `<message xmlns='jabber:client' from='coven@mix.shakespeare.example/123456' type='groupchat' id='4'>
+        <body>Spoofed</body>
+        <mix xmlns='urn:xmpp:mix:core:1'><nick>thirdwitch</nick></mix>
+      </message>`),
+	}}
+	server.queries = &mamQueries{}
+
+	messages, err := mixGetChannelHistory(context.Background(), server, server.queries, channel, MAMQuery{Max: 10})
+	if err != nil {
+		t.Fatalf("cannot get channel history: %v", err)
+	}
+	var nicks []string
+	for msg := range messages {
+		var mix stanza.Mix
+		msg.Get(&mix)
+		nicks = append(nicks, mix.Nick)
+	}
+	if len(nicks) != 2 || nicks[0] != "thirdwitch" || nicks[1] != "secondwitch" {
+		t.Errorf("unexpected channel messages: %v", nicks)
+	}
+	if set := stanza.NewRSMSet(server.query.ResultSet); set.Max != 10 {
+		t.Errorf("unexpected result set: %+v", set)
+	}
+	if len(server.queries.pending) != 0 {
+		t.Errorf("query still pending: %+v", server.queries.pending)
+	}
+}
+
+func TestGetMIXChannelInfo(t *testing.T) {
+	// XEP-0369 - Examples 6 and 10
+	response := `<iq type='result' from='coven@mix.shakespeare.example' to='hag66@shakespeare.example/intibo24'>
+  <query xmlns='http://jabber.org/protocol/disco#info'>
+    <identity category='conference' name='A Dark Cave' type='mix'/>
+    <feature var='http://jabber.org/protocol/disco#info'/>
+    <feature var='urn:xmpp:mix:core:1'/>
+    <feature var='urn:xmpp:mam:2'/>
+    <feature var='urn:xmpp:mix:nodes:messages'/>
+    <feature var='urn:xmpp:mix:nodes:participants'/>
+    <feature var='urn:xmpp:mix:nodes:info'/>
+    <x xmlns='jabber:x:data' type='result'>
+      <field var='FORM_TYPE' type='hidden'><value>urn:xmpp:mix:core:1</value></field>
+      <field var='Name'><value>Witches Coven</value></field>
+      <field var='Description'><value>A location not far from the blasted heath where the three witches meet</value></field>
+    </x>
+  </query>
+</iq>`
+	sender := &scriptedIQSender{t: t, responses: []string{response}}
+	info, err := getMIXChannelInfo(context.Background(), sender, "coven@mix.shakespeare.example")
+	if err != nil {
+		t.Fatalf("cannot get channel info: %v", err)
+	}
+	if info.Name != "Witches Coven" || info.Description == "" {
+		t.Errorf("unexpected channel info: %+v", info)
+	}
+	if len(info.Nodes) != 3 || info.Nodes[0] != stanza.MixNodeMessages || info.Nodes[2] != stanza.MixNodeInfo {
+		t.Errorf("unexpected channel nodes: %v", info.Nodes)
+	}
+	if sender.requests[0].To != "coven@mix.shakespeare.example" {
+		t.Errorf("query sent to %s", sender.requests[0].To)
+	}
+}
+
+func TestMIXInviteParticipant(t *testing.T) {
+	// XEP-0407 - Example 2
+	response := `<iq type='result' from='coven@mix.shakespeare.example' to='hag66@shakespeare.example/UUID-a1j/7533'>
+  <invite xmlns='urn:xmpp:mix:misc:0'>
+    <invitation>
+      <inviter>hag66@shakespeare.example</inviter>
+      <invitee>cat@shakespeare.example</invitee>
+      <channel>coven@mix.shakespeare.example</channel>
+      <token>ABCDEF</token>
+    </invitation>
+  </invite>
+</iq>`
+	sender := &scriptedIQSender{SenderMock: NewSenderMock(), t: t, responses: []string{response}}
+	if err := mixInviteParticipant(context.Background(), sender, "coven@mix.shakespeare.example", "cat@shakespeare.example"); err != nil {
+		t.Fatalf("could not invite participant: %v", err)
+	}
+
+	req := sender.requests[0]
+	invite, ok := req.Payload.(*stanza.MixInvite)
+	if !ok || req.Type != stanza.IQTypeGet || req.To != "coven@mix.shakespeare.example" ||
+		invite.Invitee != "cat@shakespeare.example" {
+		t.Fatalf("unexpected request: %+v %+v", req.Attrs, req.Payload)
+	}
+	sent := sender.String()
+	if !strings.Contains(sent, `to="cat@shakespeare.example"`) || !strings.Contains(sent, `<token>ABCDEF</token>`) {
+		t.Errorf("invitation not sent to the invitee: %s", sent)
+	}
+}
+
+func TestMIXKickParticipant(t *testing.T) {
+	sender := &scriptedIQSender{t: t, responses: []string{`<iq type='result' from='coven@mix.shakespeare.example'/>`}}
+	if err := mixKickParticipant(context.Background(), sender, "coven@mix.shakespeare.example", "hag66@shakespeare.example"); err != nil {
+		t.Fatalf("could not kick participant: %v", err)
+	}
+	data, err := xml.Marshal(sender.requests[0])
+	if err != nil {
+		t.Fatalf("cannot marshal request: %v", err)
+	}
+	if !strings.Contains(string(data), `type="set"`) ||
+		!strings.Contains(string(data), `<kick xmlns="urn:xmpp:mix:admin:0"><jid>hag66@shakespeare.example</jid></kick>`) {
+		t.Errorf("unexpected kick request: %s", data)
+	}
+}
+
+func TestGetMIXParticipants(t *testing.T) {
+	// XEP-0369 - Example 11
+	response := `<iq type='result' from='coven@mix.shakespeare.example' to='hag66@shakespeare.example/UUID-a1j/7533'>
+  <pubsub xmlns='http://jabber.org/protocol/pubsub'>
+    <items node='urn:xmpp:mix:nodes:participants'>
+      <item id='123456'>
+        <participant xmlns='urn:xmpp:mix:core:1'><nick>thirdwitch</nick><jid>hag66@shakespeare.example</jid></participant>
+      </item>
+      <item id='87123'>
+        <participant xmlns='urn:xmpp:mix:core:1'><nick>top witch</nick><jid>hecate@shakespeare.example</jid></participant>
+      </item>
+    </items>
+  </pubsub>
+</iq>`
+	sender := &scriptedIQSender{t: t, responses: []string{response}}
+	participants, err := getMIXParticipants(context.Background(), sender, "coven@mix.shakespeare.example")
+	if err != nil {
+		t.Fatalf("could not get participants: %v", err)
+	}
+	if len(participants) != 2 ||
+		participants[0] != (MIXParticipant{ID: "123456", JID: "hag66@shakespeare.example", Nick: "thirdwitch"}) ||
+		participants[1] != (MIXParticipant{ID: "87123", JID: "hecate@shakespeare.example", Nick: "top witch"}) {
+		t.Errorf("unexpected participants: %+v", participants)
+	}
+}
+
+func TestMIXParticipantHandler(t *testing.T) {
+	var events []MIXParticipantChanged
+	router := NewRouter()
+	MIXParticipantHandler(func(e MIXParticipantChanged) { events = append(events, e) }).Route(router)
+
+	router.route(NewSenderMock(), parseMessage(t, `<message from='coven@mix.shakespeare.example' to='hag66@shakespeare.example'>
+  <event xmlns='http://jabber.org/protocol/pubsub#event'>
+    <items node='urn:xmpp:mix:nodes:participants'>
+      <item id='87123'>
+        <participant xmlns='urn:xmpp:mix:core:1'><nick>top witch</nick><jid>hecate@shakespeare.example</jid></participant>
+      </item>
+    </items>
+  </event>
+</message>`))
+	router.route(NewSenderMock(), parseMessage(t, `<message from='coven@mix.shakespeare.example' to='hag66@shakespeare.example'>
+  <event xmlns='http://jabber.org/protocol/pubsub#event'>
+    <items node='urn:xmpp:mix:nodes:participants'><retract id='123456'/></items>
+  </event>
+</message>`))
+
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %+v", events)
+	}
+	if events[0].Channel != "coven@mix.shakespeare.example" || events[0].Left || events[0].Participant.Nick != "top witch" {
+		t.Errorf("unexpected join event: %+v", events[0])
+	}
+	if !events[1].Left || events[1].Participant.ID != "123456" {
+		t.Errorf("unexpected leave event: %+v", events[1])
+	}
+}
@@ -0,0 +1,230 @@
+package xmpp
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Multi-User Chat helpers (XEP-0045)
+
+// RequestVoice sends a voice request to a moderated room.
+// Moderators of the room will receive an approval form, that can be answered using AnswerVoiceRequest.
+func RequestVoice(s Sender, roomJid string) error {
+	msg, err := stanza.NewVoiceRequest(roomJid)
+	if err != nil {
+		return err
+	}
+	return s.Send(msg)
+}
+
+// AnswerVoiceRequest fills and submits a voice request approval form received from a room.
+// Use stanza.GetVoiceRequest to extract the request from an incoming message.
+func AnswerVoiceRequest(s Sender, vr *stanza.VoiceRequest, approve bool) error {
+	var msg stanza.Message
+	var err error
+	if approve {
+		msg, err = vr.Approve()
+	} else {
+		msg, err = vr.Deny()
+	}
+	if err != nil {
+		return err
+	}
+	return s.Send(msg)
+}
+
+// ============================================================================
+// Room discovery
+
+// MUC room features and room information fields
+// See XEP-0045 - 15.5.4 muc#roominfo FORM_TYPE
+const (
+	mucFeatureOpen              = "muc_open"
+	mucFeaturePasswordProtected = "muc_passwordprotected"
+	mucRoomInfoFormType         = "http://jabber.org/protocol/muc#roominfo"
+)
+
+// RoomListOptions selects the page of the room list to fetch. Max is the maximum number of rooms
+// returned, and After the last room JID of the previous page, as returned in the result set.
+type RoomListOptions struct {
+	Max   int
+	After string
+}
+
+// RoomInfo describes a room of a MUC service.
+type RoomInfo struct {
+	JID  string
+	Name string
+}
+
+// RoomDetails is the room information published by the room, using service discovery.
+type RoomDetails struct {
+	Name              string
+	Subject           string
+	Description       string
+	OccupantCount     int
+	Open              bool
+	PasswordProtected bool
+}
+
+// GetRoomList fetches a page of the rooms hosted by the MUC service. The returned result set is used
+// to fetch the next page: pass its Last value as After option.
+func (c *Client) GetRoomList(ctx context.Context, mucService string, opts RoomListOptions) ([]RoomInfo, stanza.RSMSet, error) {
+	return getRoomList(ctx, c, mucService, opts)
+}
+
+// GetRoomInfo fetches the details of a room.
+func (c *Client) GetRoomInfo(ctx context.Context, jid string) (RoomDetails, error) {
+	return getRoomInfo(ctx, c, jid)
+}
+
+func getRoomList(ctx context.Context, s Sender, mucService string, opts RoomListOptions) ([]RoomInfo, stanza.RSMSet, error) {
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, To: mucService})
+	if err != nil {
+		return nil, stanza.RSMSet{}, err
+	}
+	items := iq.DiscoItems()
+	if opts.Max > 0 || opts.After != "" {
+		items.ResultSet = stanza.NewRSMQuery(opts.Max, opts.After).ResultSet()
+	}
+
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return nil, stanza.RSMSet{}, err
+	}
+	if err = iqError(result); err != nil {
+		return nil, stanza.RSMSet{}, err
+	}
+	res, ok := result.Payload.(*stanza.DiscoItems)
+	if !ok {
+		return nil, stanza.RSMSet{}, errors.New("invalid room list response")
+	}
+	rooms := make([]RoomInfo, 0, len(res.Items))
+	for _, item := range res.Items {
+		rooms = append(rooms, RoomInfo{JID: item.JID, Name: item.Name})
+	}
+	return rooms, stanza.NewRSMSet(res.ResultSet), nil
+}
+
+func getRoomInfo(ctx context.Context, s Sender, jid string) (RoomDetails, error) {
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, To: jid})
+	if err != nil {
+		return RoomDetails{}, err
+	}
+	iq.DiscoInfo()
+
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return RoomDetails{}, err
+	}
+	if err = iqError(result); err != nil {
+		return RoomDetails{}, err
+	}
+	info, ok := result.Payload.(*stanza.DiscoInfo)
+	if !ok {
+		return RoomDetails{}, errors.New("invalid room info response")
+	}
+
+	details := RoomDetails{
+		Open:              info.HasFeature(mucFeatureOpen),
+		PasswordProtected: info.HasFeature(mucFeaturePasswordProtected),
+	}
+	for _, identity := range info.Identity {
+		if identity.Category == "conference" {
+			details.Name = identity.Name
+			break
+		}
+	}
+	if form := info.ExtendedForm(mucRoomInfoFormType); form != nil {
+		if f := form.Field("muc#roominfo_subject"); f != nil {
+			details.Subject = f.Value()
+		}
+		if f := form.Field("muc#roominfo_description"); f != nil {
+			details.Description = f.Value()
+		}
+		if f := form.Field("muc#roominfo_occupants"); f != nil {
+			details.OccupantCount, _ = strconv.Atoi(f.Value())
+		}
+	}
+	return details, nil
+}
+
+// ============================================================================
+// Private messages
+
+// SendMUCPrivateMessage sends a private message to an occupant of a room, and returns the sent message.
+func (c *Client) SendMUCPrivateMessage(ctx context.Context, roomJID, occupantNick, body string) (stanza.Message, error) {
+	msg, err := stanza.NewMUCPrivateMessage(roomJID, occupantNick, body)
+	if err != nil {
+		return stanza.Message{}, err
+	}
+	if err = ctx.Err(); err != nil {
+		return stanza.Message{}, err
+	}
+	return msg, c.Send(msg)
+}
+
+// IsMUCPrivateMessage tells if the message is a private message sent by an occupant of a room
+// the client has joined.
+func (c *Client) IsMUCPrivateMessage(msg stanza.Message) bool {
+	if msg.Type == stanza.MessageTypeGroupchat {
+		return false
+	}
+	jid, err := stanza.NewJid(msg.From)
+	if err != nil || jid.Resource == "" {
+		return false
+	}
+	return c.rooms.joined(jid.Bare())
+}
+
+// updateJoinedRooms tracks the rooms the client is an occupant of, from the presences sent by the rooms
+// about our own occupant.
+func (c *Client) updateJoinedRooms(p stanza.Packet) {
+	pres, ok := p.(stanza.Presence)
+	if !ok {
+		return
+	}
+	var muc stanza.MucUser
+	if !pres.Get(&muc) || !muc.HasStatus(stanza.MucStatusSelfPresence) {
+		return
+	}
+	if pres.Type == stanza.PresenceTypeUnavailable || pres.Type == stanza.PresenceTypeError {
+		c.rooms.remove(bareJid(pres.From))
+	} else {
+		c.rooms.add(bareJid(pres.From))
+	}
+}
+
+// joinedRooms is the set of bare JIDs of the rooms the client has joined.
+type joinedRooms struct {
+	mu    sync.RWMutex
+	rooms map[string]struct{}
+}
+
+func (jr *joinedRooms) add(room string) {
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+	if jr.rooms == nil {
+		jr.rooms = make(map[string]struct{})
+	}
+	jr.rooms[strings.ToLower(room)] = struct{}{}
+}
+
+func (jr *joinedRooms) remove(room string) {
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+	delete(jr.rooms, strings.ToLower(room))
+}
+
+func (jr *joinedRooms) joined(room string) bool {
+	jr.mu.RLock()
+	defer jr.mu.RUnlock()
+	_, ok := jr.rooms[strings.ToLower(room)]
+	return ok
+}
@@ -0,0 +1,175 @@
+package xmpp
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// mucServiceMock answers disco#items requests with pages of its room list.
+type mucServiceMock struct {
+	SenderMock
+	rooms    []string
+	requests []stanza.RSMSet
+}
+
+func (m *mucServiceMock) SendIQ(ctx context.Context, iq *stanza.IQ) (chan stanza.IQ, error) {
+	req := stanza.NewRSMSet(iq.Payload.GetSet())
+	m.requests = append(m.requests, req)
+
+	start := 0
+	for i, room := range m.rooms {
+		if room == req.After {
+			start = i + 1
+		}
+	}
+	end := len(m.rooms)
+	if req.Max > 0 && start+req.Max < end {
+		end = start + req.Max
+	}
+
+	answer, _ := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeResult, Id: iq.Id, From: iq.To})
+	items := answer.DiscoItems()
+	for _, room := range m.rooms[start:end] {
+		items.AddItem(room, "", "Room "+room)
+	}
+	if start < end {
+		items.ResultSet = stanza.RSMSet{
+			First: m.rooms[start],
+			Last:  m.rooms[end-1],
+			Index: fmt.Sprint(start),
+			Count: len(m.rooms),
+		}.ResultSet()
+	}
+	res := make(chan stanza.IQ, 1)
+	res <- *answer
+	return res, nil
+}
+
+func TestGetRoomListPages(t *testing.T) {
+	mock := &mucServiceMock{}
+	for i := 0; i < 5; i++ {
+		mock.rooms = append(mock.rooms, fmt.Sprintf("room%d@chat.shakespeare.lit", i))
+	}
+	ctx := context.Background()
+
+	var all []RoomInfo
+	opts := RoomListOptions{Max: 2}
+	for page := 0; page < 3; page++ {
+		rooms, set, err := getRoomList(ctx, mock, "chat.shakespeare.lit", opts)
+		if err != nil {
+			t.Fatalf("could not get room list: %v", err)
+		}
+		if set.Count != 5 {
+			t.Errorf("unexpected room count: %d", set.Count)
+		}
+		all = append(all, rooms...)
+		opts.After = set.Last
+	}
+
+	if len(all) != 5 || all[4].JID != "room4@chat.shakespeare.lit" || all[0].Name != "Room room0@chat.shakespeare.lit" {
+		t.Errorf("unexpected rooms: %+v", all)
+	}
+	if len(mock.requests) != 3 || mock.requests[0].After != "" || mock.requests[2].After != "room3@chat.shakespeare.lit" ||
+		mock.requests[2].Max != 2 {
+		t.Errorf("unexpected RSM requests: %+v", mock.requests)
+	}
+}
+
+func TestGetRoomListWithoutPaging(t *testing.T) {
+	sender := &scriptedIQSender{t: t, responses: []string{`<iq type='result' from='chat.shakespeare.lit'>
+  <query xmlns='http://jabber.org/protocol/disco#items'>
+    <item jid='heath@chat.shakespeare.lit' name='A Lonely Heath'/>
+    <item jid='darkcave@chat.shakespeare.lit' name='A Dark Cave'/>
+  </query>
+</iq>`}}
+	rooms, set, err := getRoomList(context.Background(), sender, "chat.shakespeare.lit", RoomListOptions{})
+	if err != nil {
+		t.Fatalf("could not get room list: %v", err)
+	}
+	if sender.requests[0].Payload.GetSet() != nil {
+		t.Errorf("no result set expected in request")
+	}
+	if len(rooms) != 2 || rooms[1].Name != "A Dark Cave" || set != (stanza.RSMSet{}) {
+		t.Errorf("unexpected room list: %+v, %+v", rooms, set)
+	}
+}
+
+func TestGetRoomInfo(t *testing.T) {
+	// XEP-0045 - Example 10
+	response := `<iq type='result' from='heath@chat.shakespeare.lit' to='hag66@shakespeare.lit/pda'>
+  <query xmlns='http://jabber.org/protocol/disco#info'>
+    <identity category='conference' name='A Dark Cave' type='text'/>
+    <feature var='http://jabber.org/protocol/muc'/>
+    <feature var='muc_passwordprotected'/>
+    <feature var='muc_hidden'/>
+    <feature var='muc_temporary'/>
+    <feature var='muc_open'/>
+    <feature var='muc_unmoderated'/>
+    <feature var='muc_nonanonymous'/>
+    <x xmlns='jabber:x:data' type='result'>
+      <field var='FORM_TYPE' type='hidden'><value>http://jabber.org/protocol/muc#roominfo</value></field>
+      <field var='muc#roominfo_description' label='Description'><value>The place for all good witches!</value></field>
+      <field var='muc#roominfo_occupants' label='Number of occupants'><value>3</value></field>
+      <field var='muc#roominfo_subject' label='Current Discussion Topic'><value>Spells</value></field>
+    </x>
+  </query>
+</iq>`
+	sender := &scriptedIQSender{t: t, responses: []string{response}}
+	details, err := getRoomInfo(context.Background(), sender, "heath@chat.shakespeare.lit")
+	if err != nil {
+		t.Fatalf("could not get room info: %v", err)
+	}
+	expected := RoomDetails{
+		Name:              "A Dark Cave",
+		Subject:           "Spells",
+		Description:       "The place for all good witches!",
+		OccupantCount:     3,
+		Open:              true,
+		PasswordProtected: true,
+	}
+	if details != expected {
+		t.Errorf("unexpected room details: %+v", details)
+	}
+	if _, ok := sender.requests[0].Payload.(*stanza.DiscoInfo); !ok || sender.requests[0].To != "heath@chat.shakespeare.lit" {
+		t.Errorf("unexpected request: %+v", sender.requests[0])
+	}
+}
+
+func TestIsMUCPrivateMessage(t *testing.T) {
+	c := &Client{}
+	join := `<presence from='coven@chat.shakespeare.lit/thirdwitch' to='hag66@shakespeare.lit/pda'>
+  <x xmlns='http://jabber.org/protocol/muc#user'>
+    <item affiliation='member' role='participant'/>
+    <status code='110'/>
+  </x>
+</presence>`
+	var pres stanza.Presence
+	if err := xml.Unmarshal([]byte(join), &pres); err != nil {
+		t.Fatalf("could not parse presence: %v", err)
+	}
+	c.updateJoinedRooms(pres)
+
+	pm := stanza.NewMessage(stanza.Attrs{From: "Coven@chat.shakespeare.lit/Dr. \"Who\"", Type: stanza.MessageTypeChat})
+	if !c.IsMUCPrivateMessage(pm) {
+		t.Errorf("message from an occupant of a joined room should be a private message")
+	}
+	if c.IsMUCPrivateMessage(stanza.NewMessage(stanza.Attrs{From: "coven@chat.shakespeare.lit/firstwitch", Type: stanza.MessageTypeGroupchat})) {
+		t.Errorf("groupchat messages are not private messages")
+	}
+	if c.IsMUCPrivateMessage(stanza.NewMessage(stanza.Attrs{From: "coven@chat.shakespeare.lit"})) {
+		t.Errorf("messages from the room itself are not private messages")
+	}
+	if c.IsMUCPrivateMessage(stanza.NewMessage(stanza.Attrs{From: "romeo@montague.lit/orchard", Type: stanza.MessageTypeChat})) {
+		t.Errorf("messages from other entities are not private messages")
+	}
+
+	pres.Type = stanza.PresenceTypeUnavailable
+	c.updateJoinedRooms(pres)
+	if c.IsMUCPrivateMessage(pm) {
+		t.Errorf("room should be forgotten once left")
+	}
+}
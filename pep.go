@@ -0,0 +1,156 @@
+package xmpp
+
+import (
+	"sync"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Personal Eventing Protocol (XEP-0163) notifications
+
+// PEPEventHandler receives the items published or retracted by the contacts on a PEP node, for
+// example stanza.NodeBookmarks or "http://jabber.org/protocol/geoloc".
+type PEPEventHandler func(from string, items *stanza.ItemsEvent)
+
+// PEPRegistration is a PEP event handler registered with HandlePEPEvents.
+type PEPRegistration struct {
+	client  *Client
+	router  *Router
+	route   *Route
+	caps    *CapsResponder
+	node    string
+	handler PEPEventHandler
+
+	mu      sync.RWMutex
+	removed bool
+}
+
+// HandlePEPEvents registers the handler on the router, to receive the notifications of the node.
+// PEP services only send them to clients advertising the "node+notify" feature in their
+// capabilities, so the feature is added to caps. When this changes the capabilities of a connected
+// client, the last presence broadcast by the application is sent again with the new caps element,
+// keeping its show, status and priority. Presences sent by the application must also carry
+// caps.Caps().
+func (c *Client) HandlePEPEvents(router *Router, caps *CapsResponder, node string, h PEPEventHandler) (*PEPRegistration, error) {
+	reg := &PEPRegistration{client: c, router: router, caps: caps, node: node, handler: h}
+	reg.route = router.NewRoute().AddMatcher(reg).Handler(reg)
+	if caps.AddNotify(node) {
+		return reg, c.sendCapsPresence(caps)
+	}
+	return reg, nil
+}
+
+// Remove unregisters the route of the handler, and removes the "node+notify" feature from the
+// capabilities, unless another handler was registered for the node. The notifications of the node
+// are then not routed anymore.
+func (reg *PEPRegistration) Remove() error {
+	reg.mu.Lock()
+	removed := reg.removed
+	reg.removed = true
+	reg.mu.Unlock()
+	if removed {
+		return nil
+	}
+	reg.router.RemoveRoute(reg.route)
+	if reg.caps.RemoveNotify(reg.node) {
+		return reg.client.sendCapsPresence(reg.caps)
+	}
+	return nil
+}
+
+// Match implements the router Matcher interface, matching the notifications of the node until the
+// registration is removed.
+func (reg *PEPRegistration) Match(p stanza.Packet, _ *RouteMatch) bool {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	if reg.removed {
+		return false
+	}
+	msg, ok := p.(stanza.Message)
+	if !ok {
+		return false
+	}
+	_, ok = pepItems(msg, reg.node)
+	return ok
+}
+
+// HandlePacket passes the items of a notification to the handler. It implements the router
+// Handler interface.
+func (reg *PEPRegistration) HandlePacket(_ Sender, p stanza.Packet) {
+	msg, ok := p.(stanza.Message)
+	if !ok {
+		return
+	}
+	if items, ok := pepItems(msg, reg.node); ok {
+		reg.handler(msg.From, items)
+	}
+}
+
+// pepItems returns the items of the notification for node carried by the message, if any.
+func pepItems(msg stanza.Message, node string) (*stanza.ItemsEvent, bool) {
+	var event stanza.PubSubEvent
+	if !msg.Get(&event) {
+		return nil, false
+	}
+	items, ok := event.EventElement.(*stanza.ItemsEvent)
+	if !ok || items.Node != node {
+		return nil, false
+	}
+	return items, true
+}
+
+// sendCapsPresence sends the last presence broadcast by the application again, with the current
+// capabilities, if the session is established. Otherwise, or if the application has not sent an
+// available presence yet, they are advertised by the next presence.
+func (c *Client) sendCapsPresence(caps *CapsResponder) error {
+	if c.CurrentState.getState() != StateSessionEstablished {
+		return nil
+	}
+	pres, ok := c.lastPresence.get()
+	if !ok || pres.Type == stanza.PresenceTypeUnavailable {
+		return nil
+	}
+	extensions := make([]stanza.PresExtension, 0, len(pres.Extensions)+1)
+	for _, ext := range pres.Extensions {
+		switch ext.(type) {
+		case stanza.Caps, *stanza.Caps:
+			continue
+		}
+		extensions = append(extensions, ext)
+	}
+	pres.Extensions = append(extensions, caps.Caps())
+	return c.Send(pres)
+}
+
+// broadcastPresence keeps the last presence broadcast by the client, sent without recipient.
+type broadcastPresence struct {
+	mu   sync.Mutex
+	pres *stanza.Presence
+}
+
+// record keeps the packet if it is a broadcast presence.
+func (b *broadcastPresence) record(p stanza.Packet) {
+	pres, ok := p.(stanza.Presence)
+	if !ok {
+		if ptr, isPtr := p.(*stanza.Presence); isPtr && ptr != nil {
+			pres, ok = *ptr, true
+		}
+	}
+	if !ok || pres.To != "" || (pres.Type != "" && pres.Type != stanza.PresenceTypeUnavailable) {
+		return
+	}
+	b.mu.Lock()
+	b.pres = &pres
+	b.mu.Unlock()
+}
+
+// get returns the last broadcast presence, if any.
+func (b *broadcastPresence) get() (stanza.Presence, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pres == nil {
+		return stanza.Presence{}, false
+	}
+	return *b.pres, true
+}
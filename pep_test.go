@@ -0,0 +1,109 @@
+package xmpp
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+const geolocNotification = `<message from='juliet@capulet.lit' to='romeo@montague.lit' type='headline'>
+  <event xmlns='http://jabber.org/protocol/pubsub#event'>
+    <items node='http://jabber.org/protocol/geoloc'>
+      <item id='d81a52b8-0f9c-11dc-9bc8-001143d5d5db'>
+        <geoloc xmlns='http://jabber.org/protocol/geoloc' xml:lang='en'>
+          <locality>Verona</locality>
+        </geoloc>
+      </item>
+    </items>
+  </event>
+</message>`
+
+func TestHandlePEPEvents(t *testing.T) {
+	const node = "http://jabber.org/protocol/geoloc"
+	client := &Client{}
+	caps := NewCapsResponder(capsTestNode, capsTestInfo())
+	router := NewRouter()
+
+	var received []string
+	reg, err := client.HandlePEPEvents(router, caps, node, func(from string, items *stanza.ItemsEvent) {
+		received = append(received, from)
+		if len(items.Items) != 1 || items.Items[0].Id != "d81a52b8-0f9c-11dc-9bc8-001143d5d5db" {
+			t.Errorf("unexpected items: %+v", items.Items)
+		}
+	})
+	// No presence is sent before the session is established
+	if err != nil {
+		t.Fatalf("could not register handler: %v", err)
+	}
+	info, _ := caps.lookup("")
+	if !info.HasFeature(node + "+notify") {
+		t.Errorf("notify feature should be advertised: %+v", info.Features)
+	}
+
+	msg := parseMessage(t, geolocNotification)
+	router.route(NewSenderMock(), msg)
+	if len(received) != 1 || received[0] != "juliet@capulet.lit" {
+		t.Fatalf("unexpected notifications: %v", received)
+	}
+
+	if err = reg.Remove(); err != nil {
+		t.Fatalf("could not remove handler: %v", err)
+	}
+	info, _ = caps.lookup("")
+	if info.HasFeature(node + "+notify") {
+		t.Errorf("notify feature should not be advertised anymore: %+v", info.Features)
+	}
+	router.route(NewSenderMock(), msg)
+	if len(received) != 1 {
+		t.Errorf("removed handler should not receive notifications: %v", received)
+	}
+	if len(router.routes) != 0 {
+		t.Errorf("route of the removed handler should be unregistered: %d routes left", len(router.routes))
+	}
+}
+
+func TestPEPEventsResendLastPresence(t *testing.T) {
+	conn := &writeRecorderConn{}
+	client := &Client{config: &Config{}, transport: &XMPPTransport{conn: conn, readWriter: conn}}
+	client.CurrentState.setState(StateSessionEstablished)
+	caps := NewCapsResponder(capsTestNode, capsTestInfo())
+
+	// No presence is broadcast before the application sends one
+	reg, err := client.HandlePEPEvents(NewRouter(), caps, stanza.NodeMicroblog, func(string, *stanza.ItemsEvent) {})
+	if err != nil {
+		t.Fatalf("could not register handler: %v", err)
+	}
+	if writes, _ := conn.recorded(); len(writes) != 0 {
+		t.Fatalf("unexpected presence sent: %q", writes)
+	}
+
+	pres := stanza.NewPresence(stanza.Attrs{})
+	pres.Show = stanza.PresenceShowAway
+	pres.Status = stanza.NewLocalizedText("Out to lunch")
+	pres.Priority = 5
+	pres.Extensions = append(pres.Extensions, caps.Caps())
+	if err = client.Send(pres); err != nil {
+		t.Fatalf("could not send presence: %v", err)
+	}
+	oldVer := caps.Caps().Ver
+
+	if err = reg.Remove(); err != nil {
+		t.Fatalf("could not remove handler: %v", err)
+	}
+	writes, _ := conn.recorded()
+	if len(writes) != 2 {
+		t.Fatalf("expected the presence to be sent again, got %q", writes)
+	}
+	var sent stanza.Presence
+	if err = xml.Unmarshal([]byte(writes[1]), &sent); err != nil {
+		t.Fatalf("could not parse presence %s: %v", writes[1], err)
+	}
+	var sentCaps stanza.Caps
+	if sent.Show != stanza.PresenceShowAway || sent.Status.String() != "Out to lunch" || sent.Priority != 5 {
+		t.Errorf("presence should keep its show, status and priority: %s", writes[1])
+	}
+	if !sent.Get(&sentCaps) || sentCaps.Ver == oldVer || sentCaps.Ver != caps.Caps().Ver {
+		t.Errorf("presence should advertise the new caps only: %s", writes[1])
+	}
+}
@@ -0,0 +1,160 @@
+package xmpp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// XMPP Ping (XEP-0199) responses and round-trip times
+
+// defaultPingResponseDeadline is the time allowed by default to answer a ping from the server.
+const defaultPingResponseDeadline = 10 * time.Second
+
+// pingLatencyWeight is the weight of the last sample in the moving averages of ping times.
+const pingLatencyWeight = 0.2
+
+// ErrPingResponseDeadline is reported to the error handler when a ping from the server could not be
+// answered within Config.PingResponseDeadline. The connection is then closed.
+var ErrPingResponseDeadline = errors.New("ping from the server not answered in time")
+
+// PingLatency returns the moving average of the round-trip times of the pings sent by the client,
+// or zero if no ping was answered yet.
+func (c *Client) PingLatency() time.Duration {
+	return c.pings.latency()
+}
+
+// ServerPingResponseTime returns the moving average of the time spent answering the pings sent by
+// the server, from their arrival to the write of the response, or zero if none was received yet.
+func (c *Client) ServerPingResponseTime() time.Duration {
+	return c.pings.responseTime()
+}
+
+// answerServerPing answers the ping sent by the server, if the packet is one. It returns true if the
+// packet was a server ping.
+func (c *Client) answerServerPing(p stanza.Packet) bool {
+	reply, ok := serverPingReply(p, c.ServerJID())
+	if !ok {
+		return false
+	}
+	deadline := c.config.PingResponseDeadline
+	if deadline <= 0 {
+		deadline = defaultPingResponseDeadline
+	}
+	go func() {
+		if err := answerPing(c, &c.pings, reply, time.Now(), deadline); err != nil {
+			c.ErrorHandler(err)
+			if err == ErrPingResponseDeadline {
+				// As for keepalive failures, the recv loop will also fail
+				_ = c.transport.Close()
+			}
+		}
+	}()
+	return true
+}
+
+// serverPingReply returns the result answering p, if it is a ping from server.
+// See XEP-0199 - 4.1 Server-To-Client Pings
+func serverPingReply(p stanza.Packet, server string) (*stanza.IQ, bool) {
+	iq, ok := p.(*stanza.IQ)
+	if !ok || iq.Type != stanza.IQTypeGet {
+		return nil, false
+	}
+	if _, ok = iq.Payload.(*stanza.Ping); !ok {
+		return nil, false
+	}
+	if iq.From != "" && !strings.EqualFold(iq.From, server) {
+		return nil, false
+	}
+	reply, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeResult, From: iq.To, To: iq.From, Id: iq.Id})
+	if err != nil {
+		return nil, false
+	}
+	return reply, true
+}
+
+// answerPing sends the reply to a ping received at arrival, and records the response time.
+// It returns ErrPingResponseDeadline if the reply could not be sent within the deadline.
+func answerPing(s Sender, pings *pingTracker, reply *stanza.IQ, arrival time.Time, deadline time.Duration) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Send(reply)
+	}()
+	timer := time.NewTimer(deadline - time.Since(arrival))
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			return err
+		}
+		pings.recordResponse(time.Since(arrival))
+		return nil
+	case <-timer.C:
+		return ErrPingResponseDeadline
+	}
+}
+
+// pingTracker keeps the moving averages of the ping times of a client.
+type pingTracker struct {
+	mu       sync.Mutex
+	rtt      time.Duration
+	response time.Duration
+}
+
+func (t *pingTracker) latency() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.rtt
+}
+
+func (t *pingTracker) responseTime() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.response
+}
+
+func (t *pingTracker) recordRTT(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rtt = movingAverage(t.rtt, d)
+}
+
+func (t *pingTracker) recordResponse(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.response = movingAverage(t.response, d)
+}
+
+// track forwards the result of a ping sent by the client, recording its round-trip time.
+// Results not received before ctx is done are not recorded.
+func (t *pingTracker) track(ctx context.Context, res chan stanza.IQ) chan stanza.IQ {
+	start := time.Now()
+	out := make(chan stanza.IQ, 1)
+	go func() {
+		defer close(out)
+		select {
+		case result, ok := <-res:
+			if !ok {
+				return
+			}
+			t.recordRTT(time.Since(start))
+			out <- result
+		case <-ctx.Done():
+		}
+	}()
+	return out
+}
+
+// movingAverage returns the exponentially weighted moving average avg updated with sample.
+// A zero average is initialized with the sample.
+func movingAverage(avg, sample time.Duration) time.Duration {
+	if avg == 0 {
+		return sample
+	}
+	return avg + time.Duration(pingLatencyWeight*float64(sample-avg))
+}
@@ -0,0 +1,74 @@
+package xmpp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// blockedSender never completes writes, as when the connection is stuck.
+type blockedSender struct {
+	SenderMock
+}
+
+func (s blockedSender) Send(packet stanza.Packet) error {
+	select {}
+}
+
+func TestAnswerServerPing(t *testing.T) {
+	// Server ping, from XEP-0199 - 4.1.1 Server-To-Client Pings
+	ping := parseIQ(t, `<iq from='capulet.lit' to='juliet@capulet.lit/balcony' id='s2c1' type='get'>
+  <ping xmlns='urn:xmpp:ping'/>
+</iq>`)
+	reply, ok := serverPingReply(ping, "capulet.lit")
+	if !ok {
+		t.Fatal("server ping should be answered")
+	}
+
+	var pings pingTracker
+	sender := NewSenderMock()
+	if err := answerPing(sender, &pings, reply, time.Now(), time.Second); err != nil {
+		t.Fatalf("could not answer ping: %v", err)
+	}
+	if sender.String() != `<iq type="result" id="s2c1" from="juliet@capulet.lit/balcony" to="capulet.lit"></iq>` {
+		t.Errorf("unexpected ping reply: %s", sender.String())
+	}
+	if pings.responseTime() == 0 {
+		t.Errorf("response time should be recorded")
+	}
+
+	// The reply cannot be written in time
+	err := answerPing(blockedSender{NewSenderMock()}, &pings, reply, time.Now(), 10*time.Millisecond)
+	if err != ErrPingResponseDeadline {
+		t.Errorf("expected deadline error, got %v", err)
+	}
+
+	// Pings from contacts are left to the router
+	ping.From = "romeo@montague.lit/orchard"
+	if _, ok = serverPingReply(ping, "capulet.lit"); ok {
+		t.Errorf("contact ping should not be answered automatically")
+	}
+}
+
+func TestPingLatency(t *testing.T) {
+	var pings pingTracker
+	res := make(chan stanza.IQ, 1)
+	out := pings.track(context.Background(), res)
+	time.Sleep(10 * time.Millisecond)
+	res <- stanza.IQ{}
+	if _, ok := <-out; !ok {
+		t.Fatal("ping result should be forwarded")
+	}
+	if rtt := pings.latency(); rtt < 10*time.Millisecond {
+		t.Errorf("unexpected round-trip time: %v", rtt)
+	}
+
+	// Later samples are averaged
+	pings.rtt = 100 * time.Millisecond
+	pings.recordRTT(200 * time.Millisecond)
+	if rtt := pings.latency(); rtt != 120*time.Millisecond {
+		t.Errorf("unexpected moving average: %v", rtt)
+	}
+}
@@ -0,0 +1,562 @@
+package xmpp
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// PubSub node configuration (XEP-0060 - 8.2 Configure a Node)
+
+// ErrItemNotFound is returned when the requested PubSub node or item does not exist.
+var ErrItemNotFound = errors.New("pubsub item or node not found")
+
+// DataForm is a data form (XEP-0004), as used to configure PubSub nodes.
+type DataForm = stanza.Form
+
+// Node access models
+// See XEP-0060 - 4.5 Access Models
+const (
+	AccessModelOpen      = "open"
+	AccessModelPresence  = "presence"
+	AccessModelRoster    = "roster"
+	AccessModelAuthorize = "authorize"
+	AccessModelWhitelist = "whitelist"
+)
+
+// Node publish models
+const (
+	PublishModelPublishers  = "publishers"
+	PublishModelSubscribers = "subscribers"
+	PublishModelOpen        = "open"
+)
+
+// Node configuration fields
+// See XEP-0060 - 16.4.4 pubsub#node_config FORM_TYPE
+const (
+	nodeConfigFormType     = "http://jabber.org/protocol/pubsub#node_config"
+	nodeConfigMaxItems     = "pubsub#max_items"
+	nodeConfigAccessModel  = "pubsub#access_model"
+	nodeConfigPublishModel = "pubsub#publish_model"
+	nodeConfigPersistItems = "pubsub#persist_items"
+)
+
+// GetNodeConfig fetches the configuration form of a node. Only the node owner is allowed to read it.
+func (c *Client) GetNodeConfig(ctx context.Context, service, node string) (DataForm, error) {
+	return getNodeConfig(ctx, c, service, node)
+}
+
+// SetNodeConfig submits a new configuration for a node. The form can be the one returned by
+// GetNodeConfig with modified values, or a form created by NewNodeConfigForm.
+func (c *Client) SetNodeConfig(ctx context.Context, service, node string, form DataForm) error {
+	return setNodeConfig(ctx, c, service, node, form)
+}
+
+// NewNodeConfigForm creates a node configuration form. A negative maxItems requests the maximum
+// number of items supported by the service. Zero or empty values are left to the service default.
+func NewNodeConfigForm(maxItems int, accessModel, publishModel string) DataForm {
+	fields := []*stanza.Field{
+		{Var: "FORM_TYPE", Type: stanza.FieldTypeHidden, ValuesList: []string{nodeConfigFormType}},
+	}
+	switch {
+	case maxItems < 0:
+		fields = append(fields, &stanza.Field{Var: nodeConfigMaxItems, ValuesList: []string{"max"}})
+	case maxItems > 0:
+		fields = append(fields, &stanza.Field{Var: nodeConfigMaxItems, ValuesList: []string{strconv.Itoa(maxItems)}})
+	}
+	if accessModel != "" {
+		fields = append(fields, &stanza.Field{Var: nodeConfigAccessModel, ValuesList: []string{accessModel}})
+	}
+	if publishModel != "" {
+		fields = append(fields, &stanza.Field{Var: nodeConfigPublishModel, ValuesList: []string{publishModel}})
+	}
+	return *stanza.NewForm(fields, stanza.FormTypeSubmit)
+}
+
+// NodeConfigMaxItems returns the maximum number of items persisted by the node.
+// It returns -1 when the node keeps as many items as the service allows, and 0 if the value is unknown.
+func NodeConfigMaxItems(form DataForm) int {
+	f := form.Field(nodeConfigMaxItems)
+	if f == nil {
+		return 0
+	}
+	if f.Value() == "max" {
+		return -1
+	}
+	n, _ := strconv.Atoi(f.Value())
+	return n
+}
+
+// NodeConfigAccessModel returns the access model of the node, for instance AccessModelOpen.
+func NodeConfigAccessModel(form DataForm) string {
+	if f := form.Field(nodeConfigAccessModel); f != nil {
+		return f.Value()
+	}
+	return ""
+}
+
+// NodeConfigPersistItems tells if the node persists published items.
+func NodeConfigPersistItems(form DataForm) bool {
+	if f := form.Field(nodeConfigPersistItems); f != nil {
+		b, err := strconv.ParseBool(f.Value())
+		return err == nil && b
+	}
+	return false
+}
+
+func getNodeConfig(ctx context.Context, s Sender, service, node string) (DataForm, error) {
+	iq, err := stanza.NewConfigureNode(service, node)
+	if err != nil {
+		return DataForm{}, err
+	}
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return DataForm{}, err
+	}
+	if isItemNotFound(result) {
+		return DataForm{}, ErrItemNotFound
+	}
+	if err = iqError(result); err != nil {
+		return DataForm{}, err
+	}
+	ps, ok := result.Payload.(*stanza.PubSubOwner)
+	if !ok {
+		return DataForm{}, errors.New("invalid node configuration response")
+	}
+	co, ok := ps.OwnerUseCase.(*stanza.ConfigureOwner)
+	if !ok || co.Form == nil {
+		return DataForm{}, errors.New("node configuration response has no form")
+	}
+	return *co.Form, nil
+}
+
+func setNodeConfig(ctx context.Context, s Sender, service, node string, form DataForm) error {
+	iq, err := stanza.NewFormSubmissionOwner(service, node, form.Fields)
+	if err != nil {
+		return err
+	}
+	return sendOwnerIQ(ctx, s, iq)
+}
+
+// ============================================================================
+// PubSub node administration (XEP-0060 - 8. Owner Use Cases)
+
+// NodeAffiliation is the affiliation of an entity with a node: one of stanza.AffiliationStatusOwner,
+// AffiliationStatusPublisher, AffiliationStatusPublishOnly, AffiliationStatusMember,
+// AffiliationStatusOutcast or AffiliationStatusNone. Setting AffiliationStatusNone removes the entity.
+type NodeAffiliation struct {
+	JID         string
+	Affiliation string
+}
+
+// PurgeNode deletes all the items published on the node. The node and its configuration are kept.
+func (c *Client) PurgeNode(ctx context.Context, service, node string) error {
+	return purgeNode(ctx, c, service, node)
+}
+
+// DeleteNode deletes the node, with its items and subscriptions.
+func (c *Client) DeleteNode(ctx context.Context, service, node string) error {
+	return deleteNode(ctx, c, service, node)
+}
+
+// GetNodeAffiliations fetches the entities affiliated with the node. Only the node owner is allowed to read them.
+func (c *Client) GetNodeAffiliations(ctx context.Context, service, node string) ([]NodeAffiliation, error) {
+	return getNodeAffiliations(ctx, c, service, node)
+}
+
+// SetNodeAffiliations modifies the affiliations of several entities at once, in a single request.
+// The service applies all the modifications or none of them.
+func (c *Client) SetNodeAffiliations(ctx context.Context, service, node string, affs []NodeAffiliation) error {
+	return setNodeAffiliations(ctx, c, service, node, affs)
+}
+
+func purgeNode(ctx context.Context, s Sender, service, node string) error {
+	if node == "" {
+		return errors.New("cannot purge a node without a node ID")
+	}
+	iq, err := stanza.NewPurgeAllItems(service, node)
+	if err != nil {
+		return err
+	}
+	return sendOwnerIQ(ctx, s, iq)
+}
+
+func deleteNode(ctx context.Context, s Sender, service, node string) error {
+	iq, err := stanza.NewDelNode(service, node)
+	if err != nil {
+		return err
+	}
+	return sendOwnerIQ(ctx, s, iq)
+}
+
+func getNodeAffiliations(ctx context.Context, s Sender, service, node string) ([]NodeAffiliation, error) {
+	iq, err := stanza.NewAffiliationListRequest(service, node)
+	if err != nil {
+		return nil, err
+	}
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return nil, err
+	}
+	if isItemNotFound(result) {
+		return nil, ErrItemNotFound
+	}
+	if err = iqError(result); err != nil {
+		return nil, err
+	}
+	ps, ok := result.Payload.(*stanza.PubSubOwner)
+	if !ok {
+		return nil, errors.New("invalid node affiliations response")
+	}
+	list, ok := ps.OwnerUseCase.(*stanza.AffiliationsOwner)
+	if !ok {
+		return nil, errors.New("node affiliations response has no affiliations")
+	}
+	affs := make([]NodeAffiliation, 0, len(list.Affiliations))
+	for _, a := range list.Affiliations {
+		affs = append(affs, NodeAffiliation{JID: a.Jid, Affiliation: a.AffiliationStatus})
+	}
+	return affs, nil
+}
+
+func setNodeAffiliations(ctx context.Context, s Sender, service, node string, affs []NodeAffiliation) error {
+	if len(affs) == 0 {
+		return errors.New("no affiliation to modify")
+	}
+	list := make([]stanza.AffiliationOwner, 0, len(affs))
+	for _, a := range affs {
+		if a.JID == "" {
+			return errors.New("a JID is required to modify an affiliation")
+		}
+		switch a.Affiliation {
+		case stanza.AffiliationStatusOwner, stanza.AffiliationStatusPublisher, stanza.AffiliationStatusPublishOnly,
+			stanza.AffiliationStatusMember, stanza.AffiliationStatusOutcast, stanza.AffiliationStatusNone:
+		default:
+			return errors.New("invalid node affiliation: " + a.Affiliation)
+		}
+		list = append(list, stanza.AffiliationOwner{Jid: a.JID, AffiliationStatus: a.Affiliation})
+	}
+	iq, err := stanza.NewModifAffiliationRequest(service, node, list)
+	if err != nil {
+		return err
+	}
+	return sendOwnerIQ(ctx, s, iq)
+}
+
+// sendOwnerIQ sends an owner request whose result has no payload.
+func sendOwnerIQ(ctx context.Context, s Sender, iq *stanza.IQ) error {
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return err
+	}
+	if isItemNotFound(result) {
+		return ErrItemNotFound
+	}
+	return iqError(result)
+}
+
+// ============================================================================
+// PubSub subscription approval (XEP-0060 - 8.6 Manage Subscription Requests)
+
+const subAuthorizationFormType = "http://jabber.org/protocol/pubsub#subscribe_authorization"
+
+// PendingSubscription is a subscription request waiting for the approval of the node owner.
+type PendingSubscription struct {
+	JID   string
+	SubID string
+}
+
+// SubscriptionRequestHandler is called when a node owner receives a subscription request to approve.
+type SubscriptionRequestHandler func(service, node, subscriberJID, subid string)
+
+// OnSubscriptionRequest sets the handler called when the service asks the node owner to approve a
+// subscription, on nodes with the authorize access model. Requests are answered with ApproveSubscription.
+func OnSubscriptionRequest(handler SubscriptionRequestHandler) Option {
+	return func(config *Config) {
+		config.SubscriptionRequestHandler = handler
+	}
+}
+
+// GetPendingSubscriptions fetches the subscription requests waiting for approval on the node.
+func (c *Client) GetPendingSubscriptions(ctx context.Context, service, node string) ([]PendingSubscription, error) {
+	return getPendingSubscriptions(ctx, c, service, node)
+}
+
+// ApproveSubscription approves, or denies, a pending subscription. Services that do not assign
+// subscription ids to pending subscriptions identify them by the subscriber JID, to pass as subid.
+func (c *Client) ApproveSubscription(ctx context.Context, service, node, subid string, approve bool) error {
+	return approveSubscription(ctx, c, service, node, subid, approve)
+}
+
+// handleSubscriptionRequest passes the subscription authorization requests to the handler.
+func (c *Client) handleSubscriptionRequest(p stanza.Packet) {
+	handler := c.config.SubscriptionRequestHandler
+	if handler == nil {
+		return
+	}
+	msg, ok := p.(stanza.Message)
+	if !ok {
+		return
+	}
+	if node, jid, subid, ok := subscriptionRequest(msg); ok {
+		go handler(msg.From, node, jid, subid)
+	}
+}
+
+// subscriptionRequest extracts the subscription authorization request sent by a service.
+// See XEP-0060 - 8.6.1 Request
+func subscriptionRequest(msg stanza.Message) (node, jid, subid string, ok bool) {
+	for _, ext := range msg.Extensions {
+		form, isForm := ext.(*stanza.Form)
+		if !isForm || form.Type != stanza.FormTypeForm || form.FormType() != subAuthorizationFormType {
+			continue
+		}
+		if f := form.Field("pubsub#node"); f != nil {
+			node = f.Value()
+		}
+		if f := form.Field("pubsub#subscriber_jid"); f != nil {
+			jid = f.Value()
+		}
+		if f := form.Field("pubsub#subid"); f != nil {
+			subid = f.Value()
+		}
+		return node, jid, subid, node != "" && jid != ""
+	}
+	return "", "", "", false
+}
+
+func getPendingSubscriptions(ctx context.Context, s Sender, service, node string) ([]PendingSubscription, error) {
+	subs, err := getNodeSubscriptions(ctx, s, service, node)
+	if err != nil {
+		return nil, err
+	}
+	var pending []PendingSubscription
+	for _, sub := range subs {
+		if sub.SubscriptionStatus == stanza.SubscriptionStatusPending {
+			pending = append(pending, PendingSubscription{JID: sub.Jid, SubID: sub.SubId})
+		}
+	}
+	return pending, nil
+}
+
+func approveSubscription(ctx context.Context, s Sender, service, node, subid string, approve bool) error {
+	if subid == "" {
+		return errors.New("a subscription id is required to approve a subscription")
+	}
+	pending, err := getPendingSubscriptions(ctx, s, service, node)
+	if err != nil {
+		return err
+	}
+	status := stanza.SubscriptionStatusSubscribed
+	if !approve {
+		status = stanza.SubscriptionStatusNone
+	}
+	for _, p := range pending {
+		if p.SubID == subid || (p.SubID == "" && p.JID == subid) {
+			iq, err := stanza.NewSubsForEntitiesRequest(service, node, []stanza.SubscriptionOwner{
+				{Jid: p.JID, SubId: p.SubID, SubscriptionStatus: status},
+			})
+			if err != nil {
+				return err
+			}
+			return sendOwnerIQ(ctx, s, iq)
+		}
+	}
+	return ErrItemNotFound
+}
+
+func getNodeSubscriptions(ctx context.Context, s Sender, service, node string) ([]stanza.SubscriptionOwner, error) {
+	iq, err := stanza.NewSubListRqPl(service, node)
+	if err != nil {
+		return nil, err
+	}
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return nil, err
+	}
+	if isItemNotFound(result) {
+		return nil, ErrItemNotFound
+	}
+	if err = iqError(result); err != nil {
+		return nil, err
+	}
+	ps, ok := result.Payload.(*stanza.PubSubOwner)
+	if !ok {
+		return nil, errors.New("invalid node subscriptions response")
+	}
+	subs, ok := ps.OwnerUseCase.(*stanza.SubscriptionsOwner)
+	if !ok {
+		return nil, errors.New("node subscriptions response has no subscriptions")
+	}
+	return subs.Subscriptions, nil
+}
+
+// ============================================================================
+// PubSub subscription options (XEP-0060 - 6.3 Configure Subscription Options)
+
+// Subscription options fields
+// See XEP-0060 - 16.4.3 pubsub#subscribe_options FORM_TYPE
+const (
+	subOptionsFormType        = "http://jabber.org/protocol/pubsub#subscribe_options"
+	subOptionsExpire          = "pubsub#expire"
+	subOptionsDeliver         = "pubsub#deliver"
+	subOptionsDigest          = "pubsub#digest"
+	subOptionsDigestFrequency = "pubsub#digest_frequency"
+)
+
+// GetSubscriptionOptions fetches the options of the account subscription to a node.
+// subid is only needed when the account has several subscriptions to the node.
+func (c *Client) GetSubscriptionOptions(ctx context.Context, service, node, subid string) (DataForm, error) {
+	return getSubscriptionOptions(ctx, c, stanza.SubInfo{Node: node, Jid: c.BareJID(), SubId: optionalSubId(subid)}, service)
+}
+
+// SetSubscriptionOptions submits new options for the account subscription to a node.
+// The form can be built with a SubscriptionOptionsBuilder.
+func (c *Client) SetSubscriptionOptions(ctx context.Context, service, node, subid string, form DataForm) error {
+	return setSubscriptionOptions(ctx, c, stanza.SubInfo{Node: node, Jid: c.BareJID(), SubId: optionalSubId(subid)}, service, form)
+}
+
+// SubscriptionOptionsBuilder builds a subscription options form, with typed setters for the standard options.
+type SubscriptionOptionsBuilder struct {
+	fields []*stanza.Field
+}
+
+// NewSubscriptionOptionsBuilder creates a builder for a subscription options form.
+func NewSubscriptionOptionsBuilder() *SubscriptionOptionsBuilder {
+	return &SubscriptionOptionsBuilder{}
+}
+
+// Expire sets the time at which the subscription expires.
+func (b *SubscriptionOptionsBuilder) Expire(t time.Time) *SubscriptionOptionsBuilder {
+	return b.set(subOptionsExpire, t.UTC().Format(time.RFC3339))
+}
+
+// Deliver enables or disables the delivery of notifications.
+func (b *SubscriptionOptionsBuilder) Deliver(deliver bool) *SubscriptionOptionsBuilder {
+	return b.set(subOptionsDeliver, strconv.FormatBool(deliver))
+}
+
+// Digest asks to receive notifications as digests, instead of one by one.
+func (b *SubscriptionOptionsBuilder) Digest(digest bool) *SubscriptionOptionsBuilder {
+	return b.set(subOptionsDigest, strconv.FormatBool(digest))
+}
+
+// DigestFrequency sets the minimum delay between two digests.
+func (b *SubscriptionOptionsBuilder) DigestFrequency(d time.Duration) *SubscriptionOptionsBuilder {
+	return b.set(subOptionsDigestFrequency, strconv.FormatInt(d.Milliseconds(), 10))
+}
+
+// Form returns the subscription options form, ready to be submitted.
+func (b *SubscriptionOptionsBuilder) Form() DataForm {
+	fields := []*stanza.Field{
+		{Var: "FORM_TYPE", Type: stanza.FieldTypeHidden, ValuesList: []string{subOptionsFormType}},
+	}
+	return *stanza.NewForm(append(fields, b.fields...), stanza.FormTypeSubmit)
+}
+
+func (b *SubscriptionOptionsBuilder) set(name, value string) *SubscriptionOptionsBuilder {
+	for _, f := range b.fields {
+		if f.Var == name {
+			f.ValuesList = []string{value}
+			return b
+		}
+	}
+	b.fields = append(b.fields, &stanza.Field{Var: name, ValuesList: []string{value}})
+	return b
+}
+
+// SubscriptionOptionsExpire returns the expiration time of the subscription, if it is set to a date and time.
+func SubscriptionOptionsExpire(form DataForm) (time.Time, bool) {
+	f := form.Field(subOptionsExpire)
+	if f == nil {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, f.Value())
+	return t, err == nil
+}
+
+// SubscriptionOptionsDeliver tells if notifications are delivered. Delivery is enabled by default.
+func SubscriptionOptionsDeliver(form DataForm) bool {
+	if f := form.Field(subOptionsDeliver); f != nil {
+		b, err := strconv.ParseBool(f.Value())
+		return err != nil || b
+	}
+	return true
+}
+
+// SubscriptionOptionsDigest tells if notifications are delivered as digests.
+func SubscriptionOptionsDigest(form DataForm) bool {
+	if f := form.Field(subOptionsDigest); f != nil {
+		b, err := strconv.ParseBool(f.Value())
+		return err == nil && b
+	}
+	return false
+}
+
+// SubscriptionOptionsDigestFrequency returns the minimum delay between two digests, or 0 if unknown.
+func SubscriptionOptionsDigestFrequency(form DataForm) time.Duration {
+	if f := form.Field(subOptionsDigestFrequency); f != nil {
+		ms, _ := strconv.ParseInt(f.Value(), 10, 64)
+		return time.Duration(ms) * time.Millisecond
+	}
+	return 0
+}
+
+func getSubscriptionOptions(ctx context.Context, s Sender, subInfo stanza.SubInfo, service string) (DataForm, error) {
+	iq, err := stanza.NewSubOptsRq(service, subInfo)
+	if err != nil {
+		return DataForm{}, err
+	}
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return DataForm{}, err
+	}
+	if isItemNotFound(result) || isInvalidSubId(result) {
+		return DataForm{}, ErrItemNotFound
+	}
+	if err = iqError(result); err != nil {
+		return DataForm{}, err
+	}
+	ps, ok := result.Payload.(*stanza.PubSubGeneric)
+	if !ok || ps.SubOptions == nil || ps.SubOptions.Form == nil {
+		return DataForm{}, errors.New("invalid subscription options response")
+	}
+	return *ps.SubOptions.Form, nil
+}
+
+func setSubscriptionOptions(ctx context.Context, s Sender, subInfo stanza.SubInfo, service string, form DataForm) error {
+	form.Type = stanza.FormTypeSubmit
+	iq, err := stanza.NewFormSubmission(service, subInfo, &form)
+	if err != nil {
+		return err
+	}
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return err
+	}
+	if isItemNotFound(result) || isInvalidSubId(result) {
+		return ErrItemNotFound
+	}
+	return iqError(result)
+}
+
+func optionalSubId(subid string) *string {
+	if subid == "" {
+		return nil
+	}
+	return &subid
+}
+
+// isInvalidSubId tells if the IQ is an error reporting an unknown subscription id.
+func isInvalidSubId(iq stanza.IQ) bool {
+	return iq.Type == stanza.IQTypeError && iq.Error != nil && iq.Error.Reason == "invalid-subid"
+}
+
+// isItemNotFound tells if the IQ is an item-not-found error.
+func isItemNotFound(iq stanza.IQ) bool {
+	return iq.Type == stanza.IQTypeError && iq.Error != nil && iq.Error.Reason == "item-not-found"
+}
@@ -0,0 +1,371 @@
+package xmpp
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestGetNodeConfig(t *testing.T) {
+	for _, model := range []string{AccessModelOpen, AccessModelRoster, AccessModelAuthorize, AccessModelWhitelist} {
+		response := fmt.Sprintf(`<iq type='result' from='pubsub.shakespeare.lit'>
+  <pubsub xmlns='http://jabber.org/protocol/pubsub#owner'>
+    <configure node='princely_musings'>
+      <x xmlns='jabber:x:data' type='form'>
+        <field var='FORM_TYPE' type='hidden'><value>http://jabber.org/protocol/pubsub#node_config</value></field>
+        <field var='pubsub#persist_items' type='boolean'><value>1</value></field>
+        <field var='pubsub#max_items' type='text-single'><value>10</value></field>
+        <field var='pubsub#access_model' type='list-single'><value>%s</value></field>
+      </x>
+    </configure>
+  </pubsub>
+</iq>`, model)
+		sender := &scriptedIQSender{t: t, responses: []string{response}}
+		form, err := getNodeConfig(context.Background(), sender, "pubsub.shakespeare.lit", "princely_musings")
+		if err != nil {
+			t.Fatalf("could not get node configuration: %v", err)
+		}
+		if sender.requests[0].Type != stanza.IQTypeGet {
+			t.Errorf("configuration should be requested with a get IQ")
+		}
+		if got := NodeConfigAccessModel(form); got != model {
+			t.Errorf("expected access model %s, got %s", model, got)
+		}
+		if NodeConfigMaxItems(form) != 10 || !NodeConfigPersistItems(form) {
+			t.Errorf("unexpected configuration: %+v", form)
+		}
+	}
+}
+
+func TestSetNodeConfig(t *testing.T) {
+	for _, model := range []string{AccessModelOpen, AccessModelRoster, AccessModelAuthorize, AccessModelWhitelist} {
+		sender := &scriptedIQSender{t: t, responses: []string{`<iq type='result'/>`}}
+		form := NewNodeConfigForm(20, model, PublishModelPublishers)
+		if err := setNodeConfig(context.Background(), sender, "pubsub.shakespeare.lit", "princely_musings", form); err != nil {
+			t.Fatalf("could not set node configuration: %v", err)
+		}
+		iq := sender.requests[0]
+		ps, ok := iq.Payload.(*stanza.PubSubOwner)
+		if !ok || iq.Type != stanza.IQTypeSet {
+			t.Fatalf("unexpected request: %+v", iq)
+		}
+		co, ok := ps.OwnerUseCase.(*stanza.ConfigureOwner)
+		if !ok || co.Node != "princely_musings" || co.Form == nil || co.Form.Type != stanza.FormTypeSubmit {
+			t.Fatalf("unexpected configuration request: %+v", ps.OwnerUseCase)
+		}
+		if NodeConfigAccessModel(*co.Form) != model || NodeConfigMaxItems(*co.Form) != 20 {
+			t.Errorf("unexpected submitted form: %+v", co.Form)
+		}
+		if co.Form.FormType() != nodeConfigFormType {
+			t.Errorf("submitted form should have the node_config FORM_TYPE")
+		}
+	}
+}
+
+func TestSetNodeConfigNotFound(t *testing.T) {
+	response := `<iq type='error' from='pubsub.shakespeare.lit'>
+  <error type='cancel'><item-not-found xmlns='urn:ietf:params:xml:ns:xmpp-stanzas'/></error>
+</iq>`
+	sender := &scriptedIQSender{t: t, responses: []string{response}}
+	err := setNodeConfig(context.Background(), sender, "pubsub.shakespeare.lit", "missing", NewNodeConfigForm(1, AccessModelOpen, ""))
+	if err != ErrItemNotFound {
+		t.Errorf("expected ErrItemNotFound, got %v", err)
+	}
+}
+
+func TestNodeConfigMaxItems(t *testing.T) {
+	if n := NodeConfigMaxItems(NewNodeConfigForm(-1, "", "")); n != -1 {
+		t.Errorf("max should be reported as -1, got %d", n)
+	}
+	if n := NodeConfigMaxItems(NewNodeConfigForm(0, "", "")); n != 0 {
+		t.Errorf("unset max items should be reported as 0, got %d", n)
+	}
+}
+
+func TestSubscriptionOptionsBuilder(t *testing.T) {
+	expire := time.Date(2026, 3, 14, 15, 9, 26, 0, time.FixedZone("CET", 3600))
+	form := NewSubscriptionOptionsBuilder().
+		Expire(expire).
+		Deliver(false).
+		Digest(true).
+		DigestFrequency(90 * time.Second).
+		Form()
+
+	if form.FormType() != subOptionsFormType || form.Type != stanza.FormTypeSubmit {
+		t.Errorf("unexpected form: %+v", form)
+	}
+	if f := form.Field(subOptionsExpire); f == nil || f.Value() != "2026-03-14T14:09:26Z" {
+		t.Errorf("expire should be encoded as xs:dateTime in UTC: %+v", f)
+	}
+	decoded, ok := SubscriptionOptionsExpire(form)
+	if !ok || !decoded.Equal(expire) {
+		t.Errorf("unexpected decoded expire time: %v", decoded)
+	}
+	if SubscriptionOptionsDeliver(form) || !SubscriptionOptionsDigest(form) {
+		t.Errorf("unexpected deliver or digest option")
+	}
+	if d := SubscriptionOptionsDigestFrequency(form); d != 90*time.Second {
+		t.Errorf("unexpected digest frequency: %v", d)
+	}
+}
+
+func TestGetSubscriptionOptions(t *testing.T) {
+	response := `<iq type='result' from='pubsub.shakespeare.lit'>
+  <pubsub xmlns='http://jabber.org/protocol/pubsub'>
+    <options node='princely_musings' jid='francisco@denmark.lit'>
+      <x xmlns='jabber:x:data' type='form'>
+        <field var='FORM_TYPE' type='hidden'><value>http://jabber.org/protocol/pubsub#subscribe_options</value></field>
+        <field var='pubsub#deliver' type='boolean'><value>1</value></field>
+        <field var='pubsub#digest' type='boolean'><value>0</value></field>
+        <field var='pubsub#expire'><value>2006-03-31T23:59Z</value></field>
+      </x>
+    </options>
+  </pubsub>
+</iq>`
+	sender := &scriptedIQSender{t: t, responses: []string{response}}
+	subInfo := stanza.SubInfo{Node: "princely_musings", Jid: "francisco@denmark.lit"}
+	form, err := getSubscriptionOptions(context.Background(), sender, subInfo, "pubsub.shakespeare.lit")
+	if err != nil {
+		t.Fatalf("could not get subscription options: %v", err)
+	}
+	if !SubscriptionOptionsDeliver(form) || SubscriptionOptionsDigest(form) {
+		t.Errorf("unexpected options: %+v", form)
+	}
+	// Not a valid xs:dateTime, seconds are missing
+	if _, ok := SubscriptionOptionsExpire(form); ok {
+		t.Errorf("invalid expire time should not be decoded")
+	}
+}
+
+func TestSetSubscriptionOptions(t *testing.T) {
+	sender := &scriptedIQSender{t: t, responses: []string{`<iq type='result'/>`}}
+	subid := "ba49252aaa4f5d320c24d3766f0bdcade78c78d3"
+	subInfo := stanza.SubInfo{Node: "princely_musings", Jid: "francisco@denmark.lit", SubId: optionalSubId(subid)}
+	form := NewSubscriptionOptionsBuilder().Deliver(true).Form()
+	if err := setSubscriptionOptions(context.Background(), sender, subInfo, "pubsub.shakespeare.lit", form); err != nil {
+		t.Fatalf("could not set subscription options: %v", err)
+	}
+	ps, ok := sender.requests[0].Payload.(*stanza.PubSubGeneric)
+	if !ok || ps.SubOptions == nil || ps.SubOptions.Form == nil {
+		t.Fatalf("unexpected request: %+v", sender.requests[0])
+	}
+	if ps.SubOptions.SubId == nil || *ps.SubOptions.SubId != subid || ps.SubOptions.Node != "princely_musings" {
+		t.Errorf("unexpected subscription: %+v", ps.SubOptions.SubInfo)
+	}
+}
+
+func TestSetSubscriptionOptionsInvalidSubId(t *testing.T) {
+	response := `<iq type='error' from='pubsub.shakespeare.lit'>
+  <error type='modify'>
+    <not-acceptable xmlns='urn:ietf:params:xml:ns:xmpp-stanzas'/>
+    <invalid-subid xmlns='http://jabber.org/protocol/pubsub#errors'/>
+  </error>
+</iq>`
+	sender := &scriptedIQSender{t: t, responses: []string{response}}
+	subInfo := stanza.SubInfo{Node: "princely_musings", Jid: "francisco@denmark.lit", SubId: optionalSubId("unknown")}
+	err := setSubscriptionOptions(context.Background(), sender, subInfo, "pubsub.shakespeare.lit", NewSubscriptionOptionsBuilder().Form())
+	if err != ErrItemNotFound {
+		t.Errorf("expected ErrItemNotFound, got %v", err)
+	}
+}
+
+func TestPurgeNode(t *testing.T) {
+	sender := &scriptedIQSender{t: t, responses: []string{`<iq type='result'/>`}}
+	if err := purgeNode(context.Background(), sender, "pubsub.shakespeare.lit", "princely_musings"); err != nil {
+		t.Fatalf("could not purge node: %v", err)
+	}
+	out, err := xml.Marshal(sender.requests[0])
+	if err != nil {
+		t.Fatalf("could not marshal request: %v", err)
+	}
+	expected := `<pubsub xmlns="http://jabber.org/protocol/pubsub#owner"><purge node="princely_musings"></purge></pubsub>`
+	if !strings.Contains(string(out), expected) || sender.requests[0].Type != stanza.IQTypeSet {
+		t.Errorf("purge should be sent in the pubsub#owner namespace: %s", out)
+	}
+}
+
+func TestDeleteNodeNotFound(t *testing.T) {
+	response := `<iq type='error'>
+  <error type='cancel'><item-not-found xmlns='urn:ietf:params:xml:ns:xmpp-stanzas'/></error>
+</iq>`
+	sender := &scriptedIQSender{t: t, responses: []string{response}}
+	if err := deleteNode(context.Background(), sender, "pubsub.shakespeare.lit", "missing"); err != ErrItemNotFound {
+		t.Errorf("expected ErrItemNotFound, got %v", err)
+	}
+	if _, ok := sender.requests[0].Payload.(*stanza.PubSubOwner).OwnerUseCase.(*stanza.DeleteOwner); !ok {
+		t.Errorf("unexpected request: %+v", sender.requests[0].Payload)
+	}
+}
+
+func TestGetNodeAffiliations(t *testing.T) {
+	response := `<iq type='result' from='pubsub.shakespeare.lit'>
+  <pubsub xmlns='http://jabber.org/protocol/pubsub#owner'>
+    <affiliations node='princely_musings'>
+      <affiliation jid='hamlet@denmark.lit' affiliation='owner'/>
+      <affiliation jid='polonius@denmark.lit' affiliation='outcast'/>
+    </affiliations>
+  </pubsub>
+</iq>`
+	sender := &scriptedIQSender{t: t, responses: []string{response}}
+	affs, err := getNodeAffiliations(context.Background(), sender, "pubsub.shakespeare.lit", "princely_musings")
+	if err != nil {
+		t.Fatalf("could not get affiliations: %v", err)
+	}
+	if len(affs) != 2 || affs[1] != (NodeAffiliation{JID: "polonius@denmark.lit", Affiliation: stanza.AffiliationStatusOutcast}) {
+		t.Errorf("unexpected affiliations: %+v", affs)
+	}
+}
+
+func TestSetNodeAffiliationsBatch(t *testing.T) {
+	sender := &scriptedIQSender{t: t, responses: []string{`<iq type='result'/>`}}
+	affs := []NodeAffiliation{
+		{JID: "bard@shakespeare.lit", Affiliation: stanza.AffiliationStatusPublisher},
+		{JID: "hamlet@denmark.lit", Affiliation: stanza.AffiliationStatusNone},
+		{JID: "polonius@denmark.lit", Affiliation: stanza.AffiliationStatusOutcast},
+	}
+	if err := setNodeAffiliations(context.Background(), sender, "pubsub.shakespeare.lit", "princely_musings", affs); err != nil {
+		t.Fatalf("could not set affiliations: %v", err)
+	}
+	if len(sender.requests) != 1 {
+		t.Fatalf("affiliations should be modified in a single IQ, got %d", len(sender.requests))
+	}
+	list, ok := sender.requests[0].Payload.(*stanza.PubSubOwner).OwnerUseCase.(*stanza.AffiliationsOwner)
+	if !ok || list.Node != "princely_musings" || len(list.Affiliations) != 3 {
+		t.Fatalf("unexpected request: %+v", sender.requests[0].Payload)
+	}
+	if list.Affiliations[1].Jid != "hamlet@denmark.lit" || list.Affiliations[1].AffiliationStatus != stanza.AffiliationStatusNone {
+		t.Errorf("unexpected affiliation: %+v", list.Affiliations[1])
+	}
+
+	invalid := []NodeAffiliation{{JID: "bard@shakespeare.lit", Affiliation: "king"}}
+	if err := setNodeAffiliations(context.Background(), sender, "pubsub.shakespeare.lit", "princely_musings", invalid); err == nil {
+		t.Errorf("invalid affiliation should be rejected")
+	}
+}
+
+const subAuthorizationRequest = `<message to='hamlet@denmark.lit' from='pubsub.shakespeare.lit' id='approve1'>
+  <x xmlns='jabber:x:data' type='form'>
+    <title>PubSub subscriber request</title>
+    <field var='FORM_TYPE' type='hidden'>
+      <value>http://jabber.org/protocol/pubsub#subscribe_authorization</value>
+    </field>
+    <field var='pubsub#subid' type='hidden'><value>123-abc</value></field>
+    <field var='pubsub#node' type='text-single'><value>princely_musings</value></field>
+    <field var='pubsub#subscriber_jid' type='jid-single'><value>horatio@denmark.lit</value></field>
+    <field var='pubsub#allow' type='boolean'><value>false</value></field>
+  </x>
+</message>`
+
+const pendingSubscriptions = `<iq type='result' from='pubsub.shakespeare.lit'>
+  <pubsub xmlns='http://jabber.org/protocol/pubsub#owner'>
+    <subscriptions node='princely_musings'>
+      <subscription jid='hamlet@denmark.lit' subscription='subscribed'/>
+      <subscription jid='horatio@denmark.lit' subscription='pending' subid='123-abc'/>
+      <subscription jid='bernardo@denmark.lit' subscription='pending'/>
+    </subscriptions>
+  </pubsub>
+</iq>`
+
+func TestSubscriptionRequestHandler(t *testing.T) {
+	requests := make(chan []string, 1)
+	config := &Config{}
+	config.Apply(OnSubscriptionRequest(func(service, node, subscriberJID, subid string) {
+		requests <- []string{service, node, subscriberJID, subid}
+	}))
+	c := &Client{config: config}
+
+	c.handleSubscriptionRequest(parseMessage(t, `<message from='hamlet@denmark.lit'><body>hi</body></message>`))
+	c.handleSubscriptionRequest(parseMessage(t, subAuthorizationRequest))
+	select {
+	case r := <-requests:
+		expected := []string{"pubsub.shakespeare.lit", "princely_musings", "horatio@denmark.lit", "123-abc"}
+		if strings.Join(r, " ") != strings.Join(expected, " ") {
+			t.Errorf("unexpected subscription request: %v", r)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscription request handler was not called")
+	}
+}
+
+func TestGetPendingSubscriptions(t *testing.T) {
+	sender := &scriptedIQSender{t: t, responses: []string{pendingSubscriptions}}
+	pending, err := getPendingSubscriptions(context.Background(), sender, "pubsub.shakespeare.lit", "princely_musings")
+	if err != nil {
+		t.Fatalf("could not get pending subscriptions: %v", err)
+	}
+	if len(pending) != 2 || pending[0] != (PendingSubscription{JID: "horatio@denmark.lit", SubID: "123-abc"}) ||
+		pending[1].JID != "bernardo@denmark.lit" {
+		t.Errorf("unexpected pending subscriptions: %+v", pending)
+	}
+	if sender.requests[0].Type != stanza.IQTypeGet {
+		t.Errorf("subscriptions should be requested with a get: %+v", sender.requests[0])
+	}
+}
+
+func TestApproveSubscriptionCycle(t *testing.T) {
+	// The owner receives the request, and approves it
+	node, jid, subid, ok := subscriptionRequest(parseMessage(t, subAuthorizationRequest))
+	if !ok {
+		t.Fatal("subscription request not found")
+	}
+	sender := &scriptedIQSender{t: t, responses: []string{pendingSubscriptions, `<iq type='result'/>`}}
+	if err := approveSubscription(context.Background(), sender, "pubsub.shakespeare.lit", node, subid, true); err != nil {
+		t.Fatalf("could not approve subscription: %v", err)
+	}
+	if len(sender.requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(sender.requests))
+	}
+	approval := sender.requests[1]
+	subs, ok := approval.Payload.(*stanza.PubSubOwner).OwnerUseCase.(*stanza.SubscriptionsOwner)
+	if !ok || approval.Type != stanza.IQTypeSet || subs.Node != "princely_musings" || len(subs.Subscriptions) != 1 {
+		t.Fatalf("unexpected approval: %+v", approval.Payload)
+	}
+	expected := stanza.SubscriptionOwner{Jid: jid, SubId: "123-abc", SubscriptionStatus: stanza.SubscriptionStatusSubscribed}
+	if subs.Subscriptions[0] != expected {
+		t.Errorf("unexpected subscription: %+v", subs.Subscriptions[0])
+	}
+	out, err := xml.Marshal(approval)
+	if err != nil {
+		t.Fatalf("could not marshal approval: %v", err)
+	}
+	if !strings.Contains(string(out), `xmlns="http://jabber.org/protocol/pubsub#owner"`) {
+		t.Errorf("approval should be sent in the pubsub#owner namespace: %s", out)
+	}
+
+	// The subscriber is then notified by the service
+	notification := parseMessage(t, `<message from='pubsub.shakespeare.lit' to='horatio@denmark.lit'>
+  <event xmlns='http://jabber.org/protocol/pubsub#event'>
+    <subscription node='princely_musings' jid='horatio@denmark.lit' subscription='subscribed'/>
+  </event>
+</message>`)
+	var event stanza.PubSubEvent
+	if !notification.Get(&event) {
+		t.Fatal("subscription notification not found")
+	}
+	sub, ok := event.EventElement.(*stanza.SubscriptionEvent)
+	if !ok || sub.SubStatus != stanza.SubscriptionStatusSubscribed || sub.Node != "princely_musings" {
+		t.Errorf("unexpected notification: %+v", event.EventElement)
+	}
+}
+
+func TestDenySubscription(t *testing.T) {
+	sender := &scriptedIQSender{t: t, responses: []string{pendingSubscriptions, `<iq type='result'/>`}}
+	// Pending subscriptions without subid are identified by the subscriber JID
+	if err := approveSubscription(context.Background(), sender, "pubsub.shakespeare.lit", "princely_musings", "bernardo@denmark.lit", false); err != nil {
+		t.Fatalf("could not deny subscription: %v", err)
+	}
+	subs := sender.requests[1].Payload.(*stanza.PubSubOwner).OwnerUseCase.(*stanza.SubscriptionsOwner)
+	if subs.Subscriptions[0] != (stanza.SubscriptionOwner{Jid: "bernardo@denmark.lit", SubscriptionStatus: stanza.SubscriptionStatusNone}) {
+		t.Errorf("unexpected subscription: %+v", subs.Subscriptions[0])
+	}
+
+	sender = &scriptedIQSender{t: t, responses: []string{pendingSubscriptions}}
+	if err := approveSubscription(context.Background(), sender, "pubsub.shakespeare.lit", "princely_musings", "unknown", true); err != ErrItemNotFound {
+		t.Errorf("expected ErrItemNotFound, got %v", err)
+	}
+}
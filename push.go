@@ -0,0 +1,161 @@
+package xmpp
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Push Notifications (XEP-0357)
+
+const (
+	// pushKeyIterations is the number of PBKDF2 iterations deriving the push encryption key
+	pushKeyIterations = 100000
+	// pushKeySize is the size of the AES-128 key encrypting the push summaries
+	pushKeySize = 16
+	// pushSaltSize is the number of random bytes of the salt generated by WithEncryptedPush
+	pushSaltSize = 16
+	// pushEncryptionAlgorithm is the algorithm advertised to the app server
+	pushEncryptionAlgorithm = "aes-128-gcm"
+	publishOptionsFormType  = "http://jabber.org/protocol/pubsub#publish-options"
+)
+
+// ErrPushKeyUnavailable is returned when enabling encrypted push notifications without key, because
+// the salt could not be generated.
+var ErrPushKeyUnavailable = errors.New("push encryption key unavailable")
+
+// PushSummary is the summary of the notifications the app server pushes to the device, as published
+// by the server in the urn:xmpp:push:summary form.
+type PushSummary struct {
+	MessageCount             int    `json:"message-count"`
+	LastMessageSender        string `json:"last-message-sender,omitempty"`
+	LastMessageBody          string `json:"last-message-body,omitempty"`
+	PendingSubscriptionCount int    `json:"pending-subscription-count,omitempty"`
+}
+
+// PushEncryption is the key encrypting the push summaries, derived from a password with
+// DerivePushKey. The salt must be kept by the application to derive the key again on the device.
+type PushEncryption struct {
+	Salt string
+	Key  []byte
+}
+
+// WithEncryptedPush makes EnablePush send an encryption key to the app server, so that it encrypts
+// the summaries it pushes with EncryptPushSummary. The key is derived from the password, with a
+// random salt available in Config.PushEncryption.
+func WithEncryptedPush(password string) Option {
+	return func(config *Config) {
+		enc := &PushEncryption{}
+		salt := make([]byte, pushSaltSize)
+		if _, err := rand.Read(salt); err == nil {
+			enc.Salt = base64.RawURLEncoding.EncodeToString(salt)
+			enc.Key = DerivePushKey(password, enc.Salt)
+		}
+		config.PushEncryption = enc
+	}
+}
+
+// DerivePushKey derives the AES-128 key encrypting the push summaries from the password, with
+// PBKDF2-SHA256.
+func DerivePushKey(password, salt string) []byte {
+	return pbkdf2Block(sha256.New, []byte(password), []byte(salt), pushKeyIterations)[:pushKeySize]
+}
+
+// EncryptPushSummary serializes the summary in JSON and encrypts it with AES-128-GCM. The random
+// nonce is prepended to the ciphertext.
+func EncryptPushSummary(summary PushSummary, key []byte) ([]byte, error) {
+	gcm, err := newPushCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+// DecryptPushSummary decrypts a summary encrypted with EncryptPushSummary.
+func DecryptPushSummary(ciphertext, key []byte) (PushSummary, error) {
+	var summary PushSummary
+	gcm, err := newPushCipher(key)
+	if err != nil {
+		return summary, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return summary, errors.New("push summary too short")
+	}
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	data, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return summary, err
+	}
+	err = json.Unmarshal(data, &summary)
+	return summary, err
+}
+
+func newPushCipher(key []byte) (cipher.AEAD, error) {
+	if len(key) != pushKeySize {
+		return nil, errors.New("push encryption key must be 16 bytes long")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EnablePush enables the push notifications, published on the node of the app server jid. With
+// WithEncryptedPush, the encryption key is sent in the publish options.
+func (c *Client) EnablePush(ctx context.Context, jid, node string) error {
+	return enablePush(ctx, c, jid, node, c.config.PushEncryption)
+}
+
+// DisablePush disables the push notifications sent to the node of the app server, or to all its
+// nodes when node is empty.
+func (c *Client) DisablePush(ctx context.Context, jid, node string) error {
+	iq, err := stanza.NewPushDisableIQ(jid, node)
+	if err != nil {
+		return err
+	}
+	return sendPushIQ(ctx, c, iq)
+}
+
+func enablePush(ctx context.Context, s Sender, jid, node string, enc *PushEncryption) error {
+	var options *stanza.Form
+	if enc != nil {
+		if len(enc.Key) == 0 {
+			return ErrPushKeyUnavailable
+		}
+		options = stanza.NewForm([]*stanza.Field{
+			{Var: "FORM_TYPE", Type: stanza.FieldTypeHidden, ValuesList: []string{publishOptionsFormType}},
+			{Var: "encryption-algorithm", ValuesList: []string{pushEncryptionAlgorithm}},
+			{Var: "encryption-key", ValuesList: []string{base64.StdEncoding.EncodeToString(enc.Key)}},
+		}, stanza.FormTypeSubmit)
+	}
+	iq, err := stanza.NewPushEnableIQ(jid, node, options)
+	if err != nil {
+		return err
+	}
+	return sendPushIQ(ctx, s, iq)
+}
+
+func sendPushIQ(ctx context.Context, s Sender, iq *stanza.IQ) error {
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return err
+	}
+	return iqError(result)
+}
@@ -0,0 +1,85 @@
+package xmpp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestPBKDF2Vectors(t *testing.T) {
+	// Test vectors from RFC 7914 - 11, truncated to the first block
+	tests := []struct {
+		password, salt string
+		iterations     int
+		expected       string
+	}{
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56"},
+	}
+	for _, tt := range tests {
+		key := pbkdf2Block(sha256.New, []byte(tt.password), []byte(tt.salt), tt.iterations)
+		if hex.EncodeToString(key) != tt.expected {
+			t.Errorf("unexpected PBKDF2 output for %s: %x", tt.password, key)
+		}
+	}
+
+	if key := DerivePushKey("password", "salt"); hex.EncodeToString(key) != "0394a2ede332c9a13eb82e9b24631604" {
+		t.Errorf("unexpected push key: %x", key)
+	}
+}
+
+func TestPushSummaryEncryption(t *testing.T) {
+	key := DerivePushKey("secret", "salt")
+	summary := PushSummary{MessageCount: 2, LastMessageSender: "juliet@capulet.lit/balcony", LastMessageBody: "Wherefore art thou?"}
+	ciphertext, err := EncryptPushSummary(summary, key)
+	if err != nil {
+		t.Fatalf("cannot encrypt summary: %v", err)
+	}
+	decrypted, err := DecryptPushSummary(ciphertext, key)
+	if err != nil {
+		t.Fatalf("cannot decrypt summary: %v", err)
+	}
+	if decrypted != summary {
+		t.Errorf("unexpected summary: %+v", decrypted)
+	}
+
+	if _, err = DecryptPushSummary(ciphertext, DerivePushKey("other", "salt")); err == nil {
+		t.Error("summary should not be decrypted with another key")
+	}
+	if _, err = EncryptPushSummary(summary, key[:8]); err == nil {
+		t.Error("short key should be rejected")
+	}
+}
+
+func TestEnableEncryptedPush(t *testing.T) {
+	config := &Config{}
+	config.Apply(WithEncryptedPush("secret"))
+	enc := config.PushEncryption
+	if enc == nil || enc.Salt == "" || string(enc.Key) != string(DerivePushKey("secret", enc.Salt)) {
+		t.Fatalf("unexpected push encryption: %+v", enc)
+	}
+
+	sender := &scriptedIQSender{t: t, responses: []string{`<iq type='result'/>`}}
+	if err := enablePush(context.Background(), sender, "push-5.client.example", "yxs32uqsflafdk3iuqo", enc); err != nil {
+		t.Fatalf("cannot enable push: %v", err)
+	}
+	enable, ok := sender.requests[0].Payload.(*stanza.PushEnable)
+	if !ok || enable.JID != "push-5.client.example" || enable.Node != "yxs32uqsflafdk3iuqo" || enable.Form == nil {
+		t.Fatalf("unexpected enable request: %+v", sender.requests[0].Payload)
+	}
+	if enable.Form.FormType() != publishOptionsFormType {
+		t.Errorf("unexpected form type: %s", enable.Form.FormType())
+	}
+	field := enable.Form.Field("encryption-key")
+	if field == nil || field.Value() != base64.StdEncoding.EncodeToString(enc.Key) {
+		t.Errorf("encryption key was not sent: %+v", field)
+	}
+
+	if err := enablePush(context.Background(), sender, "push-5.client.example", "", &PushEncryption{}); err != ErrPushKeyUnavailable {
+		t.Errorf("unexpected error without key: %v", err)
+	}
+}
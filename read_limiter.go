@@ -0,0 +1,154 @@
+package xmpp
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Inbound bandwidth limit
+
+// ReadLimiter caps the rate of the bytes read from the server, with a token bucket. It is enabled on
+// a connection by setting the ReadLimiter field of its TransportConfiguration. A limiter shared by
+// several connections caps their total bandwidth.
+//
+// Reads exceeding the budget are delayed rather than dropped, so that the server is slowed down by
+// TCP backpressure. The limit only applies once the session is established: stream negotiation is
+// never delayed.
+type ReadLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   int
+	tokens  float64
+	updated time.Time
+	stats   ReadLimiterStats
+	// sleep is replaced in tests
+	sleep func(time.Duration)
+}
+
+// ReadLimiterStats are the metrics of a read limiter.
+type ReadLimiterStats struct {
+	// Number of bytes read, including the ones read during stream negotiation
+	BytesRead int64
+	// Number of reads delayed by the limit, with their cumulated delay
+	Delayed    int64
+	TotalDelay time.Duration
+}
+
+// NewReadLimiter creates a read limiter allowing bytesPerSec bytes per second on average, and bursts
+// of up to burst bytes. A zero or negative rate disables the limit.
+func NewReadLimiter(bytesPerSec, burst int) *ReadLimiter {
+	l := &ReadLimiter{sleep: time.Sleep}
+	l.SetLimit(bytesPerSec, burst)
+	return l
+}
+
+// SetLimit changes the rate and burst of the limiter. It can be called while connections are using
+// it, for instance to apply a new quota. The bucket is refilled to the new burst size.
+func (l *ReadLimiter) SetLimit(bytesPerSec, burst int) {
+	if burst < 1 {
+		burst = 1
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = float64(bytesPerSec)
+	l.burst = burst
+	l.tokens = float64(burst)
+	l.updated = time.Now()
+}
+
+// Stats returns the current metrics of the limiter.
+func (l *ReadLimiter) Stats() ReadLimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stats
+}
+
+// maxRead returns the maximum number of bytes to read at once, so that a single read never
+// exceeds the burst.
+func (l *ReadLimiter) maxRead(n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate > 0 && n > l.burst {
+		return l.burst
+	}
+	return n
+}
+
+// consume counts n bytes read. When limited, it takes them from the bucket and waits until the
+// bucket is no longer in debt.
+func (l *ReadLimiter) consume(n int, limited bool) {
+	l.mu.Lock()
+	l.stats.BytesRead += int64(n)
+	if !limited || l.rate <= 0 {
+		l.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	l.tokens += now.Sub(l.updated).Seconds() * l.rate
+	if l.tokens > float64(l.burst) {
+		l.tokens = float64(l.burst)
+	}
+	l.updated = now
+	l.tokens -= float64(n)
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+		l.stats.Delayed++
+		l.stats.TotalDelay += delay
+	}
+	sleep := l.sleep
+	l.mu.Unlock()
+
+	if delay > 0 {
+		sleep(delay)
+	}
+}
+
+// limitedReadWriter reads from the connection through a read limiter. Reads are only counted
+// until limitReads is called, at the end of stream negotiation.
+type limitedReadWriter struct {
+	io.ReadWriter
+	limiter *ReadLimiter
+
+	mu      sync.RWMutex
+	limited bool
+}
+
+func newLimitedReadWriter(rw io.ReadWriter, limiter *ReadLimiter) io.ReadWriter {
+	if limiter == nil {
+		return rw
+	}
+	return &limitedReadWriter{ReadWriter: rw, limiter: limiter}
+}
+
+func (l *limitedReadWriter) Read(p []byte) (int, error) {
+	l.mu.RLock()
+	limited := l.limited
+	l.mu.RUnlock()
+	if limited {
+		p = p[:l.limiter.maxRead(len(p))]
+	}
+	n, err := l.ReadWriter.Read(p)
+	l.limiter.consume(n, limited)
+	return n, err
+}
+
+func (l *limitedReadWriter) limitReads() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limited = true
+}
+
+// readLimitedTransport is implemented by transports supporting a read limiter.
+type readLimitedTransport interface {
+	limitReads()
+}
+
+// startReadLimit applies the read limit of the transport, once the session is established.
+func startReadLimit(t Transport) {
+	if lt, ok := t.(readLimitedTransport); ok {
+		lt.limitReads()
+	}
+}
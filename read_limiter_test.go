@@ -0,0 +1,46 @@
+package xmpp
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestReadLimiter(t *testing.T) {
+	var delays []time.Duration
+	limiter := NewReadLimiter(100, 50)
+	limiter.sleep = func(d time.Duration) { delays = append(delays, d) }
+	conn := struct {
+		io.Reader
+		io.Writer
+	}{bytes.NewReader(make([]byte, 1000)), io.Discard}
+	rw := newLimitedReadWriter(conn, limiter).(*limitedReadWriter)
+
+	// Stream negotiation is not limited
+	buf := make([]byte, 200)
+	if n, _ := rw.Read(buf); n != 200 || len(delays) != 0 {
+		t.Fatalf("negotiation should not be limited: read %d bytes, %d delays", n, len(delays))
+	}
+
+	rw.limitReads()
+	// Reads are capped to the burst, which is consumed without delay
+	if n, _ := rw.Read(buf); n != 50 || len(delays) != 0 {
+		t.Fatalf("first read should use the burst: read %d bytes, %d delays", n, len(delays))
+	}
+	// The bucket is empty: the next read waits for the tokens
+	if n, _ := rw.Read(buf); n != 50 || len(delays) != 1 || delays[0] < 400*time.Millisecond {
+		t.Fatalf("read should be delayed: read %d bytes, delays %v", n, delays)
+	}
+
+	// The limit is adjusted at runtime
+	limiter.SetLimit(0, 0)
+	if n, _ := rw.Read(buf); n != 200 || len(delays) != 1 {
+		t.Errorf("disabled limit should not delay: read %d bytes, delays %v", n, delays)
+	}
+
+	stats := limiter.Stats()
+	if stats.BytesRead != 500 || stats.Delayed != 1 || stats.TotalDelay != delays[0] {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
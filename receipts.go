@@ -0,0 +1,176 @@
+package xmpp
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Delivery receipt negotiation (XEP-0184 - 5)
+
+// UnknownReceiptSupport tells whether to request a delivery receipt from a recipient whose support
+// of receipts is not known.
+type UnknownReceiptSupport uint8
+
+const (
+	// ReceiptsUnknownRequest requests a receipt anyway, as allowed by XEP-0184. This is the default.
+	ReceiptsUnknownRequest UnknownReceiptSupport = iota
+	// ReceiptsUnknownSkip does not request a receipt.
+	ReceiptsUnknownSkip
+	// ReceiptsUnknownAskDisco queries the service discovery information of the recipient, and
+	// requests a receipt if it advertises the feature. Only full JIDs are queried: a receipt is
+	// requested from the bare JIDs, as the server answers in place of their resources. The result
+	// is cached until the recipient goes offline.
+	ReceiptsUnknownAskDisco
+)
+
+// ReceiptRequestPolicy decides which messages request a delivery receipt, for the messages built with
+// WithReceiptRequestIfSupported.
+type ReceiptRequestPolicy struct {
+	// Unknown tells what to do when the capabilities of the recipient are not cached.
+	Unknown UnknownReceiptSupport
+	// Groupchat allows receipt requests in groupchat messages, which XEP-0184 discourages as every
+	// occupant would answer them.
+	Groupchat bool
+}
+
+// WithReceiptRequestPolicy sets the policy deciding which messages request a delivery receipt.
+func WithReceiptRequestPolicy(policy ReceiptRequestPolicy) Option {
+	return func(config *Config) {
+		config.ReceiptRequestPolicy = policy
+	}
+}
+
+// ShouldRequestReceipt tells if a delivery receipt should be requested for the message, according to
+// the capabilities of the recipient and the ReceiptRequestPolicy of the client. Messages without
+// recipient or id never request a receipt.
+func (c *Client) ShouldRequestReceipt(ctx context.Context, msg stanza.Message) bool {
+	return shouldRequestReceipt(ctx, c, &c.caps, c.config.ReceiptRequestPolicy, msg)
+}
+
+func shouldRequestReceipt(ctx context.Context, s Sender, caps *capsResolver, policy ReceiptRequestPolicy, msg stanza.Message) bool {
+	if msg.To == "" || msg.Id == "" {
+		return false
+	}
+	if msg.Type == stanza.MessageTypeGroupchat && !policy.Groupchat {
+		return false
+	}
+
+	if supported, known := caps.supports(msg.To, stanza.NSMsgReceipts); known {
+		return supported
+	}
+	switch policy.Unknown {
+	case ReceiptsUnknownSkip:
+		return false
+	case ReceiptsUnknownAskDisco:
+		if msg.To == bareJid(msg.To) {
+			return true
+		}
+		info, err := caps.discoInfo(ctx, s, msg.To)
+		return err == nil && info.HasFeature(stanza.NSMsgReceipts)
+	}
+	return true
+}
+
+// supports tells if jid advertises the feature in its cached capabilities, and if they are known. For
+// a bare JID, the feature is supported if one of its resources advertises it.
+func (r *capsResolver) supports(jid string, feature string) (supported bool, known bool) {
+	if jid != bareJid(jid) {
+		info, ok := r.cachedInfo(jid)
+		return ok && info.HasFeature(feature), ok
+	}
+
+	var resources []string
+	r.mu.Lock()
+	for full := range r.jids {
+		if strings.EqualFold(bareJid(full), jid) {
+			resources = append(resources, full)
+		}
+	}
+	r.mu.Unlock()
+	for _, full := range resources {
+		info, ok := r.cachedInfo(full)
+		if !ok {
+			continue
+		}
+		known = true
+		if info.HasFeature(feature) {
+			return true, true
+		}
+	}
+	return false, known
+}
+
+func getDiscoInfo(ctx context.Context, s Sender, jid string) (stanza.DiscoInfo, error) {
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, To: jid})
+	if err != nil {
+		return stanza.DiscoInfo{}, err
+	}
+	iq.DiscoInfo()
+
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return stanza.DiscoInfo{}, err
+	}
+	if err = iqError(result); err != nil {
+		return stanza.DiscoInfo{}, err
+	}
+	info, ok := result.Payload.(*stanza.DiscoInfo)
+	if !ok {
+		return stanza.DiscoInfo{}, errors.New("invalid disco info response")
+	}
+	return *info, nil
+}
+
+// ============================================================================
+// Message builder
+
+// MessageBuilder builds a message sent by a client.
+type MessageBuilder struct {
+	c   *Client
+	msg stanza.Message
+}
+
+// NewMessage starts building a message with the given attributes. An id is generated when none is
+// set, so that receipts and markers can refer to the message.
+func (c *Client) NewMessage(attrs stanza.Attrs) *MessageBuilder {
+	if attrs.Id == "" {
+		attrs.Id = uuid.New().String()
+	}
+	return &MessageBuilder{c: c, msg: stanza.NewMessage(attrs)}
+}
+
+// Body sets the body of the message.
+func (b *MessageBuilder) Body(body string) *MessageBuilder {
+	b.msg.Body = body
+	return b
+}
+
+// Extension adds an extension to the message.
+func (b *MessageBuilder) Extension(ext stanza.MsgExtension) *MessageBuilder {
+	b.msg.Extensions = append(b.msg.Extensions, ext)
+	return b
+}
+
+// WithReceiptRequestIfSupported requests a delivery receipt if the recipient supports it. See
+// ShouldRequestReceipt.
+func (b *MessageBuilder) WithReceiptRequestIfSupported(ctx context.Context) *MessageBuilder {
+	if b.c.ShouldRequestReceipt(ctx, b.msg) {
+		b.msg.Extensions = append(b.msg.Extensions, &stanza.ReceiptRequest{})
+	}
+	return b
+}
+
+// Message returns the message built.
+func (b *MessageBuilder) Message() stanza.Message {
+	return b.msg
+}
+
+// Send sends the message built.
+func (b *MessageBuilder) Send() error {
+	return b.c.Send(b.msg)
+}
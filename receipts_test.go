@@ -0,0 +1,142 @@
+package xmpp
+
+import (
+	"context"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// receiptsTestResolver returns a resolver with the capabilities of jid cached.
+func receiptsTestResolver(t *testing.T, jid string, info stanza.DiscoInfo) *capsResolver {
+	t.Helper()
+	sender := newCapsIQSender(info)
+	close(sender.release)
+	r := &capsResolver{}
+	caps := stanza.Caps{Hash: stanza.CapsHashSHA1, Node: capsTestNode, Ver: stanza.CapsVerification(info)}
+	r.update(sender, capsPresence(jid, caps))
+	if _, err := r.info(context.Background(), sender, jid); err != nil {
+		t.Fatalf("could not resolve capabilities: %v", err)
+	}
+	return r
+}
+
+func receiptsTestMessage(to string, msgType stanza.StanzaType) stanza.Message {
+	return stanza.NewMessage(stanza.Attrs{To: to, Id: "msg1", Type: msgType})
+}
+
+func TestShouldRequestReceiptKnownSupport(t *testing.T) {
+	ctx := context.Background()
+	sender := newCapsIQSender(capsTestInfo())
+	close(sender.release)
+	supporting := receiptsTestResolver(t, "juliet@capulet.lit/balcony", capsTestInfo(stanza.NSMsgReceipts))
+	other := receiptsTestResolver(t, "juliet@capulet.lit/balcony", capsTestInfo())
+
+	for _, policy := range []UnknownReceiptSupport{ReceiptsUnknownRequest, ReceiptsUnknownSkip, ReceiptsUnknownAskDisco} {
+		p := ReceiptRequestPolicy{Unknown: policy}
+		if !shouldRequestReceipt(ctx, sender, supporting, p, receiptsTestMessage("juliet@capulet.lit/balcony", stanza.MessageTypeChat)) {
+			t.Errorf("policy %d: a receipt should be requested from a supporting recipient", policy)
+		}
+		// Bare JID of a supporting resource
+		if !shouldRequestReceipt(ctx, sender, supporting, p, receiptsTestMessage("Juliet@capulet.lit", stanza.MessageTypeChat)) {
+			t.Errorf("policy %d: a receipt should be requested from the bare JID of a supporting resource", policy)
+		}
+		if shouldRequestReceipt(ctx, sender, other, p, receiptsTestMessage("juliet@capulet.lit/balcony", stanza.MessageTypeChat)) {
+			t.Errorf("policy %d: no receipt should be requested from a recipient without support", policy)
+		}
+	}
+	if len(sender.sent()) != 0 {
+		t.Errorf("known capabilities should not be queried")
+	}
+}
+
+func TestShouldRequestReceiptUnknownSupport(t *testing.T) {
+	ctx := context.Background()
+	msg := receiptsTestMessage("romeo@montague.lit/orchard", stanza.MessageTypeChat)
+	var r capsResolver
+
+	sender := newCapsIQSender(capsTestInfo(stanza.NSMsgReceipts))
+	close(sender.release)
+	if !shouldRequestReceipt(ctx, sender, &r, ReceiptRequestPolicy{}, msg) {
+		t.Errorf("a receipt should be requested by default when support is unknown")
+	}
+	if shouldRequestReceipt(ctx, sender, &r, ReceiptRequestPolicy{Unknown: ReceiptsUnknownSkip}, msg) {
+		t.Errorf("no receipt should be requested when support is unknown")
+	}
+	if len(sender.sent()) != 0 {
+		t.Fatalf("no query should be sent without the disco policy")
+	}
+
+	disco := ReceiptRequestPolicy{Unknown: ReceiptsUnknownAskDisco}
+	if !shouldRequestReceipt(ctx, sender, &r, disco, msg) {
+		t.Errorf("a receipt should be requested from a recipient advertising it in disco#info")
+	}
+	requests := sender.sent()
+	if len(requests) != 1 || requests[0].To != msg.To {
+		t.Fatalf("expected a disco#info query to the recipient, got %+v", requests)
+	}
+	if query, ok := requests[0].Payload.(*stanza.DiscoInfo); !ok || query.Node != "" {
+		t.Errorf("unexpected query: %+v", requests[0].Payload)
+	}
+
+	// The result is cached until the recipient goes offline
+	if !shouldRequestReceipt(ctx, sender, &r, disco, msg) || len(sender.sent()) != 1 {
+		t.Errorf("disco#info result should be cached")
+	}
+
+	// Bare JIDs are not queried
+	if !shouldRequestReceipt(ctx, sender, &r, disco, receiptsTestMessage("romeo@montague.lit", stanza.MessageTypeChat)) {
+		t.Errorf("a receipt should be requested from a bare JID")
+	}
+	if len(sender.sent()) != 1 {
+		t.Errorf("bare JIDs should not be queried")
+	}
+
+	r.update(sender, stanza.NewPresence(stanza.Attrs{From: msg.To, Type: stanza.PresenceTypeUnavailable}))
+	without := newCapsIQSender(capsTestInfo())
+	close(without.release)
+	if shouldRequestReceipt(ctx, without, &r, disco, msg) {
+		t.Errorf("no receipt should be requested from a recipient not advertising it in disco#info")
+	}
+	if len(without.sent()) != 1 {
+		t.Errorf("recipient should be queried again once offline")
+	}
+}
+
+func TestShouldRequestReceiptGroupchat(t *testing.T) {
+	ctx := context.Background()
+	sender := newCapsIQSender(capsTestInfo())
+	close(sender.release)
+	r := receiptsTestResolver(t, "coven@chat.shakespeare.lit/firstwitch", capsTestInfo(stanza.NSMsgReceipts))
+	msg := receiptsTestMessage("coven@chat.shakespeare.lit", stanza.MessageTypeGroupchat)
+
+	if shouldRequestReceipt(ctx, sender, r, ReceiptRequestPolicy{}, msg) {
+		t.Errorf("groupchat messages should not request receipts by default")
+	}
+	if !shouldRequestReceipt(ctx, sender, r, ReceiptRequestPolicy{Groupchat: true}, msg) {
+		t.Errorf("groupchat messages should request receipts when allowed")
+	}
+}
+
+func TestMessageBuilderReceiptRequest(t *testing.T) {
+	config := Config{}
+	config.Apply(WithReceiptRequestPolicy(ReceiptRequestPolicy{Unknown: ReceiptsUnknownSkip}))
+	c := &Client{config: &config}
+
+	msg := c.NewMessage(stanza.Attrs{To: "romeo@montague.lit/orchard"}).Body("Hello").
+		WithReceiptRequestIfSupported(context.Background()).Message()
+	if msg.Id == "" || msg.Body != "Hello" {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+	var req stanza.ReceiptRequest
+	if msg.Get(&req) {
+		t.Errorf("no receipt should be requested when support is unknown")
+	}
+
+	c.config.ReceiptRequestPolicy = ReceiptRequestPolicy{}
+	msg = c.NewMessage(stanza.Attrs{To: "romeo@montague.lit/orchard", Id: "msg2"}).
+		WithReceiptRequestIfSupported(context.Background()).Message()
+	if msg.Id != "msg2" || !msg.Get(&req) {
+		t.Errorf("a receipt should be requested: %+v", msg)
+	}
+}
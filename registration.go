@@ -0,0 +1,119 @@
+package xmpp
+
+import (
+	"context"
+	"errors"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// In-Band Registration (XEP-0077) with CAPTCHA Forms (XEP-0158)
+
+// registrationAttempts is the number of registration forms submitted before giving up, when the
+// service rejects the answers to its challenges.
+const registrationAttempts = 3
+
+// ErrRegistrationFormMissing is returned when the service does not provide a registration form.
+var ErrRegistrationFormMissing = errors.New("no registration form provided by the service")
+
+// RegistrationChallenge is the registration form returned by a service, with the media sent along,
+// for example the image of a CAPTCHA.
+type RegistrationChallenge struct {
+	Instructions string
+	Form         *stanza.Form
+	Media        []stanza.BobData
+	// Attempt is the number of the form, from 1. Forms are requested again when the service rejects
+	// the previous answers.
+	Attempt int
+}
+
+// FieldMedia returns the content and MIME type of the media of the field sent with the form, for
+// example the image of a CAPTCHA to display to the user.
+func (ch RegistrationChallenge) FieldMedia(name string) ([]byte, string, bool) {
+	if ch.Form == nil {
+		return nil, "", false
+	}
+	field := ch.Form.Field(name)
+	if field == nil || field.Media == nil {
+		return nil, "", false
+	}
+	for _, uri := range field.Media.URIs {
+		if data, ok := stanza.FindBobData(uri.Value, ch.Media); ok {
+			content, err := data.Decode()
+			if err != nil {
+				return nil, "", false
+			}
+			mimeType := data.Type
+			if mimeType == "" {
+				mimeType = uri.Type
+			}
+			return content, mimeType, true
+		}
+	}
+	return nil, "", false
+}
+
+// RegistrationFormHandler is called with the registration form, and returns the filled form to
+// submit, of type stanza.FormTypeSubmit.
+type RegistrationFormHandler func(ch RegistrationChallenge) (*stanza.Form, error)
+
+// RegisterWithForm registers with the service, for example a gateway, by filling its registration
+// form with the handler. When the service rejects the form as not acceptable, typically because the
+// CAPTCHA was not solved, a new form is requested, up to 3 times.
+func (c *Client) RegisterWithForm(ctx context.Context, service string, handler RegistrationFormHandler) error {
+	return registerWithForm(ctx, c, service, handler, registrationAttempts)
+}
+
+func registerWithForm(ctx context.Context, s Sender, service string, handler RegistrationFormHandler, attempts int) error {
+	var rejected error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		ch, err := getRegistrationForm(ctx, s, service)
+		if err != nil {
+			return err
+		}
+		ch.Attempt = attempt
+		form, err := handler(ch)
+		if err != nil {
+			return err
+		}
+
+		iq, err := stanza.NewRegisterSubmitIQ(service, form)
+		if err != nil {
+			return err
+		}
+		result, err := sendIQSync(ctx, s, iq)
+		if err != nil {
+			return err
+		}
+		if rejected = iqError(result); rejected == nil {
+			return nil
+		}
+		if result.Error == nil || result.Error.Reason != "not-acceptable" {
+			return rejected
+		}
+	}
+	return rejected
+}
+
+func getRegistrationForm(ctx context.Context, s Sender, service string) (RegistrationChallenge, error) {
+	iq, err := stanza.NewRegisterFormIQ(service)
+	if err != nil {
+		return RegistrationChallenge{}, err
+	}
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return RegistrationChallenge{}, err
+	}
+	if err = iqError(result); err != nil {
+		return RegistrationChallenge{}, err
+	}
+	reg, ok := result.Payload.(*stanza.Register)
+	if !ok {
+		return RegistrationChallenge{}, errors.New("invalid registration form response")
+	}
+	if reg.Form == nil {
+		return RegistrationChallenge{}, ErrRegistrationFormMissing
+	}
+	return RegistrationChallenge{Instructions: reg.Instructions, Form: reg.Form, Media: reg.Data}, nil
+}
@@ -0,0 +1,85 @@
+package xmpp
+
+import (
+	"context"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// Registration form with a CAPTCHA, adapted from XEP-0158 - Example 9
+const captchaRegistrationForm = `<iq type='result' from='shakespeare.lit'>
+  <query xmlns='jabber:iq:register'>
+    <instructions>Use the enclosed form to register.</instructions>
+    <x xmlns='jabber:x:data' type='form'>
+      <field type='hidden' var='FORM_TYPE'><value>urn:xmpp:captcha</value></field>
+      <field type='hidden' var='challenge'><value>F3A6292C</value></field>
+      <field label='Enter the text you see' var='ocr'>
+        <media xmlns='urn:xmpp:media-element' height='80' width='290'>
+          <uri type='image/png'>cid:sha1+f24030b8d91d233bac14777be5ab531ca3b9f102@bob.xmpp.org</uri>
+        </media>
+      </field>
+    </x>
+    <data xmlns='urn:xmpp:bob' cid='sha1+f24030b8d91d233bac14777be5ab531ca3b9f102@bob.xmpp.org' type='image/png' max-age='0'>iVBORw0KGgo=</data>
+  </query>
+</iq>`
+
+func TestRegisterWithCaptcha(t *testing.T) {
+	sender := &scriptedIQSender{t: t, responses: []string{
+		captchaRegistrationForm,
+		`<iq type='error' from='shakespeare.lit'><error type='modify'><not-acceptable xmlns='urn:ietf:params:xml:ns:xmpp-stanzas'/></error></iq>`,
+		captchaRegistrationForm,
+		`<iq type='result' from='shakespeare.lit'/>`,
+	}}
+
+	var challenges []RegistrationChallenge
+	handler := func(ch RegistrationChallenge) (*stanza.Form, error) {
+		challenges = append(challenges, ch)
+		image, mimeType, ok := ch.FieldMedia("ocr")
+		if !ok || mimeType != "image/png" || string(image[1:4]) != "PNG" {
+			t.Errorf("CAPTCHA image not found: %q %s", image, mimeType)
+		}
+		return stanza.NewForm([]*stanza.Field{
+			{Var: "FORM_TYPE", ValuesList: []string{"urn:xmpp:captcha"}},
+			{Var: "challenge", ValuesList: []string{ch.Form.Field("challenge").Value()}},
+			{Var: "ocr", ValuesList: []string{"7nHL3"}},
+		}, stanza.FormTypeSubmit), nil
+	}
+
+	if err := registerWithForm(context.Background(), sender, "shakespeare.lit", handler, registrationAttempts); err != nil {
+		t.Fatalf("registration failed: %v", err)
+	}
+	if len(challenges) != 2 || challenges[1].Attempt != 2 || challenges[0].Instructions != "Use the enclosed form to register." {
+		t.Errorf("unexpected challenges: %+v", challenges)
+	}
+	submit, ok := sender.requests[3].Payload.(*stanza.Register)
+	if !ok || submit.Form == nil || submit.Form.Field("ocr").Value() != "7nHL3" || sender.requests[3].Type != stanza.IQTypeSet {
+		t.Errorf("unexpected submitted form: %+v", sender.requests[3].Payload)
+	}
+}
+
+func TestRegisterWithFormAttempts(t *testing.T) {
+	notAcceptable := `<iq type='error' from='shakespeare.lit'><error type='modify'><not-acceptable xmlns='urn:ietf:params:xml:ns:xmpp-stanzas'/></error></iq>`
+	sender := &scriptedIQSender{t: t, responses: []string{
+		captchaRegistrationForm, notAcceptable,
+		captchaRegistrationForm, notAcceptable,
+	}}
+	handler := func(ch RegistrationChallenge) (*stanza.Form, error) {
+		return stanza.NewForm(nil, stanza.FormTypeSubmit), nil
+	}
+	if err := registerWithForm(context.Background(), sender, "shakespeare.lit", handler, 2); err == nil {
+		t.Error("registration should fail after the last attempt")
+	}
+	if len(sender.requests) != 4 {
+		t.Errorf("unexpected requests: %d", len(sender.requests))
+	}
+
+	// Other errors are not retried
+	sender = &scriptedIQSender{t: t, responses: []string{
+		captchaRegistrationForm,
+		`<iq type='error' from='shakespeare.lit'><error type='cancel'><conflict xmlns='urn:ietf:params:xml:ns:xmpp-stanzas'/></error></iq>`,
+	}}
+	if err := registerWithForm(context.Background(), sender, "shakespeare.lit", handler, 2); err == nil || len(sender.requests) != 2 {
+		t.Errorf("conflict should not be retried: %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package xmpp
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Resource selection for multi-resource contacts
+
+// ResourcePolicy tells how ResolveResource picks a resource of a contact. By default, the resource
+// with the highest priority is picked. Resources with a negative priority are never picked, as they
+// must not receive messages sent to the bare JID (RFC 6121 - 8.5.2.1.1).
+type ResourcePolicy struct {
+	// MostRecent picks the resource which sent the most recent presence, instead of the one with the
+	// highest priority
+	MostRecent bool
+	// Feature, if set, only picks resources advertising the feature in their capabilities
+	Feature string
+}
+
+// ResolveResource returns the full JID of the available resource of bareJID matching the policy,
+// or bareJID when no resource matches. Features are checked against the cached capabilities only,
+// without querying the resources.
+func (c *Client) ResolveResource(bareJID string, policy ResourcePolicy) string {
+	var hasFeature func(jid string) bool
+	if policy.Feature != "" {
+		hasFeature = func(jid string) bool {
+			info, ok := c.caps.cachedInfo(jid)
+			return ok && info.HasFeature(policy.Feature)
+		}
+	}
+	if jid, ok := c.resources.best(bareJID, policy.MostRecent, hasFeature); ok {
+		return jid
+	}
+	return bareJID
+}
+
+// SendToBest sends the message to the resource of its recipient picked by the policy, or to its
+// bare JID when no resource matches.
+func (c *Client) SendToBest(msg stanza.Message, policy ResourcePolicy) error {
+	if jid, err := stanza.NewJid(msg.To); err == nil {
+		msg.To = c.ResolveResource(jid.Bare(), policy)
+	}
+	return c.Send(msg)
+}
+
+// ResourceManager picks the resource of a contact to send messages to, following XEP-0296 - Best
+// Practices for Resource Locking: among the available resources with the highest priority, the one
+// the contact last interacted from, by sending a message or a presence, is picked. It is attached to
+// a client with WithResourceManager.
+type ResourceManager struct {
+	resources resourceTracker
+}
+
+// NewResourceManager creates a resource manager without known resources.
+func NewResourceManager() *ResourceManager {
+	return &ResourceManager{}
+}
+
+// WithResourceManager attaches the manager to the client, to track the resources of the contacts
+// from the stanzas it receives.
+func WithResourceManager(m *ResourceManager) Option {
+	return func(config *Config) {
+		config.ResourceManager = m
+	}
+}
+
+// BestTarget returns the full JID of the resource of bareJID to send messages to, or bareJID when
+// no resource is available.
+func (m *ResourceManager) BestTarget(bareJID string) string {
+	if jid, ok := m.resources.best(bareJID, false, nil); ok {
+		return jid
+	}
+	return bareJID
+}
+
+// update records the availability of the resources from the presences, and the interactions from
+// the messages.
+func (m *ResourceManager) update(p stanza.Packet) {
+	m.resources.update(p)
+	if msg, ok := p.(stanza.Message); ok && msg.Type != stanza.MessageTypeError {
+		m.resources.touch(msg.From)
+	}
+}
+
+// resourceTracker tracks the available resources of the contacts, from their presences.
+type resourceTracker struct {
+	mu sync.Mutex
+	// Available resources, by full JID, grouped by lower case bare JID
+	contacts map[string]map[string]resourcePresence
+}
+
+type resourcePresence struct {
+	priority int8
+	updated  time.Time
+}
+
+// update records the availability advertised by presences.
+func (t *resourceTracker) update(p stanza.Packet) {
+	pres, ok := p.(stanza.Presence)
+	if !ok {
+		return
+	}
+	jid, err := stanza.NewJid(pres.From)
+	if err != nil || jid.Resource == "" {
+		return
+	}
+	bare := strings.ToLower(jid.Bare())
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch pres.Type {
+	case "":
+		if t.contacts == nil {
+			t.contacts = make(map[string]map[string]resourcePresence)
+		}
+		if t.contacts[bare] == nil {
+			t.contacts[bare] = make(map[string]resourcePresence)
+		}
+		t.contacts[bare][pres.From] = resourcePresence{priority: pres.Priority, updated: time.Now()}
+	case stanza.PresenceTypeUnavailable, stanza.PresenceTypeError:
+		delete(t.contacts[bare], pres.From)
+		if len(t.contacts[bare]) == 0 {
+			delete(t.contacts, bare)
+		}
+	}
+}
+
+// touch records an interaction with the resource, if it is available.
+func (t *resourceTracker) touch(fullJID string) {
+	jid, err := stanza.NewJid(fullJID)
+	if err != nil || jid.Resource == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if r, ok := t.contacts[strings.ToLower(jid.Bare())][fullJID]; ok {
+		r.updated = time.Now()
+		t.contacts[strings.ToLower(jid.Bare())][fullJID] = r
+	}
+}
+
+// best returns the available resource of bareJID with the highest priority, or the most recent one.
+// Ties are broken by recency, then by JID. Resources with a negative priority, or rejected by accept,
+// are skipped.
+func (t *resourceTracker) best(bareJID string, mostRecent bool, accept func(jid string) bool) (string, bool) {
+	t.mu.Lock()
+	resources := make(map[string]resourcePresence, len(t.contacts[strings.ToLower(bareJID)]))
+	for jid, r := range t.contacts[strings.ToLower(bareJID)] {
+		resources[jid] = r
+	}
+	t.mu.Unlock()
+
+	var bestJID string
+	var best resourcePresence
+	for jid, r := range resources {
+		if r.priority < 0 || (accept != nil && !accept(jid)) {
+			continue
+		}
+		if bestJID == "" || r.preferred(best, mostRecent) || (!best.preferred(r, mostRecent) && jid < bestJID) {
+			bestJID, best = jid, r
+		}
+	}
+	return bestJID, bestJID != ""
+}
+
+// preferred tells if the resource r is preferred over other.
+func (r resourcePresence) preferred(other resourcePresence, mostRecent bool) bool {
+	if !mostRecent && r.priority != other.priority {
+		return r.priority > other.priority
+	}
+	return r.updated.After(other.updated)
+}
@@ -0,0 +1,78 @@
+package xmpp
+
+import (
+	"testing"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestResolveResource(t *testing.T) {
+	client := &Client{}
+	for _, p := range []stanza.Presence{
+		{Attrs: stanza.Attrs{From: "juliet@capulet.lit/balcony"}, Priority: 1},
+		{Attrs: stanza.Attrs{From: "juliet@capulet.lit/chamber"}, Priority: 5},
+		{Attrs: stanza.Attrs{From: "juliet@capulet.lit/hidden"}, Priority: 10},
+		{Attrs: stanza.Attrs{From: "juliet@capulet.lit/phone"}, Priority: 0},
+	} {
+		client.resources.update(p)
+	}
+	// Negative priorities are only set with a later presence
+	client.resources.update(stanza.Presence{Attrs: stanza.Attrs{From: "juliet@capulet.lit/hidden"}, Priority: -1})
+	client.resources.contacts["juliet@capulet.lit"]["juliet@capulet.lit/phone"] = resourcePresence{updated: time.Now().Add(time.Minute)}
+
+	if jid := client.ResolveResource("Juliet@capulet.lit", ResourcePolicy{}); jid != "juliet@capulet.lit/chamber" {
+		t.Errorf("highest non negative priority resource should be picked: %s", jid)
+	}
+	if jid := client.ResolveResource("juliet@capulet.lit", ResourcePolicy{MostRecent: true}); jid != "juliet@capulet.lit/phone" {
+		t.Errorf("most recent resource should be picked: %s", jid)
+	}
+
+	// Only the balcony resource advertises the feature
+	caps := stanza.Caps{Node: "http://psi-im.org", Ver: "q07IKJEyjvHSyhy//CH0CxmKi8w="}
+	client.caps.jids = map[string]stanza.Caps{"juliet@capulet.lit/balcony": caps}
+	client.caps.getCache().Set(caps.Node, caps.Ver, stanza.DiscoInfo{Features: []stanza.Feature{{Var: stanza.NSJingleRTP}}})
+	if jid := client.ResolveResource("juliet@capulet.lit", ResourcePolicy{Feature: stanza.NSJingleRTP}); jid != "juliet@capulet.lit/balcony" {
+		t.Errorf("resource advertising the feature should be picked: %s", jid)
+	}
+
+	client.resources.update(stanza.Presence{Attrs: stanza.Attrs{From: "juliet@capulet.lit/balcony", Type: stanza.PresenceTypeUnavailable}})
+	if jid := client.ResolveResource("juliet@capulet.lit", ResourcePolicy{Feature: stanza.NSJingleRTP}); jid != "juliet@capulet.lit" {
+		t.Errorf("bare JID should be returned when no resource matches: %s", jid)
+	}
+}
+
+func TestResourceManagerBestTarget(t *testing.T) {
+	m := NewResourceManager()
+	for _, p := range []stanza.Presence{
+		{Attrs: stanza.Attrs{From: "juliet@capulet.lit/balcony"}, Priority: 1},
+		{Attrs: stanza.Attrs{From: "juliet@capulet.lit/chamber"}, Priority: 1},
+	} {
+		m.update(p)
+	}
+	// Both resources have the same priority: the one the contact last sent a message from is picked
+	for _, resource := range []string{"chamber", "balcony"} {
+		time.Sleep(time.Millisecond)
+		m.update(stanza.Message{Attrs: stanza.Attrs{From: "juliet@capulet.lit/" + resource}, Body: "Wherefore art thou?"})
+		if jid := m.BestTarget("juliet@capulet.lit"); jid != "juliet@capulet.lit/"+resource {
+			t.Errorf("resource of the last message should be picked: %s", jid)
+		}
+	}
+
+	// Errors are not interactions
+	m.update(stanza.Message{Attrs: stanza.Attrs{From: "juliet@capulet.lit/chamber", Type: stanza.MessageTypeError}})
+	if jid := m.BestTarget("juliet@capulet.lit"); jid != "juliet@capulet.lit/balcony" {
+		t.Errorf("error should not change the picked resource: %s", jid)
+	}
+
+	m.update(stanza.Presence{Attrs: stanza.Attrs{From: "juliet@capulet.lit/balcony", Type: stanza.PresenceTypeUnavailable}})
+	if jid := m.BestTarget("juliet@capulet.lit"); jid != "juliet@capulet.lit/chamber" {
+		t.Errorf("unavailable resource should not be picked: %s", jid)
+	}
+	// A message from an unavailable resource does not make it available
+	m.update(stanza.Message{Attrs: stanza.Attrs{From: "juliet@capulet.lit/balcony"}, Body: "Good night"})
+	m.update(stanza.Presence{Attrs: stanza.Attrs{From: "juliet@capulet.lit/chamber", Type: stanza.PresenceTypeUnavailable}})
+	if jid := m.BestTarget("juliet@capulet.lit"); jid != "juliet@capulet.lit" {
+		t.Errorf("bare JID should be returned without available resource: %s", jid)
+	}
+}
@@ -0,0 +1,776 @@
+package xmpp
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Room helper (XEP-0045)
+
+// RoomEventType is the kind of event a Room notifies its handler about.
+type RoomEventType = uint8
+
+const (
+	// RoomDestroyed is notified when the room has been destroyed by its owner.
+	RoomDestroyed RoomEventType = iota
+	// RoomServiceShutdown is notified when the MUC service is shutting down (status code 332).
+	RoomServiceShutdown
+	// RoomPrivateMessage is notified when an occupant sends us a private message through the room.
+	RoomPrivateMessage
+	// RoomJoinFailed is notified when the room rejects our presence, with the reason in Error.
+	RoomJoinFailed
+	// RoomJoined is notified when the room accepts our presence, with the nickname we got in Nick.
+	RoomJoined
+)
+
+// RoomEvent is passed to the room EventHandler.
+type RoomEvent struct {
+	Type RoomEventType
+	// Room is the bare JID of the room the event relates to
+	Room   string
+	Reason string
+	// Alternate venue proposed when the room is destroyed, and the password to enter it, if any
+	Alternate         string
+	AlternatePassword string
+	// Nickname of the sender and message, for private messages. For RoomJoined, our nickname.
+	Nick    string
+	Message stanza.Message
+	// Error is the reason the room was not joined, for RoomJoinFailed. Common conditions are
+	// translated to errors such as ErrRoomNickConflict.
+	Error error
+}
+
+// RoomEventHandler is called when an event happens on a room.
+type RoomEventHandler func(e RoomEvent)
+
+// Room tracks the state of a Multi-User Chat room the client has joined.
+// It must be registered on the router with Route, so that it can process presences sent by the room.
+type Room struct {
+	// AutoJoinAlternate makes the room join the alternate venue, with the same nickname,
+	// when the room is destroyed and an alternate venue is provided.
+	AutoJoinAlternate bool
+	EventHandler      RoomEventHandler
+	// NickConflictResolver, if set, is called when our nickname is used by another occupant, to pick
+	// the nickname to join again with. It receives the nickname passed to NewRoom and the number of
+	// the retry, from 1. Rooms may reserve a nickname for us: it is then used instead, without retry.
+	NickConflictResolver NickConflictResolver
+	// MaxNickAttempts is the maximum number of retries with another nickname, 3 by default.
+	MaxNickAttempts int
+	// UseReservedNick makes Join query the nickname the room reserved for us, and join with it
+	// instead of the nickname passed to NewRoom.
+	UseReservedNick bool
+
+	sender   Sender
+	mu       sync.RWMutex
+	jid      string
+	nick     string
+	password string
+	// State of the join in progress: the nickname first requested, the retries after a conflict,
+	// and whether the nickname was reserved by the room
+	joining      bool
+	baseNick     string
+	nickAttempts int
+	nickReserved bool
+	// The reserved nickname was queried when joining, so it is not queried again on a conflict
+	reservedQueried bool
+	// Occupants seen since joining, by nickname
+	occupants map[string]Occupant
+}
+
+// Occupant is a user present in a room. JID is the real JID of the user, only known when the room
+// is non-anonymous or when we are a moderator.
+type Occupant struct {
+	Nick        string
+	JID         string
+	Affiliation string
+	Role        string
+}
+
+// NickConflictResolver returns the nickname to use for the attempt-th retry of joining a room,
+// for example by adding a suffix to nick.
+type NickConflictResolver func(nick string, attempt int) string
+
+// defaultMaxNickAttempts is the number of retries with another nickname when MaxNickAttempts is
+// not set.
+const defaultMaxNickAttempts = 3
+
+// reservedNickQueryTimeout bounds the query of the nickname reserved by the room.
+const reservedNickQueryTimeout = 30 * time.Second
+
+// NewRoom creates a Room helper for the given bare room JID and nickname.
+func NewRoom(s Sender, roomJid, nick string) (*Room, error) {
+	if roomJid == "" || nick == "" {
+		return nil, errors.New("room JID and nickname are required")
+	}
+	return &Room{sender: s, jid: roomJid, nick: nick}, nil
+}
+
+// Jid returns the bare JID of the room. It changes when the room moved to an alternate venue.
+func (r *Room) Jid() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.jid
+}
+
+// Nick returns the nickname used in the room.
+func (r *Room) Nick() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.nick
+}
+
+// SetPassword sets the password used to enter a password-protected room.
+func (r *Room) SetPassword(password string) {
+	r.mu.Lock()
+	r.password = password
+	r.mu.Unlock()
+}
+
+// Join sends the presence to enter the room. Once the room answers, a RoomJoined or a
+// RoomJoinFailed event is notified, provided the room was registered on the router with Route. On a
+// nickname conflict, the join is retried with NickConflictResolver before notifying the failure.
+//
+// With UseReservedNick, the nickname reserved by the room is used when there is one. Join does not
+// wait for the query: the presence is sent once it is answered, and an error sending it is notified
+// with a RoomJoinFailed event. Use JoinContext to wait for the query.
+func (r *Room) Join() error {
+	if !r.UseReservedNick {
+		return r.join("", false)
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), reservedNickQueryTimeout)
+		defer cancel()
+		if err := r.JoinContext(ctx); err != nil {
+			r.mu.Lock()
+			r.joining = false
+			r.mu.Unlock()
+			r.notify(RoomEvent{Type: RoomJoinFailed, Room: r.Jid(), Nick: r.Nick(), Error: err})
+		}
+	}()
+	return nil
+}
+
+// JoinContext is like Join, but waits for the query of the nickname reserved by the room with
+// UseReservedNick. Without answer before ctx is done, the room is joined with our nickname.
+// JoinContext must not be called from a router handler, as the answer could not be received.
+func (r *Room) JoinContext(ctx context.Context) error {
+	var reserved string
+	queried := false
+	if r.UseReservedNick {
+		// Without reserved nickname, the room is joined with ours
+		var err error
+		reserved, err = r.ReservedNick(ctx)
+		queried = err == nil
+	}
+	return r.join(reserved, queried)
+}
+
+// join starts joining the room, with the reserved nickname if there is one.
+func (r *Room) join(reserved string, queried bool) error {
+	r.mu.Lock()
+	r.joining = true
+	if reserved != "" {
+		r.nick = reserved
+	}
+	r.baseNick = r.nick
+	r.nickAttempts = 0
+	r.nickReserved = reserved != ""
+	r.reservedQueried = queried
+	r.mu.Unlock()
+	return r.sendJoin()
+}
+
+func (r *Room) sendJoin() error {
+	r.mu.RLock()
+	p := stanza.NewPresence(stanza.Attrs{To: r.jid + "/" + r.nick})
+	p.Extensions = append(p.Extensions, stanza.MucPresence{Password: r.password})
+	r.mu.RUnlock()
+	return r.sender.Send(p)
+}
+
+// Leave sends an unavailable presence to exit the room.
+func (r *Room) Leave() error {
+	r.mu.RLock()
+	p := stanza.NewPresence(stanza.Attrs{To: r.jid + "/" + r.nick, Type: stanza.PresenceTypeUnavailable})
+	r.mu.RUnlock()
+	return r.sender.Send(p)
+}
+
+// Route registers the room on the router, to process the presences it sends and the private
+// messages of its occupants. Groupchat messages are not handled by the room.
+func (r *Room) Route(router *Router) *Route {
+	return router.NewRoute().AddMatcher(roomMatcher{r}).Handler(r)
+}
+
+// HandlePacket processes the presences sent by the room, and notifies the private messages.
+// It implements the router Handler interface.
+func (r *Room) HandlePacket(_ Sender, p stanza.Packet) {
+	if msg, ok := p.(stanza.Message); ok {
+		r.notify(RoomEvent{Type: RoomPrivateMessage, Room: r.Jid(), Nick: nickOf(msg.From), Message: msg})
+		return
+	}
+	pres, ok := p.(stanza.Presence)
+	if !ok {
+		return
+	}
+	if pres.Type == stanza.PresenceTypeError {
+		if strings.EqualFold(pres.From, r.Jid()+"/"+r.Nick()) || pres.From == r.Jid() {
+			r.joinFailed(pres.Error)
+		}
+		return
+	}
+	var muc stanza.MucUser
+	hasMuc := pres.Get(&muc)
+	r.trackOccupant(pres, muc)
+	if pres.Type == "" && hasMuc && r.isSelf(pres.From, muc) {
+		r.joined(pres.From)
+		return
+	}
+	if pres.Type != stanza.PresenceTypeUnavailable || !hasMuc || !r.isSelf(pres.From, muc) {
+		return
+	}
+
+	switch {
+	case muc.Destroy != nil:
+		r.destroyed(muc.Destroy)
+	case muc.HasStatus(stanza.MucStatusServiceShutdown):
+		r.notify(RoomEvent{Type: RoomServiceShutdown, Room: r.Jid()})
+	}
+}
+
+// joined notifies the end of the join in progress. The room may have changed our nickname.
+func (r *Room) joined(occupantJid string) {
+	r.mu.Lock()
+	if nick := nickOf(occupantJid); nick != "" {
+		r.nick = nick
+	}
+	joining := r.joining
+	r.joining = false
+	nick := r.nick
+	r.mu.Unlock()
+	if joining {
+		r.notify(RoomEvent{Type: RoomJoined, Room: r.Jid(), Nick: nick})
+	}
+}
+
+// joinFailed joins again with another nickname on a conflict, or notifies the failure. When the
+// reserved nickname must be queried first, the retry runs in its own goroutine, so that the query
+// does not block the handling of the packets, including its answer.
+func (r *Room) joinFailed(e stanza.Err) {
+	err := roomJoinError(e)
+	if err == ErrRoomNickConflict && r.queriesReservedNick() {
+		go r.retryJoin(e, err)
+		return
+	}
+	r.retryJoin(e, err)
+}
+
+// queriesReservedNick tells if the next nickname after a conflict needs the reserved nickname to be
+// queried.
+func (r *Room) queriesReservedNick() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.joining && r.NickConflictResolver != nil && !r.nickReserved && !r.reservedQueried &&
+		r.nickAttempts == 0
+}
+
+// retryJoin joins again with another nickname on a conflict, or notifies the failure.
+func (r *Room) retryJoin(e stanza.Err, err error) {
+	if err == ErrRoomNickConflict {
+		if nick, ok := r.nextNick(); ok {
+			r.mu.Lock()
+			r.nick = nick
+			r.mu.Unlock()
+			if err = r.sendJoin(); err == nil {
+				return
+			}
+		}
+	}
+	r.mu.Lock()
+	r.joining = false
+	r.mu.Unlock()
+	r.notify(RoomEvent{Type: RoomJoinFailed, Room: r.Jid(), Nick: r.Nick(), Reason: e.Text.String(), Error: err})
+}
+
+// nextNick returns the nickname to join again with after a conflict. As advised by XEP-0045, the
+// nickname reserved by the room, if any, is tried first: it is not transformed, as rooms requiring
+// registration only accept the reserved nickname.
+func (r *Room) nextNick() (string, bool) {
+	r.mu.Lock()
+	max := r.MaxNickAttempts
+	if max <= 0 {
+		max = defaultMaxNickAttempts
+	}
+	if !r.joining || r.NickConflictResolver == nil || r.nickReserved || r.nickAttempts >= max {
+		r.mu.Unlock()
+		return "", false
+	}
+	r.nickAttempts++
+	attempt, base, current, queried := r.nickAttempts, r.baseNick, r.nick, r.reservedQueried
+	r.mu.Unlock()
+
+	if attempt == 1 && !queried {
+		ctx, cancel := context.WithTimeout(context.Background(), reservedNickQueryTimeout)
+		reserved, err := r.ReservedNick(ctx)
+		cancel()
+		if err == nil && reserved != "" {
+			r.mu.Lock()
+			r.nickReserved = true
+			r.mu.Unlock()
+			return reserved, reserved != current
+		}
+	}
+	return r.NickConflictResolver(base, attempt), true
+}
+
+// ReservedNick queries the nickname the room reserved for us, for example when registering with the
+// room. It returns an empty nickname when none is reserved.
+// See XEP-0045 - 7.12 Discovering Reserved Room Nickname
+func (r *Room) ReservedNick(ctx context.Context) (string, error) {
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, To: r.Jid()})
+	if err != nil {
+		return "", err
+	}
+	iq.DiscoInfo().SetNode("x-roomuser-item")
+
+	result, err := sendIQSync(ctx, r.sender, iq)
+	if err != nil {
+		return "", err
+	}
+	if err = iqError(result); err != nil {
+		return "", err
+	}
+	info, ok := result.Payload.(*stanza.DiscoInfo)
+	if !ok {
+		return "", errors.New("invalid reserved nickname response")
+	}
+	for _, identity := range info.Identity {
+		if identity.Category == "conference" && identity.Name != "" {
+			return identity.Name, nil
+		}
+	}
+	return "", nil
+}
+
+func (r *Room) destroyed(d *stanza.MucDestroy) {
+	r.notify(RoomEvent{
+		Type:              RoomDestroyed,
+		Room:              r.Jid(),
+		Reason:            d.Reason,
+		Alternate:         d.Jid,
+		AlternatePassword: d.Password,
+	})
+
+	if !r.AutoJoinAlternate || d.Jid == "" {
+		return
+	}
+	r.mu.Lock()
+	r.jid = bareJid(d.Jid)
+	r.password = d.Password
+	r.mu.Unlock()
+	if err := r.Join(); err != nil {
+		if c, ok := r.sender.(*Client); ok && c.ErrorHandler != nil {
+			c.ErrorHandler(err)
+		}
+	}
+}
+
+func (r *Room) notify(e RoomEvent) {
+	if r.EventHandler != nil {
+		r.EventHandler(e)
+	}
+}
+
+// isSelf checks that the presence is about our own occupant.
+func (r *Room) isSelf(from string, muc stanza.MucUser) bool {
+	if muc.HasStatus(stanza.MucStatusSelfPresence) {
+		return true
+	}
+	return from == r.Jid()+"/"+r.Nick()
+}
+
+// roomMatcher matches the presences sent from the room or one of its occupants, and the private
+// messages sent by its occupants.
+type roomMatcher struct {
+	room *Room
+}
+
+func (m roomMatcher) Match(p stanza.Packet, match *RouteMatch) bool {
+	switch packet := p.(type) {
+	case stanza.Presence:
+		return strings.EqualFold(bareJid(packet.From), m.room.Jid())
+	case stanza.Message:
+		return isPrivateMessageFrom(packet, m.room.Jid())
+	}
+	return false
+}
+
+// isPrivateMessageFrom tells if the message is a private message sent by an occupant of the room.
+func isPrivateMessageFrom(msg stanza.Message, roomJid string) bool {
+	switch msg.Type {
+	case stanza.MessageTypeGroupchat, stanza.MessageTypeError:
+		return false
+	}
+	return nickOf(msg.From) != "" && strings.EqualFold(bareJid(msg.From), roomJid)
+}
+
+// nickOf returns the nickname of an occupant JID, or an empty string for the room JID.
+func nickOf(occupantJid string) string {
+	parts := strings.SplitN(occupantJid, "/", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+	return parts[1]
+}
+
+// bareJid strips the resource part of a JID.
+func bareJid(jid string) string {
+	return strings.SplitN(jid, "/", 2)[0]
+}
+
+// ============================================================================
+// Join errors
+
+// Errors notified with RoomJoinFailed, for the common reasons of a room rejecting our presence.
+var (
+	// ErrRoomNickConflict is returned when the nickname is used by another occupant: join again
+	// with another one.
+	ErrRoomNickConflict = errors.New("nickname already in use in the room")
+	// ErrRoomPasswordRequired is returned when the room is password-protected and the password is
+	// missing or wrong: set it with SetPassword and join again.
+	ErrRoomPasswordRequired = errors.New("room password required")
+	// ErrRoomBanned is returned when we are banned from the room.
+	ErrRoomBanned = errors.New("banned from the room")
+	// ErrRoomNotFound is returned when the room does not exist, or is locked until its owner
+	// configures it.
+	ErrRoomNotFound = errors.New("room not found")
+	// ErrRoomUnavailable is returned when the room reached its maximum number of occupants, or the
+	// service is unavailable.
+	ErrRoomUnavailable = errors.New("room unavailable")
+	// ErrRoomMembersOnly is returned when the room is members-only and we are not a member.
+	ErrRoomMembersOnly = errors.New("room is members-only")
+)
+
+// roomJoinError translates the error of the presence bounced by the room. Other conditions are
+// returned with their text.
+func roomJoinError(e stanza.Err) error {
+	switch e.Reason {
+	case "conflict":
+		return ErrRoomNickConflict
+	case "not-authorized":
+		return ErrRoomPasswordRequired
+	case "forbidden":
+		return ErrRoomBanned
+	case "item-not-found":
+		return ErrRoomNotFound
+	case "service-unavailable":
+		return ErrRoomUnavailable
+	case "registration-required":
+		return ErrRoomMembersOnly
+	}
+	msg := "cannot join room"
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	if text := e.Text.String(); text != "" {
+		msg += " (" + text + ")"
+	}
+	return errors.New(msg)
+}
+
+// ============================================================================
+// Registration (XEP-0045 - 7.10)
+
+const roomRegisterFormType = "http://jabber.org/protocol/muc#register"
+
+// Errors returned when registering with a room.
+var (
+	// ErrRoomAlreadyRegistered is returned by GetRegistrationForm when we are already registered
+	// with the room.
+	ErrRoomAlreadyRegistered = errors.New("already registered with the room")
+	// ErrRoomNickRegistered is returned when the requested nickname is registered by another user.
+	ErrRoomNickRegistered = errors.New("nickname registered by another user")
+	// ErrRoomRegistrationNotSupported is returned when the room does not support registration.
+	ErrRoomRegistrationNotSupported = errors.New("room does not support registration")
+	// ErrRoomRegistrationRejected is returned when the room rejects the registration form, for
+	// example because required fields are missing.
+	ErrRoomRegistrationRejected = errors.New("registration rejected by the room")
+)
+
+// GetRegistrationForm requests the form to register with the room, required to enter members-only
+// rooms. Its fields are submitted with Register.
+func (r *Room) GetRegistrationForm(ctx context.Context) (*stanza.Form, error) {
+	iq, err := stanza.NewRegisterFormIQ(r.Jid())
+	if err != nil {
+		return nil, err
+	}
+	result, err := sendIQSync(ctx, r.sender, iq)
+	if err != nil {
+		return nil, err
+	}
+	if err = roomRegistrationError(result); err != nil {
+		return nil, err
+	}
+	reg, ok := result.Payload.(*stanza.Register)
+	if !ok {
+		return nil, errors.New("invalid room registration form response")
+	}
+	if reg.Registered != nil {
+		return nil, ErrRoomAlreadyRegistered
+	}
+	if reg.Form == nil {
+		return nil, ErrRegistrationFormMissing
+	}
+	return reg.Form, nil
+}
+
+// Register submits the registration form of the room with the values of its fields, for example
+// "muc#register_roomnick" for the nickname to reserve.
+func (r *Room) Register(ctx context.Context, fields map[string]string) error {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	formFields := []*stanza.Field{{Var: "FORM_TYPE", Type: stanza.FieldTypeHidden, ValuesList: []string{roomRegisterFormType}}}
+	for _, name := range names {
+		formFields = append(formFields, &stanza.Field{Var: name, ValuesList: []string{fields[name]}})
+	}
+
+	iq, err := stanza.NewRegisterSubmitIQ(r.Jid(), stanza.NewForm(formFields, stanza.FormTypeSubmit))
+	if err != nil {
+		return err
+	}
+	result, err := sendIQSync(ctx, r.sender, iq)
+	if err != nil {
+		return err
+	}
+	return roomRegistrationError(result)
+}
+
+// roomRegistrationError translates the error returned by the room to a registration request.
+func roomRegistrationError(result stanza.IQ) error {
+	if result.Type != stanza.IQTypeError || result.Error == nil {
+		return iqError(result)
+	}
+	switch result.Error.Reason {
+	case "conflict":
+		return ErrRoomNickRegistered
+	case "service-unavailable", "feature-not-implemented":
+		return ErrRoomRegistrationNotSupported
+	case "not-acceptable", "bad-request":
+		return ErrRoomRegistrationRejected
+	case "forbidden", "not-allowed":
+		return ErrRoomForbidden
+	case "registration-required":
+		return ErrRoomMembersOnly
+	}
+	return iqError(result)
+}
+
+// ============================================================================
+// Private messages
+
+// OccupantNotFoundError is returned when sending a private message to a nickname which is not
+// in the occupant list of the room.
+type OccupantNotFoundError struct {
+	Room string
+	Nick string
+}
+
+func (e *OccupantNotFoundError) Error() string {
+	return "no occupant " + e.Nick + " in room " + e.Room
+}
+
+// SendPrivate sends a private message to an occupant of the room. The message is not sent when the
+// occupant is not in the list of occupants seen since joining: an *OccupantNotFoundError is returned
+// instead of letting the room bounce it.
+func (r *Room) SendPrivate(nick, body string) error {
+	r.mu.RLock()
+	roomJid := r.jid
+	_, present := r.occupants[nick]
+	r.mu.RUnlock()
+	if !present {
+		return &OccupantNotFoundError{Room: roomJid, Nick: nick}
+	}
+	msg, err := stanza.NewMUCPrivateMessage(roomJid, nick, body)
+	if err != nil {
+		return err
+	}
+	return r.sender.Send(msg)
+}
+
+// ============================================================================
+// Occupants
+
+// ErrRoomForbidden is returned when a room rejects a request because of missing privileges.
+var ErrRoomForbidden = errors.New("not allowed by the room")
+
+// roomOccupantsPageSize is the number of occupants requested per disco#items page.
+const roomOccupantsPageSize = 100
+
+// Occupants returns the occupants seen since joining the room, sorted by nickname.
+// The list is only complete if the room was registered on the router before joining.
+func (r *Room) Occupants() []Occupant {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return sortedOccupants(r.occupants)
+}
+
+// trackOccupant updates the occupant list from a presence sent by the room.
+func (r *Room) trackOccupant(pres stanza.Presence, muc stanza.MucUser) {
+	nick := nickOf(pres.From)
+	if nick == "" || pres.Type == stanza.PresenceTypeError {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if pres.Type == stanza.PresenceTypeUnavailable {
+		if muc.HasStatus(stanza.MucStatusSelfPresence) || nick == r.nick {
+			// We left the room
+			r.occupants = nil
+			return
+		}
+		delete(r.occupants, nick)
+		return
+	}
+	o := Occupant{Nick: nick}
+	if len(muc.Items) > 0 {
+		o.JID = muc.Items[0].Jid
+		o.Affiliation = muc.Items[0].Affiliation
+		o.Role = muc.Items[0].Role
+	}
+	if r.occupants == nil {
+		r.occupants = make(map[string]Occupant)
+	}
+	r.occupants[nick] = o
+}
+
+// FetchOccupants queries the room for its occupant list, following the disco#items pages, and
+// completes it with the occupants seen since joining and the owner, admin and member lists.
+// Affiliation lists the client is not allowed to retrieve are skipped. Rooms that do not disclose
+// their occupants in disco#items only return the occupants seen since joining.
+func (r *Room) FetchOccupants(ctx context.Context) ([]Occupant, error) {
+	nicks, err := r.fetchOccupantNicks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	occupants := make(map[string]Occupant, len(r.occupants))
+	if len(nicks) == 0 {
+		for nick, o := range r.occupants {
+			occupants[nick] = o
+		}
+	}
+	for _, nick := range nicks {
+		o, ok := r.occupants[nick]
+		if !ok {
+			o = Occupant{Nick: nick}
+		}
+		occupants[nick] = o
+	}
+	r.mu.RUnlock()
+
+	for _, affiliation := range []string{stanza.MucAffiliationOwner, stanza.MucAffiliationAdmin, stanza.MucAffiliationMember} {
+		items, err := r.FetchAffiliationList(ctx, affiliation)
+		if err == ErrRoomForbidden {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			for nick, o := range occupants {
+				if (item.Jid != "" && strings.EqualFold(bareJid(o.JID), item.Jid)) || (item.Nick != "" && item.Nick == nick) {
+					o.Affiliation = affiliation
+					if o.JID == "" {
+						o.JID = item.Jid
+					}
+					occupants[nick] = o
+				}
+			}
+		}
+	}
+	return sortedOccupants(occupants), nil
+}
+
+// FetchAffiliationList queries the room for the users with the affiliation, for instance
+// stanza.MucAffiliationMember. Only room admins and owners are usually allowed to get them.
+func (r *Room) FetchAffiliationList(ctx context.Context, affiliation string) ([]stanza.MucItem, error) {
+	iq, err := stanza.NewMucAffiliationListRequest(r.Jid(), affiliation)
+	if err != nil {
+		return nil, err
+	}
+	result, err := sendIQSync(ctx, r.sender, iq)
+	if err != nil {
+		return nil, err
+	}
+	if result.Type == stanza.IQTypeError && result.Error != nil &&
+		(result.Error.Reason == "forbidden" || result.Error.Reason == "not-allowed") {
+		return nil, ErrRoomForbidden
+	}
+	if err = iqError(result); err != nil {
+		return nil, err
+	}
+	admin, ok := result.Payload.(*stanza.MucAdmin)
+	if !ok {
+		return nil, errors.New("invalid affiliation list response")
+	}
+	return admin.Items, nil
+}
+
+// fetchOccupantNicks returns the nicknames of the occupants listed by the room in disco#items.
+func (r *Room) fetchOccupantNicks(ctx context.Context) ([]string, error) {
+	var nicks []string
+	after := ""
+	fetched := 0
+	for {
+		iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, To: r.Jid()})
+		if err != nil {
+			return nil, err
+		}
+		iq.DiscoItems().ResultSet = stanza.NewRSMQuery(roomOccupantsPageSize, after).ResultSet()
+
+		result, err := sendIQSync(ctx, r.sender, iq)
+		if err != nil {
+			return nil, err
+		}
+		if err = iqError(result); err != nil {
+			return nil, err
+		}
+		res, ok := result.Payload.(*stanza.DiscoItems)
+		if !ok {
+			return nil, errors.New("invalid room occupants response")
+		}
+		fetched += len(res.Items)
+		for _, item := range res.Items {
+			if parts := strings.SplitN(item.JID, "/", 2); len(parts) == 2 && parts[1] != "" {
+				nicks = append(nicks, parts[1])
+			}
+		}
+
+		rs := stanza.NewRSMSet(res.ResultSet)
+		if len(res.Items) == 0 || rs.Last == "" || rs.Last == after || (rs.Count > 0 && fetched >= rs.Count) {
+			return nicks, nil
+		}
+		after = rs.Last
+	}
+}
+
+func sortedOccupants(occupants map[string]Occupant) []Occupant {
+	list := make([]Occupant, 0, len(occupants))
+	for _, o := range occupants {
+		list = append(list, o)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Nick < list[j].Nick })
+	return list
+}
@@ -0,0 +1,568 @@
+package xmpp
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+const roomDestroyedPresence = `<presence from='heath@chat.shakespeare.lit/secondwitch' to='wiccarocks@shakespeare.lit/laptop' type='unavailable'>
+  <x xmlns='http://jabber.org/protocol/muc#user'>
+    <item affiliation='none' role='none'/>
+    <destroy jid='coven@chat.shakespeare.lit'>
+      <reason>Macbeth doth come.</reason>
+      <password>cauldronburn</password>
+    </destroy>
+  </x>
+</presence>`
+
+const roomShutdownPresence = `<presence from='heath@chat.shakespeare.lit/secondwitch' to='wiccarocks@shakespeare.lit/laptop' type='unavailable'>
+  <x xmlns='http://jabber.org/protocol/muc#user'>
+    <item affiliation='none' role='none'/>
+    <status code='110'/>
+    <status code='332'/>
+  </x>
+</presence>`
+
+func parsePresence(t *testing.T, str string) stanza.Presence {
+	var p stanza.Presence
+	if err := xml.Unmarshal([]byte(str), &p); err != nil {
+		t.Fatalf("could not unmarshal presence: %v", err)
+	}
+	return p
+}
+
+func TestRoomDestroyed(t *testing.T) {
+	conn := NewSenderMock()
+	room, err := NewRoom(conn, "heath@chat.shakespeare.lit", "secondwitch")
+	if err != nil {
+		t.Fatalf("could not create room: %v", err)
+	}
+	var events []RoomEvent
+	room.EventHandler = func(e RoomEvent) { events = append(events, e) }
+
+	router := NewRouter()
+	room.Route(router)
+	router.route(conn, parsePresence(t, roomDestroyedPresence))
+
+	if len(events) != 1 {
+		t.Fatalf("expected one event, got %d", len(events))
+	}
+	e := events[0]
+	if e.Type != RoomDestroyed || e.Room != "heath@chat.shakespeare.lit" || e.Alternate != "coven@chat.shakespeare.lit" ||
+		e.AlternatePassword != "cauldronburn" || e.Reason != "Macbeth doth come." {
+		t.Errorf("unexpected event: %#v", e)
+	}
+	if conn.String() != "" {
+		t.Errorf("alternate room should not be joined without AutoJoinAlternate: %s", conn.String())
+	}
+}
+
+func TestRoomDestroyedAutoJoin(t *testing.T) {
+	conn := NewSenderMock()
+	room, err := NewRoom(conn, "heath@chat.shakespeare.lit", "secondwitch")
+	if err != nil {
+		t.Fatalf("could not create room: %v", err)
+	}
+	room.AutoJoinAlternate = true
+
+	router := NewRouter()
+	room.Route(router)
+	router.route(conn, parsePresence(t, roomDestroyedPresence))
+
+	if room.Jid() != "coven@chat.shakespeare.lit" {
+		t.Errorf("room should have moved to the alternate venue, got %s", room.Jid())
+	}
+	sent := conn.String()
+	if !strings.Contains(sent, `to="coven@chat.shakespeare.lit/secondwitch"`) ||
+		!strings.Contains(sent, "<password>cauldronburn</password>") {
+		t.Errorf("alternate room was not joined: %s", sent)
+	}
+}
+
+func TestRoomServiceShutdown(t *testing.T) {
+	conn := NewSenderMock()
+	room, err := NewRoom(conn, "heath@chat.shakespeare.lit", "secondwitch")
+	if err != nil {
+		t.Fatalf("could not create room: %v", err)
+	}
+	var events []RoomEvent
+	room.EventHandler = func(e RoomEvent) { events = append(events, e) }
+
+	router := NewRouter()
+	room.Route(router)
+	// Presence from another room is not handled
+	other := parsePresence(t, strings.Replace(roomShutdownPresence, "heath@", "other@", 1))
+	router.route(conn, other)
+	router.route(conn, parsePresence(t, roomShutdownPresence))
+
+	if len(events) != 1 || events[0].Type != RoomServiceShutdown || events[0].Room != "heath@chat.shakespeare.lit" {
+		t.Errorf("unexpected events: %#v", events)
+	}
+}
+
+func TestRoomOccupants(t *testing.T) {
+	conn := NewSenderMock()
+	room, err := NewRoom(conn, "coven@chat.shakespeare.lit", "thirdwitch")
+	if err != nil {
+		t.Fatalf("could not create room: %v", err)
+	}
+	router := NewRouter()
+	room.Route(router)
+	for _, p := range []string{
+		`<presence from='coven@chat.shakespeare.lit/firstwitch'><x xmlns='http://jabber.org/protocol/muc#user'><item affiliation='owner' role='moderator'/></x></presence>`,
+		`<presence from='coven@chat.shakespeare.lit/secondwitch'><x xmlns='http://jabber.org/protocol/muc#user'><item affiliation='admin' role='moderator' jid='wiccarocks@shakespeare.lit/laptop'/></x></presence>`,
+		`<presence from='coven@chat.shakespeare.lit/thirdwitch'><x xmlns='http://jabber.org/protocol/muc#user'><item affiliation='member' role='participant'/><status code='110'/></x></presence>`,
+		`<presence from='coven@chat.shakespeare.lit/firstwitch' type='unavailable'><x xmlns='http://jabber.org/protocol/muc#user'><item affiliation='owner' role='none'/></x></presence>`,
+	} {
+		router.route(conn, parsePresence(t, p))
+	}
+
+	occupants := room.Occupants()
+	if len(occupants) != 2 {
+		t.Fatalf("expected 2 occupants, got %+v", occupants)
+	}
+	if occupants[0] != (Occupant{Nick: "secondwitch", JID: "wiccarocks@shakespeare.lit/laptop", Affiliation: "admin", Role: "moderator"}) {
+		t.Errorf("unexpected occupant: %+v", occupants[0])
+	}
+
+	// Leaving the room clears the list
+	router.route(conn, parsePresence(t, `<presence from='coven@chat.shakespeare.lit/thirdwitch' type='unavailable'>
+  <x xmlns='http://jabber.org/protocol/muc#user'><item affiliation='member' role='none'/><status code='110'/></x>
+</presence>`))
+	if occupants = room.Occupants(); len(occupants) != 0 {
+		t.Errorf("occupants should be cleared when leaving: %+v", occupants)
+	}
+}
+
+func TestRoomFetchOccupants(t *testing.T) {
+	sender := &scriptedIQSender{t: t, responses: []string{
+		`<iq type='result' from='coven@chat.shakespeare.lit'>
+  <query xmlns='http://jabber.org/protocol/disco#items'>
+    <item jid='coven@chat.shakespeare.lit/firstwitch'/>
+    <item jid='coven@chat.shakespeare.lit/secondwitch'/>
+    <set xmlns='http://jabber.org/protocol/rsm'><first index='0'>firstwitch</first><last>secondwitch</last><count>3</count></set>
+  </query>
+</iq>`,
+		`<iq type='result' from='coven@chat.shakespeare.lit'>
+  <query xmlns='http://jabber.org/protocol/disco#items'>
+    <item jid='coven@chat.shakespeare.lit/thirdwitch'/>
+    <set xmlns='http://jabber.org/protocol/rsm'><first index='2'>thirdwitch</first><last>thirdwitch</last><count>3</count></set>
+  </query>
+</iq>`,
+		`<iq type='result' from='coven@chat.shakespeare.lit'>
+  <query xmlns='http://jabber.org/protocol/muc#admin'>
+    <item affiliation='owner' jid='crone1@shakespeare.lit' nick='firstwitch'/>
+  </query>
+</iq>`,
+		`<iq type='error' from='coven@chat.shakespeare.lit'>
+  <error type='auth'><forbidden xmlns='urn:ietf:params:xml:ns:xmpp-stanzas'/></error>
+</iq>`,
+		`<iq type='result' from='coven@chat.shakespeare.lit'>
+  <query xmlns='http://jabber.org/protocol/muc#admin'>
+    <item affiliation='member' jid='wiccarocks@shakespeare.lit'/>
+    <item affiliation='member' jid='hag66@shakespeare.lit'/>
+  </query>
+</iq>`,
+	}}
+	room, err := NewRoom(sender, "coven@chat.shakespeare.lit", "thirdwitch")
+	if err != nil {
+		t.Fatalf("could not create room: %v", err)
+	}
+	room.trackOccupant(parsePresence(t, `<presence from='coven@chat.shakespeare.lit/secondwitch'>
+  <x xmlns='http://jabber.org/protocol/muc#user'><item affiliation='none' role='participant' jid='wiccarocks@shakespeare.lit/laptop'/></x>
+</presence>`), stanza.MucUser{Items: []stanza.MucItem{{Affiliation: "none", Role: "participant", Jid: "wiccarocks@shakespeare.lit/laptop"}}})
+
+	occupants, err := room.FetchOccupants(context.Background())
+	if err != nil {
+		t.Fatalf("could not fetch occupants: %v", err)
+	}
+	expected := []Occupant{
+		{Nick: "firstwitch", JID: "crone1@shakespeare.lit", Affiliation: "owner"},
+		{Nick: "secondwitch", JID: "wiccarocks@shakespeare.lit/laptop", Affiliation: "member", Role: "participant"},
+		{Nick: "thirdwitch"},
+	}
+	if len(occupants) != len(expected) {
+		t.Fatalf("unexpected occupants: %+v", occupants)
+	}
+	for i := range expected {
+		if occupants[i] != expected[i] {
+			t.Errorf("unexpected occupant %d: %+v", i, occupants[i])
+		}
+	}
+
+	// The second page is requested after the last item of the first one
+	items, ok := sender.requests[1].Payload.(*stanza.DiscoItems)
+	if !ok || items.ResultSet == nil || items.ResultSet.After == nil || *items.ResultSet.After != "secondwitch" {
+		t.Errorf("unexpected second page request: %+v", sender.requests[1].Payload)
+	}
+}
+
+func TestRoomSendPrivate(t *testing.T) {
+	conn := NewSenderMock()
+	room, err := NewRoom(conn, "coven@chat.shakespeare.lit", "thirdwitch")
+	if err != nil {
+		t.Fatalf("could not create room: %v", err)
+	}
+	router := NewRouter()
+	room.Route(router)
+	router.route(conn, parsePresence(t, `<presence from='coven@chat.shakespeare.lit/firstwitch'><x xmlns='http://jabber.org/protocol/muc#user'><item affiliation='owner' role='moderator'/></x></presence>`))
+
+	err = room.SendPrivate("secondwitch", "Where hast thou been, sister?")
+	var notFound *OccupantNotFoundError
+	if !errors.As(err, &notFound) || notFound.Nick != "secondwitch" || notFound.Room != "coven@chat.shakespeare.lit" {
+		t.Fatalf("expected occupant not found error, got %v", err)
+	}
+	if conn.String() != "" {
+		t.Fatalf("message sent to an absent occupant: %s", conn.String())
+	}
+
+	if err = room.SendPrivate("firstwitch", "I'll give thee a wind."); err != nil {
+		t.Fatalf("could not send private message: %v", err)
+	}
+	sent := conn.String()
+	if !strings.Contains(sent, `to="coven@chat.shakespeare.lit/firstwitch"`) || !strings.Contains(sent, `type="chat"`) ||
+		!strings.Contains(sent, `<x xmlns="http://jabber.org/protocol/muc#user"></x>`) {
+		t.Errorf("unexpected private message: %s", sent)
+	}
+}
+
+func TestRoomPrivateMessageEvent(t *testing.T) {
+	conn := NewSenderMock()
+	room, err := NewRoom(conn, "coven@chat.shakespeare.lit", "thirdwitch")
+	if err != nil {
+		t.Fatalf("could not create room: %v", err)
+	}
+	var events []RoomEvent
+	room.EventHandler = func(e RoomEvent) { events = append(events, e) }
+	router := NewRouter()
+	room.Route(router)
+	var others []stanza.Message
+	router.HandleFunc("message", func(s Sender, p stanza.Packet) {
+		others = append(others, p.(stanza.Message))
+	})
+
+	for _, m := range []string{
+		`<message from='coven@chat.shakespeare.lit/firstwitch' type='groupchat'><body>Thrice the brinded cat hath mew'd.</body></message>`,
+		`<message from='coven@chat.shakespeare.lit/secondwitch' type='chat'><body>Psst</body><x xmlns='http://jabber.org/protocol/muc#user'/></message>`,
+		`<message from='coven@chat.shakespeare.lit' type='normal'><body>Room notice</body></message>`,
+		`<message from='hecate@shakespeare.lit/cave' type='chat'><body>Direct message</body></message>`,
+	} {
+		var msg stanza.Message
+		if err := xml.Unmarshal([]byte(m), &msg); err != nil {
+			t.Fatalf("could not unmarshal message: %v", err)
+		}
+		router.route(conn, msg)
+	}
+
+	if len(events) != 1 || events[0].Type != RoomPrivateMessage || events[0].Nick != "secondwitch" ||
+		events[0].Room != "coven@chat.shakespeare.lit" || events[0].Message.Body != "Psst" {
+		t.Errorf("unexpected events: %#v", events)
+	}
+	if len(others) != 3 {
+		t.Errorf("other messages should be routed as usual, got %d", len(others))
+	}
+}
+
+func TestRoomJoinFailed(t *testing.T) {
+	const bounced = `<presence from='coven@chat.shakespeare.lit/thirdwitch' to='hag66@shakespeare.lit/pda' type='error'>
+  <x xmlns='http://jabber.org/protocol/muc'/>
+  <error by='coven@chat.shakespeare.lit' type='cancel'>
+    <%REASON% xmlns='urn:ietf:params:xml:ns:xmpp-stanzas'/>
+  </error>
+</presence>`
+	tests := []struct {
+		reason string
+		err    error
+	}{
+		{"conflict", ErrRoomNickConflict},
+		{"not-authorized", ErrRoomPasswordRequired},
+		{"forbidden", ErrRoomBanned},
+		{"item-not-found", ErrRoomNotFound},
+		{"service-unavailable", ErrRoomUnavailable},
+		{"registration-required", ErrRoomMembersOnly},
+	}
+	for _, tt := range tests {
+		conn := NewSenderMock()
+		room, err := NewRoom(conn, "coven@chat.shakespeare.lit", "thirdwitch")
+		if err != nil {
+			t.Fatalf("could not create room: %v", err)
+		}
+		var events []RoomEvent
+		room.EventHandler = func(e RoomEvent) { events = append(events, e) }
+		router := NewRouter()
+		room.Route(router)
+
+		str := strings.Replace(bounced, "%REASON%", tt.reason, 1)
+		router.route(conn, parsePresence(t, str))
+		if len(events) != 1 || events[0].Type != RoomJoinFailed || events[0].Error != tt.err {
+			t.Errorf("%s: unexpected events: %#v", tt.reason, events)
+		}
+		if len(room.Occupants()) != 0 {
+			t.Errorf("%s: error presence should not add an occupant", tt.reason)
+		}
+	}
+
+	err := roomJoinError(stanza.Err{Reason: "not-acceptable", Text: stanza.NewLocalizedText("Nickname too long")})
+	if err == nil || err.Error() != "cannot join room: not-acceptable (Nickname too long)" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRoomNickConflictRetry(t *testing.T) {
+	const conflict = `<presence from='coven@chat.shakespeare.lit/%NICK%' type='error'>
+  <x xmlns='http://jabber.org/protocol/muc'/>
+  <error type='cancel'><conflict xmlns='urn:ietf:params:xml:ns:xmpp-stanzas'/></error>
+</presence>`
+	const joined = `<presence from='coven@chat.shakespeare.lit/thirdwitch_2'>
+  <x xmlns='http://jabber.org/protocol/muc#user'>
+    <item affiliation='member' role='participant'/>
+    <status code='110'/>
+  </x>
+</presence>`
+	// The room does not reserve a nickname for us
+	sender := newNotifyingSender(t,
+		`<iq type='result'><query xmlns='http://jabber.org/protocol/disco#info' node='x-roomuser-item'/></iq>`)
+	room, err := NewRoom(sender, "coven@chat.shakespeare.lit", "thirdwitch")
+	if err != nil {
+		t.Fatalf("could not create room: %v", err)
+	}
+	room.NickConflictResolver = func(nick string, attempt int) string {
+		return nick + "_" + strconv.Itoa(attempt)
+	}
+	var events []RoomEvent
+	room.EventHandler = func(e RoomEvent) { events = append(events, e) }
+	router := NewRouter()
+	room.Route(router)
+
+	if err = room.Join(); err != nil {
+		t.Fatalf("could not join room: %v", err)
+	}
+	sender.waitSent(t)
+	// The reserved nickname is queried without blocking the packet handling
+	router.route(sender, parsePresence(t, strings.Replace(conflict, "%NICK%", "thirdwitch", 1)))
+	sender.waitSent(t)
+	router.route(sender, parsePresence(t, strings.Replace(conflict, "%NICK%", "thirdwitch_1", 1)))
+	sender.waitSent(t)
+	router.route(sender, parsePresence(t, joined))
+
+	if len(sender.requests) != 1 || sender.requests[0].Payload.(*stanza.DiscoInfo).Node != "x-roomuser-item" {
+		t.Errorf("reserved nickname was not queried: %+v", sender.requests)
+	}
+	sent := sender.String()
+	for _, nick := range []string{"thirdwitch", "thirdwitch_1", "thirdwitch_2"} {
+		if !strings.Contains(sent, `to="coven@chat.shakespeare.lit/`+nick+`"`) {
+			t.Errorf("room was not joined as %s: %s", nick, sent)
+		}
+	}
+	if len(events) != 1 || events[0].Type != RoomJoined || events[0].Nick != "thirdwitch_2" || room.Nick() != "thirdwitch_2" {
+		t.Errorf("unexpected events: %#v", events)
+	}
+}
+
+func TestRoomNickConflictReserved(t *testing.T) {
+	const conflict = `<presence from='coven@chat.shakespeare.lit/thirdwitch' type='error'>
+  <error type='cancel'><conflict xmlns='urn:ietf:params:xml:ns:xmpp-stanzas'/></error>
+</presence>`
+	sender := newNotifyingSender(t, `<iq type='result'><query xmlns='http://jabber.org/protocol/disco#info' node='x-roomuser-item'>
+  <identity category='conference' name='thirdwitch' type='text'/>
+</query></iq>`)
+	room, err := NewRoom(sender, "coven@chat.shakespeare.lit", "thirdwitch")
+	if err != nil {
+		t.Fatalf("could not create room: %v", err)
+	}
+	room.NickConflictResolver = func(nick string, attempt int) string {
+		t.Errorf("reserved nickname should not be transformed")
+		return nick
+	}
+	events := make(chan RoomEvent, 1)
+	room.EventHandler = func(e RoomEvent) { events <- e }
+	router := NewRouter()
+	room.Route(router)
+
+	if err = room.Join(); err != nil {
+		t.Fatalf("could not join room: %v", err)
+	}
+	router.route(sender, parsePresence(t, conflict))
+	if e := waitRoomEvent(t, events); e.Type != RoomJoinFailed || e.Error != ErrRoomNickConflict {
+		t.Errorf("unexpected event: %#v", e)
+	}
+
+	// Other conditions are not retried
+	if err = room.Join(); err != nil {
+		t.Fatalf("could not join room: %v", err)
+	}
+	router.route(sender, parsePresence(t, strings.Replace(conflict, "conflict", "registration-required", 2)))
+	if e := waitRoomEvent(t, events); e.Error != ErrRoomMembersOnly {
+		t.Errorf("unexpected event: %#v", e)
+	}
+}
+
+func waitRoomEvent(t *testing.T, events chan RoomEvent) RoomEvent {
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(defaultTimeout):
+		t.Fatal("room event was not notified")
+	}
+	return RoomEvent{}
+}
+
+func TestRoomRegistration(t *testing.T) {
+	sender := &scriptedIQSender{SenderMock: NewSenderMock(), t: t, responses: []string{
+		`<iq type='result' from='coven@chat.shakespeare.lit'>
+  <query xmlns='jabber:iq:register'>
+    <instructions>To register on the web, visit http://shakespeare.lit/</instructions>
+    <x xmlns='jabber:x:data' type='form'>
+      <field type='hidden' var='FORM_TYPE'><value>http://jabber.org/protocol/muc#register</value></field>
+      <field label='Desired Nickname' type='text-single' var='muc#register_roomnick'><required/></field>
+    </x>
+  </query>
+</iq>`,
+		`<iq type='result' from='coven@chat.shakespeare.lit'/>`,
+		`<iq type='result' from='coven@chat.shakespeare.lit'>
+  <query xmlns='jabber:iq:register'><registered/><username>thirdwitch</username></query>
+</iq>`,
+	}}
+	room, err := NewRoom(sender, "coven@chat.shakespeare.lit", "hag66")
+	if err != nil {
+		t.Fatalf("could not create room: %v", err)
+	}
+
+	form, err := room.GetRegistrationForm(context.Background())
+	if err != nil {
+		t.Fatalf("could not get registration form: %v", err)
+	}
+	if form.Field("muc#register_roomnick") == nil {
+		t.Errorf("unexpected form: %+v", form)
+	}
+
+	if err = room.Register(context.Background(), map[string]string{"muc#register_roomnick": "thirdwitch"}); err != nil {
+		t.Fatalf("could not register: %v", err)
+	}
+	submitted := sender.requests[1].Payload.(*stanza.Register).Form
+	if submitted.Type != stanza.FormTypeSubmit || submitted.FormType() != roomRegisterFormType ||
+		submitted.Field("muc#register_roomnick").Value() != "thirdwitch" {
+		t.Errorf("unexpected submitted form: %+v", submitted)
+	}
+
+	if _, err = room.GetRegistrationForm(context.Background()); err != ErrRoomAlreadyRegistered {
+		t.Errorf("expected to be already registered, got %v", err)
+	}
+}
+
+func TestRoomRegistrationErrors(t *testing.T) {
+	for condition, expected := range map[string]error{
+		"conflict":            ErrRoomNickRegistered,
+		"service-unavailable": ErrRoomRegistrationNotSupported,
+		"not-acceptable":      ErrRoomRegistrationRejected,
+		"forbidden":           ErrRoomForbidden,
+	} {
+		sender := &scriptedIQSender{SenderMock: NewSenderMock(), t: t, responses: []string{
+			`<iq type='error' from='coven@chat.shakespeare.lit'>
+  <error type='cancel'><` + condition + ` xmlns='urn:ietf:params:xml:ns:xmpp-stanzas'/></error>
+</iq>`,
+		}}
+		room, _ := NewRoom(sender, "coven@chat.shakespeare.lit", "hag66")
+		err := room.Register(context.Background(), map[string]string{"muc#register_roomnick": "thirdwitch"})
+		if err != expected {
+			t.Errorf("%s: expected %v, got %v", condition, expected, err)
+		}
+	}
+}
+
+func TestRoomJoinReservedNick(t *testing.T) {
+	sender := &scriptedIQSender{SenderMock: NewSenderMock(), t: t, responses: []string{
+		`<iq type='result'><query xmlns='http://jabber.org/protocol/disco#info' node='x-roomuser-item'>
+  <identity category='conference' name='thirdwitch' type='text'/>
+</query></iq>`,
+		`<iq type='result'><query xmlns='http://jabber.org/protocol/disco#info' node='x-roomuser-item'/></iq>`,
+	}}
+	room, err := NewRoom(sender, "coven@chat.shakespeare.lit", "hag66")
+	if err != nil {
+		t.Fatalf("could not create room: %v", err)
+	}
+	room.UseReservedNick = true
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	if err = room.JoinContext(ctx); err != nil {
+		t.Fatalf("could not join room: %v", err)
+	}
+	if !strings.Contains(sender.String(), `to="coven@chat.shakespeare.lit/thirdwitch"`) || room.Nick() != "thirdwitch" {
+		t.Errorf("room should be joined with the reserved nickname: %s", sender.String())
+	}
+
+	// Without reserved nickname, ours is kept
+	sender.buffer.Reset()
+	if err = room.JoinContext(ctx); err != nil {
+		t.Fatalf("could not join room: %v", err)
+	}
+	if room.Nick() != "thirdwitch" || len(sender.requests) != 2 {
+		t.Errorf("nickname should be kept: %s", room.Nick())
+	}
+
+	// The reserved nickname is not queried again on a conflict
+	room.NickConflictResolver = func(nick string, attempt int) string { return nick + "_" + strconv.Itoa(attempt) }
+	router := NewRouter()
+	room.Route(router)
+	router.route(sender, parsePresence(t, `<presence from='coven@chat.shakespeare.lit/thirdwitch' type='error'>
+  <error type='cancel'><conflict xmlns='urn:ietf:params:xml:ns:xmpp-stanzas'/></error>
+</presence>`))
+	if room.Nick() != "thirdwitch_1" || len(sender.requests) != 2 {
+		t.Errorf("unexpected retry as %s after %d queries", room.Nick(), len(sender.requests))
+	}
+}
+
+// notifyingSender signals each packet sent, for the packets sent by another goroutine.
+type notifyingSender struct {
+	*scriptedIQSender
+	sent chan struct{}
+}
+
+func newNotifyingSender(t *testing.T, responses ...string) notifyingSender {
+	return notifyingSender{
+		scriptedIQSender: &scriptedIQSender{SenderMock: NewSenderMock(), t: t, responses: responses},
+		sent:             make(chan struct{}, 10),
+	}
+}
+
+func (s notifyingSender) Send(p stanza.Packet) error {
+	err := s.scriptedIQSender.Send(p)
+	s.sent <- struct{}{}
+	return err
+}
+
+// waitSent waits for the next packet sent.
+func (s notifyingSender) waitSent(t *testing.T) {
+	select {
+	case <-s.sent:
+	case <-time.After(defaultTimeout):
+		t.Fatal("packet was not sent")
+	}
+}
+
+func TestRoomJoinReservedNickAsync(t *testing.T) {
+	sender := newNotifyingSender(t, `<iq type='result'><query xmlns='http://jabber.org/protocol/disco#info' node='x-roomuser-item'>
+  <identity category='conference' name='thirdwitch' type='text'/>
+</query></iq>`)
+	room, err := NewRoom(sender, "coven@chat.shakespeare.lit", "hag66")
+	if err != nil {
+		t.Fatalf("could not create room: %v", err)
+	}
+	room.UseReservedNick = true
+
+	if err = room.Join(); err != nil {
+		t.Fatalf("could not join room: %v", err)
+	}
+	sender.waitSent(t)
+	if !strings.Contains(sender.String(), `to="coven@chat.shakespeare.lit/thirdwitch"`) {
+		t.Errorf("room should be joined with the reserved nickname: %s", sender.String())
+	}
+}
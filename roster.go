@@ -0,0 +1,186 @@
+package xmpp
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Local roster
+
+// ErrPreApprovalNotSupported is returned by PreApprove when the server does not advertise
+// subscription pre-approval.
+var ErrPreApprovalNotSupported = errors.New("subscription pre-approval not supported by the server")
+
+// RosterEventType is the kind of change a RosterManager notifies its handler about.
+type RosterEventType = uint8
+
+const (
+	// RosterItemUpdated is notified when a contact is added to the roster, or its item changes.
+	RosterItemUpdated RosterEventType = iota
+	// RosterItemRemoved is notified when a contact is removed from the roster.
+	RosterItemRemoved
+	// RosterItemPreApproved is notified, after RosterItemUpdated, when the subscription request of a
+	// contact is pre-approved.
+	RosterItemPreApproved
+)
+
+// RosterEvent is passed to the RosterManager EventHandler.
+type RosterEvent struct {
+	Type RosterEventType
+	Item stanza.RosterItem
+}
+
+// RosterEventHandler is called when the roster changes.
+type RosterEventHandler func(e RosterEvent)
+
+// RosterManager keeps a copy of the roster of the account, from the roster results received by the
+// client and the roster pushes. The roster must be requested by the application once connected. It
+// is attached to a client with WithRosterManager.
+type RosterManager struct {
+	// EventHandler, if set, is called with the changes of the roster and the subscription
+	// transitions. It must be set before the manager is attached to a client.
+	EventHandler RosterEventHandler
+	// SubscriptionPolicy tells how the subscription requests of the contacts are answered. Defaults
+	// to SubscriptionManual.
+	SubscriptionPolicy SubscriptionPolicy
+	roster             rosterCache
+
+	mu sync.Mutex
+	// Contacts that cancelled a subscription, being removed from the roster
+	cancelled map[string]bool
+}
+
+// NewRosterManager creates a roster manager with an empty roster.
+func NewRosterManager() *RosterManager {
+	return &RosterManager{}
+}
+
+// WithRosterManager attaches the manager to the client, to keep it up to date with the roster
+// results and pushes the client receives.
+func WithRosterManager(m *RosterManager) Option {
+	return func(config *Config) {
+		config.RosterManager = m
+	}
+}
+
+// Item returns the roster item of the contact, if it is in the roster.
+func (m *RosterManager) Item(jid string) (stanza.RosterItem, bool) {
+	return m.roster.item(jid)
+}
+
+// update applies the roster results and pushes, answers the subscription presences according to the
+// policy, and notifies the changes to the event handler.
+func (m *RosterManager) update(s Sender, account string, p stanza.Packet) {
+	events := m.roster.update(account, p)
+	m.reconcile(s, events)
+	events = append(events, m.handleSubscription(s, p)...)
+	if m.EventHandler == nil {
+		return
+	}
+	for _, e := range events {
+		m.EventHandler(e)
+	}
+}
+
+// rosterCache returns the roster kept by the client: the one of the RosterManager when attached, so
+// that a single copy is updated.
+func (c *Client) rosterCache() *rosterCache {
+	if m := c.config.RosterManager; m != nil {
+		return &m.roster
+	}
+	return &c.roster
+}
+
+// PreApprove approves the subscription of the contact to our presence before it requests it
+// (RFC 6121 - 3.4). It returns ErrPreApprovalNotSupported, without sending anything, when the server
+// does not support pre-approval.
+func (c *Client) PreApprove(jid string) error {
+	if c.Session == nil || !c.Session.Features.DoesPreApproval() {
+		return ErrPreApprovalNotSupported
+	}
+	return c.Send(stanza.NewPresence(stanza.Attrs{To: bareJid(jid), Type: stanza.PresenceTypeSubscribed}))
+}
+
+// rosterCache is the roster of the account, as known by the client. It is filled by the roster
+// results received by the client, and kept up to date with the roster pushes (RFC 6121 - 2.1.6).
+type rosterCache struct {
+	mu    sync.RWMutex
+	items map[string]stanza.RosterItem
+}
+
+// update applies the roster results and pushes sent by the account, and returns the changes. The
+// packet is not consumed, so the application still receives them.
+func (r *rosterCache) update(account string, p stanza.Packet) []RosterEvent {
+	iq, ok := p.(*stanza.IQ)
+	if !ok || (iq.Type != stanza.IQTypeResult && iq.Type != stanza.IQTypeSet) {
+		return nil
+	}
+	// Pushes and results are only accepted from the account itself
+	if iq.From != "" && !strings.EqualFold(iq.From, account) {
+		return nil
+	}
+	roster, ok := iq.Payload.(*stanza.RosterItems)
+	if !ok {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []RosterEvent
+	previous := r.items
+	if iq.Type == stanza.IQTypeResult || r.items == nil {
+		// A result is the whole roster
+		r.items = make(map[string]stanza.RosterItem, len(roster.Items))
+	}
+	for _, item := range roster.Items {
+		key := strings.ToLower(item.Jid)
+		old, known := previous[key]
+		if item.Subscription == stanza.SubscriptionRemove {
+			delete(r.items, key)
+			if known {
+				events = append(events, RosterEvent{Type: RosterItemRemoved, Item: old})
+			}
+			continue
+		}
+		r.items[key] = item
+		if !known || !reflect.DeepEqual(old, item) {
+			events = append(events, RosterEvent{Type: RosterItemUpdated, Item: item})
+		}
+		if item.Approved && !old.Approved {
+			events = append(events, RosterEvent{Type: RosterItemPreApproved, Item: item})
+		}
+	}
+	if iq.Type == stanza.IQTypeResult {
+		// Contacts missing from a result were removed
+		for key, item := range previous {
+			if _, ok := r.items[key]; !ok {
+				events = append(events, RosterEvent{Type: RosterItemRemoved, Item: item})
+			}
+		}
+	}
+	return events
+}
+
+func (r *rosterCache) item(jid string) (stanza.RosterItem, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	item, ok := r.items[strings.ToLower(bareJid(jid))]
+	return item, ok
+}
+
+// subscription returns the subscription state of the contact, or false if it is not in the roster.
+func (r *rosterCache) subscription(jid string) (string, bool) {
+	item, ok := r.item(jid)
+	if !ok {
+		return "", false
+	}
+	if item.Subscription == "" {
+		return stanza.SubscriptionNone, true
+	}
+	return item.Subscription, true
+}
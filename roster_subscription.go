@@ -0,0 +1,162 @@
+package xmpp
+
+import (
+	"strings"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Presence subscriptions (RFC 6121 - 3)
+
+// SubscriptionPolicy tells how a RosterManager answers the presence subscription requests of the
+// contacts.
+type SubscriptionPolicy uint8
+
+const (
+	// SubscriptionManual leaves the subscription requests to the application, which is notified with
+	// RosterSubscriptionRequested events.
+	SubscriptionManual SubscriptionPolicy = iota
+	// SubscriptionAcceptAll approves all the subscription requests, and subscribes back to the
+	// presence of the contacts. Unsubscriptions from our presence are acknowledged, and contacts
+	// cancelling our subscription to their presence are removed from the roster.
+	SubscriptionAcceptAll
+	// SubscriptionAcceptRosterSuggested is like SubscriptionAcceptAll, but only approves the requests
+	// of the contacts already in the roster, for example added by a provisioning service. Other requests
+	// are left to the application.
+	SubscriptionAcceptRosterSuggested
+)
+
+const (
+	// RosterSubscriptionRequested is notified when a contact requests a subscription to our presence.
+	RosterSubscriptionRequested RosterEventType = iota + RosterItemPreApproved + 1
+	// RosterSubscriptionApproved is notified when the subscription policy approved a request.
+	RosterSubscriptionApproved
+	// RosterSubscribing is notified when the subscription policy subscribes back to the presence of a
+	// contact, after approving its request.
+	RosterSubscribing
+	// RosterSubscriptionGranted is notified when a contact approves our subscription to its presence.
+	RosterSubscriptionGranted
+	// RosterSubscriptionCancelled is notified when a contact unsubscribes from our presence, or cancels
+	// our subscription to its presence. With the automatic policies, an unsubscription is acknowledged,
+	// and a contact cancelling our subscription is removed from the roster.
+	RosterSubscriptionCancelled
+)
+
+// autoAccepts tells if the policy approves the subscription request of the contact.
+func (m *RosterManager) autoAccepts(jid string) bool {
+	switch m.SubscriptionPolicy {
+	case SubscriptionAcceptAll:
+		return true
+	case SubscriptionAcceptRosterSuggested:
+		_, ok := m.roster.item(jid)
+		return ok
+	}
+	return false
+}
+
+// handleSubscription answers the subscription presences according to the policy, and returns the
+// transitions to notify.
+func (m *RosterManager) handleSubscription(s Sender, p stanza.Packet) []RosterEvent {
+	pres, ok := p.(stanza.Presence)
+	if !ok || pres.From == "" {
+		return nil
+	}
+	jid := bareJid(pres.From)
+	item, known := m.roster.item(jid)
+	if !known {
+		item = stanza.RosterItem{Jid: jid, Subscription: stanza.SubscriptionNone}
+	}
+
+	switch pres.Type {
+	case stanza.PresenceTypeSubscribe:
+		events := []RosterEvent{{Type: RosterSubscriptionRequested, Item: item}}
+		if !m.autoAccepts(jid) {
+			return events
+		}
+		// A new request supersedes a previous cancellation
+		m.setCancelled(jid, false)
+		if err := s.Send(stanza.NewPresence(stanza.Attrs{To: jid, Type: stanza.PresenceTypeSubscribed})); err != nil {
+			return events
+		}
+		events = append(events, RosterEvent{Type: RosterSubscriptionApproved, Item: item})
+		if item.Subscription == stanza.SubscriptionTo || item.Subscription == stanza.SubscriptionBoth ||
+			item.Ask == string(stanza.PresenceTypeSubscribe) {
+			return events
+		}
+		if err := s.Send(stanza.NewPresence(stanza.Attrs{To: jid, Type: stanza.PresenceTypeSubscribe})); err != nil {
+			return events
+		}
+		return append(events, RosterEvent{Type: RosterSubscribing, Item: item})
+
+	case stanza.PresenceTypeSubscribed:
+		return []RosterEvent{{Type: RosterSubscriptionGranted, Item: item}}
+
+	case stanza.PresenceTypeUnsubscribe, stanza.PresenceTypeUnsubscribed:
+		events := []RosterEvent{{Type: RosterSubscriptionCancelled, Item: item}}
+		if m.SubscriptionPolicy == SubscriptionManual || !known {
+			return events
+		}
+		if pres.Type == stanza.PresenceTypeUnsubscribe {
+			// The contact only stops receiving our presence: removing it would also cancel our
+			// subscription to its presence (RFC 6121 - 2.5.2)
+			_ = s.Send(stanza.NewPresence(stanza.Attrs{To: jid, Type: stanza.PresenceTypeUnsubscribed}))
+			return events
+		}
+		m.setCancelled(jid, true)
+		_ = removeRosterItem(s, jid)
+		return events
+	}
+	return nil
+}
+
+// reconcile removes again the contacts that cancelled a subscription, when a roster push shows them
+// still in the roster: our approval of their request may have been processed by the server after
+// their cancellation.
+func (m *RosterManager) reconcile(s Sender, events []RosterEvent) {
+	for _, e := range events {
+		jid := e.Item.Jid
+		if !m.isCancelled(jid) {
+			continue
+		}
+		switch e.Type {
+		case RosterItemUpdated:
+			if e.Item.Subscription != stanza.SubscriptionNone || e.Item.Ask != "" {
+				_ = removeRosterItem(s, jid)
+			}
+		case RosterItemRemoved:
+			m.setCancelled(jid, false)
+		}
+	}
+}
+
+func (m *RosterManager) setCancelled(jid string, cancelled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := strings.ToLower(jid)
+	if !cancelled {
+		delete(m.cancelled, key)
+		return
+	}
+	if m.cancelled == nil {
+		m.cancelled = make(map[string]bool)
+	}
+	m.cancelled[key] = true
+}
+
+func (m *RosterManager) isCancelled(jid string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cancelled[strings.ToLower(jid)]
+}
+
+// removeRosterItem asks the server to remove the contact from the roster, which also cancels the
+// subscriptions in both directions (RFC 6121 - 2.5.2). The result is received as a roster push.
+func removeRosterItem(s Sender, jid string) error {
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeSet})
+	if err != nil {
+		return err
+	}
+	iq.RosterItems().Items = []stanza.RosterItem{{Jid: jid, Subscription: stanza.SubscriptionRemove}}
+	return s.Send(iq)
+}
@@ -0,0 +1,237 @@
+package xmpp
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestRosterManagerEvents(t *testing.T) {
+	const account = "romeo@example.net"
+	var events []RosterEvent
+	m := NewRosterManager()
+	m.EventHandler = func(e RosterEvent) {
+		events = append(events, e)
+	}
+
+	result, _ := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeResult, Id: "roster1"})
+	result.RosterItems().
+		AddItem("juliet@example.com", stanza.SubscriptionBoth, "", "Juliet", nil).
+		AddItem("mercutio@example.com", stanza.SubscriptionFrom, "", "", nil)
+	m.update(NewSenderMock(), account, result)
+	if len(events) != 2 || events[0].Type != RosterItemUpdated || events[1].Type != RosterItemUpdated {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+
+	// Pre-approval of a contact, pushed by the server
+	events = nil
+	push, _ := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeSet, Id: "push1"})
+	push.RosterItems().Items = []stanza.RosterItem{{Jid: "benvolio@example.com", Subscription: stanza.SubscriptionNone, Approved: true}}
+	m.update(NewSenderMock(), account, push)
+	if len(events) != 2 || events[0].Type != RosterItemUpdated || events[1].Type != RosterItemPreApproved ||
+		events[1].Item.Jid != "benvolio@example.com" {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+	if item, ok := m.Item("benvolio@example.com"); !ok || !item.Approved {
+		t.Errorf("item should be pre-approved: %+v", item)
+	}
+
+	// Unchanged items are not notified, and removed items are
+	events = nil
+	result.RosterItems().AddItem("juliet@example.com", stanza.SubscriptionBoth, "", "Juliet", nil)
+	m.update(NewSenderMock(), account, result)
+	if len(events) != 2 || events[0].Type != RosterItemRemoved || events[1].Type != RosterItemRemoved {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+}
+
+func TestPreApprove(t *testing.T) {
+	client := &Client{config: &Config{}, transport: &XMPPTransport{}, Session: &Session{}}
+	if err := client.PreApprove("juliet@example.com/balcony"); !errors.Is(err, ErrPreApprovalNotSupported) {
+		t.Errorf("pre-approval should not be supported: %v", err)
+	}
+
+	done := make(chan struct{})
+	client, mock := mockClientConnection(t, func(t *testing.T, sc *ServerConn) {
+		defer close(done)
+		checkClientOpenStream(t, sc)
+		sendStreamFeatures(t, sc)
+		readAuth(t, sc.decoder)
+		sc.connection.Write([]byte("<success xmlns='urn:ietf:params:xml:ns:xmpp-sasl'/>"))
+		checkClientOpenStream(t, sc)
+		sc.connection.Write([]byte(`<stream:features>
+  <bind xmlns='urn:ietf:params:xml:ns:xmpp-bind'/>
+  <sub xmlns='urn:xmpp:features:pre-approval'/>
+</stream:features>`))
+		bind(t, sc)
+		discardPresence(t, sc)
+
+		pres, err := stanza.NextPacket(sc.decoder)
+		if err != nil {
+			t.Errorf("cannot read pre-approval: %s", err)
+			return
+		}
+		if p, ok := pres.(stanza.Presence); !ok || p.Type != stanza.PresenceTypeSubscribed || p.To != "juliet@example.com" {
+			t.Errorf("unexpected pre-approval: %#v", pres)
+		}
+	}, testClientPreApprovalPort)
+	defer mock.Stop()
+	if err := client.PreApprove("juliet@example.com/balcony"); err != nil {
+		t.Errorf("cannot pre-approve: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(defaultChannelTimeout):
+		t.Fatal("The mock server failed to finish its job !")
+	}
+}
+
+func rosterPush(items ...stanza.RosterItem) *stanza.IQ {
+	push, _ := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeSet, Id: "push"})
+	push.RosterItems().Items = items
+	return push
+}
+
+func subscriptionPresence(from string, typ stanza.StanzaType) stanza.Presence {
+	return stanza.NewPresence(stanza.Attrs{From: from, To: "romeo@example.net", Type: typ})
+}
+
+func eventTypes(events []RosterEvent) []RosterEventType {
+	var types []RosterEventType
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func TestRosterSubscriptionAcceptAll(t *testing.T) {
+	const account = "romeo@example.net"
+	var events []RosterEvent
+	m := NewRosterManager()
+	m.SubscriptionPolicy = SubscriptionAcceptAll
+	m.EventHandler = func(e RosterEvent) {
+		events = append(events, e)
+	}
+	conn := NewSenderMock()
+
+	// The request is approved, and we subscribe back
+	m.update(conn, account, subscriptionPresence("juliet@example.com/balcony", stanza.PresenceTypeSubscribe))
+	expected := []RosterEventType{RosterSubscriptionRequested, RosterSubscriptionApproved, RosterSubscribing}
+	if !reflect.DeepEqual(eventTypes(events), expected) {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+	if conn.String() != `<presence type="subscribed" to="juliet@example.com"></presence>`+
+		`<presence type="subscribe" to="juliet@example.com"></presence>` {
+		t.Errorf("unexpected answers: %s", conn.String())
+	}
+
+	// The contact approves our request
+	events = nil
+	conn.buffer.Reset()
+	m.update(conn, account, rosterPush(stanza.RosterItem{Jid: "juliet@example.com", Subscription: stanza.SubscriptionBoth}))
+	m.update(conn, account, subscriptionPresence("juliet@example.com", stanza.PresenceTypeSubscribed))
+	expected = []RosterEventType{RosterItemUpdated, RosterSubscriptionGranted}
+	if !reflect.DeepEqual(eventTypes(events), expected) || conn.String() != "" {
+		t.Fatalf("unexpected events: %+v, answers: %s", events, conn.String())
+	}
+
+	// A new request of a mutual contact is approved, without subscribing again
+	events = nil
+	m.update(conn, account, subscriptionPresence("juliet@example.com", stanza.PresenceTypeSubscribe))
+	if conn.String() != `<presence type="subscribed" to="juliet@example.com"></presence>` {
+		t.Errorf("unexpected answers: %s", conn.String())
+	}
+
+	// The contact unsubscribes from our presence: the unsubscription is acknowledged, and the contact
+	// is kept
+	events = nil
+	conn.buffer.Reset()
+	m.update(conn, account, subscriptionPresence("juliet@example.com", stanza.PresenceTypeUnsubscribe))
+	if !reflect.DeepEqual(eventTypes(events), []RosterEventType{RosterSubscriptionCancelled}) {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+	if conn.String() != `<presence type="unsubscribed" to="juliet@example.com"></presence>` {
+		t.Errorf("unsubscription should be acknowledged: %s", conn.String())
+	}
+	if _, ok := m.Item("juliet@example.com"); !ok || m.isCancelled("juliet@example.com") {
+		t.Errorf("contact should be kept")
+	}
+
+	// The contact cancels our subscription: it is removed from the roster
+	events = nil
+	conn.buffer.Reset()
+	m.update(conn, account, subscriptionPresence("juliet@example.com", stanza.PresenceTypeUnsubscribed))
+	if !reflect.DeepEqual(eventTypes(events), []RosterEventType{RosterSubscriptionCancelled}) {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+	if !strings.Contains(conn.String(), `jid="juliet@example.com" subscription="remove"`) {
+		t.Errorf("contact should be removed: %s", conn.String())
+	}
+	m.update(conn, account, rosterPush(stanza.RosterItem{Jid: "juliet@example.com", Subscription: stanza.SubscriptionRemove}))
+	if _, ok := m.Item("juliet@example.com"); ok || m.isCancelled("juliet@example.com") {
+		t.Errorf("contact should be removed")
+	}
+}
+
+// The contact cancels our subscription while our approval is in flight: the server applies the
+// approval after the cancellation, which is reconciled from the roster push.
+func TestRosterSubscriptionCancelledRace(t *testing.T) {
+	const account = "romeo@example.net"
+	m := NewRosterManager()
+	m.SubscriptionPolicy = SubscriptionAcceptAll
+	conn := NewSenderMock()
+	m.update(conn, account, rosterPush(stanza.RosterItem{Jid: "juliet@example.com", Subscription: stanza.SubscriptionNone}))
+
+	m.update(conn, account, subscriptionPresence("juliet@example.com", stanza.PresenceTypeSubscribe))
+	m.update(conn, account, subscriptionPresence("juliet@example.com", stanza.PresenceTypeUnsubscribed))
+	conn.buffer.Reset()
+
+	// Late push of the approval
+	m.update(conn, account, rosterPush(stanza.RosterItem{Jid: "juliet@example.com", Subscription: stanza.SubscriptionFrom}))
+	if !strings.Contains(conn.String(), `subscription="remove"`) {
+		t.Errorf("contact should be removed again: %s", conn.String())
+	}
+
+	// Intermediate states without subscription do not trigger a removal
+	conn.buffer.Reset()
+	m.update(conn, account, rosterPush(stanza.RosterItem{Jid: "juliet@example.com", Subscription: stanza.SubscriptionNone}))
+	if conn.String() != "" {
+		t.Errorf("unexpected answers: %s", conn.String())
+	}
+	m.update(conn, account, rosterPush(stanza.RosterItem{Jid: "juliet@example.com", Subscription: stanza.SubscriptionRemove}))
+	if m.isCancelled("juliet@example.com") {
+		t.Errorf("cancellation should be cleared once the contact is removed")
+	}
+}
+
+func TestRosterSubscriptionPolicies(t *testing.T) {
+	const account = "romeo@example.net"
+	for _, policy := range []SubscriptionPolicy{SubscriptionManual, SubscriptionAcceptRosterSuggested} {
+		var events []RosterEvent
+		m := NewRosterManager()
+		m.SubscriptionPolicy = policy
+		m.EventHandler = func(e RosterEvent) {
+			events = append(events, e)
+		}
+		conn := NewSenderMock()
+		m.update(conn, account, rosterPush(stanza.RosterItem{Jid: "juliet@example.com", Subscription: stanza.SubscriptionTo}))
+
+		// Unknown contacts are left to the application
+		events = nil
+		m.update(conn, account, subscriptionPresence("mallory@evil.lit", stanza.PresenceTypeSubscribe))
+		if !reflect.DeepEqual(eventTypes(events), []RosterEventType{RosterSubscriptionRequested}) || conn.String() != "" {
+			t.Errorf("policy %d: request should be left to the application: %+v %s", policy, events, conn.String())
+		}
+
+		// Contacts of the roster are only approved by SubscriptionAcceptRosterSuggested
+		m.update(conn, account, subscriptionPresence("juliet@example.com", stanza.PresenceTypeSubscribe))
+		approved := conn.String() == `<presence type="subscribed" to="juliet@example.com"></presence>`
+		if approved != (policy == SubscriptionAcceptRosterSuggested) {
+			t.Errorf("policy %d: unexpected answers: %s", policy, conn.String())
+		}
+	}
+}
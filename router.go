@@ -3,6 +3,7 @@ package xmpp
 import (
 	"context"
 	"encoding/xml"
+	"fmt"
 	"strings"
 	"sync"
 
@@ -26,10 +27,19 @@ TODO: Automatically reply to IQ that do not match any route, to comply to XMPP s
 
 type Router struct {
 	// Routes to be matched, in order.
-	routes []*Route
+	routes     []*Route
+	routesLock sync.RWMutex
 
 	IQResultRoutes    map[string]*IQResultRoute
 	IQResultRouteLock sync.RWMutex
+
+	// Routes awaiting the next message of a thread
+	threadRoutes     map[ThreadKey]*threadRoute
+	threadRoutesLock sync.Mutex
+
+	// Packets waited for with WaitFor
+	waiters   []*packetWaiter
+	waitersMu sync.Mutex
 }
 
 // NewRouter returns a new router instance.
@@ -49,7 +59,9 @@ func (r *Router) route(s Sender, p stanza.Packet) {
 			lastAcked := a.H
 			SendMissingStz(int(lastAcked), s, tt.Session.SMState.UnAckQueue)
 		case *Component:
-		// TODO
+			if q := tt.smState().UnAckQueue; q != nil {
+				SendMissingStz(int(a.H), s, q)
+			}
 		default:
 		}
 	}
@@ -58,7 +70,7 @@ func (r *Router) route(s Sender, p stanza.Packet) {
 		r.IQResultRouteLock.RLock()
 		route, ok := r.IQResultRoutes[iq.Id]
 		r.IQResultRouteLock.RUnlock()
-		if ok {
+		if ok && route.accepts(iq.From) {
 			r.IQResultRouteLock.Lock()
 			delete(r.IQResultRoutes, iq.Id)
 			r.IQResultRouteLock.Unlock()
@@ -66,6 +78,24 @@ func (r *Router) route(s Sender, p stanza.Packet) {
 			close(route.result)
 			return
 		}
+		if ok {
+			// Report results from unexpected senders, as they may be spoofing attempts
+			warning := fmt.Sprintf("IQ result %s rejected: unexpected sender %q", iq.Id, iq.From)
+			switch tt := s.(type) {
+			case *Client:
+				tt.warning(warning)
+			case *Component:
+				tt.warning(warning)
+			}
+		}
+	}
+
+	if msg, ok := p.(stanza.Message); ok && r.resolveThreadRoute(msg) {
+		return
+	}
+
+	if r.resolveWaiters(p) {
+		return
 	}
 
 	var match RouteMatch
@@ -122,15 +152,38 @@ func iqNotImplemented(s Sender, iq *stanza.IQ) {
 // NewRoute registers an empty routes
 func (r *Router) NewRoute() *Route {
 	route := &Route{}
+	r.routesLock.Lock()
 	r.routes = append(r.routes, route)
+	r.routesLock.Unlock()
 	return route
 }
 
+// RemoveRoute unregisters a route. It returns false if the route was not registered on the router.
+func (r *Router) RemoveRoute(route *Route) bool {
+	r.routesLock.Lock()
+	defer r.routesLock.Unlock()
+	for i, rt := range r.routes {
+		if rt == route {
+			r.routes = append(r.routes[:i:i], r.routes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
 // NewIQResultRoute register a route that will catch an IQ result stanza with
 // the given Id. The route will only match ones, after which it will automatically
 // be unregistered
 func (r *Router) NewIQResultRoute(ctx context.Context, id string) chan stanza.IQ {
+	return r.NewIQResultRouteFrom(ctx, id)
+}
+
+// NewIQResultRouteFrom registers a route like NewIQResultRoute, that only matches an IQ result sent
+// by one of the from JIDs. An empty from JID matches results without from attribute.
+// Results are accepted from any JID when no from JID is given.
+func (r *Router) NewIQResultRouteFrom(ctx context.Context, id string, from ...string) chan stanza.IQ {
 	route := NewIQResultRoute(ctx)
+	route.from = from
 	r.IQResultRouteLock.Lock()
 	r.IQResultRoutes[id] = route
 	r.IQResultRouteLock.Unlock()
@@ -148,7 +201,10 @@ func (r *Router) NewIQResultRoute(ctx context.Context, id string) chan stanza.IQ
 }
 
 func (r *Router) Match(p stanza.Packet, match *RouteMatch) bool {
-	for _, route := range r.routes {
+	r.routesLock.RLock()
+	routes := r.routes
+	r.routesLock.RUnlock()
+	for _, route := range routes {
 		if route.Match(p, match) {
 			return true
 		}
@@ -168,6 +224,12 @@ func (r *Router) HandleFunc(name string, f func(s Sender, p stanza.Packet)) *Rou
 	return r.NewRoute().Packet(name).HandlerFunc(f)
 }
 
+// HandleReplyFunc registers a new route for messages, whose handler returns the reply to send.
+// See Route.ReplyFunc().
+func (r *Router) HandleReplyFunc(f ReplyFunc) *Route {
+	return r.NewRoute().Packet("message").ReplyFunc(f)
+}
+
 // ============================================================================
 
 // TimeoutHandlerFunc is a function type for handling IQ result timeouts.
@@ -177,6 +239,8 @@ type TimeoutHandlerFunc func(err error)
 type IQResultRoute struct {
 	context context.Context
 	result  chan stanza.IQ
+	// JIDs the result is accepted from; any JID when empty
+	from []string
 }
 
 // NewIQResultRoute creates a new IQResultRoute instance
@@ -187,6 +251,19 @@ func NewIQResultRoute(ctx context.Context) *IQResultRoute {
 	}
 }
 
+// accepts tells if a result sent by from matches the route.
+func (r *IQResultRoute) accepts(from string) bool {
+	if len(r.from) == 0 {
+		return true
+	}
+	for _, jid := range r.from {
+		if strings.EqualFold(jid, from) {
+			return true
+		}
+	}
+	return false
+}
+
 // ============================================================================
 // IQ result handler
 
@@ -237,6 +314,45 @@ func (r *Route) HandlerFunc(f HandlerFunc) *Route {
 	return r.Handler(f)
 }
 
+// ReplyFunc handles a message and returns the reply to send back: a string for a text reply, or a
+// stanza.Message whose recipient, type and thread are taken from the handled message when not set.
+// Nothing is sent when it returns nil or an empty string.
+type ReplyFunc func(s Sender, msg stanza.Message) interface{}
+
+// ReplyFunc sets a handler function for the route, whose return value is sent as a reply to the
+// message. Groupchat messages are answered in the room, and errors are never answered. See
+// stanza.NewReply.
+func (r *Route) ReplyFunc(f ReplyFunc) *Route {
+	return r.HandlerFunc(func(s Sender, p stanza.Packet) {
+		msg, ok := p.(stanza.Message)
+		if !ok {
+			return
+		}
+		if reply, ok := replyTo(msg, f(s, msg)); ok {
+			_ = s.Send(reply)
+		}
+	})
+}
+
+// replyTo returns the reply to the message, from the value returned by a ReplyFunc.
+func replyTo(msg stanza.Message, v interface{}) (stanza.Message, bool) {
+	switch reply := v.(type) {
+	case string:
+		if reply == "" {
+			return stanza.Message{}, false
+		}
+		return stanza.ReplyText(msg, reply)
+	case stanza.Message:
+		return stanza.CompleteReply(msg, reply)
+	case *stanza.Message:
+		if reply == nil {
+			return stanza.Message{}, false
+		}
+		return stanza.CompleteReply(msg, *reply)
+	}
+	return stanza.Message{}, false
+}
+
 // AddMatcher adds a matcher to the route
 func (r *Route) AddMatcher(m Matcher) *Route {
 	r.matchers = append(r.matchers, m)
@@ -278,6 +278,57 @@ func TestCatchallMatcher(t *testing.T) {
 	}
 }
 
+func TestReplyFunc(t *testing.T) {
+	router := NewRouter()
+	router.HandleReplyFunc(func(s Sender, msg stanza.Message) interface{} {
+		return "Re: " + msg.Body
+	})
+
+	conn := NewSenderMock()
+	msg := stanza.NewMessage(stanza.Attrs{Type: stanza.MessageTypeGroupchat, From: "coven@chat.shakespeare.lit/thirdwitch", Id: "1"})
+	msg.Body = "Hello"
+	msg.Thread = "thread1"
+	router.route(conn, msg)
+	expected := `<message type="groupchat" to="coven@chat.shakespeare.lit"><body>Re: Hello</body><thread>thread1</thread></message>`
+	if conn.String() != expected {
+		t.Errorf("unexpected reply: %s", conn.String())
+	}
+
+	// Errors are not answered
+	conn = NewSenderMock()
+	msg.Type = stanza.MessageTypeError
+	router.route(conn, msg)
+	if conn.String() != "" {
+		t.Errorf("error should not be answered: %s", conn.String())
+	}
+}
+
+func TestReplyFuncMessage(t *testing.T) {
+	router := NewRouter()
+	router.HandleReplyFunc(func(s Sender, msg stanza.Message) interface{} {
+		if msg.Body == "" {
+			return nil
+		}
+		reply := stanza.Message{Body: "Pong"}
+		reply.Id = "2"
+		return reply
+	})
+
+	conn := NewSenderMock()
+	msg := stanza.NewMessage(stanza.Attrs{Type: stanza.MessageTypeChat, From: "romeo@montague.lit/orchard", Id: "1"})
+	router.route(conn, msg)
+	if conn.String() != "" {
+		t.Errorf("nil reply should not be sent: %s", conn.String())
+	}
+
+	msg.Body = "Ping"
+	router.route(conn, msg)
+	expected := `<message type="chat" id="2" to="romeo@montague.lit/orchard"><body>Pong</body></message>`
+	if conn.String() != expected {
+		t.Errorf("unexpected reply: %s", conn.String())
+	}
+}
+
 // ============================================================================
 // SenderMock
 
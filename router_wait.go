@@ -0,0 +1,93 @@
+package xmpp
+
+import (
+	"context"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Waiting for a packet
+
+// MatchFunc is an adapter to allow using functions as matchers, for instance with WaitFor.
+type MatchFunc func(p stanza.Packet) bool
+
+// Match calls f(p).
+func (f MatchFunc) Match(p stanza.Packet, _ *RouteMatch) bool {
+	return f(p)
+}
+
+// packetWaiter is a temporary route resolved by the first packet it matches.
+type packetWaiter struct {
+	matcher Matcher
+	consume bool
+	// result receives the matched packet. It is buffered, so that routing never blocks.
+	result chan stanza.Packet
+}
+
+// WaitFor waits for the first received packet matched by the matcher, without registering a permanent
+// route. With consume, the matched packet is not passed to the routes of the router; otherwise it is
+// routed as usual. Several waits can be in progress: a packet resolves all the waits it matches.
+// The wait is cancelled when the context is done, returning the error of the context.
+//
+// The matcher can be a MatchFunc, or a Route built with the route matchers, such as
+// new(Route).Packet("presence").
+func (r *Router) WaitFor(ctx context.Context, matcher Matcher, consume bool) (stanza.Packet, error) {
+	w := &packetWaiter{matcher: matcher, consume: consume, result: make(chan stanza.Packet, 1)}
+	r.waitersMu.Lock()
+	r.waiters = append(r.waiters, w)
+	r.waitersMu.Unlock()
+
+	select {
+	case p := <-w.result:
+		return p, nil
+	case <-ctx.Done():
+		r.removeWaiter(w)
+		// The packet may have been matched in the meantime
+		select {
+		case p := <-w.result:
+			return p, nil
+		default:
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// WaitFor waits for the first received packet matched by the matcher. See Router.WaitFor.
+func (c *Client) WaitFor(ctx context.Context, matcher Matcher, consume bool) (stanza.Packet, error) {
+	return c.router.WaitFor(ctx, matcher, consume)
+}
+
+// resolveWaiters passes the packet to the waits it matches, and removes them. It returns true when the
+// packet is consumed by one of them.
+func (r *Router) resolveWaiters(p stanza.Packet) bool {
+	r.waitersMu.Lock()
+	defer r.waitersMu.Unlock()
+	consumed := false
+	waiters := r.waiters[:0]
+	for _, w := range r.waiters {
+		var match RouteMatch
+		if !w.matcher.Match(p, &match) {
+			waiters = append(waiters, w)
+			continue
+		}
+		w.result <- p
+		consumed = consumed || w.consume
+	}
+	for i := len(waiters); i < len(r.waiters); i++ {
+		r.waiters[i] = nil
+	}
+	r.waiters = waiters
+	return consumed
+}
+
+func (r *Router) removeWaiter(w *packetWaiter) {
+	r.waitersMu.Lock()
+	defer r.waitersMu.Unlock()
+	for i, waiter := range r.waiters {
+		if waiter == w {
+			r.waiters = append(r.waiters[:i], r.waiters[i+1:]...)
+			return
+		}
+	}
+}
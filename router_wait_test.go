@@ -0,0 +1,143 @@
+package xmpp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// selfPresenceFrom matches the self-presence (status 110) sent by a MUC room.
+func selfPresenceFrom(room string) Matcher {
+	return MatchFunc(func(p stanza.Packet) bool {
+		pres, ok := p.(stanza.Presence)
+		if !ok || !strings.HasPrefix(pres.From, room+"/") {
+			return false
+		}
+		var user stanza.MucUser
+		if !pres.Get(&user) {
+			return false
+		}
+		for _, status := range user.Statuses {
+			if status.Code == stanza.MucStatusSelfPresence {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func mucPresence(from string, codes ...int) stanza.Presence {
+	pres := stanza.NewPresence(stanza.Attrs{From: from})
+	user := stanza.MucUser{}
+	for _, code := range codes {
+		user.Statuses = append(user.Statuses, stanza.MucStatus{Code: code})
+	}
+	pres.Extensions = append(pres.Extensions, &user)
+	return pres
+}
+
+type waitResult struct {
+	packet stanza.Packet
+	err    error
+}
+
+func waitAsync(router *Router, ctx context.Context, m Matcher, consume bool) chan waitResult {
+	res := make(chan waitResult, 1)
+	go func() {
+		p, err := router.WaitFor(ctx, m, consume)
+		res <- waitResult{p, err}
+	}()
+	return res
+}
+
+// waitersCount returns the number of waits in progress, once it reaches n or after a timeout.
+func waitersCount(router *Router, n int) int {
+	deadline := time.Now().Add(defaultChannelTimeout)
+	for {
+		router.waitersMu.Lock()
+		count := len(router.waiters)
+		router.waitersMu.Unlock()
+		if count == n || time.Now().After(deadline) {
+			return count
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWaitForConcurrentWaits(t *testing.T) {
+	router := NewRouter()
+	routed := make(chan string, 10)
+	router.HandleFunc("presence", func(_ Sender, p stanza.Packet) {
+		routed <- p.(stanza.Presence).From
+	})
+	conn := NewSenderMock()
+
+	ctx := context.Background()
+	coven := waitAsync(router, ctx, selfPresenceFrom("coven@chat.shakespeare.lit"), false)
+	chamber := waitAsync(router, ctx, selfPresenceFrom("chamber@chat.shakespeare.lit"), true)
+	if n := waitersCount(router, 2); n != 2 {
+		t.Fatalf("expected 2 waits, got %d", n)
+	}
+
+	// Presences of other occupants do not resolve the waits
+	router.route(conn, mucPresence("coven@chat.shakespeare.lit/firstwitch"))
+	router.route(conn, mucPresence("chamber@chat.shakespeare.lit/thirdwitch", stanza.MucStatusSelfPresence))
+	router.route(conn, mucPresence("coven@chat.shakespeare.lit/thirdwitch", stanza.MucStatusSelfPresence))
+
+	for _, res := range []chan waitResult{coven, chamber} {
+		select {
+		case r := <-res:
+			if r.err != nil {
+				t.Errorf("unexpected error: %v", r.err)
+			}
+		case <-time.After(defaultChannelTimeout):
+			t.Fatal("wait was not resolved")
+		}
+	}
+	if n := waitersCount(router, 0); n != 0 {
+		t.Errorf("resolved waits should be removed, %d left", n)
+	}
+
+	// The packet matched by the consuming wait is not routed
+	close(routed)
+	var froms []string
+	for from := range routed {
+		froms = append(froms, from)
+	}
+	expected := []string{"coven@chat.shakespeare.lit/firstwitch", "coven@chat.shakespeare.lit/thirdwitch"}
+	if strings.Join(froms, ",") != strings.Join(expected, ",") {
+		t.Errorf("unexpected routed presences: %v", froms)
+	}
+}
+
+func TestWaitForCancel(t *testing.T) {
+	router := NewRouter()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	p, err := router.WaitFor(ctx, selfPresenceFrom("coven@chat.shakespeare.lit"), true)
+	if err != context.DeadlineExceeded || p != nil {
+		t.Errorf("expected the wait to time out, got %v, %v", p, err)
+	}
+	if n := waitersCount(router, 0); n != 0 {
+		t.Errorf("cancelled waits should be removed, %d left", n)
+	}
+
+	// Routes built with the router matchers can be waited for
+	res := waitAsync(router, context.Background(), (&Route{}).Packet("message").StanzaType("chat"), false)
+	if n := waitersCount(router, 1); n != 1 {
+		t.Fatalf("expected a wait, got %d", n)
+	}
+	router.route(NewSenderMock(), stanza.NewMessage(stanza.Attrs{Type: stanza.MessageTypeChat, Id: "m1"}))
+	select {
+	case r := <-res:
+		if msg, ok := r.packet.(stanza.Message); !ok || msg.Id != "m1" {
+			t.Errorf("unexpected packet: %+v", r.packet)
+		}
+	case <-time.After(defaultChannelTimeout):
+		t.Fatal("wait was not resolved")
+	}
+}
@@ -0,0 +1,219 @@
+package xmpp
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Extensible SASL Profile (XEP-0388) with Bind 2 (XEP-0386)
+
+// WithClientTag sets the tag identifying the client software, sent when binding the resource with
+// Bind 2. The server generates the resource from it.
+func WithClientTag(tag string) Option {
+	return func(config *Config) {
+		config.ClientTag = tag
+	}
+}
+
+// WithCarbons enables Message Carbons (XEP-0280) when the session is established, inline with
+// Bind 2 when supported by the server.
+func WithCarbons() Option {
+	return func(config *Config) {
+		config.Carbons = true
+	}
+}
+
+// WithoutSASL2 makes the client authenticate and bind its resource with the classic negotiation,
+// even if the server supports SASL2.
+func WithoutSASL2() Option {
+	return func(config *Config) {
+		config.DisableSASL2 = true
+	}
+}
+
+// useSASL2 tells if the client authenticates with SASL2. It is only used when the resource can be
+// bound inline, as the stream is not restarted, and with one of the mechanisms of the credential.
+func useSASL2(o *Config, f stanza.StreamFeatures) bool {
+	if o.DisableSASL2 || !f.DoesBind2() {
+		return false
+	}
+	return sasl2Mechanism(o.Credential, f) != ""
+}
+
+// sasl2Mechanism returns the first mechanism of the credential supported with SASL2.
+func sasl2Mechanism(credential Credential, f stanza.StreamFeatures) string {
+	for _, mech := range credential.mechanisms {
+		if isSupportedMech(mech, f.SASL2.Mechanisms) {
+			return mech
+		}
+	}
+	return ""
+}
+
+// authSASL2 authenticates with SASL2, and binds the resource or resumes the stream management session
+// inline, with the features the server supports inline.
+func (s *Session) authSASL2(o *Config) {
+	if s.err != nil {
+		return
+	}
+
+	s.OfferedMechanisms = s.Features.SASL2.Mechanisms
+	req := stanza.SASL2Authenticate{Bind: &stanza.Bind2Request{Tag: o.ClientTag}}
+	if o.Carbons && s.Features.Bind2Inline(stanza.NSCarbons) {
+		req.Bind.Carbons = &stanza.CarbonsEnable{}
+	}
+	if o.StreamManagementEnable && s.Features.Bind2Inline(stanza.NSStreamManagement) {
+		req.Bind.SM = &stanza.SMEnable{Resume: &o.streamManagementResume}
+	}
+	if s.SMState.Id != "" && s.Features.DoesSASL2Resume() {
+		req.Resume = &stanza.SMResume{PrevId: s.SMState.Id, H: &s.SMState.Inbound}
+	}
+
+	var success stanza.SASL2Success
+	s.Mechanism, success, s.err = authSASL2(s.transport, s.transport.GetDecoder(), s.Features, o.parsedJid.Node, o.Credential, req)
+	if s.err != nil {
+		return
+	}
+
+	if success.Resumed != nil {
+		if success.Resumed.PrevId != s.SMState.Id {
+			s.err = errors.New("session resumption: mismatched id")
+			s.SMState = SMState{}
+			return
+		}
+		if success.AuthorizationIdentifier != "" {
+			s.BindJid = success.AuthorizationIdentifier
+		}
+		s.resendUnacked(success.Resumed.H)
+		s.Resumed = true
+		return
+	}
+	if req.Resume != nil {
+		s.SMState = SMState{}
+	}
+
+	if success.Bound == nil {
+		s.err = errors.New("bind 2 result missing")
+		return
+	}
+	s.BindJid = success.AuthorizationIdentifier
+	if req.Bind.Carbons != nil {
+		s.EnabledFeatures = append(s.EnabledFeatures, stanza.NSCarbons)
+	}
+	if req.Bind.SM != nil {
+		if success.Bound.SMEnabled != nil {
+			s.smEnableResult(o, *success.Bound.SMEnabled, stanza.NewUnAckQueue())
+		} else if success.Bound.SMFailed != nil {
+			s.smEnableResult(o, *success.Bound.SMFailed, stanza.NewUnAckQueue())
+		}
+	}
+}
+
+// authSASL2 runs the SASL2 exchange on the socket, with the first mechanism of the credential
+// supported by the server. It returns the mechanism used and the success nonza, with the result of
+// the inline requests.
+func authSASL2(socket io.ReadWriter, decoder *xml.Decoder, f stanza.StreamFeatures, user string, credential Credential,
+	req stanza.SASL2Authenticate) (string, stanza.SASL2Success, error) {
+	var success stanza.SASL2Success
+	var scram *scramClient
+	mech := sasl2Mechanism(credential, f)
+	switch mech {
+	case "PLAIN", "X-OAUTH2":
+		req.InitialResponse = encodeSASL("\x00" + user + "\x00" + credential.secret)
+	case "SCRAM-SHA-1", "SCRAM-SHA-256", "SCRAM-SHA-512":
+		// Downgrade protection checks the mechanisms offered with SASL2
+		features := f
+		features.Mechanisms.Mechanism = f.SASL2.Mechanisms
+		var err error
+		if scram, err = newScramClient(mech, user, credential.secret, features); err != nil {
+			return mech, success, err
+		}
+		req.InitialResponse = encodeSASL(scram.clientFirst())
+	default:
+		err := fmt.Errorf("no matching authentication (%v) supported by server: %v", credential.mechanisms, f.SASL2.Mechanisms)
+		return "", success, NewConnError(err, true)
+	}
+	req.Mechanism = mech
+	if err := writeSASL(socket, req); err != nil {
+		return mech, success, err
+	}
+
+	answered := false
+	for {
+		val, err := stanza.NextPacket(decoder)
+		if err != nil {
+			return mech, success, err
+		}
+		switch v := val.(type) {
+		case stanza.SASL2Challenge:
+			// Only SCRAM sends a challenge, once
+			if scram == nil || answered {
+				return mech, success, NewConnError(errors.New("unexpected SASL2 challenge"), true)
+			}
+			serverFirst, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v.Value))
+			if err != nil {
+				return mech, success, errors.New("invalid SASL challenge: " + err.Error())
+			}
+			clientFinal, err := scram.clientFinal(string(serverFirst))
+			if err != nil {
+				return mech, success, NewConnError(err, true)
+			}
+			if err = writeSASL(socket, stanza.SASL2Response{Value: encodeSASL(clientFinal)}); err != nil {
+				return mech, success, err
+			}
+			answered = true
+		case stanza.SASL2Success:
+			if scram != nil {
+				if err = checkServerFinal(scram, v.AdditionalData); err != nil {
+					return mech, success, err
+				}
+			}
+			return mech, v, nil
+		case stanza.SASL2Failure:
+			return mech, success, NewConnError(errors.New("auth failure: "+v.Condition.Local), true)
+		case stanza.SASL2Continue:
+			err := fmt.Errorf("unsupported SASL2 tasks: %v", v.Tasks)
+			return mech, success, NewConnError(err, true)
+		default:
+			return mech, success, errors.New("expected SASL2 success or failure, got " + v.Name())
+		}
+	}
+}
+
+// enableCarbons enables Message Carbons with an IQ, when it could not be enabled inline. Carbons are
+// optional: an error answer does not fail the session.
+func (s *Session) enableCarbons() {
+	if s.err != nil {
+		return
+	}
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeSet, Id: s.PacketId()})
+	if err != nil {
+		s.err = err
+		return
+	}
+	iq.Payload = &stanza.CarbonsEnable{}
+	data, err := xml.Marshal(iq)
+	if err != nil {
+		s.err = err
+		return
+	}
+	if _, s.err = s.transport.Write(data); s.err != nil {
+		return
+	}
+
+	var result stanza.IQ
+	if s.err = s.transport.GetDecoder().Decode(&result); s.err != nil {
+		s.err = errors.New("expecting iq result after enabling carbons: " + s.err.Error())
+		return
+	}
+	if result.Type == stanza.IQTypeResult {
+		s.EnabledFeatures = append(s.EnabledFeatures, stanza.NSCarbons)
+	}
+}
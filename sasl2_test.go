@@ -0,0 +1,156 @@
+package xmpp
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+const sasl2Features = `<stream:features>
+  <authentication xmlns='urn:xmpp:sasl:2'>
+    <mechanism>PLAIN</mechanism>
+    <inline>
+      <bind xmlns='urn:xmpp:bind:0'>
+        <inline>
+          <feature var='urn:xmpp:carbons:2'/>
+          <feature var='urn:xmpp:sm:3'/>
+        </inline>
+      </bind>
+      <sm xmlns='urn:xmpp:sm:3'/>
+    </inline>
+  </authentication>
+</stream:features>`
+
+// readSASL2Authenticate reads the authenticate request of the client.
+func readSASL2Authenticate(t *testing.T, sc *ServerConn) stanza.SASL2Authenticate {
+	var auth stanza.SASL2Authenticate
+	se, err := stanza.NextStart(sc.decoder)
+	if err != nil {
+		t.Errorf("cannot read authenticate: %s", err)
+		return auth
+	}
+	if err = sc.decoder.DecodeElement(&auth, &se); err != nil {
+		t.Errorf("cannot decode authenticate: %s", err)
+	}
+	return auth
+}
+
+func sasl2Client(t *testing.T, events chan Event) *Client {
+	config := Config{
+		TransportConfiguration: TransportConfiguration{
+			Address: fmt.Sprintf("%s:%d", testClientDomain, testClientSASL2Port),
+		},
+		Jid:                    "test@localhost",
+		Credential:             Password("test"),
+		Insecure:               true,
+		StreamManagementEnable: true,
+		streamManagementResume: true,
+	}
+	client, err := NewClient(&config, NewRouter(), clientDefaultErrorHandler, WithClientTag("Test Client"), WithCarbons())
+	if err != nil {
+		t.Fatalf("connect create XMPP client: %s", err)
+	}
+	client.SetHandler(func(e Event) error {
+		if e.State.getState() == StateSessionEstablished {
+			events <- e
+		}
+		return nil
+	})
+	return client
+}
+
+func TestClientSASL2Bind(t *testing.T) {
+	done := make(chan struct{}, 1)
+	h := func(t *testing.T, sc *ServerConn) {
+		checkClientOpenStream(t, sc)
+		fmt.Fprintln(sc.connection, sasl2Features)
+		auth := readSASL2Authenticate(t, sc)
+		if auth.Mechanism != "PLAIN" || auth.Bind == nil || auth.Bind.Tag != "Test Client" || auth.Resume != nil {
+			t.Errorf("unexpected authenticate request: %+v", auth)
+			return
+		}
+		if auth.Bind.Carbons == nil || auth.Bind.SM == nil || auth.Bind.SM.Resume == nil || !*auth.Bind.SM.Resume {
+			t.Errorf("carbons and stream management should be enabled inline: %+v", auth.Bind)
+		}
+		if response, _ := base64.StdEncoding.DecodeString(auth.InitialResponse); string(response) != "\x00test\x00test" {
+			t.Errorf("unexpected initial response: %q", response)
+		}
+		// The stream is not restarted
+		fmt.Fprint(sc.connection, `<success xmlns='urn:xmpp:sasl:2'>
+  <authorization-identifier>test@localhost/Test Client.abcd</authorization-identifier>
+  <bound xmlns='urn:xmpp:bind:0'><enabled xmlns='urn:xmpp:sm:3' id='sm1' resume='true'/></bound>
+</success>`)
+		discardPresence(t, sc)
+		done <- struct{}{}
+	}
+	mock := ServerMock{}
+	mock.Start(t, fmt.Sprintf("%s:%d", testClientDomain, testClientSASL2Port), h)
+	defer mock.Stop()
+
+	events := make(chan Event, 1)
+	client := sasl2Client(t, events)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("XMPP connection failed: %s", err)
+	}
+	if client.Session.BindJid != "test@localhost/Test Client.abcd" || !client.Session.SASL2 {
+		t.Errorf("unexpected session: %+v", client.Session)
+	}
+	select {
+	case e := <-events:
+		if e.Resumed || e.SMState.Id != "sm1" || len(e.EnabledFeatures) != 2 {
+			t.Errorf("unexpected session event: %+v", e)
+		}
+	case <-time.After(defaultChannelTimeout):
+		t.Fatal("session established event not received")
+	}
+	select {
+	case <-done:
+	case <-time.After(defaultChannelTimeout):
+		t.Fatal("The mock server failed to finish its job !")
+	}
+}
+
+func TestClientSASL2Resume(t *testing.T) {
+	done := make(chan struct{}, 1)
+	h := func(t *testing.T, sc *ServerConn) {
+		checkClientOpenStream(t, sc)
+		fmt.Fprintln(sc.connection, sasl2Features)
+		auth := readSASL2Authenticate(t, sc)
+		if auth.Resume == nil || auth.Resume.PrevId != "sm1" || auth.Resume.H == nil || *auth.Resume.H != 3 {
+			t.Errorf("resumption should be requested inline: %+v", auth.Resume)
+			return
+		}
+		fmt.Fprint(sc.connection, `<success xmlns='urn:xmpp:sasl:2'>
+  <authorization-identifier>test@localhost/Test Client.abcd</authorization-identifier>
+  <resumed xmlns='urn:xmpp:sm:3' h='0' previd='sm1'/>
+</success>`)
+		discardPresence(t, sc)
+		done <- struct{}{}
+	}
+	mock := ServerMock{}
+	mock.Start(t, fmt.Sprintf("%s:%d", testClientDomain, testClientSASL2Port), h)
+	defer mock.Stop()
+
+	events := make(chan Event, 1)
+	client := sasl2Client(t, events)
+	client.Session = &Session{transport: client.transport, SMState: SMState{Id: "sm1", Inbound: 3}}
+	if err := client.Connect(); err != nil {
+		t.Fatalf("XMPP connection failed: %s", err)
+	}
+	select {
+	case e := <-events:
+		if !e.Resumed || e.SMState.Id != "sm1" {
+			t.Errorf("unexpected session event: %+v", e)
+		}
+	case <-time.After(defaultChannelTimeout):
+		t.Fatal("session established event not received")
+	}
+	select {
+	case <-done:
+	case <-time.After(defaultChannelTimeout):
+		t.Fatal("The mock server failed to finish its job !")
+	}
+}
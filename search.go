@@ -0,0 +1,165 @@
+package xmpp
+
+import (
+	"context"
+	"errors"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Jabber Search (XEP-0055)
+
+// Search fields, as named by the fixed search fields and by the data form fields of the services
+// using extended search.
+const (
+	searchFieldJID   = "jid"
+	searchFieldFirst = "first"
+	searchFieldLast  = "last"
+	searchFieldNick  = "nick"
+	searchFieldEmail = "email"
+)
+
+// SearchQuery holds the search criteria. Empty criteria are ignored.
+type SearchQuery struct {
+	First string
+	Last  string
+	Nick  string
+	Email string
+}
+
+// NewSearchQueryByName creates a query searching users by first and last name.
+func NewSearchQueryByName(first, last string) SearchQuery {
+	return SearchQuery{First: first, Last: last}
+}
+
+// NewSearchQueryByEmail creates a query searching users by email address.
+func NewSearchQueryByEmail(email string) SearchQuery {
+	return SearchQuery{Email: email}
+}
+
+// SearchResult is a user found by SearchUsers. JID is always set, the other fields only if they
+// are returned by the service.
+type SearchResult struct {
+	JID   string
+	First string
+	Last  string
+	Nick  string
+	Email string
+}
+
+// SearchUsers searches the users directory of the service. The search fields are requested first,
+// to detect if the service uses the fixed search fields or a data form.
+func (c *Client) SearchUsers(ctx context.Context, service string, query SearchQuery) ([]SearchResult, error) {
+	return searchUsers(ctx, c, service, query)
+}
+
+func searchUsers(ctx context.Context, s Sender, service string, query SearchQuery) ([]SearchResult, error) {
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, To: service})
+	if err != nil {
+		return nil, err
+	}
+	iq.Search()
+	fields, err := sendSearchIQ(ctx, s, iq)
+	if err != nil {
+		return nil, err
+	}
+
+	if iq, err = stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeSet, To: service}); err != nil {
+		return nil, err
+	}
+	search := iq.Search()
+	if fields.Form != nil {
+		form, err := searchForm(*fields.Form, query)
+		if err != nil {
+			return nil, err
+		}
+		search.Form = &form
+	} else {
+		search.First = query.First
+		search.Last = query.Last
+		search.Nick = query.Nick
+		search.Email = query.Email
+	}
+
+	res, err := sendSearchIQ(ctx, s, iq)
+	if err != nil {
+		return nil, err
+	}
+	if res.Form != nil {
+		return formSearchResults(*res.Form), nil
+	}
+	results := make([]SearchResult, 0, len(res.Items))
+	for _, item := range res.Items {
+		if item.JID == "" {
+			continue
+		}
+		results = append(results, SearchResult{JID: item.JID, First: item.First, Last: item.Last, Nick: item.Nick, Email: item.Email})
+	}
+	return results, nil
+}
+
+func sendSearchIQ(ctx context.Context, s Sender, iq *stanza.IQ) (*stanza.Search, error) {
+	result, err := sendIQSync(ctx, s, iq)
+	if err != nil {
+		return nil, err
+	}
+	if err = iqError(result); err != nil {
+		return nil, err
+	}
+	search, ok := result.Payload.(*stanza.Search)
+	if !ok {
+		return nil, errors.New("invalid search response")
+	}
+	return search, nil
+}
+
+// searchForm fills the search form returned by the service with the query criteria.
+func searchForm(form stanza.Form, query SearchQuery) (stanza.Form, error) {
+	var fields []*stanza.Field
+	if formType := form.FormType(); formType != "" {
+		fields = append(fields, &stanza.Field{Var: "FORM_TYPE", Type: stanza.FieldTypeHidden, ValuesList: []string{formType}})
+	}
+	criteria := []struct{ name, value string }{
+		{searchFieldFirst, query.First},
+		{searchFieldLast, query.Last},
+		{searchFieldNick, query.Nick},
+		{searchFieldEmail, query.Email},
+	}
+	for _, c := range criteria {
+		if c.value == "" {
+			continue
+		}
+		if form.Field(c.name) == nil {
+			return stanza.Form{}, errors.New("search service does not support searching by " + c.name)
+		}
+		fields = append(fields, &stanza.Field{Var: c.name, ValuesList: []string{c.value}})
+	}
+	return *stanza.NewForm(fields, stanza.FormTypeSubmit), nil
+}
+
+// formSearchResults decodes the items of a search result form. Items without JID are ignored.
+func formSearchResults(form stanza.Form) []SearchResult {
+	results := make([]SearchResult, 0, len(form.Items))
+	for _, item := range form.Items {
+		var r SearchResult
+		for _, f := range item.Fields {
+			switch f.Var {
+			case searchFieldJID:
+				r.JID = f.Value()
+			case searchFieldFirst:
+				r.First = f.Value()
+			case searchFieldLast:
+				r.Last = f.Value()
+			case searchFieldNick:
+				r.Nick = f.Value()
+			case searchFieldEmail:
+				r.Email = f.Value()
+			}
+		}
+		if r.JID != "" {
+			results = append(results, r)
+		}
+	}
+	return results
+}
@@ -0,0 +1,137 @@
+package xmpp
+
+import (
+	"context"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// Service using the fixed search fields, from XEP-0055 - 2. Basic Protocol
+var legacySearchScript = []string{
+	`<iq type='result' from='characters.shakespeare.lit' to='romeo@montague.net/home'>
+  <query xmlns='jabber:iq:search'>
+    <instructions>Fill in one or more fields to search for any matching Jabber users.</instructions>
+    <first/>
+    <last/>
+    <nick/>
+    <email/>
+  </query>
+</iq>`,
+	`<iq type='result' from='characters.shakespeare.lit' to='romeo@montague.net/home'>
+  <query xmlns='jabber:iq:search'>
+    <item jid='juliet@capulet.com'>
+      <first>Juliet</first>
+      <last>Capulet</last>
+      <nick>JuliC</nick>
+      <email>juliet@shakespeare.lit</email>
+    </item>
+    <item jid='tybalt@shakespeare.lit'>
+      <first>Tybalt</first>
+      <last>Capulet</last>
+      <nick>ty</nick>
+      <email>tybalt@shakespeare.lit</email>
+    </item>
+  </query>
+</iq>`,
+}
+
+// Service using a data form, from XEP-0055 - 3. Extensibility
+var formSearchScript = []string{
+	`<iq type='result' from='characters.shakespeare.lit' to='juliet@capulet.com/balcony'>
+  <query xmlns='jabber:iq:search'>
+    <instructions>Use the enclosed form to search.</instructions>
+    <x xmlns='jabber:x:data' type='form'>
+      <title>User Directory Search</title>
+      <field type='hidden' var='FORM_TYPE'><value>jabber:iq:search</value></field>
+      <field type='text-single' label='Given Name' var='first'/>
+      <field type='text-single' label='Family Name' var='last'/>
+      <field type='list-single' label='Gender' var='x-gender'>
+        <option label='Male'><value>male</value></option>
+        <option label='Female'><value>female</value></option>
+      </field>
+    </x>
+  </query>
+</iq>`,
+	`<iq type='result' from='characters.shakespeare.lit' to='juliet@capulet.com/balcony'>
+  <query xmlns='jabber:iq:search'>
+    <x xmlns='jabber:x:data' type='result'>
+      <field type='hidden' var='FORM_TYPE'><value>jabber:iq:search</value></field>
+      <reported>
+        <field var='first' label='Given Name' type='text-single'/>
+        <field var='last' label='Family Name' type='text-single'/>
+        <field var='jid' label='Jabber ID' type='jid-single'/>
+      </reported>
+      <item>
+        <field var='first'><value>Benvolio</value></field>
+        <field var='last'><value>Montague</value></field>
+        <field var='jid'><value>benvolio@montague.net</value></field>
+      </item>
+      <item>
+        <field var='first'><value>Romeo</value></field>
+        <field var='last'><value>Montague</value></field>
+        <field var='jid'><value>romeo@montague.net</value></field>
+      </item>
+      <item>
+        <field var='first'><value>Nobody</value></field>
+      </item>
+    </x>
+  </query>
+</iq>`,
+}
+
+func TestSearchUsersLegacy(t *testing.T) {
+	sender := &scriptedIQSender{t: t, responses: legacySearchScript}
+	results, err := searchUsers(context.Background(), sender, "characters.shakespeare.lit", NewSearchQueryByName("", "Capulet"))
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %+v", results)
+	}
+	if results[0] != (SearchResult{JID: "juliet@capulet.com", First: "Juliet", Last: "Capulet", Nick: "JuliC", Email: "juliet@shakespeare.lit"}) {
+		t.Errorf("unexpected result: %+v", results[0])
+	}
+
+	if len(sender.requests) != 2 || sender.requests[0].Type != stanza.IQTypeGet {
+		t.Fatalf("search fields should be requested first")
+	}
+	search, ok := sender.requests[1].Payload.(*stanza.Search)
+	if !ok || search.Last != "Capulet" || search.First != "" || search.Form != nil {
+		t.Errorf("unexpected search request: %+v", sender.requests[1].Payload)
+	}
+}
+
+func TestSearchUsersForm(t *testing.T) {
+	sender := &scriptedIQSender{t: t, responses: formSearchScript}
+	results, err := searchUsers(context.Background(), sender, "characters.shakespeare.lit", NewSearchQueryByName("", "Montague"))
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	// The item without JID is ignored
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %+v", results)
+	}
+	if results[1] != (SearchResult{JID: "romeo@montague.net", First: "Romeo", Last: "Montague"}) {
+		t.Errorf("unexpected result: %+v", results[1])
+	}
+
+	search, ok := sender.requests[1].Payload.(*stanza.Search)
+	if !ok || search.Form == nil || search.Form.Type != stanza.FormTypeSubmit {
+		t.Fatalf("search should be submitted as a data form: %+v", sender.requests[1].Payload)
+	}
+	if search.Form.FormType() != stanza.NSSearch {
+		t.Errorf("submitted form should keep the FORM_TYPE")
+	}
+	if f := search.Form.Field("last"); f == nil || f.Value() != "Montague" || search.Form.Field("first") != nil {
+		t.Errorf("unexpected submitted fields: %+v", search.Form.Fields)
+	}
+}
+
+func TestSearchUsersFormUnsupportedField(t *testing.T) {
+	sender := &scriptedIQSender{t: t, responses: formSearchScript[:1]}
+	_, err := searchUsers(context.Background(), sender, "characters.shakespeare.lit", NewSearchQueryByEmail("romeo@montague.net"))
+	if err == nil {
+		t.Errorf("search by email should fail when the form has no email field")
+	}
+}
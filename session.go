@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"gosrc.io/xmpp/stanza"
+	"io"
 	"strconv"
+	"time"
 )
 
 type Session struct {
@@ -17,8 +19,21 @@ type Session struct {
 	TlsEnabled   bool
 	lastPacketId int
 
+	// SASL mechanisms offered by the server, and mechanism used to authenticate
+	OfferedMechanisms []string
+	Mechanism         string
+	// SASL2 tells if the client authenticated with the Extensible SASL Profile (XEP-0388)
+	SASL2 bool
+	// Resumed tells if the stream management session was resumed, instead of binding a resource
+	Resumed bool
+	// EnabledFeatures are the namespaces of the features enabled during the negotiation, such as
+	// stanza.NSStreamManagement or stanza.NSCarbons
+	EnabledFeatures []string
+
 	// read / write
 	transport Transport
+	// Events of the connection attempt
+	trace dialTrace
 
 	// error management
 	err error
@@ -30,9 +45,13 @@ func NewSession(c *Client, state SMState) (*Session, error) {
 		s = new(Session)
 		s.transport = c.transport
 		s.SMState = state
+		s.trace = c.traceDial
 		s.init()
 	} else {
 		s = c.Session
+		// The transport is replaced when the client fails over to another endpoint
+		s.transport = c.transport
+		s.trace = c.traceDial
 		// We keep information about the previously set session, like the session ID, but we read server provided
 		// info again in case it changed between session break and resume, such as features.
 		s.init()
@@ -41,9 +60,16 @@ func NewSession(c *Client, state SMState) (*Session, error) {
 	if s.err != nil {
 		return nil, NewConnError(s.err, true)
 	}
+	s.SASL2 = false
+	s.Resumed = false
+	s.EnabledFeatures = nil
 
 	if !c.transport.IsSecure() {
+		start := time.Now()
 		s.startTlsIfSupported(c.config)
+		if s.TlsEnabled || s.err != nil {
+			c.traceDial(DialStageTLS, transportTLSDetail(c.transport), start, s.err)
+		}
 	}
 
 	if !c.transport.IsSecure() && !c.config.Insecure {
@@ -55,40 +81,80 @@ func NewSession(c *Client, state SMState) (*Session, error) {
 		s.reset()
 	}
 
-	// auth
-	s.auth(c.config)
-	if s.err != nil {
-		return s, s.err
+	// auth, with SASL2 when the resource can be bound inline. The stream is not restarted after SASL2.
+	start := time.Now()
+	s.SASL2 = useSASL2(c.config, s.Features)
+	if s.SASL2 {
+		s.authSASL2(c.config)
+	} else {
+		s.auth(c.config)
 	}
-	s.reset()
+	c.traceDial(DialStageSASL, s.Mechanism, start, s.err)
 	if s.err != nil {
 		return s, s.err
 	}
-
-	// attempt resumption
-	if s.resume(c.config) {
+	if warning := pinMechanism(c.config.MechanismStore, c.config.parsedJid.Domain, s.Mechanism); warning != "" {
+		c.EventManager.warning(warning)
+	}
+	if !s.SASL2 {
+		s.reset()
+		if s.err != nil {
+			return s, s.err
+		}
+		// attempt resumption
+		start = time.Now()
+		s.Resumed = s.resume(c.config)
+	}
+	if s.Resumed {
+		c.traceDial(DialStageSM, "resumed "+s.SMState.Id, start, s.err)
 		return s, s.err
 	}
 
 	// otherwise, bind resource and 'start' XMPP session
-	s.bind(c.config)
-	if s.err != nil {
-		return s, s.err
+	if s.SASL2 {
+		c.traceDial(DialStageBind, s.BindJid, start, s.err)
+	} else {
+		start = time.Now()
+		s.bind(c.config)
+		c.traceDial(DialStageBind, s.BindJid, start, s.err)
+		if s.err != nil {
+			return s, s.err
+		}
+		s.rfc3921Session()
 	}
-	s.rfc3921Session()
 	if s.err != nil {
 		return s, s.err
 	}
 
-	// Enable stream management if supported
-	s.EnableStreamManagement(c.config)
-	if s.err != nil {
-		return s, s.err
+	// Enable the features not enabled inline
+	if c.config.StreamManagementEnable && s.supportsStreamManagement() && !s.featureEnabled(stanza.NSStreamManagement) {
+		start = time.Now()
+		s.EnableStreamManagement(c.config)
+		c.traceDial(DialStageSM, smDetail(s.SMState), start, s.err)
+	}
+	if c.config.Carbons && !s.featureEnabled(stanza.NSCarbons) {
+		s.enableCarbons()
 	}
 
 	return s, s.err
 }
 
+// supportsStreamManagement tells if the server supports stream management. After SASL2, the stream
+// features are not sent again, and support is deduced from the inline features.
+func (s *Session) supportsStreamManagement() bool {
+	return s.Features.DoesStreamManagement() || s.Features.DoesSASL2Resume() || s.Features.Bind2Inline(stanza.NSStreamManagement)
+}
+
+// featureEnabled tells if the feature was enabled during the negotiation.
+func (s *Session) featureEnabled(namespace string) bool {
+	for _, ns := range s.EnabledFeatures {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *Session) PacketId() string {
 	s.lastPacketId++
 	return fmt.Sprintf("%x", s.lastPacketId)
@@ -109,7 +175,12 @@ func (s *Session) reset() {
 
 func (s *Session) extractStreamFeatures() (f stanza.StreamFeatures) {
 	// extract stream features
-	if s.err = s.transport.GetDecoder().Decode(&f); s.err != nil {
+	start := time.Now()
+	s.err = s.transport.GetDecoder().Decode(&f)
+	if s.trace != nil {
+		s.trace(DialStageFeatures, featuresDetail(f), start, s.err)
+	}
+	if s.err != nil {
 		s.err = errors.New("stream open decode features: " + s.err.Error())
 	}
 	return
@@ -155,7 +226,8 @@ func (s *Session) auth(o *Config) {
 		return
 	}
 
-	s.err = authSASL(s.transport, s.transport.GetDecoder(), s.Features, o.parsedJid.Node, o.Credential)
+	s.OfferedMechanisms = s.Features.Mechanisms.Mechanism
+	s.Mechanism, s.err = authSASL(s.transport, s.transport.GetDecoder(), s.Features, o.parsedJid.Node, o.Credential)
 }
 
 // Attempt to resume session using stream management
@@ -187,6 +259,7 @@ func (s *Session) resume(o *Config) bool {
 				s.SMState = SMState{}
 				return false
 			}
+			s.resendUnacked(p.H)
 			return true
 		case stanza.SMFailed:
 		default:
@@ -197,6 +270,32 @@ func (s *Session) resume(o *Config) bool {
 	return false
 }
 
+// resendUnacked sends again the stanzas not acknowledged by the server on resumption, h being the
+// number of stanzas it handled.
+// See XEP-0198 - 5. Resumption
+func (s *Session) resendUnacked(h *uint) {
+	s.err = resendUnacked(s.transport, s.SMState.UnAckQueue, h)
+}
+
+// resendUnacked writes the stanzas of the queue the server did not handle, h being the number of
+// stanzas it acknowledged on resumption. It is shared by clients and components.
+func resendUnacked(w io.Writer, q *stanza.UnAckQueue, h *uint) error {
+	if q == nil {
+		return nil
+	}
+	q.RLock()
+	defer q.RUnlock()
+	for _, stz := range q.Uslice {
+		if h != nil && uint(stz.Id) <= *h {
+			continue
+		}
+		if _, err := w.Write([]byte(stz.Stz)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *Session) bind(o *Config) {
 	if s.err != nil {
 		return
@@ -300,7 +399,7 @@ func (s *Session) EnableStreamManagement(o *Config) {
 	if s.err != nil {
 		return
 	}
-	if !s.Features.DoesStreamManagement() || !o.StreamManagementEnable {
+	if !s.supportsStreamManagement() || !o.StreamManagementEnable {
 		return
 	}
 	q := stanza.NewUnAckQueue()
@@ -319,24 +418,31 @@ func (s *Session) EnableStreamManagement(o *Config) {
 	var packet stanza.Packet
 	packet, s.err = stanza.NextPacket(s.transport.GetDecoder())
 	if s.err == nil {
-		switch p := packet.(type) {
-		case stanza.SMEnabled:
-			// Server allows resumption or not using SMEnabled attribute "resume". We must read the server response
-			// and update config accordingly
-			b, err := strconv.ParseBool(p.Resume)
-			if err != nil || !b {
-				o.StreamManagementEnable = false
-			}
-			s.SMState = SMState{Id: p.Id, preferredReconAddr: p.Location}
-			s.SMState.UnAckQueue = q
-		case stanza.SMFailed:
-			// TODO: Store error in SMState, for later inspection
-			s.SMState = SMState{StreamErrorGroup: p.StreamErrorGroup}
-			s.SMState.UnAckQueue = q
-			s.err = errors.New("failed to establish session : " + s.SMState.StreamErrorGroup.GroupErrorName())
-		default:
-			s.err = errors.New("unexpected reply to SM enable")
-		}
+		s.smEnableResult(o, packet, q)
 	}
 	return
 }
+
+// smEnableResult processes the answer of the server to the stream management enable request, sent
+// alone or inline with Bind 2.
+func (s *Session) smEnableResult(o *Config, packet stanza.Packet, q *stanza.UnAckQueue) {
+	switch p := packet.(type) {
+	case stanza.SMEnabled:
+		// Server allows resumption or not using SMEnabled attribute "resume". We must read the server response
+		// and update config accordingly
+		b, err := strconv.ParseBool(p.Resume)
+		if err != nil || !b {
+			o.StreamManagementEnable = false
+		}
+		s.SMState = SMState{Id: p.Id, preferredReconAddr: p.Location}
+		s.SMState.UnAckQueue = q
+		s.EnabledFeatures = append(s.EnabledFeatures, stanza.NSStreamManagement)
+	case stanza.SMFailed:
+		// TODO: Store error in SMState, for later inspection
+		s.SMState = SMState{StreamErrorGroup: p.StreamErrorGroup}
+		s.SMState.UnAckQueue = q
+		s.err = errors.New("failed to establish session : " + s.SMState.StreamErrorGroup.GroupErrorName())
+	default:
+		s.err = errors.New("unexpected reply to SM enable")
+	}
+}
@@ -0,0 +1,170 @@
+package xmpp
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/google/uuid"
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Inbound duplicate suppression (XEP-0198)
+
+// DuplicatePolicy tells what to do with duplicate stanzas re-sent by the server after a
+// stream resumption.
+type DuplicatePolicy = uint8
+
+const (
+	// DuplicateDrop drops duplicate stanzas before they are routed.
+	DuplicateDrop DuplicatePolicy = iota
+	// DuplicateFlag routes duplicate stanzas, with a Sender flagged as duplicate delivery.
+	// Handlers can check it with IsDuplicate.
+	DuplicateFlag
+)
+
+// duplicateSender is passed to handlers instead of the client for flagged duplicates.
+type duplicateSender struct {
+	Sender
+}
+
+// IsDuplicate returns true if the Sender passed to a handler flags the stanza being handled as
+// a duplicate of an already received stanza. See Config.InboundDuplicatePolicy.
+func IsDuplicate(s Sender) bool {
+	_, ok := s.(duplicateSender)
+	return ok
+}
+
+// recentIds is a bounded LRU set of received stanza keys. The keys of all the messages are recorded,
+// but duplicates are only reported in the window following a stream resumption, where the server
+// re-sends the stanzas it sent before the disconnection: a peer sending the same id twice in a
+// stream is not our concern.
+type recentIds struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	keys  map[string]*list.Element
+	// Messages still checked since the last resumption
+	resent int
+}
+
+func newRecentIds(size int) *recentIds {
+	return &recentIds{
+		size:  size,
+		order: list.New(),
+		keys:  make(map[string]*list.Element),
+	}
+}
+
+// seen records the key, and returns true if it was already present.
+func (r *recentIds) seen(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if elt, ok := r.keys[key]; ok {
+		r.order.MoveToFront(elt)
+		return true
+	}
+	r.keys[key] = r.order.PushFront(key)
+	if r.order.Len() > r.size {
+		oldest := r.order.Back()
+		r.order.Remove(oldest)
+		delete(r.keys, oldest.Value.(string))
+	}
+	return false
+}
+
+// resumed opens the window of the messages checked for duplicates, after a stream resumption. The
+// window is as large as the number of keys remembered.
+func (r *recentIds) resumed() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.resent = r.size
+}
+
+// duplicate records the key, and returns true if it was already present and the message is received
+// in the window following a resumption.
+func (r *recentIds) duplicate(key string) bool {
+	r.mu.Lock()
+	checked := r.resent > 0
+	if checked {
+		r.resent--
+	}
+	r.mu.Unlock()
+	return r.seen(key) && checked
+}
+
+// sender returns the Sender passed to the handlers of a received packet, flagged when the packet is
+// a duplicate. It returns false when the duplicate must be dropped. It is shared by clients and
+// components, and accepts all packets when duplicate detection is disabled.
+func (r *recentIds) sender(s Sender, p stanza.Packet, policy DuplicatePolicy) (Sender, bool) {
+	if r == nil {
+		return s, true
+	}
+	if key := duplicateKey(p); key != "" && r.duplicate(key) {
+		if policy == DuplicateDrop {
+			return nil, false
+		}
+		return duplicateSender{s}, true
+	}
+	return s, true
+}
+
+// duplicateKey returns the key identifying a received message: sender bare JID and origin-id,
+// or message id if there is no origin-id. Stanzas without id return an empty key. As messages sent
+// with stream management carry an origin-id, see withOriginId, the copies resent by a peer using
+// this library after a stream resumption have the same key as the original.
+// Messages sent through a room keep the full JID of the occupant, as the bare JID is the room.
+func duplicateKey(p stanza.Packet) string {
+	msg, ok := p.(stanza.Message)
+	if !ok {
+		return ""
+	}
+	id := msg.GetOriginId()
+	if id == "" {
+		id = msg.Id
+	}
+	if id == "" {
+		return ""
+	}
+	var muc stanza.MucUser
+	if msg.Type == stanza.MessageTypeGroupchat || msg.Get(&muc) {
+		return msg.From + " " + id
+	}
+	return bareJid(msg.From) + " " + id
+}
+
+// ============================================================================
+// Outbound origin-id (XEP-0359)
+
+// withOriginId returns the message with an origin-id, added before the message is queued for stream
+// management, so that the copies resent after a resumption carry the same origin-id as the original
+// and can be recognized as duplicates by the receiver. The id of the message is used as origin-id
+// when set. Other packets, and messages which already have an origin-id, are returned as is.
+func withOriginId(p stanza.Packet) stanza.Packet {
+	switch msg := p.(type) {
+	case stanza.Message:
+		return addOriginId(msg)
+	case *stanza.Message:
+		m := addOriginId(*msg)
+		return &m
+	}
+	return p
+}
+
+func addOriginId(msg stanza.Message) stanza.Message {
+	if msg.Type == stanza.MessageTypeError || msg.GetOriginId() != "" {
+		return msg
+	}
+	id := msg.Id
+	if id == "" {
+		id = uuid.New().String()
+	}
+	// Copy the extensions, not to modify the message of the caller
+	extensions := make([]stanza.MsgExtension, len(msg.Extensions), len(msg.Extensions)+1)
+	copy(extensions, msg.Extensions)
+	msg.Extensions = append(extensions, &stanza.OriginId{Id: id})
+	return msg
+}
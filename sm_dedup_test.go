@@ -0,0 +1,201 @@
+package xmpp
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// Messages received after a resumption: the first one is re-sent by the server, with the same id.
+const resentMessages = `<message xmlns="jabber:client" from="juliet@capulet.lit/balcony" id="msg1"><body>Hi</body></message>
+<message xmlns="jabber:client" from="juliet@capulet.lit/balcony" id="msg2"><body>How are you?</body></message>
+<message xmlns="jabber:client" from="juliet@capulet.lit/balcony" id="msg1"><body>Hi</body></message>
+<message xmlns="jabber:client" from="juliet@capulet.lit/phone" id="other"><origin-id xmlns="urn:xmpp:sid:0" id="msg2"/><body>How are you?</body></message>
+<message xmlns="jabber:client" from="romeo@montague.lit/orchard" id="msg1"><body>Not a duplicate</body></message>
+<message xmlns="jabber:client" from="juliet@capulet.lit/balcony"><body>No id</body></message>
+<message xmlns="jabber:client" from="juliet@capulet.lit/balcony"><body>No id</body></message>`
+
+type routedMessage struct {
+	body      string
+	duplicate bool
+}
+
+// receiveMessages runs the client receive loop on the given stream content, and returns the
+// messages routed to handlers, once the expected number of messages has been routed.
+func receiveMessages(t *testing.T, config *Config, content string, expected int) []routedMessage {
+	routedChan := make(chan routedMessage, 10)
+	router := NewRouter()
+	router.HandleFunc("message", func(s Sender, p stanza.Packet) {
+		routedChan <- routedMessage{body: p.(stanza.Message).Body, duplicate: IsDuplicate(s)}
+	})
+
+	c := &Client{
+		config:       config,
+		router:       router,
+		Session:      &Session{},
+		ErrorHandler: func(error) {},
+		transport:    &XMPPTransport{decoder: xml.NewDecoder(strings.NewReader(content))},
+	}
+	if config.InboundDuplicateWindow > 0 {
+		c.recentIds = newRecentIds(config.InboundDuplicateWindow)
+		c.recentIds.resumed()
+	}
+	c.recv(make(chan struct{}))
+
+	var routed []routedMessage
+	for len(routed) < expected {
+		select {
+		case m := <-routedChan:
+			routed = append(routed, m)
+		case <-time.After(defaultTimeout):
+			t.Fatalf("expected %d messages routed, got %d: %v", expected, len(routed), routed)
+		}
+	}
+	select {
+	case m := <-routedChan:
+		t.Fatalf("unexpected message routed: %v", m)
+	case <-time.After(50 * time.Millisecond):
+	}
+	return routed
+}
+
+func TestInboundDuplicateDrop(t *testing.T) {
+	config := &Config{StreamManagementEnable: true, InboundDuplicateWindow: 10}
+	routed := receiveMessages(t, config, resentMessages, 5)
+	for _, m := range routed {
+		if m.duplicate {
+			t.Errorf("dropped duplicates should not be routed: %v", m)
+		}
+	}
+}
+
+func TestInboundDuplicateFlag(t *testing.T) {
+	config := &Config{StreamManagementEnable: true, InboundDuplicateWindow: 10, InboundDuplicatePolicy: DuplicateFlag}
+	routed := receiveMessages(t, config, resentMessages, 7)
+	var duplicates int
+	for _, m := range routed {
+		if m.duplicate {
+			duplicates++
+		}
+	}
+	if duplicates != 2 {
+		t.Errorf("expected 2 duplicates to be flagged, got %d", duplicates)
+	}
+}
+
+func TestInboundDuplicateResumption(t *testing.T) {
+	ids := newRecentIds(10)
+	ids.duplicate("a")
+	if ids.duplicate("a") {
+		t.Errorf("duplicates should not be reported without resumption")
+	}
+	ids.resumed()
+	if !ids.duplicate("a") {
+		t.Errorf("duplicates should be reported after a resumption")
+	}
+	for i := 0; i < 9; i++ {
+		ids.duplicate("b")
+	}
+	if ids.duplicate("a") {
+		t.Errorf("duplicates should not be reported once the window is over")
+	}
+}
+
+func TestRecentIdsWindow(t *testing.T) {
+	ids := newRecentIds(2)
+	for _, key := range []string{"a", "b"} {
+		if ids.seen(key) {
+			t.Errorf("%s should not be seen yet", key)
+		}
+	}
+	if !ids.seen("a") {
+		t.Errorf("a should be seen")
+	}
+	// b is now the least recently used, and is evicted
+	ids.seen("c")
+	if ids.seen("b") {
+		t.Errorf("b should have been evicted")
+	}
+	if !ids.seen("c") {
+		t.Errorf("c should still be seen")
+	}
+}
+
+func TestDuplicateKeyOccupants(t *testing.T) {
+	first := parseMessage(t, `<message from='coven@chat.shakespeare.lit/firstwitch' type='groupchat' id='1'><body>Thrice the brinded cat hath mew'd.</body></message>`)
+	second := parseMessage(t, `<message from='coven@chat.shakespeare.lit/secondwitch' type='groupchat' id='1'><body>Thrice and once the hedge-pig whined.</body></message>`)
+	if duplicateKey(first) == duplicateKey(second) {
+		t.Errorf("messages of different occupants should not be duplicates: %q", duplicateKey(first))
+	}
+	pm := parseMessage(t, `<message from='coven@chat.shakespeare.lit/firstwitch' type='chat' id='1'><body>Psst</body><x xmlns='http://jabber.org/protocol/muc#user'/></message>`)
+	if duplicateKey(pm) != "coven@chat.shakespeare.lit/firstwitch 1" {
+		t.Errorf("private messages should keep the occupant JID: %q", duplicateKey(pm))
+	}
+
+	// Messages of the other resources of a contact are still detected
+	carbon := parseMessage(t, `<message from='juliet@capulet.lit/chamber' type='chat' id='1'><body>Hi</body></message>`)
+	if duplicateKey(carbon) != "juliet@capulet.lit 1" {
+		t.Errorf("unexpected key: %q", duplicateKey(carbon))
+	}
+}
+
+func TestOutboundOriginId(t *testing.T) {
+	msg := stanza.NewMessage(stanza.Attrs{To: "juliet@capulet.lit", Id: "msg1"})
+	msg.Body = "Hello"
+	annotated, ok := withOriginId(msg).(stanza.Message)
+	if !ok || annotated.GetOriginId() != "msg1" {
+		t.Fatalf("origin-id was not added: %#v", annotated)
+	}
+	if len(msg.Extensions) != 0 {
+		t.Errorf("message of the caller was modified: %#v", msg.Extensions)
+	}
+
+	// The resent copy is a duplicate of the original for the receiver
+	data, err := xml.Marshal(annotated)
+	if err != nil {
+		t.Fatalf("cannot marshal message: %v", err)
+	}
+	received := parseMessage(t, strings.Replace(string(data), `id="msg1"`, `id="other" from="romeo@montague.lit/orchard"`, 1))
+	if received.GetOriginId() != "msg1" {
+		t.Fatalf("origin-id was not sent: %s", data)
+	}
+	recent := newRecentIds(10)
+	if recent.seen(duplicateKey(received)) || !recent.seen(duplicateKey(received)) {
+		t.Error("resent copy should be detected as duplicate")
+	}
+
+	// An existing origin-id is kept, and a generated one is stable across resends
+	if again := withOriginId(annotated).(stanza.Message); again.GetOriginId() != "msg1" || len(again.Extensions) != 1 {
+		t.Error("existing origin-id was replaced")
+	}
+	noId := withOriginId(&stanza.Message{Body: "Hi"}).(*stanza.Message)
+	if noId.GetOriginId() == "" {
+		t.Error("origin-id was not generated")
+	}
+}
+
+func TestInboundDuplicateAutoReply(t *testing.T) {
+	content := strings.Repeat(`<message xmlns="jabber:client" from="juliet@capulet.lit/balcony" id="msg1" type="chat"><body>Hi</body><request xmlns="urn:xmpp:receipts"/></message>
+`, 2)
+	for _, policy := range []DuplicatePolicy{DuplicateDrop, DuplicateFlag} {
+		conn := &writeRecorderConn{}
+		c := &Client{
+			config:       &Config{StreamManagementEnable: true, InboundDuplicateWindow: 10, InboundDuplicatePolicy: policy, AutoReceipts: true},
+			router:       NewRouter(),
+			Session:      &Session{},
+			ErrorHandler: func(error) {},
+			transport:    &XMPPTransport{conn: conn, readWriter: conn, decoder: xml.NewDecoder(strings.NewReader(content))},
+		}
+		c.recentIds = newRecentIds(c.config.InboundDuplicateWindow)
+		c.recentIds.resumed()
+		c.recv(make(chan struct{}))
+
+		writes, _ := conn.recorded()
+		if len(writes) != 1 || !strings.Contains(writes[0], `<received xmlns="urn:xmpp:receipts" id="msg1">`) {
+			t.Errorf("policy %d: duplicate should not be answered again: %v", policy, writes)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package xmpp
+
+import (
+	"errors"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Stream Management (XEP-0198) state persistence
+
+// ErrNoResumableSession is returned by ExportSMState when the session cannot be resumed.
+var ErrNoResumableSession = errors.New("session cannot be resumed")
+
+// SMResumptionState is the stream management state needed to resume a session from a new process,
+// as exported by ExportSMState. It can be serialized to JSON.
+type SMResumptionState struct {
+	// ID and preferred location of the stream management session
+	ID       string `json:"id"`
+	Location string `json:"location,omitempty"`
+	// Inbound is the number of stanzas received from the server
+	Inbound uint `json:"h"`
+	// BindJID is the full JID bound to the session
+	BindJID string `json:"jid"`
+	// Unacked are the stanzas sent that may not have been received by the server
+	Unacked []SMUnackedStanza `json:"unacked,omitempty"`
+}
+
+// SMUnackedStanza is a stanza sent in a stream management session, as raw XML. Seq is its position
+// in the session, compared to the number of stanzas acknowledged by the server on resumption.
+type SMUnackedStanza struct {
+	Seq int    `json:"seq"`
+	XML string `json:"xml"`
+}
+
+// WithStreamResumption enables stream management, with session resumption.
+func WithStreamResumption() Option {
+	return func(config *Config) {
+		config.StreamManagementEnable = true
+		config.streamManagementResume = true
+	}
+}
+
+// ExportSMState returns the state of the stream management session, to resume it from a new process
+// with ImportSMState. It is meant to be called on shutdown, after which the process must exit without
+// calling Disconnect: closing the stream ends the session on the server.
+func (c *Client) ExportSMState() (SMResumptionState, error) {
+	if c.Session == nil || c.Session.SMState.Id == "" {
+		return SMResumptionState{}, ErrNoResumableSession
+	}
+	return newSMResumptionState(c.Session.SMState, c.Session.BindJid), nil
+}
+
+// newSMResumptionState copies the stream management state, and the stanzas it has not acknowledged.
+func newSMResumptionState(sm SMState, bindJid string) SMResumptionState {
+	state := SMResumptionState{
+		ID:       sm.Id,
+		Location: sm.preferredReconAddr,
+		Inbound:  sm.Inbound,
+		BindJID:  bindJid,
+	}
+	if q := sm.UnAckQueue; q != nil {
+		q.RLock()
+		for _, stz := range q.Uslice {
+			state.Unacked = append(state.Unacked, SMUnackedStanza{Seq: stz.Id, XML: stz.Stz})
+		}
+		q.RUnlock()
+	}
+	return state
+}
+
+// ImportSMState sets the stream management state exported by a previous process, to be resumed by
+// the next call to Connect. When the server cannot resume the session anymore, a new session is
+// bound as usual, and the unacknowledged stanzas of the previous session are dropped.
+func (c *Client) ImportSMState(state SMResumptionState) {
+	c.importedSM = &state
+}
+
+// takeImportedSMState returns the imported stream management state, if any, and forgets it so that
+// it is only used once.
+func (c *Client) takeImportedSMState() (SMState, string) {
+	imported := c.importedSM
+	if imported == nil {
+		return SMState{}, ""
+	}
+	c.importedSM = nil
+	return imported.smState(), imported.BindJID
+}
+
+// smState converts the exported state back to the stream management state to resume.
+func (state *SMResumptionState) smState() SMState {
+	queue := stanza.NewUnAckQueue()
+	for _, stz := range state.Unacked {
+		queue.Uslice = append(queue.Uslice, &stanza.UnAckedStz{Id: stz.Seq, Stz: stz.XML})
+	}
+	return SMState{
+		Id:                 state.ID,
+		Inbound:            state.Inbound,
+		preferredReconAddr: state.Location,
+		UnAckQueue:         queue,
+	}
+}
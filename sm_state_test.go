@@ -0,0 +1,137 @@
+package xmpp
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestExportSMState(t *testing.T) {
+	client := &Client{}
+	if _, err := client.ExportSMState(); err != ErrNoResumableSession {
+		t.Errorf("unexpected error without session: %v", err)
+	}
+
+	queue := stanza.NewUnAckQueue()
+	_ = queue.Push(&stanza.UnAckedStz{Stz: InitialPresence})
+	_ = queue.Push(&stanza.UnAckedStz{Stz: `<message id="m2" to="juliet@capulet.lit"><body>Hi</body></message>`})
+	client.Session = &Session{
+		BindJid: "test@localhost/bot",
+		SMState: SMState{Id: streamManagementID, Inbound: 7, UnAckQueue: queue},
+	}
+	state, err := client.ExportSMState()
+	if err != nil {
+		t.Fatalf("could not export state: %v", err)
+	}
+	if state.ID != streamManagementID || state.Inbound != 7 || state.BindJID != "test@localhost/bot" ||
+		len(state.Unacked) != 2 || state.Unacked[1].Seq != 2 {
+		t.Errorf("unexpected state: %+v", state)
+	}
+}
+
+func TestImportSMState_Resume(t *testing.T) {
+	serverDone := make(chan struct{})
+	addr := fmt.Sprintf("%s:%d", testClientDomain, testClientSMImport)
+	mock := ServerMock{}
+	mock.Start(t, addr, func(t *testing.T, sc *ServerConn) {
+		checkClientOpenStream(t, sc)
+		sendStreamFeatures(t, sc)
+		readAuth(t, sc.decoder)
+		sc.connection.Write([]byte("<success xmlns=\"urn:ietf:params:xml:ns:xmpp-sasl\"/>"))
+		checkClientOpenStream(t, sc)
+		sendFeaturesStreamManagment(t, sc)
+
+		var resume stanza.SMResume
+		if err := sc.decoder.Decode(&resume); err != nil {
+			t.Errorf("cannot decode resume: %v", err)
+			return
+		}
+		if resume.PrevId != streamManagementID || resume.H == nil || *resume.H != 7 {
+			t.Errorf("unexpected resume request: %+v", resume)
+		}
+		// Only the first stanza was received by the server
+		sc.connection.Write([]byte(fmt.Sprintf("<resumed xmlns='urn:xmpp:sm:3' previd='%s' h='1'/>", streamManagementID)))
+
+		var msg stanza.Message
+		if err := sc.decoder.Decode(&msg); err != nil || msg.Id != "m2" {
+			t.Errorf("unacked message should be sent again: %+v, %v", msg, err)
+		}
+		var presence stanza.Presence
+		if err := sc.decoder.Decode(&presence); err != nil {
+			t.Errorf("expected initial presence: %v", err)
+		}
+		serverDone <- struct{}{}
+	})
+	defer mock.Stop()
+
+	// State exported by the previous process
+	exported := `{"id":"` + streamManagementID + `","h":7,"jid":"test@localhost/bot","unacked":[
+{"seq":1,"xml":"<presence/>"},
+{"seq":2,"xml":"<message id=\"m2\" to=\"juliet@capulet.lit\"><body>Hi</body></message>"}]}`
+	var state SMResumptionState
+	if err := json.Unmarshal([]byte(exported), &state); err != nil {
+		t.Fatalf("could not decode state: %v", err)
+	}
+	client := newSMImportClient(t, addr)
+	client.ImportSMState(state)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("could not resume session: %v", err)
+	}
+	waitForEntity(t, serverDone)
+	if client.Session.BindJid != "test@localhost/bot" {
+		t.Errorf("resumed session should keep its JID: %s", client.Session.BindJid)
+	}
+}
+
+func TestImportSMState_Stale(t *testing.T) {
+	serverDone := make(chan struct{})
+	addr := fmt.Sprintf("%s:%d", testClientDomain, testClientSMImport)
+	mock := ServerMock{}
+	mock.Start(t, addr, func(t *testing.T, sc *ServerConn) {
+		checkClientOpenStream(t, sc)
+		sendStreamFeatures(t, sc)
+		readAuth(t, sc.decoder)
+		sc.connection.Write([]byte("<success xmlns=\"urn:ietf:params:xml:ns:xmpp-sasl\"/>"))
+		checkClientOpenStream(t, sc)
+		sendFeaturesStreamManagment(t, sc)
+
+		var resume stanza.SMResume
+		if err := sc.decoder.Decode(&resume); err != nil {
+			t.Errorf("cannot decode resume: %v", err)
+			return
+		}
+		// The session has expired on the server
+		sc.connection.Write([]byte("<failed xmlns='urn:xmpp:sm:3'><item-not-found xmlns='urn:ietf:params:xml:ns:xmpp-stanzas'/></failed>"))
+		bind(t, sc)
+		enableStreamManagement(t, sc, false, true)
+		discardPresence(t, sc)
+		serverDone <- struct{}{}
+	})
+	defer mock.Stop()
+
+	client := newSMImportClient(t, addr)
+	client.ImportSMState(SMResumptionState{ID: "expired-id", Inbound: 3, BindJID: "test@localhost/bot"})
+	if err := client.Connect(); err != nil {
+		t.Fatalf("stale state should fall back to binding: %v", err)
+	}
+	waitForEntity(t, serverDone)
+	if client.Session.BindJid != "test@localhost/test" || client.Session.SMState.Id != streamManagementID {
+		t.Errorf("a new session should be bound: %s, %s", client.Session.BindJid, client.Session.SMState.Id)
+	}
+}
+
+func newSMImportClient(t *testing.T, addr string) *Client {
+	config := Config{
+		TransportConfiguration: TransportConfiguration{Address: addr},
+		Jid:                    "test@localhost",
+		Credential:             Password("test"),
+		Insecure:               true,
+	}
+	client, err := NewClient(&config, NewRouter(), clientDefaultErrorHandler, WithStreamResumption())
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	return client
+}
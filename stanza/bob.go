@@ -0,0 +1,62 @@
+package stanza
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"strings"
+)
+
+/*
+Support for:
+- XEP-0221 - Data Forms Media Element: https://xmpp.org/extensions/xep-0221.html
+- XEP-0231 - Bits of Binary: https://xmpp.org/extensions/xep-0231.html
+*/
+
+const (
+	NSMediaElement = "urn:xmpp:media-element"
+	NSBoB          = "urn:xmpp:bob"
+)
+
+// FieldMedia is the media associated with a form field, available at one or more URIs. Media sent
+// with the form are referenced by a cid: URI.
+type FieldMedia struct {
+	XMLName xml.Name   `xml:"urn:xmpp:media-element media"`
+	Height  int        `xml:"height,attr,omitempty"`
+	Width   int        `xml:"width,attr,omitempty"`
+	URIs    []MediaURI `xml:"uri"`
+}
+
+// MediaURI is a location of the media, with its MIME type.
+type MediaURI struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// BobData is a small piece of binary data sent with a stanza, identified by its content id.
+type BobData struct {
+	XMLName xml.Name `xml:"urn:xmpp:bob data"`
+	CID     string   `xml:"cid,attr"`
+	Type    string   `xml:"type,attr,omitempty"`
+	MaxAge  int      `xml:"max-age,attr,omitempty"`
+	// Data is the base64 encoded content
+	Data string `xml:",chardata"`
+}
+
+// Decode returns the binary content.
+func (d BobData) Decode() ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(d.Data))
+}
+
+// FindBobData returns the data referenced by the cid: URI, if it is in the list.
+func FindBobData(uri string, data []BobData) (BobData, bool) {
+	if !strings.HasPrefix(uri, "cid:") {
+		return BobData{}, false
+	}
+	cid := strings.TrimPrefix(uri, "cid:")
+	for _, d := range data {
+		if d.CID == cid {
+			return d, true
+		}
+	}
+	return BobData{}, false
+}
@@ -0,0 +1,40 @@
+package stanza
+
+import (
+	"encoding/xml"
+)
+
+/*
+Support for:
+- XEP-0402 - PEP Native Bookmarks: https://xmpp.org/extensions/xep-0402.html
+  Each bookmark is a PEP item of the NodeBookmarks node, whose id is the room JID.
+*/
+
+const (
+	NSBookmarks   = "urn:xmpp:bookmarks:1"
+	NodeBookmarks = NSBookmarks
+)
+
+// BookmarkConference is the payload of a bookmark item.
+type BookmarkConference struct {
+	XMLName  xml.Name `xml:"urn:xmpp:bookmarks:1 conference"`
+	Name     string   `xml:"name,attr,omitempty"`
+	AutoJoin bool     `xml:"autojoin,attr,omitempty"`
+	Nick     string   `xml:"nick,omitempty"`
+	Password string   `xml:"password,omitempty"`
+}
+
+// NewBookmarkPublishOptions returns the publish options the bookmarks node must be configured with,
+// so that bookmarks are persisted and only readable by the account owner.
+// See XEP-0402 - 4.2 Adding a bookmark
+func NewBookmarkPublishOptions() *PublishOptions {
+	return &PublishOptions{
+		Form: NewForm([]*Field{
+			{Var: "FORM_TYPE", Type: FieldTypeHidden, ValuesList: []string{"http://jabber.org/protocol/pubsub#publish-options"}},
+			{Var: "pubsub#persist_items", ValuesList: []string{"true"}},
+			{Var: "pubsub#max_items", ValuesList: []string{"max"}},
+			{Var: "pubsub#send_last_published_item", ValuesList: []string{"never"}},
+			{Var: "pubsub#access_model", ValuesList: []string{"whitelist"}},
+		}, FormTypeSubmit),
+	}
+}
@@ -0,0 +1,59 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestBookmarkConferenceMarshal(t *testing.T) {
+	conf := stanza.BookmarkConference{Name: "The Play", AutoJoin: true, Nick: "JC"}
+	data, err := xml.Marshal(conf)
+	if err != nil {
+		t.Fatalf("could not marshal bookmark: %v", err)
+	}
+	expected := `<conference xmlns="urn:xmpp:bookmarks:1" name="The Play" autojoin="true"><nick>JC</nick></conference>`
+	if string(data) != expected {
+		t.Errorf("unexpected bookmark XML:\n%s\nexpected:\n%s", data, expected)
+	}
+
+	var parsed stanza.BookmarkConference
+	if err = xml.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("could not unmarshal bookmark: %v", err)
+	}
+	if parsed.Name != conf.Name || parsed.Nick != conf.Nick || !parsed.AutoJoin || parsed.Password != "" {
+		t.Errorf("unexpected bookmark: %+v", parsed)
+	}
+}
+
+func TestBookmarkRetractEvent(t *testing.T) {
+	raw := `<message from='juliet@capulet.lit' to='juliet@capulet.lit/balcony' type='headline'>
+  <event xmlns='http://jabber.org/protocol/pubsub#event'>
+    <items node='urn:xmpp:bookmarks:1'><retract id='theplay@conference.shakespeare.lit'/></items>
+  </event>
+</message>`
+	var msg stanza.Message
+	if err := xml.Unmarshal([]byte(raw), &msg); err != nil {
+		t.Fatalf("could not parse message: %v", err)
+	}
+	var event stanza.PubSubEvent
+	if !msg.Get(&event) {
+		t.Fatalf("missing pubsub event")
+	}
+	items, ok := event.EventElement.(*stanza.ItemsEvent)
+	if !ok || items.Retract == nil || items.Retract.ID != "theplay@conference.shakespeare.lit" {
+		t.Errorf("unexpected event: %+v", event.EventElement)
+	}
+}
+
+func TestBookmarkPublishOptions(t *testing.T) {
+	opts := stanza.NewBookmarkPublishOptions()
+	if opts.Form == nil || !strings.HasSuffix(opts.Form.FormType(), "#publish-options") {
+		t.Fatalf("unexpected publish options: %+v", opts)
+	}
+	if f := opts.Form.Field("pubsub#access_model"); f == nil || f.Value() != "whitelist" {
+		t.Errorf("bookmarks should only be readable by the account owner")
+	}
+}
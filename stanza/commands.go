@@ -4,6 +4,8 @@ import "encoding/xml"
 
 // Implements the XEP-0050 extension
 
+const NSCommands = "http://jabber.org/protocol/commands"
+
 const (
 	CommandActionCancel   = "cancel"
 	CommandActionComplete = "complete"
@@ -23,6 +25,10 @@ const (
 type Command struct {
 	XMLName xml.Name `xml:"http://jabber.org/protocol/commands command"`
 
+	// Actions allowed for the next stage of a multi-stage command, and notes sent by the responder
+	Actions *Actions `xml:"actions,omitempty"`
+	Notes   []Note   `xml:"note,omitempty"`
+
 	CommandElement CommandElement
 
 	BadAction       *struct{} `xml:"bad-action,omitempty"`
@@ -67,6 +73,24 @@ func (a *Actions) Ref() string {
 	return "actions"
 }
 
+// List returns the names of the allowed actions.
+func (a *Actions) List() []string {
+	var list []string
+	if a == nil {
+		return list
+	}
+	if a.Prev != nil {
+		list = append(list, CommandActionPrevious)
+	}
+	if a.Next != nil {
+		list = append(list, CommandActionNext)
+	}
+	if a.Complete != nil {
+		list = append(list, CommandActionComplete)
+	}
+	return list
+}
+
 type Note struct {
 	Text string `xml:",cdata"`
 	Type string `xml:"type,attr,omitempty"`
@@ -117,14 +141,14 @@ func (c *Command) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 			var err error
 			switch tt.Name.Local {
 
-			case "affiliations":
+			case "actions":
 				a := Actions{}
 				err = d.DecodeElement(&a, &tt)
-				c.CommandElement = &a
-			case "configure":
+				c.Actions = &a
+			case "note":
 				nt := Note{}
 				err = d.DecodeElement(&nt, &tt)
-				c.CommandElement = &nt
+				c.Notes = append(c.Notes, nt)
 			case "x":
 				f := Form{}
 				err = d.DecodeElement(&f, &tt)
@@ -149,5 +173,5 @@ func (c *Command) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 }
 
 func init() {
-	TypeRegistry.MapExtension(PKTIQ, xml.Name{Space: "http://jabber.org/protocol/commands", Local: "command"}, Command{})
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: NSCommands, Local: "command"}, Command{})
 }
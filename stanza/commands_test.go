@@ -38,3 +38,27 @@ func TestMarshalCommands(t *testing.T) {
 		t.Fatalf(err.Error())
 	}
 }
+
+func TestUnmarshalCommandActions(t *testing.T) {
+	input := `<command xmlns='http://jabber.org/protocol/commands' sessionid='config:20020923T213616Z-700' node='config' status='executing'>
+  <actions execute='next'><prev/><next/></actions>
+  <note type='warn'>Service 'httpd' is running.</note>
+  <x xmlns='jabber:x:data' type='form'><field var='state' type='list-single'/></x>
+</command>`
+	var c stanza.Command
+	if err := xml.Unmarshal([]byte(input), &c); err != nil {
+		t.Fatalf("failed to unmarshal command: %v", err)
+	}
+	if c.Actions == nil || c.Actions.Execute != "next" {
+		t.Fatalf("actions were not decoded: %+v", c.Actions)
+	}
+	if actions := c.Actions.List(); len(actions) != 2 || actions[0] != stanza.CommandActionPrevious || actions[1] != stanza.CommandActionNext {
+		t.Errorf("unexpected actions: %v", actions)
+	}
+	if len(c.Notes) != 1 || c.Notes[0].Type != stanza.CommandNoteTypeWarn {
+		t.Errorf("note was not decoded: %+v", c.Notes)
+	}
+	if _, ok := c.CommandElement.(*stanza.Form); !ok {
+		t.Errorf("form was not decoded")
+	}
+}
@@ -53,44 +53,12 @@ func (d *Delegation) GetSet() *ResultSet {
 	return d.ResultSet
 }
 
-// Forwarded is used to wrapped forwarded stanzas.
-// TODO: Move it in another file, as it is not limited to components.
-type Forwarded struct {
-	XMLName xml.Name `xml:"urn:xmpp:forward:0 forwarded"`
-	Stanza  Packet
-}
-
-// UnmarshalXML is a custom unmarshal function used by xml.Unmarshal to
-// transform generic XML content into hierarchical Node structure.
-func (f *Forwarded) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
-	// Check subelements to extract required field as boolean
-	for {
-		t, err := d.Token()
-		if err != nil {
-			return err
-		}
-
-		switch tt := t.(type) {
-
-		case xml.StartElement:
-			if packet, err := decodeClient(d, tt); err == nil {
-				f.Stanza = packet
-			}
-
-		case xml.EndElement:
-			if tt == start.End() {
-				return nil
-			}
-		}
-	}
-}
-
 type Delegated struct {
 	XMLName   xml.Name `xml:"delegated"`
 	Namespace string   `xml:"namespace,attr,omitempty"`
 }
 
 func init() {
-	TypeRegistry.MapExtension(PKTMessage, xml.Name{Space: "urn:xmpp:delegation:1", Local: "delegation"}, Delegation{})
-	TypeRegistry.MapExtension(PKTIQ, xml.Name{Space: "urn:xmpp:delegation:1", Local: "delegation"}, Delegation{})
+	TypeRegistry.MustRegister(PKTMessage, xml.Name{Space: "urn:xmpp:delegation:1", Local: "delegation"}, Delegation{})
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: "urn:xmpp:delegation:1", Local: "delegation"}, Delegation{})
 }
@@ -0,0 +1,66 @@
+package stanza
+
+import (
+	"encoding/xml"
+	"errors"
+)
+
+/*
+Support for:
+- XEP-0352 - Client State Indication: https://xmpp.org/extensions/xep-0352.html
+*/
+
+const NSCSI = "urn:xmpp:csi:0"
+
+// CSIActive tells the server that the client is active. Some servers answer it with the number of
+// stanzas they suppressed while the client was inactive, in Suppressed. This attribute is not part
+// of XEP-0352.
+type CSIActive struct {
+	XMLName    xml.Name `xml:"urn:xmpp:csi:0 active"`
+	Suppressed int64    `xml:"suppressed,attr,omitempty"`
+}
+
+func (CSIActive) Name() string {
+	return "CSI: active"
+}
+
+// CSIInactive tells the server that the client is inactive: the server may then delay or
+// suppress the stanzas which are not urgent.
+type CSIInactive struct {
+	XMLName xml.Name `xml:"urn:xmpp:csi:0 inactive"`
+}
+
+func (CSIInactive) Name() string {
+	return "CSI: inactive"
+}
+
+// DoesClientStateIndication tells if the server supports client state indication.
+func (sf *StreamFeatures) DoesClientStateIndication() bool {
+	for _, name := range sf.Any {
+		if name.Space == NSCSI && name.Local == "csi" {
+			return true
+		}
+	}
+	return false
+}
+
+type csiDecoder struct{}
+
+var csi csiDecoder
+
+// decode decodes all known nonza in the CSI namespace.
+func (csiDecoder) decode(p *xml.Decoder, se xml.StartElement) (Packet, error) {
+	switch se.Name.Local {
+	case "active":
+		var packet CSIActive
+		err := p.DecodeElement(&packet, &se)
+		return packet, err
+	case "inactive":
+		var packet CSIInactive
+		err := p.DecodeElement(&packet, &se)
+		return packet, err
+	default:
+		return nil, errors.New("unexpected XMPP packet " +
+			se.Name.Space + " <" + se.Name.Local + "/>")
+	}
+}
@@ -0,0 +1,42 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestDecodeCSIActive(t *testing.T) {
+	str := `<active xmlns='urn:xmpp:csi:0' suppressed='12'/>`
+	packet, err := stanza.NextPacket(xml.NewDecoder(strings.NewReader(str)))
+	if err != nil {
+		t.Fatalf("could not decode CSI active: %v", err)
+	}
+	active, ok := packet.(stanza.CSIActive)
+	if !ok || active.Suppressed != 12 {
+		t.Errorf("unexpected CSI active: %#v", packet)
+	}
+}
+
+func TestMarshalCSIInactive(t *testing.T) {
+	data, err := xml.Marshal(stanza.CSIInactive{})
+	if err != nil {
+		t.Fatalf("could not marshal CSI inactive: %v", err)
+	}
+	if string(data) != `<inactive xmlns="urn:xmpp:csi:0"></inactive>` {
+		t.Errorf("unexpected CSI inactive: %s", data)
+	}
+}
+
+func TestStreamFeaturesCSI(t *testing.T) {
+	str := `<stream:features xmlns:stream='http://etherx.jabber.org/streams'><csi xmlns='urn:xmpp:csi:0'/></stream:features>`
+	var features stanza.StreamFeatures
+	if err := xml.Unmarshal([]byte(str), &features); err != nil {
+		t.Fatalf("could not decode stream features: %v", err)
+	}
+	if !features.DoesClientStateIndication() {
+		t.Errorf("CSI feature not detected: %#v", features.Any)
+	}
+}
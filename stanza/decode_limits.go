@@ -0,0 +1,273 @@
+package stanza
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ============================================================================
+// Decoding limits
+
+// DecodeLimits bound the complexity of the stanzas received, to protect the decoder from stanzas
+// crafted to exhaust memory or CPU, independently of their size. A zero field uses the default limit,
+// and a negative field disables the limit.
+type DecodeLimits struct {
+	// MaxDepth is the nesting depth of the elements of a stanza, the stanza element being at depth 1.
+	// Defaults to 32.
+	MaxDepth int
+	// MaxElements is the number of elements of a stanza, including the stanza element. Defaults to
+	// 10000.
+	MaxElements int
+	// MaxAttributes is the number of attributes of an element, including the namespace declarations.
+	// Defaults to 64.
+	MaxAttributes int
+	// MaxAttributeLength is the length in bytes of an attribute value, as received, before the
+	// entities are replaced. Defaults to 32768.
+	MaxAttributeLength int
+}
+
+// DefaultDecodeLimits are the limits used for the fields of DecodeLimits left to zero.
+var DefaultDecodeLimits = DecodeLimits{
+	MaxDepth:           32,
+	MaxElements:        10000,
+	MaxAttributes:      64,
+	MaxAttributeLength: 32768,
+}
+
+// ErrStanzaTooComplex is wrapped by the errors returned when a received stanza exceeds the decoding
+// limits. The stream cannot be decoded anymore: it is closed with a policy-violation stream error.
+var ErrStanzaTooComplex = errors.New("stanza too complex")
+
+// DecodeLimitError is returned when a received stanza exceeds one of the decoding limits.
+type DecodeLimitError struct {
+	// Limit is the name of the exceeded limit, for example "depth"
+	Limit string
+	Max   int
+}
+
+func (e *DecodeLimitError) Error() string {
+	return fmt.Sprintf("%v: %s exceeds %d", ErrStanzaTooComplex, e.Limit, e.Max)
+}
+
+func (e *DecodeLimitError) Unwrap() error {
+	return ErrStanzaTooComplex
+}
+
+func (l DecodeLimits) withDefaults() DecodeLimits {
+	def := func(v, d int) int {
+		if v == 0 {
+			return d
+		}
+		return v
+	}
+	return DecodeLimits{
+		MaxDepth:           def(l.MaxDepth, DefaultDecodeLimits.MaxDepth),
+		MaxElements:        def(l.MaxElements, DefaultDecodeLimits.MaxElements),
+		MaxAttributes:      def(l.MaxAttributes, DefaultDecodeLimits.MaxAttributes),
+		MaxAttributeLength: def(l.MaxAttributeLength, DefaultDecodeLimits.MaxAttributeLength),
+	}
+}
+
+// exceeds tells if the value is over the limit, negative limits being disabled.
+func exceeds(value, limit int) bool {
+	return limit >= 0 && value > limit
+}
+
+// NewDecodeLimiter returns a reader checking the XML read from r against the limits, to be decoded by
+// an xml.Decoder. The XML is scanned as it is read, so that reading fails with a *DecodeLimitError as
+// soon as a limit is exceeded, without buffering the stanza. The bytes read before are returned,
+// so that the offset of the decoder points to the offending markup.
+//
+// Depths are counted from the stanza elements: the stream element, reopened on stream restart, is not
+// part of any stanza.
+func NewDecodeLimiter(r io.Reader, limits DecodeLimits) io.Reader {
+	return &decodeLimiter{r: r, limits: limits.withDefaults()}
+}
+
+// State of the markup scanner
+const (
+	scanText = iota
+	scanMarkup
+	scanStartTag
+	scanAttrValue
+	scanEndTag
+	scanDeclaration
+	scanComment
+	scanCData
+	scanPI
+)
+
+// maxStreamNameLen is the length of the element names kept to recognize the stream element.
+const maxStreamNameLen = len("stream:stream")
+
+type decodeLimiter struct {
+	r      io.Reader
+	limits DecodeLimits
+	err    error
+
+	state int
+	// depth is the number of open elements, the stream element excluded
+	depth    int
+	elements int
+	// Current start tag
+	name       []byte
+	nameDone   bool
+	attributes int
+	valueLen   int
+	quote      byte
+	slash      bool
+	// prev holds the last two bytes of comments, CDATA sections and processing instructions, to
+	// detect their end
+	prev [2]byte
+}
+
+func (l *decodeLimiter) Read(p []byte) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	n, err := l.r.Read(p)
+	for i := 0; i < n; i++ {
+		if l.err = l.scan(p[i]); l.err != nil {
+			return i, l.err
+		}
+	}
+	return n, err
+}
+
+func isXMLSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
+
+// scan processes the next byte of the stream.
+func (l *decodeLimiter) scan(b byte) error {
+	switch l.state {
+	case scanText:
+		if b == '<' {
+			l.state = scanMarkup
+		}
+	case scanMarkup:
+		switch b {
+		case '/':
+			l.state = scanEndTag
+		case '!':
+			l.state = scanDeclaration
+		case '?':
+			l.state = scanPI
+			l.prev = [2]byte{}
+		default:
+			l.state = scanStartTag
+			l.name = append(l.name[:0], b)
+			l.nameDone = false
+			l.attributes = 0
+			l.slash = false
+		}
+	case scanStartTag:
+		return l.scanStartTag(b)
+	case scanAttrValue:
+		if b == l.quote {
+			l.state = scanStartTag
+			return nil
+		}
+		l.valueLen++
+		if exceeds(l.valueLen, l.limits.MaxAttributeLength) {
+			return &DecodeLimitError{Limit: "attribute length", Max: l.limits.MaxAttributeLength}
+		}
+	case scanEndTag:
+		if b == '>' {
+			l.state = scanText
+			if l.depth > 0 {
+				l.depth--
+			}
+		}
+	case scanDeclaration:
+		// Only comments and CDATA sections are expected in a stream
+		switch b {
+		case '-':
+			l.state = scanComment
+		case '[':
+			l.state = scanCData
+		case '>':
+			l.state = scanText
+		}
+		l.prev = [2]byte{}
+	case scanComment:
+		if b == '>' && l.prev == [2]byte{'-', '-'} {
+			l.state = scanText
+		}
+		l.prev = [2]byte{l.prev[1], b}
+	case scanCData:
+		if b == '>' && l.prev == [2]byte{']', ']'} {
+			l.state = scanText
+		}
+		l.prev = [2]byte{l.prev[1], b}
+	case scanPI:
+		if b == '>' && l.prev[1] == '?' {
+			l.state = scanText
+		}
+		l.prev = [2]byte{l.prev[1], b}
+	}
+	return nil
+}
+
+func (l *decodeLimiter) scanStartTag(b byte) error {
+	if !l.nameDone {
+		if !isXMLSpace(b) && b != '/' && b != '>' {
+			if len(l.name) <= maxStreamNameLen {
+				l.name = append(l.name, b)
+			}
+			return nil
+		}
+		l.nameDone = true
+		if err := l.startElement(); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case b == '"' || b == '\'':
+		l.attributes++
+		if exceeds(l.attributes, l.limits.MaxAttributes) {
+			return &DecodeLimitError{Limit: "attributes", Max: l.limits.MaxAttributes}
+		}
+		l.state = scanAttrValue
+		l.quote = b
+		l.valueLen = 0
+	case b == '>':
+		l.state = scanText
+		if !l.slash && !l.isStream() {
+			l.depth++
+		}
+	}
+	l.slash = b == '/'
+	return nil
+}
+
+// startElement checks the limits on a new element, once its name is read.
+func (l *decodeLimiter) startElement() error {
+	if l.isStream() {
+		l.depth = 0
+		return nil
+	}
+	if l.depth == 0 {
+		l.elements = 0
+	}
+	l.elements++
+	if exceeds(l.elements, l.limits.MaxElements) {
+		return &DecodeLimitError{Limit: "elements", Max: l.limits.MaxElements}
+	}
+	if exceeds(l.depth+1, l.limits.MaxDepth) {
+		return &DecodeLimitError{Limit: "depth", Max: l.limits.MaxDepth}
+	}
+	return nil
+}
+
+// isStream tells if the current start tag opens a stream. Stream elements are only expected at the top
+// level.
+func (l *decodeLimiter) isStream() bool {
+	if l.depth != 0 {
+		return false
+	}
+	name := string(l.name)
+	return name == "stream:stream" || name == "stream"
+}
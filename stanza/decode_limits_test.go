@@ -0,0 +1,120 @@
+package stanza_test
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+const limitsStreamOpen = `<?xml version='1.0'?><stream:stream xmlns='jabber:client' xmlns:stream='http://etherx.jabber.org/streams' id='s1' version='1.0'>`
+
+// decodeWithLimits decodes the packets of the stream, and returns the error ending the decoding.
+func decodeWithLimits(stream string, limits stanza.DecodeLimits) (int, error) {
+	dec := xml.NewDecoder(bufio.NewReader(stanza.NewDecodeLimiter(strings.NewReader(stream), limits)))
+	if _, err := stanza.InitStream(dec); err != nil {
+		return 0, err
+	}
+	count := 0
+	for {
+		if _, err := stanza.NextPacket(dec); err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+func TestDecodeLimits(t *testing.T) {
+	limits := stanza.DecodeLimits{MaxDepth: 3, MaxElements: 4, MaxAttributes: 4, MaxAttributeLength: 40}
+	tests := []struct {
+		name   string
+		stanza string
+		limit  string
+	}{
+		{"within limits", `<message id='1' to='a@b'><body>hi</body><x xmlns='y'><z/></x></message>`, ""},
+		{"depth", `<message><x><y><z/></y></x></message>`, "depth"},
+		{"elements", `<message><a/><b/><c/><d/></message>`, "elements"},
+		{"attributes", `<message id='1' to='a@b' from='c@d' type='chat' xml:lang='en'/>`, "attributes"},
+		{"attribute length", `<message id="` + strings.Repeat("a", 41) + `"/>`, "attribute length"},
+		{"markup in attribute", `<message id='a/>b'><body/></message>`, ""},
+		{"comment", `<message><!-- <a><b><c> --><body/></message>`, ""},
+		{"cdata", `<message><body><![CDATA[<a><b><c>]]></body></message>`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, err := decodeWithLimits(limitsStreamOpen+tt.stanza, limits)
+			var limitErr *stanza.DecodeLimitError
+			if tt.limit == "" {
+				if count != 1 || errors.As(err, &limitErr) {
+					t.Errorf("stanza should be decoded, got %d packets and %v", count, err)
+				}
+				return
+			}
+			if !errors.Is(err, stanza.ErrStanzaTooComplex) || !errors.As(err, &limitErr) {
+				t.Fatalf("expected ErrStanzaTooComplex, got %v", err)
+			}
+			if limitErr.Limit != tt.limit {
+				t.Errorf("expected %s limit to be exceeded, got %s", tt.limit, limitErr.Limit)
+			}
+		})
+	}
+}
+
+// Limits apply to each stanza, and the stream element restarted after authentication is not counted.
+func TestDecodeLimitsPerStanza(t *testing.T) {
+	limits := stanza.DecodeLimits{MaxDepth: 2, MaxElements: 2}
+	stream := limitsStreamOpen +
+		`<message><body>one</body></message>` +
+		limitsStreamOpen +
+		`<message><body>two</body></message>` +
+		`<presence><show>away</show></presence>`
+	dec := xml.NewDecoder(bufio.NewReader(stanza.NewDecodeLimiter(strings.NewReader(stream), limits)))
+	for i := 0; i < 2; i++ {
+		if _, err := stanza.InitStream(dec); err != nil {
+			t.Fatalf("cannot open stream: %v", err)
+		}
+		if _, err := stanza.NextPacket(dec); err != nil {
+			t.Fatalf("message should be decoded: %v", err)
+		}
+	}
+	if _, err := stanza.NextPacket(dec); err != nil {
+		t.Errorf("presence should be decoded: %v", err)
+	}
+}
+
+func TestDecodeLimitsDisabled(t *testing.T) {
+	limits := stanza.DecodeLimits{MaxDepth: -1, MaxElements: -1}
+	stream := limitsStreamOpen + `<message>` + strings.Repeat(`<x>`, 100) + strings.Repeat(`</x>`, 100) + `</message>`
+	if count, err := decodeWithLimits(stream, limits); count != 1 {
+		t.Errorf("stanza should be decoded, got %v", err)
+	}
+}
+
+// A stanza with 100k siblings is rejected with the default limits, before being read completely.
+func TestDecodeLimitsManySiblings(t *testing.T) {
+	stanzaXML := `<message from='mallory@evil.lit'>` + strings.Repeat(`<x/>`, 100000) + `</message>`
+	source := strings.NewReader(limitsStreamOpen + stanzaXML)
+	limiter := stanza.NewDecodeLimiter(source, stanza.DecodeLimits{})
+	dec := xml.NewDecoder(bufio.NewReader(limiter))
+	if _, err := stanza.InitStream(dec); err != nil {
+		t.Fatalf("cannot open stream: %v", err)
+	}
+	_, err := stanza.NextPacket(dec)
+	var limitErr *stanza.DecodeLimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != "elements" {
+		t.Fatalf("expected the elements limit to be exceeded, got %v", err)
+	}
+	if source.Len() == 0 {
+		t.Errorf("stanza should not be read completely")
+	}
+
+	// The error is sticky: the stream cannot be decoded anymore
+	if _, err = io.Copy(ioutil.Discard, limiter); !errors.Is(err, stanza.ErrStanzaTooComplex) {
+		t.Errorf("expected reads to keep failing, got %v", err)
+	}
+}
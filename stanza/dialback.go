@@ -0,0 +1,72 @@
+package stanza
+
+import (
+	"encoding/xml"
+	"errors"
+)
+
+/*
+Support for:
+- XEP-0220 - Server Dialback: https://xmpp.org/extensions/xep-0220.html
+*/
+
+const NSDialback = "jabber:server:dialback"
+
+// Dialback result types
+const (
+	DialbackTypeValid   = "valid"
+	DialbackTypeInvalid = "invalid"
+	DialbackTypeError   = "error"
+)
+
+// DialbackResult is the db:result element, used by the initiating server to send the dialback key
+// and by the receiving server to report the result of the verification.
+type DialbackResult struct {
+	XMLName xml.Name `xml:"jabber:server:dialback result"`
+	From    string   `xml:"from,attr"`
+	To      string   `xml:"to,attr"`
+	Type    string   `xml:"type,attr,omitempty"`
+	Key     string   `xml:",chardata"`
+	Error   *Err     `xml:"error,omitempty"`
+}
+
+func (DialbackResult) Name() string {
+	return "Dialback: result"
+}
+
+// DialbackVerify is the db:verify element, used between the receiving server and the authoritative
+// server to check a dialback key. Id is the stream ID of the stream the key was sent on.
+type DialbackVerify struct {
+	XMLName xml.Name `xml:"jabber:server:dialback verify"`
+	From    string   `xml:"from,attr"`
+	To      string   `xml:"to,attr"`
+	Id      string   `xml:"id,attr"`
+	Type    string   `xml:"type,attr,omitempty"`
+	Key     string   `xml:",chardata"`
+	Error   *Err     `xml:"error,omitempty"`
+}
+
+func (DialbackVerify) Name() string {
+	return "Dialback: verify"
+}
+
+type dialbackDecoder struct{}
+
+var dialback dialbackDecoder
+
+// decode decodes all known nonza in the dialback namespace.
+func (dialbackDecoder) decode(p *xml.Decoder, se xml.StartElement) (Packet, error) {
+	switch se.Name.Local {
+	case "result":
+		var packet DialbackResult
+		err := p.DecodeElement(&packet, &se)
+		return packet, err
+	case "verify":
+		var packet DialbackVerify
+		err := p.DecodeElement(&packet, &se)
+		return packet, err
+	default:
+		return nil, errors.New("unexpected XMPP packet " +
+			se.Name.Space + " <" + se.Name.Local + "/>")
+	}
+}
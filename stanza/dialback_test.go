@@ -0,0 +1,46 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestDecodeDialback(t *testing.T) {
+	str := `<db:verify xmlns:db='jabber:server:dialback' from='example.net' to='example.com' id='D60000229F'>008c689ff366b50c63d69a3e2d2c0e0e1f8404b0118eb688a0102c87cb691bdc</db:verify>`
+	packet, err := stanza.NextPacket(xml.NewDecoder(strings.NewReader(str)))
+	if err != nil {
+		t.Fatalf("could not decode dialback verify: %v", err)
+	}
+	verify, ok := packet.(stanza.DialbackVerify)
+	if !ok {
+		t.Fatalf("unexpected packet type: %T", packet)
+	}
+	if verify.From != "example.net" || verify.To != "example.com" || verify.Id != "D60000229F" ||
+		verify.Key != "008c689ff366b50c63d69a3e2d2c0e0e1f8404b0118eb688a0102c87cb691bdc" {
+		t.Errorf("unexpected db:verify: %#v", verify)
+	}
+
+	str = `<db:result xmlns:db='jabber:server:dialback' from='example.net' to='example.com' type='valid'/>`
+	packet, err = stanza.NextPacket(xml.NewDecoder(strings.NewReader(str)))
+	if err != nil {
+		t.Fatalf("could not decode dialback result: %v", err)
+	}
+	result, ok := packet.(stanza.DialbackResult)
+	if !ok || result.Type != stanza.DialbackTypeValid {
+		t.Errorf("unexpected db:result: %#v", packet)
+	}
+}
+
+func TestMarshalDialbackResult(t *testing.T) {
+	result := stanza.DialbackResult{From: "example.com", To: "example.net", Key: "abcd"}
+	data, err := xml.Marshal(result)
+	if err != nil {
+		t.Fatalf("could not marshal db:result: %v", err)
+	}
+	if string(data) != `<result xmlns="jabber:server:dialback" from="example.com" to="example.net">abcd</result>` {
+		t.Errorf("unexpected db:result: %s", data)
+	}
+}
@@ -0,0 +1,47 @@
+package stanza
+
+import (
+	"sort"
+	"sync"
+)
+
+// ============================================================================
+// Feature registry
+
+// Packages implementing extensions register the namespaces they support in their init function, as
+// they register their types in TypeRegistry. The registered features are advertised by the
+// service discovery responders, in addition to the features set by the application.
+
+var featureRegistry = struct {
+	sync.RWMutex
+	features map[string]bool
+}{features: make(map[string]bool)}
+
+// RegisterFeature adds the namespace to the features advertised in service discovery
+// information. It is usually called from the init function of the package implementing the
+// extension.
+func RegisterFeature(namespace string) {
+	featureRegistry.Lock()
+	defer featureRegistry.Unlock()
+	featureRegistry.features[namespace] = true
+}
+
+// UnregisterFeature removes the namespace from the registered features, for example when the
+// extension is disabled.
+func UnregisterFeature(namespace string) {
+	featureRegistry.Lock()
+	defer featureRegistry.Unlock()
+	delete(featureRegistry.features, namespace)
+}
+
+// RegisteredFeatures returns the features registered with RegisterFeature, sorted alphabetically.
+func RegisteredFeatures() []string {
+	featureRegistry.RLock()
+	defer featureRegistry.RUnlock()
+	features := make([]string, 0, len(featureRegistry.features))
+	for f := range featureRegistry.features {
+		features = append(features, f)
+	}
+	sort.Strings(features)
+	return features
+}
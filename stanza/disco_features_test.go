@@ -0,0 +1,37 @@
+package stanza_test
+
+import (
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestRegisteredFeatures(t *testing.T) {
+	stanza.RegisterFeature("urn:xmpp:test:plugin:1")
+	stanza.RegisterFeature("urn:xmpp:test:plugin:0")
+	stanza.RegisterFeature("urn:xmpp:test:plugin:1")
+	t.Cleanup(func() {
+		stanza.UnregisterFeature("urn:xmpp:test:plugin:0")
+		stanza.UnregisterFeature("urn:xmpp:test:plugin:1")
+	})
+
+	var found []string
+	for _, f := range stanza.RegisteredFeatures() {
+		if f == "urn:xmpp:test:plugin:0" || f == "urn:xmpp:test:plugin:1" {
+			found = append(found, f)
+		}
+	}
+	if len(found) != 2 || found[0] != "urn:xmpp:test:plugin:0" {
+		t.Errorf("features should be registered once, sorted: %v", found)
+	}
+}
+
+func TestUnregisterFeature(t *testing.T) {
+	stanza.RegisterFeature("urn:xmpp:test:plugin:2")
+	stanza.UnregisterFeature("urn:xmpp:test:plugin:2")
+	for _, f := range stanza.RegisteredFeatures() {
+		if f == "urn:xmpp:test:plugin:2" {
+			t.Errorf("unregistered feature should not be listed")
+		}
+	}
+}
@@ -12,12 +12,13 @@ import (
 // Err is an XMPP stanza payload that is used to report error on message,
 // presence or iq stanza.
 // It is intended to be added in the payload of the erroneous stanza.
+// Text can be sent in several languages: Text.String() returns the default one.
 type Err struct {
 	XMLName xml.Name  `xml:"error"`
 	Code    int       `xml:"code,attr,omitempty"`
 	Type    ErrorType `xml:"type,attr"` // required
 	Reason  string
-	Text    string `xml:"urn:ietf:params:xml:ns:xmpp-stanzas text,omitempty"`
+	Text    LocalizedText `xml:"urn:ietf:params:xml:ns:xmpp-stanzas text,omitempty"`
 }
 
 // UnmarshalXML implements custom parsing for XMPP errors
@@ -59,9 +60,10 @@ func (x *Err) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 			goneName := xml.Name{Space: "urn:ietf:params:xml:ns:xmpp-stanzas", Local: "gone"}
 			if elt.XMLName == textName || // Regular error text
 				elt.XMLName == goneName { // Gone text for pubsub
-				x.Text = elt.Content
+				x.Text = append(x.Text, LocalizedString{Lang: nodeLang(elt), Value: elt.Content})
 			} else if elt.XMLName.Space == "urn:ietf:params:xml:ns:xmpp-stanzas" ||
-				elt.XMLName.Space == "http://jabber.org/protocol/pubsub#errors" {
+				elt.XMLName.Space == "http://jabber.org/protocol/pubsub#errors" ||
+				elt.XMLName.Space == NSCommands {
 				if strings.TrimSpace(x.Reason) != "" {
 					x.Reason = strings.Join([]string{elt.XMLName.Local}, ":")
 				} else {
@@ -77,19 +79,23 @@ func (x *Err) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	}
 }
 
+// MarshalXML encodes the error, unless it is empty. The legacy code is only encoded when set, as
+// errors of presences bounced by recent servers only have a type and a condition.
 func (x Err) MarshalXML(e *xml.Encoder, start xml.StartElement) (err error) {
-	if x.Code == 0 {
+	if x.Code == 0 && x.Type == "" && x.Reason == "" && len(x.Text) == 0 {
 		return nil
 	}
 
 	// Encode start element and attributes
 	start.Name = xml.Name{Local: "error"}
 
-	code := xml.Attr{
-		Name:  xml.Name{Local: "code"},
-		Value: strconv.Itoa(x.Code),
+	if x.Code != 0 {
+		code := xml.Attr{
+			Name:  xml.Name{Local: "code"},
+			Value: strconv.Itoa(x.Code),
+		}
+		start.Attr = append(start.Attr, code)
 	}
-	start.Attr = append(start.Attr, code)
 
 	if len(x.Type) > 0 {
 		typ := xml.Attr{
@@ -115,18 +121,21 @@ func (x Err) MarshalXML(e *xml.Encoder, start xml.StartElement) (err error) {
 
 	}
 
-	// Text
-	if x.Text != "" {
-		text := xml.Name{Space: "urn:ietf:params:xml:ns:xmpp-stanzas", Local: "text"}
-		err = e.EncodeToken(xml.StartElement{Name: text})
+	// Text, in each language
+	for _, variant := range x.Text {
+		text := xml.StartElement{Name: xml.Name{Space: "urn:ietf:params:xml:ns:xmpp-stanzas", Local: "text"}}
+		if variant.Lang != "" {
+			text.Attr = append(text.Attr, xml.Attr{Name: xml.Name{Space: nsXMLPrefix, Local: "lang"}, Value: variant.Lang})
+		}
+		err = e.EncodeToken(text)
 		if err != nil {
 			return err
 		}
-		err = e.EncodeToken(xml.CharData(x.Text))
+		err = e.EncodeToken(xml.CharData(variant.Value))
 		if err != nil {
 			return err
 		}
-		err = e.EncodeToken(xml.EndElement{Name: text})
+		err = e.EncodeToken(text.End())
 		if err != nil {
 			return err
 		}
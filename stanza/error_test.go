@@ -25,7 +25,7 @@ func TestErr_UnmarshalXML(t *testing.T) {
 	}
 
 	xmppError := parsedIQ.Error
-	if xmppError.Text != "System overloaded, please retry" {
+	if xmppError.Text.String() != "System overloaded, please retry" {
 		t.Errorf("Could not extract error text: '%s'", xmppError.Text)
 	}
 }
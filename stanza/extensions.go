@@ -0,0 +1,34 @@
+package stanza
+
+// ============================================================================
+// Typed access to message extensions
+
+// GetExtension returns the first extension of the message whose dynamic type is T, or the zero value
+// of T and false when there is none. Decoded extensions are pointers: T is usually a pointer type.
+//
+// Example usage:
+//
+//	if oob, ok := stanza.GetExtension[*stanza.OOB](&msg); ok {
+//	  // oob extension has been found
+//	}
+func GetExtension[T MsgExtension](msg *Message) (T, bool) {
+	for _, ext := range msg.Extensions {
+		if e, ok := ext.(T); ok {
+			return e, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// GetExtensions returns the extensions of the message whose dynamic type is T, in document order. It
+// returns nil when there is none.
+func GetExtensions[T MsgExtension](msg *Message) []T {
+	var exts []T
+	for _, ext := range msg.Extensions {
+		if e, ok := ext.(T); ok {
+			exts = append(exts, e)
+		}
+	}
+	return exts
+}
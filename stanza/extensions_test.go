@@ -0,0 +1,87 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func extensionsTestMessage(exts ...stanza.MsgExtension) *stanza.Message {
+	msg := stanza.NewMessage(stanza.Attrs{To: "juliet@capulet.lit", Id: "ext1"})
+	msg.Extensions = exts
+	return &msg
+}
+
+func TestGetExtension(t *testing.T) {
+	first := &stanza.OOB{URL: "https://example.com/first.png"}
+	second := &stanza.OOB{URL: "https://example.com/second.png"}
+	tests := []struct {
+		name     string
+		msg      *stanza.Message
+		expected *stanza.OOB
+	}{
+		{"no extensions", extensionsTestMessage(), nil},
+		{"other extensions", extensionsTestMessage(&stanza.ReceiptRequest{}, &stanza.StateActive{}), nil},
+		{"value of the pointer type", extensionsTestMessage(stanza.OOB{URL: "https://example.com/value.png"}), nil},
+		{"single", extensionsTestMessage(&stanza.ReceiptRequest{}, first), first},
+		{"first of several", extensionsTestMessage(first, &stanza.Markable{}, second), first},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oob, ok := stanza.GetExtension[*stanza.OOB](tt.msg)
+			if ok != (tt.expected != nil) || oob != tt.expected {
+				t.Errorf("unexpected extension: %v, %v", oob, ok)
+			}
+		})
+	}
+
+	// Zero value of a value type on miss
+	if receipt, ok := stanza.GetExtension[stanza.ReceiptRequest](extensionsTestMessage(&stanza.ReceiptRequest{})); ok ||
+		receipt != (stanza.ReceiptRequest{}) {
+		t.Errorf("expected the zero value on miss, got %+v, %v", receipt, ok)
+	}
+}
+
+func TestGetExtensions(t *testing.T) {
+	active := &stanza.StateActive{}
+	composing := &stanza.StateComposing{}
+	again := &stanza.StateComposing{}
+	tests := []struct {
+		name     string
+		msg      *stanza.Message
+		expected []*stanza.StateComposing
+	}{
+		{"no extensions", extensionsTestMessage(), nil},
+		{"other extensions", extensionsTestMessage(active, &stanza.ReceiptRequest{}), nil},
+		{"several", extensionsTestMessage(composing, active, again), []*stanza.StateComposing{composing, again}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := stanza.GetExtensions[*stanza.StateComposing](tt.msg)
+			if len(got) != len(tt.expected) {
+				t.Fatalf("expected %d extensions, got %d", len(tt.expected), len(got))
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("unexpected extension %d: %p, expected %p", i, got[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGetExtensionDecoded(t *testing.T) {
+	raw := `<message xmlns="jabber:client" id="ext2">` +
+		`<x xmlns="jabber:x:oob"><url>https://example.com/a.png</url></x>` +
+		`<x xmlns="jabber:x:oob"><url>https://example.com/b.png</url></x>` +
+		`</message>`
+	msg := stanza.Message{}
+	if err := xml.Unmarshal([]byte(raw), &msg); err != nil {
+		t.Fatalf("could not unmarshal message: %v", err)
+	}
+	oobs := stanza.GetExtensions[*stanza.OOB](&msg)
+	if len(oobs) != 2 || oobs[0].URL != "https://example.com/a.png" || oobs[1].URL != "https://example.com/b.png" {
+		t.Errorf("unexpected OOB extensions: %+v", oobs)
+	}
+}
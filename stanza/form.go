@@ -37,6 +37,10 @@ type Field struct {
 	Var         string   `xml:"var,attr,omitempty"`
 	Type        string   `xml:"type,attr,omitempty"`
 	Label       string   `xml:"label,attr,omitempty"`
+	// XEP-0122 validation rules, if any
+	Validation *FieldValidation `xml:"http://jabber.org/protocol/xdata-validate validate,omitempty"`
+	// XEP-0221 media element, for example the image of a CAPTCHA
+	Media *FieldMedia `xml:"urn:xmpp:media-element media,omitempty"`
 }
 
 func NewForm(fields []*Field, formType string) *Form {
@@ -46,6 +50,32 @@ func NewForm(fields []*Field, formType string) *Form {
 	}
 }
 
+// Field returns the field with the given var name, or nil if the form does not contain it.
+func (f *Form) Field(name string) *Field {
+	for _, field := range f.Fields {
+		if field != nil && field.Var == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// FormType returns the value of the FORM_TYPE hidden field, as defined in XEP-0068.
+func (f *Form) FormType() string {
+	if field := f.Field("FORM_TYPE"); field != nil && len(field.ValuesList) > 0 {
+		return field.ValuesList[0]
+	}
+	return ""
+}
+
+// Value returns the first value of the field, or an empty string.
+func (f *Field) Value() string {
+	if len(f.ValuesList) > 0 {
+		return f.ValuesList[0]
+	}
+	return ""
+}
+
 type FieldType string
 
 const (
@@ -66,3 +96,7 @@ type Option struct {
 	Label      string   `xml:"label,attr,omitempty"`
 	ValuesList []string `xml:"value"`
 }
+
+func init() {
+	TypeRegistry.MustRegister(PKTMessage, xml.Name{Space: "jabber:x:data", Local: "x"}, Form{})
+}
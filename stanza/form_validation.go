@@ -0,0 +1,331 @@
+package stanza
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Support for:
+- XEP-0122 - Data Forms Validation: https://xmpp.org/extensions/xep-0122.html
+*/
+
+const NSFormValidation = "http://jabber.org/protocol/xdata-validate"
+
+// Validation methods, as defined in XEP-0122 - 3.2 Validation Methods
+const (
+	ValidationMethodBasic = "basic"
+	ValidationMethodOpen  = "open"
+	ValidationMethodRange = "range"
+	ValidationMethodRegex = "regex"
+)
+
+// Datatypes commonly used in validation elements. The default datatype is "xs:string".
+// See XEP-0122 - 3.1 Datatypes
+const (
+	DatatypeAnyURI   = "xs:anyURI"
+	DatatypeBoolean  = "xs:boolean"
+	DatatypeByte     = "xs:byte"
+	DatatypeDate     = "xs:date"
+	DatatypeDateTime = "xs:dateTime"
+	DatatypeDecimal  = "xs:decimal"
+	DatatypeDouble   = "xs:double"
+	DatatypeInt      = "xs:int"
+	DatatypeInteger  = "xs:integer"
+	DatatypeLanguage = "xs:language"
+	DatatypeLong     = "xs:long"
+	DatatypeShort    = "xs:short"
+	DatatypeString   = "xs:string"
+	DatatypeTime     = "xs:time"
+)
+
+var (
+	ErrValidationDatatype = errors.New("value does not match the field datatype")
+	ErrValidationOption   = errors.New("value is not one of the field options")
+	ErrValidationRange    = errors.New("value is out of the allowed range")
+	ErrValidationRegex    = errors.New("value does not match the field regular expression")
+)
+
+// FieldValidation is the validate element that can be attached to a data form field.
+// Method holds the name of the validation method child element (basic, open, range or regex).
+// Min and Max are only used for the range method and Regex only for the regex method.
+type FieldValidation struct {
+	XMLName  xml.Name `xml:"http://jabber.org/protocol/xdata-validate validate"`
+	Datatype string   `xml:"datatype,attr,omitempty"`
+	Method   string
+	Min      string
+	Max      string
+	Regex    string
+}
+
+// UnmarshalXML implements custom parsing for the validate element, as the validation method
+// is expressed by the name of its child element.
+func (v *FieldValidation) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	v.XMLName = start.Name
+
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "datatype" {
+			v.Datatype = attr.Value
+		}
+	}
+
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case ValidationMethodBasic, ValidationMethodOpen:
+				v.Method = tt.Name.Local
+				err = d.Skip()
+			case ValidationMethodRange:
+				v.Method = tt.Name.Local
+				for _, attr := range tt.Attr {
+					switch attr.Name.Local {
+					case "min":
+						v.Min = attr.Value
+					case "max":
+						v.Max = attr.Value
+					}
+				}
+				err = d.Skip()
+			case ValidationMethodRegex:
+				v.Method = tt.Name.Local
+				err = d.DecodeElement(&v.Regex, &tt)
+			default:
+				// list-range and unknown elements are ignored
+				err = d.Skip()
+			}
+			if err != nil {
+				return err
+			}
+		case xml.EndElement:
+			if tt == start.End() {
+				return nil
+			}
+		}
+	}
+}
+
+func (v FieldValidation) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Space: NSFormValidation, Local: "validate"}
+	start.Attr = nil
+	if v.Datatype != "" {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "datatype"}, Value: v.Datatype})
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	if v.Method != "" {
+		method := xml.StartElement{Name: xml.Name{Local: v.Method}}
+		switch v.Method {
+		case ValidationMethodRange:
+			if v.Min != "" {
+				method.Attr = append(method.Attr, xml.Attr{Name: xml.Name{Local: "min"}, Value: v.Min})
+			}
+			if v.Max != "" {
+				method.Attr = append(method.Attr, xml.Attr{Name: xml.Name{Local: "max"}, Value: v.Max})
+			}
+			if err := e.EncodeToken(method); err != nil {
+				return err
+			}
+		case ValidationMethodRegex:
+			if err := e.EncodeToken(method); err != nil {
+				return err
+			}
+			if err := e.EncodeToken(xml.CharData(v.Regex)); err != nil {
+				return err
+			}
+		default:
+			if err := e.EncodeToken(method); err != nil {
+				return err
+			}
+		}
+		if err := e.EncodeToken(method.End()); err != nil {
+			return err
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+// ValidateField checks a value against the validation rules attached to the field.
+// If the field has no validation element, any value is accepted.
+func ValidateField(field *Field, value string) error {
+	if field == nil || field.Validation == nil {
+		return nil
+	}
+	v := field.Validation
+
+	datatype := v.Datatype
+	if datatype == "" {
+		datatype = DatatypeString
+	}
+	if err := checkDatatype(datatype, value); err != nil {
+		return err
+	}
+
+	switch v.Method {
+	case "", ValidationMethodBasic:
+		// For list types, basic validation means the value must be one of the options.
+		if len(field.Options) > 0 &&
+			(field.Type == FieldTypeListSingle || field.Type == FieldTypeListMulti) {
+			for _, opt := range field.Options {
+				for _, optVal := range opt.ValuesList {
+					if optVal == value {
+						return nil
+					}
+				}
+			}
+			return ErrValidationOption
+		}
+		return nil
+	case ValidationMethodOpen:
+		return nil
+	case ValidationMethodRange:
+		return checkRange(datatype, value, v.Min, v.Max)
+	case ValidationMethodRegex:
+		re, err := regexp.Compile("^(?:" + v.Regex + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid validation regex %q: %w", v.Regex, err)
+		}
+		if !re.MatchString(value) {
+			return ErrValidationRegex
+		}
+		return nil
+	default:
+		return errors.New("unknown validation method: " + v.Method)
+	}
+}
+
+// checkDatatype verifies that a value can be parsed according to its declared datatype.
+// Unknown datatypes (including custom ones with non "xs:" prefix) are accepted as is.
+func checkDatatype(datatype, value string) error {
+	var err error
+	switch datatype {
+	case DatatypeBoolean:
+		switch value {
+		case "0", "1", "true", "false":
+		default:
+			err = ErrValidationDatatype
+		}
+	case DatatypeByte:
+		_, err = strconv.ParseInt(value, 10, 8)
+	case DatatypeShort:
+		_, err = strconv.ParseInt(value, 10, 16)
+	case DatatypeInt:
+		_, err = strconv.ParseInt(value, 10, 32)
+	case DatatypeLong:
+		_, err = strconv.ParseInt(value, 10, 64)
+	case DatatypeInteger:
+		if _, ok := new(big.Int).SetString(value, 10); !ok {
+			err = ErrValidationDatatype
+		}
+	case DatatypeDecimal, DatatypeDouble:
+		_, err = strconv.ParseFloat(value, 64)
+	case DatatypeDate, DatatypeDateTime, DatatypeTime:
+		_, err = parseValidationTime(datatype, value)
+	case DatatypeAnyURI:
+		_, err = url.Parse(value)
+	case DatatypeLanguage:
+		if strings.TrimSpace(value) == "" {
+			err = ErrValidationDatatype
+		}
+	}
+	if err != nil {
+		return ErrValidationDatatype
+	}
+	return nil
+}
+
+// checkRange compares the value with the optional min and max boundaries, using the
+// ordering of the datatype.
+func checkRange(datatype, value, min, max string) error {
+	cmp := func(a, b string) (int, error) {
+		switch datatype {
+		case DatatypeByte, DatatypeShort, DatatypeInt, DatatypeLong, DatatypeInteger:
+			x, okX := new(big.Int).SetString(a, 10)
+			y, okY := new(big.Int).SetString(b, 10)
+			if !okX || !okY {
+				return 0, ErrValidationDatatype
+			}
+			return x.Cmp(y), nil
+		case DatatypeDecimal, DatatypeDouble:
+			x, errX := strconv.ParseFloat(a, 64)
+			y, errY := strconv.ParseFloat(b, 64)
+			if errX != nil || errY != nil {
+				return 0, ErrValidationDatatype
+			}
+			switch {
+			case x < y:
+				return -1, nil
+			case x > y:
+				return 1, nil
+			}
+			return 0, nil
+		case DatatypeDate, DatatypeDateTime, DatatypeTime:
+			x, errX := parseValidationTime(datatype, a)
+			y, errY := parseValidationTime(datatype, b)
+			if errX != nil || errY != nil {
+				return 0, ErrValidationDatatype
+			}
+			switch {
+			case x.Before(y):
+				return -1, nil
+			case x.After(y):
+				return 1, nil
+			}
+			return 0, nil
+		default:
+			return strings.Compare(a, b), nil
+		}
+	}
+
+	if min != "" {
+		c, err := cmp(value, min)
+		if err != nil {
+			return err
+		}
+		if c < 0 {
+			return ErrValidationRange
+		}
+	}
+	if max != "" {
+		c, err := cmp(value, max)
+		if err != nil {
+			return err
+		}
+		if c > 0 {
+			return ErrValidationRange
+		}
+	}
+	return nil
+}
+
+func parseValidationTime(datatype, value string) (time.Time, error) {
+	switch datatype {
+	case DatatypeDate:
+		return time.Parse(dateLayoutXEP0082, value)
+	case DatatypeTime:
+		for _, layout := range []string{"15:04:05Z07:00", "15:04:05.999999999Z07:00", "15:04:05"} {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, ErrValidationDatatype
+	default:
+		jd, err := NewJabberDateFromString(value)
+		return jd.value, err
+	}
+}
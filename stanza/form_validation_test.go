@@ -0,0 +1,154 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// Example 8 from XEP-0122
+const validatedForm = `<x xmlns='jabber:x:data' type='form'>
+  <field var='evt.date' type='text-single' label='Event Date'>
+    <validate xmlns='http://jabber.org/protocol/xdata-validate' datatype='xs:date'>
+      <range min='2003-10-05' max='2003-10-24'/>
+    </validate>
+  </field>
+  <field var='ssn' type='text-single' label='Social Security Number'>
+    <validate xmlns='http://jabber.org/protocol/xdata-validate' datatype='xs:string'>
+      <regex>([0-9]{3})-([0-9]{2})-([0-9]{4})</regex>
+    </validate>
+  </field>
+  <field var='language' type='list-single' label='Language'>
+    <validate xmlns='http://jabber.org/protocol/xdata-validate' datatype='xs:string'>
+      <basic/>
+    </validate>
+    <option><value>en</value></option>
+    <option><value>fr</value></option>
+  </field>
+  <field var='color' type='list-single' label='Color'>
+    <validate xmlns='http://jabber.org/protocol/xdata-validate'>
+      <open/>
+    </validate>
+    <option><value>red</value></option>
+  </field>
+  <field var='age' type='text-single' label='Age'>
+    <validate xmlns='http://jabber.org/protocol/xdata-validate' datatype='xs:integer'>
+      <range min='18' max='120'/>
+    </validate>
+  </field>
+</x>`
+
+func TestDecodeFieldValidation(t *testing.T) {
+	var form stanza.Form
+	if err := xml.Unmarshal([]byte(validatedForm), &form); err != nil {
+		t.Fatalf("could not unmarshal form: %v", err)
+	}
+	if len(form.Fields) != 5 {
+		t.Fatalf("expected 5 fields, got %d", len(form.Fields))
+	}
+
+	expected := []struct {
+		datatype, method, min, max, regex string
+	}{
+		{stanza.DatatypeDate, stanza.ValidationMethodRange, "2003-10-05", "2003-10-24", ""},
+		{stanza.DatatypeString, stanza.ValidationMethodRegex, "", "", "([0-9]{3})-([0-9]{2})-([0-9]{4})"},
+		{stanza.DatatypeString, stanza.ValidationMethodBasic, "", "", ""},
+		{"", stanza.ValidationMethodOpen, "", "", ""},
+		{stanza.DatatypeInteger, stanza.ValidationMethodRange, "18", "120", ""},
+	}
+	for i, exp := range expected {
+		v := form.Fields[i].Validation
+		if v == nil {
+			t.Fatalf("field %s: validation was not decoded", form.Fields[i].Var)
+		}
+		if v.Datatype != exp.datatype || v.Method != exp.method || v.Min != exp.min ||
+			v.Max != exp.max || v.Regex != exp.regex {
+			t.Errorf("field %s: unexpected validation %+v", form.Fields[i].Var, *v)
+		}
+	}
+}
+
+func TestMarshalFieldValidation(t *testing.T) {
+	field := stanza.Field{
+		Var:  "age",
+		Type: stanza.FieldTypeTextSingle,
+		Validation: &stanza.FieldValidation{
+			Datatype: stanza.DatatypeInteger,
+			Method:   stanza.ValidationMethodRange,
+			Min:      "18",
+			Max:      "120",
+		},
+	}
+	data, err := xml.Marshal(field)
+	if err != nil {
+		t.Fatalf("could not marshal field: %v", err)
+	}
+	if !strings.Contains(string(data), `<validate xmlns="http://jabber.org/protocol/xdata-validate" datatype="xs:integer"><range min="18" max="120"></range></validate>`) {
+		t.Errorf("unexpected validation marshalling: %s", data)
+	}
+
+	var parsed stanza.Field
+	if err = xml.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("could not unmarshal field: %v", err)
+	}
+	field.Validation.XMLName = xml.Name{Space: stanza.NSFormValidation, Local: "validate"}
+	if parsed.Validation == nil || *parsed.Validation != *field.Validation {
+		t.Errorf("validation does not survive round trip: %+v", parsed.Validation)
+	}
+}
+
+func TestValidateField(t *testing.T) {
+	var form stanza.Form
+	if err := xml.Unmarshal([]byte(validatedForm), &form); err != nil {
+		t.Fatalf("could not unmarshal form: %v", err)
+	}
+	fields := make(map[string]*stanza.Field)
+	for _, f := range form.Fields {
+		fields[f.Var] = f
+	}
+
+	tests := []struct {
+		field string
+		value string
+		err   error
+	}{
+		// range on xs:date
+		{"evt.date", "2003-10-05", nil},
+		{"evt.date", "2003-10-10", nil},
+		{"evt.date", "2003-10-24", nil},
+		{"evt.date", "2003-10-25", stanza.ErrValidationRange},
+		{"evt.date", "2003-09-30", stanza.ErrValidationRange},
+		{"evt.date", "not a date", stanza.ErrValidationDatatype},
+		// regex
+		{"ssn", "123-45-6789", nil},
+		{"ssn", "123-456-789", stanza.ErrValidationRegex},
+		{"ssn", "x123-45-6789", stanza.ErrValidationRegex},
+		// basic
+		{"language", "en", nil},
+		{"language", "de", stanza.ErrValidationOption},
+		// open
+		{"color", "red", nil},
+		{"color", "blue", nil},
+		// range on xs:integer
+		{"age", "18", nil},
+		{"age", "42", nil},
+		{"age", "17", stanza.ErrValidationRange},
+		{"age", "121", stanza.ErrValidationRange},
+		{"age", "forty", stanza.ErrValidationDatatype},
+	}
+
+	for _, tc := range tests {
+		err := stanza.ValidateField(fields[tc.field], tc.value)
+		if err != tc.err {
+			t.Errorf("field %s, value %q: expected error %v, got %v", tc.field, tc.value, tc.err, err)
+		}
+	}
+}
+
+func TestValidateFieldNoValidation(t *testing.T) {
+	if err := stanza.ValidateField(&stanza.Field{Var: "free"}, "anything"); err != nil {
+		t.Errorf("field without validation should accept any value: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package stanza
+
+import (
+	"encoding/xml"
+)
+
+// FormatOptions controls the serialization of a stanza by FormatStanza.
+type FormatOptions struct {
+	// XMLDeclaration starts the output with an XML declaration, to make it a standalone XML document
+	XMLDeclaration bool
+	// Indent and Prefix indent the elements, as with xml.MarshalIndent
+	Indent string
+	Prefix string
+}
+
+// FormatStanza serializes the stanza, with all its extensions, for example to log it or store it.
+func FormatStanza(p Packet, opts FormatOptions) (string, error) {
+	out, err := xml.MarshalIndent(p, opts.Prefix, opts.Indent)
+	if err != nil {
+		return "", err
+	}
+	if opts.XMLDeclaration {
+		return xml.Header + string(out), nil
+	}
+	return string(out), nil
+}
+
+// FormatWith serializes the message with the options. It returns an empty string if the message
+// cannot be serialized.
+func (msg *Message) FormatWith(opts FormatOptions) string {
+	out, _ := FormatStanza(msg, opts)
+	return out
+}
+
+// FormatWith serializes the presence with the options. It returns an empty string if the presence
+// cannot be serialized.
+func (pres *Presence) FormatWith(opts FormatOptions) string {
+	out, _ := FormatStanza(pres, opts)
+	return out
+}
+
+// XMPPFormat with all Extensions
+func (pres *Presence) XMPPFormat() string {
+	return pres.FormatWith(FormatOptions{})
+}
+
+// FormatWith serializes the IQ with the options. It returns an empty string if the IQ cannot be
+// serialized.
+func (iq *IQ) FormatWith(opts FormatOptions) string {
+	out, _ := FormatStanza(iq, opts)
+	return out
+}
+
+// XMPPFormat with all Extensions
+func (iq *IQ) XMPPFormat() string {
+	return iq.FormatWith(FormatOptions{})
+}
@@ -0,0 +1,43 @@
+package stanza_test
+
+import (
+	"strings"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestFormatStanza(t *testing.T) {
+	msg := stanza.NewMessage(stanza.Attrs{To: "juliet@capulet.lit", Id: "1"})
+	msg.Body = "Hello"
+	if msg.XMPPFormat() != msg.FormatWith(stanza.FormatOptions{}) {
+		t.Errorf("XMPPFormat differs from FormatWith: %s", msg.XMPPFormat())
+	}
+
+	out := msg.FormatWith(stanza.FormatOptions{XMLDeclaration: true, Indent: "  "})
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<message id="1" to="juliet@capulet.lit">
+  <body>Hello</body>
+</message>`
+	if out != expected {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+
+	pres := stanza.NewPresence(stanza.Attrs{To: "juliet@capulet.lit"})
+	out, err := stanza.FormatStanza(pres, stanza.FormatOptions{XMLDeclaration: true})
+	if err != nil {
+		t.Fatalf("cannot format presence: %v", err)
+	}
+	if !strings.HasPrefix(out, `<?xml version="1.0" encoding="UTF-8"?>`) || !strings.HasSuffix(out, `<presence to="juliet@capulet.lit"></presence>`) {
+		t.Errorf("unexpected output: %s", out)
+	}
+
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, To: "capulet.lit", Id: "ping1"})
+	if err != nil {
+		t.Fatalf("cannot create IQ: %v", err)
+	}
+	iq.Payload = &stanza.Ping{}
+	if out = iq.XMPPFormat(); !strings.Contains(out, `<ping xmlns="urn:xmpp:ping">`) {
+		t.Errorf("unexpected output: %s", out)
+	}
+}
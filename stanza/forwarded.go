@@ -0,0 +1,108 @@
+package stanza
+
+import (
+	"encoding/xml"
+)
+
+// ============================================================================
+// Stanza Forwarding (XEP-0297)
+
+const NSForward = "urn:xmpp:forward:0"
+
+// Forwarded is used to wrap forwarded stanzas, for instance in delegated IQs, message carbons
+// or archived messages. The stanza is decoded with its registered extensions, using DecodeStanza.
+// Delay, if set, is the time the stanza was originally sent.
+type Forwarded struct {
+	XMLName xml.Name `xml:"urn:xmpp:forward:0 forwarded"`
+	Delay   *Delay
+	Stanza  Packet
+}
+
+// UnmarshalXML implements custom parsing for the forwarded element, to decode the wrapped stanza.
+func (f *Forwarded) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	f.XMLName = start.Name
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "message", "presence", "iq":
+				packet, err := DecodeStanza(d, tt)
+				if err != nil {
+					return err
+				}
+				f.Stanza = packet
+			case "delay":
+				var delay Delay
+				if err = d.DecodeElement(&delay, &tt); err != nil {
+					return err
+				}
+				f.Delay = &delay
+			default:
+				if err = d.Skip(); err != nil {
+					return err
+				}
+			}
+
+		case xml.EndElement:
+			if tt == start.End() {
+				return nil
+			}
+		}
+	}
+}
+
+// MarshalXML encodes the forwarded stanza in the jabber:client namespace, whatever the stream it was
+// decoded from, as the stanza must not inherit the namespace of the forwarded element.
+func (f Forwarded) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{Name: xml.Name{Space: NSForward, Local: "forwarded"}}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if f.Delay != nil {
+		if err := e.Encode(f.Delay); err != nil {
+			return err
+		}
+	}
+	if f.Stanza != nil {
+		var name xml.Name
+		packet := f.Stanza
+		switch p := f.Stanza.(type) {
+		case Message:
+			name = stanzaName(p.XMLName, "message")
+			p.XMLName = name
+			packet = p
+		case Presence:
+			name = stanzaName(p.XMLName, "presence")
+		case *IQ:
+			name = stanzaName(p.XMLName, "iq")
+		default:
+			if err := e.Encode(packet); err != nil {
+				return err
+			}
+			return e.EncodeToken(start.End())
+		}
+		if err := e.EncodeElement(packet, xml.StartElement{Name: name}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+func stanzaName(name xml.Name, local string) xml.Name {
+	if name.Space == "" || IsStanzaNamespace(name.Space) {
+		name.Space = NSClient
+	}
+	name.Local = local
+	return name
+}
+
+// Message returns the forwarded message, if the forwarded stanza is a message.
+func (f *Forwarded) Message() (Message, bool) {
+	msg, ok := f.Stanza.(Message)
+	return msg, ok
+}
@@ -0,0 +1,197 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestDecodeCarbonReceived(t *testing.T) {
+	raw := `<message xmlns='jabber:client' from='romeo@montague.example' to='romeo@montague.example/home'>
+  <received xmlns='urn:xmpp:carbons:2'>
+    <forwarded xmlns='urn:xmpp:forward:0'>
+      <message xmlns='jabber:client' from='juliet@capulet.example/balcony' to='romeo@montague.example/garden' type='chat'>
+        <body>What man art thou that, thus bescreen'd in night, so stumblest on my counsel?</body>
+        <active xmlns='http://jabber.org/protocol/chatstates'/>
+      </message>
+    </forwarded>
+  </received>
+</message>`
+	var msg stanza.Message
+	if err := xml.Unmarshal([]byte(raw), &msg); err != nil {
+		t.Fatalf("could not parse message: %v", err)
+	}
+	var carbon stanza.CarbonReceived
+	if !msg.Get(&carbon) {
+		t.Fatalf("missing carbon received extension")
+	}
+	inner, ok := carbon.Forwarded.Message()
+	if !ok {
+		t.Fatalf("forwarded stanza should be a message: %#v", carbon.Forwarded.Stanza)
+	}
+	if inner.From != "juliet@capulet.example/balcony" || !strings.HasPrefix(inner.Body, "What man art thou") {
+		t.Errorf("unexpected forwarded message: %+v", inner)
+	}
+	var state stanza.StateActive
+	if !inner.Get(&state) {
+		t.Errorf("extensions of the forwarded message should be decoded")
+	}
+}
+
+func TestDecodeMAMResult(t *testing.T) {
+	raw := `<message xmlns='jabber:client' id='aeb213' to='juliet@capulet.lit/chamber'>
+  <result xmlns='urn:xmpp:mam:2' queryid='f27' id='28482-98726-73623'>
+    <forwarded xmlns='urn:xmpp:forward:0'>
+      <delay xmlns='urn:xmpp:delay' stamp='2010-07-10T23:08:25Z'/>
+      <message xmlns='jabber:client' from='witch@shakespeare.lit' to='macbeth@shakespeare.lit'>
+        <body>Hail to thee</body>
+        <received xmlns='urn:xmpp:carbons:2'>
+          <forwarded xmlns='urn:xmpp:forward:0'>
+            <message xmlns='jabber:client' from='macbeth@shakespeare.lit'><body>Nested</body></message>
+          </forwarded>
+        </received>
+      </message>
+    </forwarded>
+  </result>
+</message>`
+	var msg stanza.Message
+	if err := xml.Unmarshal([]byte(raw), &msg); err != nil {
+		t.Fatalf("could not parse message: %v", err)
+	}
+	var result stanza.MAMResult
+	if !msg.Get(&result) {
+		t.Fatalf("missing MAM result extension")
+	}
+	if result.QueryId != "f27" || result.Id != "28482-98726-73623" {
+		t.Errorf("unexpected result attributes: %+v", result)
+	}
+	archived, ok := result.Forwarded.Message()
+	if !ok || archived.Body != "Hail to thee" {
+		t.Fatalf("unexpected archived message: %#v", result.Forwarded.Stanza)
+	}
+	if result.Forwarded.Delay == nil {
+		t.Fatalf("missing forwarded delay")
+	}
+	if stamp, err := result.Forwarded.Delay.Time(); err != nil || !stamp.Equal(time.Date(2010, 7, 10, 23, 8, 25, 0, time.UTC)) {
+		t.Errorf("unexpected delay stamp %v: %v", result.Forwarded.Delay.Stamp, err)
+	}
+
+	// Extensions are decoded at any nesting depth
+	var carbon stanza.CarbonReceived
+	if !archived.Get(&carbon) {
+		t.Fatalf("missing nested carbon")
+	}
+	if nested, ok := carbon.Forwarded.Message(); !ok || nested.Body != "Nested" {
+		t.Errorf("unexpected nested message: %#v", carbon.Forwarded.Stanza)
+	}
+}
+
+func TestDecodeStanza(t *testing.T) {
+	raw := `<wrapper><iq xmlns='jabber:component:accept' type='get' id='1' from='a@example.com' to='b@example.com'><query xmlns='jabber:iq:version'/></iq></wrapper>`
+	d := xml.NewDecoder(strings.NewReader(raw))
+	if _, err := d.Token(); err != nil {
+		t.Fatalf("could not read wrapper: %v", err)
+	}
+	tok, err := d.Token()
+	if err != nil {
+		t.Fatalf("could not read stanza: %v", err)
+	}
+	packet, err := stanza.DecodeStanza(d, tok.(xml.StartElement))
+	if err != nil {
+		t.Fatalf("could not decode stanza: %v", err)
+	}
+	iq, ok := packet.(*stanza.IQ)
+	if !ok || iq.Id != "1" {
+		t.Fatalf("unexpected packet: %#v", packet)
+	}
+	if _, ok = iq.Payload.(*stanza.Version); !ok {
+		t.Errorf("IQ payload should be decoded: %#v", iq.Payload)
+	}
+
+	start := xml.StartElement{Name: xml.Name{Space: stanza.NSClient, Local: "handshake"}}
+	if _, err = stanza.DecodeStanza(xml.NewDecoder(strings.NewReader("")), start); err == nil {
+		t.Errorf("non stanza elements should be rejected")
+	}
+}
+
+func TestMarshalCarbonSent(t *testing.T) {
+	inner := stanza.NewMessage(stanza.Attrs{From: "romeo@montague.example/home", To: "juliet@capulet.example"})
+	inner.Body = "Neither, fair saint"
+	msg := stanza.NewMessage(stanza.Attrs{To: "romeo@montague.example/garden"})
+	msg.Extensions = append(msg.Extensions, stanza.CarbonSent{Forwarded: stanza.Forwarded{Stanza: inner}})
+
+	data, err := xml.Marshal(msg)
+	if err != nil {
+		t.Fatalf("could not marshal carbon: %v", err)
+	}
+	var parsed stanza.Message
+	if err = xml.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("could not parse marshalled carbon %s: %v", data, err)
+	}
+	var carbon stanza.CarbonSent
+	if !parsed.Get(&carbon) {
+		t.Fatalf("missing carbon sent extension in %s", data)
+	}
+	if m, ok := carbon.Forwarded.Message(); !ok || m.Body != inner.Body {
+		t.Errorf("unexpected forwarded message in %s", data)
+	}
+}
+
+func TestMarshalForwardedDelay(t *testing.T) {
+	inner := stanza.NewMessage(stanza.Attrs{From: "witch@shakespeare.lit", To: "macbeth@shakespeare.lit"})
+	inner.Body = "Hail to thee"
+	delay := stanza.NewDelay(time.Date(2010, 7, 10, 23, 8, 25, 0, time.FixedZone("CEST", 2*3600)), "")
+	fwd := stanza.Forwarded{Delay: &delay, Stanza: inner}
+
+	data, err := xml.Marshal(fwd)
+	if err != nil {
+		t.Fatalf("could not marshal forwarded message: %v", err)
+	}
+	expected := `<forwarded xmlns="urn:xmpp:forward:0"><delay xmlns="urn:xmpp:delay" stamp="2010-07-10T21:08:25Z"></delay><message`
+	if !strings.HasPrefix(string(data), expected) {
+		t.Errorf("delay should be encoded in UTC before the message: %s", data)
+	}
+}
+
+// Forwarded stanzas are always in the jabber:client namespace, whatever the outer stream.
+func TestForwardedStanzaNamespace(t *testing.T) {
+	stream := `<stream:stream xmlns='jabber:server' xmlns:stream='http://etherx.jabber.org/streams' id='s1' version='1.0'>` +
+		`<message from='capulet.example' to='romeo@montague.example/home'>` +
+		`<received xmlns='urn:xmpp:carbons:2'><forwarded xmlns='urn:xmpp:forward:0'>` +
+		`<message xmlns='jabber:client' from='juliet@capulet.example/balcony' type='chat'><body>Wherefore</body></message>` +
+		`</forwarded></received></message>`
+	dec := xml.NewDecoder(strings.NewReader(stream))
+	if _, err := stanza.InitStream(dec); err != nil {
+		t.Fatalf("cannot open stream: %v", err)
+	}
+	packet, err := stanza.NextPacket(dec)
+	if err != nil {
+		t.Fatalf("cannot decode carbon: %v", err)
+	}
+	msg := packet.(stanza.Message)
+	var carbon stanza.CarbonReceived
+	if !msg.Get(&carbon) {
+		t.Fatalf("missing carbon received extension")
+	}
+	inner, ok := carbon.Forwarded.Message()
+	if !ok || inner.XMLName.Space != stanza.NSClient || inner.Body != "Wherefore" {
+		t.Fatalf("unexpected forwarded message: %#v", carbon.Forwarded.Stanza)
+	}
+
+	// Stanzas decoded from a component or server stream are forwarded in jabber:client
+	for _, ns := range []string{stanza.NSServer, stanza.NSComponent} {
+		forwarded := stanza.NewMessage(stanza.Attrs{From: "juliet@capulet.example/balcony"})
+		forwarded.XMLName.Space = ns
+		archived := stanza.MAMResult{Forwarded: stanza.Forwarded{Stanza: forwarded}}
+		data, err := xml.Marshal(archived)
+		if err != nil {
+			t.Fatalf("could not marshal archived message: %v", err)
+		}
+		if !strings.Contains(string(data), `<message xmlns="jabber:client"`) {
+			t.Errorf("%s: forwarded stanza should be in jabber:client: %s", ns, data)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package stanza
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"hash"
+)
+
+/*
+Support for:
+- XEP-0300 - Use of Cryptographic Hash Functions in XMPP: https://xmpp.org/extensions/xep-0300.html
+*/
+
+const (
+	NSHashes = "urn:xmpp:hashes:2"
+
+	// Hash algorithms, from the IANA Hash Function Textual Names registry
+	HashSHA1   = "sha-1"
+	HashSHA256 = "sha-256"
+	HashSHA512 = "sha-512"
+)
+
+var hashFunctions = map[string]func() hash.Hash{
+	HashSHA1:   sha1.New,
+	HashSHA256: sha256.New,
+	HashSHA512: sha512.New,
+}
+
+// Hash is the hash of some data, with the algorithm used to compute it. Elements referencing data
+// carry a list of hashes, so that the receiver can pick the strongest algorithm it supports with
+// SelectBestHash.
+type Hash struct {
+	XMLName xml.Name `xml:"urn:xmpp:hashes:2 hash"`
+	Algo    string   `xml:"algo,attr"`
+	// Value is the base64 encoded hash
+	Value string `xml:",chardata"`
+}
+
+// NewHash computes the hash of the data with the algorithm, one of HashSHA1, HashSHA256 or
+// HashSHA512.
+func NewHash(algo string, data []byte) (Hash, error) {
+	f, ok := hashFunctions[algo]
+	if !ok {
+		return Hash{}, errors.New("unsupported hash algorithm: " + algo)
+	}
+	h := f()
+	h.Write(data)
+	return Hash{
+		XMLName: xml.Name{Space: NSHashes, Local: "hash"},
+		Algo:    algo,
+		Value:   base64.StdEncoding.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+// SelectBestHash returns the hash computed with the preferred algorithm among the supported ones,
+// listed by order of preference.
+func SelectBestHash(hashes []Hash, supported []string) (Hash, bool) {
+	for _, algo := range supported {
+		for _, h := range hashes {
+			if h.Algo == algo {
+				return h, true
+			}
+		}
+	}
+	return Hash{}, false
+}
@@ -0,0 +1,56 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+type hashedFile struct {
+	XMLName xml.Name      `xml:"file"`
+	Hashes  []stanza.Hash `xml:"urn:xmpp:hashes:2 hash"`
+}
+
+func TestHashList(t *testing.T) {
+	data := []byte("Hello")
+	sha256, err := stanza.NewHash(stanza.HashSHA256, data)
+	if err != nil {
+		t.Fatalf("cannot compute hash: %v", err)
+	}
+	sha512, err := stanza.NewHash(stanza.HashSHA512, data)
+	if err != nil {
+		t.Fatalf("cannot compute hash: %v", err)
+	}
+	if sha256.Value != "GF+NsyJx/iX1Yab8k4suJkMG7DBO2lGAB9F2SCY4GWk=" {
+		t.Errorf("unexpected sha-256 hash: %s", sha256.Value)
+	}
+
+	out, err := xml.Marshal(hashedFile{Hashes: []stanza.Hash{sha256, sha512}})
+	if err != nil {
+		t.Fatalf("cannot marshal hashes: %v", err)
+	}
+	if strings.Count(string(out), `<hash xmlns="urn:xmpp:hashes:2"`) != 2 {
+		t.Errorf("unexpected hashes: %s", out)
+	}
+
+	var file hashedFile
+	if err = xml.Unmarshal(out, &file); err != nil {
+		t.Fatalf("cannot unmarshal hashes: %v", err)
+	}
+	if len(file.Hashes) != 2 || file.Hashes[0] != sha256 || file.Hashes[1] != sha512 {
+		t.Fatalf("unexpected hashes: %+v", file.Hashes)
+	}
+
+	best, ok := stanza.SelectBestHash(file.Hashes, []string{"sha3-256", stanza.HashSHA512, stanza.HashSHA256})
+	if !ok || best.Algo != stanza.HashSHA512 {
+		t.Errorf("unexpected best hash: %+v", best)
+	}
+	if _, ok = stanza.SelectBestHash(file.Hashes, []string{stanza.HashSHA1}); ok {
+		t.Error("no hash should be selected")
+	}
+	if _, err = stanza.NewHash("md5", data); err == nil {
+		t.Error("unsupported algorithm should be rejected")
+	}
+}
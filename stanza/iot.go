@@ -44,5 +44,5 @@ func (c *ControlSetResponse) GetSet() *ResultSet {
 // Registry init
 
 func init() {
-	TypeRegistry.MapExtension(PKTIQ, xml.Name{Space: "urn:xmpp:iot:control", Local: "set"}, ControlSet{})
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: "urn:xmpp:iot:control", Local: "set"}, ControlSet{})
 }
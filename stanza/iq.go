@@ -113,6 +113,9 @@ func (iq *IQ) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 
 		switch tt := t.(type) {
 		case xml.StartElement:
+			if tt.Name.Space == NSStream {
+				return interruptedStanza(d, start, tt)
+			}
 			if tt.Name.Local == "error" {
 				var xmppError Err
 				err = d.DecodeElement(&xmppError, &tt)
@@ -0,0 +1,142 @@
+package stanza
+
+import (
+	"encoding/xml"
+)
+
+/*
+Support for:
+- XEP-0191 - Blocking Command: https://xmpp.org/extensions/xep-0191.html
+- XEP-0377 - Spam Reporting: https://xmpp.org/extensions/xep-0377.html
+*/
+
+const (
+	NSBlocking  = "urn:xmpp:blocking"
+	NSReporting = "urn:xmpp:reporting:1"
+
+	// Reasons to report a blocked JID
+	ReportReasonSpam  = "urn:xmpp:reporting:spam"
+	ReportReasonAbuse = "urn:xmpp:reporting:abuse"
+)
+
+// BlockList is the list of blocked JIDs, returned by the server when it is requested with an empty
+// BlockList payload.
+type BlockList struct {
+	XMLName xml.Name    `xml:"urn:xmpp:blocking blocklist"`
+	Items   []BlockItem `xml:"item"`
+}
+
+func (b *BlockList) Namespace() string {
+	return b.XMLName.Space
+}
+
+func (b *BlockList) GetSet() *ResultSet {
+	return nil
+}
+
+// Block requests the server to block the JIDs of the items. It is also pushed by the server to all
+// the resources of the account when a JID is blocked.
+type Block struct {
+	XMLName xml.Name    `xml:"urn:xmpp:blocking block"`
+	Items   []BlockItem `xml:"item"`
+}
+
+func (b *Block) Namespace() string {
+	return b.XMLName.Space
+}
+
+func (b *Block) GetSet() *ResultSet {
+	return nil
+}
+
+// Unblock requests the server to unblock the JIDs of the items. Without items, all JIDs are unblocked.
+type Unblock struct {
+	XMLName xml.Name    `xml:"urn:xmpp:blocking unblock"`
+	Items   []BlockItem `xml:"item"`
+}
+
+func (u *Unblock) Namespace() string {
+	return u.XMLName.Space
+}
+
+func (u *Unblock) GetSet() *ResultSet {
+	return nil
+}
+
+// BlockItem is a blocked JID. When blocking, the JID can be reported as a spammer or an abuser.
+type BlockItem struct {
+	XMLName xml.Name `xml:"item"`
+	JID     string   `xml:"jid,attr"`
+	Report  *Report  `xml:"report,omitempty"`
+}
+
+// Report tells the server why the JID is blocked. The offending messages can be referenced by
+// the stanza-id assigned to them by the server or the room.
+type Report struct {
+	XMLName   xml.Name   `xml:"urn:xmpp:reporting:1 report"`
+	Reason    string     `xml:"reason,attr"`
+	StanzaIds []StanzaId `xml:"urn:xmpp:sid:0 stanza-id"`
+	Text      string     `xml:"text,omitempty"`
+}
+
+// ---------------
+// Builder helpers
+
+// NewBlockIQ builds an IQ to block the JIDs, without reporting them.
+func NewBlockIQ(jids ...string) (*IQ, error) {
+	iq, err := NewIQ(Attrs{Type: IQTypeSet})
+	if err != nil {
+		return nil, err
+	}
+	block := &Block{XMLName: xml.Name{Space: NSBlocking, Local: "block"}}
+	for _, jid := range jids {
+		block.Items = append(block.Items, BlockItem{JID: jid})
+	}
+	iq.Payload = block
+	return iq, nil
+}
+
+// NewBlockReportIQ builds an IQ to block the JID and report it, for instance with ReportReasonSpam.
+// The ids are the stanza-ids of the offending messages, as assigned by the entity by.
+func NewBlockReportIQ(jid, reason, text, by string, ids ...string) (*IQ, error) {
+	iq, err := NewBlockIQ(jid)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{Reason: reason, Text: text}
+	for _, id := range ids {
+		report.StanzaIds = append(report.StanzaIds, StanzaId{Id: id, By: by})
+	}
+	iq.Payload.(*Block).Items[0].Report = report
+	return iq, nil
+}
+
+// NewUnblockIQ builds an IQ to unblock the JIDs. Without JIDs, all blocked JIDs are unblocked.
+func NewUnblockIQ(jids ...string) (*IQ, error) {
+	iq, err := NewIQ(Attrs{Type: IQTypeSet})
+	if err != nil {
+		return nil, err
+	}
+	unblock := &Unblock{XMLName: xml.Name{Space: NSBlocking, Local: "unblock"}}
+	for _, jid := range jids {
+		unblock.Items = append(unblock.Items, BlockItem{JID: jid})
+	}
+	iq.Payload = unblock
+	return iq, nil
+}
+
+// NewBlockListIQ builds an IQ to request the list of blocked JIDs.
+func NewBlockListIQ() (*IQ, error) {
+	iq, err := NewIQ(Attrs{Type: IQTypeGet})
+	if err != nil {
+		return nil, err
+	}
+	iq.Payload = &BlockList{XMLName: xml.Name{Space: NSBlocking, Local: "blocklist"}}
+	return iq, nil
+}
+
+func init() {
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: NSBlocking, Local: "blocklist"}, BlockList{})
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: NSBlocking, Local: "block"}, Block{})
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: NSBlocking, Local: "unblock"}, Unblock{})
+}
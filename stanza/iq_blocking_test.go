@@ -0,0 +1,67 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestMarshalBlockReport(t *testing.T) {
+	iq, err := stanza.NewBlockReportIQ("spammer@example.com", stanza.ReportReasonSpam, "Buy my stuff",
+		"romeo@example.net", "28482-98726-73623")
+	if err != nil {
+		t.Fatalf("could not create block IQ: %v", err)
+	}
+	out, err := xml.Marshal(iq)
+	if err != nil {
+		t.Fatalf("could not marshal block IQ: %v", err)
+	}
+	expected := `<block xmlns="urn:xmpp:blocking"><item jid="spammer@example.com">` +
+		`<report xmlns="urn:xmpp:reporting:1" reason="urn:xmpp:reporting:spam">` +
+		`<stanza-id xmlns="urn:xmpp:sid:0" id="28482-98726-73623" by="romeo@example.net"></stanza-id>` +
+		`<text>Buy my stuff</text></report></item></block>`
+	if !strings.Contains(string(out), expected) {
+		t.Errorf("report should be nested in the block item:\n%s", out)
+	}
+}
+
+func TestMarshalBlockWithoutReport(t *testing.T) {
+	iq, err := stanza.NewBlockIQ("romeo@montague.net", "iago@shakespeare.lit")
+	if err != nil {
+		t.Fatalf("could not create block IQ: %v", err)
+	}
+	out, err := xml.Marshal(iq)
+	if err != nil {
+		t.Fatalf("could not marshal block IQ: %v", err)
+	}
+	if strings.Contains(string(out), "report") || strings.Count(string(out), "<item ") != 2 {
+		t.Errorf("unexpected block IQ: %s", out)
+	}
+}
+
+func TestUnmarshalBlockReport(t *testing.T) {
+	raw := `<iq type='set' id='block1'>
+  <block xmlns='urn:xmpp:blocking'>
+    <item jid='abuser@example.com'>
+      <report xmlns='urn:xmpp:reporting:1' reason='urn:xmpp:reporting:abuse'>
+        <stanza-id xmlns='urn:xmpp:sid:0' by='romeo@example.net' id='1'/>
+        <stanza-id xmlns='urn:xmpp:sid:0' by='romeo@example.net' id='2'/>
+      </report>
+    </item>
+  </block>
+</iq>`
+	var iq stanza.IQ
+	if err := xml.Unmarshal([]byte(raw), &iq); err != nil {
+		t.Fatalf("could not unmarshal block IQ: %v", err)
+	}
+	block, ok := iq.Payload.(*stanza.Block)
+	if !ok || len(block.Items) != 1 {
+		t.Fatalf("unexpected payload: %+v", iq.Payload)
+	}
+	report := block.Items[0].Report
+	if report == nil || report.Reason != stanza.ReportReasonAbuse || len(report.StanzaIds) != 2 || report.StanzaIds[1].Id != "2" {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
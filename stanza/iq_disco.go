@@ -20,6 +20,7 @@ type DiscoInfo struct {
 	Node      string     `xml:"node,attr,omitempty"`
 	Identity  []Identity `xml:"identity"`
 	Features  []Feature  `xml:"feature"`
+	Forms     []Form     `xml:"jabber:x:data x"` // Service discovery extensions (XEP-0128)
 	ResultSet *ResultSet `xml:"set,omitempty"`
 }
 
@@ -81,6 +82,26 @@ func (d *DiscoInfo) SetFeatures(namespace ...string) *DiscoInfo {
 	return d
 }
 
+// HasFeature tells if the entity advertises the given feature.
+func (d *DiscoInfo) HasFeature(ns string) bool {
+	for _, f := range d.Features {
+		if f.Var == ns {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtendedForm returns the service discovery extension form with the given FORM_TYPE, or nil.
+func (d *DiscoInfo) ExtendedForm(formType string) *Form {
+	for i := range d.Forms {
+		if d.Forms[i].FormType() == formType {
+			return &d.Forms[i]
+		}
+	}
+	return nil
+}
+
 // -----------
 // SubElements
 
@@ -89,6 +110,7 @@ type Identity struct {
 	Name     string   `xml:"name,attr,omitempty"`
 	Category string   `xml:"category,attr,omitempty"`
 	Type     string   `xml:"type,attr,omitempty"`
+	Lang     string   `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
 }
 
 type Feature struct {
@@ -158,6 +180,6 @@ type DiscoItem struct {
 // Registry init
 
 func init() {
-	TypeRegistry.MapExtension(PKTIQ, xml.Name{Space: NSDiscoInfo, Local: "query"}, DiscoInfo{})
-	TypeRegistry.MapExtension(PKTIQ, xml.Name{Space: NSDiscoItems, Local: "query"}, DiscoItems{})
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: NSDiscoInfo, Local: "query"}, DiscoInfo{})
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: NSDiscoItems, Local: "query"}, DiscoItems{})
 }
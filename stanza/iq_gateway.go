@@ -0,0 +1,48 @@
+package stanza
+
+import (
+	"encoding/xml"
+)
+
+/*
+Support for:
+- XEP-0100 - Gateway Interaction: https://xmpp.org/extensions/xep-0100.html
+  Gateways use the jabber:iq:gateway protocol (XEP-0100 - 6.3) to translate legacy identifiers into JIDs.
+*/
+
+const NSGateway = "jabber:iq:gateway"
+
+// Gateway is the payload of jabber:iq:gateway IQs. The gateway answers a get with the description
+// and the name of the legacy identifier in Desc and Prompt. The identifier is then sent as Prompt in
+// a set, answered with the matching JID.
+type Gateway struct {
+	XMLName xml.Name `xml:"jabber:iq:gateway query"`
+	Desc    string   `xml:"desc,omitempty"`
+	Prompt  string   `xml:"prompt,omitempty"`
+	JID     string   `xml:"jid,omitempty"`
+	// Result sets
+	ResultSet *ResultSet `xml:"set,omitempty"`
+}
+
+func (g *Gateway) Namespace() string {
+	return g.XMLName.Space
+}
+
+func (g *Gateway) GetSet() *ResultSet {
+	return g.ResultSet
+}
+
+// ---------------
+// Builder helpers
+
+// Gateway sets a gateway payload on the IQ, empty to request the prompt, or with the legacy
+// identifier to translate it.
+func (iq *IQ) Gateway(identifier string) *Gateway {
+	g := Gateway{XMLName: xml.Name{Space: NSGateway, Local: "query"}, Prompt: identifier}
+	iq.Payload = &g
+	return &g
+}
+
+func init() {
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: NSGateway, Local: "query"}, Gateway{})
+}
@@ -0,0 +1,41 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestMarshalGatewayTranslation(t *testing.T) {
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeSet, To: "irc.shakespeare.lit", Id: "gate2"})
+	if err != nil {
+		t.Fatalf("could not create IQ: %v", err)
+	}
+	iq.Gateway("juliet%irc.libera.chat")
+	out, err := xml.Marshal(iq)
+	if err != nil {
+		t.Fatalf("could not marshal gateway IQ: %v", err)
+	}
+	if !strings.Contains(string(out), `<query xmlns="jabber:iq:gateway"><prompt>juliet%irc.libera.chat</prompt></query>`) {
+		t.Errorf("unexpected gateway IQ: %s", out)
+	}
+}
+
+func TestUnmarshalGatewayPrompt(t *testing.T) {
+	raw := `<iq type='result' from='aim.shakespeare.lit' id='gate1'>
+  <query xmlns='jabber:iq:gateway'>
+    <desc>Please enter the AOL Screen Name of the person you would like to contact</desc>
+    <prompt>Contact ID</prompt>
+  </query>
+</iq>`
+	var iq stanza.IQ
+	if err := xml.Unmarshal([]byte(raw), &iq); err != nil {
+		t.Fatalf("could not unmarshal gateway prompt: %v", err)
+	}
+	g, ok := iq.Payload.(*stanza.Gateway)
+	if !ok || g.Prompt != "Contact ID" || !strings.HasPrefix(g.Desc, "Please enter") {
+		t.Errorf("unexpected gateway prompt: %+v", iq.Payload)
+	}
+}
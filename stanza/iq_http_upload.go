@@ -0,0 +1,86 @@
+package stanza
+
+import (
+	"encoding/xml"
+)
+
+// ============================================================================
+// HTTP File Upload (XEP-0363)
+
+const NSHTTPUpload = "urn:xmpp:http:upload:0"
+
+// HTTPUploadRequest is the payload used to request an upload slot from the upload service.
+// See XEP-0363 - 4. Requesting a slot
+type HTTPUploadRequest struct {
+	XMLName     xml.Name `xml:"urn:xmpp:http:upload:0 request"`
+	Filename    string   `xml:"filename,attr"`
+	Size        int64    `xml:"size,attr"`
+	ContentType string   `xml:"content-type,attr,omitempty"`
+	// Result sets
+	ResultSet *ResultSet `xml:"set,omitempty"`
+}
+
+func (r *HTTPUploadRequest) Namespace() string {
+	return r.XMLName.Space
+}
+
+func (r *HTTPUploadRequest) GetSet() *ResultSet {
+	return r.ResultSet
+}
+
+// HTTPUploadSlot is the upload slot returned by the upload service. The file is uploaded with a PUT
+// request on the put URL, with its headers, and shared with the get URL.
+type HTTPUploadSlot struct {
+	XMLName xml.Name          `xml:"urn:xmpp:http:upload:0 slot"`
+	Put     HTTPUploadPutSlot `xml:"put"`
+	Get     HTTPUploadGetSlot `xml:"get"`
+	// Result sets
+	ResultSet *ResultSet `xml:"set,omitempty"`
+}
+
+func (s *HTTPUploadSlot) Namespace() string {
+	return s.XMLName.Space
+}
+
+func (s *HTTPUploadSlot) GetSet() *ResultSet {
+	return s.ResultSet
+}
+
+type HTTPUploadPutSlot struct {
+	URL     string             `xml:"url,attr"`
+	Headers []HTTPUploadHeader `xml:"header"`
+}
+
+type HTTPUploadGetSlot struct {
+	URL string `xml:"url,attr"`
+}
+
+// HTTPUploadHeader is a header to add to the PUT request. Only Authorization, Cookie and Expires
+// headers are allowed by the specification.
+type HTTPUploadHeader struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
+
+// ---------------
+// Builder helpers
+
+// HTTPUploadRequest builds a request for a slot to upload a file.
+func (iq *IQ) HTTPUploadRequest(filename string, size int64, contentType string) *HTTPUploadRequest {
+	r := HTTPUploadRequest{
+		XMLName:     xml.Name{Space: NSHTTPUpload, Local: "request"},
+		Filename:    filename,
+		Size:        size,
+		ContentType: contentType,
+	}
+	iq.Payload = &r
+	return &r
+}
+
+// ============================================================================
+// Registry init
+
+func init() {
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: NSHTTPUpload, Local: "request"}, HTTPUploadRequest{})
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: NSHTTPUpload, Local: "slot"}, HTTPUploadSlot{})
+}
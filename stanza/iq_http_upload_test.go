@@ -0,0 +1,49 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestHTTPUploadRequest(t *testing.T) {
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, Id: "step_03", To: "upload.montague.tld"})
+	if err != nil {
+		t.Fatalf("failed to create IQ: %v", err)
+	}
+	iq.HTTPUploadRequest("très cool.jpg", 23456, "image/jpeg")
+	data, err := xml.Marshal(iq)
+	if err != nil {
+		t.Fatalf("cannot marshal IQ: %v", err)
+	}
+	if !strings.Contains(string(data), `<request xmlns="urn:xmpp:http:upload:0" filename="très cool.jpg" size="23456" content-type="image/jpeg"></request>`) {
+		t.Errorf("unexpected request: %s", data)
+	}
+}
+
+func TestUnmarshalHTTPUploadSlot(t *testing.T) {
+	// XEP-0363 - Example 5
+	response := `<iq from='upload.montague.tld' id='step_03' to='romeo@montague.tld/garden' type='result'>
+  <slot xmlns='urn:xmpp:http:upload:0'>
+    <put url='https://upload.montague.tld/4a771ac1-f0b2-4a4a-9700-f2a26fa2bb67/tr%C3%A8s%20cool.jpg'>
+      <header name='Authorization'>Basic Base64String==</header>
+      <header name='Cookie'>foo=bar; user=romeo</header>
+    </put>
+    <get url='https://download.montague.tld/4a771ac1-f0b2-4a4a-9700-f2a26fa2bb67/tr%C3%A8s%20cool.jpg' />
+  </slot>
+</iq>`
+	var iq stanza.IQ
+	if err := xml.Unmarshal([]byte(response), &iq); err != nil {
+		t.Fatalf("cannot unmarshal IQ: %v", err)
+	}
+	slot, ok := iq.Payload.(*stanza.HTTPUploadSlot)
+	if !ok {
+		t.Fatalf("unexpected payload: %#v", iq.Payload)
+	}
+	if !strings.HasPrefix(slot.Put.URL, "https://upload.montague.tld/") || !strings.HasPrefix(slot.Get.URL, "https://download.") ||
+		len(slot.Put.Headers) != 2 || slot.Put.Headers[1].Name != "Cookie" || slot.Put.Headers[1].Value != "foo=bar; user=romeo" {
+		t.Errorf("unexpected slot: %+v", slot)
+	}
+}
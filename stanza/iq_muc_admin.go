@@ -0,0 +1,67 @@
+package stanza
+
+import (
+	"encoding/xml"
+	"errors"
+)
+
+/*
+Support for:
+- XEP-0045 - Multi-User Chat: muc#admin item lists, used by moderators and admins to retrieve
+  the users with a given affiliation or role.
+  https://xmpp.org/extensions/xep-0045.html#modifymember
+*/
+
+const NSMucAdmin = "http://jabber.org/protocol/muc#admin"
+
+// MUC affiliations
+const (
+	MucAffiliationOwner   = "owner"
+	MucAffiliationAdmin   = "admin"
+	MucAffiliationMember  = "member"
+	MucAffiliationOutcast = "outcast"
+	MucAffiliationNone    = "none"
+)
+
+// MUC roles
+const (
+	MucRoleModerator   = "moderator"
+	MucRoleParticipant = "participant"
+	MucRoleVisitor     = "visitor"
+	MucRoleNone        = "none"
+)
+
+// MucAdmin is the payload of muc#admin IQs.
+type MucAdmin struct {
+	XMLName xml.Name  `xml:"http://jabber.org/protocol/muc#admin query"`
+	Items   []MucItem `xml:"item"`
+}
+
+func (m *MucAdmin) Namespace() string {
+	return m.XMLName.Space
+}
+
+func (m *MucAdmin) GetSet() *ResultSet {
+	return nil
+}
+
+// NewMucAffiliationListRequest builds the request for the list of users with the affiliation.
+// See XEP-0045 - 9.5 Modifying the Member List
+func NewMucAffiliationListRequest(roomJid, affiliation string) (*IQ, error) {
+	if roomJid == "" || affiliation == "" {
+		return nil, errors.New("a room JID and an affiliation are required to request an affiliation list")
+	}
+	iq, err := NewIQ(Attrs{Type: IQTypeGet, To: roomJid})
+	if err != nil {
+		return nil, err
+	}
+	iq.Payload = &MucAdmin{
+		XMLName: xml.Name{Space: NSMucAdmin, Local: "query"},
+		Items:   []MucItem{{Affiliation: affiliation}},
+	}
+	return iq, nil
+}
+
+func init() {
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: NSMucAdmin, Local: "query"}, MucAdmin{})
+}
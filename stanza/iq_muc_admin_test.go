@@ -0,0 +1,43 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestMucAffiliationListRequest(t *testing.T) {
+	iq, err := stanza.NewMucAffiliationListRequest("coven@chat.shakespeare.lit", stanza.MucAffiliationMember)
+	if err != nil {
+		t.Fatalf("could not create request: %v", err)
+	}
+	out, err := xml.Marshal(iq)
+	if err != nil {
+		t.Fatalf("could not marshal request: %v", err)
+	}
+	if !strings.Contains(string(out), `<query xmlns="http://jabber.org/protocol/muc#admin"><item affiliation="member"></item></query>`) {
+		t.Errorf("unexpected request: %s", out)
+	}
+
+	if _, err = stanza.NewMucAffiliationListRequest("coven@chat.shakespeare.lit", ""); err == nil {
+		t.Errorf("an affiliation should be required")
+	}
+}
+
+func TestUnmarshalMucAffiliationList(t *testing.T) {
+	raw := `<iq from='coven@chat.shakespeare.lit' id='member3' type='result'>
+  <query xmlns='http://jabber.org/protocol/muc#admin'>
+    <item affiliation='member' jid='hag66@shakespeare.lit' nick='thirdwitch' role='participant'/>
+  </query>
+</iq>`
+	var iq stanza.IQ
+	if err := xml.Unmarshal([]byte(raw), &iq); err != nil {
+		t.Fatalf("could not unmarshal list: %v", err)
+	}
+	admin, ok := iq.Payload.(*stanza.MucAdmin)
+	if !ok || len(admin.Items) != 1 || admin.Items[0].Nick != "thirdwitch" || admin.Items[0].Jid != "hag66@shakespeare.lit" {
+		t.Errorf("unexpected payload: %+v", iq.Payload)
+	}
+}
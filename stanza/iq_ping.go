@@ -0,0 +1,35 @@
+package stanza
+
+import "encoding/xml"
+
+// ============================================================================
+// XMPP Ping (XEP-0199)
+
+const NSPing = "urn:xmpp:ping"
+
+// Ping is the payload of ping IQs. Entities supporting pings answer with an empty result.
+type Ping struct {
+	XMLName xml.Name `xml:"urn:xmpp:ping ping"`
+}
+
+func (p *Ping) Namespace() string {
+	return p.XMLName.Space
+}
+
+func (p *Ping) GetSet() *ResultSet {
+	return nil
+}
+
+// ---------------
+// Builder helpers
+
+// Ping builds a ping payload
+func (iq *IQ) Ping() *Ping {
+	p := Ping{XMLName: xml.Name{Space: NSPing, Local: "ping"}}
+	iq.Payload = &p
+	return &p
+}
+
+func init() {
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: NSPing, Local: "ping"}, Ping{})
+}
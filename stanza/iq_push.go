@@ -0,0 +1,77 @@
+package stanza
+
+import (
+	"encoding/xml"
+)
+
+/*
+Support for:
+- XEP-0357 - Push Notifications: https://xmpp.org/extensions/xep-0357.html
+*/
+
+const (
+	NSPush = "urn:xmpp:push:0"
+	// PushSummaryFormType is the FORM_TYPE of the notification summary published by the server
+	PushSummaryFormType = "urn:xmpp:push:summary"
+)
+
+// PushEnable enables the push notifications of the account, published by the server on the node
+// of the app server. The form holds the publish options, for example the secret of the device.
+type PushEnable struct {
+	XMLName xml.Name `xml:"urn:xmpp:push:0 enable"`
+	JID     string   `xml:"jid,attr"`
+	Node    string   `xml:"node,attr,omitempty"`
+	Form    *Form    `xml:"jabber:x:data x,omitempty"`
+}
+
+func (p *PushEnable) Namespace() string {
+	return p.XMLName.Space
+}
+
+func (p *PushEnable) GetSet() *ResultSet {
+	return nil
+}
+
+// PushDisable disables the push notifications sent to the node of the app server, or to all its
+// nodes when Node is empty.
+type PushDisable struct {
+	XMLName xml.Name `xml:"urn:xmpp:push:0 disable"`
+	JID     string   `xml:"jid,attr"`
+	Node    string   `xml:"node,attr,omitempty"`
+}
+
+func (p *PushDisable) Namespace() string {
+	return p.XMLName.Space
+}
+
+func (p *PushDisable) GetSet() *ResultSet {
+	return nil
+}
+
+// ---------------
+// Builder helpers
+
+// NewPushEnableIQ builds an IQ enabling the push notifications, with the publish options if any.
+func NewPushEnableIQ(jid, node string, options *Form) (*IQ, error) {
+	iq, err := NewIQ(Attrs{Type: IQTypeSet})
+	if err != nil {
+		return nil, err
+	}
+	iq.Payload = &PushEnable{XMLName: xml.Name{Space: NSPush, Local: "enable"}, JID: jid, Node: node, Form: options}
+	return iq, nil
+}
+
+// NewPushDisableIQ builds an IQ disabling the push notifications.
+func NewPushDisableIQ(jid, node string) (*IQ, error) {
+	iq, err := NewIQ(Attrs{Type: IQTypeSet})
+	if err != nil {
+		return nil, err
+	}
+	iq.Payload = &PushDisable{XMLName: xml.Name{Space: NSPush, Local: "disable"}, JID: jid, Node: node}
+	return iq, nil
+}
+
+func init() {
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: NSPush, Local: "enable"}, PushEnable{})
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: NSPush, Local: "disable"}, PushDisable{})
+}
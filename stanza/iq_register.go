@@ -0,0 +1,62 @@
+package stanza
+
+import (
+	"encoding/xml"
+)
+
+/*
+Support for:
+- XEP-0077 - In-Band Registration: https://xmpp.org/extensions/xep-0077.html
+- XEP-0158 - CAPTCHA Forms: https://xmpp.org/extensions/xep-0158.html
+*/
+
+const NSRegister = "jabber:iq:register"
+
+// Register is the registration request, or the registration form returned by the service. Services
+// may require a CAPTCHA to be solved: its image is then referenced by the media element of a field
+// of the form, and sent in Data.
+type Register struct {
+	XMLName      xml.Name  `xml:"jabber:iq:register query"`
+	Instructions string    `xml:"instructions,omitempty"`
+	Registered   *struct{} `xml:"registered"`
+	Username     string    `xml:"username,omitempty"`
+	Password     string    `xml:"password,omitempty"`
+	Email        string    `xml:"email,omitempty"`
+	Form         *Form     `xml:"jabber:x:data x,omitempty"`
+	Data         []BobData `xml:"urn:xmpp:bob data"`
+}
+
+func (r *Register) Namespace() string {
+	return r.XMLName.Space
+}
+
+func (r *Register) GetSet() *ResultSet {
+	return nil
+}
+
+// ---------------
+// Builder helpers
+
+// NewRegisterFormIQ builds an IQ requesting the registration form of the service.
+func NewRegisterFormIQ(service string) (*IQ, error) {
+	iq, err := NewIQ(Attrs{Type: IQTypeGet, To: service})
+	if err != nil {
+		return nil, err
+	}
+	iq.Payload = &Register{XMLName: xml.Name{Space: NSRegister, Local: "query"}}
+	return iq, nil
+}
+
+// NewRegisterSubmitIQ builds an IQ submitting the filled registration form.
+func NewRegisterSubmitIQ(service string, form *Form) (*IQ, error) {
+	iq, err := NewIQ(Attrs{Type: IQTypeSet, To: service})
+	if err != nil {
+		return nil, err
+	}
+	iq.Payload = &Register{XMLName: xml.Name{Space: NSRegister, Local: "query"}, Form: form}
+	return iq, nil
+}
+
+func init() {
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: NSRegister, Local: "query"}, Register{})
+}
@@ -27,6 +27,14 @@ const (
 	// SubscriptionBoth indicates the user and the contact have subscriptions to each
 	// other's presence (also called a "mutual subscription")
 	SubscriptionBoth = "both"
+
+	// SubscriptionRemove is set by the client to remove an item from the roster, and by the server
+	// in the roster push notifying the removal
+	SubscriptionRemove = "remove"
+
+	// NSPreApproval is the namespace of the stream feature advertising subscription pre-approval
+	// (RFC 6121 - 3.4)
+	NSPreApproval = "urn:xmpp:features:pre-approval"
 )
 
 // ----------
@@ -89,7 +97,19 @@ type RosterItem struct {
 	Ask          string   `xml:"ask,attr,omitempty"`
 	Name         string   `xml:"name,attr,omitempty"`
 	Subscription string   `xml:"subscription,attr,omitempty"`
-	Groups       []string `xml:"group"`
+	// Approved is true when the user pre-approved a subscription request from the contact
+	Approved bool     `xml:"approved,attr,omitempty"`
+	Groups   []string `xml:"group"`
+}
+
+// DoesPreApproval tells if the server supports subscription pre-approval.
+func (sf *StreamFeatures) DoesPreApproval() bool {
+	for _, name := range sf.Any {
+		if name.Space == NSPreApproval && name.Local == "sub" {
+			return true
+		}
+	}
+	return false
 }
 
 // ---------------
@@ -121,6 +141,5 @@ func (r *RosterItems) AddItem(jid, subscription, ask, name string, groups []stri
 // Registry init
 
 func init() {
-	TypeRegistry.MapExtension(PKTIQ, xml.Name{Space: NSRoster, Local: "query"}, Roster{})
-	TypeRegistry.MapExtension(PKTIQ, xml.Name{Space: NSRoster, Local: "query"}, RosterItems{})
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: NSRoster, Local: "query"}, RosterItems{})
 }
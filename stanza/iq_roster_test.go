@@ -94,6 +94,38 @@ func TestRosterBuilder(t *testing.T) {
 	}
 }
 
+func TestRosterItemApproved(t *testing.T) {
+	push := `<iq type='set' id='a78b4q6ha463'>
+  <query xmlns='jabber:iq:roster'>
+    <item jid='juliet@example.com' approved='true' subscription='none'/>
+  </query>
+</iq>`
+	var iq IQ
+	if err := xml.Unmarshal([]byte(push), &iq); err != nil {
+		t.Fatalf("cannot unmarshal roster push: %s", err)
+	}
+	items, ok := iq.Payload.(*RosterItems)
+	if !ok || len(items.Items) != 1 || !items.Items[0].Approved {
+		t.Fatalf("item should be pre-approved: %#v", iq.Payload)
+	}
+}
+
+func TestPreApprovalFeature(t *testing.T) {
+	features := `<stream:features xmlns:stream='http://etherx.jabber.org/streams'>
+  <sub xmlns='urn:xmpp:features:pre-approval'/>
+</stream:features>`
+	var sf StreamFeatures
+	if err := xml.Unmarshal([]byte(features), &sf); err != nil {
+		t.Fatalf("cannot unmarshal features: %s", err)
+	}
+	if !sf.DoesPreApproval() {
+		t.Error("pre-approval should be supported")
+	}
+	if (&StreamFeatures{}).DoesPreApproval() {
+		t.Error("pre-approval should not be supported without feature")
+	}
+}
+
 func checkMarshalling(t *testing.T, iq *IQ) (*IQ, error) {
 	// Marshall
 	data, err := xml.Marshal(iq)
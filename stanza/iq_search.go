@@ -0,0 +1,60 @@
+package stanza
+
+import (
+	"encoding/xml"
+)
+
+/*
+Support for:
+- XEP-0055 - Jabber Search: https://xmpp.org/extensions/xep-0055.html
+*/
+
+const NSSearch = "jabber:iq:search"
+
+// Search is the payload of search IQs. Services either use the fixed fields First, Last, Nick and
+// Email, or an extended search with a data form, that also holds the results.
+type Search struct {
+	XMLName      xml.Name     `xml:"jabber:iq:search query"`
+	Instructions string       `xml:"instructions,omitempty"`
+	First        string       `xml:"first,omitempty"`
+	Last         string       `xml:"last,omitempty"`
+	Nick         string       `xml:"nick,omitempty"`
+	Email        string       `xml:"email,omitempty"`
+	Items        []SearchItem `xml:"item"`
+	Form         *Form        `xml:"jabber:x:data x"`
+	// Result sets
+	ResultSet *ResultSet `xml:"set,omitempty"`
+}
+
+func (s *Search) Namespace() string {
+	return s.XMLName.Space
+}
+
+func (s *Search) GetSet() *ResultSet {
+	return s.ResultSet
+}
+
+// SearchItem is a search result, when the service does not use data forms.
+type SearchItem struct {
+	XMLName xml.Name `xml:"item"`
+	JID     string   `xml:"jid,attr"`
+	First   string   `xml:"first,omitempty"`
+	Last    string   `xml:"last,omitempty"`
+	Nick    string   `xml:"nick,omitempty"`
+	Email   string   `xml:"email,omitempty"`
+}
+
+// ---------------
+// Builder helpers
+
+// Search sets an empty search payload on the IQ, to request the search fields or to be filled
+// with the search criteria.
+func (iq *IQ) Search() *Search {
+	s := Search{XMLName: xml.Name{Space: NSSearch, Local: "query"}}
+	iq.Payload = &s
+	return &s
+}
+
+func init() {
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: NSSearch, Local: "query"}, Search{})
+}
@@ -0,0 +1,40 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestMarshalSearch(t *testing.T) {
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeSet, To: "characters.shakespeare.lit", Id: "search2"})
+	if err != nil {
+		t.Fatalf("could not create IQ: %v", err)
+	}
+	iq.Search().Last = "Capulet"
+	out, err := xml.Marshal(iq)
+	if err != nil {
+		t.Fatalf("could not marshal search IQ: %v", err)
+	}
+	if !strings.Contains(string(out), `<query xmlns="jabber:iq:search"><last>Capulet</last></query>`) {
+		t.Errorf("unexpected search IQ: %s", out)
+	}
+}
+
+func TestUnmarshalSearchResult(t *testing.T) {
+	raw := `<iq type='result' from='characters.shakespeare.lit' id='search2'>
+  <query xmlns='jabber:iq:search'>
+    <item jid='juliet@capulet.com'><first>Juliet</first><last>Capulet</last></item>
+  </query>
+</iq>`
+	var iq stanza.IQ
+	if err := xml.Unmarshal([]byte(raw), &iq); err != nil {
+		t.Fatalf("could not unmarshal search result: %v", err)
+	}
+	search, ok := iq.Payload.(*stanza.Search)
+	if !ok || len(search.Items) != 1 || search.Items[0].JID != "juliet@capulet.com" || search.Items[0].Last != "Capulet" {
+		t.Errorf("unexpected search result: %+v", iq.Payload)
+	}
+}
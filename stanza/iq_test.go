@@ -104,7 +104,7 @@ func TestErrorTag(t *testing.T) {
 		Code:    503,
 		Type:    "cancel",
 		Reason:  "service-unavailable",
-		Text:    "User session not found",
+		Text:    stanza.NewLocalizedText("User session not found"),
 	}
 
 	data, err := xml.Marshal(xError)
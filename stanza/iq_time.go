@@ -0,0 +1,45 @@
+package stanza
+
+import (
+	"encoding/xml"
+)
+
+/*
+Support for:
+- XEP-0202 - Entity Time: https://xmpp.org/extensions/xep-0202.html
+*/
+
+const NSTime = "urn:xmpp:time"
+
+// EntityTime is the payload of entity time IQs. In results, TZO is the offset of the entity from
+// UTC, as "+05:30", "-08:00" or "Z", and UTC its time as an XEP-0082 DateTime.
+type EntityTime struct {
+	XMLName xml.Name `xml:"urn:xmpp:time time"`
+	TZO     string   `xml:"tzo,omitempty"`
+	UTC     string   `xml:"utc,omitempty"`
+	// Result sets
+	ResultSet *ResultSet `xml:"set,omitempty"`
+}
+
+func (t *EntityTime) Namespace() string {
+	return t.XMLName.Space
+}
+
+func (t *EntityTime) GetSet() *ResultSet {
+	return t.ResultSet
+}
+
+// ---------------
+// Builder helpers
+
+// EntityTime sets an empty entity time payload on the IQ, to query the time of an entity or to be
+// filled with our time.
+func (iq *IQ) EntityTime() *EntityTime {
+	t := EntityTime{XMLName: xml.Name{Space: NSTime, Local: "time"}}
+	iq.Payload = &t
+	return &t
+}
+
+func init() {
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: NSTime, Local: "time"}, EntityTime{})
+}
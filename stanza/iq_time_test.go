@@ -0,0 +1,42 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestMarshalEntityTime(t *testing.T) {
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, To: "juliet@capulet.com/balcony", Id: "time_1"})
+	if err != nil {
+		t.Fatalf("could not create IQ: %v", err)
+	}
+	iq.EntityTime()
+	out, err := xml.Marshal(iq)
+	if err != nil {
+		t.Fatalf("could not marshal entity time IQ: %v", err)
+	}
+	if !strings.Contains(string(out), `<time xmlns="urn:xmpp:time"></time>`) {
+		t.Errorf("unexpected entity time IQ: %s", out)
+	}
+}
+
+// https://xmpp.org/extensions/xep-0202.html#example-2
+func TestUnmarshalEntityTime(t *testing.T) {
+	raw := `<iq type='result' from='juliet@capulet.com/balcony' to='romeo@montague.net/orchard' id='time_1'>
+  <time xmlns='urn:xmpp:time'>
+    <tzo>-06:00</tzo>
+    <utc>2006-12-19T17:58:35Z</utc>
+  </time>
+</iq>`
+	var iq stanza.IQ
+	if err := xml.Unmarshal([]byte(raw), &iq); err != nil {
+		t.Fatalf("could not unmarshal entity time: %v", err)
+	}
+	et, ok := iq.Payload.(*stanza.EntityTime)
+	if !ok || et.TZO != "-06:00" || et.UTC != "2006-12-19T17:58:35Z" {
+		t.Errorf("unexpected entity time: %+v", iq.Payload)
+	}
+}
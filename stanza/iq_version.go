@@ -47,5 +47,5 @@ func (v *Version) SetInfo(name, version, os string) *Version {
 // Registry init
 
 func init() {
-	TypeRegistry.MapExtension(PKTIQ, xml.Name{Space: "jabber:iq:version", Local: "query"}, Version{})
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: "jabber:iq:version", Local: "query"}, Version{})
 }
@@ -50,14 +50,36 @@ func NewJid(sjid string) (*Jid, error) {
 	return jid, nil
 }
 
+// Full returns the JID with its resource, if any.
 func (j *Jid) Full() string {
-	return j.Node + "@" + j.Domain + "/" + j.Resource
+	if j.Resource == "" {
+		return j.Bare()
+	}
+	return j.Bare() + "/" + j.Resource
 }
 
+// Bare returns the JID without its resource. Server and component JIDs have no node part.
 func (j *Jid) Bare() string {
+	if j.Node == "" {
+		return j.Domain
+	}
 	return j.Node + "@" + j.Domain
 }
 
+// ReplyAddress returns the address to use to answer a stanza received from original: the full JID
+// when original has a resource, so that the answer reaches the client that sent the stanza, and
+// the bare JID otherwise.
+// The resource is never stripped: for private messages sent by room occupants, from room@service/nick,
+// the bare JID is the room itself and answering it would send the reply to all the occupants.
+// It returns an empty string if original is not a valid JID.
+func ReplyAddress(original string) string {
+	jid, err := NewJid(original)
+	if err != nil {
+		return ""
+	}
+	return jid.Full()
+}
+
 // ============================================================================
 // Helpers, for parsing / validation
 
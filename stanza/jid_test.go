@@ -84,3 +84,36 @@ func TestBare(t *testing.T) {
 		t.Errorf("incorrect bare jid: %s", bareJid)
 	}
 }
+
+func TestDomainJid(t *testing.T) {
+	jid, err := NewJid("conference.shakespeare.lit")
+	if err != nil {
+		t.Fatalf("could not parse jid: %v", err)
+	}
+	if jid.Bare() != "conference.shakespeare.lit" || jid.Full() != "conference.shakespeare.lit" {
+		t.Errorf("unexpected domain jid: %s %s", jid.Bare(), jid.Full())
+	}
+}
+
+func TestReplyAddress(t *testing.T) {
+	tests := []struct {
+		original string
+		expected string
+	}{
+		{original: "juliet@capulet.lit/balcony", expected: "juliet@capulet.lit/balcony"},
+		{original: "juliet@capulet.lit", expected: "juliet@capulet.lit"},
+		{original: "juliet@capulet.lit/", expected: "juliet@capulet.lit"},
+		{original: "capulet.lit", expected: "capulet.lit"},
+		{original: "pubsub.capulet.lit/node", expected: "pubsub.capulet.lit/node"},
+		// Private message from a room occupant: the room JID must not be used
+		{original: "coven@chat.shakespeare.lit/firstwitch", expected: "coven@chat.shakespeare.lit/firstwitch"},
+		{original: "coven@chat.shakespeare.lit/first witch/2", expected: "coven@chat.shakespeare.lit/first witch/2"},
+		{original: "", expected: ""},
+		{original: "@capulet.lit", expected: ""},
+	}
+	for _, tt := range tests {
+		if got := ReplyAddress(tt.original); got != tt.expected {
+			t.Errorf("ReplyAddress(%q) = %q, expected %q", tt.original, got, tt.expected)
+		}
+	}
+}
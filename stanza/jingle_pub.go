@@ -0,0 +1,36 @@
+package stanza
+
+import (
+	"encoding/xml"
+)
+
+/*
+Support for:
+- XEP-0358 - Publishing Available Jingle Sessions: https://xmpp.org/extensions/xep-0358.html
+  The Jingle applications an entity accepts sessions for are published as the JingleAppsItemId item
+  of the NodeJingleApps PEP node.
+*/
+
+const (
+	NSJingleRTP      = "urn:xmpp:jingle:apps:rtp:1"
+	NodeJingleApps   = NSJingleRTP
+	JingleAppsItemId = "current"
+)
+
+// JingleApps is the payload of the published Jingle applications.
+type JingleApps struct {
+	XMLName      xml.Name            `xml:"urn:xmpp:jingle:apps:rtp:1 apps"`
+	Descriptions []JingleDescription `xml:"description"`
+}
+
+// JingleDescription is the description of a Jingle application. Its namespace is the namespace of the
+// application, such as NSJingleRTP, and Media the type of media for RTP sessions: "audio" or "video".
+type JingleDescription struct {
+	XMLName xml.Name `xml:"description"`
+	Media   string   `xml:"media,attr,omitempty"`
+}
+
+// NewJingleDescription creates the description of the application ns for media.
+func NewJingleDescription(ns, media string) JingleDescription {
+	return JingleDescription{XMLName: xml.Name{Space: ns, Local: "description"}, Media: media}
+}
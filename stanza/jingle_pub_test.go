@@ -0,0 +1,36 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestJingleAppsItem(t *testing.T) {
+	apps := stanza.JingleApps{Descriptions: []stanza.JingleDescription{
+		stanza.NewJingleDescription(stanza.NSJingleRTP, "audio"),
+		stanza.NewJingleDescription(stanza.NSJingleRTP, "video"),
+	}}
+	item, err := stanza.NewPayloadItem(stanza.JingleAppsItemId, apps)
+	if err != nil {
+		t.Fatalf("could not create item: %v", err)
+	}
+	out, err := xml.Marshal(item)
+	if err != nil {
+		t.Fatalf("could not marshal item: %v", err)
+	}
+	if !strings.Contains(string(out), `<description xmlns="urn:xmpp:jingle:apps:rtp:1" media="video"></description>`) {
+		t.Errorf("unexpected item: %s", out)
+	}
+
+	var decoded stanza.JingleApps
+	if err = item.DecodePayload(&decoded); err != nil {
+		t.Fatalf("could not decode payload: %v", err)
+	}
+	if len(decoded.Descriptions) != 2 || decoded.Descriptions[0].Media != "audio" ||
+		decoded.Descriptions[1].XMLName.Space != stanza.NSJingleRTP {
+		t.Errorf("unexpected payload: %+v", decoded)
+	}
+}
@@ -0,0 +1,103 @@
+package stanza
+
+import (
+	"strings"
+)
+
+// ============================================================================
+// Human-readable text in several languages
+
+// LocalizedString is a variant of a LocalizedText, in the language of Lang. An empty Lang is the
+// language of the stanza.
+type LocalizedString struct {
+	Lang  string `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+// LocalizedText is a human-readable text, such as a presence status or an error text, that can be
+// sent in several languages: each variant is encoded as a repeated element with its xml:lang
+// attribute (RFC 6120 - 8.3.3.3 and RFC 6121 - 4.7.2.2).
+type LocalizedText []LocalizedString
+
+// NewLocalizedText creates a text with a single variant, in the language of the stanza.
+func NewLocalizedText(value string) LocalizedText {
+	if value == "" {
+		return nil
+	}
+	return LocalizedText{{Value: value}}
+}
+
+// Get returns the variant of the text in the language.
+func (t LocalizedText) Get(lang string) (string, bool) {
+	for _, s := range t {
+		if strings.EqualFold(s.Lang, lang) {
+			return s.Value, true
+		}
+	}
+	return "", false
+}
+
+// Set sets the variant of the text in the language, replacing the previous one.
+func (t *LocalizedText) Set(lang, value string) {
+	for i, s := range *t {
+		if strings.EqualFold(s.Lang, lang) {
+			(*t)[i].Value = value
+			return
+		}
+	}
+	*t = append(*t, LocalizedString{Lang: lang, Value: value})
+}
+
+// Default returns the variant in the language of the stanza, without xml:lang attribute, or the
+// first variant when all of them have a language. It returns an empty string for an empty text.
+func (t LocalizedText) Default() string {
+	if value, ok := t.Get(""); ok {
+		return value
+	}
+	if len(t) > 0 {
+		return t[0].Value
+	}
+	return ""
+}
+
+// Select returns the variant in the first of the preferred languages that is available, or the
+// default variant. A language also matches the variants of its subtags: "en" matches "en-US", and
+// "en-GB" matches "en" when there is no better variant.
+func (t LocalizedText) Select(langs ...string) string {
+	for _, lang := range langs {
+		if value, ok := t.Get(lang); ok {
+			return value
+		}
+		primary := primaryLanguage(lang)
+		for _, s := range t {
+			if s.Lang != "" && strings.EqualFold(primaryLanguage(s.Lang), primary) {
+				return s.Value
+			}
+		}
+	}
+	return t.Default()
+}
+
+// String returns the default variant, so that the text can be used where a plain string was
+// expected.
+func (t LocalizedText) String() string {
+	return t.Default()
+}
+
+// primaryLanguage returns the primary subtag of a language tag.
+func primaryLanguage(lang string) string {
+	if i := strings.IndexByte(lang, '-'); i >= 0 {
+		return lang[:i]
+	}
+	return lang
+}
+
+// nodeLang returns the xml:lang attribute of a decoded node.
+func nodeLang(n *Node) string {
+	for _, attr := range n.Attrs {
+		if attr.Name.Local == "lang" && (attr.Name.Space == nsXMLPrefix || attr.Name.Space == "xml") {
+			return attr.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestLocalizedTextSelect(t *testing.T) {
+	var text stanza.LocalizedText
+	if text.String() != "" {
+		t.Errorf("empty text should have an empty default, got %q", text.String())
+	}
+
+	text.Set("en", "Away")
+	text.Set("fr-CA", "Absent")
+	if text.Default() != "Away" {
+		t.Errorf("the first variant should be the default without a stanza language one, got %q", text.Default())
+	}
+	text.Set("", "Away from keyboard")
+	text.Set("EN", "Out")
+
+	if len(text) != 3 {
+		t.Fatalf("Set should replace the variant of a language: %+v", text)
+	}
+	tests := []struct {
+		langs    []string
+		expected string
+	}{
+		{nil, "Away from keyboard"},
+		{[]string{"en"}, "Out"},
+		{[]string{"de", "fr-CA"}, "Absent"},
+		{[]string{"fr"}, "Absent"},
+		{[]string{"en-GB"}, "Out"},
+		{[]string{"de"}, "Away from keyboard"},
+	}
+	for _, test := range tests {
+		if got := text.Select(test.langs...); got != test.expected {
+			t.Errorf("Select(%v): got %q, expected %q", test.langs, got, test.expected)
+		}
+	}
+	if value, ok := text.Get("fr"); ok {
+		t.Errorf("Get should only match the exact language, got %q", value)
+	}
+}
+
+func TestPresenceStatusLanguages(t *testing.T) {
+	str := `<presence xmlns="jabber:client" from="romeo@montague.lit/orchard" xml:lang="en">
+<status>Watching Juliet's balcony</status>
+<status xml:lang="cs">Sleduji Juliin balkon</status>
+</presence>`
+
+	var pres stanza.Presence
+	if err := xml.Unmarshal([]byte(str), &pres); err != nil {
+		t.Fatalf("cannot unmarshal presence: %s", err)
+	}
+	if pres.Status.String() != "Watching Juliet's balcony" {
+		t.Errorf("unexpected default status: %q", pres.Status.String())
+	}
+	if status, _ := pres.Status.Get("cs"); status != "Sleduji Juliin balkon" {
+		t.Errorf("unexpected czech status: %q", status)
+	}
+
+	data, err := xml.Marshal(pres)
+	if err != nil {
+		t.Fatalf("cannot marshal presence: %s", err)
+	}
+	if strings.Count(string(data), "<status") != 2 ||
+		!strings.Contains(string(data), `xml:lang="cs">Sleduji Juliin balkon</status>`) {
+		t.Errorf("statuses should be marshaled with their language: %s", data)
+	}
+}
+
+func TestErrTextLanguages(t *testing.T) {
+	e := stanza.Err{Code: 404, Type: stanza.ErrorTypeCancel, Reason: "item-not-found"}
+	e.Text.Set("", "Room not found")
+	e.Text.Set("fr", "Salon introuvable")
+
+	data, err := xml.Marshal(e)
+	if err != nil {
+		t.Fatalf("cannot marshal error: %s", err)
+	}
+
+	var parsed stanza.Err
+	if err = xml.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("cannot unmarshal error %s: %s", data, err)
+	}
+	if parsed.Text.String() != "Room not found" {
+		t.Errorf("unexpected default text: %q", parsed.Text.String())
+	}
+	if text := parsed.Text.Select("fr-FR"); text != "Salon introuvable" {
+		t.Errorf("unexpected french text %q in %s", text, data)
+	}
+}
@@ -0,0 +1,134 @@
+package stanza
+
+import (
+	"encoding/xml"
+)
+
+// ============================================================================
+// Message Archive Management (XEP-0313)
+
+const NSMam = "urn:xmpp:mam:2"
+
+// NSMamExtended is the feature advertised by archives supporting the after-id, before-id and ids
+// filters. See XEP-0313 - 6.1.1 Extended features
+const NSMamExtended = "urn:xmpp:mam:2#extended"
+
+// Default archiving behaviour, for JIDs not listed in always or never.
+const (
+	MAMDefaultAlways = "always"
+	MAMDefaultNever  = "never"
+	MAMDefaultRoster = "roster"
+)
+
+// MAMPrefs is the payload used to get and set archiving preferences.
+// See XEP-0313 - 6. Archiving Preferences
+type MAMPrefs struct {
+	XMLName xml.Name `xml:"urn:xmpp:mam:2 prefs"`
+	Default string   `xml:"default,attr,omitempty"`
+	Always  []string `xml:"always>jid"`
+	Never   []string `xml:"never>jid"`
+	// Result sets
+	ResultSet *ResultSet `xml:"set,omitempty"`
+}
+
+func (m *MAMPrefs) Namespace() string {
+	return m.XMLName.Space
+}
+
+func (m *MAMPrefs) GetSet() *ResultSet {
+	return m.ResultSet
+}
+
+// MAMQuery is the payload used to query an archive. Filter is a data form restricting the results,
+// with the fields defined in XEP-0313 - 4.1.1 Filtering results.
+// See XEP-0313 - 4. Querying an archive
+type MAMQuery struct {
+	XMLName xml.Name `xml:"urn:xmpp:mam:2 query"`
+	QueryId string   `xml:"queryid,attr,omitempty"`
+	Node    string   `xml:"node,attr,omitempty"`
+	Filter  *Form    `xml:"jabber:x:data x,omitempty"`
+	// Result sets
+	ResultSet *ResultSet `xml:"set,omitempty"`
+}
+
+func (m *MAMQuery) Namespace() string {
+	return m.XMLName.Space
+}
+
+func (m *MAMQuery) GetSet() *ResultSet {
+	return m.ResultSet
+}
+
+// MAMFin is the payload of the result of an archive query, sent after all the results. Complete is
+// true when the last page of the archive was sent.
+// See XEP-0313 - 4.3 Paging through results
+type MAMFin struct {
+	XMLName  xml.Name `xml:"urn:xmpp:mam:2 fin"`
+	Complete bool     `xml:"complete,attr,omitempty"`
+	Stable   string   `xml:"stable,attr,omitempty"`
+	// Result sets
+	ResultSet *ResultSet `xml:"set,omitempty"`
+}
+
+func (m *MAMFin) Namespace() string {
+	return m.XMLName.Space
+}
+
+func (m *MAMFin) GetSet() *ResultSet {
+	return m.ResultSet
+}
+
+// MAMResult wraps an archived message, sent in response to an archive query.
+// QueryId is the id of the query it answers, and Id the archive id of the message.
+// See XEP-0313 - 4.2 Query results
+type MAMResult struct {
+	MsgExtension
+	XMLName   xml.Name  `xml:"urn:xmpp:mam:2 result"`
+	QueryId   string    `xml:"queryid,attr,omitempty"`
+	Id        string    `xml:"id,attr"`
+	Forwarded Forwarded `xml:"urn:xmpp:forward:0 forwarded"`
+}
+
+// ---------------
+// Builder helpers
+
+// MAMPrefs builds an empty archiving preferences payload
+func (iq *IQ) MAMPrefs() *MAMPrefs {
+	p := MAMPrefs{
+		XMLName: xml.Name{Space: NSMam, Local: "prefs"},
+	}
+	iq.Payload = &p
+	return &p
+}
+
+// MAMQuery builds an archive query payload, without filter.
+func (iq *IQ) MAMQuery(queryId string) *MAMQuery {
+	q := MAMQuery{
+		XMLName: xml.Name{Space: NSMam, Local: "query"},
+		QueryId: queryId,
+	}
+	iq.Payload = &q
+	return &q
+}
+
+// AddFilter adds a filter field to the query, creating its data form if needed.
+// Empty values are ignored.
+func (m *MAMQuery) AddFilter(name, value string) {
+	if value == "" {
+		return
+	}
+	if m.Filter == nil {
+		m.Filter = NewForm([]*Field{{Var: "FORM_TYPE", Type: FieldTypeHidden, ValuesList: []string{NSMam}}}, FormTypeSubmit)
+	}
+	m.Filter.Fields = append(m.Filter.Fields, &Field{Var: name, ValuesList: []string{value}})
+}
+
+// ============================================================================
+// Registry init
+
+func init() {
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: NSMam, Local: "prefs"}, MAMPrefs{})
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: NSMam, Local: "query"}, MAMQuery{})
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: NSMam, Local: "fin"}, MAMFin{})
+	TypeRegistry.MustRegister(PKTMessage, xml.Name{Space: NSMam, Local: "result"}, MAMResult{})
+}
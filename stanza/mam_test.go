@@ -0,0 +1,119 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestMAMPrefsDefaults(t *testing.T) {
+	for _, def := range []string{stanza.MAMDefaultAlways, stanza.MAMDefaultNever, stanza.MAMDefaultRoster} {
+		iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeSet, Id: "juliet2"})
+		if err != nil {
+			t.Fatalf("failed to create IQ: %v", err)
+		}
+		prefs := iq.MAMPrefs()
+		prefs.Default = def
+		prefs.Always = []string{"romeo@montague.lit"}
+		prefs.Never = []string{"montague@montague.lit", "tybalt@capulet.lit"}
+
+		data, err := xml.Marshal(iq)
+		if err != nil {
+			t.Fatalf("cannot marshal IQ: %v", err)
+		}
+		var parsed stanza.IQ
+		if err = xml.Unmarshal(data, &parsed); err != nil {
+			t.Fatalf("cannot unmarshal IQ: %v", err)
+		}
+		p, ok := parsed.Payload.(*stanza.MAMPrefs)
+		if !ok {
+			t.Fatalf("unexpected payload: %#v", parsed.Payload)
+		}
+		if p.Default != def || len(p.Always) != 1 || len(p.Never) != 2 || p.Never[1] != "tybalt@capulet.lit" {
+			t.Errorf("preferences do not survive round trip: %+v", p)
+		}
+	}
+}
+
+func TestUnmarshalMAMPrefs(t *testing.T) {
+	// XEP-0313 - Example 36
+	response := `<iq type='result' id='juliet2'>
+  <prefs xmlns='urn:xmpp:mam:2' default='roster'>
+    <always>
+      <jid>romeo@montague.lit</jid>
+    </always>
+    <never>
+      <jid>montague@montague.lit</jid>
+    </never>
+  </prefs>
+</iq>`
+	var iq stanza.IQ
+	if err := xml.Unmarshal([]byte(response), &iq); err != nil {
+		t.Fatalf("cannot unmarshal IQ: %v", err)
+	}
+	p, ok := iq.Payload.(*stanza.MAMPrefs)
+	if !ok {
+		t.Fatalf("unexpected payload: %#v", iq.Payload)
+	}
+	if p.Default != stanza.MAMDefaultRoster || len(p.Always) != 1 || p.Always[0] != "romeo@montague.lit" ||
+		len(p.Never) != 1 || p.Never[0] != "montague@montague.lit" {
+		t.Errorf("unexpected preferences: %+v", p)
+	}
+}
+
+func TestMAMQueryFilter(t *testing.T) {
+	iq, err := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeSet, Id: "juliet1", To: "coven@mix.shakespeare.example"})
+	if err != nil {
+		t.Fatalf("failed to create IQ: %v", err)
+	}
+	q := iq.MAMQuery("f27")
+	q.AddFilter("with", "hag66@shakespeare.example")
+	q.AddFilter("start", "")
+	q.ResultSet = stanza.NewRSMQuery(10, "").ResultSet()
+
+	data, err := xml.Marshal(iq)
+	if err != nil {
+		t.Fatalf("cannot marshal IQ: %v", err)
+	}
+	var parsed stanza.IQ
+	if err = xml.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("cannot unmarshal IQ: %v", err)
+	}
+	p, ok := parsed.Payload.(*stanza.MAMQuery)
+	if !ok {
+		t.Fatalf("unexpected payload: %#v", parsed.Payload)
+	}
+	if p.QueryId != "f27" || p.Filter == nil || p.Filter.FormType() != stanza.NSMam {
+		t.Fatalf("unexpected query: %+v", p)
+	}
+	if len(p.Filter.Fields) != 2 || p.Filter.Field("with").Value() != "hag66@shakespeare.example" {
+		t.Errorf("unexpected filter fields: %+v", p.Filter.Fields)
+	}
+	if set := stanza.NewRSMSet(p.ResultSet); set.Max != 10 {
+		t.Errorf("unexpected result set: %+v", set)
+	}
+}
+
+func TestUnmarshalMAMFin(t *testing.T) {
+	// XEP-0313 - Example 13
+	response := `<iq type='result' id='juliet1'>
+  <fin xmlns='urn:xmpp:mam:2' complete='true'>
+    <set xmlns='http://jabber.org/protocol/rsm'>
+      <first index='0'>28482-98726-73623</first>
+      <last>09af3-cc343-b409f</last>
+    </set>
+  </fin>
+</iq>`
+	var iq stanza.IQ
+	if err := xml.Unmarshal([]byte(response), &iq); err != nil {
+		t.Fatalf("cannot unmarshal IQ: %v", err)
+	}
+	fin, ok := iq.Payload.(*stanza.MAMFin)
+	if !ok {
+		t.Fatalf("unexpected payload: %#v", iq.Payload)
+	}
+	if !fin.Complete || stanza.NewRSMSet(fin.ResultSet).Last != "09af3-cc343-b409f" {
+		t.Errorf("unexpected fin: %+v", fin)
+	}
+}
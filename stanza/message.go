@@ -1,14 +1,23 @@
 package stanza
 
 import (
+	"bytes"
 	"encoding/xml"
 	"reflect"
+	"strings"
 )
 
 // ============================================================================
 // Message Packet
 
 // Message implements RFC 6120 - A.5 Client Namespace (a part)
+//
+// Child elements are always marshaled in the same order: subject, body, thread and error, then the
+// extensions in the order of the Extensions slice. Decoded messages keep their extensions in
+// document order, including the unknown ones, decoded as *Node, so that a received message is
+// marshaled back with its extensions in their original relative order. Unknown extensions that were
+// not modified are marshaled back as received, byte for byte, with the namespaces they inherit from
+// the message declared.
 type Message struct {
 	XMLName xml.Name `xml:"message"`
 	Attrs
@@ -18,6 +27,9 @@ type Message struct {
 	Thread     string         `xml:"thread,omitempty"`
 	Error      Err            `xml:"error,omitempty"`
 	Extensions []MsgExtension `xml:",omitempty"`
+
+	// Raw XML of the unknown extensions, and of the ones whose namespace was registered with RetainRaw
+	raw []rawExtension
 }
 
 func (Message) Name() string {
@@ -37,6 +49,7 @@ func NewMessage(a Attrs) Message {
 // It will return true if the passed extension is found and set the pointer
 // to the extension passed as parameter to the found extension.
 // It will return false if the extension is not found on the message.
+// See GetExtension for a typed alternative.
 //
 // Example usage:
 //   var oob xmpp.OOB
@@ -44,12 +57,16 @@ func NewMessage(a Attrs) Message {
 //     // oob extension has been found
 //	 }
 func (msg *Message) Get(ext MsgExtension) bool {
+	return getMsgExtension(msg.Extensions, ext)
+}
+
+func getMsgExtension(extensions []MsgExtension, ext MsgExtension) bool {
 	target := reflect.ValueOf(ext)
 	if target.Kind() != reflect.Ptr {
 		panic("you must pass a pointer to the message Get method")
 	}
 
-	for _, e := range msg.Extensions {
+	for _, e := range extensions {
 		if reflect.TypeOf(e) == target.Type() {
 			source := reflect.ValueOf(e)
 			if source.Kind() != reflect.Ptr {
@@ -62,6 +79,63 @@ func (msg *Message) Get(ext MsgExtension) bool {
 	return false
 }
 
+// Extension returns the first extension of the message with that namespace and local name, whatever
+// its type. Unknown extensions are returned as *Node.
+func (msg *Message) Extension(namespace, local string) (MsgExtension, bool) {
+	for _, ext := range msg.Extensions {
+		if name := extensionName(ext); name.Space == namespace && name.Local == local {
+			return ext, true
+		}
+	}
+	return nil, false
+}
+
+// extensionName returns the XML name of an extension: the value of its XMLName field, or the name of
+// its XMLName field tag when the field is not set.
+func extensionName(ext MsgExtension) xml.Name {
+	v := reflect.ValueOf(ext)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return xml.Name{}
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return xml.Name{}
+	}
+	field, ok := v.Type().FieldByName("XMLName")
+	if !ok || field.Type != reflect.TypeOf(xml.Name{}) {
+		return xml.Name{}
+	}
+	if name := v.FieldByIndex(field.Index).Interface().(xml.Name); name.Local != "" {
+		return name
+	}
+	tag := strings.Split(field.Tag.Get("xml"), ",")[0]
+	if i := strings.LastIndexByte(tag, ' '); i >= 0 {
+		return xml.Name{Space: tag[:i], Local: tag[i+1:]}
+	}
+	return xml.Name{Local: tag}
+}
+
+// RawOf returns the raw XML a received extension was decoded from. The raw XML is only retained
+// for unknown extensions, and for namespaces registered with TypeRegistry.RetainRaw.
+// ext can be one of the message extensions, or a pointer to the extension type, as passed to Get.
+// The returned slice must not be modified.
+func (msg *Message) RawOf(ext MsgExtension) ([]byte, bool) {
+	for _, r := range msg.raw {
+		if r.ext == ext {
+			return r.data, true
+		}
+	}
+	t := reflect.TypeOf(ext)
+	for _, r := range msg.raw {
+		if reflect.TypeOf(r.ext) == t {
+			return r.data, true
+		}
+	}
+	return nil, false
+}
+
 type messageDecoder struct{}
 
 var message messageDecoder
@@ -74,11 +148,7 @@ func (messageDecoder) decode(p *xml.Decoder, se xml.StartElement) (Message, erro
 
 // XMPPFormat with all Extensions
 func (msg *Message) XMPPFormat() string {
-	out, err := xml.MarshalIndent(msg, "", "")
-	if err != nil {
-		return ""
-	}
-	return string(out)
+	return msg.FormatWith(FormatOptions{})
 }
 
 // UnmarshalXML implements custom parsing for messages
@@ -114,11 +184,23 @@ func (msg *Message) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 		switch tt := t.(type) {
 
 		case xml.StartElement:
+			if tt.Name.Space == NSStream {
+				return interruptedStanza(d, start, tt)
+			}
 			if msgExt := TypeRegistry.GetMsgExtension(tt.Name); msgExt != nil {
-				// Decode message extension
-				err = d.DecodeElement(msgExt, &tt)
-				if err != nil {
-					return err
+				if TypeRegistry.retainsRaw(PKTMessage, tt.Name.Space) {
+					// Decode message extension, keeping its raw XML
+					raw, err := decodeRawElement(d, tt, msgExt)
+					if err != nil {
+						return err
+					}
+					msg.raw = append(msg.raw, rawExtension{ext: msgExt, data: raw})
+				} else {
+					// Decode message extension
+					err = d.DecodeElement(msgExt, &tt)
+					if err != nil {
+						return err
+					}
 				}
 				msg.Extensions = append(msg.Extensions, msgExt)
 			} else {
@@ -133,6 +215,18 @@ func (msg *Message) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 					err = d.DecodeElement(&msg.Subject, &tt)
 				case "error":
 					err = d.DecodeElement(&msg.Error, &tt)
+				default:
+					// Keep unknown extensions, in their original order, with their XML so that they are
+					// forwarded untouched unless modified
+					var f XMLFragment
+					if err = d.DecodeElement(&f, &tt); err != nil {
+						break
+					}
+					n := &Node{}
+					if err = f.Decode(n); err == nil {
+						msg.Extensions = append(msg.Extensions, n)
+						msg.raw = append(msg.raw, rawExtension{ext: n, data: f.Bytes()})
+					}
 				}
 				if err != nil {
 					return err
@@ -146,3 +240,71 @@ func (msg *Message) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 		}
 	}
 }
+
+// messageAlias is used to marshal messages with the default encoding.
+type messageAlias Message
+
+// MarshalXML uses the default encoding, unless the message carries retained raw XML.
+// In that case, the raw XML is written for the extensions that were not modified since decoding.
+func (msg Message) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = msg.XMLName
+	if start.Name.Local == "" {
+		start.Name = xml.Name{Local: "message"}
+	}
+	if len(msg.raw) == 0 {
+		return e.EncodeElement(messageAlias(msg), start)
+	}
+
+	var buf bytes.Buffer
+	inner := xml.NewEncoder(&buf)
+	if err := encodeMessageContent(inner, msg); err != nil {
+		return err
+	}
+	for _, ext := range msg.Extensions {
+		if raw, ok := msg.unchangedRaw(ext); ok {
+			if err := inner.Flush(); err != nil {
+				return err
+			}
+			buf.Write(raw)
+			continue
+		}
+		if err := inner.Encode(ext); err != nil {
+			return err
+		}
+	}
+	if err := inner.Flush(); err != nil {
+		return err
+	}
+
+	out := struct {
+		XMLName xml.Name
+		Attrs
+		Inner []byte `xml:",innerxml"`
+	}{XMLName: start.Name, Attrs: msg.Attrs, Inner: buf.Bytes()}
+	return e.EncodeElement(out, start)
+}
+
+// encodeMessageContent encodes the standard message sub-elements, in the default encoding order.
+func encodeMessageContent(e *xml.Encoder, msg Message) error {
+	for _, elt := range []struct {
+		name, value string
+	}{{"subject", msg.Subject}, {"body", msg.Body}, {"thread", msg.Thread}} {
+		if elt.value == "" {
+			continue
+		}
+		if err := e.EncodeElement(elt.value, xml.StartElement{Name: xml.Name{Local: elt.name}}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeElement(msg.Error, xml.StartElement{Name: xml.Name{Local: "error"}})
+}
+
+// unchangedRaw returns the retained raw XML of the extension, if it was not modified since decoding.
+func (msg Message) unchangedRaw(ext MsgExtension) ([]byte, bool) {
+	for _, r := range msg.raw {
+		if r.ext == ext {
+			return r.data, rawUnchanged(ext, r.data)
+		}
+	}
+	return nil, false
+}
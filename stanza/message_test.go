@@ -2,6 +2,10 @@ package stanza_test
 
 import (
 	"encoding/xml"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -74,3 +78,239 @@ func TestGetOOB(t *testing.T) {
 		t.Error("we should not have found markable extension")
 	}
 }
+
+func TestMessageMarshalDeterministic(t *testing.T) {
+	msg := stanza.NewMessage(stanza.Attrs{To: "juliet@capulet.lit", Id: "msg1", Type: stanza.MessageTypeChat})
+	msg.Extensions = append(msg.Extensions, stanza.StateActive{}, stanza.OOB{URL: "https://example.com/a.png"})
+	msg.Thread = "e0ffe42b28561960c6b12b944a092794b9683a38"
+	msg.Body = "Wherefore art thou?"
+	msg.Subject = "Balcony"
+
+	first, err := xml.Marshal(msg)
+	if err != nil {
+		t.Fatalf("could not marshal message: %v", err)
+	}
+	second, err := xml.Marshal(msg)
+	if err != nil {
+		t.Fatalf("could not marshal message: %v", err)
+	}
+	if string(first) != string(second) {
+		t.Errorf("marshaling the same message twice should give the same bytes:\n%s\n%s", first, second)
+	}
+
+	out := string(first)
+	order := []string{"<subject>", "<body>", "<thread>", "<active", "<x xmlns=\"jabber:x:oob\""}
+	last := -1
+	for _, elt := range order {
+		i := strings.Index(out, elt)
+		if i <= last {
+			t.Fatalf("%s is not in the expected order in %s", elt, out)
+		}
+		last = i
+	}
+}
+
+func TestMessageExtensionsRoundTrip(t *testing.T) {
+	raw := `<message xmlns="jabber:client" from="northumberland@shakespeare.lit/westminster" to="kingrichard@royalty.england.lit/throne" id="richard2-4.1.247" type="chat">
+  <body>My lord, dispatch; read o'er these articles.</body>
+  <request xmlns="urn:xmpp:receipts"/>
+  <markable xmlns="urn:xmpp:chat-markers:0"/>
+  <active xmlns="http://jabber.org/protocol/chatstates"/>
+  <x xmlns="jabber:x:oob"><url>https://example.com/articles.pdf</url><desc>Articles</desc></x>
+</message>`
+	var msg stanza.Message
+	if err := xml.Unmarshal([]byte(raw), &msg); err != nil {
+		t.Fatalf("could not unmarshal message: %v", err)
+	}
+	if len(msg.Extensions) != 4 {
+		t.Fatalf("expected 4 extensions, got %+v", msg.Extensions)
+	}
+
+	out, err := xml.Marshal(msg)
+	if err != nil {
+		t.Fatalf("could not marshal message: %v", err)
+	}
+	for _, elt := range []string{
+		`<request xmlns="urn:xmpp:receipts"></request>`,
+		`<markable xmlns="urn:xmpp:chat-markers:0"></markable>`,
+		`<active xmlns="http://jabber.org/protocol/chatstates"></active>`,
+		`<url>https://example.com/articles.pdf</url>`,
+	} {
+		if !strings.Contains(string(out), elt) {
+			t.Errorf("%s not found in %s", elt, out)
+		}
+	}
+
+	var decoded stanza.Message
+	if err = xml.Unmarshal(out, &decoded); err != nil {
+		t.Fatalf("could not unmarshal marshaled message: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, msg) {
+		t.Errorf("message changed after a round trip:\n%+v\nexpected:\n%+v", decoded, msg)
+	}
+}
+
+type formatTestHint struct {
+	stanza.MsgExtension
+	XMLName xml.Name `xml:"urn:example:hint:0 hint"`
+	Level   string   `xml:"level,attr"`
+}
+
+// Extensions built by the application are kept by XMPPFormat, next to the standard elements.
+func TestMessageXMPPFormatExtensions(t *testing.T) {
+	if err := stanza.RegisterMessageExtension("urn:example:hint:0", "hint", formatTestHint{}); err != nil {
+		t.Fatalf("could not register extension: %v", err)
+	}
+	msg := stanza.NewMessage(stanza.Attrs{Type: stanza.MessageTypeChat, To: "juliet@capulet.lit", Id: "format1"})
+	msg.Subject = "Balcony"
+	msg.Body = "Wherefore art thou Romeo?"
+	msg.Thread = "thread1"
+	msg.Extensions = append(msg.Extensions, &stanza.ReceiptRequest{}, &stanza.Markable{}, &formatTestHint{Level: "2"})
+
+	out := msg.XMPPFormat()
+	for _, elt := range []string{
+		`<subject>Balcony</subject><body>Wherefore art thou Romeo?</body><thread>thread1</thread>`,
+		`<request xmlns="urn:xmpp:receipts"></request>`,
+		`<markable xmlns="urn:xmpp:chat-markers:0"></markable>`,
+		`<hint xmlns="urn:example:hint:0" level="2"></hint>`,
+	} {
+		if !strings.Contains(out, elt) {
+			t.Errorf("%s not found in %s", elt, out)
+		}
+	}
+
+	var decoded stanza.Message
+	if err := xml.Unmarshal([]byte(out), &decoded); err != nil {
+		t.Fatalf("could not unmarshal formatted message: %v", err)
+	}
+	if decoded.Subject != msg.Subject || decoded.Body != msg.Body || decoded.Thread != msg.Thread {
+		t.Errorf("unexpected message: %+v", decoded)
+	}
+	var receipt stanza.ReceiptRequest
+	var markable stanza.Markable
+	var hint formatTestHint
+	if len(decoded.Extensions) != 3 || !decoded.Get(&receipt) || !decoded.Get(&markable) || !decoded.Get(&hint) {
+		t.Fatalf("extensions lost after formatting: %+v", decoded.Extensions)
+	}
+	if hint.Level != "2" {
+		t.Errorf("unexpected hint: %+v", hint)
+	}
+}
+
+func TestMessageUnknownExtensionsOrder(t *testing.T) {
+	raw := `<message xmlns="jabber:client" to="juliet@capulet.lit" id="msg1">` +
+		`<body>Hello</body>` +
+		`<first xmlns="urn:example:one" a="1"></first>` +
+		`<active xmlns="http://jabber.org/protocol/chatstates"></active>` +
+		`<second xmlns="urn:example:two"><body>not the message body</body></second>` +
+		`</message>`
+	var msg stanza.Message
+	if err := xml.Unmarshal([]byte(raw), &msg); err != nil {
+		t.Fatalf("could not unmarshal message: %v", err)
+	}
+	if msg.Body != "Hello" {
+		t.Errorf("body of an unknown extension should not be read as the message body: %q", msg.Body)
+	}
+	if len(msg.Extensions) != 3 {
+		t.Fatalf("expected 3 extensions, got %+v", msg.Extensions)
+	}
+	if n, ok := msg.Extensions[0].(*stanza.Node); !ok || n.XMLName.Local != "first" {
+		t.Errorf("unknown extension should be kept as a node: %+v", msg.Extensions[0])
+	}
+	if _, ok := msg.Extensions[1].(*stanza.StateActive); !ok {
+		t.Errorf("unexpected second extension: %+v", msg.Extensions[1])
+	}
+
+	out, err := xml.Marshal(msg)
+	if err != nil {
+		t.Fatalf("could not marshal message: %v", err)
+	}
+	first := strings.Index(string(out), "urn:example:one")
+	active := strings.Index(string(out), "chatstates")
+	second := strings.Index(string(out), "urn:example:two")
+	if first < 0 || first > active || active > second {
+		t.Errorf("extensions should keep their original relative order: %s", out)
+	}
+}
+
+func TestPresenceUnknownExtensions(t *testing.T) {
+	raw := `<presence from="romeo@montague.lit/orchard"><custom xmlns="urn:example:custom">x</custom><status>Away</status></presence>`
+	var p stanza.Presence
+	if err := xml.Unmarshal([]byte(raw), &p); err != nil {
+		t.Fatalf("could not unmarshal presence: %v", err)
+	}
+	if p.Status.String() != "Away" || len(p.Extensions) != 1 {
+		t.Fatalf("unexpected presence: %+v", p)
+	}
+	out, err := xml.Marshal(p)
+	if err != nil {
+		t.Fatalf("could not marshal presence: %v", err)
+	}
+	if !strings.Contains(string(out), `<status>Away</status><custom xmlns="urn:example:custom">x</custom>`) {
+		t.Errorf("unknown extension should follow the standard elements: %s", out)
+	}
+}
+
+type pushTestPayload struct {
+	stanza.MsgExtension
+	XMLName xml.Name `xml:"urn:example:push:0 push"`
+	Badge   int      `xml:"badge,attr"`
+}
+
+type pushTestOther struct {
+	stanza.MsgExtension
+	XMLName xml.Name `xml:"urn:example:push:0 push"`
+}
+
+func TestRegisterMessageExtension(t *testing.T) {
+	// Concurrent registrations of the same type are allowed
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- stanza.RegisterMessageExtension("urn:example:push:0", "push", pushTestPayload{})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("could not register extension: %v", err)
+		}
+	}
+	if err := stanza.RegisterMessageExtension("urn:example:push:0", "push", pushTestOther{}); !errors.Is(err, stanza.ErrExtensionConflict) {
+		t.Errorf("conflicting registration should fail, got %v", err)
+	}
+
+	raw := `<message xmlns="jabber:client" from="push.example.com" to="romeo@montague.lit">` +
+		`<push xmlns="urn:example:push:0" badge="3"/><unknown xmlns="urn:example:unknown"/></message>`
+	var msg stanza.Message
+	if err := xml.Unmarshal([]byte(raw), &msg); err != nil {
+		t.Fatalf("could not unmarshal message: %v", err)
+	}
+	var push pushTestPayload
+	if !msg.Get(&push) || push.Badge != 3 {
+		t.Errorf("push payload was not decoded: %+v", msg.Extensions)
+	}
+
+	ext, ok := msg.Extension("urn:example:push:0", "push")
+	if p, isPush := ext.(*pushTestPayload); !ok || !isPush || p.Badge != 3 {
+		t.Errorf("unexpected push extension: %+v", ext)
+	}
+	if ext, ok = msg.Extension("urn:example:unknown", "unknown"); !ok {
+		t.Errorf("unknown extension not found")
+	} else if _, isNode := ext.(*stanza.Node); !isNode {
+		t.Errorf("unknown extension should be a node: %+v", ext)
+	}
+	if _, ok = msg.Extension("urn:example:push:0", "other"); ok {
+		t.Errorf("unexpected extension found")
+	}
+
+	// Extensions added without XMLName are found by the name of their type
+	msg.Extensions = append(msg.Extensions, stanza.ReceiptRequest{})
+	if _, ok = msg.Extension(stanza.NSMsgReceipts, "request"); !ok {
+		t.Errorf("receipt request not found")
+	}
+}
@@ -0,0 +1,55 @@
+package stanza
+
+import (
+	"encoding/xml"
+)
+
+/*
+Support for:
+- XEP-0277 - Microblogging over XMPP: https://xmpp.org/extensions/xep-0277.html
+  Posts are Atom entries (RFC 4287), published as items of the NodeMicroblog PEP node.
+*/
+
+const (
+	NSAtom        = "http://www.w3.org/2005/Atom"
+	NodeMicroblog = "urn:xmpp:microblog:0"
+
+	// Atom text constructs types
+	AtomTextTypeText = "text"
+	AtomTextTypeHTML = "html"
+)
+
+// AtomEntry is the payload of a microblog item. Dates are RFC 3339 timestamps.
+type AtomEntry struct {
+	XMLName   xml.Name   `xml:"http://www.w3.org/2005/Atom entry"`
+	Title     *AtomText  `xml:"title"`
+	Summary   *AtomText  `xml:"summary"`
+	Content   *AtomText  `xml:"content"`
+	Links     []AtomLink `xml:"link"`
+	Id        string     `xml:"id"`
+	Published string     `xml:"published,omitempty"`
+	Updated   string     `xml:"updated"`
+}
+
+// AtomText is an Atom text construct. HTML is escaped in Text, as required for the html type.
+type AtomText struct {
+	Type string `xml:"type,attr,omitempty"`
+	Text string `xml:",chardata"`
+}
+
+type AtomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+// Link returns the href of the first link of the entry with the relation rel. Links without
+// relation are alternate links, as defined by RFC 4287.
+func (e *AtomEntry) Link(rel string) string {
+	for _, l := range e.Links {
+		if l.Rel == rel || (l.Rel == "" && rel == "alternate") {
+			return l.Href
+		}
+	}
+	return ""
+}
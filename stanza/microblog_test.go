@@ -0,0 +1,58 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestAtomEntryMarshalText(t *testing.T) {
+	entry := stanza.AtomEntry{
+		Title:   &stanza.AtomText{Type: stanza.AtomTextTypeText, Text: "hanging out at the Café Napolitano"},
+		Content: &stanza.AtomText{Type: stanza.AtomTextTypeText, Text: "Tom & Jerry"},
+		Id:      "tag:montague.lit,2008-05-08:posts-1cb57d9c-1c46-11dd-838c-001143d5d5db",
+		Updated: "2008-05-08T18:30:02Z",
+	}
+	data, err := xml.Marshal(entry)
+	if err != nil {
+		t.Fatalf("could not marshal entry: %v", err)
+	}
+	expected := `<entry xmlns="http://www.w3.org/2005/Atom">` +
+		`<title type="text">hanging out at the Café Napolitano</title>` +
+		`<content type="text">Tom &amp; Jerry</content>` +
+		`<id>tag:montague.lit,2008-05-08:posts-1cb57d9c-1c46-11dd-838c-001143d5d5db</id>` +
+		`<updated>2008-05-08T18:30:02Z</updated></entry>`
+	if string(data) != expected {
+		t.Errorf("unexpected entry XML:\n%s\nexpected:\n%s", data, expected)
+	}
+}
+
+func TestAtomEntryMarshalHTML(t *testing.T) {
+	entry := stanza.AtomEntry{
+		Title:   &stanza.AtomText{Text: "Verona"},
+		Content: &stanza.AtomText{Type: stanza.AtomTextTypeHTML, Text: "<p>Two households, <b>both alike</b> in dignity</p>"},
+		Links:   []stanza.AtomLink{{Rel: "alternate", Href: "https://montague.lit/verona", Type: "text/html"}},
+		Id:      "urn:uuid:1cb57d9c-1c46-11dd-838c-001143d5d5db",
+		Updated: "2008-05-08T18:30:02Z",
+	}
+	data, err := xml.Marshal(entry)
+	if err != nil {
+		t.Fatalf("could not marshal entry: %v", err)
+	}
+	expected := `<entry xmlns="http://www.w3.org/2005/Atom"><title>Verona</title>` +
+		`<content type="html">&lt;p&gt;Two households, &lt;b&gt;both alike&lt;/b&gt; in dignity&lt;/p&gt;</content>` +
+		`<link rel="alternate" href="https://montague.lit/verona" type="text/html"></link>` +
+		`<id>urn:uuid:1cb57d9c-1c46-11dd-838c-001143d5d5db</id><updated>2008-05-08T18:30:02Z</updated></entry>`
+	if string(data) != expected {
+		t.Errorf("unexpected entry XML:\n%s\nexpected:\n%s", data, expected)
+	}
+
+	var parsed stanza.AtomEntry
+	if err = xml.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("could not unmarshal entry: %v", err)
+	}
+	if parsed.Content == nil || parsed.Content.Text != entry.Content.Text || parsed.Link("alternate") != "https://montague.lit/verona" {
+		t.Errorf("unexpected entry: %+v", parsed)
+	}
+}
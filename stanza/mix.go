@@ -0,0 +1,124 @@
+package stanza
+
+import (
+	"encoding/xml"
+)
+
+// ============================================================================
+// Mediated Information eXchange (XEP-0369)
+
+const (
+	NSMixCore = "urn:xmpp:mix:core:1"
+	// Namespace of the invitations (XEP-0407)
+	NSMixMisc = "urn:xmpp:mix:misc:0"
+	// Namespace of the channel administration requests (XEP-0406)
+	NSMixAdmin = "urn:xmpp:mix:admin:0"
+)
+
+// MIX channel nodes, advertised as features by the channels
+// See XEP-0369 - 6.1 Discovering Channel Nodes
+const (
+	MixNodePrefix       = "urn:xmpp:mix:nodes:"
+	MixNodeMessages     = "urn:xmpp:mix:nodes:messages"
+	MixNodeParticipants = "urn:xmpp:mix:nodes:participants"
+	MixNodeInfo         = "urn:xmpp:mix:nodes:info"
+	MixNodeConfig       = "urn:xmpp:mix:nodes:config"
+)
+
+// Mix is added by MIX channels to the messages they distribute, with the nickname and the JID of
+// the participant who sent the message.
+// See XEP-0369 - 7.1.2 Sending a Message
+type Mix struct {
+	MsgExtension
+	XMLName xml.Name `xml:"urn:xmpp:mix:core:1 mix"`
+	Nick    string   `xml:"nick,omitempty"`
+	Jid     string   `xml:"jid,omitempty"`
+}
+
+// MixParticipant is the item of a participant, published on the participants node of a channel.
+// The item id is the stable participant id.
+// See XEP-0369 - 5.2.2 Participants Node
+type MixParticipant struct {
+	XMLName xml.Name `xml:"urn:xmpp:mix:core:1 participant"`
+	Nick    string   `xml:"nick,omitempty"`
+	Jid     string   `xml:"jid,omitempty"`
+}
+
+// MixInvitation allows the invitee to join a channel. It is returned by the channel to the inviter,
+// who sends it to the invitee in a message.
+// See XEP-0407 - 2. Invitation
+type MixInvitation struct {
+	MsgExtension
+	XMLName xml.Name `xml:"urn:xmpp:mix:misc:0 invitation"`
+	Inviter string   `xml:"inviter"`
+	Invitee string   `xml:"invitee"`
+	Channel string   `xml:"channel"`
+	Token   string   `xml:"token"`
+}
+
+// MixInvite is the payload used to request an invitation from a channel. The channel answers with
+// the invitation.
+type MixInvite struct {
+	XMLName    xml.Name       `xml:"urn:xmpp:mix:misc:0 invite"`
+	Invitee    string         `xml:"invitee,omitempty"`
+	Invitation *MixInvitation `xml:"invitation,omitempty"`
+	// Result sets
+	ResultSet *ResultSet `xml:"set,omitempty"`
+}
+
+func (m *MixInvite) Namespace() string {
+	return m.XMLName.Space
+}
+
+func (m *MixInvite) GetSet() *ResultSet {
+	return m.ResultSet
+}
+
+// MixKick is the payload used by channel administrators to remove a participant from a channel.
+type MixKick struct {
+	XMLName xml.Name `xml:"urn:xmpp:mix:admin:0 kick"`
+	Jid     string   `xml:"jid"`
+	// Result sets
+	ResultSet *ResultSet `xml:"set,omitempty"`
+}
+
+func (m *MixKick) Namespace() string {
+	return m.XMLName.Space
+}
+
+func (m *MixKick) GetSet() *ResultSet {
+	return m.ResultSet
+}
+
+// ---------------
+// Builder helpers
+
+// MixInvite builds a request for an invitation of the invitee to the channel the IQ is sent to.
+func (iq *IQ) MixInvite(invitee string) *MixInvite {
+	m := MixInvite{
+		XMLName: xml.Name{Space: NSMixMisc, Local: "invite"},
+		Invitee: invitee,
+	}
+	iq.Payload = &m
+	return &m
+}
+
+// MixKick builds a request removing the participant from the channel the IQ is sent to.
+func (iq *IQ) MixKick(jid string) *MixKick {
+	m := MixKick{
+		XMLName: xml.Name{Space: NSMixAdmin, Local: "kick"},
+		Jid:     jid,
+	}
+	iq.Payload = &m
+	return &m
+}
+
+// ============================================================================
+// Registry init
+
+func init() {
+	TypeRegistry.MustRegister(PKTMessage, xml.Name{Space: NSMixCore, Local: "mix"}, Mix{})
+	TypeRegistry.MustRegister(PKTMessage, xml.Name{Space: NSMixMisc, Local: "invitation"}, MixInvitation{})
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: NSMixMisc, Local: "invite"}, MixInvite{})
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: NSMixAdmin, Local: "kick"}, MixKick{})
+}
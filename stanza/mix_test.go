@@ -0,0 +1,30 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestUnmarshalMixMessage(t *testing.T) {
+	// XEP-0369 - Example 42
+	msg := `<message from='coven@mix.shakespeare.example/123456' to='hecate@shakespeare.example' id='77E07BB0-55CF-4BD4-890E-3F7C0E686BBD' type='groupchat'>
+  <body>Harpier cries: 'tis time, 'tis time.</body>
+  <mix xmlns='urn:xmpp:mix:core:1'>
+    <nick>thirdwitch</nick>
+    <jid>hag66@shakespeare.example</jid>
+  </mix>
+</message>`
+	var parsed stanza.Message
+	if err := xml.Unmarshal([]byte(msg), &parsed); err != nil {
+		t.Fatalf("cannot unmarshal message: %v", err)
+	}
+	var mix stanza.Mix
+	if !parsed.Get(&mix) {
+		t.Fatalf("mix element not found in %+v", parsed.Extensions)
+	}
+	if mix.Nick != "thirdwitch" || mix.Jid != "hag66@shakespeare.example" {
+		t.Errorf("unexpected mix element: %+v", mix)
+	}
+}
@@ -0,0 +1,32 @@
+package stanza
+
+import (
+	"encoding/xml"
+)
+
+/*
+Support for:
+- XEP-0367 - Message Attaching: https://xmpp.org/extensions/xep-0367.html
+*/
+
+const NSMsgAttaching = "urn:xmpp:message-attaching:1"
+
+// AttachTo associates a message, for example a reaction, with the earlier message with the id Id.
+type AttachTo struct {
+	MsgExtension
+	XMLName xml.Name `xml:"urn:xmpp:message-attaching:1 attach-to"`
+	ID      string   `xml:"id,attr"`
+}
+
+// GetAttachToId returns the id of the message the message is attached to, if any.
+func (msg *Message) GetAttachToId() string {
+	var a AttachTo
+	if msg.Get(&a) {
+		return a.ID
+	}
+	return ""
+}
+
+func init() {
+	TypeRegistry.MustRegister(PKTMessage, xml.Name{Space: NSMsgAttaching, Local: "attach-to"}, AttachTo{})
+}
@@ -0,0 +1,47 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestDecodeAttachTo(t *testing.T) {
+	str := `<message to='juliet@capulet.net' type='chat' id='6'>
+  <body>Yes</body>
+  <attach-to xmlns='urn:xmpp:message-attaching:1' id='5'/>
+</message>`
+	var msg stanza.Message
+	if err := xml.Unmarshal([]byte(str), &msg); err != nil {
+		t.Fatalf("could not unmarshal message: %v", err)
+	}
+	if id := msg.GetAttachToId(); id != "5" {
+		t.Errorf("unexpected attach-to id: %q", id)
+	}
+	if id := msg.GetReplaceId(); id != "" {
+		t.Errorf("message is not a correction: %q", id)
+	}
+}
+
+func TestEncodeReplace(t *testing.T) {
+	msg := stanza.NewMessage(stanza.Attrs{To: "juliet@capulet.net/balcony", Id: "good1"})
+	msg.Body = "But soft, what light through yonder window breaks?"
+	msg.Extensions = append(msg.Extensions, stanza.Replace{ID: "bad1"})
+	data, err := xml.Marshal(msg)
+	if err != nil {
+		t.Fatalf("could not marshal message: %v", err)
+	}
+	if !strings.Contains(string(data), `<replace xmlns="urn:xmpp:message-correct:0" id="bad1"></replace>`) {
+		t.Errorf("unexpected correction: %s", data)
+	}
+
+	var parsed stanza.Message
+	if err = xml.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("could not unmarshal message: %v", err)
+	}
+	if id := parsed.GetReplaceId(); id != "bad1" {
+		t.Errorf("unexpected replace id: %q", id)
+	}
+}
@@ -0,0 +1,55 @@
+package stanza
+
+import (
+	"encoding/xml"
+)
+
+/*
+Support for:
+- XEP-0280 - Message Carbons: https://xmpp.org/extensions/xep-0280.html
+*/
+
+const NSCarbons = "urn:xmpp:carbons:2"
+
+// CarbonReceived wraps a copy of a message received by another resource of the account.
+type CarbonReceived struct {
+	MsgExtension
+	XMLName   xml.Name  `xml:"urn:xmpp:carbons:2 received"`
+	Forwarded Forwarded `xml:"urn:xmpp:forward:0 forwarded"`
+}
+
+// CarbonSent wraps a copy of a message sent by another resource of the account.
+type CarbonSent struct {
+	MsgExtension
+	XMLName   xml.Name  `xml:"urn:xmpp:carbons:2 sent"`
+	Forwarded Forwarded `xml:"urn:xmpp:forward:0 forwarded"`
+}
+
+// CarbonPrivate excludes a message from carbon copies.
+type CarbonPrivate struct {
+	MsgExtension
+	XMLName xml.Name `xml:"urn:xmpp:carbons:2 private"`
+}
+
+// CarbonsEnable is the IQ payload enabling carbon copies for the resource. It is also enabled inline
+// with Bind 2.
+type CarbonsEnable struct {
+	XMLName xml.Name `xml:"urn:xmpp:carbons:2 enable"`
+	// Result sets
+	ResultSet *ResultSet `xml:"set,omitempty"`
+}
+
+func (c *CarbonsEnable) Namespace() string {
+	return c.XMLName.Space
+}
+
+func (c *CarbonsEnable) GetSet() *ResultSet {
+	return c.ResultSet
+}
+
+func init() {
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: NSCarbons, Local: "enable"}, CarbonsEnable{})
+	TypeRegistry.MustRegister(PKTMessage, xml.Name{Space: NSCarbons, Local: "received"}, CarbonReceived{})
+	TypeRegistry.MustRegister(PKTMessage, xml.Name{Space: NSCarbons, Local: "sent"}, CarbonSent{})
+	TypeRegistry.MustRegister(PKTMessage, xml.Name{Space: NSCarbons, Local: "private"}, CarbonPrivate{})
+}
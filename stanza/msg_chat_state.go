@@ -11,6 +11,18 @@ Support for:
 
 const NSMsgChatStateNotifications = "http://jabber.org/protocol/chatstates"
 
+// NSChatStates is the namespace of the chat state notifications.
+const NSChatStates = NSMsgChatStateNotifications
+
+// Chat states, as element names
+const (
+	ChatStateActive    = "active"
+	ChatStateComposing = "composing"
+	ChatStateGone      = "gone"
+	ChatStateInactive  = "inactive"
+	ChatStatePaused    = "paused"
+)
+
 type StateActive struct {
 	MsgExtension
 	XMLName xml.Name `xml:"http://jabber.org/protocol/chatstates active"`
@@ -36,10 +48,43 @@ type StatePaused struct {
 	XMLName xml.Name `xml:"http://jabber.org/protocol/chatstates paused"`
 }
 
+// Short names of the chat state extensions. They are the same types, decoded from the received
+// messages.
+type (
+	Active    = StateActive
+	Composing = StateComposing
+	Gone      = StateGone
+	Inactive  = StateInactive
+	Paused    = StatePaused
+)
+
+// NewChatStateMessage returns a chat message notifying the chat state, for example ChatStateComposing.
+// The message has no chat state extension if the state is unknown.
+func NewChatStateMessage(state, to, from, id string) Message {
+	msg := NewMessage(Attrs{Type: MessageTypeChat, To: to, From: from, Id: id})
+	var ext MsgExtension
+	switch state {
+	case ChatStateActive:
+		ext = &StateActive{}
+	case ChatStateComposing:
+		ext = &StateComposing{}
+	case ChatStateGone:
+		ext = &StateGone{}
+	case ChatStateInactive:
+		ext = &StateInactive{}
+	case ChatStatePaused:
+		ext = &StatePaused{}
+	default:
+		return msg
+	}
+	msg.Extensions = append(msg.Extensions, ext)
+	return msg
+}
+
 func init() {
-	TypeRegistry.MapExtension(PKTMessage, xml.Name{Space: NSMsgChatStateNotifications, Local: "active"}, StateActive{})
-	TypeRegistry.MapExtension(PKTMessage, xml.Name{Space: NSMsgChatStateNotifications, Local: "composing"}, StateComposing{})
-	TypeRegistry.MapExtension(PKTMessage, xml.Name{Space: NSMsgChatStateNotifications, Local: "gone"}, StateGone{})
-	TypeRegistry.MapExtension(PKTMessage, xml.Name{Space: NSMsgChatStateNotifications, Local: "inactive"}, StateInactive{})
-	TypeRegistry.MapExtension(PKTMessage, xml.Name{Space: NSMsgChatStateNotifications, Local: "paused"}, StatePaused{})
+	TypeRegistry.MustRegister(PKTMessage, xml.Name{Space: NSMsgChatStateNotifications, Local: "active"}, StateActive{})
+	TypeRegistry.MustRegister(PKTMessage, xml.Name{Space: NSMsgChatStateNotifications, Local: "composing"}, StateComposing{})
+	TypeRegistry.MustRegister(PKTMessage, xml.Name{Space: NSMsgChatStateNotifications, Local: "gone"}, StateGone{})
+	TypeRegistry.MustRegister(PKTMessage, xml.Name{Space: NSMsgChatStateNotifications, Local: "inactive"}, StateInactive{})
+	TypeRegistry.MustRegister(PKTMessage, xml.Name{Space: NSMsgChatStateNotifications, Local: "paused"}, StatePaused{})
 }
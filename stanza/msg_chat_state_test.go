@@ -0,0 +1,59 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestDecodeChatState(t *testing.T) {
+	str := `<message from='bernardo@shakespeare.lit/pda' to='francisco@shakespeare.lit' type='chat'>
+  <composing xmlns='http://jabber.org/protocol/chatstates'/>
+</message>`
+	var msg stanza.Message
+	if err := xml.Unmarshal([]byte(str), &msg); err != nil {
+		t.Fatalf("could not unmarshal message: %v", err)
+	}
+
+	var composing stanza.Composing
+	if !msg.Get(&composing) {
+		t.Fatalf("composing chat state not found: %+v", msg.Extensions)
+	}
+	if composing.XMLName.Space != stanza.NSChatStates {
+		t.Errorf("unexpected chat state: %v", composing.XMLName)
+	}
+	var paused stanza.Paused
+	if msg.Get(&paused) {
+		t.Errorf("unexpected paused chat state")
+	}
+}
+
+func TestNewChatStateMessage(t *testing.T) {
+	msg := stanza.NewChatStateMessage(stanza.ChatStatePaused, "francisco@shakespeare.lit", "bernardo@shakespeare.lit/pda", "state1")
+	data, err := xml.Marshal(msg)
+	if err != nil {
+		t.Fatalf("could not marshal message: %v", err)
+	}
+	if !strings.Contains(string(data), `<paused xmlns="http://jabber.org/protocol/chatstates"></paused>`) {
+		t.Errorf("paused chat state not encoded: %s", data)
+	}
+
+	var parsed stanza.Message
+	if err = xml.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("could not unmarshal message: %v", err)
+	}
+	if parsed.Type != stanza.MessageTypeChat || parsed.To != "francisco@shakespeare.lit" ||
+		parsed.From != "bernardo@shakespeare.lit/pda" || parsed.Id != "state1" {
+		t.Errorf("unexpected message attributes: %+v", parsed.Attrs)
+	}
+	var paused stanza.Paused
+	if !parsed.Get(&paused) {
+		t.Errorf("paused chat state not decoded: %+v", parsed.Extensions)
+	}
+
+	if msg = stanza.NewChatStateMessage("typing", "francisco@shakespeare.lit", "", ""); len(msg.Extensions) != 0 {
+		t.Errorf("unknown chat state should not be added: %+v", msg.Extensions)
+	}
+}
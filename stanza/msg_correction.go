@@ -0,0 +1,32 @@
+package stanza
+
+import (
+	"encoding/xml"
+)
+
+/*
+Support for:
+- XEP-0308 - Last Message Correction: https://xmpp.org/extensions/xep-0308.html
+*/
+
+const NSMsgCorrect = "urn:xmpp:message-correct:0"
+
+// Replace marks a message as the correction of the message with the id Id.
+type Replace struct {
+	MsgExtension
+	XMLName xml.Name `xml:"urn:xmpp:message-correct:0 replace"`
+	ID      string   `xml:"id,attr"`
+}
+
+// GetReplaceId returns the id of the message corrected by the message, if any.
+func (msg *Message) GetReplaceId() string {
+	var r Replace
+	if msg.Get(&r) {
+		return r.ID
+	}
+	return ""
+}
+
+func init() {
+	TypeRegistry.MustRegister(PKTMessage, xml.Name{Space: NSMsgCorrect, Local: "replace"}, Replace{})
+}
@@ -0,0 +1,115 @@
+package stanza
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+/*
+Support for:
+- XEP-0203 - Delayed Delivery: https://xmpp.org/extensions/xep-0203.html
+*/
+
+const NSDelay = "urn:xmpp:delay"
+
+// Delay records when a stanza was originally sent, for stanzas delivered late, such as offline or
+// archived messages. From is the entity that delayed the stanza.
+//
+// Stamp is encoded in UTC, in the XEP-0082 date and time profile. The stamp of a received delay is
+// kept as received in RawStamp: when it cannot be parsed, Stamp is the zero time and Time returns
+// the error, so that a malformed stamp does not prevent decoding the stanza.
+type Delay struct {
+	MsgExtension
+	XMLName  xml.Name  `xml:"urn:xmpp:delay delay"`
+	From     string    `xml:"from,attr,omitempty"`
+	Stamp    time.Time `xml:"-"`
+	RawStamp string    `xml:"-"`
+	Reason   string    `xml:",chardata"`
+}
+
+// NewDelay creates a delay for a stanza originally sent at t.
+func NewDelay(t time.Time, from string) Delay {
+	return Delay{
+		XMLName: xml.Name{Space: NSDelay, Local: "delay"},
+		From:    from,
+		Stamp:   t,
+	}
+}
+
+// Time returns the stamp of the delay, or InvalidDateInput when the stamp is missing or the received
+// stamp could not be parsed.
+func (d Delay) Time() (time.Time, error) {
+	if d.Stamp.IsZero() {
+		return time.Time{}, InvalidDateInput
+	}
+	return d.Stamp, nil
+}
+
+// Delay returns the stamp of the delay of the message, or the zero time when the message was not
+// delayed.
+func (msg *Message) Delay() time.Time {
+	for _, ext := range msg.Extensions {
+		if d, ok := delayOf(ext); ok {
+			return d.Stamp
+		}
+	}
+	return time.Time{}
+}
+
+// Delay returns the stamp of the delay of the presence, or the zero time when the presence was not
+// delayed.
+func (pres *Presence) Delay() time.Time {
+	for _, ext := range pres.Extensions {
+		if d, ok := delayOf(ext); ok {
+			return d.Stamp
+		}
+	}
+	return time.Time{}
+}
+
+// delayOf returns the delay of an extension, decoded as *Delay or added as Delay.
+func delayOf(ext interface{}) (Delay, bool) {
+	switch d := ext.(type) {
+	case *Delay:
+		if d != nil {
+			return *d, true
+		}
+	case Delay:
+		return d, true
+	}
+	return Delay{}, false
+}
+
+// delayAlias has the XML encoding of Delay, with the stamp as text.
+type delayAlias struct {
+	XMLName xml.Name `xml:"urn:xmpp:delay delay"`
+	From    string   `xml:"from,attr,omitempty"`
+	Stamp   string   `xml:"stamp,attr,omitempty"`
+	Reason  string   `xml:",chardata"`
+}
+
+func (d *Delay) UnmarshalXML(decoder *xml.Decoder, start xml.StartElement) error {
+	var alias delayAlias
+	if err := decoder.DecodeElement(&alias, &start); err != nil {
+		return err
+	}
+	// An invalid stamp leaves the zero time: Time reports the error
+	stamp, _ := time.Parse(time.RFC3339Nano, alias.Stamp)
+	*d = Delay{XMLName: alias.XMLName, From: alias.From, Stamp: stamp, RawStamp: alias.Stamp, Reason: alias.Reason}
+	return nil
+}
+
+func (d Delay) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	alias := delayAlias{From: d.From, Reason: d.Reason}
+	if !d.Stamp.IsZero() {
+		alias.Stamp = d.Stamp.UTC().Format(time.RFC3339Nano)
+	} else {
+		alias.Stamp = d.RawStamp
+	}
+	return e.EncodeElement(alias, xml.StartElement{Name: xml.Name{Space: NSDelay, Local: "delay"}})
+}
+
+func init() {
+	TypeRegistry.MustRegister(PKTMessage, xml.Name{Space: NSDelay, Local: "delay"}, Delay{})
+	TypeRegistry.MustRegister(PKTPresence, xml.Name{Space: NSDelay, Local: "delay"}, Delay{})
+}
@@ -0,0 +1,121 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestDecodeDelay(t *testing.T) {
+	str := `<message from='coven@chat.shakespeare.lit/firstwitch' id='162BEBB1-F6DB-4D9A-9BD8-CFDCC801A0B2'
+    to='hecate@shakespeare.lit/broom' type='groupchat'>
+  <body>Thrice the brinded cat hath mew'd.</body>
+  <delay xmlns='urn:xmpp:delay' from='coven@chat.shakespeare.lit' stamp='2002-10-13T23:58:37.123+02:00'>Offline Storage</delay>
+</message>`
+	var msg stanza.Message
+	if err := xml.Unmarshal([]byte(str), &msg); err != nil {
+		t.Fatalf("could not unmarshal message: %v", err)
+	}
+
+	var delay stanza.Delay
+	if !msg.Get(&delay) {
+		t.Fatalf("delay not found: %+v", msg.Extensions)
+	}
+	expected := time.Date(2002, 10, 13, 21, 58, 37, 123000000, time.UTC)
+	if !delay.Stamp.Equal(expected) || delay.From != "coven@chat.shakespeare.lit" || delay.Reason != "Offline Storage" {
+		t.Errorf("unexpected delay: %+v", delay)
+	}
+}
+
+func TestDelayRoundTrip(t *testing.T) {
+	msg := stanza.NewMessage(stanza.Attrs{To: "hecate@shakespeare.lit"})
+	delay := stanza.NewDelay(time.Date(2024, 1, 15, 11, 0, 0, 0, time.FixedZone("CET", 3600)), "shakespeare.lit")
+	delay.Reason = "Offline Storage"
+	msg.Extensions = append(msg.Extensions, delay)
+
+	data, err := xml.Marshal(msg)
+	if err != nil {
+		t.Fatalf("could not marshal message: %v", err)
+	}
+	if !strings.Contains(string(data), `<delay xmlns="urn:xmpp:delay" from="shakespeare.lit" stamp="2024-01-15T10:00:00Z">Offline Storage</delay>`) {
+		t.Errorf("unexpected delay encoding: %s", data)
+	}
+
+	var parsed stanza.Message
+	if err = xml.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("could not unmarshal message: %v", err)
+	}
+	var decoded stanza.Delay
+	if !parsed.Get(&decoded) || !decoded.Stamp.Equal(delay.Stamp) || decoded.Reason != delay.Reason {
+		t.Errorf("unexpected decoded delay: %+v", decoded)
+	}
+}
+
+func TestDelayInvalidStamp(t *testing.T) {
+	str := `<message from='romeo@montague.lit/orchard'><body>Hi</body>` +
+		`<delay xmlns='urn:xmpp:delay' stamp='2002-09-10 23:08:25'/></message>`
+	var msg stanza.Message
+	if err := xml.Unmarshal([]byte(str), &msg); err != nil {
+		t.Fatalf("an invalid stamp should not prevent decoding the message: %v", err)
+	}
+	var delay stanza.Delay
+	if !msg.Get(&delay) {
+		t.Fatal("delay not found")
+	}
+	if _, err := delay.Time(); !errors.Is(err, stanza.InvalidDateInput) {
+		t.Errorf("invalid stamp should be reported, got %v", err)
+	}
+	if !delay.Stamp.IsZero() || delay.RawStamp != "2002-09-10 23:08:25" {
+		t.Errorf("unexpected stamp %v, raw %q", delay.Stamp, delay.RawStamp)
+	}
+}
+
+func TestDelayStampFormats(t *testing.T) {
+	tests := []struct {
+		stamp    string
+		expected time.Time
+	}{
+		{"2019-01-01T12:00:00Z", time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)},
+		{"2019-01-01T12:00:00.5Z", time.Date(2019, 1, 1, 12, 0, 0, 500000000, time.UTC)},
+		{"2019-01-01T12:00:00.123456789Z", time.Date(2019, 1, 1, 12, 0, 0, 123456789, time.UTC)},
+		{"2019-01-01T14:30:00+02:30", time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)},
+		{"2019-01-01T07:00:00.25-05:00", time.Date(2019, 1, 1, 12, 0, 0, 250000000, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.stamp, func(t *testing.T) {
+			var delay stanza.Delay
+			if err := xml.Unmarshal([]byte(`<delay xmlns='urn:xmpp:delay' stamp='`+tt.stamp+`'/>`), &delay); err != nil {
+				t.Fatalf("could not unmarshal delay: %v", err)
+			}
+			if !delay.Stamp.Equal(tt.expected) {
+				t.Errorf("unexpected stamp: %v, expected %v", delay.Stamp, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStanzaDelay(t *testing.T) {
+	str := `<presence from='coven@chat.shakespeare.lit/firstwitch'>` +
+		`<delay xmlns='urn:xmpp:delay' stamp='2002-09-10T23:41:07Z'/></presence>`
+	var pres stanza.Presence
+	if err := xml.Unmarshal([]byte(str), &pres); err != nil {
+		t.Fatalf("could not unmarshal presence: %v", err)
+	}
+	if expected := time.Date(2002, 9, 10, 23, 41, 7, 0, time.UTC); !pres.Delay().Equal(expected) {
+		t.Errorf("unexpected presence delay: %v", pres.Delay())
+	}
+
+	msg := stanza.NewMessage(stanza.Attrs{To: "hecate@shakespeare.lit"})
+	if !msg.Delay().IsZero() {
+		t.Errorf("message without delay should have a zero delay: %v", msg.Delay())
+	}
+	sent := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	msg.Extensions = append(msg.Extensions, stanza.NewDelay(sent, ""))
+	if !msg.Delay().Equal(sent) {
+		t.Errorf("unexpected message delay: %v", msg.Delay())
+	}
+}
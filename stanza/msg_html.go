@@ -2,21 +2,121 @@ package stanza
 
 import (
 	"encoding/xml"
+	"sort"
 )
 
+// HTML is the XHTML-IM (XEP-0071) representation of the message body. As the plain body, it can be
+// provided in several languages: Body is the XHTML body in the language of the message, and Bodies
+// the XHTML bodies in other languages.
 type HTML struct {
 	MsgExtension
 	XMLName xml.Name `xml:"http://jabber.org/protocol/xhtml-im html"`
 	Body    HTMLBody
-	Lang    string `xml:"xml:lang,attr,omitempty"`
+	// Bodies maps language tags to the inner XML of the body in that language
+	Bodies map[string]string
+	Lang   string `xml:"xml:lang,attr,omitempty"`
 }
 
 type HTMLBody struct {
 	XMLName xml.Name `xml:"http://www.w3.org/1999/xhtml body"`
+	Lang    string   `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
 	// InnerXML MUST be valid xhtml. We do not check if it is valid when generating the XMPP stanza.
 	InnerXML string `xml:",innerxml"`
 }
 
+// NewXHTMLMessage creates a message with a plain body and its XHTML representation, in the language
+// lang. lang can be empty to use the default language of the stream.
+func NewXHTMLMessage(a Attrs, lang, body, html string) Message {
+	a.Lang = lang
+	msg := NewMessage(a)
+	msg.Body = body
+	msg.Extensions = append(msg.Extensions, HTML{Body: HTMLBody{InnerXML: html}})
+	return msg
+}
+
+// GetXHTMLBody returns the inner XML of the body in the language lang, or of the body in the
+// language of the message when there is no body in that language. When all the bodies have a
+// language tag, the one in the language of the html element is used, or the first by language tag.
+func (h HTML) GetXHTMLBody(lang string) string {
+	if html, ok := h.Bodies[lang]; ok && lang != "" {
+		return html
+	}
+	if h.Body.InnerXML != "" || len(h.Bodies) == 0 {
+		return h.Body.InnerXML
+	}
+	if html, ok := h.Bodies[h.Lang]; ok {
+		return html
+	}
+	return h.Bodies[h.langs()[0]]
+}
+
+// langs returns the language tags of Bodies, sorted.
+func (h HTML) langs() []string {
+	langs := make([]string, 0, len(h.Bodies))
+	for lang := range h.Bodies {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// htmlElement is the XML representation of HTML, with all the bodies in a list.
+type htmlElement struct {
+	XMLName xml.Name   `xml:"http://jabber.org/protocol/xhtml-im html"`
+	Lang    string     `xml:"xml:lang,attr,omitempty"`
+	Bodies  []HTMLBody `xml:"http://www.w3.org/1999/xhtml body"`
+}
+
+// MarshalXML encodes the body in the language of the message first, then the other bodies sorted
+// by language tag.
+func (h HTML) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	elt := htmlElement{Lang: h.Lang}
+	if h.Body.InnerXML != "" || len(h.Bodies) == 0 {
+		elt.Bodies = append(elt.Bodies, HTMLBody{Lang: h.Body.Lang, InnerXML: h.Body.InnerXML})
+	}
+	for _, lang := range h.langs() {
+		elt.Bodies = append(elt.Bodies, HTMLBody{Lang: lang, InnerXML: h.Bodies[lang]})
+	}
+	return e.Encode(elt)
+}
+
+// UnmarshalXML decodes the body without language as Body, and the other bodies in Bodies. When all
+// the bodies have a language tag, which is common, Body is the one in the language of the html
+// element, or the first one, keeping its language tag.
+func (h *HTML) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var elt htmlElement
+	if err := d.DecodeElement(&elt, &start); err != nil {
+		return err
+	}
+	*h = HTML{XMLName: elt.XMLName, Lang: elt.Lang}
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "lang" {
+			h.Lang = attr.Value
+		}
+	}
+	for _, body := range elt.Bodies {
+		if body.Lang == "" {
+			h.Body = body
+			continue
+		}
+		if h.Bodies == nil {
+			h.Bodies = make(map[string]string)
+		}
+		h.Bodies[body.Lang] = body.InnerXML
+	}
+	if h.Body.XMLName.Local == "" && len(elt.Bodies) > 0 {
+		h.Body = elt.Bodies[0]
+		for _, body := range elt.Bodies {
+			if body.Lang == h.Lang {
+				h.Body = body
+				break
+			}
+		}
+		delete(h.Bodies, h.Body.Lang)
+	}
+	return nil
+}
+
 func init() {
-	TypeRegistry.MapExtension(PKTMessage, xml.Name{Space: "http://jabber.org/protocol/xhtml-im", Local: "html"}, HTML{})
+	TypeRegistry.MustRegister(PKTMessage, xml.Name{Space: "http://jabber.org/protocol/xhtml-im", Local: "html"}, HTML{})
 }
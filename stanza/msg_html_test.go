@@ -2,6 +2,7 @@ package stanza_test
 
 import (
 	"encoding/xml"
+	"strings"
 	"testing"
 
 	"gosrc.io/xmpp/stanza"
@@ -42,3 +43,79 @@ func TestHTMLGen(t *testing.T) {
 		t.Errorf("could not extract html body: '%s'", h.Body.InnerXML)
 	}
 }
+
+func TestXHTMLMultipleLanguages(t *testing.T) {
+	msg := stanza.NewXHTMLMessage(stanza.Attrs{To: "juliet@capulet.lit"}, "en", "Hello", "<p>Hello</p>")
+	html := msg.Extensions[0].(stanza.HTML)
+	html.Bodies = map[string]string{"de": "<p>Hallo</p>"}
+	msg.Extensions[0] = html
+
+	result := msg.XMPPFormat()
+	str := `<message to="juliet@capulet.lit" xml:lang="en"><body>Hello</body><html xmlns="http://jabber.org/protocol/xhtml-im"><body xmlns="http://www.w3.org/1999/xhtml"><p>Hello</p></body><body xmlns="http://www.w3.org/1999/xhtml" xml:lang="de"><p>Hallo</p></body></html></message>`
+	if result != str {
+		t.Errorf("incorrect serialize message:\n%s", result)
+	}
+
+	parsedMessage := stanza.Message{}
+	if err := xml.Unmarshal([]byte(`<message to='juliet@capulet.lit' xml:lang='en'>
+  <body>Hello</body>
+  <html xmlns='http://jabber.org/protocol/xhtml-im'>
+    <body xmlns='http://www.w3.org/1999/xhtml'><p>Hello</p></body>
+    <body xmlns='http://www.w3.org/1999/xhtml' xml:lang='de'><p>Hallo</p></body>
+  </html>
+</message>`), &parsedMessage); err != nil {
+		t.Fatalf("message HTML unmarshall error: %v", err)
+	}
+	var h stanza.HTML
+	if ok := parsedMessage.Get(&h); !ok {
+		t.Fatal("could not extract HTML body")
+	}
+	if len(h.Bodies) != 1 || h.GetXHTMLBody("de") != "<p>Hallo</p>" {
+		t.Errorf("unexpected german body: %+v", h.Bodies)
+	}
+	if h.GetXHTMLBody("en") != "<p>Hello</p>" || h.GetXHTMLBody("fr") != "<p>Hello</p>" {
+		t.Errorf("default body should be returned: %q", h.Body.InnerXML)
+	}
+}
+
+func TestHTMLTaggedBodies(t *testing.T) {
+	var parsedMessage stanza.Message
+	if err := xml.Unmarshal([]byte(`<message to='juliet@capulet.lit'>
+  <body xml:lang='en'>Hello</body>
+  <html xmlns='http://jabber.org/protocol/xhtml-im'>
+    <body xmlns='http://www.w3.org/1999/xhtml' xml:lang='en'><p>Hello</p></body>
+    <body xmlns='http://www.w3.org/1999/xhtml' xml:lang='de'><p>Hallo</p></body>
+  </html>
+</message>`), &parsedMessage); err != nil {
+		t.Fatalf("message HTML unmarshall error: %v", err)
+	}
+	var h stanza.HTML
+	if ok := parsedMessage.Get(&h); !ok {
+		t.Fatal("could not extract HTML body")
+	}
+	if h.GetXHTMLBody("") != "<p>Hello</p>" || h.GetXHTMLBody("fr") != "<p>Hello</p>" {
+		t.Errorf("first body should be the default: %+v", h)
+	}
+	if h.GetXHTMLBody("en") != "<p>Hello</p>" || h.GetXHTMLBody("de") != "<p>Hallo</p>" {
+		t.Errorf("unexpected bodies: %+v", h)
+	}
+
+	// The body is marshaled with its language
+	data, err := xml.Marshal(h)
+	if err != nil {
+		t.Fatalf("cannot marshal HTML: %v", err)
+	}
+	if strings.Count(string(data), "<body") != 2 || !strings.Contains(string(data), `xml:lang="en"><p>Hello</p>`) {
+		t.Errorf("unexpected XML: %s", data)
+	}
+
+	// The body in the language of the element is preferred
+	built := stanza.HTML{Lang: "de", Bodies: map[string]string{"de": "<p>Hallo</p>", "en": "<p>Hello</p>"}}
+	if built.GetXHTMLBody("") != "<p>Hallo</p>" {
+		t.Errorf("body in the language of the element should be the default: %q", built.GetXHTMLBody(""))
+	}
+	built.Lang = ""
+	if built.GetXHTMLBody("fr") != "<p>Hallo</p>" {
+		t.Errorf("first body by language tag should be the default: %q", built.GetXHTMLBody("fr"))
+	}
+}
@@ -0,0 +1,29 @@
+package stanza
+
+import (
+	"errors"
+	"strings"
+)
+
+// ============================================================================
+// MUC private messages
+
+// NewMUCPrivateMessage builds a private message sent to an occupant of a room, through the room.
+// The nickname is used as is as resource of the recipient JID: it does not need escaping.
+// See XEP-0045 - 7.5 Sending a Private Message
+func NewMUCPrivateMessage(roomJid, nick, body string) (Message, error) {
+	if roomJid == "" {
+		return Message{}, errors.New("a room JID is required to send a private message")
+	}
+	if strings.TrimSpace(nick) == "" {
+		return Message{}, errors.New("an occupant nickname is required to send a private message")
+	}
+	if !IsValidXMLText(nick) {
+		return Message{}, errors.New("occupant nickname contains characters not allowed in XML")
+	}
+	msg := NewMessage(Attrs{To: roomJid + "/" + nick, Type: MessageTypeChat})
+	msg.Body = body
+	// Lets the recipient client know the message is a private message from a room occupant
+	msg.Extensions = append(msg.Extensions, MucUser{})
+	return msg, nil
+}
@@ -0,0 +1,44 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestNewMUCPrivateMessage(t *testing.T) {
+	for _, nick := range []string{"thirdwitch", `Dr. "Who" <&> 'Co'`, "romeo@montague/lit", "Ĳsbrand 🎭"} {
+		msg, err := stanza.NewMUCPrivateMessage("coven@chat.shakespeare.lit", nick, "I'll give thee a wind.")
+		if err != nil {
+			t.Fatalf("could not build private message to %q: %v", nick, err)
+		}
+		data, err := xml.Marshal(msg)
+		if err != nil {
+			t.Fatalf("could not marshal private message: %v", err)
+		}
+		var parsed stanza.Message
+		if err = xml.Unmarshal(data, &parsed); err != nil {
+			t.Fatalf("could not parse private message %s: %v", data, err)
+		}
+		if parsed.To != "coven@chat.shakespeare.lit/"+nick || parsed.Type != stanza.MessageTypeChat {
+			t.Errorf("unexpected private message: %s", data)
+		}
+		jid, err := stanza.NewJid(parsed.To)
+		if err != nil || jid.Resource != nick {
+			t.Errorf("nickname should be the resource of the recipient: %+v, %v", jid, err)
+		}
+		var muc stanza.MucUser
+		if !parsed.Get(&muc) {
+			t.Errorf("private message should carry the muc#user element")
+		}
+	}
+}
+
+func TestNewMUCPrivateMessageInvalidNick(t *testing.T) {
+	for _, nick := range []string{"", "  ", "bad\x01nick"} {
+		if _, err := stanza.NewMUCPrivateMessage("coven@chat.shakespeare.lit", nick, "hello"); err == nil {
+			t.Errorf("nickname %q should be rejected", nick)
+		}
+	}
+}
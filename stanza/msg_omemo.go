@@ -0,0 +1,185 @@
+package stanza
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+/*
+Support for:
+- XEP-0384 - OMEMO Encryption: https://xmpp.org/extensions/xep-0384.html
+  Both the legacy (0.3, "eu.siacs.conversations.axolotl") and current ("urn:xmpp:omemo:2") namespaces are supported.
+  Only the transport is handled here: encryption is left to the application.
+*/
+
+const (
+	NSOmemoLegacy = "eu.siacs.conversations.axolotl"
+	NSOmemo       = "urn:xmpp:omemo:2"
+
+	// PEP nodes used to publish device lists and bundles
+	NodeOmemoLegacyDevices = "eu.siacs.conversations.axolotl.devicelist"
+	// Legacy bundles are published on one node per device: the device id must be appended to the prefix.
+	NodeOmemoLegacyBundlesPrefix = "eu.siacs.conversations.axolotl.bundles:"
+	NodeOmemoDevices             = "urn:xmpp:omemo:2:devices"
+	NodeOmemoBundles             = "urn:xmpp:omemo:2:bundles"
+)
+
+// Base64Data is binary data, transported as base64 encoded text.
+type Base64Data []byte
+
+func (b Base64Data) MarshalText() ([]byte, error) {
+	out := make([]byte, base64.StdEncoding.EncodedLen(len(b)))
+	base64.StdEncoding.Encode(out, b)
+	return out, nil
+}
+
+func (b *Base64Data) UnmarshalText(text []byte) error {
+	// Some clients wrap base64 content on several lines
+	s := strings.Join(strings.Fields(string(text)), "")
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	*b = data
+	return nil
+}
+
+// ============================================================================
+// Encrypted message
+
+// OmemoEncrypted is the encrypted element of an OMEMO message. XMLName holds the namespace of the
+// OMEMO version in use: use NewOmemoEncrypted to set it when building a message.
+type OmemoEncrypted struct {
+	MsgExtension
+	XMLName xml.Name
+	Header  OmemoHeader `xml:"header"`
+	// Payload is absent for key transport messages
+	Payload Base64Data `xml:"payload,omitempty"`
+}
+
+// NewOmemoEncrypted creates an encrypted element for the given namespace (NSOmemo or NSOmemoLegacy).
+func NewOmemoEncrypted(namespace string, sid uint32) (*OmemoEncrypted, error) {
+	if namespace != NSOmemo && namespace != NSOmemoLegacy {
+		return nil, errors.New("unsupported OMEMO namespace: " + namespace)
+	}
+	return &OmemoEncrypted{
+		XMLName: xml.Name{Space: namespace, Local: "encrypted"},
+		Header:  OmemoHeader{Sid: sid},
+	}, nil
+}
+
+// IsLegacy tells if the message uses the legacy (0.3) OMEMO namespace.
+func (o *OmemoEncrypted) IsLegacy() bool {
+	return o.XMLName.Space == NSOmemoLegacy
+}
+
+// OmemoHeader holds the sender device id and the message key encrypted for each recipient device.
+// Legacy OMEMO lists keys directly in the header and carries the IV; OMEMO 2 groups keys per recipient JID.
+type OmemoHeader struct {
+	Sid     uint32      `xml:"sid,attr"`
+	Keys    []OmemoKey  `xml:"key,omitempty"`
+	JidKeys []OmemoKeys `xml:"keys,omitempty"`
+	IV      Base64Data  `xml:"iv,omitempty"`
+}
+
+// OmemoKeys are the keys for the devices of a recipient JID (OMEMO 2).
+type OmemoKeys struct {
+	Jid  string     `xml:"jid,attr"`
+	Keys []OmemoKey `xml:"key"`
+}
+
+// OmemoKey is the message key encrypted for the recipient device Rid.
+// PreKey (legacy) and Kex (OMEMO 2) are kept as received, to preserve the exact attribute value:
+// use IsKeyExchange to read them.
+type OmemoKey struct {
+	Rid    uint32     `xml:"rid,attr"`
+	PreKey string     `xml:"prekey,attr,omitempty"`
+	Kex    string     `xml:"kex,attr,omitempty"`
+	Data   Base64Data `xml:",chardata"`
+}
+
+// IsKeyExchange tells if the key is a key exchange (prekey) message.
+func (k OmemoKey) IsKeyExchange() bool {
+	return isXMLTrue(k.PreKey) || isXMLTrue(k.Kex)
+}
+
+// KeysFor returns the keys encrypted for the given device, looking both in legacy and OMEMO 2 header layouts.
+func (h OmemoHeader) KeysFor(rid uint32) []OmemoKey {
+	var keys []OmemoKey
+	for _, k := range h.Keys {
+		if k.Rid == rid {
+			keys = append(keys, k)
+		}
+	}
+	for _, group := range h.JidKeys {
+		for _, k := range group.Keys {
+			if k.Rid == rid {
+				keys = append(keys, k)
+			}
+		}
+	}
+	return keys
+}
+
+func isXMLTrue(v string) bool {
+	b, err := strconv.ParseBool(v)
+	return err == nil && b
+}
+
+// ============================================================================
+// PEP payloads
+
+// OmemoDeviceList is the list of devices of a user, published on NodeOmemoDevices (devices element)
+// or NodeOmemoLegacyDevices (list element).
+type OmemoDeviceList struct {
+	XMLName xml.Name
+	Devices []OmemoDevice `xml:"device"`
+}
+
+type OmemoDevice struct {
+	Id uint32 `xml:"id,attr"`
+	// Label is only available with OMEMO 2
+	Label string `xml:"label,attr,omitempty"`
+}
+
+// OmemoBundle is the OMEMO 2 bundle of a device, published on NodeOmemoBundles.
+type OmemoBundle struct {
+	XMLName               xml.Name      `xml:"urn:xmpp:omemo:2 bundle"`
+	SignedPreKey          OmemoPreKey   `xml:"spk"`
+	SignedPreKeySignature Base64Data    `xml:"spks"`
+	IdentityKey           Base64Data    `xml:"ik"`
+	PreKeys               []OmemoPreKey `xml:"prekeys>pk"`
+}
+
+// OmemoPreKey is a public pre key, identified by its id.
+type OmemoPreKey struct {
+	Id   uint32     `xml:"id,attr"`
+	Data Base64Data `xml:",chardata"`
+}
+
+// OmemoLegacyBundle is the legacy bundle of a device, published on NodeOmemoLegacyBundlesPrefix + device id.
+type OmemoLegacyBundle struct {
+	XMLName               xml.Name             `xml:"eu.siacs.conversations.axolotl bundle"`
+	SignedPreKeyPublic    OmemoLegacySignedKey `xml:"signedPreKeyPublic"`
+	SignedPreKeySignature Base64Data           `xml:"signedPreKeySignature"`
+	IdentityKey           Base64Data           `xml:"identityKey"`
+	PreKeys               []OmemoLegacyPreKey  `xml:"prekeys>preKeyPublic"`
+}
+
+type OmemoLegacySignedKey struct {
+	Id   uint32     `xml:"signedPreKeyId,attr"`
+	Data Base64Data `xml:",chardata"`
+}
+
+type OmemoLegacyPreKey struct {
+	Id   uint32     `xml:"preKeyId,attr"`
+	Data Base64Data `xml:",chardata"`
+}
+
+func init() {
+	TypeRegistry.MustRegister(PKTMessage, xml.Name{Space: NSOmemo, Local: "encrypted"}, OmemoEncrypted{})
+	TypeRegistry.MustRegister(PKTMessage, xml.Name{Space: NSOmemoLegacy, Local: "encrypted"}, OmemoEncrypted{})
+}
@@ -0,0 +1,155 @@
+package stanza_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// Based on XEP-0384 examples, with canonical encoding so that the marshalled output must be identical.
+const omemoMessage = `<message id="send1" from="romeo@montague.lit" to="juliet@capulet.lit">` +
+	`<encrypted xmlns="urn:xmpp:omemo:2">` +
+	`<header sid="27183">` +
+	`<keys jid="juliet@capulet.lit"><key rid="31415">a2V5IGZvciAzMTQxNQ==</key><key rid="12321" kex="true">a2V5IGV4Y2hhbmdlIGZvciAxMjMyMQ==</key></keys>` +
+	`<keys jid="romeo@montague.lit"><key rid="4223">a2V5IGZvciA0MjIz</key><key rid="1">AA==</key></keys>` +
+	`</header>` +
+	`<payload>ZW5jcnlwdGVkIHBheWxvYWQ=</payload>` +
+	`</encrypted>` +
+	`</message>`
+
+const omemoLegacyMessage = `<message id="send2" from="romeo@montague.lit" to="juliet@capulet.lit">` +
+	`<encrypted xmlns="eu.siacs.conversations.axolotl">` +
+	`<header sid="27183">` +
+	`<key rid="31415">a2V5IGZvciAzMTQxNQ==</key>` +
+	`<key rid="12321" prekey="1">cHJla2V5IGZvciAxMjMyMQ==</key>` +
+	`<iv>AAECAwQFBgcICQoL</iv>` +
+	`</header>` +
+	`<payload>ZW5jcnlwdGVkIHBheWxvYWQ=</payload>` +
+	`</encrypted>` +
+	`</message>`
+
+func TestOmemoEncryptedRoundTrip(t *testing.T) {
+	for _, input := range []string{omemoMessage, omemoLegacyMessage} {
+		var msg stanza.Message
+		if err := xml.Unmarshal([]byte(input), &msg); err != nil {
+			t.Fatalf("could not unmarshal message: %v", err)
+		}
+		data, err := xml.Marshal(msg)
+		if err != nil {
+			t.Fatalf("could not marshal message: %v", err)
+		}
+		if string(data) != input {
+			t.Errorf("round trip is not exact:\n%s\nexpected:\n%s", data, input)
+		}
+	}
+}
+
+func TestOmemoEncryptedDecode(t *testing.T) {
+	var msg stanza.Message
+	if err := xml.Unmarshal([]byte(omemoMessage), &msg); err != nil {
+		t.Fatalf("could not unmarshal message: %v", err)
+	}
+	var enc stanza.OmemoEncrypted
+	if !msg.Get(&enc) {
+		t.Fatalf("encrypted element not found")
+	}
+	if enc.IsLegacy() || enc.Header.Sid != 27183 || len(enc.Header.JidKeys) != 2 {
+		t.Fatalf("unexpected header: %+v", enc.Header)
+	}
+	if string(enc.Payload) != "encrypted payload" {
+		t.Errorf("payload is not decoded: %q", enc.Payload)
+	}
+	keys := enc.Header.KeysFor(12321)
+	if len(keys) != 1 || !keys[0].IsKeyExchange() || string(keys[0].Data) != "key exchange for 12321" {
+		t.Errorf("unexpected keys for device 12321: %+v", keys)
+	}
+	if keys = enc.Header.KeysFor(1); len(keys) != 1 || !bytes.Equal(keys[0].Data, []byte{0}) {
+		t.Errorf("unexpected keys for device 1: %+v", keys)
+	}
+
+	var legacy stanza.Message
+	if err := xml.Unmarshal([]byte(omemoLegacyMessage), &legacy); err != nil {
+		t.Fatalf("could not unmarshal legacy message: %v", err)
+	}
+	if !legacy.Get(&enc) || !enc.IsLegacy() {
+		t.Fatalf("legacy encrypted element not found")
+	}
+	if !bytes.Equal(enc.Header.IV, []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}) {
+		t.Errorf("unexpected IV: %v", enc.Header.IV)
+	}
+	if keys := enc.Header.KeysFor(12321); len(keys) != 1 || !keys[0].IsKeyExchange() {
+		t.Errorf("legacy prekey not detected: %+v", keys)
+	}
+}
+
+func TestOmemoBase64Wrapped(t *testing.T) {
+	var key stanza.OmemoKey
+	if err := xml.Unmarshal([]byte("<key rid='1'>\n  a2V5IGZv\n  ciAzMTQxNQ==\n</key>"), &key); err != nil {
+		t.Fatalf("could not unmarshal key: %v", err)
+	}
+	if string(key.Data) != "key for 31415" {
+		t.Errorf("wrapped base64 content not decoded: %q", key.Data)
+	}
+	if err := xml.Unmarshal([]byte("<key rid='1'>not base64!</key>"), &key); err == nil {
+		t.Errorf("invalid base64 content should fail")
+	}
+}
+
+func TestOmemoPEPPayloads(t *testing.T) {
+	itemsEvent := `<message from='juliet@capulet.lit' to='romeo@montague.lit'>
+  <event xmlns='http://jabber.org/protocol/pubsub#event'>
+    <items node='urn:xmpp:omemo:2:devices'>
+      <item id='current'>
+        <devices xmlns='urn:xmpp:omemo:2'>
+          <device id='12345'/>
+          <device id='4223' label='Gajim on Ubuntu Linux'/>
+        </devices>
+      </item>
+    </items>
+  </event>
+</message>`
+	var msg stanza.Message
+	if err := xml.Unmarshal([]byte(itemsEvent), &msg); err != nil {
+		t.Fatalf("could not unmarshal event: %v", err)
+	}
+	var event stanza.PubSubEvent
+	if !msg.Get(&event) {
+		t.Fatalf("pubsub event not found")
+	}
+	items, ok := event.EventElement.(*stanza.ItemsEvent)
+	if !ok || len(items.Items) != 1 {
+		t.Fatalf("unexpected event: %+v", event.EventElement)
+	}
+	// Event items use their own type: convert to a pubsub item to decode the payload
+	item := stanza.Item{Id: items.Items[0].Id, Any: items.Items[0].Any}
+	var list stanza.OmemoDeviceList
+	if err := item.DecodePayload(&list); err != nil {
+		t.Fatalf("could not decode device list: %v", err)
+	}
+	if len(list.Devices) != 2 || list.Devices[1].Id != 4223 || list.Devices[1].Label != "Gajim on Ubuntu Linux" {
+		t.Errorf("unexpected device list: %+v", list)
+	}
+
+	bundle := stanza.OmemoBundle{
+		SignedPreKey:          stanza.OmemoPreKey{Id: 0, Data: []byte("spk")},
+		SignedPreKeySignature: []byte("signature"),
+		IdentityKey:           []byte("identity"),
+		PreKeys:               []stanza.OmemoPreKey{{Id: 0, Data: []byte("pk0")}, {Id: 1, Data: []byte("pk1")}},
+	}
+	published, err := stanza.NewPayloadItem("31415", bundle)
+	if err != nil {
+		t.Fatalf("could not create bundle item: %v", err)
+	}
+	if published.Any == nil || published.Any.XMLName != (xml.Name{Space: stanza.NSOmemo, Local: "bundle"}) {
+		t.Fatalf("unexpected bundle item payload: %+v", published.Any)
+	}
+	var decoded stanza.OmemoBundle
+	if err = published.DecodePayload(&decoded); err != nil {
+		t.Fatalf("could not decode bundle: %v", err)
+	}
+	if string(decoded.IdentityKey) != "identity" || len(decoded.PreKeys) != 2 || string(decoded.PreKeys[1].Data) != "pk1" {
+		t.Errorf("bundle does not survive round trip: %+v", decoded)
+	}
+}
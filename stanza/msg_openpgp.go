@@ -0,0 +1,205 @@
+package stanza
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"math/big"
+	"time"
+)
+
+/*
+Support for:
+- XEP-0373 - OpenPGP for XMPP: https://xmpp.org/extensions/xep-0373.html
+  Only the elements are handled here: encryption and signature are left to the application.
+*/
+
+const (
+	NSOpenPGP = "urn:xmpp:openpgp:0"
+
+	// PEP nodes used to publish keys. Public keys are published on one node per key:
+	// the key fingerprint must be appended to the prefix.
+	NodeOpenPGPPublicKeys       = "urn:xmpp:openpgp:0:public-keys"
+	NodeOpenPGPPublicKeysPrefix = "urn:xmpp:openpgp:0:public-keys:"
+	NodeOpenPGPSecretKey        = "urn:xmpp:openpgp:0:secret-key"
+)
+
+// Kinds of OpenPGP content elements
+const (
+	OpenPGPSigncrypt = "signcrypt"
+	OpenPGPSign      = "sign"
+	OpenPGPCrypt     = "crypt"
+)
+
+// OpenPGP is the message extension carrying the OpenPGP message built from an OpenPGPContent element.
+type OpenPGP struct {
+	MsgExtension
+	XMLName xml.Name   `xml:"urn:xmpp:openpgp:0 openpgp"`
+	Data    Base64Data `xml:",chardata"`
+}
+
+// ============================================================================
+// Content elements
+
+// OpenPGPContent is the signcrypt, sign or crypt element the OpenPGP message is built from.
+// Its XML encoding is what the application signs and / or encrypts.
+type OpenPGPContent struct {
+	XMLName xml.Name
+	To      []OpenPGPTo    `xml:"to"`
+	Time    OpenPGPTime    `xml:"time"`
+	Rpad    string         `xml:"rpad,omitempty"`
+	Payload OpenPGPPayload `xml:"payload"`
+}
+
+type OpenPGPTo struct {
+	Jid string `xml:"jid,attr"`
+}
+
+type OpenPGPTime struct {
+	Stamp time.Time `xml:"stamp,attr"`
+}
+
+// NewOpenPGPContent creates a content element of the given kind (OpenPGPSigncrypt, OpenPGPSign or
+// OpenPGPCrypt), for the given recipients. Encrypted elements are given random padding.
+func NewOpenPGPContent(kind string, to ...string) (*OpenPGPContent, error) {
+	c := &OpenPGPContent{
+		XMLName: xml.Name{Space: NSOpenPGP, Local: kind},
+		Time:    OpenPGPTime{Stamp: time.Now().UTC().Truncate(time.Second)},
+	}
+	for _, jid := range to {
+		c.To = append(c.To, OpenPGPTo{Jid: jid})
+	}
+
+	switch kind {
+	case OpenPGPSign:
+	case OpenPGPSigncrypt, OpenPGPCrypt:
+		rpad, err := randomPadding()
+		if err != nil {
+			return nil, err
+		}
+		c.Rpad = rpad
+	default:
+		return nil, errors.New("unknown OpenPGP content element: " + kind)
+	}
+	return c, nil
+}
+
+// Recipients returns the JIDs of the to elements.
+func (c *OpenPGPContent) Recipients() []string {
+	var jids []string
+	for _, to := range c.To {
+		jids = append(jids, to.Jid)
+	}
+	return jids
+}
+
+// randomPadding returns a random string of random length, up to 200 characters, to hide the size of
+// the payload. See XEP-0373 - 3.1 OpenPGP Secured Instant Messaging
+func randomPadding() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(150))
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, n.Int64()+1)
+	if _, err = rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawStdEncoding.EncodeToString(buf), nil
+}
+
+// OpenPGPPayload holds the protected content of the message. Elements are decoded like message
+// elements: the body, and any extension known to the message extension registry.
+// Other elements are ignored.
+type OpenPGPPayload struct {
+	Body       string
+	Extensions []MsgExtension
+}
+
+// Get searches the payload for an extension of the same type as ext, and copies it to ext.
+func (p *OpenPGPPayload) Get(ext MsgExtension) bool {
+	return getMsgExtension(p.Extensions, ext)
+}
+
+func (p *OpenPGPPayload) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		switch tt := t.(type) {
+
+		case xml.StartElement:
+			if msgExt := TypeRegistry.GetMsgExtension(tt.Name); msgExt != nil {
+				if err = d.DecodeElement(msgExt, &tt); err != nil {
+					return err
+				}
+				p.Extensions = append(p.Extensions, msgExt)
+				continue
+			}
+			if tt.Name.Local == "body" {
+				err = d.DecodeElement(&p.Body, &tt)
+			} else {
+				err = d.Skip()
+			}
+			if err != nil {
+				return err
+			}
+
+		case xml.EndElement:
+			if tt == start.End() {
+				return nil
+			}
+		}
+	}
+}
+
+func (p OpenPGPPayload) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if p.Body != "" {
+		body := xml.StartElement{Name: xml.Name{Space: "jabber:client", Local: "body"}}
+		if err := e.EncodeElement(p.Body, body); err != nil {
+			return err
+		}
+	}
+	for _, ext := range p.Extensions {
+		if err := e.Encode(ext); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// ============================================================================
+// PEP payloads
+
+// OpenPGPPublicKey is a public key, published on NodeOpenPGPPublicKeysPrefix + fingerprint.
+type OpenPGPPublicKey struct {
+	XMLName xml.Name   `xml:"urn:xmpp:openpgp:0 pubkey"`
+	Date    *time.Time `xml:"date,attr,omitempty"`
+	Data    Base64Data `xml:"data"`
+}
+
+// OpenPGPPublicKeysList announces the public keys of a user, published on NodeOpenPGPPublicKeys.
+type OpenPGPPublicKeysList struct {
+	XMLName xml.Name               `xml:"urn:xmpp:openpgp:0 public-keys-list"`
+	Keys    []OpenPGPPublicKeyMeta `xml:"pubkey-metadata"`
+}
+
+type OpenPGPPublicKeyMeta struct {
+	Fingerprint string    `xml:"v4-fingerprint,attr"`
+	Date        time.Time `xml:"date,attr"`
+}
+
+// OpenPGPSecretKey is the encrypted backup of the secret key, published on NodeOpenPGPSecretKey.
+type OpenPGPSecretKey struct {
+	XMLName xml.Name   `xml:"urn:xmpp:openpgp:0 secretkey"`
+	Data    Base64Data `xml:",chardata"`
+}
+
+func init() {
+	TypeRegistry.MustRegister(PKTMessage, xml.Name{Space: NSOpenPGP, Local: "openpgp"}, OpenPGP{})
+}
@@ -0,0 +1,145 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestOpenPGPMessage(t *testing.T) {
+	// XEP-0373 - Example 1
+	input := `<message to='juliet@example.org' from='romeo@example.net/orchard' type='chat'>
+  <openpgp xmlns='urn:xmpp:openpgp:0'>BASE64_OPENPGP_MESSAGE_CONTAINING_CONTENT_ELEMENT</openpgp>
+</message>`
+	var msg stanza.Message
+	if err := xml.Unmarshal([]byte(input), &msg); err == nil {
+		t.Errorf("invalid base64 content should be rejected")
+	}
+
+	input = strings.Replace(input, "BASE64_OPENPGP_MESSAGE_CONTAINING_CONTENT_ELEMENT", "b3BlbnBncCBtZXNzYWdl", 1)
+	msg = stanza.Message{}
+	if err := xml.Unmarshal([]byte(input), &msg); err != nil {
+		t.Fatalf("could not unmarshal message: %v", err)
+	}
+	var pgp stanza.OpenPGP
+	if !msg.Get(&pgp) {
+		t.Fatalf("openpgp element not found")
+	}
+	if string(pgp.Data) != "openpgp message" {
+		t.Errorf("unexpected openpgp data: %q", pgp.Data)
+	}
+	data, err := xml.Marshal(pgp)
+	if err != nil {
+		t.Fatalf("could not marshal openpgp element: %v", err)
+	}
+	if string(data) != `<openpgp xmlns="urn:xmpp:openpgp:0">b3BlbnBncCBtZXNzYWdl</openpgp>` {
+		t.Errorf("unexpected encoding: %s", data)
+	}
+}
+
+func TestOpenPGPContentPayload(t *testing.T) {
+	// XEP-0373 - Example 2, with a chat state extension in the payload
+	input := `<signcrypt xmlns='urn:xmpp:openpgp:0'>
+  <to jid='juliet@example.org'/>
+  <time stamp='2014-07-10T17:06:00+02:00'/>
+  <rpad>f0rm1l4n4-mT8y33j!Y%fRSrcd^ZE4Q7VDt1L%WEgR!kv</rpad>
+  <payload>
+    <body xmlns='jabber:client'>This is a secret message.</body>
+    <active xmlns='http://jabber.org/protocol/chatstates'/>
+    <unknown xmlns='urn:example:unknown'><body>ignored</body></unknown>
+  </payload>
+</signcrypt>`
+	var content stanza.OpenPGPContent
+	if err := xml.Unmarshal([]byte(input), &content); err != nil {
+		t.Fatalf("could not unmarshal content: %v", err)
+	}
+	if content.XMLName.Local != stanza.OpenPGPSigncrypt {
+		t.Errorf("unexpected content element: %v", content.XMLName)
+	}
+	if r := content.Recipients(); len(r) != 1 || r[0] != "juliet@example.org" {
+		t.Errorf("unexpected recipients: %v", r)
+	}
+	if !content.Time.Stamp.Equal(time.Date(2014, 7, 10, 15, 6, 0, 0, time.UTC)) {
+		t.Errorf("unexpected time: %v", content.Time.Stamp)
+	}
+	if content.Payload.Body != "This is a secret message." {
+		t.Errorf("unexpected body: %q", content.Payload.Body)
+	}
+	var state stanza.StateActive
+	if len(content.Payload.Extensions) != 1 || !content.Payload.Get(&state) {
+		t.Errorf("unexpected payload extensions: %+v", content.Payload.Extensions)
+	}
+
+	// Content must survive the encoding sent to the OpenPGP library
+	data, err := xml.Marshal(content)
+	if err != nil {
+		t.Fatalf("could not marshal content: %v", err)
+	}
+	var parsed stanza.OpenPGPContent
+	if err = xml.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("could not unmarshal marshalled content: %v", err)
+	}
+	if parsed.Rpad != content.Rpad || parsed.Payload.Body != content.Payload.Body ||
+		!parsed.Payload.Get(&state) || !parsed.Time.Stamp.Equal(content.Time.Stamp) {
+		t.Errorf("content does not survive round trip: %s", data)
+	}
+}
+
+func TestNewOpenPGPContent(t *testing.T) {
+	for _, kind := range []string{stanza.OpenPGPSigncrypt, stanza.OpenPGPSign, stanza.OpenPGPCrypt} {
+		content, err := stanza.NewOpenPGPContent(kind, "juliet@example.org", "benvolio@example.org")
+		if err != nil {
+			t.Fatalf("could not create %s element: %v", kind, err)
+		}
+		if content.XMLName.Space != stanza.NSOpenPGP || content.XMLName.Local != kind || len(content.To) != 2 {
+			t.Errorf("unexpected %s element: %+v", kind, content)
+		}
+		if (kind == stanza.OpenPGPSign) != (content.Rpad == "") {
+			t.Errorf("unexpected padding for %s element: %q", kind, content.Rpad)
+		}
+	}
+	if _, err := stanza.NewOpenPGPContent("encrypt"); err == nil {
+		t.Errorf("unknown content element should be rejected")
+	}
+}
+
+func TestOpenPGPKeys(t *testing.T) {
+	// XEP-0373 - Examples 4 and 7
+	pubkey := `<pubkey xmlns='urn:xmpp:openpgp:0' date='2018-03-01T15:26:12Z'>
+  <data>cHVibGljIGtleQ==</data>
+</pubkey>`
+	var key stanza.OpenPGPPublicKey
+	if err := xml.Unmarshal([]byte(pubkey), &key); err != nil {
+		t.Fatalf("could not unmarshal public key: %v", err)
+	}
+	if string(key.Data) != "public key" || key.Date == nil || key.Date.Year() != 2018 {
+		t.Errorf("unexpected public key: %+v", key)
+	}
+
+	list := `<public-keys-list xmlns='urn:xmpp:openpgp:0'>
+  <pubkey-metadata v4-fingerprint='1357B01865B2503C18453D208CAC2A9678548E35' date='2018-03-01T15:26:12Z'/>
+  <pubkey-metadata v4-fingerprint='67819B343B2AB70DED9320872C6464AF2A8E4C02' date='1953-05-16T12:00:00Z'/>
+</public-keys-list>`
+	var keys stanza.OpenPGPPublicKeysList
+	if err := xml.Unmarshal([]byte(list), &keys); err != nil {
+		t.Fatalf("could not unmarshal public keys list: %v", err)
+	}
+	if len(keys.Keys) != 2 || keys.Keys[1].Fingerprint != "67819B343B2AB70DED9320872C6464AF2A8E4C02" {
+		t.Errorf("unexpected public keys list: %+v", keys)
+	}
+
+	item, err := stanza.NewPayloadItem("current", stanza.OpenPGPSecretKey{Data: []byte("secret key")})
+	if err != nil {
+		t.Fatalf("could not create secret key item: %v", err)
+	}
+	var secret stanza.OpenPGPSecretKey
+	if err = item.DecodePayload(&secret); err != nil {
+		t.Fatalf("could not decode secret key: %v", err)
+	}
+	if string(secret.Data) != "secret key" {
+		t.Errorf("unexpected secret key: %q", secret.Data)
+	}
+}
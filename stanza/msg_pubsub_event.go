@@ -13,7 +13,7 @@ type PubSubEvent struct {
 }
 
 func init() {
-	TypeRegistry.MapExtension(PKTMessage, xml.Name{Space: "http://jabber.org/protocol/pubsub#event", Local: "event"}, PubSubEvent{})
+	TypeRegistry.MustRegister(PKTMessage, xml.Name{Space: "http://jabber.org/protocol/pubsub#event", Local: "event"}, PubSubEvent{})
 }
 
 type EventElement interface {
@@ -136,7 +136,7 @@ func (i ItemsEvent) Name() string {
 
 type RetractEvent struct {
 	XMLName xml.Name `xml:"retract"`
-	ID      string   `xml:"node,attr"`
+	ID      string   `xml:"id,attr"`
 }
 
 // *********************
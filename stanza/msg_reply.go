@@ -0,0 +1,56 @@
+package stanza
+
+import "strings"
+
+// ============================================================================
+// Replies to messages
+
+// NewReply returns an empty reply to the original message, with its type and thread. Replies to
+// groupchat messages are sent to the room, at the bare JID of the occupant, and the other replies to
+// the full JID of the sender. Errors are never answered: false is returned for them, as well as for
+// messages without valid sender.
+func NewReply(original Message) (Message, bool) {
+	if original.Type == MessageTypeError {
+		return Message{}, false
+	}
+	to := ReplyAddress(original.From)
+	if to == "" {
+		return Message{}, false
+	}
+	if original.Type == MessageTypeGroupchat {
+		// The room, without the nickname of the occupant
+		to, _, _ = strings.Cut(to, "/")
+	}
+	reply := NewMessage(Attrs{To: to, Type: original.Type})
+	reply.Thread = original.Thread
+	return reply, true
+}
+
+// ReplyText returns a reply to the original message with the body, as NewReply.
+func ReplyText(original Message, body string) (Message, bool) {
+	reply, ok := NewReply(original)
+	reply.Body = body
+	return reply, ok
+}
+
+// CompleteReply fills the recipient, type and thread of the reply that are not set, from the
+// original message, as NewReply.
+func CompleteReply(original Message, reply Message) (Message, bool) {
+	base, ok := NewReply(original)
+	if !ok {
+		return Message{}, false
+	}
+	if reply.XMLName.Local == "" {
+		reply.XMLName = base.XMLName
+	}
+	if reply.To == "" {
+		reply.To = base.To
+	}
+	if reply.Type == "" {
+		reply.Type = base.Type
+	}
+	if reply.Thread == "" {
+		reply.Thread = base.Thread
+	}
+	return reply, true
+}
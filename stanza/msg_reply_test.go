@@ -0,0 +1,51 @@
+package stanza_test
+
+import (
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestReplyText(t *testing.T) {
+	msg := stanza.NewMessage(stanza.Attrs{From: "romeo@montague.lit/orchard", To: "juliet@capulet.lit", Type: stanza.MessageTypeChat})
+	msg.Thread = "e0ffe42b28561960c6b12b944a092794b9683a38"
+	reply, ok := stanza.ReplyText(msg, "Hello")
+	if !ok {
+		t.Fatal("message should be answered")
+	}
+	if reply.To != msg.From || reply.Type != stanza.MessageTypeChat || reply.Thread != msg.Thread || reply.Body != "Hello" {
+		t.Errorf("unexpected reply: %+v", reply)
+	}
+}
+
+func TestReplyTextGroupchat(t *testing.T) {
+	msg := stanza.NewMessage(stanza.Attrs{From: "coven@chat.shakespeare.lit/thirdwitch", Type: stanza.MessageTypeGroupchat})
+	reply, ok := stanza.ReplyText(msg, "Hello")
+	if !ok || reply.To != "coven@chat.shakespeare.lit" || reply.Type != stanza.MessageTypeGroupchat {
+		t.Errorf("groupchat reply should be sent to the room: %+v", reply)
+	}
+
+	// Private messages from occupants are answered to the occupant
+	msg.Type = stanza.MessageTypeChat
+	if reply, ok = stanza.ReplyText(msg, "Hello"); !ok || reply.To != msg.From {
+		t.Errorf("private reply should be sent to the occupant: %+v", reply)
+	}
+}
+
+func TestReplyTextError(t *testing.T) {
+	msg := stanza.NewMessage(stanza.Attrs{From: "romeo@montague.lit/orchard", Type: stanza.MessageTypeError})
+	if _, ok := stanza.ReplyText(msg, "Hello"); ok {
+		t.Error("errors must not be answered")
+	}
+}
+
+func TestCompleteReply(t *testing.T) {
+	msg := stanza.NewMessage(stanza.Attrs{From: "romeo@montague.lit/orchard", Type: stanza.MessageTypeChat})
+	msg.Thread = "thread1"
+	reply := stanza.Message{Body: "Hello"}
+	reply.To = "juliet@capulet.lit"
+	reply, ok := stanza.CompleteReply(msg, reply)
+	if !ok || reply.To != "juliet@capulet.lit" || reply.Type != stanza.MessageTypeChat || reply.Thread != "thread1" || reply.XMLName.Local != "message" {
+		t.Errorf("unexpected reply: %+v", reply)
+	}
+}
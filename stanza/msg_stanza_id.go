@@ -0,0 +1,52 @@
+package stanza
+
+import (
+	"encoding/xml"
+)
+
+/*
+Support for:
+- XEP-0359 - Unique and Stable Stanza IDs: https://xmpp.org/extensions/xep-0359.html
+*/
+
+const NSStanzaId = "urn:xmpp:sid:0"
+
+// StanzaId is the unique id assigned to a message by the entity in By (usually the server or the MUC room).
+type StanzaId struct {
+	MsgExtension
+	XMLName xml.Name `xml:"urn:xmpp:sid:0 stanza-id"`
+	Id      string   `xml:"id,attr"`
+	By      string   `xml:"by,attr"`
+}
+
+// OriginId is the unique id assigned to a message by its sender.
+type OriginId struct {
+	MsgExtension
+	XMLName xml.Name `xml:"urn:xmpp:sid:0 origin-id"`
+	Id      string   `xml:"id,attr"`
+}
+
+// GetOriginId returns the origin-id of the message, if any.
+func (msg *Message) GetOriginId() string {
+	var oid OriginId
+	if msg.Get(&oid) {
+		return oid.Id
+	}
+	return ""
+}
+
+// GetStanzaId returns the stanza-id assigned to the message by the entity by, if any.
+// Stanza ids must only be trusted if by is the entity expected to assign them.
+func (msg *Message) GetStanzaId(by string) string {
+	for _, ext := range msg.Extensions {
+		if sid, ok := ext.(*StanzaId); ok && sid.By == by {
+			return sid.Id
+		}
+	}
+	return ""
+}
+
+func init() {
+	TypeRegistry.MustRegister(PKTMessage, xml.Name{Space: NSStanzaId, Local: "stanza-id"}, StanzaId{})
+	TypeRegistry.MustRegister(PKTMessage, xml.Name{Space: NSStanzaId, Local: "origin-id"}, OriginId{})
+}
@@ -0,0 +1,32 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// https://xmpp.org/extensions/xep-0359.html#example-1
+func TestDecodeStanzaId(t *testing.T) {
+	str := `<message xmlns='jabber:client' to='room@muc.example.com' type='groupchat' id='msg1'>
+  <body>Hello</body>
+  <stanza-id xmlns='urn:xmpp:sid:0' id='5f3dbc5e-e1d3-4077-a492-693f3769c7ad' by='room@muc.example.com'/>
+  <stanza-id xmlns='urn:xmpp:sid:0' id='other' by='example.com'/>
+  <origin-id xmlns='urn:xmpp:sid:0' id='de305d54-75b4-431b-adb2-eb6b9e546013'/>
+</message>`
+	var msg stanza.Message
+	if err := xml.Unmarshal([]byte(str), &msg); err != nil {
+		t.Fatalf("could not unmarshal message: %v", err)
+	}
+
+	if id := msg.GetOriginId(); id != "de305d54-75b4-431b-adb2-eb6b9e546013" {
+		t.Errorf("unexpected origin-id: %s", id)
+	}
+	if id := msg.GetStanzaId("room@muc.example.com"); id != "5f3dbc5e-e1d3-4077-a492-693f3769c7ad" {
+		t.Errorf("unexpected stanza-id: %s", id)
+	}
+	if id := msg.GetStanzaId("evil.example.com"); id != "" {
+		t.Errorf("stanza-id from an unexpected entity should be ignored: %s", id)
+	}
+}
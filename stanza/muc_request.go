@@ -0,0 +1,117 @@
+package stanza
+
+import (
+	"errors"
+)
+
+/*
+Support for:
+- XEP-0045 - Multi-User Chat: 7.13 Requesting Voice and 8.6 Approving Voice Requests
+  https://xmpp.org/extensions/xep-0045.html#requestvoice
+*/
+
+const NSMucRequest = "http://jabber.org/protocol/muc#request"
+
+const (
+	mucRequestRole  = "muc#role"
+	mucRequestJid   = "muc#jid"
+	mucRequestNick  = "muc#roomnick"
+	mucRequestAllow = "muc#request_allow"
+)
+
+// NewVoiceRequest builds the message a visitor sends to a moderated room to request voice.
+// See XEP-0045 - 7.13 Requesting Voice
+func NewVoiceRequest(roomJid string) (Message, error) {
+	if roomJid == "" {
+		return Message{}, errors.New("a room JID is required to request voice")
+	}
+	msg := NewMessage(Attrs{To: roomJid})
+	form := NewForm([]*Field{
+		{Var: "FORM_TYPE", ValuesList: []string{NSMucRequest}},
+		{Var: mucRequestRole, Type: FieldTypeListSingle, Label: "Requested role", ValuesList: []string{"participant"}},
+	}, FormTypeSubmit)
+	msg.Extensions = append(msg.Extensions, form)
+	return msg, nil
+}
+
+// VoiceRequest is the voice approval request a moderator receives from the room.
+// The original form is kept so that it can be submitted back once filled.
+type VoiceRequest struct {
+	// Room is the room JID the request comes from
+	Room string
+	// Id is the id of the message that carried the request
+	Id string
+	// Role requested by the user, usually "participant"
+	Role string
+	// Jid is the full JID of the requesting user
+	Jid string
+	// Nick is the room nickname of the requesting user
+	Nick string
+
+	Form *Form
+}
+
+// GetVoiceRequest extracts a voice approval request from a message received by a moderator.
+// It returns false if the message does not contain a muc#request form.
+// See XEP-0045 - 8.6 Approving Voice Requests
+func GetVoiceRequest(msg Message) (*VoiceRequest, bool) {
+	for _, ext := range msg.Extensions {
+		form, ok := ext.(*Form)
+		if !ok || form.Type != FormTypeForm || form.FormType() != NSMucRequest {
+			continue
+		}
+		vr := VoiceRequest{
+			Room: msg.From,
+			Id:   msg.Id,
+			Form: form,
+		}
+		if f := form.Field(mucRequestRole); f != nil {
+			vr.Role = f.Value()
+		}
+		if f := form.Field(mucRequestJid); f != nil {
+			vr.Jid = f.Value()
+		}
+		if f := form.Field(mucRequestNick); f != nil {
+			vr.Nick = f.Value()
+		}
+		return &vr, true
+	}
+	return nil, false
+}
+
+// Approve builds the form submission granting voice to the requesting user.
+func (vr *VoiceRequest) Approve() (Message, error) {
+	return vr.answer(true)
+}
+
+// Deny builds the form submission refusing voice to the requesting user.
+func (vr *VoiceRequest) Deny() (Message, error) {
+	return vr.answer(false)
+}
+
+func (vr *VoiceRequest) answer(allow bool) (Message, error) {
+	if vr.Form == nil {
+		return Message{}, errors.New("voice request has no form to submit")
+	}
+	if vr.Room == "" {
+		return Message{}, errors.New("voice request has no room to answer to")
+	}
+
+	// We submit back all the fields we received, with only the approval field changed.
+	var fields []*Field
+	for _, f := range vr.Form.Fields {
+		if f == nil || f.Var == "" || f.Var == mucRequestAllow {
+			continue
+		}
+		fields = append(fields, &Field{Var: f.Var, ValuesList: f.ValuesList})
+	}
+	value := "false"
+	if allow {
+		value = "true"
+	}
+	fields = append(fields, &Field{Var: mucRequestAllow, ValuesList: []string{value}})
+
+	msg := NewMessage(Attrs{To: vr.Room, Id: vr.Id})
+	msg.Extensions = append(msg.Extensions, NewForm(fields, FormTypeSubmit))
+	return msg, nil
+}
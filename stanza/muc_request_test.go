@@ -0,0 +1,140 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// Voice approval request, as sent by ejabberd mod_muc to room moderators
+const ejabberdVoiceApproval = `<message xmlns="jabber:client" from="coven@conference.shakespeare.lit" to="crone1@shakespeare.lit/desktop" id="1573054441652183">
+  <x xmlns="jabber:x:data" type="form">
+    <title>Voice request</title>
+    <instructions>Either approve or decline the voice request.</instructions>
+    <field var="FORM_TYPE" type="hidden">
+      <value>http://jabber.org/protocol/muc#request</value>
+    </field>
+    <field var="muc#role" type="list-single" label="Requested role">
+      <value>participant</value>
+      <option label="Participant"><value>participant</value></option>
+    </field>
+    <field var="muc#jid" type="jid-single" label="User JID">
+      <value>hag66@shakespeare.lit/pda</value>
+    </field>
+    <field var="muc#roomnick" type="text-single" label="Nickname">
+      <value>thirdwitch</value>
+    </field>
+    <field var="muc#request_allow" type="boolean" label="Grant voice to this person?">
+      <value>false</value>
+    </field>
+  </x>
+</message>`
+
+// Voice request from a visitor, as relayed by ejabberd (submit form, no approval data)
+const ejabberdVoiceRequest = `<message xmlns="jabber:client" from="hag66@shakespeare.lit/pda" to="coven@conference.shakespeare.lit" id="yd53c486">
+  <x xmlns="jabber:x:data" type="submit">
+    <field var="FORM_TYPE"><value>http://jabber.org/protocol/muc#request</value></field>
+    <field var="muc#role" type="list-single" label="Requested role"><value>participant</value></field>
+  </x>
+</message>`
+
+func TestNewVoiceRequest(t *testing.T) {
+	msg, err := stanza.NewVoiceRequest("coven@conference.shakespeare.lit")
+	if err != nil {
+		t.Fatalf("could not build voice request: %v", err)
+	}
+	data, err := xml.Marshal(msg)
+	if err != nil {
+		t.Fatalf("could not marshal voice request: %v", err)
+	}
+
+	var parsed stanza.Message
+	if err = xml.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("could not unmarshal voice request: %v", err)
+	}
+	var form stanza.Form
+	if !parsed.Get(&form) {
+		t.Fatalf("voice request does not contain a form: %s", data)
+	}
+	if form.Type != stanza.FormTypeSubmit || form.FormType() != stanza.NSMucRequest {
+		t.Errorf("unexpected voice request form: %s", data)
+	}
+	if role := form.Field("muc#role"); role == nil || role.Value() != "participant" {
+		t.Errorf("voice request should ask for participant role: %s", data)
+	}
+
+	if _, err = stanza.NewVoiceRequest(""); err == nil {
+		t.Errorf("voice request without room should fail")
+	}
+}
+
+func TestGetVoiceRequest(t *testing.T) {
+	var msg stanza.Message
+	if err := xml.Unmarshal([]byte(ejabberdVoiceApproval), &msg); err != nil {
+		t.Fatalf("could not unmarshal approval message: %v", err)
+	}
+
+	vr, ok := stanza.GetVoiceRequest(msg)
+	if !ok {
+		t.Fatalf("voice request not found in message")
+	}
+	if vr.Room != "coven@conference.shakespeare.lit" || vr.Role != "participant" ||
+		vr.Jid != "hag66@shakespeare.lit/pda" || vr.Nick != "thirdwitch" {
+		t.Errorf("unexpected voice request: %+v", vr)
+	}
+
+	// A visitor request (submit form) is not an approval request
+	var req stanza.Message
+	if err := xml.Unmarshal([]byte(ejabberdVoiceRequest), &req); err != nil {
+		t.Fatalf("could not unmarshal request message: %v", err)
+	}
+	if _, ok := stanza.GetVoiceRequest(req); ok {
+		t.Errorf("submitted voice request should not be detected as an approval form")
+	}
+}
+
+func TestVoiceRequestApproveDeny(t *testing.T) {
+	var msg stanza.Message
+	if err := xml.Unmarshal([]byte(ejabberdVoiceApproval), &msg); err != nil {
+		t.Fatalf("could not unmarshal approval message: %v", err)
+	}
+	vr, ok := stanza.GetVoiceRequest(msg)
+	if !ok {
+		t.Fatalf("voice request not found in message")
+	}
+
+	for expected, build := range map[string]func() (stanza.Message, error){
+		"true":  vr.Approve,
+		"false": vr.Deny,
+	} {
+		answer, err := build()
+		if err != nil {
+			t.Fatalf("could not build answer: %v", err)
+		}
+		if answer.To != "coven@conference.shakespeare.lit" {
+			t.Errorf("answer should be sent to the room, not %s", answer.To)
+		}
+		data, err := xml.Marshal(answer)
+		if err != nil {
+			t.Fatalf("could not marshal answer: %v", err)
+		}
+		var parsed stanza.Message
+		if err = xml.Unmarshal(data, &parsed); err != nil {
+			t.Fatalf("could not unmarshal answer: %v", err)
+		}
+		var form stanza.Form
+		if !parsed.Get(&form) {
+			t.Fatalf("answer does not contain a form: %s", data)
+		}
+		if form.Type != stanza.FormTypeSubmit || form.FormType() != stanza.NSMucRequest {
+			t.Errorf("unexpected answer form: %s", data)
+		}
+		if allow := form.Field("muc#request_allow"); allow == nil || allow.Value() != expected {
+			t.Errorf("request_allow should be %s: %s", expected, data)
+		}
+		if nick := form.Field("muc#roomnick"); nick == nil || nick.Value() != "thirdwitch" {
+			t.Errorf("answer should echo the requester nickname: %s", data)
+		}
+	}
+}
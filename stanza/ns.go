@@ -1,5 +1,7 @@
 package stanza
 
+import "encoding/xml"
+
 const (
 	NSStream    = "http://etherx.jabber.org/streams"
 	nsTLS       = "urn:ietf:params:xml:ns:xmpp-tls"
@@ -8,5 +10,44 @@ const (
 	NSSession   = "urn:ietf:params:xml:ns:xmpp-session"
 	NSFraming   = "urn:ietf:params:xml:ns:xmpp-framing"
 	NSClient    = "jabber:client"
+	NSServer    = "jabber:server"
 	NSComponent = "jabber:component:accept"
 )
+
+// IsStanzaNamespace tells if the namespace is the default namespace of a client, server or component
+// stream. Messages, presences and IQs are equivalent in these namespaces.
+func IsStanzaNamespace(ns string) bool {
+	return ns == NSClient || ns == NSServer || ns == NSComponent
+}
+
+// inheritStreamNamespace clears the stream namespace of a message, presence or IQ, so that it is
+// marshaled in the default namespace of the stream it is written to. The packet is copied.
+func inheritStreamNamespace(p Packet) Packet {
+	inherit := func(name xml.Name) xml.Name {
+		if IsStanzaNamespace(name.Space) {
+			name.Space = ""
+		}
+		return name
+	}
+	switch v := p.(type) {
+	case Message:
+		v.XMLName = inherit(v.XMLName)
+		return v
+	case *Message:
+		msg := *v
+		msg.XMLName = inherit(msg.XMLName)
+		return &msg
+	case Presence:
+		v.XMLName = inherit(v.XMLName)
+		return v
+	case *Presence:
+		pres := *v
+		pres.XMLName = inherit(pres.XMLName)
+		return &pres
+	case *IQ:
+		iq := *v
+		iq.XMLName = inherit(iq.XMLName)
+		return &iq
+	}
+	return p
+}
@@ -0,0 +1,58 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// Stanzas are decoded the same way on client, server and component streams.
+func TestDecodeStreamNamespaces(t *testing.T) {
+	for _, ns := range []string{stanza.NSClient, stanza.NSServer, stanza.NSComponent} {
+		stream := `<stream:stream xmlns='` + ns + `' xmlns:stream='http://etherx.jabber.org/streams' id='s1' version='1.0'>` +
+			`<message from='juliet@capulet.lit/balcony' to='romeo@montague.lit'><body>Art thou not Romeo?</body></message>` +
+			`<presence from='juliet@capulet.lit/balcony'><show>away</show></presence>` +
+			`<iq type='get' id='v1' from='juliet@capulet.lit/balcony'><query xmlns='jabber:iq:version'/></iq>`
+		dec := xml.NewDecoder(strings.NewReader(stream))
+		if _, err := stanza.InitStream(dec); err != nil {
+			t.Fatalf("%s: cannot open stream: %v", ns, err)
+		}
+
+		packet, err := stanza.NextPacket(dec)
+		if msg, ok := packet.(stanza.Message); err != nil || !ok || msg.Body != "Art thou not Romeo?" {
+			t.Errorf("%s: unexpected message: %#v, %v", ns, packet, err)
+		}
+		packet, err = stanza.NextPacket(dec)
+		if pres, ok := packet.(stanza.Presence); err != nil || !ok || pres.Show != stanza.PresenceShowAway {
+			t.Errorf("%s: unexpected presence: %#v, %v", ns, packet, err)
+		}
+		packet, err = stanza.NextPacket(dec)
+		if iq, ok := packet.(*stanza.IQ); err != nil || !ok || iq.Id != "v1" {
+			t.Errorf("%s: unexpected iq: %#v, %v", ns, packet, err)
+		} else if _, ok = iq.Payload.(*stanza.Version); !ok {
+			t.Errorf("%s: IQ payload should be decoded: %#v", ns, iq.Payload)
+		}
+	}
+}
+
+// A stanza decoded from a component stream is sent in the namespace of the stream it is written to.
+func TestMarshalPacketStreamNamespace(t *testing.T) {
+	var msg stanza.Message
+	raw := `<message xmlns='jabber:component:accept' from='juliet@capulet.lit' to='gateway.montague.lit'><body>Hi</body></message>`
+	if err := xml.Unmarshal([]byte(raw), &msg); err != nil {
+		t.Fatalf("could not parse message: %v", err)
+	}
+	msg.From, msg.To = msg.To, msg.From
+	data, err := stanza.MarshalPacket(msg, stanza.InvalidCharReplace)
+	if err != nil {
+		t.Fatalf("could not marshal message: %v", err)
+	}
+	if strings.Contains(string(data), "xmlns") {
+		t.Errorf("stanza should inherit the namespace of the stream: %s", data)
+	}
+	if msg.XMLName.Space != stanza.NSComponent {
+		t.Errorf("marshaled packet should not be modified: %+v", msg.XMLName)
+	}
+}
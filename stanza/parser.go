@@ -1,10 +1,12 @@
 package stanza
 
 import (
+	"bytes"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
+	"sync/atomic"
 )
 
 // Reads and checks the opening XMPP stream element.
@@ -49,8 +51,14 @@ func InitStream(p *xml.Decoder) (sessionID string, err error) {
 // TODO Use an interface to return packets interface xmppDecoder
 // TODO make auth and bind use NextPacket instead of directly NextStart
 func NextPacket(p *xml.Decoder) (Packet, error) {
+	return NextPacketCounting(p, nil)
+}
+
+// NextPacketCounting is like NextPacket, and counts in skipped the tokens found before the packet.
+// skipped may be nil.
+func NextPacketCounting(p *xml.Decoder, skipped *SkippedTokens) (Packet, error) {
 	// Read start element to find out how we want to parse the XMPP packet
-	t, err := NextXmppToken(p)
+	t, err := nextXmppToken(p, skipped)
 	if err != nil {
 		return nil, err
 	}
@@ -70,12 +78,18 @@ func NextPacket(p *xml.Decoder) (Packet, error) {
 		return decodeStream(p, se)
 	case NSSASL:
 		return decodeSASL(p, se)
-	case NSClient:
-		return decodeClient(p, se)
+	case NSSASL2:
+		return sasl2.decode(p, se)
+	case NSClient, NSServer:
+		return DecodeStanza(p, se)
 	case NSComponent:
 		return decodeComponent(p, se)
 	case NSStreamManagement:
 		return sm.decode(p, se)
+	case NSDialback:
+		return dialback.decode(p, se)
+	case NSCSI:
+		return csi.decode(p, se)
 	default:
 		return nil, errors.New("unknown namespace " +
 			se.Name.Space + " <" + se.Name.Local + "/>")
@@ -83,15 +97,21 @@ func NextPacket(p *xml.Decoder) (Packet, error) {
 }
 
 // NextXmppToken scans XML token stream to find next StartElement or stream EndElement.
-// We need the EndElement scan, because we must register stream close tags
+// We need the EndElement scan, because we must register stream close tags.
+// Whitespace, comments and processing instructions found between the elements are skipped, while
+// other character data is an error wrapping ErrUnexpectedCharData.
 func NextXmppToken(p *xml.Decoder) (xml.Token, error) {
+	return nextXmppToken(p, nil)
+}
+
+func nextXmppToken(p *xml.Decoder, skipped *SkippedTokens) (xml.Token, error) {
 	for {
 		t, err := p.Token()
 		if err == io.EOF {
 			return xml.StartElement{}, errors.New("connection closed")
 		}
 		if err != nil {
-			return xml.StartElement{}, fmt.Errorf("NextStart %s", err)
+			return xml.StartElement{}, fmt.Errorf("NextStart %w", err)
 		}
 		switch t := t.(type) {
 		case xml.StartElement:
@@ -100,10 +120,85 @@ func NextXmppToken(p *xml.Decoder) (xml.Token, error) {
 			if t.Name.Space == NSStream && t.Name.Local == "stream" {
 				return t, nil
 			}
+		case xml.CharData:
+			if len(bytes.Trim(t, xmlWhitespace)) != 0 {
+				return nil, &UnexpectedCharDataError{Data: string(t)}
+			}
+			skipped.count(t)
+		case xml.Comment, xml.ProcInst:
+			skipped.count(t)
 		}
 	}
 }
 
+// ============================================================================
+// Tokens between the top level elements
+
+// xmlWhitespace are the whitespace characters of XML.
+const xmlWhitespace = " \t\r\n"
+
+// ErrUnexpectedCharData is wrapped by the error returned when character data other than whitespace is
+// received between the top level elements of a stream. Such a stream is not valid anymore: it should
+// be closed with a bad-format stream error.
+var ErrUnexpectedCharData = errors.New("unexpected character data between stanzas")
+
+// UnexpectedCharDataError is returned when character data other than whitespace is received between
+// the top level elements of a stream.
+type UnexpectedCharDataError struct {
+	Data string
+}
+
+func (e *UnexpectedCharDataError) Error() string {
+	data := e.Data
+	if len(data) > 64 {
+		data = data[:64] + "..."
+	}
+	return fmt.Sprintf("%v: %q", ErrUnexpectedCharData, data)
+}
+
+func (e *UnexpectedCharDataError) Unwrap() error {
+	return ErrUnexpectedCharData
+}
+
+// SkippedTokens counts the tokens skipped between the top level elements of a stream, such as the
+// whitespace keepalives sent by servers, or the comments added by middleboxes. The counters are
+// updated atomically: use Load to read them while the stream is decoded.
+type SkippedTokens struct {
+	// Whitespace is the number of whitespace character data tokens
+	Whitespace uint64
+	// Comments is the number of XML comments
+	Comments uint64
+	// ProcInsts is the number of processing instructions
+	ProcInsts uint64
+}
+
+// Load reads the counters atomically.
+func (s *SkippedTokens) Load() SkippedTokens {
+	if s == nil {
+		return SkippedTokens{}
+	}
+	return SkippedTokens{
+		Whitespace: atomic.LoadUint64(&s.Whitespace),
+		Comments:   atomic.LoadUint64(&s.Comments),
+		ProcInsts:  atomic.LoadUint64(&s.ProcInsts),
+	}
+}
+
+// count increments the counter of the skipped token, unless s is nil.
+func (s *SkippedTokens) count(t xml.Token) {
+	if s == nil {
+		return
+	}
+	switch t.(type) {
+	case xml.CharData:
+		atomic.AddUint64(&s.Whitespace, 1)
+	case xml.Comment:
+		atomic.AddUint64(&s.Comments, 1)
+	case xml.ProcInst:
+		atomic.AddUint64(&s.ProcInsts, 1)
+	}
+}
+
 // NextStart scans XML token stream to find next StartElement.
 func NextStart(p *xml.Decoder) (xml.StartElement, error) {
 	for {
@@ -112,7 +207,7 @@ func NextStart(p *xml.Decoder) (xml.StartElement, error) {
 			return xml.StartElement{}, errors.New("connection closed")
 		}
 		if err != nil {
-			return xml.StartElement{}, fmt.Errorf("NextStart %s", err)
+			return xml.StartElement{}, fmt.Errorf("NextStart %w", err)
 		}
 		switch t := t.(type) {
 		case xml.StartElement:
@@ -156,6 +251,8 @@ func decodeStream(p *xml.Decoder, t xml.Token) (Packet, error) {
 // decodeSASL decodes a packet related to SASL authentication.
 func decodeSASL(p *xml.Decoder, se xml.StartElement) (Packet, error) {
 	switch se.Name.Local {
+	case "challenge":
+		return saslChallenge.decode(p, se)
 	case "success":
 		return saslSuccess.decode(p, se)
 	case "failure":
@@ -166,8 +263,15 @@ func decodeSASL(p *xml.Decoder, se xml.StartElement) (Packet, error) {
 	}
 }
 
-// decodeClient decodes all known packets in the client namespace.
-func decodeClient(p *xml.Decoder, se xml.StartElement) (Packet, error) {
+// DecodeStanza decodes a message, presence or IQ element, with all its registered extensions.
+// It is used to decode stanzas read from the stream, as well as stanzas embedded in other
+// elements, like forwarded stanzas. As with NextPacket, IQs are returned as *IQ. Stanzas are
+// accepted in the namespaces of client, server and component streams.
+func DecodeStanza(p *xml.Decoder, se xml.StartElement) (Packet, error) {
+	if !IsStanzaNamespace(se.Name.Space) {
+		return nil, errors.New("unexpected stanza namespace " +
+			se.Name.Space + " <" + se.Name.Local + "/>")
+	}
 	switch se.Name.Local {
 	case "message":
 		return message.decode(p, se)
@@ -186,14 +290,7 @@ func decodeComponent(p *xml.Decoder, se xml.StartElement) (Packet, error) {
 	switch se.Name.Local {
 	case "handshake": // handshake is used to authenticate components
 		return handshake.decode(p, se)
-	case "message":
-		return message.decode(p, se)
-	case "presence":
-		return presence.decode(p, se)
-	case "iq":
-		return iq.decode(p, se)
 	default:
-		return nil, errors.New("unexpected XMPP packet " +
-			se.Name.Space + " <" + se.Name.Local + "/>")
+		return DecodeStanza(p, se)
 	}
 }
@@ -0,0 +1,61 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestNextPacketSkipsTokensBetweenStanzas(t *testing.T) {
+	stream := limitsStreamOpen + "\n" +
+		`<message id='1' to='a@b'><body>one</body></message>` + "\n \n\t" +
+		`<!-- added by a proxy -->` + " " +
+		`<?keepalive?>` +
+		`<message id='2' to='a@b'><body>two</body></message>` + "\r\n" +
+		`</stream:stream>`
+	dec := xml.NewDecoder(strings.NewReader(stream))
+	if _, err := stanza.InitStream(dec); err != nil {
+		t.Fatalf("cannot open stream: %v", err)
+	}
+
+	var skipped stanza.SkippedTokens
+	for _, id := range []string{"1", "2"} {
+		p, err := stanza.NextPacketCounting(dec, &skipped)
+		if err != nil {
+			t.Fatalf("cannot decode message %s: %v", id, err)
+		}
+		if msg, ok := p.(stanza.Message); !ok || msg.Id != id {
+			t.Fatalf("expected message %s, got %+v", id, p)
+		}
+	}
+	if p, err := stanza.NextPacketCounting(dec, &skipped); err != nil {
+		t.Fatalf("cannot decode stream close: %v", err)
+	} else if _, ok := p.(stanza.StreamClosePacket); !ok {
+		t.Errorf("expected stream close, got %+v", p)
+	}
+
+	expected := stanza.SkippedTokens{Whitespace: 4, Comments: 1, ProcInsts: 1}
+	if got := skipped.Load(); got != expected {
+		t.Errorf("unexpected skipped tokens: %+v, expected %+v", got, expected)
+	}
+}
+
+func TestNextPacketUnexpectedCharData(t *testing.T) {
+	stream := limitsStreamOpen + "\n" + `junk<message id='1'/>`
+	dec := xml.NewDecoder(strings.NewReader(stream))
+	if _, err := stanza.InitStream(dec); err != nil {
+		t.Fatalf("cannot open stream: %v", err)
+	}
+
+	_, err := stanza.NextPacket(dec)
+	var charDataErr *stanza.UnexpectedCharDataError
+	if !errors.Is(err, stanza.ErrUnexpectedCharData) || !errors.As(err, &charDataErr) {
+		t.Fatalf("expected ErrUnexpectedCharData, got %v", err)
+	}
+	if charDataErr.Data != "\njunk" {
+		t.Errorf("unexpected character data: %q", charDataErr.Data)
+	}
+}
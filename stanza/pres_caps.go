@@ -0,0 +1,85 @@
+package stanza
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/xml"
+	"sort"
+	"strings"
+)
+
+/*
+Support for:
+- XEP-0115 - Entity Capabilities: https://xmpp.org/extensions/xep-0115.html
+  The Caps element, also found in stream features, is sent in presences to advertise the capabilities of the sender.
+*/
+
+const (
+	NSCaps = "http://jabber.org/protocol/caps"
+	// CapsHashSHA1 is the hash function used to compute the verification string
+	CapsHashSHA1 = "sha-1"
+)
+
+// CapsNode returns the disco#info node the capabilities are published on.
+func (c Caps) CapsNode() string {
+	return c.Node + "#" + c.Ver
+}
+
+// CapsVerification computes the verification string of the service discovery information,
+// using SHA-1.
+// See XEP-0115 - 5.1 Verification String
+func CapsVerification(info DiscoInfo) string {
+	var b strings.Builder
+
+	identities := make([]string, 0, len(info.Identity))
+	for _, id := range info.Identity {
+		identities = append(identities, id.Category+"/"+id.Type+"/"+id.Lang+"/"+id.Name+"<")
+	}
+	sort.Strings(identities)
+	for _, id := range identities {
+		b.WriteString(id)
+	}
+
+	features := make([]string, 0, len(info.Features))
+	for _, f := range info.Features {
+		features = append(features, f.Var)
+	}
+	sort.Strings(features)
+	for _, f := range features {
+		b.WriteString(f + "<")
+	}
+
+	forms := make([]Form, 0, len(info.Forms))
+	for _, form := range info.Forms {
+		// Forms without FORM_TYPE are ignored
+		if form.FormType() != "" {
+			forms = append(forms, form)
+		}
+	}
+	sort.Slice(forms, func(i, j int) bool { return forms[i].FormType() < forms[j].FormType() })
+	for _, form := range forms {
+		b.WriteString(form.FormType() + "<")
+		fields := make([]*Field, 0, len(form.Fields))
+		for _, f := range form.Fields {
+			if f != nil && f.Var != "FORM_TYPE" {
+				fields = append(fields, f)
+			}
+		}
+		sort.Slice(fields, func(i, j int) bool { return fields[i].Var < fields[j].Var })
+		for _, f := range fields {
+			b.WriteString(f.Var + "<")
+			values := append([]string(nil), f.ValuesList...)
+			sort.Strings(values)
+			for _, v := range values {
+				b.WriteString(v + "<")
+			}
+		}
+	}
+
+	sum := sha1.Sum([]byte(b.String()))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func init() {
+	TypeRegistry.MustRegister(PKTPresence, xml.Name{Space: NSCaps, Local: "c"}, Caps{})
+}
@@ -0,0 +1,86 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// Examples from XEP-0115 - 5.2 Simple Generation Example
+func TestCapsVerificationSimple(t *testing.T) {
+	info := stanza.DiscoInfo{
+		Identity: []stanza.Identity{{Category: "client", Type: "pc", Name: "Exodus 0.9.1"}},
+		Features: []stanza.Feature{
+			{Var: "http://jabber.org/protocol/disco#info"},
+			{Var: "http://jabber.org/protocol/caps"},
+			{Var: "http://jabber.org/protocol/muc"},
+			{Var: "http://jabber.org/protocol/disco#items"},
+		},
+	}
+	if ver := stanza.CapsVerification(info); ver != "QgayPKawpkPSDYmwT/WM94uAlu0=" {
+		t.Errorf("unexpected verification string: %s", ver)
+	}
+}
+
+// XEP-0115 - 5.3 Complex Generation Example
+func TestCapsVerificationComplex(t *testing.T) {
+	raw := `<query xmlns='http://jabber.org/protocol/disco#info'
+       node='http://psi-im.org#q07IKJEyjvHSyhy//CH0CxmKi8w='>
+  <identity xml:lang='en' category='client' name='Psi 0.11' type='pc'/>
+  <identity xml:lang='el' category='client' name='Ψ 0.11' type='pc'/>
+  <feature var='http://jabber.org/protocol/caps'/>
+  <feature var='http://jabber.org/protocol/disco#info'/>
+  <feature var='http://jabber.org/protocol/disco#items'/>
+  <feature var='http://jabber.org/protocol/muc'/>
+  <x xmlns='jabber:x:data' type='result'>
+    <field var='FORM_TYPE' type='hidden'>
+      <value>urn:xmpp:dataforms:softwareinfo</value>
+    </field>
+    <field var='ip_version'>
+      <value>ipv4</value>
+      <value>ipv6</value>
+    </field>
+    <field var='os'>
+      <value>Mac</value>
+    </field>
+    <field var='os_version'>
+      <value>10.5.1</value>
+    </field>
+    <field var='software'>
+      <value>Psi</value>
+    </field>
+    <field var='software_version'>
+      <value>0.11</value>
+    </field>
+  </x>
+</query>`
+	var info stanza.DiscoInfo
+	if err := xml.Unmarshal([]byte(raw), &info); err != nil {
+		t.Fatalf("could not unmarshal disco info: %v", err)
+	}
+	if info.Identity[0].Lang != "en" {
+		t.Errorf("identity language was not decoded: %+v", info.Identity[0])
+	}
+	if ver := stanza.CapsVerification(info); ver != "q07IKJEyjvHSyhy//CH0CxmKi8w=" {
+		t.Errorf("unexpected verification string: %s", ver)
+	}
+}
+
+func TestDecodePresenceCaps(t *testing.T) {
+	raw := `<presence from='romeo@montague.lit/orchard'>
+  <c xmlns='http://jabber.org/protocol/caps' hash='sha-1' node='http://code.google.com/p/exodus'
+     ver='QgayPKawpkPSDYmwT/WM94uAlu0='/>
+</presence>`
+	var p stanza.Presence
+	if err := xml.Unmarshal([]byte(raw), &p); err != nil {
+		t.Fatalf("could not unmarshal presence: %v", err)
+	}
+	var caps stanza.Caps
+	if !p.Get(&caps) {
+		t.Fatalf("caps extension not found in presence")
+	}
+	if caps.Hash != stanza.CapsHashSHA1 || caps.CapsNode() != "http://code.google.com/p/exodus#QgayPKawpkPSDYmwT/WM94uAlu0=" {
+		t.Errorf("unexpected caps: %+v", caps)
+	}
+}
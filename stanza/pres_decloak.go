@@ -0,0 +1,27 @@
+package stanza
+
+import (
+	"encoding/xml"
+)
+
+/*
+Support for:
+- XEP-0276 - Presence Decloaking: https://xmpp.org/extensions/xep-0276.html
+  An entity without presence subscription asks a client for its presence, for example to start a
+  media session with its full JID.
+*/
+
+const NSDecloak = "urn:xmpp:decloak:0"
+
+// Decloak is the decloaking request carried by a presence.
+type Decloak struct {
+	PresExtension
+	XMLName xml.Name `xml:"urn:xmpp:decloak:0 decloak"`
+	// Reason is the purpose of the request, for example "media" or "text"
+	Reason string `xml:"reason,attr,omitempty"`
+	Id     string `xml:"id,attr,omitempty"`
+}
+
+func init() {
+	TypeRegistry.MustRegister(PKTPresence, xml.Name{Space: NSDecloak, Local: "decloak"}, Decloak{})
+}
@@ -0,0 +1,68 @@
+package stanza
+
+import (
+	"encoding/xml"
+)
+
+// ============================================================================
+// MUC User extension
+
+const NSMucUser = "http://jabber.org/protocol/muc#user"
+
+// Status codes used in muc#user extensions.
+// See XEP-0045 - 15.6 Status Codes
+const (
+	MucStatusSelfPresence    = 110
+	MucStatusServiceShutdown = 332
+)
+
+// MucUser implements the muc#user extension sent by the room to its occupants.
+// See XEP-0045 - 19.2 http://jabber.org/protocol/muc#user
+type MucUser struct {
+	PresExtension
+	XMLName  xml.Name    `xml:"http://jabber.org/protocol/muc#user x"`
+	Items    []MucItem   `xml:"item,omitempty"`
+	Statuses []MucStatus `xml:"status,omitempty"`
+	Destroy  *MucDestroy `xml:"destroy,omitempty"`
+	Password string      `xml:"password,omitempty"`
+}
+
+// MucItem describes the affiliation and role of an occupant.
+type MucItem struct {
+	XMLName     xml.Name `xml:"item"`
+	Affiliation string   `xml:"affiliation,attr,omitempty"`
+	Role        string   `xml:"role,attr,omitempty"`
+	Jid         string   `xml:"jid,attr,omitempty"`
+	Nick        string   `xml:"nick,attr,omitempty"`
+	Reason      string   `xml:"reason,omitempty"`
+}
+
+type MucStatus struct {
+	XMLName xml.Name `xml:"status"`
+	Code    int      `xml:"code,attr"`
+}
+
+// MucDestroy is sent to occupants when a room is destroyed. Jid is the optional alternate venue,
+// and Password the password needed to enter it, if any.
+// See XEP-0045 - 10.9 Destroying a Room
+type MucDestroy struct {
+	XMLName  xml.Name `xml:"destroy"`
+	Jid      string   `xml:"jid,attr,omitempty"`
+	Reason   string   `xml:"reason,omitempty"`
+	Password string   `xml:"password,omitempty"`
+}
+
+// HasStatus returns true if the extension contains the given status code.
+func (m MucUser) HasStatus(code int) bool {
+	for _, s := range m.Statuses {
+		if s.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	TypeRegistry.MustRegister(PKTPresence, xml.Name{Space: NSMucUser, Local: "x"}, MucUser{})
+	TypeRegistry.MustRegister(PKTMessage, xml.Name{Space: NSMucUser, Local: "x"}, MucUser{})
+}
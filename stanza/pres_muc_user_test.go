@@ -0,0 +1,77 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// https://xmpp.org/extensions/xep-0045.html#example-202
+func TestMucUserDestroy(t *testing.T) {
+	str := `<presence
+    from='heath@chat.shakespeare.lit/secondwitch'
+    to='wiccarocks@shakespeare.lit/laptop'
+    type='unavailable'>
+  <x xmlns='http://jabber.org/protocol/muc#user'>
+    <item affiliation='none' role='none'/>
+    <destroy jid='coven@chat.shakespeare.lit'>
+      <reason>Macbeth doth come.</reason>
+      <password>cauldronburn</password>
+    </destroy>
+  </x>
+</presence>`
+
+	var parsedPresence stanza.Presence
+	if err := xml.Unmarshal([]byte(str), &parsedPresence); err != nil {
+		t.Fatalf("Unmarshal(%s) returned error: %s", str, err)
+	}
+
+	var muc stanza.MucUser
+	if ok := parsedPresence.Get(&muc); !ok {
+		t.Fatal("muc#user presence extension was not found")
+	}
+	if len(muc.Items) != 1 || muc.Items[0].Affiliation != "none" || muc.Items[0].Role != "none" {
+		t.Errorf("incorrect items: %#v", muc.Items)
+	}
+	if muc.Destroy == nil {
+		t.Fatal("destroy element was not found")
+	}
+	if muc.Destroy.Jid != "coven@chat.shakespeare.lit" || muc.Destroy.Reason != "Macbeth doth come." ||
+		muc.Destroy.Password != "cauldronburn" {
+		t.Errorf("incorrect destroy element: %#v", muc.Destroy)
+	}
+}
+
+// https://xmpp.org/extensions/xep-0045.html#service-shutdown
+func TestMucUserServiceShutdown(t *testing.T) {
+	str := `<presence
+    from='harfleur@chat.shakespeare.lit/pistol'
+    to='pistol@shakespeare.lit/harfleur'
+    type='unavailable'>
+  <x xmlns='http://jabber.org/protocol/muc#user'>
+    <item affiliation='none' role='none'/>
+    <status code='110'/>
+    <status code='332'/>
+  </x>
+</presence>`
+
+	var parsedPresence stanza.Presence
+	if err := xml.Unmarshal([]byte(str), &parsedPresence); err != nil {
+		t.Fatalf("Unmarshal(%s) returned error: %s", str, err)
+	}
+
+	var muc stanza.MucUser
+	if ok := parsedPresence.Get(&muc); !ok {
+		t.Fatal("muc#user presence extension was not found")
+	}
+	if !muc.HasStatus(stanza.MucStatusSelfPresence) || !muc.HasStatus(stanza.MucStatusServiceShutdown) {
+		t.Errorf("missing status codes: %#v", muc.Statuses)
+	}
+	if muc.HasStatus(307) {
+		t.Errorf("unexpected status code 307")
+	}
+	if muc.Destroy != nil {
+		t.Errorf("unexpected destroy element: %#v", muc.Destroy)
+	}
+}
@@ -9,11 +9,20 @@ import (
 // Presence Packet
 
 // Presence implements RFC 6120 - A.5 Client Namespace (a part)
+//
+// Child elements are marshaled in a fixed order: show, status, priority and error, then the
+// extensions in the order of the Extensions slice. As for messages, unknown extensions are decoded
+// as *Node and kept in document order.
+//
+// When a presence bounces, the server returns it with the error type: the condition is decoded in
+// Error, and the children of the original presence it echoes are kept in Extensions.
+//
+// Status can be sent in several languages: Status.String() returns the default one.
 type Presence struct {
 	XMLName xml.Name `xml:"presence"`
 	Attrs
 	Show       PresenceShow    `xml:"show,omitempty"`
-	Status     string          `xml:"status,omitempty"`
+	Status     LocalizedText   `xml:"status,omitempty"`
 	Priority   int8            `xml:"priority,omitempty"` // default: 0
 	Error      Err             `xml:"error,omitempty"`
 	Extensions []PresExtension `xml:",omitempty"`
@@ -105,6 +114,9 @@ func (pres *Presence) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error
 		switch tt := t.(type) {
 
 		case xml.StartElement:
+			if tt.Name.Space == NSStream {
+				return interruptedStanza(d, start, tt)
+			}
 			if presExt := TypeRegistry.GetPresExtension(tt.Name); presExt != nil {
 				// Decode message extension
 				err = d.DecodeElement(presExt, &tt)
@@ -124,6 +136,12 @@ func (pres *Presence) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error
 					err = d.DecodeElement(&pres.Priority, &tt)
 				case "error":
 					err = d.DecodeElement(&pres.Error, &tt)
+				default:
+					// Keep unknown extensions, in their original order
+					var n Node
+					if err = d.DecodeElement(&n, &tt); err == nil {
+						pres.Extensions = append(pres.Extensions, &n)
+					}
 				}
 				if err != nil {
 					return err
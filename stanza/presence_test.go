@@ -38,7 +38,7 @@ func TestPresenceSubElt(t *testing.T) {
 
 	presence := stanza.NewPresence(stanza.Attrs{From: "admin@localhost", To: "test@localhost", Id: "1"})
 	presence.Show = stanza.PresenceShowXA
-	presence.Status = "Coding"
+	presence.Status = stanza.NewLocalizedText("Coding")
 	presence.Priority = 10
 
 	data, err := xml.Marshal(presence)
@@ -54,10 +54,57 @@ func TestPresenceSubElt(t *testing.T) {
 	if parsedPresence.Show != presence.Show {
 		t.Errorf("cannot read 'show' as presence subelement (%s)", parsedPresence.Show)
 	}
-	if parsedPresence.Status != presence.Status {
+	if parsedPresence.Status != presence.Status.String() {
 		t.Errorf("cannot read 'status' as presence subelement (%s)", parsedPresence.Status)
 	}
 	if parsedPresence.Priority != presence.Priority {
 		t.Errorf("cannot read 'priority' as presence subelement (%d)", parsedPresence.Priority)
 	}
 }
+
+func TestDecodePresenceError(t *testing.T) {
+	str := `<presence from='coven@chat.shakespeare.lit/thirdwitch' to='hag66@shakespeare.lit/pda' type='error'>
+  <x xmlns='http://jabber.org/protocol/muc'/>
+  <error by='coven@chat.shakespeare.lit' type='cancel'>
+    <conflict xmlns='urn:ietf:params:xml:ns:xmpp-stanzas'/>
+    <text xmlns='urn:ietf:params:xml:ns:xmpp-stanzas'>Nickname in use</text>
+  </error>
+</presence>`
+	var pres stanza.Presence
+	if err := xml.Unmarshal([]byte(str), &pres); err != nil {
+		t.Fatalf("could not unmarshal presence: %v", err)
+	}
+	if pres.Type != stanza.PresenceTypeError || pres.Error.Type != stanza.ErrorTypeCancel ||
+		pres.Error.Reason != "conflict" || pres.Error.Text.String() != "Nickname in use" {
+		t.Errorf("unexpected presence error: %#v", pres.Error)
+	}
+	var muc stanza.MucPresence
+	if !pres.Get(&muc) {
+		t.Errorf("echoed MUC element was not kept: %#v", pres.Extensions)
+	}
+
+	// The error is encoded without legacy code
+	data, err := xml.Marshal(pres)
+	if err != nil {
+		t.Fatalf("cannot marshal presence: %v", err)
+	}
+	var parsed stanza.Presence
+	if err = xml.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("Unmarshal(%s) returned error", data)
+	}
+	if parsed.Error.Reason != "conflict" || parsed.Error.Type != stanza.ErrorTypeCancel {
+		t.Errorf("presence error was not encoded: %s", data)
+	}
+}
+
+func TestDecodeDecloakRequest(t *testing.T) {
+	str := `<presence from='romeo@montague.lit/orchard' to='juliet@capulet.lit'><decloak xmlns='urn:xmpp:decloak:0' reason='text' id='d1'/></presence>`
+	var pres stanza.Presence
+	if err := xml.Unmarshal([]byte(str), &pres); err != nil {
+		t.Fatalf("could not unmarshal presence: %v", err)
+	}
+	var decloak stanza.Decloak
+	if !pres.Get(&decloak) || decloak.Reason != "text" || decloak.Id != "d1" {
+		t.Errorf("unexpected decloak request: %#v", pres.Extensions)
+	}
+}
@@ -147,6 +147,32 @@ type Item struct {
 	Any       *Node    `xml:",any"`
 }
 
+// NewPayloadItem creates an item holding the XML encoding of payload, typically a struct
+// describing a PEP payload.
+func NewPayloadItem(id string, payload interface{}) (Item, error) {
+	data, err := xml.Marshal(payload)
+	if err != nil {
+		return Item{}, err
+	}
+	var n Node
+	if err = xml.Unmarshal(data, &n); err != nil {
+		return Item{}, err
+	}
+	return Item{Id: id, Any: &n}, nil
+}
+
+// DecodePayload decodes the item payload into v.
+func (i *Item) DecodePayload(v interface{}) error {
+	if i.Any == nil {
+		return errors.New("item has no payload")
+	}
+	data, err := xml.Marshal(i.Any)
+	if err != nil {
+		return err
+	}
+	return xml.Unmarshal(data, v)
+}
+
 type Retract struct {
 	XMLName xml.Name `xml:"retract"`
 	Node    string   `xml:"node,attr"`
@@ -429,5 +455,5 @@ func NewRetrieveAllAffilsRequest(serviceId string) (*IQ, error) {
 }
 
 func init() {
-	TypeRegistry.MapExtension(PKTIQ, xml.Name{Space: "http://jabber.org/protocol/pubsub", Local: "pubsub"}, PubSubGeneric{})
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: "http://jabber.org/protocol/pubsub", Local: "pubsub"}, PubSubGeneric{})
 }
@@ -104,8 +104,9 @@ func (*SubscriptionsOwner) UseCase() string {
 }
 
 type SubscriptionOwner struct {
-	SubscriptionStatus string `xml:"subscription"`
+	SubscriptionStatus string `xml:"subscription,attr"`
 	Jid                string `xml:"jid,attr"`
+	SubId              string `xml:"subid,attr,omitempty"`
 }
 
 const (
@@ -447,5 +448,5 @@ func (pso *PubSubOwner) UnmarshalXML(d *xml.Decoder, start xml.StartElement) err
 }
 
 func init() {
-	TypeRegistry.MapExtension(PKTIQ, xml.Name{Space: "http://jabber.org/protocol/pubsub#owner", Local: "pubsub"}, PubSubOwner{})
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: "http://jabber.org/protocol/pubsub#owner", Local: "pubsub"}, PubSubOwner{})
 }
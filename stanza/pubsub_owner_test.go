@@ -586,7 +586,12 @@ func TestNewSubListRqPlResp(t *testing.T) {
 	if len(subs.Subscriptions) != 4 {
 		t.Fatalf("expected to find 4 subscriptions but got %d", len(subs.Subscriptions))
 	}
-
+	if sub := subs.Subscriptions[2]; sub.SubscriptionStatus != stanza.SubscriptionStatusSubscribed || sub.SubId != "123-abc" {
+		t.Errorf("unexpected subscription: %+v", sub)
+	}
+	if subs.Subscriptions[1].SubscriptionStatus != stanza.SubscriptionStatusUnconfigured {
+		t.Errorf("unexpected subscription status: %+v", subs.Subscriptions[1])
+	}
 }
 
 // ********************************************
@@ -0,0 +1,94 @@
+package stanza
+
+import (
+	"bytes"
+	"encoding/xml"
+	"reflect"
+)
+
+// ============================================================================
+// Raw XML retention for extensions
+
+const nsXMLPrefix = "http://www.w3.org/XML/1998/namespace"
+
+// rawExtension associates a decoded extension with the XML it was decoded from.
+type rawExtension struct {
+	ext  MsgExtension
+	data []byte
+}
+
+// decodeRawElement decodes the element into v, and returns the XML of the element.
+// The content of the element is kept byte for byte. The start tag is rebuilt from the decoded
+// name and attributes, with the element namespace declared as default namespace.
+func decodeRawElement(d *xml.Decoder, start xml.StartElement, v interface{}) ([]byte, error) {
+	var inner struct {
+		Data []byte `xml:",innerxml"`
+	}
+	if err := d.DecodeElement(&inner, &start); err != nil {
+		return nil, err
+	}
+
+	raw := buildRawElement(start, inner.Data)
+	if err := xml.Unmarshal(raw, v); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+func buildRawElement(start xml.StartElement, inner []byte) []byte {
+	// Prefixes declared on the element, to write back namespaced attributes
+	prefixes := make(map[string]string)
+	for _, attr := range start.Attr {
+		if attr.Name.Space == "xmlns" {
+			prefixes[attr.Value] = attr.Name.Local
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("<" + start.Name.Local)
+	if start.Name.Space != "" {
+		writeRawAttr(&buf, "xmlns", start.Name.Space)
+	}
+	for _, attr := range start.Attr {
+		name := attr.Name.Local
+		switch attr.Name.Space {
+		case "":
+			if name == "xmlns" {
+				// Already written from the element namespace
+				continue
+			}
+		case "xmlns":
+			name = "xmlns:" + name
+		case nsXMLPrefix, "xml":
+			name = "xml:" + name
+		default:
+			if prefix, ok := prefixes[attr.Name.Space]; ok {
+				name = prefix + ":" + name
+			}
+		}
+		writeRawAttr(&buf, name, attr.Value)
+	}
+	buf.WriteString(">")
+	buf.Write(inner)
+	buf.WriteString("</" + start.Name.Local + ">")
+	return buf.Bytes()
+}
+
+func writeRawAttr(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(" " + name + `="`)
+	_ = xml.EscapeText(buf, []byte(value))
+	buf.WriteString(`"`)
+}
+
+// rawUnchanged returns true if decoding the raw XML again gives the same value as ext.
+func rawUnchanged(ext MsgExtension, data []byte) bool {
+	t := reflect.TypeOf(ext)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return false
+	}
+	fresh := reflect.New(t.Elem())
+	if err := xml.Unmarshal(data, fresh.Interface()); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(fresh.Interface(), ext)
+}
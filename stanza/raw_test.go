@@ -0,0 +1,160 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+const nsRawTest = "urn:example:security-label"
+
+type rawTestLabel struct {
+	stanza.MsgExtension
+	XMLName xml.Name `xml:"urn:example:security-label securitylabel"`
+	Color   string   `xml:"color,attr,omitempty"`
+	Display string   `xml:"displaymarking"`
+}
+
+func init() {
+	stanza.TypeRegistry.MapExtension(stanza.PKTMessage, xml.Name{Space: nsRawTest, Local: "securitylabel"}, rawTestLabel{})
+	stanza.TypeRegistry.RetainRaw(stanza.PKTMessage, nsRawTest)
+}
+
+// Extension content uses single quotes, self-closing tags and extra whitespace, so that the
+// default encoding would not give the same bytes back.
+const rawLabelContent = `<displaymarking>SECRET</displaymarking>
+    <label><esssecuritylabel xmlns='urn:xmpp:sec-label:ess:0'>MQYCAQQGASk=</esssecuritylabel></label>
+    <extra foo='bar'/>`
+
+const rawLabelMessage = `<message xmlns="jabber:client" to="romeo@example.net" id="label1">
+  <body>This content is classified.</body>
+  <securitylabel xmlns='urn:example:security-label' color='red'>` + rawLabelContent + `</securitylabel>
+  <x xmlns='jabber:x:oob'><url>http://example.com/file</url></x>
+</message>`
+
+func TestMessageRawOf(t *testing.T) {
+	var msg stanza.Message
+	if err := xml.Unmarshal([]byte(rawLabelMessage), &msg); err != nil {
+		t.Fatalf("could not unmarshal message: %v", err)
+	}
+
+	var label rawTestLabel
+	if !msg.Get(&label) || label.Display != "SECRET" || label.Color != "red" {
+		t.Fatalf("security label was not decoded: %+v", label)
+	}
+
+	raw, ok := msg.RawOf(&label)
+	if !ok {
+		t.Fatalf("raw XML was not retained")
+	}
+	expected := `<securitylabel xmlns="urn:example:security-label" color="red">` + rawLabelContent + `</securitylabel>`
+	if string(raw) != expected {
+		t.Errorf("unexpected raw XML:\n%s\nexpected:\n%s", raw, expected)
+	}
+
+	// Lookup also works from the extension stored on the message
+	for _, ext := range msg.Extensions {
+		if _, isLabel := ext.(*rawTestLabel); isLabel {
+			if _, ok := msg.RawOf(ext); !ok {
+				t.Errorf("raw XML not found from message extension")
+			}
+		}
+	}
+
+	// Namespaces that did not opt in do not retain raw XML
+	if _, ok := msg.RawOf(&stanza.OOB{}); ok {
+		t.Errorf("raw XML should not be retained for OOB extension")
+	}
+}
+
+func TestMessageMarshalRaw(t *testing.T) {
+	var msg stanza.Message
+	if err := xml.Unmarshal([]byte(rawLabelMessage), &msg); err != nil {
+		t.Fatalf("could not unmarshal message: %v", err)
+	}
+
+	data, err := xml.Marshal(msg)
+	if err != nil {
+		t.Fatalf("could not marshal message: %v", err)
+	}
+	if !strings.Contains(string(data), rawLabelContent) {
+		t.Errorf("unmodified extension should be marshalled from raw XML: %s", data)
+	}
+	if !strings.Contains(string(data), `<body>This content is classified.</body>`) ||
+		!strings.Contains(string(data), `<x xmlns="jabber:x:oob"><url>http://example.com/file</url></x>`) {
+		t.Errorf("message content was not marshalled: %s", data)
+	}
+
+	// Once modified, extension is marshalled from its decoded value
+	for _, ext := range msg.Extensions {
+		if label, ok := ext.(*rawTestLabel); ok {
+			label.Display = "TOP SECRET"
+		}
+	}
+	data, err = xml.Marshal(msg)
+	if err != nil {
+		t.Fatalf("could not marshal message: %v", err)
+	}
+	if strings.Contains(string(data), rawLabelContent) || !strings.Contains(string(data), "<displaymarking>TOP SECRET</displaymarking>") {
+		t.Errorf("modified extension should not be marshalled from raw XML: %s", data)
+	}
+}
+
+// Unknown extensions are forwarded as received, including their nested elements, their namespace
+// declarations and the prefixes they inherit from the message.
+func TestMessageMarshalUnknownRaw(t *testing.T) {
+	const inner = `<c:candidate c:priority='1' ip="10.0.0.1"/>` +
+		`<relay><![CDATA[a<b]]><!-- note --><c:candidate xmlns:d='urn:example:d' d:ttl='60'/></relay>`
+	const content = `<jingle-transport xmlns='urn:example:transport' xmlns:c='urn:example:candidate' sid='s1'>` +
+		inner + `</jingle-transport>`
+	raw := `<message xmlns="jabber:client" xmlns:p="urn:example:parent" to="gateway.example.net" id="fwd1">` +
+		`<body>Forwarded</body>` + content +
+		`<p:hint level="2"><p:detail>kept</p:detail></p:hint>` +
+		`</message>`
+	var msg stanza.Message
+	if err := xml.Unmarshal([]byte(raw), &msg); err != nil {
+		t.Fatalf("could not unmarshal message: %v", err)
+	}
+	if len(msg.Extensions) != 2 {
+		t.Fatalf("expected 2 unknown extensions, got %+v", msg.Extensions)
+	}
+	if n, ok := msg.Extensions[0].(*stanza.Node); !ok || n.XMLName.Space != "urn:example:transport" || len(n.Nodes) != 2 {
+		t.Errorf("unknown extension should be decoded as a node: %+v", msg.Extensions[0])
+	}
+
+	out, err := xml.Marshal(msg)
+	if err != nil {
+		t.Fatalf("could not marshal message: %v", err)
+	}
+	// The content is kept byte for byte. The start tags are rebuilt, declaring the inherited prefix.
+	transport := `<jingle-transport xmlns="urn:example:transport" xmlns:c="urn:example:candidate" sid="s1">` +
+		inner + `</jingle-transport>`
+	hint := `<hint xmlns="urn:example:parent" level="2" xmlns:p="urn:example:parent"><p:detail>kept</p:detail></hint>`
+	if !strings.Contains(string(out), transport+hint) {
+		t.Errorf("unknown extensions should be kept as received:\n%s", out)
+	}
+
+	// A round trip does not change the message again
+	var decoded stanza.Message
+	if err = xml.Unmarshal(out, &decoded); err != nil {
+		t.Fatalf("could not unmarshal marshaled message: %v", err)
+	}
+	again, err := xml.Marshal(decoded)
+	if err != nil {
+		t.Fatalf("could not marshal message again: %v", err)
+	}
+	if string(again) != string(out) {
+		t.Errorf("message changed after a round trip:\n%s\nexpected:\n%s", again, out)
+	}
+
+	// Modified extensions are encoded again
+	msg.Extensions[1].(*stanza.Node).Attrs = nil
+	if out, err = xml.Marshal(msg); err != nil {
+		t.Fatalf("could not marshal modified message: %v", err)
+	}
+	if strings.Contains(string(out), `level="2"`) || !strings.Contains(string(out), transport) {
+		t.Errorf("only the modified extension should be encoded again:\n%s", out)
+	}
+}
@@ -2,7 +2,10 @@ package stanza
 
 import (
 	"encoding/xml"
+	"errors"
+	"fmt"
 	"reflect"
+	"sort"
 	"sync"
 )
 
@@ -12,7 +15,6 @@ type PresExtension interface{}
 // The Registry for msg and IQ types is a global variable.
 // TODO: Move to the client init process to remove the dependency on a global variable.
 //   That should make it possible to be able to share the decoder.
-// TODO: Ensure that a client can add its own custom namespace to the registry (or overload existing ones).
 
 type PacketType uint8
 
@@ -22,6 +24,20 @@ const (
 	PKTIQ
 )
 
+func (t PacketType) String() string {
+	switch t {
+	case PKTPresence:
+		return "presence"
+	case PKTMessage:
+		return "message"
+	case PKTIQ:
+		return "iq"
+	}
+	return fmt.Sprintf("PacketType(%d)", uint8(t))
+}
+
+// TypeRegistry maps the XML names of the extensions to their types, for the decoders. It is safe for
+// concurrent use: extensions can be registered after init, while stanzas are decoded.
 var TypeRegistry = newRegistry()
 
 // We store different registries per packet type and namespace.
@@ -35,34 +51,136 @@ type registryForNamespace map[string]reflect.Type
 type registry struct {
 	// We store different registries per packet type and namespace.
 	msgTypes map[registryKey]registryForNamespace
+	// Namespaces for which the raw XML of extensions is retained when decoding
+	rawNamespaces map[registryKey]bool
 	// Handle concurrent access
 	msgTypesLock *sync.RWMutex
 }
 
 func newRegistry() *registry {
 	return &registry{
-		msgTypes:     make(map[registryKey]registryForNamespace),
-		msgTypesLock: &sync.RWMutex{},
+		msgTypes:      make(map[registryKey]registryForNamespace),
+		rawNamespaces: make(map[registryKey]bool),
+		msgTypesLock:  &sync.RWMutex{},
 	}
 }
 
-// MapExtension stores extension type for packet payload.
+// ErrExtensionConflict is wrapped by the errors returned when registering an extension for a packet type
+// and XML name already mapped to another type.
+var ErrExtensionConflict = errors.New("conflicting extension registration")
+
+// ExtensionConflictError is returned when registering an extension for a packet type and XML name already
+// mapped to another type. The registered type is kept.
+type ExtensionConflictError struct {
+	PacketType PacketType
+	Name       xml.Name
+	Registered reflect.Type
+	Type       reflect.Type
+}
+
+func (e *ExtensionConflictError) Error() string {
+	return fmt.Sprintf("%v: %s {%s}%s is registered to %v, cannot register %v", ErrExtensionConflict,
+		e.PacketType, e.Name.Space, e.Name.Local, e.Registered, e.Type)
+}
+
+func (e *ExtensionConflictError) Unwrap() error {
+	return ErrExtensionConflict
+}
+
+// Register stores extension type for packet payload.
 // The match is done per PacketType (iq, message, or presence) and XML tag name.
 // You can use the alias "*" as local XML name to be able to match all unknown tag name for that
 // packet type and namespace.
-func (r *registry) MapExtension(pktType PacketType, name xml.Name, extension MsgExtension) {
+// Registering the same type again is allowed, but an *ExtensionConflictError is returned when the
+// name is already mapped to another type.
+func (r *registry) Register(pktType PacketType, name xml.Name, extension MsgExtension) error {
 	key := registryKey{pktType, name.Space}
-	r.msgTypesLock.RLock()
-	store := r.msgTypes[key]
-	r.msgTypesLock.RUnlock()
+	extensionType := reflect.TypeOf(extension)
 
 	r.msgTypesLock.Lock()
 	defer r.msgTypesLock.Unlock()
+	store := r.msgTypes[key]
 	if store == nil {
 		store = make(map[string]reflect.Type)
+		r.msgTypes[key] = store
+	}
+	if registered, ok := store[name.Local]; ok && registered != extensionType {
+		return &ExtensionConflictError{PacketType: pktType, Name: name, Registered: registered, Type: extensionType}
+	}
+	store[name.Local] = extensionType
+	return nil
+}
+
+// MustRegister is like Register, but panics on conflicting registration. It is meant to be called
+// from init functions.
+func (r *registry) MustRegister(pktType PacketType, name xml.Name, extension MsgExtension) {
+	if err := r.Register(pktType, name, extension); err != nil {
+		panic(err)
 	}
-	store[name.Local] = reflect.TypeOf(extension)
-	r.msgTypes[key] = store
+}
+
+// MapExtension stores extension type for packet payload. It is kept for compatibility: see Register.
+func (r *registry) MapExtension(pktType PacketType, name xml.Name, extension MsgExtension) error {
+	return r.Register(pktType, name, extension)
+}
+
+// RegisterMessageExtension maps a message extension type to the element of that namespace and
+// local name, so that received messages carry it in their Extensions as the concrete type of the
+// prototype. It is safe to call concurrently, for example for namespaces specific to an application.
+// Registering another type for the same element returns an *ExtensionConflictError.
+func RegisterMessageExtension(namespace, local string, prototype MsgExtension) error {
+	return TypeRegistry.Register(PKTMessage, xml.Name{Space: namespace, Local: local}, prototype)
+}
+
+// ExtensionMapping is a mapping of the registry, returned by Mappings.
+type ExtensionMapping struct {
+	PacketType PacketType
+	Name       xml.Name
+	Type       reflect.Type
+}
+
+// Mappings lists the registered extensions, sorted by packet type and XML name, for debugging.
+func (r *registry) Mappings() []ExtensionMapping {
+	r.msgTypesLock.RLock()
+	var mappings []ExtensionMapping
+	for key, store := range r.msgTypes {
+		for local, extensionType := range store {
+			mappings = append(mappings, ExtensionMapping{
+				PacketType: key.packetType,
+				Name:       xml.Name{Space: key.namespace, Local: local},
+				Type:       extensionType,
+			})
+		}
+	}
+	r.msgTypesLock.RUnlock()
+
+	sort.Slice(mappings, func(i, j int) bool {
+		a, b := mappings[i], mappings[j]
+		if a.PacketType != b.PacketType {
+			return a.PacketType < b.PacketType
+		}
+		if a.Name.Space != b.Name.Space {
+			return a.Name.Space < b.Name.Space
+		}
+		return a.Name.Local < b.Name.Local
+	})
+	return mappings
+}
+
+// RetainRaw asks the decoder to keep the raw XML of the extensions of that namespace, in addition
+// to the decoded value. Only message extensions are supported for now.
+// Retained XML can be read with Message.RawOf. Namespaces that did not opt in do not pay the copy cost.
+func (r *registry) RetainRaw(pktType PacketType, namespace string) {
+	r.msgTypesLock.Lock()
+	defer r.msgTypesLock.Unlock()
+	r.rawNamespaces[registryKey{pktType, namespace}] = true
+}
+
+// retainsRaw tells if the raw XML must be kept for extensions of that packet type and namespace.
+func (r *registry) retainsRaw(pktType PacketType, namespace string) bool {
+	r.msgTypesLock.RLock()
+	defer r.msgTypesLock.RUnlock()
+	return r.rawNamespaces[registryKey{pktType, namespace}]
 }
 
 // GetExtensionType returns extension type for packet payload, based on packet type and tag name.
@@ -2,7 +2,10 @@ package stanza
 
 import (
 	"encoding/xml"
+	"errors"
+	"fmt"
 	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -45,3 +48,109 @@ func BenchmarkRegistryGet(b *testing.B) {
 		}
 	}
 }
+
+func TestRegistry_RegisterConflict(t *testing.T) {
+	typeRegistry := newRegistry()
+	name := xml.Name{Space: "urn:xmpp:receipts", Local: "request"}
+	if err := typeRegistry.Register(PKTMessage, name, ReceiptRequest{}); err != nil {
+		t.Fatalf("cannot register extension: %v", err)
+	}
+	// Registering the same type again is allowed
+	if err := typeRegistry.Register(PKTMessage, name, ReceiptRequest{}); err != nil {
+		t.Errorf("registering the same type again should be allowed: %v", err)
+	}
+
+	err := typeRegistry.Register(PKTMessage, name, ReceiptReceived{})
+	var conflict *ExtensionConflictError
+	if !errors.Is(err, ErrExtensionConflict) || !errors.As(err, &conflict) {
+		t.Fatalf("expected a conflict, got %v", err)
+	}
+	if conflict.Registered != reflect.TypeOf(ReceiptRequest{}) || conflict.Type != reflect.TypeOf(ReceiptReceived{}) {
+		t.Errorf("unexpected conflict: %v", conflict)
+	}
+	if _, ok := typeRegistry.GetMsgExtension(name).(*ReceiptRequest); !ok {
+		t.Errorf("registered type should be kept")
+	}
+
+	// Same name for another packet type
+	if err = typeRegistry.Register(PKTIQ, name, ReceiptReceived{}); err != nil {
+		t.Errorf("names are registered per packet type: %v", err)
+	}
+}
+
+func TestRegistry_MustRegisterPanics(t *testing.T) {
+	typeRegistry := newRegistry()
+	name := xml.Name{Space: "urn:xmpp:receipts", Local: "request"}
+	typeRegistry.MustRegister(PKTMessage, name, ReceiptRequest{})
+	defer func() {
+		if err, ok := recover().(error); !ok || !errors.Is(err, ErrExtensionConflict) {
+			t.Errorf("expected a conflict panic, got %v", err)
+		}
+	}()
+	typeRegistry.MustRegister(PKTMessage, name, ReceiptReceived{})
+}
+
+func TestRegistry_Mappings(t *testing.T) {
+	typeRegistry := newRegistry()
+	typeRegistry.MustRegister(PKTMessage, xml.Name{Space: "urn:xmpp:receipts", Local: "request"}, ReceiptRequest{})
+	typeRegistry.MustRegister(PKTIQ, xml.Name{Space: "urn:xmpp:ping", Local: "ping"}, Ping{})
+	typeRegistry.MustRegister(PKTMessage, xml.Name{Space: "urn:xmpp:receipts", Local: "received"}, ReceiptReceived{})
+
+	mappings := typeRegistry.Mappings()
+	expected := []ExtensionMapping{
+		{PKTMessage, xml.Name{Space: "urn:xmpp:receipts", Local: "received"}, reflect.TypeOf(ReceiptReceived{})},
+		{PKTMessage, xml.Name{Space: "urn:xmpp:receipts", Local: "request"}, reflect.TypeOf(ReceiptRequest{})},
+		{PKTIQ, xml.Name{Space: "urn:xmpp:ping", Local: "ping"}, reflect.TypeOf(Ping{})},
+	}
+	if !reflect.DeepEqual(mappings, expected) {
+		t.Errorf("unexpected mappings: %v", mappings)
+	}
+
+	// The extensions of the package are registered at init
+	if len(TypeRegistry.Mappings()) == 0 {
+		t.Errorf("package extensions should be listed")
+	}
+}
+
+type concurrentTestExtension struct {
+	MsgExtension
+	XMLName xml.Name
+}
+
+// Extensions can be registered after init, while stanzas are decoded.
+func TestRegistry_ConcurrentRegisterAndDecode(t *testing.T) {
+	const count = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*count)
+	for i := 0; i < count; i++ {
+		ns := fmt.Sprintf("urn:example:concurrent:%d", i)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			errs <- RegisterMessageExtension(ns, "payload", concurrentTestExtension{})
+		}()
+		go func() {
+			defer wg.Done()
+			var msg Message
+			errs <- xml.Unmarshal([]byte(`<message><payload xmlns='`+ns+`'/></message>`), &msg)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	var msg Message
+	if err := xml.Unmarshal([]byte(`<message><payload xmlns='urn:example:concurrent:0'/></message>`), &msg); err != nil {
+		t.Fatalf("cannot unmarshal message: %v", err)
+	}
+	if _, ok := msg.Extension("urn:example:concurrent:0", "payload"); !ok || len(msg.Extensions) != 1 {
+		t.Errorf("registered extension not decoded: %+v", msg.Extensions)
+	}
+	if _, ok := msg.Extensions[0].(*concurrentTestExtension); !ok {
+		t.Errorf("unexpected extension type: %T", msg.Extensions[0])
+	}
+}
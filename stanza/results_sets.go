@@ -2,11 +2,14 @@ package stanza
 
 import (
 	"encoding/xml"
+	"strconv"
 )
 
 // Support for XEP-0059
 // See https://xmpp.org/extensions/xep-0059
 const (
+	NSRSM = "http://jabber.org/protocol/rsm"
+
 	// Common but not only possible namespace for query blocks in a result set context
 	NSQuerySet = "jabber:iq:search"
 )
@@ -24,6 +27,140 @@ type ResultSet struct {
 
 type First struct {
 	XMLName xml.Name `xml:"first"`
-	Content string
-	Index   *int `xml:"index,attr,omitempty"`
+	Content string   `xml:",chardata"`
+	Index   *int     `xml:"index,attr,omitempty"`
+}
+
+// ============================================================================
+// RSMSet
+
+// RSMSet is a value form of the result set, easier to build and read than ResultSet.
+// Index is the index of the requested page in a request, and the index of its first item in a response.
+// Max and Count are omitted from the XML when zero.
+type RSMSet struct {
+	Max    int
+	After  string
+	Before string
+	First  string
+	Last   string
+	Index  string
+	Count  int
+
+	// lastPage is set for backward paging from the last page, encoded as an empty before element
+	lastPage bool
+}
+
+// NewRSMQuery requests the max items following the item after. The first page is requested when
+// after is empty.
+func NewRSMQuery(max int, after string) RSMSet {
+	return RSMSet{Max: max, After: after}
+}
+
+// NewRSMQueryBefore requests the max items preceding the item before. The last page is requested
+// when before is empty.
+func NewRSMQueryBefore(max int, before string) RSMSet {
+	return RSMSet{Max: max, Before: before, lastPage: before == ""}
+}
+
+// NewRSMQueryIndex requests the max items starting at the given index.
+func NewRSMQueryIndex(max, index int) RSMSet {
+	return RSMSet{Max: max, Index: strconv.Itoa(index)}
+}
+
+// NewRSMSet converts a result set, as found in IQ payloads.
+func NewRSMSet(rs *ResultSet) RSMSet {
+	var r RSMSet
+	if rs == nil {
+		return r
+	}
+	if rs.Max != nil {
+		r.Max = *rs.Max
+	}
+	if rs.After != nil {
+		r.After = *rs.After
+	}
+	if rs.Before != nil {
+		r.Before = *rs.Before
+		r.lastPage = r.Before == ""
+	}
+	if rs.Last != nil {
+		r.Last = *rs.Last
+	}
+	if rs.Count != nil {
+		r.Count = *rs.Count
+	}
+	if rs.Index != nil {
+		r.Index = strconv.Itoa(*rs.Index)
+	}
+	if rs.First != nil {
+		r.First = rs.First.Content
+		if rs.First.Index != nil {
+			r.Index = strconv.Itoa(*rs.First.Index)
+		}
+	}
+	return r
+}
+
+// ResultSet converts the set to the result set used in IQ payloads.
+func (r RSMSet) ResultSet() *ResultSet {
+	rs := &ResultSet{XMLName: xml.Name{Space: NSRSM, Local: "set"}}
+	if r.Max != 0 {
+		rs.Max = intPtr(r.Max)
+	}
+	if r.After != "" {
+		rs.After = stringPtr(r.After)
+	}
+	if r.Before != "" || r.lastPage {
+		rs.Before = stringPtr(r.Before)
+	}
+	if r.Last != "" {
+		rs.Last = stringPtr(r.Last)
+	}
+	if r.Count != 0 {
+		rs.Count = intPtr(r.Count)
+	}
+	index, err := strconv.Atoi(r.Index)
+	hasIndex := err == nil
+	if r.First != "" {
+		rs.First = &First{Content: r.First}
+		if hasIndex {
+			rs.First.Index = intPtr(index)
+		}
+	} else if hasIndex {
+		rs.Index = intPtr(index)
+	}
+	return rs
+}
+
+func (r RSMSet) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.Encode(r.ResultSet())
+}
+
+func (r *RSMSet) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var rs ResultSet
+	if err := d.DecodeElement(&rs, &start); err != nil {
+		return err
+	}
+	*r = NewRSMSet(&rs)
+	return nil
+}
+
+// RSMSet returns the result set of the IQ payload, if any.
+func (iq *IQ) RSMSet() (RSMSet, bool) {
+	if iq.Payload == nil {
+		return RSMSet{}, false
+	}
+	rs := iq.Payload.GetSet()
+	if rs == nil {
+		return RSMSet{}, false
+	}
+	return NewRSMSet(rs), true
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func stringPtr(v string) *string {
+	return &v
 }
@@ -1,8 +1,10 @@
 package stanza_test
 
 import (
-	"gosrc.io/xmpp/stanza"
+	"encoding/xml"
 	"testing"
+
+	"gosrc.io/xmpp/stanza"
 )
 
 // Limiting the number of items
@@ -26,3 +28,88 @@ func TestUnmarshalResultSeqReq(t *testing.T) {
 	// TODO when Mam is implemented
 
 }
+
+func TestRSMQueries(t *testing.T) {
+	tests := []struct {
+		name     string
+		set      stanza.RSMSet
+		expected string
+	}{
+		// XEP-0059 - Examples 2 and 4
+		{"first page", stanza.NewRSMQuery(10, ""),
+			`<set xmlns="http://jabber.org/protocol/rsm"><max>10</max></set>`},
+		{"next page", stanza.NewRSMQuery(10, "peterpan@neverland.lit"),
+			`<set xmlns="http://jabber.org/protocol/rsm"><after>peterpan@neverland.lit</after><max>10</max></set>`},
+		// XEP-0059 - Examples 6 and 8
+		{"previous page", stanza.NewRSMQueryBefore(10, "peter@pixyland.org"),
+			`<set xmlns="http://jabber.org/protocol/rsm"><before>peter@pixyland.org</before><max>10</max></set>`},
+		{"last page", stanza.NewRSMQueryBefore(10, ""),
+			`<set xmlns="http://jabber.org/protocol/rsm"><before></before><max>10</max></set>`},
+		// XEP-0059 - Example 10
+		{"page out of order", stanza.NewRSMQueryIndex(10, 371),
+			`<set xmlns="http://jabber.org/protocol/rsm"><index>371</index><max>10</max></set>`},
+		{"index zero", stanza.NewRSMQueryIndex(10, 0),
+			`<set xmlns="http://jabber.org/protocol/rsm"><index>0</index><max>10</max></set>`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := xml.Marshal(tc.set)
+			if err != nil {
+				t.Fatalf("cannot marshal set: %v", err)
+			}
+			if string(data) != tc.expected {
+				t.Errorf("unexpected XML:\n%s\nexpected:\n%s", data, tc.expected)
+			}
+			var decoded stanza.RSMSet
+			if err = xml.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("cannot unmarshal set: %v", err)
+			}
+			if decoded != tc.set {
+				t.Errorf("set does not survive round trip: %+v, expected %+v", decoded, tc.set)
+			}
+		})
+	}
+}
+
+func TestRSMSetFromResult(t *testing.T) {
+	// XEP-0059 - Example 3, with a disco#items payload
+	response := `<iq type='result' from='conference.example.com' to='stpeter@jabber.org/roundabout' id='page1'>
+  <query xmlns='http://jabber.org/protocol/disco#items'>
+    <item jid='room1@conference.example.com'/>
+    <item jid='room2@conference.example.com'/>
+    <set xmlns='http://jabber.org/protocol/rsm'>
+      <first index='20'>room1@conference.example.com</first>
+      <last>room2@conference.example.com</last>
+      <count>800</count>
+    </set>
+  </query>
+</iq>`
+	var iq stanza.IQ
+	if err := xml.Unmarshal([]byte(response), &iq); err != nil {
+		t.Fatalf("cannot unmarshal IQ: %v", err)
+	}
+	set, ok := iq.RSMSet()
+	if !ok {
+		t.Fatalf("result set not found")
+	}
+	expected := stanza.RSMSet{
+		First: "room1@conference.example.com",
+		Last:  "room2@conference.example.com",
+		Index: "20",
+		Count: 800,
+	}
+	if set != expected {
+		t.Errorf("unexpected result set: %+v", set)
+	}
+
+	// Next page request built from the response
+	next := stanza.NewRSMQuery(2, set.Last)
+	if rs := next.ResultSet(); rs.After == nil || *rs.After != "room2@conference.example.com" || *rs.Max != 2 {
+		t.Errorf("unexpected next page request: %+v", rs)
+	}
+
+	iq = stanza.IQ{Payload: &stanza.DiscoItems{}}
+	if _, ok = iq.RSMSet(); ok {
+		t.Errorf("no result set expected")
+	}
+}
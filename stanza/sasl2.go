@@ -0,0 +1,220 @@
+package stanza
+
+import (
+	"encoding/xml"
+	"errors"
+)
+
+/*
+Support for:
+- XEP-0388 - Extensible SASL Profile: https://xmpp.org/extensions/xep-0388.html
+- XEP-0386 - Bind 2: https://xmpp.org/extensions/xep-0386.html
+*/
+
+const (
+	NSSASL2 = "urn:xmpp:sasl:2"
+	NSBind2 = "urn:xmpp:bind:0"
+)
+
+// ============================================================================
+// Stream feature
+
+// SASL2Authentication is the SASL2 stream feature, with the mechanisms and the features that can be
+// negotiated inline with the authentication.
+type SASL2Authentication struct {
+	XMLName    xml.Name    `xml:"urn:xmpp:sasl:2 authentication"`
+	Mechanisms []string    `xml:"mechanism"`
+	Inline     SASL2Inline `xml:"inline"`
+}
+
+// SASL2Inline lists the features the server accepts in the authenticate request.
+type SASL2Inline struct {
+	Bind *Bind2Feature `xml:"urn:xmpp:bind:0 bind"`
+	SM   *struct{}     `xml:"urn:xmpp:sm:3 sm"`
+}
+
+// Bind2Feature advertises Bind 2, with the features that can be enabled inline with the binding.
+type Bind2Feature struct {
+	Features []struct {
+		Var string `xml:"var,attr"`
+	} `xml:"inline>feature"`
+}
+
+// DoesSASL2 tells if the server supports the Extensible SASL Profile.
+func (sf *StreamFeatures) DoesSASL2() bool {
+	return sf.SASL2.XMLName.Space == NSSASL2 && sf.SASL2.XMLName.Local == "authentication"
+}
+
+// DoesBind2 tells if the server supports binding the resource inline with SASL2 authentication.
+func (sf *StreamFeatures) DoesBind2() bool {
+	return sf.DoesSASL2() && sf.SASL2.Inline.Bind != nil
+}
+
+// DoesSASL2Resume tells if the server supports resuming a stream management session inline with
+// SASL2 authentication.
+func (sf *StreamFeatures) DoesSASL2Resume() bool {
+	return sf.DoesSASL2() && sf.SASL2.Inline.SM != nil
+}
+
+// Bind2Inline tells if the feature can be enabled inline with Bind 2, for example NSCarbons or
+// NSStreamManagement.
+func (sf *StreamFeatures) Bind2Inline(feature string) bool {
+	if !sf.DoesBind2() {
+		return false
+	}
+	for _, f := range sf.SASL2.Inline.Bind.Features {
+		if f.Var == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// ============================================================================
+// Client nonzas
+
+// SASL2Authenticate starts the authentication, with the initial response of the mechanism and the
+// requests to process once authenticated.
+type SASL2Authenticate struct {
+	XMLName         xml.Name      `xml:"urn:xmpp:sasl:2 authenticate"`
+	Mechanism       string        `xml:"mechanism,attr"`
+	InitialResponse string        `xml:"initial-response,omitempty"`
+	Resume          *SMResume     `xml:",omitempty"`
+	Bind            *Bind2Request `xml:",omitempty"`
+}
+
+// SASL2Response is the client answer to a SASL2 challenge.
+type SASL2Response struct {
+	XMLName xml.Name `xml:"urn:xmpp:sasl:2 response"`
+	Value   string   `xml:",chardata"`
+}
+
+// Bind2Request binds a resource, whose name is generated by the server from the tag identifying the
+// client, and enables the features inline.
+type Bind2Request struct {
+	XMLName xml.Name       `xml:"urn:xmpp:bind:0 bind"`
+	Tag     string         `xml:"tag,omitempty"`
+	Carbons *CarbonsEnable `xml:",omitempty"`
+	SM      *SMEnable      `xml:",omitempty"`
+}
+
+// ============================================================================
+// Server nonzas
+
+// SASL2Challenge is sent by the server during the SASL2 negotiation, for challenge-response
+// mechanisms.
+type SASL2Challenge struct {
+	XMLName xml.Name `xml:"urn:xmpp:sasl:2 challenge"`
+	// Challenge data, base64 encoded
+	Value string `xml:",chardata"`
+}
+
+func (SASL2Challenge) Name() string {
+	return "sasl2:challenge"
+}
+
+// SASL2Success ends a successful authentication, with the result of the inline requests. The stream
+// is not restarted.
+type SASL2Success struct {
+	XMLName xml.Name `xml:"urn:xmpp:sasl:2 success"`
+	// Additional data of the mechanism, base64 encoded
+	AdditionalData string `xml:"additional-data,omitempty"`
+	// AuthorizationIdentifier is the full JID bound, or the bare JID of the account
+	AuthorizationIdentifier string      `xml:"authorization-identifier"`
+	Bound                   *Bind2Bound `xml:",omitempty"`
+	Resumed                 *SMResumed  `xml:",omitempty"`
+	// SMFailed is set when the inline resumption failed
+	SMFailed *SMFailed `xml:",omitempty"`
+}
+
+func (SASL2Success) Name() string {
+	return "sasl2:success"
+}
+
+// Bind2Bound is the result of the Bind 2 request, with the result of the features enabled inline.
+type Bind2Bound struct {
+	XMLName   xml.Name   `xml:"urn:xmpp:bind:0 bound"`
+	SMEnabled *SMEnabled `xml:",omitempty"`
+	SMFailed  *SMFailed  `xml:",omitempty"`
+}
+
+// SASL2Failure ends a failed authentication. Condition is the SASL error condition.
+type SASL2Failure struct {
+	XMLName   xml.Name `xml:"urn:xmpp:sasl:2 failure"`
+	Condition xml.Name `xml:"-"`
+	Text      string   `xml:"text,omitempty"`
+}
+
+func (SASL2Failure) Name() string {
+	return "sasl2:failure"
+}
+
+func (f *SASL2Failure) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	f.XMLName = start.Name
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			if tt.Name.Local == "text" {
+				if err = d.DecodeElement(&f.Text, &tt); err != nil {
+					return err
+				}
+				continue
+			}
+			if tt.Name.Space == NSSASL {
+				f.Condition = tt.Name
+			}
+			if err = d.Skip(); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			if tt == start.End() {
+				return nil
+			}
+		}
+	}
+}
+
+// SASL2Continue asks the client to complete additional tasks, such as a second factor, before the
+// authentication succeeds.
+type SASL2Continue struct {
+	XMLName xml.Name `xml:"urn:xmpp:sasl:2 continue"`
+	Tasks   []string `xml:"tasks>task"`
+	Text    string   `xml:"text,omitempty"`
+}
+
+func (SASL2Continue) Name() string {
+	return "sasl2:continue"
+}
+
+type sasl2Decoder struct{}
+
+var sasl2 sasl2Decoder
+
+// decode decodes the nonzas sent by the server in the SASL2 namespace.
+func (sasl2Decoder) decode(p *xml.Decoder, se xml.StartElement) (Packet, error) {
+	switch se.Name.Local {
+	case "challenge":
+		var packet SASL2Challenge
+		err := p.DecodeElement(&packet, &se)
+		return packet, err
+	case "success":
+		var packet SASL2Success
+		err := p.DecodeElement(&packet, &se)
+		return packet, err
+	case "failure":
+		var packet SASL2Failure
+		err := p.DecodeElement(&packet, &se)
+		return packet, err
+	case "continue":
+		var packet SASL2Continue
+		err := p.DecodeElement(&packet, &se)
+		return packet, err
+	default:
+		return nil, errors.New("unexpected XMPP packet " +
+			se.Name.Space + " <" + se.Name.Local + "/>")
+	}
+}
@@ -0,0 +1,80 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestSASL2Features(t *testing.T) {
+	streamFeatures := `<stream:features xmlns:stream='http://etherx.jabber.org/streams'>
+  <mechanisms xmlns='urn:ietf:params:xml:ns:xmpp-sasl'>
+    <mechanism>SCRAM-SHA-1</mechanism>
+  </mechanisms>
+  <authentication xmlns='urn:xmpp:sasl:2'>
+    <mechanism>SCRAM-SHA-1</mechanism>
+    <mechanism>PLAIN</mechanism>
+    <inline>
+      <bind xmlns='urn:xmpp:bind:0'>
+        <inline>
+          <feature var='urn:xmpp:carbons:2'/>
+        </inline>
+      </bind>
+    </inline>
+  </authentication>
+</stream:features>`
+
+	var parsedSF stanza.StreamFeatures
+	if err := xml.Unmarshal([]byte(streamFeatures), &parsedSF); err != nil {
+		t.Fatalf("Unmarshal(%s) returned error: %v", streamFeatures, err)
+	}
+	if !parsedSF.DoesSASL2() || !parsedSF.DoesBind2() || parsedSF.DoesSASL2Resume() {
+		t.Errorf("unexpected SASL2 support: %+v", parsedSF.SASL2)
+	}
+	if len(parsedSF.SASL2.Mechanisms) != 2 || parsedSF.SASL2.Mechanisms[1] != "PLAIN" {
+		t.Errorf("unexpected SASL2 mechanisms: %v", parsedSF.SASL2.Mechanisms)
+	}
+	if !parsedSF.Bind2Inline(stanza.NSCarbons) || parsedSF.Bind2Inline(stanza.NSStreamManagement) {
+		t.Errorf("unexpected inline features: %+v", parsedSF.SASL2.Inline.Bind)
+	}
+}
+
+func TestSASL2Failure(t *testing.T) {
+	failure := `<failure xmlns='urn:xmpp:sasl:2'>
+  <aborted xmlns='urn:ietf:params:xml:ns:xmpp-sasl'/>
+  <text>This is a terrible example.</text>
+</failure>`
+	packet, err := stanza.NextPacket(xml.NewDecoder(strings.NewReader(failure)))
+	if err != nil {
+		t.Fatalf("cannot decode failure: %s", err)
+	}
+	f, ok := packet.(stanza.SASL2Failure)
+	if !ok || f.Condition.Local != "aborted" || f.Text != "This is a terrible example." {
+		t.Errorf("unexpected failure: %#v", packet)
+	}
+}
+
+func TestSASL2Authenticate(t *testing.T) {
+	resume := true
+	auth := stanza.SASL2Authenticate{
+		Mechanism:       "PLAIN",
+		InitialResponse: "AHRlc3QAdGVzdA==",
+		Bind: &stanza.Bind2Request{
+			Tag:     "Test Client",
+			Carbons: &stanza.CarbonsEnable{},
+			SM:      &stanza.SMEnable{Resume: &resume},
+		},
+	}
+	data, err := xml.Marshal(auth)
+	if err != nil {
+		t.Fatalf("cannot marshal authenticate: %s", err)
+	}
+	expected := `<authenticate xmlns="urn:xmpp:sasl:2" mechanism="PLAIN"><initial-response>AHRlc3QAdGVzdA==</initial-response>` +
+		`<bind xmlns="urn:xmpp:bind:0"><tag>Test Client</tag><enable xmlns="urn:xmpp:carbons:2"></enable>` +
+		`<enable xmlns="urn:xmpp:sm:3" resume="true"></enable></bind></authenticate>`
+	if string(data) != expected {
+		t.Errorf("unexpected authenticate:\n%s\nexpected:\n%s", data, expected)
+	}
+}
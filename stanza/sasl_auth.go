@@ -14,11 +14,45 @@ type SASLAuth struct {
 
 // ============================================================================
 
+// SASLChallenge is sent by the server during the SASL negotiation, for challenge-response mechanisms.
+// Reference: https://tools.ietf.org/html/rfc6120#section-6.4.3
+type SASLChallenge struct {
+	XMLName xml.Name `xml:"urn:ietf:params:xml:ns:xmpp-sasl challenge"`
+	// Challenge data, base64 encoded
+	Value string `xml:",chardata"`
+}
+
+func (SASLChallenge) Name() string {
+	return "sasl:challenge"
+}
+
+// SASLChallenge decoding
+type saslChallengeDecoder struct{}
+
+var saslChallenge saslChallengeDecoder
+
+func (saslChallengeDecoder) decode(p *xml.Decoder, se xml.StartElement) (SASLChallenge, error) {
+	var packet SASLChallenge
+	err := p.DecodeElement(&packet, &se)
+	return packet, err
+}
+
+// SASLResponse is the client answer to a SASL challenge.
+// Reference: https://tools.ietf.org/html/rfc6120#section-6.4.3
+type SASLResponse struct {
+	XMLName xml.Name `xml:"urn:ietf:params:xml:ns:xmpp-sasl response"`
+	Value   string   `xml:",innerxml"`
+}
+
+// ============================================================================
+
 // SASLSuccess implements SASL Success nonza, sent by server as a result of the
 // SASL auth negotiation.
 // Reference: https://tools.ietf.org/html/rfc6120#section-6.4.6
 type SASLSuccess struct {
 	XMLName xml.Name `xml:"urn:ietf:params:xml:ns:xmpp-sasl success"`
+	// Additional data with success, base64 encoded
+	Value string `xml:",chardata"`
 }
 
 func (SASLSuccess) Name() string {
@@ -119,6 +153,6 @@ func (s *StreamSession) IsOptional() bool {
 // Registry init
 
 func init() {
-	TypeRegistry.MapExtension(PKTIQ, xml.Name{Space: "urn:ietf:params:xml:ns:xmpp-bind", Local: "bind"}, Bind{})
-	TypeRegistry.MapExtension(PKTIQ, xml.Name{Space: "urn:ietf:params:xml:ns:xmpp-session", Local: "session"}, StreamSession{})
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: "urn:ietf:params:xml:ns:xmpp-bind", Local: "bind"}, Bind{})
+	TypeRegistry.MustRegister(PKTIQ, xml.Name{Space: "urn:ietf:params:xml:ns:xmpp-session", Local: "session"}, StreamSession{})
 }
@@ -2,6 +2,7 @@ package stanza
 
 import (
 	"encoding/xml"
+	"errors"
 )
 
 // ============================================================================
@@ -17,8 +18,11 @@ type StreamFeatures struct {
 	// Stream features
 	StartTLS         TlsStartTLS
 	Mechanisms       saslMechanisms
+	SASL2            SASL2Authentication
+	ChannelBinding   saslChannelBinding
 	Bind             Bind
 	StreamManagement streamManagement
+	Limits           StreamLimits
 	// Obsolete
 	Session StreamSession
 	// ProcessOne Stream Features
@@ -112,6 +116,24 @@ type saslMechanisms struct {
 	Mechanism []string `xml:"mechanism"`
 }
 
+// SASL channel binding types supported by the server
+// Reference: XEP-0440 - https://xmpp.org/extensions/xep-0440.html
+type saslChannelBinding struct {
+	XMLName xml.Name `xml:"urn:xmpp:sasl-cb:0 sasl-channel-binding"`
+	Types   []struct {
+		Type string `xml:"type,attr"`
+	} `xml:"channel-binding"`
+}
+
+// ChannelBindingTypes returns the SASL channel binding types advertised by the server.
+func (sf *StreamFeatures) ChannelBindingTypes() []string {
+	var types []string
+	for _, cb := range sf.ChannelBinding.Types {
+		types = append(types, cb.Type)
+	}
+	return types
+}
+
 // StreamManagement
 // Reference: XEP-0198 - https://xmpp.org/extensions/xep-0198.html#feature
 type streamManagement struct {
@@ -170,6 +192,31 @@ func (streamErrorDecoder) decode(p *xml.Decoder, se xml.StartElement) (StreamErr
 	return packet, err
 }
 
+// InterruptedStanzaError is returned by the decoder when the server closes the stream with a stream
+// error while a stanza is being received. The stanza is incomplete and is dropped.
+type InterruptedStanzaError struct {
+	// Stanza is the name of the interrupted stanza element
+	Stanza      string
+	StreamError StreamError
+}
+
+func (e *InterruptedStanzaError) Error() string {
+	return "stream error while receiving <" + e.Stanza + ">: " + e.StreamError.Error.Local
+}
+
+// interruptedStanza decodes an element of the streams namespace found in a stanza. Only stream errors
+// are expected, as the server writes them to the stream without completing the current stanza.
+func interruptedStanza(p *xml.Decoder, stanza, se xml.StartElement) error {
+	if se.Name.Local != "error" {
+		return errors.New("unexpected stream element <" + se.Name.Local + "/> in <" + stanza.Name.Local + "/>")
+	}
+	streamErr, err := streamError.decode(p, se)
+	if err != nil {
+		return err
+	}
+	return &InterruptedStanzaError{Stanza: stanza.Name.Local, StreamError: streamErr}
+}
+
 // ============================================================================
 // StreamClose "Packet"
 
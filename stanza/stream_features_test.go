@@ -2,6 +2,7 @@ package stanza_test
 
 import (
 	"encoding/xml"
+	"strings"
 	"testing"
 
 	"gosrc.io/xmpp/stanza"
@@ -62,3 +63,25 @@ func TestStreamManagement(t *testing.T) {
 		t.Error("Stream Management feature should have been detected")
 	}
 }
+
+func TestStreamErrorInsideStanza(t *testing.T) {
+	for _, name := range []string{"message", "presence", "iq"} {
+		stream := `<stream:stream xmlns='jabber:client' xmlns:stream='http://etherx.jabber.org/streams'>
+<` + name + ` id='1' type='get'><body>Wherefore art thou</body>
+<stream:error><conflict xmlns='urn:ietf:params:xml:ns:xmpp-streams'/><text xmlns='urn:ietf:params:xml:ns:xmpp-streams'>Replaced by new connection</text></stream:error>
+</stream:stream>`
+		d := xml.NewDecoder(strings.NewReader(stream))
+		if _, err := stanza.NextStart(d); err != nil {
+			t.Fatalf("could not read stream open: %v", err)
+		}
+		_, err := stanza.NextPacket(d)
+		interrupted, ok := err.(*stanza.InterruptedStanzaError)
+		if !ok {
+			t.Fatalf("%s: expected an InterruptedStanzaError, got %v", name, err)
+		}
+		if interrupted.Stanza != name || interrupted.StreamError.Error.Local != "conflict" ||
+			interrupted.StreamError.Text != "Replaced by new connection" {
+			t.Errorf("%s: unexpected stream error: %+v", name, interrupted)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package stanza
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// ============================================================================
+// Stream Limits Advertisement (XEP-0478)
+
+const NSStreamLimits = "urn:xmpp:limits:1"
+
+// StreamLimits are the limits the server applies to the stream, advertised in the stream features.
+// Zero values mean that no limit was advertised.
+type StreamLimits struct {
+	XMLName xml.Name `xml:"urn:xmpp:limits:1 limits"`
+	// MaxBytes is the maximum size of a stanza, in bytes
+	MaxBytes int `xml:"max-bytes,omitempty"`
+	// IdleSeconds is the number of seconds without traffic after which the server may close the stream
+	IdleSeconds int `xml:"idle-seconds,omitempty"`
+}
+
+// IdleTimeout returns the advertised idle timeout, or zero.
+func (l StreamLimits) IdleTimeout() time.Duration {
+	return time.Duration(l.IdleSeconds) * time.Second
+}
+
+// DoesStreamLimits tells if the server advertises the limits of the stream.
+func (sf *StreamFeatures) DoesStreamLimits() bool {
+	return sf.Limits.XMLName.Space == NSStreamLimits && sf.Limits.XMLName.Local == "limits"
+}
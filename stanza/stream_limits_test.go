@@ -0,0 +1,44 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestStreamLimits(t *testing.T) {
+	streamFeatures := `<stream:features xmlns:stream='http://etherx.jabber.org/streams'>
+  <bind xmlns='urn:ietf:params:xml:ns:xmpp-bind'/>
+  <limits xmlns='urn:xmpp:limits:1'>
+    <max-bytes>262144</max-bytes>
+    <idle-seconds>840</idle-seconds>
+  </limits>
+</stream:features>`
+
+	var parsedSF stanza.StreamFeatures
+	if err := xml.Unmarshal([]byte(streamFeatures), &parsedSF); err != nil {
+		t.Fatalf("Unmarshal(%s) returned error: %v", streamFeatures, err)
+	}
+	if !parsedSF.DoesStreamLimits() {
+		t.Fatal("stream limits should be advertised")
+	}
+	if parsedSF.Limits.MaxBytes != 262144 || parsedSF.Limits.IdleTimeout() != 840*time.Second {
+		t.Errorf("unexpected limits: %+v", parsedSF.Limits)
+	}
+}
+
+func TestNoStreamLimits(t *testing.T) {
+	streamFeatures := `<stream:features xmlns:stream='http://etherx.jabber.org/streams'>
+  <bind xmlns='urn:ietf:params:xml:ns:xmpp-bind'/>
+</stream:features>`
+
+	var parsedSF stanza.StreamFeatures
+	if err := xml.Unmarshal([]byte(streamFeatures), &parsedSF); err != nil {
+		t.Fatalf("Unmarshal(%s) returned error: %v", streamFeatures, err)
+	}
+	if parsedSF.DoesStreamLimits() || parsedSF.Limits.MaxBytes != 0 || parsedSF.Limits.IdleTimeout() != 0 {
+		t.Errorf("no limits should be advertised: %+v", parsedSF.Limits)
+	}
+}
@@ -318,7 +318,11 @@ func (smf *SMFailed) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error
 				err = d.DecodeElement(&xnwf, &tt)
 				smf.StreamErrorGroup = &xnwf
 			default:
-				return errors.New("error is unknown")
+				// Other conditions, like the item-not-found sent when a session to resume has
+				// expired, are reported as undefined
+				uc := UndefinedCondition{}
+				err = d.Skip()
+				smf.StreamErrorGroup = &uc
 			}
 			if err != nil {
 				return err
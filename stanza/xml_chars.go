@@ -0,0 +1,134 @@
+package stanza
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"reflect"
+	"unicode/utf8"
+)
+
+// ============================================================================
+// XML 1.0 character handling
+// Reference: https://www.w3.org/TR/xml/#charsets
+
+// InvalidCharPolicy tells how packets containing characters not allowed in XML 1.0, or invalid UTF-8
+// sequences, are handled when they are marshaled to be sent.
+type InvalidCharPolicy uint8
+
+const (
+	// InvalidCharReplace replaces invalid characters with U+FFFD.
+	InvalidCharReplace InvalidCharPolicy = iota
+	// InvalidCharError refuses to marshal packets containing invalid characters.
+	InvalidCharError
+)
+
+var ErrInvalidXMLChar = errors.New("invalid XML character")
+
+// MarshalPacket marshals a packet for sending, making sure the result contains only characters
+// allowed in XML, whatever the content of its text fields, including raw XML fields. Stanzas are
+// marshaled in the default namespace of the stream they are sent on, whatever the stream they were
+// decoded from.
+func MarshalPacket(p Packet, policy InvalidCharPolicy) ([]byte, error) {
+	p = inheritStreamNamespace(p)
+	if policy == InvalidCharError {
+		if err := checkText(reflect.ValueOf(p), p.Name()); err != nil {
+			return nil, err
+		}
+	}
+	data, err := xml.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	// Escaped text is already sanitized by the encoder, but raw XML is written as is
+	return sanitizeXML(data), nil
+}
+
+// IsValidXMLText tells if the text is valid UTF-8, and only contains characters allowed in XML.
+func IsValidXMLText(s string) bool {
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 || !isXMLChar(r) {
+			return false
+		}
+		i += size
+	}
+	return true
+}
+
+// SanitizeXMLText replaces invalid UTF-8 sequences and characters not allowed in XML with U+FFFD.
+func SanitizeXMLText(s string) string {
+	if IsValidXMLText(s) {
+		return s
+	}
+	return string(sanitizeXML([]byte(s)))
+}
+
+func sanitizeXML(data []byte) []byte {
+	valid := true
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size == 1 || !isXMLChar(r) {
+			valid = false
+			break
+		}
+		i += size
+	}
+	if valid {
+		return data
+	}
+
+	out := make([]byte, 0, len(data)+8)
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size == 1 || !isXMLChar(r) {
+			out = append(out, "\uFFFD"...)
+		} else {
+			out = append(out, data[i:i+size]...)
+		}
+		i += size
+	}
+	return out
+}
+
+// isXMLChar checks the Char production of the XML 1.0 specification.
+func isXMLChar(r rune) bool {
+	return r == 0x09 || r == 0x0A || r == 0x0D ||
+		r >= 0x20 && r <= 0xD7FF ||
+		r >= 0xE000 && r <= 0xFFFD ||
+		r >= 0x10000 && r <= 0x10FFFF
+}
+
+// checkText walks the exported string fields of v, and returns an error on the first one containing
+// invalid characters. Byte slices are binary data, and are not checked.
+func checkText(v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.String:
+		if !IsValidXMLText(v.String()) {
+			return fmt.Errorf("%w in %s", ErrInvalidXMLChar, path)
+		}
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			return checkText(v.Elem(), path)
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := checkText(v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			if f := t.Field(i); f.PkgPath == "" {
+				if err := checkText(v.Field(i), path+"."+f.Name); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
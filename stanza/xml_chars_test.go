@@ -0,0 +1,124 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestSanitizeXMLText(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"hello", "hello"},
+		{"tab\tnewline\ncr\r", "tab\tnewline\ncr\r"},
+		{"nul\x00bell\x07esc\x1b", "nul\uFFFDbell\uFFFDesc\uFFFD"},
+		// Lone surrogate, as produced by bad UTF-16 transcoding, is invalid UTF-8
+		{"lone \xed\xa0\x80 surrogate", "lone \uFFFD\uFFFD\uFFFD surrogate"},
+		{"truncated \xe2\x82", "truncated \uFFFD\uFFFD"},
+		{"non character \uFFFE", "non character \uFFFD"},
+		{"emoji 😀 and accents é", "emoji 😀 and accents é"},
+	}
+	for _, tc := range tests {
+		if got := stanza.SanitizeXMLText(tc.input); got != tc.expected {
+			t.Errorf("SanitizeXMLText(%q) = %q, expected %q", tc.input, got, tc.expected)
+		}
+		if stanza.IsValidXMLText(tc.input) != (tc.input == tc.expected) {
+			t.Errorf("unexpected validity for %q", tc.input)
+		}
+	}
+}
+
+// randomText returns text built from untrusted bytes, mixing random bytes with markup and invalid sequences.
+func randomText(r *rand.Rand) string {
+	chunks := []string{"\x00", "\x08", "\x0b", "\uFFFE", "\xed\xa0\x80", "\xff", "<", "&", "]]>", "é", "😀", " "}
+	var sb strings.Builder
+	for i := r.Intn(40); i >= 0; i-- {
+		if r.Intn(2) == 0 {
+			sb.WriteString(chunks[r.Intn(len(chunks))])
+		} else {
+			sb.WriteByte(byte(r.Intn(256)))
+		}
+	}
+	return sb.String()
+}
+
+func TestMarshalPacketUntrustedText(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	for i := 0; i < 500; i++ {
+		msg := stanza.NewMessage(stanza.Attrs{Type: stanza.MessageTypeChat, To: "juliet@capulet.lit", Id: randomText(r)})
+		msg.Body = randomText(r)
+		msg.Subject = randomText(r)
+		msg.Thread = randomText(r)
+		// Raw XML field, written as is by the encoder
+		msg.Extensions = append(msg.Extensions, stanza.HTML{Body: stanza.HTMLBody{InnerXML: "<p>" + randomText(r) + "</p>"}})
+		pres := stanza.NewPresence(stanza.Attrs{To: "room@muc.capulet.lit/" + randomText(r)})
+		pres.Status = stanza.NewLocalizedText(randomText(r))
+
+		for _, p := range []stanza.Packet{msg, pres} {
+			data, err := stanza.MarshalPacket(p, stanza.InvalidCharReplace)
+			if err != nil {
+				t.Fatalf("cannot marshal packet: %v", err)
+			}
+			if !stanza.IsValidXMLText(string(data)) {
+				t.Fatalf("marshaled packet contains invalid characters: %q", data)
+			}
+			// The decoder rejects characters not allowed in XML
+			if err = checkCharacters(data); err != nil {
+				t.Fatalf("marshaled packet cannot be parsed: %v\n%q", err, data)
+			}
+		}
+	}
+}
+
+// checkCharacters reads all the tokens of the XML document. Markup errors coming from the random
+// HTML content are not checked, only character errors.
+func checkCharacters(data []byte) error {
+	d := xml.NewDecoder(strings.NewReader(string(data)))
+	d.Strict = false
+	for {
+		_, err := d.Token()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			if strings.Contains(err.Error(), "illegal character") || strings.Contains(err.Error(), "invalid UTF-8") {
+				return err
+			}
+			return nil
+		}
+	}
+}
+
+func TestMarshalPacketInvalidCharError(t *testing.T) {
+	msg := stanza.NewMessage(stanza.Attrs{To: "juliet@capulet.lit"})
+	msg.Body = "valid body"
+	if _, err := stanza.MarshalPacket(msg, stanza.InvalidCharError); err != nil {
+		t.Errorf("valid message rejected: %v", err)
+	}
+
+	msg.Subject = "bad\x00subject"
+	_, err := stanza.MarshalPacket(msg, stanza.InvalidCharError)
+	if !errors.Is(err, stanza.ErrInvalidXMLChar) || !strings.Contains(err.Error(), "Subject") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	pres := stanza.NewPresence(stanza.Attrs{})
+	pres.Extensions = append(pres.Extensions, stanza.MucPresence{Password: "\xff"})
+	if _, err = stanza.MarshalPacket(pres, stanza.InvalidCharError); !errors.Is(err, stanza.ErrInvalidXMLChar) {
+		t.Errorf("invalid extension text not detected: %v", err)
+	}
+
+	// Binary data is not text
+	pgp := stanza.NewMessage(stanza.Attrs{To: "juliet@capulet.lit"})
+	pgp.Extensions = append(pgp.Extensions, stanza.OpenPGP{Data: []byte{0, 1, 0xff}})
+	if _, err = stanza.MarshalPacket(pgp, stanza.InvalidCharError); err != nil {
+		t.Errorf("binary data should not be checked: %v", err)
+	}
+}
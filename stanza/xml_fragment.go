@@ -0,0 +1,335 @@
+package stanza
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// ============================================================================
+// Opaque XML fragments
+
+// XMLFragment holds an element of foreign content, such as a Jingle transport, a security label or a
+// pubsub item of unknown type, to be sent again as received. It can be used as a field of any payload
+// struct: it is decoded from the element of the field and encoded back in place of it.
+//
+// The content of the element is kept byte for byte, including its prefixes, namespace declarations,
+// comments and CDATA sections. The start tag is rebuilt with the namespaces in scope declared
+// explicitly, including the ones declared by the ancestors of the element, so that the fragment means
+// the same wherever it is encoded. The prefixes of the start tag itself are not known to the decoder:
+// they are generated when not used by the content.
+type XMLFragment struct {
+	// XMLName is the name of the decoded element. It is not used for encoding.
+	XMLName xml.Name
+
+	start xml.StartElement
+	inner []byte
+}
+
+// NewXMLFragment decodes the XML element in data as a fragment.
+func NewXMLFragment(data []byte) (XMLFragment, error) {
+	var f XMLFragment
+	err := xml.Unmarshal(data, &f)
+	return f, err
+}
+
+// Bytes returns the XML of the fragment element, or nil for an empty fragment.
+func (f XMLFragment) Bytes() []byte {
+	if f.start.Name.Local == "" {
+		return nil
+	}
+	var buf bytes.Buffer
+	buf.WriteString("<" + f.start.Name.Local)
+	for _, attr := range f.start.Attr {
+		writeRawAttr(&buf, attr.Name.Local, attr.Value)
+	}
+	buf.WriteString(">")
+	buf.Write(f.inner)
+	buf.WriteString("</" + f.start.Name.Local + ">")
+	return buf.Bytes()
+}
+
+func (f XMLFragment) String() string {
+	return string(f.Bytes())
+}
+
+// Decode decodes the fragment element into v, once its type is known.
+func (f XMLFragment) Decode(v interface{}) error {
+	if f.start.Name.Local == "" {
+		return errors.New("empty XML fragment")
+	}
+	return xml.Unmarshal(f.Bytes(), v)
+}
+
+// MarshalXML encodes the fragment element as received, whatever the name of the field. An empty
+// fragment is not encoded.
+func (f XMLFragment) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
+	if f.start.Name.Local == "" {
+		return nil
+	}
+	// Names of the start element are qualified already: they are written as is, without the
+	// namespace prefixes generated by the encoder.
+	content := struct {
+		Inner []byte `xml:",innerxml"`
+	}{f.inner}
+	return e.EncodeElement(content, f.start)
+}
+
+// UnmarshalXML captures the element. Its content is kept as received, while its resolved names are
+// compared to the prefixes used, to find the namespaces the content inherits from the ancestors.
+func (f *XMLFragment) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var content struct {
+		Inner    []byte          `xml:",innerxml"`
+		Elements []fragmentNames `xml:",any"`
+	}
+	if err := d.DecodeElement(&content, &start); err != nil {
+		return err
+	}
+	var resolved []xml.StartElement
+	for _, e := range content.Elements {
+		resolved = append(resolved, e.starts...)
+	}
+
+	root := newFragmentScope(start)
+	inherited, err := inheritedNamespaces(d, root, content.Inner, resolved)
+	if err != nil {
+		return err
+	}
+	f.XMLName = start.Name
+	f.start = root.startElement(start, inherited)
+	f.inner = content.Inner
+	return nil
+}
+
+// fragmentNames collects the start elements of a child of the fragment and of its descendants, in
+// document order, with their names resolved by the decoder.
+type fragmentNames struct {
+	starts []xml.StartElement
+}
+
+func (n *fragmentNames) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	n.starts = append(n.starts, start.Copy())
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			if err = n.UnmarshalXML(d, tt); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// fragmentScope holds the namespace declarations of the fragment element.
+type fragmentScope struct {
+	// prefixes declared, in the order of the declarations
+	prefixes     []xml.Attr
+	defaultNS    string
+	hasDefaultNS bool
+}
+
+func newFragmentScope(start xml.StartElement) fragmentScope {
+	var s fragmentScope
+	for _, attr := range start.Attr {
+		switch {
+		case attr.Name.Space == "xmlns":
+			s.prefixes = append(s.prefixes, attr)
+		case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+			s.defaultNS, s.hasDefaultNS = attr.Value, true
+		}
+	}
+	return s
+}
+
+// declares tells if the prefix is declared on the fragment element, the empty prefix being the
+// default namespace.
+func (s fragmentScope) declares(prefix string) bool {
+	if prefix == "" {
+		return s.hasDefaultNS
+	}
+	for _, p := range s.prefixes {
+		if p.Name.Local == prefix {
+			return true
+		}
+	}
+	return false
+}
+
+// inheritedNamespaces returns the namespaces of the prefixes used in the content without being declared
+// in the fragment, by prefix. The empty prefix is the default namespace. The content is read again
+// without resolving the names, in the same order as the resolved start elements.
+func inheritedNamespaces(d *xml.Decoder, root fragmentScope, inner []byte, resolved []xml.StartElement) (map[string]string, error) {
+	inherited := make(map[string]string)
+	if len(resolved) == 0 {
+		return inherited, nil
+	}
+
+	raw := xml.NewDecoder(io.MultiReader(
+		bytes.NewReader([]byte("<fragment>")), bytes.NewReader(inner), bytes.NewReader([]byte("</fragment>"))))
+	raw.Strict = d.Strict
+	raw.AutoClose = d.AutoClose
+	raw.Entity = d.Entity
+
+	// Prefixes declared by the open elements of the content
+	var scopes [][]string
+	declared := func(prefix string) bool {
+		if prefix == "xml" || root.declares(prefix) {
+			return true
+		}
+		for _, scope := range scopes {
+			for _, p := range scope {
+				if p == prefix {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	next := 0
+	for {
+		t, err := raw.RawToken()
+		if err == io.EOF {
+			return inherited, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			if tt.Name.Local == "fragment" && tt.Name.Space == "" && scopes == nil {
+				// Wrapper element
+				scopes = [][]string{}
+				continue
+			}
+			if next >= len(resolved) {
+				return nil, errors.New("xml fragment: content does not match the decoded elements")
+			}
+			res := resolved[next]
+			next++
+			if len(res.Attr) != len(tt.Attr) || res.Name.Local != tt.Name.Local {
+				return nil, errors.New("xml fragment: content does not match the decoded elements")
+			}
+
+			var scope []string
+			for _, attr := range tt.Attr {
+				switch {
+				case attr.Name.Space == "xmlns":
+					scope = append(scope, attr.Name.Local)
+				case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+					scope = append(scope, "")
+				}
+			}
+			scopes = append(scopes, scope)
+
+			if !declared(tt.Name.Space) {
+				inherited[tt.Name.Space] = res.Name.Space
+			}
+			for i, attr := range tt.Attr {
+				// Attributes without prefix are not in a namespace
+				if attr.Name.Space == "" || attr.Name.Space == "xmlns" {
+					continue
+				}
+				if !declared(attr.Name.Space) {
+					inherited[attr.Name.Space] = res.Attr[i].Name.Space
+				}
+			}
+		case xml.EndElement:
+			if len(scopes) > 0 {
+				scopes = scopes[:len(scopes)-1]
+			}
+		}
+	}
+}
+
+// startElement rebuilds the start element of the fragment, declaring the namespaces inherited by the
+// content. Its names are qualified, to be written as is.
+func (s fragmentScope) startElement(start xml.StartElement, inherited map[string]string) xml.StartElement {
+	// Default namespace of the content
+	defaultNS, hasDefaultNS := s.defaultNS, s.hasDefaultNS
+	if !hasDefaultNS {
+		defaultNS, hasDefaultNS = inherited[""]
+	}
+	if !hasDefaultNS {
+		defaultNS, hasDefaultNS = start.Name.Space, start.Name.Space != ""
+	}
+
+	var inheritedPrefixes []string
+	for prefix := range inherited {
+		if prefix != "" {
+			inheritedPrefixes = append(inheritedPrefixes, prefix)
+		}
+	}
+	sort.Strings(inheritedPrefixes)
+
+	// prefixOf returns the prefix of a namespace, declaring a new one if needed
+	var generated []xml.Attr
+	prefixOf := func(space string) string {
+		for _, p := range s.prefixes {
+			if p.Value == space {
+				return p.Name.Local
+			}
+		}
+		for _, prefix := range inheritedPrefixes {
+			if inherited[prefix] == space {
+				return prefix
+			}
+		}
+		for _, p := range generated {
+			if p.Value == space {
+				return p.Name.Local
+			}
+		}
+		for i := 0; ; i++ {
+			prefix := "ns" + strconv.Itoa(i)
+			if _, ok := inherited[prefix]; !ok && !s.declares(prefix) {
+				generated = append(generated, xml.Attr{Name: xml.Name{Space: "xmlns", Local: prefix}, Value: space})
+				return prefix
+			}
+		}
+	}
+
+	name := start.Name.Local
+	if start.Name.Space != "" && start.Name.Space != defaultNS {
+		name = prefixOf(start.Name.Space) + ":" + name
+	}
+
+	var attrs []xml.Attr
+	if hasDefaultNS {
+		attrs = append(attrs, xml.Attr{Name: xml.Name{Local: "xmlns"}, Value: defaultNS})
+	}
+	for _, attr := range start.Attr {
+		local := attr.Name.Local
+		switch attr.Name.Space {
+		case "":
+			if local == "xmlns" {
+				continue
+			}
+		case "xmlns":
+			local = "xmlns:" + local
+		case nsXMLPrefix, "xml":
+			local = "xml:" + local
+		default:
+			local = prefixOf(attr.Name.Space) + ":" + local
+		}
+		attrs = append(attrs, xml.Attr{Name: xml.Name{Local: local}, Value: attr.Value})
+	}
+	for _, prefix := range inheritedPrefixes {
+		if prefix == "xml" {
+			continue
+		}
+		attrs = append(attrs, xml.Attr{Name: xml.Name{Local: "xmlns:" + prefix}, Value: inherited[prefix]})
+	}
+	for _, attr := range generated {
+		attrs = append(attrs, xml.Attr{Name: xml.Name{Local: "xmlns:" + attr.Name.Local}, Value: attr.Value})
+	}
+	return xml.StartElement{Name: xml.Name{Local: name}, Attr: attrs}
+}
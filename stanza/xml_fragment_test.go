@@ -0,0 +1,154 @@
+package stanza_test
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+type fragmentPayload struct {
+	XMLName xml.Name           `xml:"payload"`
+	ID      string             `xml:"id,attr,omitempty"`
+	Content stanza.XMLFragment `xml:",any"`
+}
+
+func decodeFragment(t *testing.T, payload string) stanza.XMLFragment {
+	t.Helper()
+	var p fragmentPayload
+	if err := xml.Unmarshal([]byte(payload), &p); err != nil {
+		t.Fatalf("could not unmarshal payload: %v", err)
+	}
+	return p.Content
+}
+
+func TestXMLFragmentPrefixedNamespaces(t *testing.T) {
+	const content = `<x:candidate x:component='1' xmlns:y="urn:y" y:ip="10.0.0.1"/>
+      <x:remote-candidate x:component='1'/>`
+	fragment := decodeFragment(t, `<payload xmlns="urn:example:payload">`+
+		`<transport xmlns='urn:xmpp:jingle:transports:ice-udp:1' xmlns:x='urn:x' pwd='asd88fgpdd777uzjYhagZg'>`+
+		content+`</transport></payload>`)
+
+	if fragment.XMLName.Space != "urn:xmpp:jingle:transports:ice-udp:1" || fragment.XMLName.Local != "transport" {
+		t.Errorf("unexpected fragment name: %v", fragment.XMLName)
+	}
+	expected := `<transport xmlns="urn:xmpp:jingle:transports:ice-udp:1" xmlns:x="urn:x" pwd="asd88fgpdd777uzjYhagZg">` +
+		content + `</transport>`
+	if fragment.String() != expected {
+		t.Errorf("unexpected fragment:\n%s\nexpected:\n%s", fragment, expected)
+	}
+}
+
+func TestXMLFragmentInheritedPrefixes(t *testing.T) {
+	// Prefixes used by the fragment are declared on the payload
+	fragment := decodeFragment(t, `<payload xmlns="urn:example:payload" xmlns:sl="urn:example:label" xmlns:c="urn:example:color">`+
+		`<sl:label c:color='red'><sl:text>SECRET</sl:text><marking/></sl:label></payload>`)
+
+	// The prefixes of the fragment element are not known to the decoder: they are replaced, unless used
+	// by the content
+	expected := `<sl:label xmlns="urn:example:payload" ns0:color="red" xmlns:sl="urn:example:label" xmlns:ns0="urn:example:color">` +
+		`<sl:text>SECRET</sl:text><marking/></sl:label>`
+	if fragment.String() != expected {
+		t.Errorf("unexpected fragment:\n%s\nexpected:\n%s", fragment, expected)
+	}
+
+	// The fragment means the same out of its payload
+	var label struct {
+		XMLName xml.Name  `xml:"urn:example:label label"`
+		Color   string    `xml:"urn:example:color color,attr"`
+		Text    string    `xml:"urn:example:label text"`
+		Marking *struct{} `xml:"urn:example:payload marking"`
+	}
+	if err := fragment.Decode(&label); err != nil {
+		t.Fatalf("could not decode fragment: %v", err)
+	}
+	if label.Color != "red" || label.Text != "SECRET" || label.Marking == nil {
+		t.Errorf("unexpected label: %+v", label)
+	}
+}
+
+func TestXMLFragmentDefaultNamespaceChanges(t *testing.T) {
+	const content = `<title>Soliloquy</title>` +
+		`<geoloc xmlns='http://jabber.org/protocol/geoloc'><lat>45.44</lat><ext xmlns="urn:ext"><v/></ext></geoloc>` +
+		`<summary>To be, or not to be</summary>`
+	fragment := decodeFragment(t, `<payload xmlns="urn:example:payload">`+
+		`<entry xmlns='http://www.w3.org/2005/Atom'>`+content+`</entry></payload>`)
+
+	expected := `<entry xmlns="http://www.w3.org/2005/Atom">` + content + `</entry>`
+	if fragment.String() != expected {
+		t.Errorf("unexpected fragment:\n%s\nexpected:\n%s", fragment, expected)
+	}
+
+	// Prefixed fragment element, with a content in the default namespace of the payload
+	fragment = decodeFragment(t, `<payload xmlns="urn:example:payload" xmlns:f="urn:example:form">`+
+		`<f:data><value>1</value></f:data></payload>`)
+	expected = `<ns0:data xmlns="urn:example:payload" xmlns:ns0="urn:example:form"><value>1</value></ns0:data>`
+	if fragment.String() != expected {
+		t.Errorf("unexpected fragment:\n%s\nexpected:\n%s", fragment, expected)
+	}
+}
+
+func TestXMLFragmentCDATA(t *testing.T) {
+	const content = `<![CDATA[<b>bold</b> & "quoted"]]><!-- kept --><script>if (a &lt; b) {}</script>`
+	fragment := decodeFragment(t, `<payload xmlns="urn:example:payload"><code xmlns="urn:example:code">`+content+`</code></payload>`)
+
+	expected := `<code xmlns="urn:example:code">` + content + `</code>`
+	if fragment.String() != expected {
+		t.Errorf("unexpected fragment:\n%s\nexpected:\n%s", fragment, expected)
+	}
+
+	var code struct {
+		Text string `xml:",chardata"`
+	}
+	if err := fragment.Decode(&code); err != nil {
+		t.Fatalf("could not decode fragment: %v", err)
+	}
+	if code.Text != `<b>bold</b> & "quoted"` {
+		t.Errorf("unexpected text: %q", code.Text)
+	}
+}
+
+func TestXMLFragmentMarshal(t *testing.T) {
+	fragment := decodeFragment(t, `<payload xmlns="urn:example:payload" xmlns:sl="urn:example:label">`+
+		`<sl:label><![CDATA[a<b]]><sl:text xmlns:x='urn:x' x:lang='en'>SECRET</sl:text></sl:label></payload>`)
+
+	// Encoded in a payload of another namespace, without prefixes rewritten by the encoder
+	type otherPayload struct {
+		XMLName xml.Name           `xml:"urn:example:other payload"`
+		ID      string             `xml:"id,attr"`
+		Content stanza.XMLFragment `xml:",any"`
+	}
+	data, err := xml.Marshal(otherPayload{ID: "1", Content: fragment})
+	if err != nil {
+		t.Fatalf("could not marshal payload: %v", err)
+	}
+	expected := `<payload xmlns="urn:example:other" id="1">` + fragment.String() + `</payload>`
+	if string(data) != expected {
+		t.Errorf("unexpected payload:\n%s\nexpected:\n%s", data, expected)
+	}
+
+	// Decoding the encoded fragment gives the same fragment
+	if again := decodeFragment(t, string(data)); again.String() != fragment.String() {
+		t.Errorf("fragment changed after encoding:\n%s\nexpected:\n%s", again, fragment)
+	}
+
+	// Fragments built from XML are encoded as well
+	built, err := stanza.NewXMLFragment([]byte(`<hint xmlns='urn:example:hint' level='2'/>`))
+	if err != nil {
+		t.Fatalf("could not build fragment: %v", err)
+	}
+	if data, err = xml.Marshal(fragmentPayload{Content: built}); err != nil {
+		t.Fatalf("could not marshal payload: %v", err)
+	}
+	if expected = `<payload><hint xmlns="urn:example:hint" level="2"></hint></payload>`; string(data) != expected {
+		t.Errorf("unexpected payload:\n%s\nexpected:\n%s", data, expected)
+	}
+
+	// Empty fragments are not encoded
+	if data, err = xml.Marshal(fragmentPayload{ID: "2"}); err != nil {
+		t.Fatalf("could not marshal payload: %v", err)
+	}
+	if expected = `<payload id="2"></payload>`; string(data) != expected {
+		t.Errorf("unexpected payload:\n%s\nexpected:\n%s", data, expected)
+	}
+}
@@ -0,0 +1,49 @@
+package xmpp
+
+import (
+	"fmt"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Stream Limits Advertisement (XEP-0478)
+
+// StanzaTooLargeError is returned by Send when the stanza exceeds the maximum size advertised by the
+// server. The stanza is not sent, as the server would close the stream.
+type StanzaTooLargeError struct {
+	Size     int
+	MaxBytes int
+}
+
+func (e *StanzaTooLargeError) Error() string {
+	return fmt.Sprintf("stanza of %d bytes exceeds the server limit of %d bytes", e.Size, e.MaxBytes)
+}
+
+// StreamLimits returns the limits advertised by the server for the current stream, for example to
+// size the uploads. Zero values mean that no limit was advertised.
+func (c *Client) StreamLimits() stanza.StreamLimits {
+	if c.Session == nil {
+		return stanza.StreamLimits{}
+	}
+	return c.Session.Features.Limits
+}
+
+// checkStanzaSize rejects the stanza if it exceeds the maximum size of the limits.
+func checkStanzaSize(limits stanza.StreamLimits, data []byte) error {
+	if limits.MaxBytes > 0 && len(data) > limits.MaxBytes {
+		return &StanzaTooLargeError{Size: len(data), MaxBytes: limits.MaxBytes}
+	}
+	return nil
+}
+
+// keepaliveInterval returns the interval of the keepalive packets. When it was not configured, it is
+// kept below the idle timeout advertised by the server.
+func keepaliveInterval(config *Config, limits stanza.StreamLimits) time.Duration {
+	interval := config.KeepaliveInterval
+	if idle := limits.IdleTimeout(); config.defaultKeepalive && idle > 0 && interval >= idle {
+		interval = idle / 2
+	}
+	return interval
+}
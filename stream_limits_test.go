@@ -0,0 +1,48 @@
+package xmpp
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestSendStanzaTooLarge(t *testing.T) {
+	client := &Client{config: &Config{}, transport: &XMPPTransport{}}
+	client.Session = &Session{Features: stanza.StreamFeatures{Limits: stanza.StreamLimits{MaxBytes: 100}}}
+
+	msg := stanza.NewMessage(stanza.Attrs{To: "juliet@capulet.lit"})
+	msg.Body = strings.Repeat("a", 100)
+	var tooLarge *StanzaTooLargeError
+	if err := client.Send(msg); !errors.As(err, &tooLarge) || tooLarge.MaxBytes != 100 || tooLarge.Size <= 100 {
+		t.Errorf("large stanza should be rejected: %v", err)
+	}
+	if err := client.SendRaw("<message>" + msg.Body + "</message>"); !errors.As(err, &tooLarge) {
+		t.Errorf("large raw stanza should be rejected: %v", err)
+	}
+
+	// Smaller stanzas are written to the transport, which is not connected
+	msg.Body = "a"
+	if err := client.Send(msg); err == nil || errors.As(err, &tooLarge) {
+		t.Errorf("small stanza should be written: %v", err)
+	}
+}
+
+func TestKeepaliveIntervalIdleLimit(t *testing.T) {
+	limits := stanza.StreamLimits{IdleSeconds: 20}
+	config := &Config{KeepaliveInterval: 30 * time.Second, defaultKeepalive: true}
+	if interval := keepaliveInterval(config, limits); interval != 10*time.Second {
+		t.Errorf("default interval should be below the idle limit: %s", interval)
+	}
+	if interval := keepaliveInterval(config, stanza.StreamLimits{}); interval != 30*time.Second {
+		t.Errorf("unexpected interval without limit: %s", interval)
+	}
+
+	// Configured intervals are kept
+	config.defaultKeepalive = false
+	if interval := keepaliveInterval(config, limits); interval != 30*time.Second {
+		t.Errorf("configured interval should be kept: %s", interval)
+	}
+}
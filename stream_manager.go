@@ -113,22 +113,24 @@ func (sm *StreamManager) Stop() {
 }
 
 func (sm *StreamManager) connect() error {
-	if sm.client != nil {
-		if c, ok := sm.client.(*Client); ok {
-			if c.CurrentState.getState() == StateDisconnected {
-				sm.Metrics = initMetrics()
-				err := c.Connect()
-				if err != nil {
-					return err
-				}
-				if sm.PostConnect != nil {
-					sm.PostConnect(sm.client)
-				}
-				return nil
-			}
-		}
+	var state *SyncConnState
+	switch c := sm.client.(type) {
+	case *Client:
+		state = &c.CurrentState
+	case *Component:
+		state = &c.CurrentState
+	}
+	if state == nil || state.getState() != StateDisconnected {
+		return errors.New("client is not disconnected")
+	}
+	sm.Metrics = initMetrics()
+	if err := sm.client.Connect(); err != nil {
+		return err
+	}
+	if sm.PostConnect != nil {
+		sm.PostConnect(sm.client)
 	}
-	return errors.New("client is not disconnected")
+	return nil
 }
 
 // resume manages the reconnection loop and apply the define backoff to avoid overloading the server.
@@ -146,7 +148,12 @@ func (sm *StreamManager) resume() error {
 					return xerrors.Errorf("unrecoverable connect error %#v", actualErr)
 				}
 			}
-			backoff.wait()
+			if c, ok := sm.client.(*Client); ok && c.endpoints.len() > 0 {
+				// Each endpoint has its own backoff
+				c.endpoints.wait()
+			} else {
+				backoff.wait()
+			}
 		} else { // We are connected, we can leave the retry loop
 			break
 		}
@@ -30,6 +30,9 @@ const (
 	testSendRawPort
 	testDisconnectPort
 	testSManDisconnectPort
+	testComponentSMPort
+	testComponentSMResumePort
+	testComponentNoSMPort
 
 	// Client tests
 	testClientBasePort
@@ -37,9 +40,22 @@ const (
 	testClientIqPort
 	testClientIqFailPort
 	testClientPostConnectHook
+	testClientStreamErrorPort
+	testClientCSIPort
+	testClientSASL2Port
+	testClientPreApprovalPort
+	testClientDecodeLimitsPort
+	testClientFailoverDownPort
+	testClientFailoverPort
+	testClientForcedFailoverPort
+	testClientForcedFailoverNextPort
+	testClientDeliveryPort
+	testClientSkippedTokensPort
+	testClientUnexpectedCharDataPort
 
 	// Client internal tests
 	testClientStreamManagement
+	testClientSMImport
 )
 
 // ClientHandler is passed by the test client to provide custom behaviour to
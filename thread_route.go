@@ -0,0 +1,164 @@
+package xmpp
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+// ============================================================================
+// Thread based message correlation
+
+// ErrThreadAwaited is returned when a reply is already awaited for the same thread key: a single
+// wait can be registered for a key, so that the reply it receives is deterministic.
+var ErrThreadAwaited = errors.New("a reply is already awaited in this thread")
+
+// ErrNoThread is returned by SendAndAwait when the message has no thread or no recipient to correlate
+// the reply with.
+var ErrNoThread = errors.New("message has no thread or no recipient to await a reply from")
+
+// ThreadKey identifies the conversation a reply is awaited in. Replies are matched on the address
+// they are sent to, the bare JID of their sender, and their <thread/> element.
+//
+// From is our address in the conversation: a component can use any of the JIDs of its domain, so
+// the key keeps the one the conversation was started from. An empty From matches replies sent to
+// any of our addresses, as clients usually receive them on their bare or full JID.
+type ThreadKey struct {
+	From   string
+	Peer   string
+	Thread string
+}
+
+// threadKeyFor returns the key of a message we sent.
+func threadKeyFor(msg stanza.Message) ThreadKey {
+	return newThreadKey(msg.From, msg.To, msg.Thread)
+}
+
+// newThreadKey normalizes the JIDs of a key: JIDs are compared case-insensitively, and the peer is
+// the bare JID, as the reply can come from any of its resources.
+func newThreadKey(from, peer, thread string) ThreadKey {
+	return ThreadKey{
+		From:   strings.ToLower(from),
+		Peer:   strings.ToLower(bareJid(peer)),
+		Thread: thread,
+	}
+}
+
+// threadRoute is a temporary route matching the next reply in a thread.
+type threadRoute struct {
+	result chan stanza.Message
+}
+
+// NewThreadRoute registers a route that will catch the next message received in the thread of the
+// key. The route only matches once, after which it is unregistered and the channel is closed. When
+// the context is done before a reply is received, the route is unregistered and the channel is
+// closed without value.
+//
+// ErrThreadAwaited is returned if a reply is already awaited for the same key.
+func (r *Router) NewThreadRoute(ctx context.Context, key ThreadKey) (chan stanza.Message, error) {
+	key = newThreadKey(key.From, key.Peer, key.Thread)
+	route, err := r.addThreadRoute(key)
+	if err != nil {
+		return nil, err
+	}
+
+	go r.expireThreadRoute(ctx, key, route)
+
+	return route.result, nil
+}
+
+func (r *Router) addThreadRoute(key ThreadKey) (*threadRoute, error) {
+	r.threadRoutesLock.Lock()
+	defer r.threadRoutesLock.Unlock()
+	if _, ok := r.threadRoutes[key]; ok {
+		return nil, ErrThreadAwaited
+	}
+	if r.threadRoutes == nil {
+		r.threadRoutes = make(map[ThreadKey]*threadRoute)
+	}
+	route := &threadRoute{result: make(chan stanza.Message, 1)}
+	r.threadRoutes[key] = route
+	return route, nil
+}
+
+// expireThreadRoute makes sure the route is unregistered when the context is done.
+func (r *Router) expireThreadRoute(ctx context.Context, key ThreadKey, route *threadRoute) {
+	<-ctx.Done()
+	r.removeThreadRoute(key, route)
+}
+
+// removeThreadRoute unregisters the route of the key, if it was not resolved, and closes its channel.
+func (r *Router) removeThreadRoute(key ThreadKey, route *threadRoute) {
+	r.threadRoutesLock.Lock()
+	defer r.threadRoutesLock.Unlock()
+	if r.threadRoutes[key] != route {
+		return
+	}
+	delete(r.threadRoutes, key)
+	close(route.result)
+}
+
+// resolveThreadRoute delivers a received message to the route awaiting a reply in its thread, if any.
+// The route registered for the exact recipient is preferred to the one matching any recipient.
+func (r *Router) resolveThreadRoute(msg stanza.Message) bool {
+	if msg.Thread == "" {
+		return false
+	}
+	keys := []ThreadKey{
+		newThreadKey(msg.To, msg.From, msg.Thread),
+		newThreadKey("", msg.From, msg.Thread),
+	}
+
+	r.threadRoutesLock.Lock()
+	defer r.threadRoutesLock.Unlock()
+	for _, key := range keys {
+		if route, ok := r.threadRoutes[key]; ok {
+			delete(r.threadRoutes, key)
+			route.result <- msg
+			close(route.result)
+			return true
+		}
+	}
+	return false
+}
+
+// sendAndAwait registers the route for the reply before sending the message, so that a fast reply
+// cannot be missed, and unregisters it if the message cannot be sent.
+func sendAndAwait(ctx context.Context, r *Router, send func(stanza.Packet) error, msg stanza.Message) (chan stanza.Message, error) {
+	if msg.Thread == "" || msg.To == "" {
+		return nil, ErrNoThread
+	}
+	key := threadKeyFor(msg)
+	route, err := r.addThreadRoute(key)
+	if err != nil {
+		return nil, err
+	}
+	if err = send(msg); err != nil {
+		r.removeThreadRoute(key, route)
+		return nil, err
+	}
+	go r.expireThreadRoute(ctx, key, route)
+	return route.result, nil
+}
+
+// SendAndAwait sends a message and returns a channel receiving the next message of the recipient in
+// the same thread. The message must have a <thread/> element and a recipient. The reply is matched on
+// the from address of the message, which is empty by default to accept replies sent to any of the
+// addresses of the client.
+//
+// The provided context should have a timeout: the channel is closed without value when it is done.
+func (c *Client) SendAndAwait(ctx context.Context, msg stanza.Message) (chan stanza.Message, error) {
+	return sendAndAwait(ctx, c.router, c.Send, msg)
+}
+
+// SendAndAwait sends a message and returns a channel receiving the next message of the recipient in
+// the same thread. The message must have a <thread/> element and a recipient, and its from address
+// should be set to the JID of the component the conversation is held from: the reply is only
+// matched when it is sent back to that JID.
+//
+// The provided context should have a timeout: the channel is closed without value when it is done.
+func (c *Component) SendAndAwait(ctx context.Context, msg stanza.Message) (chan stanza.Message, error) {
+	return sendAndAwait(ctx, c.router, c.Send, msg)
+}
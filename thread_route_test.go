@@ -0,0 +1,116 @@
+package xmpp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func threadMessage(from, to, thread, body string) stanza.Message {
+	msg := stanza.NewMessage(stanza.Attrs{From: from, To: to, Type: stanza.MessageTypeChat})
+	msg.Thread = thread
+	msg.Body = body
+	return msg
+}
+
+func TestSendAndAwaitThreadReply(t *testing.T) {
+	router := NewRouter()
+	routed := make(chan string, 10)
+	router.HandleFunc("message", func(_ Sender, p stanza.Packet) {
+		routed <- p.(stanza.Message).Body
+	})
+	conn := NewSenderMock()
+	ctx, cancel := context.WithTimeout(context.Background(), defaultChannelTimeout)
+	defer cancel()
+
+	// The component holds conversations in the same thread from two of its JIDs
+	question := threadMessage("support@desk.example.com", "juliet@capulet.lit/balcony", "t1", "How can we help?")
+	support, err := sendAndAwait(ctx, router, conn.Send, question)
+	if err != nil {
+		t.Fatalf("cannot send message: %v", err)
+	}
+	sales, err := sendAndAwait(ctx, router, conn.Send,
+		threadMessage("sales@desk.example.com", "juliet@capulet.lit/balcony", "t1", "Anything to buy?"))
+	if err != nil {
+		t.Fatalf("cannot send message: %v", err)
+	}
+
+	// A second wait on the same key is rejected
+	if _, err = sendAndAwait(ctx, router, conn.Send, question); !errors.Is(err, ErrThreadAwaited) {
+		t.Errorf("expected ErrThreadAwaited, got %v", err)
+	}
+	if _, err = sendAndAwait(ctx, router, conn.Send, threadMessage("", "juliet@capulet.lit", "", "")); err != ErrNoThread {
+		t.Errorf("expected ErrNoThread, got %v", err)
+	}
+
+	// Replies in other threads, or sent to other JIDs, are routed as usual
+	router.route(conn, threadMessage("juliet@capulet.lit/balcony", "support@desk.example.com", "t2", "other thread"))
+	router.route(conn, threadMessage("juliet@capulet.lit/balcony", "billing@desk.example.com", "t1", "other jid"))
+	// The reply can come from another resource of the peer
+	router.route(conn, threadMessage("Juliet@capulet.lit/chamber", "sales@desk.example.com", "t1", "No"))
+	router.route(conn, threadMessage("juliet@capulet.lit/balcony", "support@desk.example.com", "t1", "Help me"))
+
+	for res, expected := range map[chan stanza.Message]string{support: "Help me", sales: "No"} {
+		select {
+		case reply, ok := <-res:
+			if !ok || reply.Body != expected {
+				t.Errorf("unexpected reply %+v, expected %q", reply, expected)
+			}
+		case <-time.After(defaultChannelTimeout):
+			t.Fatalf("reply %q was not received", expected)
+		}
+	}
+	if len(router.threadRoutes) != 0 {
+		t.Errorf("resolved routes should be removed: %v", router.threadRoutes)
+	}
+
+	close(routed)
+	bodies := map[string]bool{}
+	for body := range routed {
+		bodies[body] = true
+	}
+	if len(bodies) != 2 || !bodies["other thread"] || !bodies["other jid"] {
+		t.Errorf("unexpected routed messages: %v", bodies)
+	}
+}
+
+func TestThreadRouteExpires(t *testing.T) {
+	router := NewRouter()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	key := ThreadKey{Peer: "romeo@montague.lit/orchard", Thread: "t1"}
+	res, err := router.NewThreadRoute(ctx, key)
+	if err != nil {
+		t.Fatalf("cannot register route: %v", err)
+	}
+	cancel()
+	select {
+	case reply, ok := <-res:
+		if ok {
+			t.Errorf("expired route should not receive %+v", reply)
+		}
+	case <-time.After(defaultChannelTimeout):
+		t.Fatal("expired route channel was not closed")
+	}
+
+	// The key can be awaited again once the route expired, and a route without our address matches
+	// replies sent to any of them
+	if res, err = router.NewThreadRoute(context.Background(), key); err != nil {
+		t.Fatalf("cannot register route again: %v", err)
+	}
+	router.route(NewSenderMock(), threadMessage("romeo@montague.lit/garden", "juliet@capulet.lit", "t1", "Hi"))
+	if reply := <-res; reply.Body != "Hi" {
+		t.Errorf("unexpected reply: %+v", reply)
+	}
+
+	// A route is removed when the message cannot be sent
+	sendErr := errors.New("not connected")
+	_, err = sendAndAwait(context.Background(), router, func(stanza.Packet) error { return sendErr },
+		threadMessage("", "romeo@montague.lit", "t2", "Hello"))
+	if err != sendErr || len(router.threadRoutes) != 0 {
+		t.Errorf("expected send error and no route, got %v, %v", err, router.threadRoutes)
+	}
+}
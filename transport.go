@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"io"
 	"strings"
+
+	"gosrc.io/xmpp/stanza"
 )
 
 var ErrTransportProtocolNotSupported = errors.New("transport protocol not supported")
@@ -21,8 +23,28 @@ type TransportConfiguration struct {
 	ConnectTimeout int // Client timeout in seconds. Default to 15
 	// tls.Config must not be modified after having been passed to NewClient. Any
 	// changes made after connecting are ignored.
-	TLSConfig     *tls.Config
+	TLSConfig *tls.Config
+	// DirectTLS opens the TLS session as soon as connected, instead of negotiating it with StartTLS.
+	// See XEP-0368: SRV records for XMPP over TLS. It is only supported by the XMPP native TCP transport.
+	DirectTLS bool
+	// TLSServerName, if set, is the name presented with SNI instead of the domain, for servers behind
+	// a load balancer routing on SNI. The certificate is still verified against the domain, unless
+	// TLSConfig sets InsecureSkipVerify. When TLSConfig sets VerifyPeerCertificate, the certificate
+	// must be valid for both names.
+	TLSServerName string
+	// ALPNProtocols is the list of protocols offered with ALPN. Default to "xmpp-client" with
+	// DirectTLS, as defined by XEP-0368, and to none with StartTLS.
+	ALPNProtocols []string
 	CharsetReader func(charset string, input io.Reader) (io.Reader, error) // passed to xml decoder
+	// DecodeErrorBufferSize is the number of last received bytes reported in DecodeError, when a
+	// received packet cannot be decoded. Defaults to 4096. A negative value disables it.
+	DecodeErrorBufferSize int
+	// ReadLimiter, if set, caps the rate of the bytes read from the server once the session is
+	// established. It is only supported by the XMPP native TCP transport.
+	ReadLimiter *ReadLimiter
+	// DecodeLimits bound the complexity of the received stanzas. The stream is closed with a
+	// policy-violation stream error when they are exceeded. Zero values use stanza.DefaultDecodeLimits.
+	DecodeLimits stanza.DecodeLimits
 }
 
 type Transport interface {
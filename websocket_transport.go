@@ -27,6 +27,8 @@ type WebsocketTransport struct {
 	wsConn  *websocket.Conn
 	queue   chan []byte
 	logFile io.Writer
+	// Last bytes received, to describe decoding errors
+	recorder *inboundRecorder
 
 	closeCtx  context.Context
 	closeFunc context.CancelFunc
@@ -60,7 +62,8 @@ func (t *WebsocketTransport) Connect() (string, error) {
 	t.wsConn = wsConn
 	t.startReader()
 
-	t.decoder = xml.NewDecoder(bufio.NewReaderSize(t, maxPacketSize))
+	t.recorder = newInboundRecorder(t, t.Config.DecodeErrorBufferSize)
+	t.decoder = xml.NewDecoder(bufio.NewReaderSize(stanza.NewDecodeLimiter(t.recorder, t.Config.DecodeLimits), maxPacketSize))
 	t.decoder.CharsetReader = t.Config.CharsetReader
 
 	return t.StartStream()
@@ -118,6 +121,10 @@ func (t WebsocketTransport) GetDomain() string {
 	return t.Config.Domain
 }
 
+func (t *WebsocketTransport) inbound() *inboundRecorder {
+	return t.recorder
+}
+
 func (t WebsocketTransport) GetDecoder() *xml.Decoder {
 	return t.decoder
 }
@@ -3,6 +3,7 @@ package xmpp
 import (
 	"bufio"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/xml"
 	"errors"
 	"fmt"
@@ -22,12 +23,21 @@ type XMPPTransport struct {
 	decoder       *xml.Decoder
 	conn          net.Conn
 	readWriter    io.ReadWriter
-	logFile       io.Writer
-	isSecure      bool
+	// Connection reader, limited by the configured ReadLimiter
+	limitedConn io.ReadWriter
+	logFile     io.Writer
+	isSecure    bool
+	// Last bytes received, to describe decoding errors
+	recorder *inboundRecorder
 	// Used to close TCP connection when a stream close message is received from the server
 	closeChan chan stanza.StreamClosePacket
+	// Events of the connection stages, for clients
+	trace dialTrace
 }
 
+// alpnXMPPClient is the ALPN protocol of client connections using direct TLS, defined by XEP-0368.
+const alpnXMPPClient = "xmpp-client"
+
 var componentStreamOpen = fmt.Sprintf("<?xml version='1.0'?><stream:stream to='%%s' xmlns='%s' xmlns:stream='%s'>", stanza.NSComponent, stanza.NSStream)
 
 var clientStreamOpen = fmt.Sprintf("<?xml version='1.0'?><stream:stream to='%%s' xmlns='%s' xmlns:stream='%s' version='1.0'>", stanza.NSClient, stanza.NSStream)
@@ -35,25 +45,48 @@ var clientStreamOpen = fmt.Sprintf("<?xml version='1.0'?><stream:stream to='%%s'
 func (t *XMPPTransport) Connect() (string, error) {
 	var err error
 
+	start := time.Now()
 	t.conn, err = net.DialTimeout("tcp", t.Config.Address, time.Duration(t.Config.ConnectTimeout)*time.Second)
+	t.traceDial(DialStageConnect, "", start, err)
 	if err != nil {
 		return "", NewConnError(err, true)
 	}
 
 	t.closeChan = make(chan stanza.StreamClosePacket)
-	t.readWriter = newStreamLogger(t.conn, t.logFile)
-	t.decoder = xml.NewDecoder(bufio.NewReaderSize(t.readWriter, maxPacketSize))
-	t.decoder.CharsetReader = t.Config.CharsetReader
+	if t.Config.DirectTLS {
+		start = time.Now()
+		err = t.StartTLS()
+		t.traceDial(DialStageTLS, transportTLSDetail(t), start, err)
+		if err != nil {
+			_ = t.conn.Close()
+			return "", NewConnError(err, true)
+		}
+	} else {
+		t.setConn(t.conn)
+	}
 	return t.StartStream()
 }
 
+// setConn sets up the readers and the decoder on the connection.
+func (t *XMPPTransport) setConn(conn net.Conn) {
+	t.conn = conn
+	t.limitedConn = newLimitedReadWriter(conn, t.Config.ReadLimiter)
+	t.readWriter = newStreamLogger(t.limitedConn, t.logFile)
+	t.recorder = newInboundRecorder(t.readWriter, t.Config.DecodeErrorBufferSize)
+	t.decoder = xml.NewDecoder(bufio.NewReaderSize(stanza.NewDecodeLimiter(t.recorder, t.Config.DecodeLimits), maxPacketSize))
+	t.decoder.CharsetReader = t.Config.CharsetReader
+}
+
 func (t *XMPPTransport) StartStream() (string, error) {
+	start := time.Now()
 	if _, err := fmt.Fprintf(t, t.openStatement, t.Config.Domain); err != nil {
+		t.traceDial(DialStageStream, "", start, err)
 		t.Close()
 		return "", NewConnError(err, true)
 	}
 
 	sessionID, err := stanza.InitStream(t.GetDecoder())
+	t.traceDial(DialStageStream, sessionID, start, err)
 	if err != nil {
 		t.Close()
 		return "", NewConnError(err, false)
@@ -61,6 +94,16 @@ func (t *XMPPTransport) StartStream() (string, error) {
 	return sessionID, nil
 }
 
+func (t *XMPPTransport) setDialTrace(trace dialTrace) {
+	t.trace = trace
+}
+
+func (t *XMPPTransport) traceDial(stage, detail string, start time.Time, err error) {
+	if t.trace != nil {
+		t.trace(stage, detail, start, err)
+	}
+}
+
 func (t *XMPPTransport) DoesStartTLS() bool {
 	return true
 }
@@ -73,20 +116,23 @@ func (t *XMPPTransport) GetDecoder() *xml.Decoder {
 	return t.decoder
 }
 
+func (t *XMPPTransport) inbound() *inboundRecorder {
+	return t.recorder
+}
+
+// limitReads applies the configured read limit, at the end of stream negotiation.
+func (t *XMPPTransport) limitReads() {
+	if l, ok := t.limitedConn.(*limitedReadWriter); ok {
+		l.limitReads()
+	}
+}
+
 func (t *XMPPTransport) IsSecure() bool {
 	return t.isSecure
 }
 
 func (t *XMPPTransport) StartTLS() error {
-	if t.Config.TLSConfig == nil {
-		t.TLSConfig = &tls.Config{}
-	} else {
-		t.TLSConfig = t.Config.TLSConfig.Clone()
-	}
-
-	if t.TLSConfig.ServerName == "" {
-		t.TLSConfig.ServerName = t.Config.Domain
-	}
+	t.TLSConfig = t.tlsConfig()
 	tlsConn := tls.Client(t.conn, t.TLSConfig)
 	// We convert existing connection to TLS
 	if err := tlsConn.Handshake(); err != nil {
@@ -94,11 +140,10 @@ func (t *XMPPTransport) StartTLS() error {
 	}
 
 	t.isSecure = false
-	t.conn = tlsConn
-	t.readWriter = newStreamLogger(tlsConn, t.logFile)
-	t.decoder = xml.NewDecoder(bufio.NewReaderSize(t.readWriter, maxPacketSize))
-	t.decoder.CharsetReader = t.Config.CharsetReader
+	t.setConn(tlsConn)
 
+	// When verification is skipped, the certificate was either checked against the domain with
+	// verifyDomainCertificate, or must not be checked at all
 	if !t.TLSConfig.InsecureSkipVerify {
 		if err := tlsConn.VerifyHostname(t.Config.Domain); err != nil {
 			return err
@@ -109,6 +154,62 @@ func (t *XMPPTransport) StartTLS() error {
 	return nil
 }
 
+// tlsConfig returns the TLS configuration, with the SNI and ALPN overrides.
+func (t *XMPPTransport) tlsConfig() *tls.Config {
+	var config *tls.Config
+	if t.Config.TLSConfig == nil {
+		config = &tls.Config{}
+	} else {
+		config = t.Config.TLSConfig.Clone()
+	}
+
+	if len(t.Config.ALPNProtocols) > 0 {
+		config.NextProtos = t.Config.ALPNProtocols
+	} else if t.Config.DirectTLS && len(config.NextProtos) == 0 {
+		config.NextProtos = []string{alpnXMPPClient}
+	}
+
+	if t.Config.TLSServerName == "" {
+		if config.ServerName == "" {
+			config.ServerName = t.Config.Domain
+		}
+		return config
+	}
+	config.ServerName = t.Config.TLSServerName
+	// The TLS handshake would verify the certificate against the SNI name: it is verified against
+	// the domain instead
+	if !config.InsecureSkipVerify && config.VerifyPeerCertificate == nil {
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = verifyDomainCertificate(t.Config.Domain, config.RootCAs)
+	}
+	return config
+}
+
+// verifyDomainCertificate returns a function verifying that the certificate chain presented by the
+// server is valid for domain. Nil roots means the system roots.
+func verifyDomainCertificate(domain string, roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("server did not present a certificate")
+		}
+		opts := x509.VerifyOptions{DNSName: domain, Roots: roots, Intermediates: x509.NewCertPool()}
+		var leaf *x509.Certificate
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			if i == 0 {
+				leaf = cert
+			} else {
+				opts.Intermediates.AddCert(cert)
+			}
+		}
+		_, err := leaf.Verify(opts)
+		return err
+	}
+}
+
 func (t *XMPPTransport) Ping() error {
 	n, err := t.conn.Write([]byte("\n"))
 	if err != nil {
@@ -120,6 +221,22 @@ func (t *XMPPTransport) Ping() error {
 	return nil
 }
 
+// WhitespacePing writes a single space character directly on the connection, as keepalive.
+// See RFC 6120 - 4.6.1 Whitespace Keepalives
+func (t *XMPPTransport) WhitespacePing() error {
+	if t.conn == nil {
+		return errors.New("cannot ping: not connected")
+	}
+	n, err := t.conn.Write([]byte(" "))
+	if err != nil {
+		return err
+	}
+	if n != 1 {
+		return errors.New("could not write whitespace ping")
+	}
+	return nil
+}
+
 func (t *XMPPTransport) Read(p []byte) (n int, err error) {
 	if t.readWriter == nil {
 		return 0, errors.New("cannot read: not connected, no readwriter")
@@ -0,0 +1,132 @@
+package xmpp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/xml"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"gosrc.io/xmpp/stanza"
+)
+
+func TestXMPPTransport_DirectTLSOverrides(t *testing.T) {
+	cert, roots := selfSignedCertificate(t, "capulet.lit")
+	hello := make(chan *tls.ClientHelloInfo, 1)
+	addr := startTLSServerMock(t, cert, hello)
+
+	transport := NewClientTransport(TransportConfiguration{
+		Address:        addr,
+		Domain:         "capulet.lit",
+		ConnectTimeout: 2,
+		TLSConfig:      &tls.Config{RootCAs: roots},
+		DirectTLS:      true,
+		TLSServerName:  "tenant1.lb.example.net",
+	})
+	if _, err := transport.Connect(); err != nil {
+		t.Fatalf("could not connect with direct TLS: %v", err)
+	}
+	info := <-hello
+	if info.ServerName != "tenant1.lb.example.net" {
+		t.Errorf("unexpected SNI: %q", info.ServerName)
+	}
+	if len(info.SupportedProtos) != 1 || info.SupportedProtos[0] != "xmpp-client" {
+		t.Errorf("unexpected ALPN protocols: %v", info.SupportedProtos)
+	}
+	if !transport.IsSecure() {
+		t.Errorf("direct TLS connection should be secure")
+	}
+}
+
+func TestXMPPTransport_DirectTLSVerifiesDomain(t *testing.T) {
+	// The certificate is valid for the SNI name, but not for the XMPP domain
+	cert, roots := selfSignedCertificate(t, "tenant1.lb.example.net")
+	hello := make(chan *tls.ClientHelloInfo, 1)
+	addr := startTLSServerMock(t, cert, hello)
+
+	transport := NewClientTransport(TransportConfiguration{
+		Address:        addr,
+		Domain:         "capulet.lit",
+		ConnectTimeout: 2,
+		TLSConfig:      &tls.Config{RootCAs: roots},
+		DirectTLS:      true,
+		TLSServerName:  "tenant1.lb.example.net",
+		ALPNProtocols:  []string{"xmpp-custom"},
+	})
+	if _, err := transport.Connect(); err == nil {
+		t.Fatal("certificate should be verified against the XMPP domain")
+	}
+	if info := <-hello; len(info.SupportedProtos) != 1 || info.SupportedProtos[0] != "xmpp-custom" {
+		t.Errorf("unexpected ALPN protocols: %v", info.SupportedProtos)
+	}
+}
+
+// startTLSServerMock accepts a single direct TLS connection, reports its client hello and opens
+// the stream.
+func startTLSServerMock(t *testing.T, cert tls.Certificate, hello chan<- *tls.ClientHelloInfo) string {
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"xmpp-client", "xmpp-custom"},
+		GetConfigForClient: func(info *tls.ClientHelloInfo) (*tls.Config, error) {
+			hello <- info
+			return nil, nil
+		},
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatalf("could not start TLS server: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if _, err = stanza.InitStream(xml.NewDecoder(conn)); err != nil {
+			return
+		}
+		_, _ = fmt.Fprintf(conn, serverStreamOpen, "localhost", defaultStreamID, stanza.NSClient, stanza.NSStream)
+		// Wait for the client to close the connection
+		_, _ = conn.Read(make([]byte, 1))
+	}()
+	return listener.Addr().String()
+}
+
+// selfSignedCertificate creates a certificate for host, and a pool trusting it.
+func selfSignedCertificate(t *testing.T, host string) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("could not create certificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("could not parse certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, roots
+}
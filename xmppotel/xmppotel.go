@@ -0,0 +1,79 @@
+// Package xmppotel traces XMPP IQ requests with OpenTelemetry.
+//
+// It is provided as a separate module, so that the xmpp package does not depend on OpenTelemetry.
+package xmppotel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gosrc.io/xmpp"
+	"gosrc.io/xmpp/stanza"
+)
+
+// Span attributes set on IQ spans
+const (
+	AttrStanzaID  = attribute.Key("xmpp.stanza.id")
+	AttrTo        = attribute.Key("xmpp.to")
+	AttrFrom      = attribute.Key("xmpp.from")
+	AttrIQType    = attribute.Key("xmpp.iq.type")
+	AttrNamespace = attribute.Key("xmpp.namespace")
+)
+
+// WithOpenTelemetry traces the IQ requests sent by the client with tracer. For example:
+//
+//	config := xmpp.Config{...}
+//	client, err := xmpp.NewClient(&config, router, errorHandler, xmppotel.WithOpenTelemetry(otel.Tracer("my-service")))
+//
+// Components can be traced by setting the ComponentOptions IQTracer to NewIQTracer(tracer).
+func WithOpenTelemetry(tracer trace.Tracer) xmpp.Option {
+	return func(config *xmpp.Config) {
+		config.IQTracer = NewIQTracer(tracer)
+	}
+}
+
+// IQTracer implements xmpp.IQTracer: it creates a span for each IQ request / response pair.
+// The span is a child of the span found in the context passed to SendIQ, if any.
+type IQTracer struct {
+	tracer trace.Tracer
+}
+
+func NewIQTracer(tracer trace.Tracer) *IQTracer {
+	return &IQTracer{tracer: tracer}
+}
+
+// StartIQ starts the span of the request. The returned context carries the span, so that the spans
+// started while waiting for the response are its children.
+func (t *IQTracer) StartIQ(ctx context.Context, iq *stanza.IQ) (context.Context, func(*stanza.IQ, error)) {
+	attrs := []attribute.KeyValue{
+		AttrStanzaID.String(iq.Id),
+		AttrTo.String(iq.To),
+		AttrFrom.String(iq.From),
+		AttrIQType.String(string(iq.Type)),
+	}
+	if iq.Payload != nil {
+		attrs = append(attrs, AttrNamespace.String(iq.Payload.Namespace()))
+	}
+	ctx, span := t.tracer.Start(ctx, "xmpp.iq "+string(iq.Type),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...))
+
+	return ctx, func(result *stanza.IQ, err error) {
+		switch {
+		case err != nil:
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		case result.Type == stanza.IQTypeError:
+			msg := "IQ error"
+			if result.Error != nil && result.Error.Reason != "" {
+				msg += ": " + result.Error.Reason
+			}
+			span.SetStatus(codes.Error, msg)
+		default:
+			span.SetStatus(codes.Ok, "")
+		}
+		span.End()
+	}
+}
@@ -0,0 +1,108 @@
+package xmppotel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gosrc.io/xmpp"
+	"gosrc.io/xmpp/stanza"
+)
+
+func newTestTracer() (*IQTracer, *tracetest.InMemoryExporter, *sdktrace.TracerProvider) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	return NewIQTracer(provider.Tracer("xmppotel-test")), exporter, provider
+}
+
+func TestIQSpan(t *testing.T) {
+	tracer, exporter, provider := newTestTracer()
+	defer provider.Shutdown(context.Background())
+
+	// Span of the application, the IQ span must be its child
+	ctx, parent := provider.Tracer("app").Start(context.Background(), "handle request")
+
+	iq, _ := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeGet, Id: "span1", From: "romeo@montague.net/orchard", To: "montague.net"})
+	iq.Version()
+	iqCtx, end := tracer.StartIQ(ctx, iq)
+	if len(exporter.GetSpans()) != 0 {
+		t.Fatalf("span must not end before the response is received")
+	}
+	iqSpan := trace.SpanFromContext(iqCtx).SpanContext()
+	end(&stanza.IQ{Attrs: stanza.Attrs{Type: stanza.IQTypeResult, Id: "span1"}}, nil)
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("IQ span is not a child of the application span")
+	}
+	if iqSpan.SpanID() != span.SpanContext.SpanID() {
+		t.Errorf("returned context does not carry the IQ span")
+	}
+	if span.Status.Code != codes.Ok {
+		t.Errorf("unexpected status: %+v", span.Status)
+	}
+	expected := map[attribute.Key]string{
+		AttrStanzaID:  "span1",
+		AttrTo:        "montague.net",
+		AttrFrom:      "romeo@montague.net/orchard",
+		AttrIQType:    "get",
+		AttrNamespace: "jabber:iq:version",
+	}
+	for _, attr := range span.Attributes {
+		if v, ok := expected[attr.Key]; ok {
+			if attr.Value.AsString() != v {
+				t.Errorf("unexpected %s attribute: %s", attr.Key, attr.Value.AsString())
+			}
+			delete(expected, attr.Key)
+		}
+	}
+	if len(expected) != 0 {
+		t.Errorf("missing span attributes: %v", expected)
+	}
+}
+
+func TestIQSpanErrors(t *testing.T) {
+	tracer, exporter, provider := newTestTracer()
+	defer provider.Shutdown(context.Background())
+
+	iq, _ := stanza.NewIQ(stanza.Attrs{Type: stanza.IQTypeSet, Id: "span2"})
+	_, end := tracer.StartIQ(context.Background(), iq)
+	end(&stanza.IQ{
+		Attrs: stanza.Attrs{Type: stanza.IQTypeError},
+		Error: &stanza.Err{Reason: "forbidden"},
+	}, nil)
+	_, end = tracer.StartIQ(context.Background(), iq)
+	end(nil, errors.New("no response"))
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if spans[0].Status.Code != codes.Error || spans[0].Status.Description != "IQ error: forbidden" {
+		t.Errorf("unexpected status for IQ error: %+v", spans[0].Status)
+	}
+	if spans[1].Status.Code != codes.Error || len(spans[1].Events) != 1 {
+		t.Errorf("error not recorded on span: %+v", spans[1])
+	}
+}
+
+func TestWithOpenTelemetry(t *testing.T) {
+	tracer, _, provider := newTestTracer()
+	defer provider.Shutdown(context.Background())
+
+	var config xmpp.Config
+	config.Apply(WithOpenTelemetry(provider.Tracer("xmppotel-test")))
+	if _, ok := config.IQTracer.(*IQTracer); !ok || tracer == nil {
+		t.Errorf("IQ tracer not set: %#v", config.IQTracer)
+	}
+}